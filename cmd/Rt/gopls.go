@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// findWithGopls asks gopls for the definition of the symbol at the cursor of the window Rt was
+// executed from, and acquires it in Anvil. If symbol is not empty, the occurrence of symbol
+// nearest the cursor is looked up instead.
+func findWithGopls(symbol string, ldr *AnvilLoader) {
+	if ldr.anvil == nil {
+		fmt.Printf("Rt: gopls lookup needs the Anvil API to find the cursor\n")
+		return
+	}
+
+	globalPath := os.Getenv("ANVIL_WIN_GLOBAL_PATH")
+	localPath := os.Getenv("ANVIL_WIN_LOCAL_PATH")
+	if globalPath == "" {
+		fmt.Printf("Rt: gopls lookup must be executed from a window holding a Go file\n")
+		return
+	}
+	if globalPath != localPath {
+		fmt.Printf("Rt: gopls lookup is not supported for the remote file %s. Generate a tags file on the remote host instead\n", globalPath)
+		return
+	}
+	if filepath.Ext(localPath) != ".go" {
+		fmt.Printf("Rt: gopls lookup only works for Go files, and %s is not one\n", localPath)
+		return
+	}
+
+	winId, err := strconv.Atoi(os.Getenv("ANVIL_WIN_ID"))
+	if err != nil {
+		fmt.Printf("Rt: gopls lookup must be executed from a window: %v\n", err)
+		return
+	}
+
+	win, err := ldr.anvil.Window(winId)
+	if err != nil {
+		fmt.Printf("Rt: getting the window failed: %v\n", err)
+		return
+	}
+	if win.Dirty {
+		fmt.Printf("Rt: %s has unsaved changes; gopls only sees the saved file\n", localPath)
+	}
+
+	cursors, err := ldr.anvil.WindowBodyCursors(win)
+	if err != nil {
+		fmt.Printf("Rt: getting the cursor position failed: %v\n", err)
+		return
+	}
+	if len(cursors) == 0 {
+		fmt.Printf("Rt: the window has no cursor\n")
+		return
+	}
+
+	r, err := ldr.anvil.WindowBody(win)
+	if err != nil {
+		fmt.Printf("Rt: getting the window body failed: %v\n", err)
+		return
+	}
+	body, err := io.ReadAll(r)
+	if err != nil {
+		fmt.Printf("Rt: reading the window body failed: %v\n", err)
+		return
+	}
+
+	offset, err := symbolOffset(body, cursors[0], symbol)
+	if err != nil {
+		fmt.Printf("Rt: %v\n", err)
+		return
+	}
+
+	fmt.Printf("Rt: asking gopls for the definition at byte %d of %s\n", offset, localPath)
+	loc, err := goplsDefinition(localPath, offset)
+	if err != nil {
+		fmt.Printf("Rt: %v\n", err)
+		return
+	}
+
+	path := makeLocaAbsolutelFileGlobal(loc)
+	fmt.Printf("%s\n", path)
+	ldr.acquire(path)
+}
+
+// symbolOffset returns the byte offset in body to look up. cursor is the rune offset of the
+// cursor. If symbol is empty the cursor is used, otherwise the start of the occurrence of
+// symbol as a whole identifier nearest the cursor.
+func symbolOffset(body []byte, cursor int, symbol string) (offset int, err error) {
+	for i := 0; i < cursor && offset < len(body); i++ {
+		_, sz := utf8.DecodeRune(body[offset:])
+		offset += sz
+	}
+
+	if symbol == "" {
+		return
+	}
+
+	best := -1
+	for i := 0; ; {
+		j := bytes.Index(body[i:], []byte(symbol))
+		if j < 0 {
+			break
+		}
+		start := i + j
+		end := start + len(symbol)
+		i = start + 1
+
+		if !isIdentifierBoundary(body, start, end) {
+			continue
+		}
+
+		if best < 0 || distanceToSpan(offset, start, end) < distanceToSpan(offset, best, best+len(symbol)) {
+			best = start
+		}
+	}
+
+	if best < 0 {
+		err = fmt.Errorf("'%s' does not appear in the window", symbol)
+		return
+	}
+	offset = best
+	return
+}
+
+func isIdentifierBoundary(body []byte, start, end int) bool {
+	isIdent := func(r rune) bool {
+		return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+	}
+
+	if start > 0 {
+		r, _ := utf8.DecodeLastRune(body[:start])
+		if isIdent(r) {
+			return false
+		}
+	}
+	if end < len(body) {
+		r, _ := utf8.DecodeRune(body[end:])
+		if isIdent(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// distanceToSpan returns how many bytes offset is from the span [start,end]. It is 0 if the
+// offset is within the span.
+func distanceToSpan(offset, start, end int) int {
+	switch {
+	case offset < start:
+		return start - offset
+	case offset > end:
+		return offset - end
+	}
+	return 0
+}
+
+// goplsDefinition runs gopls to find the definition of the identifier at the byte offset in
+// the Go file at path. It returns the location as path:line:col.
+func goplsDefinition(path string, offset int) (loc string, err error) {
+	cmd := exec.Command("gopls", "definition", fmt.Sprintf("%s:#%d", path, offset))
+	cmd.Dir = filepath.Dir(path)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if errors.Is(err, exec.ErrNotFound) {
+		err = fmt.Errorf("gopls is not installed or not in the PATH")
+		return
+	}
+	if err != nil {
+		err = fmt.Errorf("gopls failed: %v: %s", err, strings.TrimSpace(stderr.String()))
+		return
+	}
+
+	file, line, col, err := parseGoplsDefinition(string(out))
+	if err != nil {
+		return
+	}
+	loc = fmt.Sprintf("%s:%d:%d", file, line, col)
+	return
+}
+
+// goplsLocationRegexp matches the span at the start of the output of gopls definition, like
+// /src/main.go:12:6-10: defined here as func main()
+var goplsLocationRegexp = regexp.MustCompile(`^(.*):(\d+):(\d+)(?:-(?:\d+:)?\d+)?:`)
+
+func parseGoplsDefinition(out string) (file string, line, col int, err error) {
+	first, _, _ := strings.Cut(out, "\n")
+	m := goplsLocationRegexp.FindStringSubmatch(first)
+	if m == nil {
+		err = fmt.Errorf("gopls returned no definition: %s", strings.TrimSpace(out))
+		return
+	}
+
+	file = m[1]
+	line, _ = strconv.Atoi(m[2])
+	col, _ = strconv.Atoi(m[3])
+	return
+}
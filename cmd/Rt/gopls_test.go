@@ -0,0 +1,61 @@
+package main
+
+import "testing"
+
+func TestParseGoplsDefinition(t *testing.T) {
+	tests := []struct {
+		out       string
+		file      string
+		line, col int
+	}{
+		{"/src/main.go:12:6-10: defined here as func main()\nmain is the entry point\n", "/src/main.go", 12, 6},
+		{"/src/a.go:3:1-4:2: defined here as type T struct{}\n", "/src/a.go", 3, 1},
+		{`C:\src\a.go:7:2: defined here as var x int`, `C:\src\a.go`, 7, 2},
+	}
+
+	for _, tc := range tests {
+		file, line, col, err := parseGoplsDefinition(tc.out)
+		if err != nil {
+			t.Fatalf("parsing %q failed: %v", tc.out, err)
+		}
+		if file != tc.file || line != tc.line || col != tc.col {
+			t.Fatalf("for %q expected %s:%d:%d but got %s:%d:%d", tc.out, tc.file, tc.line, tc.col, file, line, col)
+		}
+	}
+
+	if _, _, _, err := parseGoplsDefinition("gopls: no identifier found\n"); err == nil {
+		t.Fatalf("expected output without a location to fail")
+	}
+}
+
+func TestSymbolOffset(t *testing.T) {
+	body := []byte("// é\nfoo := foobar(foo)\nfoo++\n")
+
+	tests := []struct {
+		name     string
+		cursor   int
+		symbol   string
+		expected int
+	}{
+		{"cursor only counts runes", 5, "", 6},
+		{"occurrence containing the cursor", 6, "foo", 6},
+		{"whole identifiers only", 17, "foo", 20},
+		{"nearest occurrence", 25, "foo", 25},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			offset, err := symbolOffset(body, tc.cursor, tc.symbol)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if offset != tc.expected {
+				t.Fatalf("expected offset %d but got %d", tc.expected, offset)
+			}
+		})
+	}
+
+	if _, err := symbolOffset(body, 0, "bar"); err == nil {
+		t.Fatalf("expected a symbol that isn't a whole identifier in the body to fail")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/jeffwilliams/anvil/internal/ctags"
 	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
 
 	"github.com/ogier/pflag"
@@ -15,10 +16,12 @@ import (
 
 // Ctags format: http://ctags.sourceforge.net/FORMAT
 
+var optGopls = pflag.BoolP("gopls", "g", false, "Ask gopls for the definition of the symbol at the cursor instead of searching tags files. The symbol argument is optional; if given, the occurrence nearest the cursor is looked up. This is also done when no tags file is found")
+
 func main() {
 	pflag.Parse()
 
-	if pflag.NArg() == 0 {
+	if pflag.NArg() == 0 && !*optGopls {
 		fmt.Printf("Rt: Pass the symbol to find as the first argument\n")
 		return
 	}
@@ -33,76 +36,33 @@ func main() {
 		ldr.anvil = &anvil
 	}
 
-	c := make(chan string)
-	err = findAllTagsFiles(c)
-	if err != nil {
-		fmt.Printf("Rt: Can't load tags files: %v\n", err)
+	if *optGopls {
+		findWithGopls(tag, &ldr)
 		return
 	}
 
-	count := 0
-	for path := range c {
-		count++
-		found := searchInTagsFile(path, tag, printAnvilPathForTag, ldr.acquireInAnvil)
-		if found {
-			break
-		}
-	}
-	if count == 0 {
-		fmt.Printf("Rt: No tags file found\n")
-	}
-}
-
-func findTagsFile() (path string, err error) {
 	dir, err := os.Getwd()
 	if err != nil {
+		fmt.Printf("Rt: Can't load tags files: %v\n", err)
 		return
 	}
 
-	dir, err = filepath.Abs(dir)
+	paths, err := ctags.FindAll(dir)
 	if err != nil {
+		fmt.Printf("Rt: Can't load tags files: %v\n", err)
 		return
 	}
 
-	for dir != "/" {
-		f := filepath.Join(dir, "tags")
-		if _, err = os.Stat(f); err == nil {
-			path = f
-			return
+	for _, path := range paths {
+		found := searchInTagsFile(path, tag, printAnvilPathForTag, ldr.acquireInAnvil)
+		if found {
+			break
 		}
-
-		dir = filepath.Dir(dir)
-	}
-
-	err = os.ErrNotExist
-	return
-}
-
-func findAllTagsFiles(c chan string) (err error) {
-	dir, err := os.Getwd()
-	if err != nil {
-		return
 	}
-
-	dir, err = filepath.Abs(dir)
-	if err != nil {
-		return
+	if len(paths) == 0 {
+		fmt.Printf("Rt: No tags file found. Asking gopls instead\n")
+		findWithGopls(tag, &ldr)
 	}
-
-	go func() {
-		for dir != "/" {
-			f := filepath.Join(dir, "tags")
-			if _, err = os.Stat(f); err == nil {
-				c <- f
-			}
-
-			dir = filepath.Dir(dir)
-		}
-
-		close(c)
-	}()
-
-	return
 }
 
 type ActionWhenFound func(pathBuilder *PathBuilder, tag *Tag)
@@ -206,10 +166,17 @@ func (l *AnvilLoader) acquireInAnvil(pathBuilder *PathBuilder, tag *Tag) {
 	}
 
 	f := pathBuilder.AnvilPath(tag.Tagfile)
+	l.acquire(f + tag.AnvilAddress())
+}
+
+// acquire loads the Anvil path, which may include an address, in Anvil.
+func (l *AnvilLoader) acquire(path string) {
+	if l.anvil == nil {
+		return
+	}
 
-	b := []byte(fmt.Sprintf(`{"cmd": "Acq", "args": ["%s%s"], "winid": -1}`,
-		f,
-		insertEscapesForJson(tag.AnvilAddress())))
+	b := []byte(fmt.Sprintf(`{"cmd": "Acq", "args": ["%s"], "winid": -1}`,
+		insertEscapesForJson(path)))
 	cmd := bytes.NewReader(b)
 	fmt.Printf("Rt: sending command: %s\n", string(b))
 
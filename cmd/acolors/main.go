@@ -1,40 +1,64 @@
 package main
 
-// This is a tool to help darken or lighten the colors in an Anvil .js style file.
+// This is a tool to help adjust the colors in an Anvil .js style file.
 // It is a simple tool. Give it a style file or portion of one on stdin, and it will
 // search for hex colors of the form "#xxxxxx" where x are hex digits, and replace them
 // with a new color. The result is printed on stdout.
+//
+// The hue, saturation and value (in HSV) of the colors can be changed. The JSON keys the
+// colors belong to are tracked while the lines are scanned so that only the colors of
+// some keys can be changed using --keys.
 import (
 	"bufio"
 	"fmt"
+	"math"
 	"os"
+	"path"
 	"regexp"
 	"strconv"
+	"strings"
 
 	"github.com/crazy3lf/colorconv"
 	"github.com/spf13/pflag"
 )
 
 var optDarken = pflag.StringP("darken", "d", "", "Darken the colors. This option accepts a constant amount to reduce the Value of the color (in HSV) as a float, or a percentage as a number with a following % sign")
-var optLighten = pflag.StringP("lighten", "l", "", "Lighten the colors. This option accepts a constant amount to reduce the Value of the color (in HSV) as a float, or a percentage as a number with a following % sign")
+var optLighten = pflag.StringP("lighten", "l", "", "Lighten the colors. This option accepts a constant amount to increase the Value of the color (in HSV) as a float, or a percentage as a number with a following % sign")
+var optSaturate = pflag.String("saturate", "", "Saturate the colors. This option accepts a constant amount to increase the Saturation of the color (in HSV) as a float, or a percentage as a number with a following % sign")
+var optDesaturate = pflag.String("desaturate", "", "Desaturate the colors. This option accepts a constant amount to reduce the Saturation of the color (in HSV) as a float, or a percentage as a number with a following % sign")
+var optHueShift = pflag.Float64("hue-shift", 0, "Rotate the Hue of the colors (in HSV) by this many degrees. Negative values rotate the other way")
+var optKeys = pflag.String("keys", "", "Only change the colors of the JSON keys matching this comma-separated list of globs, like 'Syntax.*,TagBgColor'. Keys of nested objects are joined with dots. An element of an array is selected with an index or a range of indexes, like 'Ansi.Colors[8-15]'")
 
 func main() {
 	pflag.Usage = usage
 	pflag.Parse()
 
-	oper = newOp()
+	var err error
+	opers, err = newOps()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(1)
+	}
 
+	keys, err = parseKeyFilters(*optKeys)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "error parsing argument to --keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	var ctx jsonContext
 	scanner := bufio.NewScanner(os.Stdin)
 	lineno := 1
 	for scanner.Scan() {
 		line := scanner.Text()
-		line = updateLine(line, lineno)
+		line = updateLine(&ctx, line, lineno)
 		fmt.Printf("%s\n", line)
 		lineno++
 	}
 }
 
-var oper op
+var opers []op
+var keys []keyFilter
 
 type op struct {
 	opcode opcode
@@ -47,104 +71,322 @@ type opcode int
 const (
 	darken opcode = iota
 	lighten
+	saturate
+	desaturate
+	hueShift
 )
 
-func newOp() (o op) {
-	var amt string
-	if *optDarken != "" {
-		o.opcode = darken
-		amt = *optDarken
-	} else if *optLighten != "" {
-		o.opcode = lighten
-		amt = *optLighten
-	} else {
-		fmt.Fprintf(os.Stderr, "error: one of --lighten or --darken is needed\n")
-		os.Exit(1)
+// newOps returns the operations chosen on the command line. They are applied to each color in
+// the order hue, saturation, value.
+func newOps() (ops []op, err error) {
+	if *optHueShift != 0 {
+		ops = append(ops, op{opcode: hueShift, amt: *optHueShift})
 	}
 
+	args := []struct {
+		opt    *string
+		name   string
+		opcode opcode
+	}{
+		{optSaturate, "saturate", saturate},
+		{optDesaturate, "desaturate", desaturate},
+		{optDarken, "darken", darken},
+		{optLighten, "lighten", lighten},
+	}
+
+	for _, a := range args {
+		if *a.opt == "" {
+			continue
+		}
+		o, err := parseAmount(*a.opt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing argument to --%s: %v", a.name, err)
+		}
+		o.opcode = a.opcode
+		ops = append(ops, o)
+	}
+
+	if len(ops) == 0 {
+		err = fmt.Errorf("one of --lighten, --darken, --saturate, --desaturate or --hue-shift is needed")
+	}
+	return
+}
+
+// parseAmount parses an amount that is a float, or a percentage with a following % sign.
+func parseAmount(amt string) (o op, err error) {
 	l := len(amt)
 	if amt[l-1] == '%' {
 		o.isPct = true
 		amt = amt[:l-1]
 	}
 
-	v, err := strconv.ParseFloat(amt, 64)
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "error parsing argument to --lighten or --darken\n")
-		os.Exit(1)
-	}
-
-	o.amt = v
+	o.amt, err = strconv.ParseFloat(amt, 64)
 	return
 }
 
 func (o op) update(h, s, v *float64) {
-	scaleByPct := func(f float64) float64 {
-		if o.opcode == darken {
-			//fmt.Printf("darken %v by %v\n", f, o.amt)
-			return f - f*(o.amt/100)
+	adjust := func(f float64, increase bool) float64 {
+		amt := o.amt
+		if o.isPct {
+			amt = f * (o.amt / 100)
+		}
+		if increase {
+			f += amt
 		} else {
-			return f + f*(o.amt/100)
+			f -= amt
 		}
+		return math.Max(0, math.Min(1, f))
 	}
 
-	//oldv := *v
-	if o.isPct {
-		*v = scaleByPct(*v)
-	} else {
-		*v -= o.amt
+	switch o.opcode {
+	case darken:
+		*v = adjust(*v, false)
+	case lighten:
+		*v = adjust(*v, true)
+	case saturate:
+		*s = adjust(*s, true)
+	case desaturate:
+		*s = adjust(*s, false)
+	case hueShift:
+		*h = math.Mod(*h+o.amt, 360)
+		if *h < 0 {
+			*h += 360
+		}
 	}
-
-	//fmt.Printf("update: %f -> %f\n", oldv, *v)
 }
 
-var colorRegex = regexp.MustCompile(`"#[[:xdigit:]]{6}"`)
+var colorRegex = regexp.MustCompile(`^#[[:xdigit:]]{6}$`)
 
-func updateLine(line string, lineno int) (newline string) {
-	//matches := colorRegex.FindAllStringSubmatchIndex(line)
-	newline = line
-	skipLeading := 0
-	//for _, match := range matches {
-	for {
-		text := newline[skipLeading:]
-		match := colorRegex.FindStringSubmatchIndex(text)
-		if match == nil {
-			break
+// updateLine replaces the colors in line that belong to keys selected by --keys. ctx is the
+// JSON context at the start of the line, and is updated to the context at its end.
+func updateLine(ctx *jsonContext, line string, lineno int) (newline string) {
+	var buf strings.Builder
+	last := 0
+	ctx.scan(line, func(start, end int, key string) {
+		colortext := line[start:end]
+		if !colorRegex.MatchString(colortext[1:len(colortext)-1]) || !keySelected(key) {
+			return
 		}
-		//fmt.Printf("match: %#v\n", match)
-		colortext := text[match[0]:match[1]]
 		digits := colortext[2 : len(colortext)-1]
+
 		r, g, b, err := colorconv.HexToRGB(digits)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error on line %d, col %d: can't parse color %s: %v", lineno, match[0]+1, colortext, err)
-			continue
+			fmt.Fprintf(os.Stderr, "error on line %d, col %d: can't parse color %s: %v\n", lineno, start+1, colortext, err)
+			return
 		}
 
 		h, s, v := colorconv.RGBToHSV(r, g, b)
 		updateColor(&h, &s, &v)
 		r, g, b, err = colorconv.HSVToRGB(h, s, v)
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "error on line %d, col %d: can't convert color %s back from HSV to RGB: %v", lineno, match[0]+1, colortext, err)
+			fmt.Fprintf(os.Stderr, "error on line %d, col %d: can't convert color %s back from HSV to RGB: %v\n", lineno, start+1, colortext, err)
+			return
+		}
+
+		buf.WriteString(line[last:start])
+		fmt.Fprintf(&buf, `"#%02x%02x%02x"`, r, g, b)
+		last = end
+	})
+	buf.WriteString(line[last:])
+	return buf.String()
+}
+
+func updateColor(h, s, v *float64) {
+	for _, o := range opers {
+		o.update(h, s, v)
+	}
+}
+
+// jsonContext tracks where in a JSON document the scanner is, so that each string value can be
+// given the key it belongs to. It is lenient: a portion of a style file, such as a few lines
+// from the middle of an object, is treated as if it were inside an object.
+type jsonContext struct {
+	// frames are the objects and arrays that enclose the current position, outermost first.
+	frames   []jsonFrame
+	inString bool
+	escaped  bool
+	// strStart is the byte offset in the current line of the opening quote of the string being
+	// scanned, or -1 if the string began on an earlier line.
+	strStart int
+	str      strings.Builder
+}
+
+type jsonFrame struct {
+	isArray bool
+	// key is the key of the current member of an object.
+	key string
+	// expectKey is true in an object when the next string is a key.
+	expectKey bool
+	// index is the index of the current element of an array.
+	index int
+}
+
+func (c *jsonContext) top() *jsonFrame {
+	if len(c.frames) == 0 {
+		c.frames = append(c.frames, jsonFrame{expectKey: true})
+	}
+	return &c.frames[len(c.frames)-1]
+}
+
+// key returns the key of the current position, like "Syntax.KeywordColor" or "Ansi.Colors[3]".
+func (c *jsonContext) key() string {
+	var b strings.Builder
+	for _, f := range c.frames {
+		if f.isArray {
+			fmt.Fprintf(&b, "[%d]", f.index)
+			continue
+		}
+		if f.key == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteRune('.')
+		}
+		b.WriteString(f.key)
+	}
+	return b.String()
+}
+
+// scan advances the context over line, calling value with the byte offsets of the quotes around
+// each string value that lies on one line and the key it belongs to.
+func (c *jsonContext) scan(line string, value func(start, end int, key string)) {
+	if c.inString {
+		c.strStart = -1
+	}
+
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		if c.inString {
+			switch {
+			case c.escaped:
+				c.escaped = false
+			case ch == '\\':
+				c.escaped = true
+			case ch == '"':
+				c.inString = false
+				c.endString(c.strStart, i+1, value)
+				continue
+			}
+			c.str.WriteByte(ch)
+			continue
+		}
+
+		switch ch {
+		case '"':
+			c.inString = true
+			c.strStart = i
+			c.str.Reset()
+		case '{':
+			c.top()
+			c.frames = append(c.frames, jsonFrame{expectKey: true})
+		case '[':
+			c.top()
+			c.frames = append(c.frames, jsonFrame{isArray: true})
+		case '}', ']':
+			// Never pop the outermost frame, so that unbalanced input doesn't leave the
+			// context without one.
+			if len(c.frames) > 1 {
+				c.frames = c.frames[:len(c.frames)-1]
+			}
+		case ',':
+			f := c.top()
+			if f.isArray {
+				f.index++
+			} else {
+				f.expectKey = true
+			}
+		}
+	}
+}
+
+func (c *jsonContext) endString(start, end int, value func(start, end int, key string)) {
+	f := c.top()
+	if !f.isArray && f.expectKey {
+		f.key = c.str.String()
+		f.expectKey = false
+		return
+	}
+	if start >= 0 {
+		value(start, end, c.key())
+	}
+}
+
+// keyFilter selects the keys matching a glob, and if the key is an element of an array, with an
+// index in the range [first,last].
+type keyFilter struct {
+	glob        string
+	hasIndex    bool
+	first, last int
+}
+
+var keyIndexRegex = regexp.MustCompile(`^(.*)\[(\d+)(?:-(\d+))?\]$`)
+
+func parseKeyFilters(s string) (filters []keyFilter, err error) {
+	for _, g := range strings.Split(s, ",") {
+		g = strings.TrimSpace(g)
+		if g == "" {
 			continue
 		}
-		colortext = fmt.Sprintf(`"#%02x%02x%02x"`, r, g, b)
 
-		text = text[0:match[0]] + colortext + text[match[1]:]
-		newline = newline[0:skipLeading] + text
-		skipLeading += match[0] + len(colortext)
-		//fmt.Printf("line now: %s\n", newline)
+		f := keyFilter{glob: g}
+		if m := keyIndexRegex.FindStringSubmatch(g); m != nil {
+			f.glob = m[1]
+			f.hasIndex = true
+			f.first, _ = strconv.Atoi(m[2])
+			f.last = f.first
+			if m[3] != "" {
+				f.last, _ = strconv.Atoi(m[3])
+			}
+		}
+
+		if _, err = path.Match(f.glob, ""); err != nil {
+			return nil, fmt.Errorf("%s: %v", g, err)
+		}
+		filters = append(filters, f)
 	}
 	return
 }
 
-func updateColor(h, s, v *float64) {
-	oper.update(h, s, v)
+func (f keyFilter) matches(key string) bool {
+	if m := keyIndexRegex.FindStringSubmatch(key); m != nil {
+		if f.hasIndex {
+			ndx, _ := strconv.Atoi(m[2])
+			if ndx < f.first || ndx > f.last {
+				return false
+			}
+			ok, _ := path.Match(f.glob, m[1])
+			return ok
+		}
+		// A filter without an index selects every element of a matching array.
+		if ok, _ := path.Match(f.glob, m[1]); ok {
+			return true
+		}
+	} else if f.hasIndex {
+		return false
+	}
+
+	ok, _ := path.Match(f.glob, key)
+	return ok
+}
+
+// keySelected returns true if the color of key should be changed: if --keys was not given, or
+// one of its globs matches key.
+func keySelected(key string) bool {
+	if len(keys) == 0 {
+		return true
+	}
+	for _, f := range keys {
+		if f.matches(key) {
+			return true
+		}
+	}
+	return false
 }
 
 func usage() {
-	fmt.Fprintf(os.Stderr, "Usage: %s <--darken|-d N | --lighten|-l N>\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Usage: %s [--darken|-d N] [--lighten|-l N] [--saturate N] [--desaturate N] [--hue-shift DEGREES] [--keys GLOBS]\n", os.Args[0])
 	fmt.Fprintf(os.Stderr, "\n")
-	fmt.Fprintf(os.Stderr, "This is a tool to help darken or lighten the colors in an Anvil .js style file. It is a simple tool. Give it a style file or portion of one on stdin, and it will search for hex colors of the form '#xxxxxx' where x are hex digits, and replace them with a new color. The result is printed on stdout.\n\nUse one of the --lighten or --darken arguments to choose how to modify the colors")
+	fmt.Fprintf(os.Stderr, "This is a tool to help adjust the colors in an Anvil .js style file. It is a simple tool. Give it a style file or portion of one on stdin, and it will search for hex colors of the form '#xxxxxx' where x are hex digits, and replace them with a new color. The result is printed on stdout.\n\nUse one or more of the --lighten, --darken, --saturate, --desaturate and --hue-shift arguments to choose how to modify the colors, and --keys to only modify the colors of some keys. For example --desaturate 20%% --keys 'Syntax.*,Ansi.Colors[8-15]'\n\n")
 
 	pflag.PrintDefaults()
 }
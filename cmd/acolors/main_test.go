@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestJsonContextKeys(t *testing.T) {
+	style := `{
+  "Fonts": [
+    {
+      "FontName": "a \"#ffffff\" font",
+      "FontSize": 14
+    }
+  ],
+  "TagFgColor": "#f0f0f0",
+  "Syntax": {
+    "KeywordColor": "#8fbfdc", "NameColor": "#f0f0f0"
+  },
+  "Ansi": {
+    "Colors": [
+      "#000000",
+      "#800000"
+    ]
+  },
+  "TagBgColor": "#263859"
+}`
+
+	var ctx jsonContext
+	var keys []string
+	for _, line := range strings.Split(style, "\n") {
+		ctx.scan(line, func(start, end int, key string) {
+			keys = append(keys, key+"="+line[start:end])
+		})
+	}
+
+	expected := []string{
+		`Fonts[0].FontName="a \"#ffffff\" font"`,
+		`TagFgColor="#f0f0f0"`,
+		`Syntax.KeywordColor="#8fbfdc"`,
+		`Syntax.NameColor="#f0f0f0"`,
+		`Ansi.Colors[0]="#000000"`,
+		`Ansi.Colors[1]="#800000"`,
+		`TagBgColor="#263859"`,
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("expected the keys\n%s\nbut got\n%s", strings.Join(expected, "\n"), strings.Join(keys, "\n"))
+	}
+}
+
+func TestKeyFilters(t *testing.T) {
+	filters, err := parseKeyFilters("Syntax.*, TagBgColor,Ansi.Colors[8-15]")
+	if err != nil {
+		t.Fatalf("parsing the filters failed: %v", err)
+	}
+
+	tests := []struct {
+		key      string
+		expected bool
+	}{
+		{"Syntax.KeywordColor", true},
+		{"TagBgColor", true},
+		{"TagFgColor", false},
+		{"Ansi.Colors[8]", true},
+		{"Ansi.Colors[15]", true},
+		{"Ansi.Colors[7]", false},
+		{"Ansi.Colors[16]", false},
+	}
+
+	for _, tc := range tests {
+		matched := false
+		for _, f := range filters {
+			matched = matched || f.matches(tc.key)
+		}
+		if matched != tc.expected {
+			t.Errorf("expected %s to be selected to be %v", tc.key, tc.expected)
+		}
+	}
+}
+
+func TestUpdateLineOperations(t *testing.T) {
+	opers = []op{{opcode: hueShift, amt: 120}, {opcode: desaturate, amt: 100, isPct: true}}
+	keys = nil
+	defer func() { opers = nil }()
+
+	var ctx jsonContext
+	got := updateLine(&ctx, `  "A": "#ff0000", "B": "not a color"`, 1)
+	if got != `  "A": "#ffffff", "B": "not a color"` {
+		t.Fatalf("expected the color to be fully desaturated but got %s", got)
+	}
+}
@@ -9,13 +9,20 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+
+	"github.com/ogier/pflag"
 )
 
 var (
 	winsToDiff []Window
 	httpApi    api.Anvil
+
+	optWordDiffLimit = pflag.IntP("word-diff-limit", "w", 5000, "Highlight the words that changed within lines only if the diff has at most this many lines. 0 disables highlighting changed words")
 )
 
+// changedWordsColor is the color of the words that changed within lines.
+const changedWordsColor = "#ffaa00"
+
 type Window struct {
 	api.Window
 	order        int
@@ -23,11 +30,13 @@ type Window struct {
 }
 
 func main() {
+	pflag.Parse()
+
 	var err error
 	httpApi, err = api.NewFromEnv()
 	dieIfError(err, "connecting to API failed")
 
-	if len(os.Args) > 1 && os.Args[1] == "clr" {
+	if pflag.Arg(0) == "clr" {
 		clearMarksFromWindowTags()
 		return
 	}
@@ -117,6 +126,24 @@ func writeToDiffWindow(diff []byte) {
 
 	httpApi.SetWindowTag(win, fmt.Sprintf("%s Del! | Look", "+Diff"))
 	httpApi.ExecuteInWin(win, "Syn", []string{"diff"})
+
+	highlightChangedWords(win, string(diff))
+}
+
+// highlightChangedWords highlights the words that changed within the lines of the diff in
+// the +Diff window. The highlights from a previous diff are removed.
+func highlightChangedWords(win api.Window, diff string) {
+	var hls []api.Highlight
+	if *optWordDiffLimit > 0 && strings.Count(diff, "\n") <= *optWordDiffLimit {
+		for _, r := range changedWords(diff) {
+			hls = append(hls, api.Highlight{Start: r.start, End: r.end, Color: changedWordsColor})
+		}
+	}
+
+	err := httpApi.SetWindowBodyHighlights(win, hls)
+	if err != nil {
+		fmt.Printf("adiff: warning: highlighting changed words failed: %v\n", err)
+	}
 }
 
 func removeTempfiles() {
@@ -145,11 +172,7 @@ func findOrCreateWindow(path string) (win api.Window, err error) {
 		}
 	}
 
-	win, err = httpApi.NewWindow()
-	if err != nil {
-		return
-	}
-
+	win, err = httpApi.NewWindowWithPath(path, -1)
 	return
 }
 
@@ -157,12 +180,21 @@ func clearMarksFromWindowTags() {
 	wins, err := httpApi.Windows()
 	dieIfError(err, "getting windows failed")
 
-	for _, win := range wins {
-		tag, err := httpApi.WindowTag(win)
-		if err != nil {
-			fmt.Printf("adiff: warning: couldn't get tag for window %d: %v\n", win.Id, err)
-		}
+	// Read all of the tags in one request, and write the changed ones back in another.
+	var get api.Batch
+	tags := make([]*api.BatchText, len(wins))
+	for i, win := range wins {
+		tags[i] = get.WindowTag(win)
+	}
+	err = httpApi.RunBatch(&get)
+	if err != nil {
+		fmt.Printf("adiff: warning: couldn't get the window tags: %v\n", err)
+		return
+	}
 
+	var set api.Batch
+	for j, win := range wins {
+		tag := tags[j].Text
 		if i := strings.Index(tag, "&&"); i >= 0 {
 			spaceIndex := strings.Index(tag[i:], " ")
 			var newTag string
@@ -177,7 +209,14 @@ func clearMarksFromWindowTags() {
 				// Must be at end of line
 				newTag = tag[0:i]
 			}
-			httpApi.SetWindowTag(win, newTag)
+			set.SetWindowTag(win, newTag)
+		}
+	}
+
+	if set.Len() > 0 {
+		err = httpApi.RunBatch(&set)
+		if err != nil {
+			fmt.Printf("adiff: warning: couldn't clear the marks from the window tags: %v\n", err)
 		}
 	}
 }
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxWordDiffTokens limits the size of the table used to compare a pair of lines. Pairs of
+// lines with more tokens than this multiplied together are not compared.
+const maxWordDiffTokens = 1000000
+
+// runeRange is the range [start,end) of rune offsets.
+type runeRange struct {
+	start, end int
+}
+
+// changedWords finds the spans that changed within lines of the unified diff output diff.
+// Each run of removed lines followed by a run of added lines is compared pairwise: the first
+// removed line with the first added line, and so on. It returns the ranges of the changed
+// spans as rune offsets in diff.
+func changedWords(diff string) (ranges []runeRange) {
+	var removed, added []diffLine
+	inHunk := false
+
+	comparePairs := func() {
+		for i := 0; i < len(removed) && i < len(added); i++ {
+			r, a := compareLines(removed[i].text, added[i].text)
+			ranges = append(ranges, removed[i].offset(r)...)
+			ranges = append(ranges, added[i].offset(a)...)
+		}
+		removed, added = removed[:0], added[:0]
+	}
+
+	start := 0
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		text := strings.TrimSuffix(line, "\n")
+
+		switch {
+		case strings.HasPrefix(text, "@@"):
+			comparePairs()
+			inHunk = true
+		case !inHunk:
+			// File headers before the first hunk begin with --- and +++ but are not changes.
+		case strings.HasPrefix(text, "-"):
+			if len(added) > 0 {
+				comparePairs()
+			}
+			removed = append(removed, diffLine{text: text[1:], start: start + 1})
+		case strings.HasPrefix(text, "+"):
+			added = append(added, diffLine{text: text[1:], start: start + 1})
+		default:
+			comparePairs()
+		}
+
+		start += utf8.RuneCountInString(line)
+	}
+	comparePairs()
+
+	return
+}
+
+// diffLine is a removed or added line of a diff, without the leading - or +.
+type diffLine struct {
+	text string
+	// start is the rune offset of text in the diff
+	start int
+}
+
+// offset returns the ranges, relative to the start of the line, as offsets in the diff.
+func (l diffLine) offset(ranges []runeRange) []runeRange {
+	for i := range ranges {
+		ranges[i].start += l.start
+		ranges[i].end += l.start
+	}
+	return ranges
+}
+
+// compareLines returns the ranges of runes in a and in b that are not part of the longest
+// common sequence of words of a and b.
+func compareLines(a, b string) (changedA, changedB []runeRange) {
+	ta, tb := tokenize(a), tokenize(b)
+	if len(ta)*len(tb) > maxWordDiffTokens {
+		return
+	}
+
+	// lcs[i][j] is the length of the longest common sequence of ta[i:] and tb[j:]
+	lcs := make([][]int, len(ta)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(tb)+1)
+	}
+	for i := len(ta) - 1; i >= 0; i-- {
+		for j := len(tb) - 1; j >= 0; j-- {
+			if ta[i].text == tb[j].text {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(ta) && j < len(tb) {
+		switch {
+		case ta[i].text == tb[j].text:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			changedA = addChangedToken(changedA, ta[i])
+			i++
+		default:
+			changedB = addChangedToken(changedB, tb[j])
+			j++
+		}
+	}
+	for ; i < len(ta); i++ {
+		changedA = addChangedToken(changedA, ta[i])
+	}
+	for ; j < len(tb); j++ {
+		changedB = addChangedToken(changedB, tb[j])
+	}
+	return
+}
+
+// addChangedToken adds the range of t to ranges, extending the last range if t follows it.
+// Changed whitespace is not highlighted on its own since it can't be seen.
+func addChangedToken(ranges []runeRange, t token) []runeRange {
+	if len(ranges) > 0 && ranges[len(ranges)-1].end == t.start {
+		ranges[len(ranges)-1].end = t.start + t.runes
+		return ranges
+	}
+	if t.isSpace() {
+		return ranges
+	}
+	return append(ranges, runeRange{t.start, t.start + t.runes})
+}
+
+// token is a word, a run of whitespace, or a single other character of a line.
+type token struct {
+	text string
+	// start is the rune offset of the token in the line, and runes is its length in runes.
+	start, runes int
+}
+
+func (t token) isSpace() bool {
+	r, _ := utf8.DecodeRuneInString(t.text)
+	return unicode.IsSpace(r)
+}
+
+func tokenize(s string) (tokens []token) {
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		}
+		return 0
+	}
+
+	runeOffset := 0
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		c := class(r)
+		j, n := i+size, 1
+		for c != 0 && j < len(s) {
+			r, size := utf8.DecodeRuneInString(s[j:])
+			if class(r) != c {
+				break
+			}
+			j += size
+			n++
+		}
+
+		tokens = append(tokens, token{text: s[i:j], start: runeOffset, runes: n})
+		runeOffset += n
+		i = j
+	}
+	return
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestChangedWords(t *testing.T) {
+	tests := []struct {
+		name     string
+		diff     string
+		expected []string
+	}{
+		{
+			name:     "one word",
+			diff:     "--- a\n+++ b\n@@ -1 +1 @@\n-the quick fox\n+the slow fox\n",
+			expected: []string{"quick", "slow"},
+		},
+		{
+			name:     "multibyte runes and tabs",
+			diff:     "@@ -1 +1 @@\n-\tnaïve café = 1\n+\tnaïve café = 2\n",
+			expected: []string{"1", "2"},
+		},
+		{
+			name:     "pairs in order",
+			diff:     "@@ -1,3 +1,3 @@\n ctx\n-a := 1\n-b := 2\n+a := 10\n+b := 20\n ctx\n",
+			expected: []string{"1", "10", "2", "20"},
+		},
+		{
+			name:     "adjacent changes merged",
+			diff:     "@@ -1 +1 @@\n-x = f(a, b)\n+x = g[a, b]\n",
+			expected: []string{"f(", ")", "g[", "]"},
+		},
+		{
+			name:     "unpaired lines",
+			diff:     "@@ -1,2 +1,1 @@\n-gone\n-also gone\n+kept\n",
+			expected: []string{"gone", "kept"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			body := []rune(tc.diff)
+			var actual []string
+			for _, r := range changedWords(tc.diff) {
+				actual = append(actual, string(body[r.start:r.end]))
+			}
+
+			if len(actual) != len(tc.expected) {
+				t.Fatalf("expected %q but got %q", tc.expected, actual)
+			}
+			for i := range actual {
+				if actual[i] != tc.expected[i] {
+					t.Fatalf("expected %q but got %q", tc.expected, actual)
+				}
+			}
+		})
+	}
+}
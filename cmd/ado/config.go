@@ -8,11 +8,90 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+
+	anvil "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
 )
 
+// The config file is the file filehooks in the Anvil config directory. It is a list of hooks,
+// each of which starts with a match line followed by lines that say when the hook runs and what
+// it does:
+//
+//	# Lines starting with # are comments.
+//	match \.go$
+//	on Put
+//	run gofmt -l "$ANVIL_WIN_GLOBAL_PATH"
+//	final true
+//
+//	match ^(.*)/big/
+//	do Syn off
+//	tag Get Put $1
+//
+// The lines are:
+//
+//	match <regexp>      starts a hook that runs for windows whose path matches the regexp
+//	on <event> [<cmd>]  the event the hook runs for: FileOpened (the default), Put, FileClosed,
+//	                    or Exec followed by the name of a command. Exec hooks run when the
+//	                    command is executed in a window, and ado registers the command with Anvil.
+//	do <cmd>            executes the editor command in the window
+//	run <program>       runs the program using sh, with ANVIL_WIN_ID, ANVIL_WIN_GLOBAL_PATH and
+//	                    ANVIL_WIN_LOCAL_PATH set in its environment
+//	tag <text>          replaces the user area of the window tag, after the |, with the text
+//	append <text>       appends the text to the window body. \n and \t in it are a newline and a tab.
+//	final true          stops the hooks after this one from running when it matches
+//
+// In the text of do, tag and append lines, $1 or ${name} are replaced with the submatches of the
+// regexp as in regexp.Expand. All the hooks that match an event run in the order they are listed.
+
+// Hook is a list of actions to perform when an event occurs in a window whose path matches.
 type Hook struct {
 	Match *regexp.Regexp
-	Do    []string
+	// Event is the notification that the hook runs for.
+	Event anvil.NotificationOp
+	// Cmd is the name of the command that an Exec hook runs for.
+	Cmd     string
+	Actions []Action
+	// Final stops the hooks after this one from running if this one matches.
+	Final bool
+	// Line is the line in the config file that the hook starts on.
+	Line int
+}
+
+// ActionType is the kind of thing that an Action does.
+type ActionType int
+
+const (
+	// ActionDo executes an editor command in the window.
+	ActionDo ActionType = iota
+	// ActionRun runs an external program.
+	ActionRun
+	// ActionTag sets the user area of the window tag.
+	ActionTag
+	// ActionAppend appends text to the window body.
+	ActionAppend
+)
+
+// Action is something that a hook does. Arg is the command, program or text, depending on Type.
+type Action struct {
+	Type ActionType
+	Arg  string
+}
+
+var actionTypes = map[string]ActionType{
+	"do":     ActionDo,
+	"run":    ActionRun,
+	"tag":    ActionTag,
+	"append": ActionAppend,
+}
+
+var hookEvents = map[string]anvil.NotificationOp{
+	"FileOpened": anvil.NotificationOpFileOpened,
+	"Put":        anvil.NotificationOpPut,
+	"FileClosed": anvil.NotificationOpFileClosed,
+	"Exec":       anvil.NotificationOpExec,
+}
+
+func (h Hook) String() string {
+	return fmt.Sprintf("hook at line %d (match %s)", h.Line, h.Match)
 }
 
 func parseConfigFile() (hooks []Hook, err error) {
@@ -26,104 +105,147 @@ func parseConfigFile() (hooks []Hook, err error) {
 	var f *os.File
 	f, err = os.Open(name)
 	if err != nil {
-		err = fmt.Errorf("Can't open config file: %v", name, err)
+		err = fmt.Errorf("Can't open config file %s: %v", name, err)
 		return
 	}
 	defer f.Close()
 
-	return parseConfigFileFrom(f)
-}
-
-func parseConfigFileFrom(f io.Reader) (hooks []Hook, err error) {
-
-	var hook Hook
-	onMatch := func(re *regexp.Regexp) {
-		if hook.Match != nil {
-			hooks = append(hooks, hook)
-			hook.Do = nil
-		}
-		hook.Match = re
-	}
-
-	onDo := func(do string) {
-		hook.Do = append(hook.Do, do)
-	}
-
-	err = ParseMatchDoConfigFile(f, onMatch, onDo)
-
-	if err == nil && len(hook.Do) > 0 {
-		hooks = append(hooks, hook)
+	hooks, err = parseConfigFileFrom(f)
+	if err != nil {
+		err = fmt.Errorf("%s: %v", name, err)
 	}
-
 	return
 }
 
-func ParseMatchDoConfigFile(f io.Reader, onMatch func(re *regexp.Regexp), onDo func(do string)) (err error) {
-
+// parseConfigFileFrom parses the hooks in the config file f, and checks that they are valid.
+func parseConfigFileFrom(f io.Reader) (hooks []Hook, err error) {
 	s := bufio.NewScanner(f)
-
-	const (
-		stateNormal = iota
-		stateSawMatch
-		stateSawDo
-	)
-
-	state := stateNormal
-
-	handleMatch := func(line, data string) {
-		re, err2 := regexp.Compile(data)
-		if err2 != nil {
-			err = fmt.Errorf("Parsing regexp for line '%s' failed: %v", line, err)
-			return
-		}
-		onMatch(re)
-		state = stateSawMatch
-	}
-
-	handleDo := func(data string) {
-		onDo(data)
-		//rules = append(rules, rule)
-		state = stateSawDo
-	}
+	var hook *Hook
+	sawOn := false
+	lineNum := 0
 
 	for s.Scan() {
-		line := s.Text()
-		line = strings.TrimSpace(line)
+		lineNum++
+		line := strings.TrimSpace(s.Text())
 		if line == "" || line[0] == '#' {
 			continue
 		}
 
 		toks := strings.SplitN(line, " ", 2)
 		if len(toks) < 2 {
-			err = fmt.Errorf("Invalid line: expected a word, a space, then a string. Line is '%s'", line)
+			err = fmt.Errorf("line %d: expected a word, a space, then a string but got '%s'", lineNum, line)
 			return
 		}
+		word, data := toks[0], strings.TrimSpace(toks[1])
 
-		switch state {
-		case stateSawDo:
-			if toks[0] == "do" {
-				handleDo(toks[1])
-				continue
-			}
-			if toks[0] != "match" {
-				err = fmt.Errorf("Expected line beginning with 'match' but got '%s'", line)
+		if word == "match" {
+			var re *regexp.Regexp
+			re, err = regexp.Compile(data)
+			if err != nil {
+				err = fmt.Errorf("line %d: parsing the regexp '%s' failed: %v", lineNum, data, err)
 				return
 			}
+			hooks = append(hooks, Hook{Match: re, Event: anvil.NotificationOpFileOpened, Line: lineNum})
+			hook = &hooks[len(hooks)-1]
+			sawOn = false
+			continue
+		}
 
-			handleMatch(line, toks[1])
-		case stateNormal:
-			if toks[0] != "match" {
-				err = fmt.Errorf("Expected line beginning with 'match' but got '%s'", line)
+		if hook == nil {
+			err = fmt.Errorf("line %d: expected a line beginning with 'match' but got '%s'", lineNum, line)
+			return
+		}
+
+		switch word {
+		case "on":
+			if sawOn {
+				err = fmt.Errorf("%s: line %d: the hook has more than one 'on' line", hook, lineNum)
 				return
 			}
-
-			handleMatch(line, toks[1])
-		case stateSawMatch:
-			if toks[0] != "do" {
-				err = fmt.Errorf("Expected line beginning with 'do' but got '%s'", line)
+			sawOn = true
+			err = parseHookEvent(hook, data)
+			if err != nil {
+				err = fmt.Errorf("%s: line %d: %v", hook, lineNum, err)
+				return
+			}
+		case "final":
+			switch data {
+			case "true":
+				hook.Final = true
+			case "false":
+				hook.Final = false
+			default:
+				err = fmt.Errorf("%s: line %d: final must be 'true' or 'false' but is '%s'", hook, lineNum, data)
+				return
+			}
+		default:
+			t, ok := actionTypes[word]
+			if !ok {
+				err = fmt.Errorf("%s: line %d: unknown keyword '%s'; expected on, do, run, tag, append or final", hook, lineNum, word)
 				return
 			}
-			handleDo(toks[1])
+			hook.Actions = append(hook.Actions, Action{Type: t, Arg: data})
+		}
+	}
+	if err = s.Err(); err != nil {
+		return
+	}
+
+	for _, h := range hooks {
+		if err = validateHook(h); err != nil {
+			err = fmt.Errorf("%s: %v", h, err)
+			return
+		}
+	}
+	return
+}
+
+func parseHookEvent(hook *Hook, data string) error {
+	toks := strings.Fields(data)
+	ev, ok := hookEvents[toks[0]]
+	if !ok {
+		return fmt.Errorf("unknown event '%s'; expected FileOpened, Put, FileClosed or Exec", toks[0])
+	}
+	hook.Event = ev
+
+	if ev == anvil.NotificationOpExec {
+		if len(toks) != 2 {
+			return fmt.Errorf("an Exec event must be followed by the name of one command")
+		}
+		hook.Cmd = toks[1]
+		return nil
+	}
+
+	if len(toks) > 1 {
+		return fmt.Errorf("only an Exec event may be followed by a command name")
+	}
+	return nil
+}
+
+// validateHook checks that the actions of the hook can be performed for its event.
+func validateHook(h Hook) error {
+	if len(h.Actions) == 0 {
+		return fmt.Errorf("the hook has no do, run, tag or append lines")
+	}
+
+	if h.Event != anvil.NotificationOpFileClosed {
+		return nil
+	}
+	for _, a := range h.Actions {
+		if a.Type != ActionRun {
+			return fmt.Errorf("a FileClosed hook may only have run lines, since the window no longer exists")
+		}
+	}
+	return nil
+}
+
+// execHookCommands returns the names of the commands that Exec hooks run for.
+func execHookCommands(hooks []Hook) (names []string) {
+	seen := map[string]bool{}
+	for _, h := range hooks {
+		if h.Event == anvil.NotificationOpExec && !seen[h.Cmd] {
+			seen[h.Cmd] = true
+			names = append(names, h.Cmd)
 		}
 	}
 	return
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	anvil "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+func TestParseConfigFile(t *testing.T) {
+	cfg := `
+# Comment
+match \.go$
+do Syn go
+
+match ^/big/(.*)
+on Put
+run echo "$ANVIL_WIN_GLOBAL_PATH"
+tag Get $1
+append \nsaved\n
+final true
+
+match .
+on Exec Lint
+run lint
+`
+	hooks, err := parseConfigFileFrom(strings.NewReader(cfg))
+	if err != nil {
+		t.Fatalf("parsing failed: %v", err)
+	}
+
+	if len(hooks) != 3 {
+		t.Fatalf("expected 3 hooks but got %d", len(hooks))
+	}
+
+	h := hooks[0]
+	if h.Event != anvil.NotificationOpFileOpened || h.Final || h.Line != 3 {
+		t.Fatalf("unexpected first hook %#v", h)
+	}
+
+	h = hooks[1]
+	expected := []Action{
+		{ActionRun, `echo "$ANVIL_WIN_GLOBAL_PATH"`},
+		{ActionTag, "Get $1"},
+		{ActionAppend, `\nsaved\n`},
+	}
+	if h.Event != anvil.NotificationOpPut || !h.Final || !reflect.DeepEqual(h.Actions, expected) {
+		t.Fatalf("unexpected second hook %#v", h)
+	}
+
+	h = hooks[2]
+	if h.Event != anvil.NotificationOpExec || h.Cmd != "Lint" {
+		t.Fatalf("unexpected third hook %#v", h)
+	}
+
+	if cmds := execHookCommands(hooks); !reflect.DeepEqual(cmds, []string{"Lint"}) {
+		t.Fatalf("expected the exec hook commands to be [Lint] but got %v", cmds)
+	}
+}
+
+func TestParseConfigFileErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      string
+		expected string
+	}{
+		{
+			name:     "line before match",
+			cfg:      "do Syn off\n",
+			expected: "line 1: expected a line beginning with 'match'",
+		},
+		{
+			name:     "bad regexp",
+			cfg:      "match (\n",
+			expected: "line 1: parsing the regexp '('",
+		},
+		{
+			name:     "unknown event",
+			cfg:      "match a\non Opened\ndo x\n",
+			expected: "hook at line 1 (match a): line 2: unknown event 'Opened'",
+		},
+		{
+			name:     "exec without command",
+			cfg:      "match a\non Exec\ndo x\n",
+			expected: "hook at line 1 (match a): line 2: an Exec event must be followed",
+		},
+		{
+			name:     "command for other event",
+			cfg:      "match a\non Put Lint\ndo x\n",
+			expected: "only an Exec event may be followed by a command name",
+		},
+		{
+			name:     "two on lines",
+			cfg:      "match a\non Put\non Exec Lint\ndo x\n",
+			expected: "line 3: the hook has more than one 'on' line",
+		},
+		{
+			name:     "bad final",
+			cfg:      "match a\ndo x\nfinal yes\n",
+			expected: "final must be 'true' or 'false'",
+		},
+		{
+			name:     "unknown keyword",
+			cfg:      "match a\nexec x\n",
+			expected: "unknown keyword 'exec'",
+		},
+		{
+			name:     "no actions",
+			cfg:      "match a\ndo x\nmatch b\non Put\n",
+			expected: "hook at line 3 (match b): the hook has no do, run, tag or append lines",
+		},
+		{
+			name:     "window action on FileClosed",
+			cfg:      "match a\non FileClosed\ntag x\n",
+			expected: "a FileClosed hook may only have run lines",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseConfigFileFrom(strings.NewReader(tc.cfg))
+			if err == nil {
+				t.Fatalf("expected an error containing %q but parsing succeeded", tc.expected)
+			}
+			if !strings.Contains(err.Error(), tc.expected) {
+				t.Fatalf("expected an error containing %q but got %q", tc.expected, err)
+			}
+		})
+	}
+}
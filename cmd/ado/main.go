@@ -1,32 +1,49 @@
 package main
 
 import (
-	anvil "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
 	"fmt"
 	"os"
+	"os/exec"
+	"strings"
 
+	anvil "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
 	"github.com/ogier/pflag"
 )
 
 var (
 	optDebug = pflag.BoolP("debug", "d", false, "Print debug messages")
+	optCheck = pflag.BoolP("check", "c", false, "Check the config file for errors and exit without connecting to Anvil")
 )
 
 var (
 	anvilHttpApi anvil.Anvil
 	anvilWsApi   anvil.Websock
 	hooks        []Hook
+	// winPaths are the paths of the open windows by id.
+	winPaths = map[int]string{}
 )
 
 func main() {
 	pflag.Parse()
 
-	connectToAnvil()
 	var err error
 	hooks, err = parseConfigFile()
 	dieIfError(err, "Parsing config failed")
 
-	anvilWsApi.Run()
+	if *optCheck {
+		fmt.Printf("ado: the config file is valid and has %d hooks\n", len(hooks))
+		return
+	}
+
+	connectToAnvil()
+	if cmds := execHookCommands(hooks); len(cmds) > 0 {
+		err = anvilHttpApi.RegisterCommands(cmds...)
+		dieIfError(err, "registering commands failed")
+	}
+	rememberWindowPaths()
+
+	err = anvilWsApi.Run()
+	dieIfError(err, "lost the connection to Anvil")
 }
 
 func connectToAnvil() {
@@ -36,7 +53,9 @@ func connectToAnvil() {
 	dieIfError(err, "connecting to API failed")
 
 	handlers := anvil.WebsockHandlers{
-		Notification: handleNotification,
+		Notification:      handleNotification,
+		Reconnect:         true,
+		ConnectionChanged: handleConnectionChanged,
 	}
 
 	debug("ado: connecting to WS API\n")
@@ -44,6 +63,14 @@ func connectToAnvil() {
 	dieIfError(err, "creating websocket failed")
 }
 
+func handleConnectionChanged(state anvil.ConnectionState, err error) {
+	if err != nil {
+		fmt.Printf("ado: lost the connection to Anvil, reconnecting: %v\n", err)
+		return
+	}
+	debug("ado: %s to Anvil\n", state)
+}
+
 func dieIfError(err error, msg string) {
 	if err != nil {
 		msg := fmt.Sprintf("%s: %s", msg, err)
@@ -70,29 +97,71 @@ func handleNotification(notif *anvil.Notification, err error) {
 	}
 
 	switch notif.Op {
-	case anvil.NotificationOpFileOpened:
-		handleFileOpenedNotification(notif)
+	case anvil.NotificationOpFileOpened, anvil.NotificationOpPut, anvil.NotificationOpFileClosed, anvil.NotificationOpExec:
+		handleHookNotification(notif)
 	}
+}
 
-	if notif.Op != anvil.NotificationOpFileOpened {
+func handleHookNotification(notif *anvil.Notification) {
+	debug("ado: got notification: %#v\n", notif)
+
+	win, ok := windowForNotification(notif)
+	if !ok {
 		return
 	}
+
+	cmd := ""
+	if notif.Op == anvil.NotificationOpExec && len(notif.Cmd) > 0 {
+		cmd = notif.Cmd[0]
+	}
+
+	for i := range hooks {
+		hook := &hooks[i]
+		if hook.Event != notif.Op || hook.Cmd != cmd {
+			continue
+		}
+		matched := tryHook(win, hook)
+		if matched && hook.Final {
+			break
+		}
+	}
 }
 
-func handleFileOpenedNotification(notif *anvil.Notification) {
-	debug("ado: got file opened notification: %#v\n", notif)
+// windowForNotification returns the window that the notification is for. The window in a
+// FileClosed notification no longer exists, so its path is the one remembered from an earlier
+// notification.
+func windowForNotification(notif *anvil.Notification) (win anvil.Window, ok bool) {
+	if notif.Op == anvil.NotificationOpFileClosed {
+		var path string
+		path, ok = winPaths[notif.WinId]
+		delete(winPaths, notif.WinId)
+		return anvil.Window{Id: notif.WinId, GlobalPath: path}, ok
+	}
+
+	if notif.WinId < 0 {
+		debug("ado: ignoring notification that is not for a window\n")
+		return
+	}
 
 	win, err := anvilHttpApi.Window(notif.WinId)
 	if err != nil {
-		fmt.Printf("ado: error getting window info when opened: %v\n", err)
+		fmt.Printf("ado: error getting info for window %d: %v\n", notif.WinId, err)
 		return
 	}
+	winPaths[win.Id] = win.GlobalPath
+	return win, true
+}
 
-	for _, hook := range hooks {
-		matched := tryHook(win, &hook)
-		if matched {
-			break
-		}
+// rememberWindowPaths stores the paths of the windows that are already open, so that FileClosed
+// hooks run for them.
+func rememberWindowPaths() {
+	wins, err := anvilHttpApi.Windows()
+	if err != nil {
+		fmt.Printf("ado: error listing windows: %v\n", err)
+		return
+	}
+	for _, w := range wins {
+		winPaths[w.Id] = w.GlobalPath
 	}
 }
 
@@ -105,15 +174,86 @@ func tryHook(win anvil.Window, hook *Hook) (matched bool) {
 
 	matched = true
 
-	for _, do := range hook.Do {
-		cmd := []byte{}
-		cmd = hook.Match.Expand(cmd, []byte(do), []byte(win.GlobalPath), submatches)
-		debug("ado: executing '%s'\n", cmd)
-		err := anvilHttpApi.ExecuteInWin(win, string(cmd), nil)
-		if err != nil {
-			fmt.Printf("ado: executing command '%s' in win %d failed: %v \n", cmd, win.Id, err)
+	expand := func(s string) string {
+		return string(hook.Match.Expand(nil, []byte(s), []byte(win.GlobalPath), submatches))
+	}
+
+	for _, a := range hook.Actions {
+		switch a.Type {
+		case ActionDo:
+			doCommand(win, expand(a.Arg))
+		case ActionRun:
+			runProgram(win, a.Arg)
+		case ActionTag:
+			setTagUserArea(win, expand(a.Arg))
+		case ActionAppend:
+			appendToBody(win, expand(a.Arg))
 		}
 	}
 
 	return
 }
+
+func doCommand(win anvil.Window, cmd string) {
+	debug("ado: executing '%s'\n", cmd)
+	err := anvilHttpApi.ExecuteInWin(win, cmd, nil)
+	if err != nil {
+		fmt.Printf("ado: executing command '%s' in win %d failed: %v \n", cmd, win.Id, err)
+	}
+}
+
+// runProgram runs the program using sh without waiting for it to finish, so that a slow program
+// doesn't delay the hooks for other notifications.
+func runProgram(win anvil.Window, program string) {
+	debug("ado: running '%s'\n", program)
+	cmd := exec.Command("sh", "-c", program)
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("ANVIL_WIN_ID=%d", win.Id),
+		fmt.Sprintf("ANVIL_WIN_GLOBAL_PATH=%s", win.GlobalPath),
+		fmt.Sprintf("ANVIL_WIN_LOCAL_PATH=%s", win.Path),
+	)
+
+	go func() {
+		out, err := cmd.CombinedOutput()
+		if len(out) > 0 {
+			fmt.Printf("%s", out)
+		}
+		if err != nil {
+			fmt.Printf("ado: running '%s' for win %d failed: %v\n", program, win.Id, err)
+		}
+	}()
+}
+
+func setTagUserArea(win anvil.Window, text string) {
+	tag, err := anvilHttpApi.WindowTag(win)
+	if err != nil {
+		fmt.Printf("ado: getting the tag of win %d failed: %v\n", win.Id, err)
+		return
+	}
+
+	i := strings.IndexRune(tag, '|')
+	if i < 0 {
+		fmt.Printf("ado: the tag of win %d has no user area\n", win.Id)
+		return
+	}
+
+	err = anvilHttpApi.SetWindowTag(win, tag[:i+1]+" "+text)
+	if err != nil {
+		fmt.Printf("ado: setting the tag of win %d failed: %v\n", win.Id, err)
+	}
+}
+
+var appendEscapes = strings.NewReplacer(`\n`, "\n", `\t`, "\t")
+
+func appendToBody(win anvil.Window, text string) {
+	info, err := anvilHttpApi.WindowBodyInfo(win)
+	if err != nil {
+		fmt.Printf("ado: getting the body of win %d failed: %v\n", win.Id, err)
+		return
+	}
+
+	err = anvilHttpApi.SetWindowBodyRange(win, info.Len, info.Len, appendEscapes.Replace(text))
+	if err != nil {
+		fmt.Printf("ado: appending to the body of win %d failed: %v\n", win.Id, err)
+	}
+}
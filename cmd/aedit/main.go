@@ -75,7 +75,9 @@ func connectToAnvil() {
 	dieIfError(err, "connecting to API failed")
 
 	handlers := api.WebsockHandlers{
-		Notification: handleFileCloseNotification,
+		Notification:      handleFileCloseNotification,
+		Reconnect:         true,
+		ConnectionChanged: handleConnectionChanged,
 	}
 
 	debug("aedit: connecting to WS API\n")
@@ -153,7 +155,24 @@ func waitForWindow(path string) api.Window {
 
 func waitForWindowDel() {
 	debug("aedit: starting wait for window Del\n")
-	wsApi.Run()
+	err := wsApi.Run()
+	dieIfError(err, "lost the connection to Anvil")
+}
+
+func handleConnectionChanged(state api.ConnectionState, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "aedit: lost the connection to Anvil, reconnecting: %v\n", err)
+		return
+	}
+	debug("aedit: %s to Anvil\n", state)
+
+	// If the window is gone, it was closed while we were disconnected.
+	w, ok := findWindow(win.GlobalPath)
+	if !ok {
+		debug("aedit: the window is gone after reconnecting\n")
+		os.Exit(0)
+	}
+	win = w
 }
 
 func handleFileCloseNotification(notif *api.Notification, err error) {
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+
+	"gioui.org/io/key"
 	"gioui.org/layout"
 )
 
@@ -42,6 +44,10 @@ type adapter interface {
 	style() Style
 	setStyle(s Style)
 	insertWhenTabPressed() string
+	noteUserInteraction()
+	resumeTailing()
+	searchWrapped()
+	focusKey(ev *key.Event) (handled bool)
 }
 
 // editableAdapter connects an editable with the rest of the editor (it's owning window, etc)
@@ -88,6 +94,7 @@ func (a editableAdapter) execute(e *editable, gtx layout.Context, cmd string, ar
 	}
 
 	log(LogCatgCmd, "adapter: Execute '%s' %v\n", cmd, args)
+	editor.Macros.commandExecuted(cmd, args)
 	if a.executor != nil {
 		ctx := a.buildCmdContext(e, gtx, args)
 		ctx.RawCommand = cmd
@@ -119,7 +126,7 @@ func (a editableAdapter) findFile(file string) (path *GlobalPath, err error) {
 }
 
 func (a editableAdapter) buildCmdContext(e *editable, gtx layout.Context, args []string) *CmdContext {
-	dir, err := a.fileFinder.WindowDir()
+	dir, err := a.fileFinder.CommandDir()
 	if err != nil {
 		dir = ""
 	}
@@ -137,6 +144,7 @@ func (a *editableAdapter) setShellString(s string) {
 }
 
 func (a editableAdapter) plumb(e *editable, gtx layout.Context, obj string) (plumbed bool) {
+	HirePlumber()
 	if plumber != nil && a.executor != nil {
 		ctx := a.buildCmdContext(e, gtx, nil)
 		var err error
@@ -169,6 +177,7 @@ func (a editableAdapter) loadFileAndGoto(gtx layout.Context, path string, opts L
 	w := editor.LoadFileOpts(path, opts)
 	if w != nil {
 		w.SetFocus(gtx)
+		w.warpPointerToBody()
 	}
 }
 
@@ -178,6 +187,7 @@ func (a editableAdapter) loadFile(gtx layout.Context, path string) {
 	w := editor.LoadFileOpts(path, opts)
 	if w != nil {
 		w.SetFocus(gtx)
+		w.warpPointerToBody()
 	}
 }
 
@@ -317,6 +327,43 @@ func (a editableAdapter) setStyle(s Style) {
 	editor.SetStyle(s)
 }
 
+func (a editableAdapter) noteUserInteraction() {
+	noteInteractiveActivity()
+	switch v := a.owner.(type) {
+	case *Window:
+		if v.col != nil {
+			v.col.NoteInteraction()
+		}
+		v.noteInteraction()
+	case *Col:
+		v.NoteInteraction()
+	}
+}
+
+func (a editableAdapter) resumeTailing() {
+	if w, ok := a.owner.(*Window); ok {
+		w.SetTailing(true)
+	}
+}
+
+func (a editableAdapter) searchWrapped() {
+	w, _ := a.owner.(*Window)
+	application.Cue(cueSearchWrapped, w)
+}
+
+// focusKey moves the keyboard focus to another window if the key press ev is bound to a focus
+// action in the key settings.
+func (a editableAdapter) focusKey(ev *key.Event) (handled bool) {
+	act, ok := currentKeyBindings().lookup(ev)
+	if !ok {
+		return false
+	}
+
+	w, _ := a.owner.(*Window)
+	editor.moveFocus(w, a.column(), act)
+	return true
+}
+
 func (a editableAdapter) insertWhenTabPressed() string {
 	win, ok := a.owner.(*Window)
 	if !ok {
@@ -369,3 +416,7 @@ func (a nilAdapter) clearEditableWhereTertiaryButtonHoldStarted()
 func (a nilAdapter) style() Style                                                              { return Style{} }
 func (a nilAdapter) setStyle(s Style)                                                          {}
 func (a nilAdapter) insertWhenTabPressed() string                                              { return "\t" }
+func (a nilAdapter) noteUserInteraction()                                                      {}
+func (a nilAdapter) resumeTailing()                                                            {}
+func (a nilAdapter) searchWrapped()                                                            {}
+func (a nilAdapter) focusKey(ev *key.Event) (handled bool)                                     { return false }
@@ -11,12 +11,16 @@ import (
 	"io/ioutil"
 	"net"
 	"net/http"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 
 	"gioui.org/layout"
 	"github.com/gorilla/websocket"
+	"github.com/jeffwilliams/anvil/internal/runes"
 	"github.com/jszwec/csvutil"
 )
 
@@ -27,23 +31,45 @@ Summary of operations:
 
 
     GET /wins/: list window ids and paths
-   POST /wins/: create a new window and return the id
+   POST /wins/: create a new window and return it. The optional body {"path": "/a/file", "column": 1}
+                loads the file into the window and puts it in the column with that index.
     GET /wins/1/body: Get contents of body of window 1
     PUT /wins/1/body: Set contents of body of window 1
 	 POST /wins/1/body: Append to the contents of the body of window 1
-    GET /wins/1/body/info: Get info about window body (i.e. length)
-    PUT /wins/1/body?start=20&end=25: Set part of buffer in [20,25). Not implemented.
-    GET /wins/1/body/cursors: Get info about cursors in the window body
-    PUT /wins/1/body/cursors: Set position of cursors in the window body
+    GET /wins/1/body/info: Get info about window body (i.e. length, and size in characters)
+    GET /wins/1/body?start=20&end=25: Get the part of the body in [20,25), as rune offsets. Either
+                may be left out to mean the start or the end of the body.
+    PUT /wins/1/body?start=20&end=25: Replace the part of the body in [20,25) as one undoable edit
+    GET /wins/1/body/cursors: Get info about cursors in the window body. With ?fmt=linecol the line and
+                column of each cursor is given with its rune offset.
+    PUT /wins/1/body/cursors: Set position of cursors in the window body, as rune offsets like [12, 40], or
+                lines and columns like [{"line": 42, "col": 3}], or with ?addr=42.3 (repeated for
+                more cursors) in place of a request body
+    GET /wins/1/body/highlights: Get the manual highlights (as added by Tint) in the window body
+    PUT /wins/1/body/highlights: Replace the manual highlights in the window body
+   POST /wins/1/body/highlights: Add manual highlights to the window body. Highlights outside
+                the body are refused.
     GET /wins/1/info: get window information, such as file paths
     GET /wins/1/selections: get window selections
     GET /wins/1/tag: Get tag
     PUT /wins/1/tag: Set tag
-    GET /jobs: list jobs
+    GET /wins/1/mode: Get the mode of the window, including whether following output is paused
+    PUT /wins/1/mode: Set the mode of the window, as in {"followOutput": true, "userScrollPausesFollow": true}
+   POST /wins/1/move: Move window 1 to another column, as in {"column": 2} or {"name": "Logs"}. A column index
+                out of range is clamped; the response gives the column the window is in.
+    GET /jobs: list jobs with their ids and start times
+    GET /recent: list the files most recently closed, most recent first, with when they were closed
     GET /notifs: Get any pending notifications for the current API session. The notifications are then cleared.
 	 POST /cmds: Create a new client-defined command. If it already exists, register interest in it.
 	 POST /execute: Execute a command as if it was clicked. The command is executed as if it was run from the editor tag
-    GET /ws: upgrade the connection to a websocket
+	 POST /edits: Apply edits to many files. Either all the edits are applied or none are.
+	 POST /batch: Perform a list of operations on window bodies, tags and cursors, and execute commands,
+                all in one step so that no user edits happen between them. See batch.go.
+    GET /ws: upgrade the connection to a websocket. The notifications for the session are sent
+             over it, and the requests above can be made over it too. See apiwebsock.go.
+
+The scope of a session, set with ANVIL_API_SCOPE in the environment of the command it was created
+for, can make it read-only or limit it to one window. See apiscope.go.
 
 Supports JSON and CSV encodings. CSV is better for bash.
 
@@ -96,6 +122,11 @@ func (a ApiHandler) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if status, msg, ok := a.authorize(&sess, req); !ok {
+		http.Error(rsp, msg, status)
+		return
+	}
+
 	if req.URL.Path == "/wins" {
 		a.serveWindows(rsp, req)
 		return
@@ -109,7 +140,9 @@ func (a ApiHandler) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
 		case "/body/cursors":
 			fallthrough
 		case "/body/info":
-			a.serveWindowBody(winId, rsp, req, subpath)
+			fallthrough
+		case "/body/highlights":
+			a.serveWindowBody(&sess, winId, rsp, req, subpath)
 			return
 		case "/selections":
 			a.serveWindowSelections(winId, rsp, req)
@@ -120,10 +153,31 @@ func (a ApiHandler) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
 		case "/tag":
 			a.serveWindowTag(winId, rsp, req)
 			return
+		case "/mode":
+			a.serveWindowMode(winId, rsp, req)
+			return
+		case "/move":
+			a.serveWindowMove(winId, rsp, req)
+			return
+		}
+	} else if req.URL.Path == "/tag" {
+		a.serveEditorTag(rsp, req)
+		return
+	} else if req.URL.Path == "/cols" {
+		a.serveColumns(rsp, req)
+		return
+	} else if strings.HasPrefix(req.URL.Path, "/cols/") {
+		colIndex, subpath := a.parseInitialNumber(req.URL.Path[6:])
+		if subpath == "/tag" {
+			a.serveColumnTag(colIndex, rsp, req)
+			return
 		}
 	} else if req.URL.Path == "/jobs" {
 		a.serveJobs(rsp, req)
 		return
+	} else if req.URL.Path == "/recent" {
+		a.serveRecent(rsp, req)
+		return
 	} else if req.URL.Path == "/notifs" {
 		a.serveNotifs(&sess, rsp, req)
 		return
@@ -133,8 +187,15 @@ func (a ApiHandler) ServeHTTP(rsp http.ResponseWriter, req *http.Request) {
 	} else if req.URL.Path == "/execute" {
 		a.serveExecute(&sess, rsp, req)
 		return
+	} else if req.URL.Path == "/edits" {
+		a.serveEdits(rsp, req)
+		return
+	} else if req.URL.Path == "/batch" {
+		a.serveBatch(&sess, rsp, req)
+		return
 	} else if req.URL.Path == "/ws" {
 		a.serveWebsocket(&sess, rsp, req)
+		return
 	}
 
 	//if strings.HasPrefix(req.URL.Path, "/wins"
@@ -200,15 +261,45 @@ func (a ApiHandler) getWindows(rsp http.ResponseWriter, req *http.Request) {
 }
 
 func (a ApiHandler) postWindows(rsp http.ResponseWriter, req *http.Request) {
-	win := editor.NewWindow(nil)
-	if win == nil {
-		msg := fmt.Sprintf("Creating new window failed")
-		http.Error(rsp, msg, http.StatusInternalServerError)
+	var opts apiNewWindowReq
+
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		msg := fmt.Sprintf("Reading request body failed with error %v", err)
+		http.Error(rsp, msg, http.StatusBadRequest)
 		return
 	}
+	// A POST without a body creates an empty window.
+	if len(bytes.TrimSpace(data)) > 0 {
+		err = json.Unmarshal(data, &opts)
+		if err != nil {
+			msg := fmt.Sprintf("Decoding request body failed with error %v", err)
+			http.Error(rsp, msg, http.StatusBadRequest)
+			return
+		}
+	}
 
-	log(LogCatgAPI, "ApiHandler.postWindows: created new window with id %d\n", win.Id)
-	apiWin := apiWindow{Id: win.Id}
+	var apiWin apiWindow
+	var status int
+	done := make(chan struct{})
+	fn := func() {
+		defer close(done)
+		var win *Window
+		win, status, err = a.newWindow(opts)
+		if err == nil {
+			apiWin = a.buildWindowOnMainGoroutine(win)
+		}
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	<-done
+
+	if err != nil {
+		http.Error(rsp, err.Error(), status)
+		return
+	}
+
+	log(LogCatgAPI, "ApiHandler.postWindows: created new window with id %d for '%s'\n", apiWin.Id, apiWin.GlobalPath)
 
 	contentType, enc, flush := a.getEncoderForHTTPResponse(rsp, req)
 
@@ -217,6 +308,61 @@ func (a ApiHandler) postWindows(rsp http.ResponseWriter, req *http.Request) {
 	flush()
 }
 
+// apiNewWindowReq is the optional body of a POST to /wins.
+type apiNewWindowReq struct {
+	// Path is the path of the file to load into the window. If the file doesn't exist the
+	// window is empty and only its tag is set.
+	Path string `json:"path"`
+	// Column is the index of the column to create the window in. If it is not set the window is
+	// created in the column with the fewest windows.
+	Column *int `json:"column"`
+}
+
+// newWindow creates the window described by opts. It must be called in the main goroutine so that
+// the window is never seen without its file. If it fails, status is the HTTP status to respond with.
+func (a ApiHandler) newWindow(opts apiNewWindowReq) (win *Window, status int, err error) {
+	var col *Col
+	if opts.Column != nil {
+		if *opts.Column < 0 || *opts.Column >= len(editor.Cols) {
+			err = fmt.Errorf("No column with index %d", *opts.Column)
+			status = http.StatusBadRequest
+			return
+		}
+		col = editor.Cols[*opts.Column]
+		col.SetVisible(true)
+	}
+
+	if opts.Path != "" {
+		_, err = NewGlobalPath(opts.Path, GlobalPathUnknown)
+		if err != nil {
+			err = fmt.Errorf("Invalid path '%s': %v", opts.Path, err)
+			status = http.StatusBadRequest
+			return
+		}
+	}
+
+	win = editor.NewWindow(col)
+	if win == nil {
+		err = fmt.Errorf("Creating new window failed")
+		status = http.StatusInternalServerError
+		return
+	}
+
+	if opts.Path == "" {
+		return
+	}
+
+	err = win.LoadFileAndGoto(opts.Path, seek{}, selectText, growBodyIfTooSmall)
+	if err != nil {
+		win.col.markForRemoval(win)
+		err = fmt.Errorf("Loading '%s' failed: %v", opts.Path, err)
+		status = http.StatusInternalServerError
+		return
+	}
+	editor.notifyFileOpened(win)
+	return
+}
+
 func getEncoding(req *http.Request) (contentType apiEncoding) {
 	typ := req.Header.Get("Accept")
 	log(LogCatgAPI, "ApiHandler.getEncoding: Accept header is '%s'\n", typ)
@@ -292,38 +438,56 @@ const (
 	encodingTextPlain                   = "text/plain"
 )
 
-func (a ApiHandler) buildWindows() apiWindows {
-	// Retrieve the editor's list of windows, but run the
-	// function in the main goroutine so we don't cause race conditions.
-	ch := make(chan []*Window)
-
+func (a ApiHandler) buildWindows() (wins apiWindows) {
+	// Build the whole list in one function run in the main goroutine, so that we don't cause
+	// race conditions and don't wait for the main goroutine once per window.
+	done := make(chan struct{})
 	fn := func() {
-		ch <- editor.Windows()
+		for _, w := range editor.Windows() {
+			wins = append(wins, a.buildWindowOnMainGoroutine(w))
+		}
+		close(done)
 	}
 
 	editor.WorkChan() <- basicWork{fn}
-	edWins := <-ch
+	<-done
+	return
+}
 
-	var wins apiWindows
-	for _, w := range edWins {
-		wins = append(wins, a.buildWindow(w))
+func (a ApiHandler) buildWindow(w *Window) (aw apiWindow) {
+	// The window must only be examined in the main goroutine.
+	done := make(chan struct{})
+	fn := func() {
+		aw = a.buildWindowOnMainGoroutine(w)
+		close(done)
 	}
-	return wins
-}
 
-func (a ApiHandler) buildWindow(w *Window) apiWindow {
+	editor.WorkChan() <- basicWork{fn}
+	<-done
+	return
+}
 
+func (a ApiHandler) buildWindowOnMainGoroutine(w *Window) apiWindow {
 	finder := NewFileFinder(w)
 	file, err := finder.WindowFile()
 	if err != nil {
 		file = ""
 	}
 
-	return apiWindow{
-		Id:         w.Id,
-		GlobalPath: w.file,
-		Path:       file,
+	win := apiWindow{
+		Id:                  w.Id,
+		GlobalPath:          w.file,
+		Path:                file,
+		MissingFinalNewline: w.fileType == typeFile && lacksFinalNewline(w.Body.Bytes()),
+		Dirty:               w.IsDirty(),
+	}
+	if w.fileType == typeFile {
+		win.Encoding = w.encoding.String()
+	}
+	if dir, err := finder.CommandDir(); err == nil {
+		win.Dir = dir
 	}
+	return win
 }
 
 type apiWindows []apiWindow
@@ -332,26 +496,54 @@ type apiWindow struct {
 	Id         int
 	GlobalPath string
 	Path       string
+	// MissingFinalNewline is true if the window holds a file whose text doesn't end with a newline.
+	MissingFinalNewline bool `json:",omitempty"`
+	// Dirty is true if the window holds a file with changes that haven't been saved.
+	Dirty bool
+	// Encoding is the character encoding and line endings of the file in the window.
+	Encoding string `json:",omitempty"`
+	// Dir is the directory commands executed from the window run in. It is the directory set
+	// with Cd, or the directory of the file in the window.
+	Dir string
 }
 
 func (a ApiHandler) buildWindowBody(w *Window) apiWindowBody {
-	return apiWindowBody{
+	b := apiWindowBody{
 		Len: w.Body.Len(),
 	}
+
+	done := make(chan struct{})
+	fn := func() {
+		b.Cols, b.Rows = w.Body.sizeInCharacters()
+		b.Generation = w.Body.generation
+		close(done)
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	<-done
+	return b
 }
 
 type apiWindowBody struct {
 	Len int
+	// Cols and Rows are the approximate number of characters that fit across the body, and
+	// the number of lines that fit down it.
+	Cols, Rows int
+	// Generation changes whenever the body text changes. It can be passed to POST /edits
+	// to make sure the edits are applied to the text they were computed from.
+	Generation int
 }
 
-func (a ApiHandler) serveWindowBody(winId int, rsp http.ResponseWriter, req *http.Request, subpath string) {
+func (a ApiHandler) serveWindowBody(sess *ApiSession, winId int, rsp http.ResponseWriter, req *http.Request, subpath string) {
 	switch subpath {
 	case "/body/info":
 		a.serveWindowBodyInfo(winId, rsp, req)
 	case "/body/cursors":
 		a.serveWindowBodyCursors(winId, rsp, req)
+	case "/body/highlights":
+		a.serveWindowBodyHighlights(winId, rsp, req)
 	default:
-		a.serveWindowBodyContent(winId, rsp, req)
+		a.serveWindowBodyContent(sess, winId, rsp, req)
 	}
 }
 
@@ -399,11 +591,22 @@ func (a ApiHandler) getWindowBodyCursors(winId int, rsp http.ResponseWriter, req
 		return
 	}
 
-	ch := make(chan []int)
+	lineCol := req.URL.Query().Get("fmt") == "linecol"
+
+	ch := make(chan interface{})
 	fn := func() {
 		s := make([]int, len(win.Body.CursorIndices))
 		copy(s, win.Body.CursorIndices)
-		ch <- s
+		if !lineCol {
+			ch <- s
+			return
+		}
+
+		cursors := make([]apiCursor, len(s))
+		for i, lc := range runes.LineColsOfRunePositions(win.Body.Bytes(), s) {
+			cursors[i] = apiCursor{Offset: s[i], Line: lc.Line, Col: lc.Col}
+		}
+		ch <- cursors
 	}
 
 	editor.WorkChan() <- basicWork{fn}
@@ -416,27 +619,225 @@ func (a ApiHandler) getWindowBodyCursors(winId int, rsp http.ResponseWriter, req
 	flush()
 }
 
+// apiCursor is a cursor in a window body as returned by GET /wins/N/body/cursors?fmt=linecol.
+// Line and Col count from 1.
+type apiCursor struct {
+	Offset    int
+	Line, Col int
+}
+
+// apiCursorAddr is the address of a cursor to set in a window body: either a rune offset, or a
+// line and column counting from 1 if lineCol is set. In JSON it is a number or an object like
+// {"line": 42, "col": 3}.
+type apiCursorAddr struct {
+	offset  int
+	lineCol *runes.LineCol
+}
+
+func (c *apiCursorAddr) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 || b[0] != '{' {
+		return json.Unmarshal(b, &c.offset)
+	}
+
+	var lc struct{ Line, Col int }
+	if err := json.Unmarshal(b, &lc); err != nil {
+		return err
+	}
+	c.lineCol = &runes.LineCol{Line: lc.Line, Col: lc.Col}
+	return nil
+}
+
+// parseApiCursorAddr parses an addr query parameter, which is a line and optional column
+// separated by a period like 42.3.
+func parseApiCursorAddr(s string) (addr apiCursorAddr, err error) {
+	line, col, _ := strings.Cut(s, ".")
+	var lc runes.LineCol
+	if lc.Line, err = strconv.Atoi(line); err != nil {
+		return addr, fmt.Errorf("invalid line in address %q", s)
+	}
+	lc.Col = 1
+	if col != "" {
+		if lc.Col, err = strconv.Atoi(col); err != nil {
+			return addr, fmt.Errorf("invalid column in address %q", s)
+		}
+	}
+	addr.lineCol = &lc
+	return
+}
+
+// resolveApiCursorAddrs returns the rune offsets in text of addrs. The lines and columns are all
+// converted in one pass over text.
+func resolveApiCursorAddrs(text []byte, addrs []apiCursorAddr) []int {
+	offsets := make([]int, len(addrs))
+	var lcs []runes.LineCol
+	var lcIndices []int
+	for i, addr := range addrs {
+		if addr.lineCol == nil {
+			offsets[i] = addr.offset
+			continue
+		}
+		lcs = append(lcs, *addr.lineCol)
+		lcIndices = append(lcIndices, i)
+	}
+
+	if len(lcs) > 0 {
+		for i, off := range runes.RunePositionsOfLineCols(text, lcs) {
+			offsets[lcIndices[i]] = off
+		}
+	}
+	return offsets
+}
+
 func (a ApiHandler) putWindowBodyCursors(winId int, rsp http.ResponseWriter, req *http.Request) {
 	// We need to check the encoding of the request body that was sent usign the header, and then
 	// decode it using the right decoder (CSV or JSON).
 
-	var cursors []int
-
-	_, dec, err := a.getDecoder(rsp, req, "cursor_index")
-
+	cursors, err := a.decodeApiCursorAddrs(rsp, req)
 	if err != nil {
 		msg := fmt.Sprintf("Decoding request body failed with error %v", err)
 		http.Error(rsp, msg, http.StatusBadRequest)
 		return
 	}
 
-	err = dec.Decode(&cursors)
+	win := a.FindWindowForId(winId)
+
+	if win == nil {
+		msg := fmt.Sprintf("No window with id %d", winId)
+		http.Error(rsp, msg, http.StatusNotFound)
+		return
+	}
+
+	ch := make(chan []apiCursorAddr)
+	fn := func() {
+		cursors := <-ch
+		if !win.apiCursorMovesAllowed() {
+			log(LogCatgAPI, "ApiHandler.putWindowBodyCursors: ignoring cursors since following is paused\n")
+			return
+		}
+		win.Body.SetCursorIndices(resolveApiCursorAddrs(win.Body.Bytes(), cursors))
+		return
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	ch <- cursors
+}
+
+// decodeApiCursorAddrs returns the cursor addresses given by the addr query parameters of req,
+// or if there are none, in the request body. In CSV the body can only hold rune offsets.
+func (a ApiHandler) decodeApiCursorAddrs(rsp http.ResponseWriter, req *http.Request) (addrs []apiCursorAddr, err error) {
+	if params := req.URL.Query()["addr"]; len(params) > 0 {
+		addrs = make([]apiCursorAddr, len(params))
+		for i, p := range params {
+			if addrs[i], err = parseApiCursorAddr(p); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	contentType, dec, err := a.getDecoder(rsp, req, "cursor_index")
+	if err != nil {
+		return
+	}
+
+	if contentType == encodingTextCsv {
+		var offsets []int
+		if err = dec.Decode(&offsets); err != nil {
+			return
+		}
+		addrs = make([]apiCursorAddr, len(offsets))
+		for i, off := range offsets {
+			addrs[i].offset = off
+		}
+		return
+	}
+
+	err = dec.Decode(&addrs)
+	return
+}
+
+// apiHighlight is a manual highlight of the text in [Start,End) of a window body. Color is
+// a hex color code in the form #rrggbb or a color name, as accepted by Tint.
+type apiHighlight struct {
+	Start, End int
+	Color      string
+}
+
+func (a ApiHandler) serveWindowBodyHighlights(winId int, rsp http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet {
+		log(LogCatgAPI, "ApiHandler.serveWindowBodyHighlights: request to get highlights\n")
+		a.getWindowBodyHighlights(winId, rsp, req)
+		return
+	} else if req.Method == http.MethodPut || req.Method == http.MethodPost {
+		log(LogCatgAPI, "ApiHandler.serveWindowBodyHighlights: request to %s highlights\n", req.Method)
+		a.addWindowBodyHighlights(winId, rsp, req, req.Method == http.MethodPut)
+		return
+	}
+
+	msg := fmt.Sprintf("Method %s is not supported for %s", req.Method, req.URL.Path)
+	http.Error(rsp, msg, http.StatusBadRequest)
+}
+
+func (a ApiHandler) getWindowBodyHighlights(winId int, rsp http.ResponseWriter, req *http.Request) {
+	win := a.FindWindowForId(winId)
+
+	if win == nil {
+		msg := fmt.Sprintf("No window with id %d", winId)
+		http.Error(rsp, msg, http.StatusNotFound)
+		return
+	}
+
+	ch := make(chan []apiHighlight)
+	fn := func() {
+		hls := make([]apiHighlight, len(win.Body.manualHighlighting))
+		for i, h := range win.Body.manualHighlighting {
+			hls[i] = apiHighlight{Start: h.start, End: h.end, Color: fmt.Sprintf("#%02x%02x%02x", h.color.R, h.color.G, h.color.B)}
+		}
+		ch <- hls
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	hls := <-ch
+
+	contentType, enc, flush := a.getEncoderForHTTPResponse(rsp, req)
+
+	rsp.Header().Add("Content-Type", string(contentType))
+	enc.Encode(hls)
+	flush()
+}
+
+// addWindowBodyHighlights adds the highlights in the request body to the window body. If
+// replace is true the existing highlights are removed first. If a highlight is outside the body
+// none of them are added.
+func (a ApiHandler) addWindowBodyHighlights(winId int, rsp http.ResponseWriter, req *http.Request, replace bool) {
+	var hls []apiHighlight
+
+	_, dec, err := a.getDecoder(rsp, req, "start", "end", "color")
+	if err == nil {
+		err = dec.Decode(&hls)
+	}
 	if err != nil {
 		msg := fmt.Sprintf("Decoding request body failed with error %v", err)
 		http.Error(rsp, msg, http.StatusBadRequest)
 		return
 	}
 
+	colors := make([]Color, len(hls))
+	for i, h := range hls {
+		c, ok := ColorFromName(h.Color)
+		if !ok && h.Color != "" {
+			c, err = ParseHexColor(h.Color)
+			ok = err == nil
+		}
+		if !ok {
+			msg := fmt.Sprintf("Highlight %d has an invalid color '%s'", i, h.Color)
+			http.Error(rsp, msg, http.StatusBadRequest)
+			return
+		}
+		colors[i] = c
+	}
+
 	win := a.FindWindowForId(winId)
 
 	if win == nil {
@@ -445,21 +846,35 @@ func (a ApiHandler) putWindowBodyCursors(winId int, rsp http.ResponseWriter, req
 		return
 	}
 
-	ch := make(chan []int)
+	done := make(chan struct{})
+	var msg string
 	fn := func() {
-		cursors := <-ch
-		win.Body.SetCursorIndices(cursors)
-		return
+		defer close(done)
+		for i, h := range hls {
+			if h.Start < 0 || h.Start > h.End || h.End > win.Body.Len() {
+				msg = fmt.Sprintf("Highlight %d from %d to %d is outside the body of window %d, which has %d runes", i, h.Start, h.End, win.Id, win.Body.Len())
+				return
+			}
+		}
+		if replace {
+			win.Body.ClearManualHighlights()
+		}
+		for i, h := range hls {
+			win.Body.AddManualHighlight(h.Start, h.End, colors[i])
+		}
 	}
 
 	editor.WorkChan() <- basicWork{fn}
-	ch <- cursors
+	<-done
+	if msg != "" {
+		http.Error(rsp, msg, http.StatusBadRequest)
+	}
 }
 
-func (a ApiHandler) serveWindowBodyContent(winId int, rsp http.ResponseWriter, req *http.Request) {
+func (a ApiHandler) serveWindowBodyContent(sess *ApiSession, winId int, rsp http.ResponseWriter, req *http.Request) {
 	if req.Method == http.MethodGet {
 		log(LogCatgAPI, "ApiHandler.serveWindowBody: request to get content\n")
-		a.getWindowBodyContent(winId, rsp, req)
+		a.getWindowBodyContent(sess, winId, rsp, req)
 		return
 	} else if req.Method == http.MethodPut {
 		log(LogCatgAPI, "ApiHandler.serveWindowBody: request to put content\n")
@@ -475,7 +890,7 @@ func (a ApiHandler) serveWindowBodyContent(winId int, rsp http.ResponseWriter, r
 	http.Error(rsp, msg, http.StatusBadRequest)
 }
 
-func (a ApiHandler) getWindowBodyContent(winId int, rsp http.ResponseWriter, req *http.Request) {
+func (a ApiHandler) getWindowBodyContent(sess *ApiSession, winId int, rsp http.ResponseWriter, req *http.Request) {
 
 	win := a.FindWindowForId(winId)
 
@@ -485,13 +900,43 @@ func (a ApiHandler) getWindowBodyContent(winId int, rsp http.ResponseWriter, req
 		return
 	}
 
+	r, err := parseApiBodyRange(req)
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	ch := make(chan []byte)
+	var rangeErr error
 	fn := func() {
-		ch <- win.Body.Bytes()
+		if !sess.mayReadBodyOf(win) {
+			close(ch)
+			return
+		}
+		if r == nil {
+			ch <- win.Body.Bytes()
+			return
+		}
+		var start, end int
+		start, end, rangeErr = r.resolve(win.Body.text.Len())
+		if rangeErr != nil {
+			ch <- nil
+			return
+		}
+		ch <- win.Body.bytesBetweenRuneIndices(win.Body.Bytes(), start, end)
 	}
 
 	editor.WorkChan() <- basicWork{fn}
-	content := <-ch
+	content, ok := <-ch
+	if !ok {
+		msg := fmt.Sprintf("Window %d is sensitive. Its body may only be read by commands run from that window", winId)
+		http.Error(rsp, msg, http.StatusForbidden)
+		return
+	}
+	if rangeErr != nil {
+		http.Error(rsp, rangeErr.Error(), http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
 
 	rsp.Header().Add("Content-Type", encodingTextPlain)
 	rsp.Write(content)
@@ -507,20 +952,23 @@ func (a ApiHandler) putWindowBodyContent(winId int, rsp http.ResponseWriter, req
 		return
 	}
 
+	r, err := parseApiBodyRange(req)
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r != nil {
+		a.putWindowBodyRange(win, r, rsp, req)
+		return
+	}
+
 	ch := make(chan []byte)
 	fn := func() {
 		data, ok := <-ch
 		if !ok {
 			return
 		}
-		ci := win.Body.blockEditable.firstCursorIndex()
-		tl := win.Body.TopLeftIndex
-		win.Body.SetText(data)
-		win.SetTag()
-		win.Body.AddOpForNextLayout(func(gtx layout.Context) {
-			win.Body.moveCursorTo(gtx, seek{seekType: seekToRunePos, runePos: ci}, dontSelectText)
-			win.Body.TopLeftIndex = tl
-		})
+		setWindowBodyFromApi(win, data)
 	}
 
 	editor.WorkChan() <- basicWork{fn}
@@ -534,6 +982,113 @@ func (a ApiHandler) putWindowBodyContent(winId int, rsp http.ResponseWriter, req
 	ch <- data
 }
 
+// setWindowBodyFromApi replaces the body of win with data, keeping the cursor and the top of
+// the body where they were. It must be called in the main goroutine.
+func setWindowBodyFromApi(win *Window, data []byte) {
+	ci := win.Body.blockEditable.firstCursorIndex()
+	tl := win.Body.TopLeftIndex
+	win.Body.SetText(data)
+	win.SetTag()
+	win.Body.AddOpForNextLayout(func(gtx layout.Context) {
+		win.Body.moveCursorTo(gtx, seek{seekType: seekToRunePos, runePos: ci}, dontSelectText)
+		win.Body.TopLeftIndex = tl
+	})
+}
+
+// putWindowBodyRange replaces the part of the body of win in the range r with the request body.
+// It is applied like the edits of POST /edits, so it shifts the cursors and selections and is
+// undone in one step.
+func (a ApiHandler) putWindowBodyRange(win *Window, r *apiBodyRange, rsp http.ResponseWriter, req *http.Request) {
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		msg := fmt.Sprintf("Reading request body failed with error %v", err)
+		http.Error(rsp, msg, http.StatusInternalServerError)
+		return
+	}
+
+	var status int
+	var msg string
+	done := make(chan struct{})
+	fn := func() {
+		defer close(done)
+		body := &win.Body.editable
+		start, end, err := r.resolve(body.text.Len())
+		if err != nil {
+			status, msg = http.StatusRequestedRangeNotSatisfiable, err.Error()
+			return
+		}
+		if body.writeLock.isLocked() {
+			status, msg = http.StatusConflict, fmt.Sprintf("Window %d body is locked", win.Id)
+			return
+		}
+		if body.affectsImmutableRange(start, end) {
+			status, msg = http.StatusConflict, fmt.Sprintf("Range [%d,%d) contains text that can't be modified", start, end)
+			return
+		}
+		win.applyEdits([]apiEdit{{Offset: start, Length: end - start, Text: string(data)}})
+		win.SetTag()
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	<-done
+	if status != 0 {
+		http.Error(rsp, msg, status)
+	}
+}
+
+// apiBodyRange is the range of a window body given by the start and end query parameters, as
+// rune offsets. A parameter that is left out is the start or the end of the body.
+type apiBodyRange struct {
+	start, end *int
+}
+
+// parseApiBodyRange parses the start and end query parameters of req. It returns nil if
+// neither is given.
+func parseApiBodyRange(req *http.Request) (r *apiBodyRange, err error) {
+	q := req.URL.Query()
+	parse := func(name string) (*int, error) {
+		if !q.Has(name) {
+			return nil, nil
+		}
+		v, err := strconv.Atoi(q.Get(name))
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("Invalid %s parameter %q: it must be a rune offset", name, q.Get(name))
+		}
+		return &v, nil
+	}
+
+	var b apiBodyRange
+	if b.start, err = parse("start"); err != nil {
+		return
+	}
+	if b.end, err = parse("end"); err != nil {
+		return
+	}
+	if b.start == nil && b.end == nil {
+		return
+	}
+	return &b, nil
+}
+
+// resolve returns the offsets of the range in a body that is length runes long.
+func (r apiBodyRange) resolve(length int) (start, end int, err error) {
+	start, end = 0, length
+	if r.start != nil {
+		start = *r.start
+	}
+	if r.end != nil {
+		end = *r.end
+	}
+	if start > end {
+		err = fmt.Errorf("Range start %d is after its end %d", start, end)
+		return
+	}
+	if end > length {
+		err = fmt.Errorf("Range end %d is past the end of the body (length %d)", end, length)
+	}
+	return
+}
+
 func (a ApiHandler) postWindowBodyContent(winId int, rsp http.ResponseWriter, req *http.Request) {
 
 	win := a.FindWindowForId(winId)
@@ -551,7 +1106,9 @@ func (a ApiHandler) postWindowBodyContent(winId int, rsp http.ResponseWriter, re
 			return
 		}
 
+		atEnd := win.Body.endOfDocVisible()
 		win.Body.Append(data)
+		win.followAppendedOutput(atEnd)
 		/*
 			ci := win.Body.blockEditable.firstCursorIndex()
 			tl := win.Body.TopLeftIndex
@@ -643,6 +1200,177 @@ func (a ApiHandler) serveWindowInfo(winId int, rsp http.ResponseWriter, req *htt
 	flush()
 }
 
+// apiWindowMode is the mode of a window driven by a tool, as set using PUT /wins/1/mode.
+type apiWindowMode struct {
+	// FollowOutput scrolls the body to the end, and moves the cursor there, when text is
+	// appended to it through the API while the end of the body is visible.
+	FollowOutput bool `json:"followOutput" csv:"followOutput"`
+	// UserScrollPausesFollow pauses following while the user has scrolled away from the end.
+	UserScrollPausesFollow bool `json:"userScrollPausesFollow" csv:"userScrollPausesFollow"`
+	// Paused is true while following is paused. Cursor moves made through the API are ignored
+	// while it is true. It is ignored by PUT.
+	Paused bool `json:"paused" csv:"paused"`
+}
+
+func (a ApiHandler) serveWindowMode(winId int, rsp http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet {
+		a.getWindowMode(winId, rsp, req)
+		return
+	} else if req.Method == http.MethodPut {
+		a.putWindowMode(winId, rsp, req)
+		return
+	}
+
+	msg := fmt.Sprintf("Method %s is not supported for %s", req.Method, req.URL.Path)
+	http.Error(rsp, msg, http.StatusBadRequest)
+}
+
+func (a ApiHandler) getWindowMode(winId int, rsp http.ResponseWriter, req *http.Request) {
+	win := a.FindWindowForId(winId)
+
+	if win == nil {
+		msg := fmt.Sprintf("No window with id %d", winId)
+		http.Error(rsp, msg, http.StatusNotFound)
+		return
+	}
+
+	ch := make(chan apiWindowMode)
+	fn := func() {
+		ch <- apiWindowMode{
+			FollowOutput:           win.mode.followOutput,
+			UserScrollPausesFollow: win.mode.userScrollPausesFollow,
+			Paused:                 win.mode.paused,
+		}
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	mode := <-ch
+
+	contentType, enc, flush := a.getEncoderForHTTPResponse(rsp, req)
+
+	rsp.Header().Add("Content-Type", string(contentType))
+	enc.Encode(mode)
+	flush()
+}
+
+func (a ApiHandler) putWindowMode(winId int, rsp http.ResponseWriter, req *http.Request) {
+	var mode apiWindowMode
+
+	_, dec, err := a.getDecoder(rsp, req, "followOutput", "userScrollPausesFollow", "paused")
+	if err == nil {
+		err = dec.Decode(&mode)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Decoding request body failed with error %v", err)
+		http.Error(rsp, msg, http.StatusBadRequest)
+		return
+	}
+
+	win := a.FindWindowForId(winId)
+
+	if win == nil {
+		msg := fmt.Sprintf("No window with id %d", winId)
+		http.Error(rsp, msg, http.StatusNotFound)
+		return
+	}
+
+	done := make(chan struct{})
+	fn := func() {
+		win.SetMode(mode.FollowOutput, mode.UserScrollPausesFollow)
+		close(done)
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	<-done
+}
+
+// apiWindowMoveReq is the body of a POST to /wins/1/move. Either Column or Name is set.
+type apiWindowMoveReq struct {
+	// Column is the index of the column to move the window to, as in GET /cols. If it is out
+	// of range it is clamped to the nearest column.
+	Column *int `json:"column"`
+	// Name is the name of the column to move the window to, which is the first word of its tag.
+	Name string `json:"name"`
+}
+
+// apiWindowMove is the response to a POST to /wins/1/move.
+type apiWindowMove struct {
+	// Column is the index of the column the window was moved to.
+	Column int `json:"column"`
+	// Clamped is true if the requested column index was out of range.
+	Clamped bool `json:"clamped"`
+}
+
+func (a ApiHandler) serveWindowMove(winId int, rsp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		msg := fmt.Sprintf("Method %s is not supported for %s", req.Method, req.URL.Path)
+		http.Error(rsp, msg, http.StatusBadRequest)
+		return
+	}
+
+	var opts apiWindowMoveReq
+	err := json.NewDecoder(req.Body).Decode(&opts)
+	if err == nil && opts.Column == nil && opts.Name == "" {
+		err = fmt.Errorf("a column or name is required")
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Decoding request body failed with error %v", err)
+		http.Error(rsp, msg, http.StatusBadRequest)
+		return
+	}
+
+	var move apiWindowMove
+	var status int
+	done := make(chan struct{})
+	fn := func() {
+		defer close(done)
+		move, status, err = a.moveWindow(winId, opts)
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	<-done
+
+	if err != nil {
+		http.Error(rsp, err.Error(), status)
+		return
+	}
+
+	contentType, enc, flush := a.getEncoderForHTTPResponse(rsp, req)
+	rsp.Header().Add("Content-Type", string(contentType))
+	enc.Encode(move)
+	flush()
+}
+
+// moveWindow moves the window with id winId as opts says. It must be called in the main goroutine.
+// If it fails, status is the HTTP status to respond with.
+func (a ApiHandler) moveWindow(winId int, opts apiWindowMoveReq) (move apiWindowMove, status int, err error) {
+	win := editor.FindWindowForId(winId)
+	if win == nil {
+		return move, http.StatusNotFound, fmt.Errorf("No window with id %d", winId)
+	}
+	if len(editor.Cols) == 0 {
+		return move, http.StatusInternalServerError, fmt.Errorf("There are no columns")
+	}
+
+	if opts.Column != nil {
+		move.Column, move.Clamped = clampColIndex(*opts.Column, len(editor.Cols))
+	} else {
+		move.Column = -1
+		for i, c := range editor.Cols {
+			if c.Name() == opts.Name {
+				move.Column = i
+				break
+			}
+		}
+		if move.Column < 0 {
+			return move, http.StatusNotFound, fmt.Errorf("No column named %s", opts.Name)
+		}
+	}
+
+	editor.MoveWindowToCol(win, editor.Cols[move.Column])
+	return
+}
+
 func (a ApiHandler) serveWindowTag(winId int, rsp http.ResponseWriter, req *http.Request) {
 	if req.Method == http.MethodGet {
 		a.getWindowTag(winId, rsp, req)
@@ -686,42 +1414,25 @@ func (a ApiHandler) putWindowTag(winId int, rsp http.ResponseWriter, req *http.R
 		return
 	}
 
-	ch := make(chan []byte)
-	fn := func() {
-		data, ok := <-ch
-		if !ok {
-			return
-		}
-
-		file := ""
-		edArea := ""
-		s := string(data)
-		parts, _, err := calculateTagParts(s)
-		if err == nil {
-			file = s[parts.path[0]:parts.path[1]]
-			edArea = s[parts.editorArea[0]:parts.editorArea[1]]
-		} else {
-			log(LogCatgAPI, "APIHandler: calculating tag parts failed: %v\n", err)
-		}
+	data, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		msg := fmt.Sprintf("Reading request body failed with error %v", err)
+		http.Error(rsp, msg, http.StatusInternalServerError)
+		return
+	}
 
-		win.file = file
-		win.fileType = typeFile
-		win.initialTagUserArea = ""
-		win.customEdCommands = edArea
+	ch := make(chan error)
+	fn := func() {
 		log(LogCatgAPI, "APIHandler: setting window %d tag to '%s'\n", winId, data)
-		win.Tag.SetText(data)
-		return
+		ch <- win.SetWholeTag(string(data))
 	}
 
 	editor.WorkChan() <- basicWork{fn}
-	data, err := ioutil.ReadAll(req.Body)
+	err = <-ch
 	if err != nil {
-		msg := fmt.Sprintf("Reading request body failed with error %v", err)
-		http.Error(rsp, msg, http.StatusInternalServerError)
-		close(ch)
-		return
+		msg := fmt.Sprintf("Setting the tag failed: %v", err)
+		http.Error(rsp, msg, http.StatusBadRequest)
 	}
-	ch <- data
 }
 
 func (a ApiHandler) serveJobs(rsp http.ResponseWriter, req *http.Request) {
@@ -746,28 +1457,29 @@ func (a ApiHandler) getJobs(rsp http.ResponseWriter, req *http.Request) {
 }
 
 func (a ApiHandler) buildJobs() apiJobs {
-	// Retrieve the editor's list of windows, but run the
+	// Retrieve the editor's list of jobs, but run the
 	// function in the main goroutine so we don't cause race conditions.
-	ch := make(chan []Job)
+	ch := make(chan apiJobs)
 
 	fn := func() {
-		ch <- editor.Jobs()
+		var jobs apiJobs
+		for _, j := range editor.Jobs() {
+			jobs = append(jobs, a.buildJob(j))
+		}
+		ch <- jobs
 	}
 
 	editor.WorkChan() <- basicWork{fn}
-	edJobs := <-ch
-
-	var jobs apiJobs
-	for _, j := range edJobs {
-		jobs = append(jobs, a.buildJob(j))
-	}
-	return jobs
+	return <-ch
 }
 
 func (a ApiHandler) buildJob(j Job) apiJob {
-
+	started := editor.JobStartTime(j)
 	return apiJob{
-		Name: j.Name(),
+		Name:           j.Name(),
+		Id:             editor.JobId(j),
+		Started:        started,
+		ElapsedSeconds: time.Since(started).Seconds(),
 	}
 }
 
@@ -775,6 +1487,49 @@ type apiJobs []apiJob
 
 type apiJob struct {
 	Name string
+	// Id identifies the job among all the jobs run by the editor. It can be passed to Kill as #id.
+	Id             int
+	Started        time.Time
+	ElapsedSeconds float64
+}
+
+func (a ApiHandler) serveRecent(rsp http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodGet {
+		a.getRecent(rsp, req)
+		return
+	}
+
+	msg := fmt.Sprintf("Method %s is not supported for %s", req.Method, req.URL.Path)
+	http.Error(rsp, msg, http.StatusBadRequest)
+}
+
+func (a ApiHandler) getRecent(rsp http.ResponseWriter, req *http.Request) {
+	// Read the list in the main goroutine so we don't cause race conditions.
+	ch := make(chan []apiClosedFile)
+
+	fn := func() {
+		var files []apiClosedFile
+		for _, f := range editor.closedFiles.all() {
+			files = append(files, apiClosedFile{Path: f.File, Cursor: f.Cursor, Closed: f.Closed})
+		}
+		ch <- files
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	files := <-ch
+
+	contentType, enc, flush := a.getEncoderForHTTPResponse(rsp, req)
+
+	rsp.Header().Add("Content-Type", string(contentType))
+	enc.Encode(files)
+	flush()
+}
+
+type apiClosedFile struct {
+	Path string
+	// Cursor is the rune offset of the cursor in the file when it was closed.
+	Cursor int
+	Closed time.Time
 }
 
 func (a ApiHandler) serveNotifs(sess *ApiSession, rsp http.ResponseWriter, req *http.Request) {
@@ -864,6 +1619,13 @@ func (a ApiHandler) execute(sess *ApiSession, rsp http.ResponseWriter, req *http
 		return
 	}
 
+	if !sess.mayUseWindow(cmd.WinId) {
+		id, _ := sess.scopedWinId()
+		msg := fmt.Sprintf("The API session may only execute commands in window %d", id)
+		http.Error(rsp, msg, http.StatusForbidden)
+		return
+	}
+
 	if cmd.WinId < 0 {
 		log(LogCatgAPI, "ApiHandler.execute: running command '%s %v' in context of editor tag\n", cmd.Cmd, strings.Join(cmd.Args, " "))
 		editor.Execute(cmd.Cmd, cmd.Args)
@@ -919,10 +1681,13 @@ func (a ApiHandler) serveWebsocket(sess *ApiSession, rsp http.ResponseWriter, re
 
 	log(LogCatgAPI, "APIHandler.serveWebsocket: upgraded session %s to websocket\n", sess.Id())
 
-	sess.websockCtx = &apiSessionWebsockCtx{
+	ctx := apiSessionWebsockCtx{
 		websock:     conn,
 		apiEncoding: getEncoding(req),
+		lock:        &sync.Mutex{},
 	}
+	startApiNotificationQueue(sess, ctx)
+	go a.serveWebsocketRequests(sess.Id(), ctx)
 
 	updateApiSession(sess)
 }
@@ -977,6 +1742,9 @@ func (s *ApiSessionStore) Find(id ApiSessionId) (sess ApiSession, ok bool) {
 func (s *ApiSessionStore) Del(id ApiSessionId) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	if sess, ok := s.sessions[id]; ok && sess.websock != nil {
+		sess.websock.stop()
+	}
 	delete(s.sessions, id)
 }
 
@@ -986,6 +1754,7 @@ func (s *ApiSessionStore) Len() int {
 	return len(s.sessions)
 }
 
+// All returns the sessions in the order they were created.
 func (s *ApiSessionStore) All() []*ApiSession {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -994,9 +1763,28 @@ func (s *ApiSessionStore) All() []*ApiSession {
 	for _, v := range s.sessions {
 		r = append(r, v)
 	}
+	sort.Slice(r, func(i, j int) bool {
+		if !r[i].created.Equal(r[j].created) {
+			return r[i].created.Before(r[j].created)
+		}
+		return r[i].id < r[j].id
+	})
 	return r
 }
 
+// HasWebsocket returns true if a session has a websocket open to receive notifications.
+func (s *ApiSessionStore) HasWebsocket() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, sess := range s.sessions {
+		if sess.websock != nil {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *ApiSessionStore) AddNotificationToAll(n ApiNotification) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
@@ -1027,6 +1815,9 @@ func (s *ApiSessionStore) HandleCommand(winId int, cmd string, args []string) (h
 	log(LogCatgAPI, "ApiSessionStore.HandleCommand: called\n")
 	for _, sess := range s.sessions {
 		log(LogCatgAPI, "ApiSessionStore.HandleCommand: checking session. Has %d commands\n", len(sess.userDefinedCommands))
+		if !sess.mayUseWindow(winId) {
+			continue
+		}
 		for _, scmd := range sess.userDefinedCommands {
 			log(LogCatgAPI, "ApiSessionStore.HandleCommand: checking command %s vs %s\n", cmd, scmd)
 			if scmd == cmd {
@@ -1066,10 +1857,15 @@ type ApiSession struct {
 	pendingNotifications []ApiNotification
 	cmd                  string
 	userDefinedCommands  []string
-	websockCtx           *apiSessionWebsockCtx
+	// websock delivers the notifications for the session if it has a websocket.
+	websock *apiNotificationQueue
+	scopes  []string
+	created time.Time
 }
 
-func createApiSession(cmd string) (sess *ApiSession, err error) {
+// createApiSession creates a session for a command. The scopes grant the session access
+// that API sessions don't have by default, such as apiScopeSensitive.
+func createApiSession(cmd string, scopes ...string) (sess *ApiSession, err error) {
 
 	buf := make([]byte, 200)
 
@@ -1079,8 +1875,10 @@ func createApiSession(cmd string) (sess *ApiSession, err error) {
 	}
 
 	sess = &ApiSession{
-		id:  ApiSessionId(base64.StdEncoding.EncodeToString(buf)),
-		cmd: cmd,
+		id:      ApiSessionId(base64.StdEncoding.EncodeToString(buf)),
+		cmd:     cmd,
+		scopes:  scopes,
+		created: time.Now(),
 	}
 
 	apiSessions.Add(sess)
@@ -1106,6 +1904,37 @@ func getApiSessions() []*ApiSession {
 
 func addApiNotificationToAllSessions(n ApiNotification) {
 	apiSessions.AddNotificationToAll(n)
+	notifyApiNotificationObservers(n)
+}
+
+// apiNotificationObservers are called with the notifications sent to API sessions, so that
+// features inside the editor can react to the same events as API clients. They are also called
+// with an exec notification for every command executed, not only those defined by API clients.
+// They are only used from the main goroutine.
+var apiNotificationObservers = map[int]func(ApiNotification){}
+var nextApiNotificationObserver int
+
+// observeApiNotifications adds an observer of notifications. Calling stop removes it.
+func observeApiNotifications(fn func(ApiNotification)) (stop func()) {
+	id := nextApiNotificationObserver
+	nextApiNotificationObserver++
+	apiNotificationObservers[id] = fn
+	return func() { delete(apiNotificationObservers, id) }
+}
+
+func notifyApiNotificationObservers(n ApiNotification) {
+	if len(apiNotificationObservers) == 0 {
+		return
+	}
+
+	// Observers may add or remove observers.
+	fns := make([]func(ApiNotification), 0, len(apiNotificationObservers))
+	for _, fn := range apiNotificationObservers {
+		fns = append(fns, fn)
+	}
+	for _, fn := range fns {
+		fn(n)
+	}
 }
 
 func apiGetAndClearNotifications(id ApiSessionId) []ApiNotification {
@@ -1124,13 +1953,22 @@ func (s *ApiSession) Cmd() string {
 	return s.cmd
 }
 
+func (s *ApiSession) hasScope(name string) bool {
+	for _, sc := range s.scopes {
+		if sc == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *ApiSession) AddNotification(n ApiNotification) {
+	if !s.mayUseWindow(n.WinId) {
+		return
+	}
 	log(LogCatgAPI, "ApiSession.AddNotification: adding notification %+v\n", n)
-	if s.websockCtx != nil {
-		err := s.sendNotificationOverWebsock(n)
-		if err != nil {
-			log(LogCatgAPI, "ApiSession.AddNotification: sending over websock failed: %v\n", err)
-		}
+	if s.websock != nil {
+		s.websock.add(n)
 		return
 	}
 	s.addPendingNotification(n)
@@ -1143,21 +1981,6 @@ func (s *ApiSession) addPendingNotification(n ApiNotification) {
 	s.pendingNotifications = append(s.pendingNotifications, n)
 }
 
-func (s *ApiSession) sendNotificationOverWebsock(n ApiNotification) error {
-	if s.websockCtx == nil {
-		return fmt.Errorf("No websocket is available")
-	}
-
-	enc, flush, err := s.websockCtx.encoder()
-	if err != nil {
-		return err
-	}
-
-	enc.Encode(n)
-	flush()
-	return nil
-}
-
 func (a *ApiSession) addUserDefinedCommand(s string) {
 	t := a.textBeforeFirstSpace(s)
 	log(LogCatgAPI, "ApiSession.addUserDefinedCommand: cmd: '%s' cleaned: '%s'\n", s, t)
@@ -1179,36 +2002,17 @@ func (a *ApiSession) textBeforeFirstSpace(s string) string {
 	return buf.String()
 }
 
-type apiSessionWebsockCtx struct {
-	websock     *websocket.Conn
-	apiEncoding apiEncoding
-}
-
-func (c apiSessionWebsockCtx) encoder() (enc Encoder, flush func(), err error) {
-	w, err := c.websock.NextWriter(websocket.TextMessage)
-
-	if err != nil {
-		return
-	}
-
-	var innerFlush func()
-	enc, innerFlush = getEncoder(w, c.apiEncoding)
-
-	flush = func() {
-		innerFlush()
-		w.Close()
-	}
-
-	return
-
-}
-
 type ApiNotification struct {
 	WinId  int
 	Op     ApiNotificationOp
 	Offset int
 	Len    int
 	Cmd    []string
+	// Col is the index of the column whose tag changed, for ApiNotificationOpColTagChanged.
+	Col int
+	// Selections are the selections in the window body for ApiNotificationOpSelectionChanged,
+	// the primary selection first.
+	Selections []apiSelection
 }
 
 type ApiNotificationOp int
@@ -1220,6 +2024,11 @@ const (
 	ApiNotificationOpPut
 	ApiNotificationOpFileClosed
 	ApiNotificationOpFileOpened
+	ApiNotificationOpDirty
+	ApiNotificationOpClean
+	ApiNotificationOpEditorTagChanged
+	ApiNotificationOpColTagChanged
+	ApiNotificationOpSelectionChanged
 )
 
 func (o ApiNotificationOp) String() string {
@@ -1236,13 +2045,27 @@ func (o ApiNotificationOp) String() string {
 		return "FileClosed"
 	case ApiNotificationOpFileOpened:
 		return "FileOpened"
+	case ApiNotificationOpDirty:
+		return "Dirty"
+	case ApiNotificationOpClean:
+		return "Clean"
+	case ApiNotificationOpEditorTagChanged:
+		return "EditorTagChanged"
+	case ApiNotificationOpColTagChanged:
+		return "ColTagChanged"
+	case ApiNotificationOpSelectionChanged:
+		return "SelectionChanged"
 	default:
 		return "?"
 	}
 }
 
+// WebsockMessageId is the kind of a message sent to the client over a websocket, given by the
+// field Message. Notifications have no such field.
 type WebsockMessageId int
 
 const (
 	WebsockMessageNotification = iota
+	// WebsockMessageResponse is the response to a request made over the websocket.
+	WebsockMessageResponse
 )
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+func TestWindowDirtyStateVisibleToApi(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag(filepath.Join(t.TempDir(), "a.txt"), typeFile)
+		win.Body.SetText([]byte("text\n"))
+		win.markTextAsUnchanged()
+	})
+
+	dirty := func() bool {
+		t.Helper()
+		wins, err := anvil.Windows()
+		if err != nil {
+			t.Fatalf("listing windows failed: %v", err)
+		}
+		for _, w := range wins {
+			if w.Id == win.Id {
+				return w.Dirty
+			}
+		}
+		t.Fatalf("window %d was not listed", win.Id)
+		return false
+	}
+
+	if dirty() {
+		t.Fatalf("expected an unchanged window not to be dirty")
+	}
+
+	onMainGoroutine(func() {
+		win.Body.InsertText("more ")
+	})
+	if !dirty() {
+		t.Fatalf("expected a changed window to be dirty")
+	}
+
+	onMainGoroutine(func() {
+		win.markTextAsUnchanged()
+	})
+	if dirty() {
+		t.Fatalf("expected a saved window not to be dirty")
+	}
+}
+
+func TestWindowDirtyNotificationsWhenStateChanges(t *testing.T) {
+	h := newTestHeadless()
+	win := h.OpenWindow(filepath.Join(t.TempDir(), "a.txt"), []byte("text\n"))
+
+	var ops []ApiNotificationOp
+	stop := observeApiNotifications(func(n ApiNotification) {
+		if n.WinId == win.Id && (n.Op == ApiNotificationOpDirty || n.Op == ApiNotificationOpClean) {
+			ops = append(ops, n.Op)
+		}
+	})
+	defer stop()
+
+	// The notifications are sent when the state changes, without waiting for the window to be
+	// laid out.
+	win.Body.InsertText("more ")
+	win.Body.InsertText("and more ")
+	if len(ops) != 1 || ops[0] != ApiNotificationOpDirty {
+		t.Fatalf("expected one Dirty notification after changing the text but got %v", ops)
+	}
+	win.markTextAsUnchanged()
+	if len(ops) != 2 || ops[1] != ApiNotificationOpClean {
+		t.Fatalf("expected a Clean notification after the window was saved but got %v", ops)
+	}
+}
+
+func TestWindowBodyHighlightsThroughApi(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.Body.SetText([]byte("one two three\n"))
+	})
+	apiWin := api.Window{Id: win.Id}
+
+	err := anvil.AddWindowBodyHighlights(apiWin, []api.Highlight{{Start: 0, End: 3, Color: "red"}})
+	if err != nil {
+		t.Fatalf("adding highlights failed: %v", err)
+	}
+	err = anvil.AddWindowBodyHighlights(apiWin, []api.Highlight{{Start: 4, End: 7, Color: "#00ff00"}})
+	if err != nil {
+		t.Fatalf("adding highlights failed: %v", err)
+	}
+
+	hls, err := anvil.WindowBodyHighlights(apiWin)
+	if err != nil {
+		t.Fatalf("getting highlights failed: %v", err)
+	}
+	if len(hls) != 2 || hls[0] != (api.Highlight{Start: 0, End: 3, Color: "#ff0000"}) || hls[1] != (api.Highlight{Start: 4, End: 7, Color: "#00ff00"}) {
+		t.Fatalf("unexpected highlights %+v", hls)
+	}
+
+	err = anvil.SetWindowBodyHighlights(apiWin, []api.Highlight{{Start: 8, End: 13, Color: "blue"}})
+	if err != nil {
+		t.Fatalf("replacing highlights failed: %v", err)
+	}
+	hls, _ = anvil.WindowBodyHighlights(apiWin)
+	if len(hls) != 1 || hls[0].Start != 8 {
+		t.Fatalf("expected the highlights to be replaced but got %+v", hls)
+	}
+
+	err = anvil.AddWindowBodyHighlights(apiWin, []api.Highlight{{Start: 0, End: 1, Color: "nocolor"}})
+	if err == nil {
+		t.Fatalf("expected an invalid color to be refused")
+	}
+
+	err = anvil.SetWindowBodyHighlights(apiWin, []api.Highlight{{Start: 0, End: 3, Color: "red"}, {Start: 10, End: 100, Color: "red"}})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("expected a highlight outside the body to be refused with 400 but got %v", err)
+	}
+	hls, _ = anvil.WindowBodyHighlights(apiWin)
+	if len(hls) != 1 || hls[0].Start != 8 {
+		t.Fatalf("expected the highlights to be left as they were but got %+v", hls)
+	}
+}
+
+func TestNewWindowWithPathThroughApi(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+	onMainGoroutine(func() {
+		editor.NewColDontPosition()
+	})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "existing.txt")
+	err := os.WriteFile(path, []byte("contents\n"), 0644)
+	if err != nil {
+		t.Fatalf("writing file failed: %v", err)
+	}
+
+	apiWin, err := anvil.NewWindowWithPath(path, 1)
+	if err != nil {
+		t.Fatalf("creating window failed: %v", err)
+	}
+	if apiWin.GlobalPath != path {
+		t.Fatalf("expected the window path to be %s but it is %s", path, apiWin.GlobalPath)
+	}
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.FindWindowForId(apiWin.Id)
+	})
+	if win == nil || win.col != editor.Cols[1] {
+		t.Fatalf("expected the window to be created in the second column")
+	}
+
+	missing := filepath.Join(dir, "missing.txt")
+	apiWin, err = anvil.NewWindowWithPath(missing, -1)
+	if err != nil {
+		t.Fatalf("creating window for a missing file failed: %v", err)
+	}
+	if apiWin.GlobalPath != missing {
+		t.Fatalf("expected the window path to be %s but it is %s", missing, apiWin.GlobalPath)
+	}
+
+	_, err = anvil.NewWindowWithPath(path, 5)
+	if err == nil {
+		t.Fatalf("expected creating a window in a column that doesn't exist to fail")
+	}
+
+	// A bare POST still creates an empty window.
+	apiWin, err = anvil.NewWindow()
+	if err != nil || apiWin.Id == 0 || apiWin.GlobalPath != "" {
+		t.Fatalf("expected an empty window to be created but got %+v (error %v)", apiWin, err)
+	}
+}
+
+func TestApiSessionsListedInCreationOrder(t *testing.T) {
+	store := NewApiSessionStore(10)
+	now := time.Now()
+	for i, id := range []string{"c", "a", "b"} {
+		store.Add(&ApiSession{id: ApiSessionId(id), created: now.Add(time.Duration(i) * time.Second)})
+	}
+
+	var ids []ApiSessionId
+	for _, s := range store.All() {
+		ids = append(ids, s.id)
+	}
+
+	expected := []ApiSessionId{"c", "a", "b"}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected %v but got %v", expected, ids)
+	}
+}
+
+func TestWindowBodyCursorsByLineThroughApi(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.Body.SetText([]byte("one\ntwö\nthree\n"))
+	})
+	awin := api.Window{Id: win.Id}
+
+	err := anvil.SetCursorsLineCol(awin, []api.LineCol{{Line: 3, Col: 2}, {Line: 2, Col: 3}})
+	if err != nil {
+		t.Fatalf("setting the cursors failed: %v", err)
+	}
+
+	cursors, err := anvil.WindowBodyCursorsLineCol(awin)
+	if err != nil {
+		t.Fatalf("getting the cursors failed: %v", err)
+	}
+	expected := []api.Cursor{{Offset: 9, Line: 3, Col: 2}, {Offset: 6, Line: 2, Col: 3}}
+	if !reflect.DeepEqual(cursors, expected) {
+		t.Fatalf("expected the cursors %v but got %v", expected, cursors)
+	}
+
+	_, err = anvil.Put(fmt.Sprintf("/wins/%d/body/cursors?addr=1.2&addr=2", win.Id), nil)
+	if err != nil {
+		t.Fatalf("setting the cursors by address failed: %v", err)
+	}
+	offsets, err := anvil.WindowBodyCursors(awin)
+	if err != nil || !reflect.DeepEqual(offsets, []int{1, 4}) {
+		t.Fatalf("expected the cursors at 1 and 4 but got %v (%v)", offsets, err)
+	}
+}
+
+func TestWindowBodyRangeThroughApi(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.Body.SetText([]byte("héllo world\n"))
+		win.Body.SetCursorIndices([]int{12})
+	})
+	awin := api.Window{Id: win.Id}
+
+	body, err := anvil.WindowBodyRange(awin, 1, 5)
+	if err != nil || string(body) != "éllo" {
+		t.Fatalf("expected the range to be %q but got %q (%v)", "éllo", body, err)
+	}
+
+	rsp, err := anvil.Get(fmt.Sprintf("/wins/%d/body?start=6", win.Id))
+	if err != nil {
+		t.Fatalf("getting the end of the body failed: %v", err)
+	}
+	body, _ = io.ReadAll(rsp.Body)
+	if string(body) != "world\n" {
+		t.Fatalf("expected the end of the body to be %q but got %q", "world\n", body)
+	}
+
+	err = anvil.SetWindowBodyRange(awin, 6, 11, "there!")
+	if err != nil {
+		t.Fatalf("setting the range failed: %v", err)
+	}
+	var text string
+	var cursors []int
+	onMainGoroutine(func() {
+		text = win.Body.String()
+		cursors = append(cursors, win.Body.CursorIndices...)
+	})
+	if text != "héllo there!\n" || !reflect.DeepEqual(cursors, []int{13}) {
+		t.Fatalf("expected the range to be replaced and the cursor shifted but the body is %q with cursors %v", text, cursors)
+	}
+
+	onMainGoroutine(func() {
+		win.Body.applyUndoOrRedo(win.Body.text.Undo, -1)
+		text = win.Body.String()
+	})
+	if text != "héllo world\n" {
+		t.Fatalf("expected undo to restore the range in one step but the body is %q", text)
+	}
+
+	for _, q := range []string{"start=5&end=2", "start=0&end=13", "start=x", "end=-1"} {
+		if _, err := anvil.Get(fmt.Sprintf("/wins/%d/body?%s", win.Id, q)); err == nil {
+			t.Fatalf("expected getting the range %s to fail", q)
+		}
+		if _, err := anvil.Put(fmt.Sprintf("/wins/%d/body?%s", win.Id, q), strings.NewReader("x")); err == nil {
+			t.Fatalf("expected setting the range %s to fail", q)
+		}
+	}
+	onMainGoroutine(func() { text = win.Body.String() })
+	if text != "héllo world\n" {
+		t.Fatalf("expected the invalid ranges to leave the body unchanged but it is %q", text)
+	}
+}
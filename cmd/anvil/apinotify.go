@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxApiWebsockQueueLen is the most notifications waiting to be written to a websocket.
+	// Notifications added when the queue is full are dropped.
+	maxApiWebsockQueueLen = 1000
+	// apiWebsockMaxTimeouts is the number of writes in a row to a websocket that may time
+	// out before the websocket is closed and its session removed.
+	apiWebsockMaxTimeouts = 3
+)
+
+// apiWebsockWriteTimeout is how long writing one notification to a websocket may take.
+var apiWebsockWriteTimeout = 5 * time.Second
+
+// apiNotificationWriter writes notifications to a client of the API.
+type apiNotificationWriter interface {
+	writeNotification(n ApiNotification, deadline time.Time) error
+	close() error
+}
+
+// apiNotificationQueue delivers notifications to a session that has a websocket. Notifications
+// are added from the main goroutine, which must never wait for a client, so they are only
+// queued there and a goroutine per session encodes them and writes them to the websocket.
+type apiNotificationQueue struct {
+	writer  apiNotificationWriter
+	lock    sync.Mutex
+	pending []ApiNotification
+	// finished is set once run has returned, after which notifications are dropped.
+	finished bool
+	wake     chan struct{}
+	done     chan struct{}
+	stopped  sync.Once
+	dropped  atomic.Uint64
+	sent     atomic.Uint64
+}
+
+func newApiNotificationQueue(writer apiNotificationWriter) *apiNotificationQueue {
+	return &apiNotificationQueue{
+		writer: writer,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// add queues n to be written. It doesn't block.
+func (q *apiNotificationQueue) add(n ApiNotification) {
+	q.lock.Lock()
+	if q.finished || len(q.pending) >= maxApiWebsockQueueLen {
+		q.lock.Unlock()
+		q.dropped.Add(1)
+		return
+	}
+	q.pending = append(q.pending, n)
+	q.lock.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *apiNotificationQueue) take() []ApiNotification {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	p := q.pending
+	q.pending = nil
+	return p
+}
+
+func (q *apiNotificationQueue) finish() {
+	q.lock.Lock()
+	q.finished = true
+	q.dropped.Add(uint64(len(q.pending)))
+	q.pending = nil
+	q.lock.Unlock()
+
+	q.writer.close()
+}
+
+// Queued returns the number of notifications waiting to be written.
+func (q *apiNotificationQueue) Queued() int {
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	return len(q.pending)
+}
+
+// Dropped returns the number of notifications that were not written because the queue was
+// full or the write timed out.
+func (q *apiNotificationQueue) Dropped() uint64 {
+	return q.dropped.Load()
+}
+
+func (q *apiNotificationQueue) Sent() uint64 {
+	return q.sent.Load()
+}
+
+// stop makes run return and close the writer.
+func (q *apiNotificationQueue) stop() {
+	q.stopped.Do(func() { close(q.done) })
+}
+
+// run writes the queued notifications until stop is called or writing fails. If writing fails
+// failed is called.
+func (q *apiNotificationQueue) run(failed func(err error)) {
+	defer q.finish()
+
+	timeouts := 0
+	for {
+		select {
+		case <-q.wake:
+		case <-q.done:
+			return
+		}
+
+		notifs := q.take()
+		for i, n := range notifs {
+			err := q.writer.writeNotification(n, time.Now().Add(apiWebsockWriteTimeout))
+			if err == nil {
+				q.sent.Add(1)
+				timeouts = 0
+				continue
+			}
+
+			q.dropped.Add(1)
+			if errors.Is(err, os.ErrDeadlineExceeded) {
+				timeouts++
+				if timeouts < apiWebsockMaxTimeouts {
+					continue
+				}
+			}
+
+			q.dropped.Add(uint64(len(notifs) - i - 1))
+			failed(err)
+			return
+		}
+	}
+}
+
+type apiSessionWebsockCtx struct {
+	websock     *websocket.Conn
+	apiEncoding apiEncoding
+	// lock serializes the writes to the websocket, since notifications and the responses to
+	// requests are written from different goroutines.
+	lock *sync.Mutex
+}
+
+func (c apiSessionWebsockCtx) writeNotification(n ApiNotification, deadline time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	err := c.websock.SetWriteDeadline(deadline)
+	if err != nil {
+		return err
+	}
+
+	w, err := c.websock.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+
+	enc, flush := getEncoder(w, c.apiEncoding)
+	err = enc.Encode(n)
+	flush()
+	if err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// writeResponse writes the response to a request made over the websocket. Responses are always
+// encoded as JSON.
+func (c apiSessionWebsockCtx) writeResponse(r apiWebsockResponse, deadline time.Time) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	err := c.websock.SetWriteDeadline(deadline)
+	if err != nil {
+		return err
+	}
+	return c.websock.WriteJSON(r)
+}
+
+func (c apiSessionWebsockCtx) close() error {
+	return c.websock.Close()
+}
+
+// startApiNotificationQueue starts writing the notifications for sess to writer. If writing
+// persistently fails the session is removed.
+func startApiNotificationQueue(sess *ApiSession, writer apiNotificationWriter) {
+	if sess.websock != nil {
+		sess.websock.stop()
+	}
+	q := newApiNotificationQueue(writer)
+	sess.websock = q
+	id := sess.Id()
+	go q.run(func(err error) {
+		log(LogCatgAPI, "apiNotificationQueue: writing to the websocket of session %s failed: %v. Removing the session\n", id, err)
+		deleteApiSession(id)
+	})
+}
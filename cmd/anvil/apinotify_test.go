@@ -0,0 +1,127 @@
+package main
+
+import (
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeNotificationWriter is a client that takes delay to receive each notification.
+type fakeNotificationWriter struct {
+	delay    time.Duration
+	lock     sync.Mutex
+	received []time.Time
+	closed   bool
+}
+
+func (f *fakeNotificationWriter) writeNotification(n ApiNotification, deadline time.Time) error {
+	if d := time.Until(deadline); d < f.delay {
+		time.Sleep(d)
+		return os.ErrDeadlineExceeded
+	}
+	time.Sleep(f.delay)
+
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.received = append(f.received, time.Now())
+	return nil
+}
+
+func (f *fakeNotificationWriter) close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.closed = true
+	return nil
+}
+
+func (f *fakeNotificationWriter) state() (received []time.Time, closed bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return append([]time.Time(nil), f.received...), f.closed
+}
+
+func TestSlowApiClientDoesNotDelayOthers(t *testing.T) {
+	oldTimeout := apiWebsockWriteTimeout
+	apiWebsockWriteTimeout = 20 * time.Millisecond
+	defer func() { apiWebsockWriteTimeout = oldTimeout }()
+
+	slow := &fakeNotificationWriter{delay: time.Second}
+	fast := []*fakeNotificationWriter{{}, {}}
+
+	newSession := func(w apiNotificationWriter) *ApiSession {
+		sess, err := createApiSession("test")
+		if err != nil {
+			t.Fatalf("creating API session failed: %v", err)
+		}
+		t.Cleanup(func() { deleteApiSession(sess.id) })
+		startApiNotificationQueue(sess, w)
+		return sess
+	}
+
+	slowSess := newSession(slow)
+	for _, f := range fast {
+		newSession(f)
+	}
+
+	const count = 500
+	start := time.Now()
+	var longest time.Duration
+	for i := 0; i < count; i++ {
+		s := time.Now()
+		addApiNotificationToAllSessions(ApiNotification{WinId: 1, Op: ApiNotificationOpInsert, Offset: i, Len: 1})
+		if d := time.Since(s); d > longest {
+			longest = d
+		}
+	}
+
+	// Adding notifications is done on the main goroutine between frames, so it must never wait for clients.
+	if longest > 10*time.Millisecond {
+		t.Fatalf("adding a notification took %v", longest)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for _, f := range fast {
+		for {
+			received, _ := f.state()
+			if len(received) == count {
+				if last := received[len(received)-1].Sub(start); last > time.Second {
+					t.Fatalf("the fast client received the last notification after %v", last)
+				}
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("the fast client received %d of %d notifications", len(received), count)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	for {
+		_, closed := slow.state()
+		_, found := findApiSession(slowSess.id)
+		if closed && !found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the slow client to be closed and its session removed but closed=%v found=%v", closed, found)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if slowSess.websock.Dropped() != count || slowSess.websock.Sent() != 0 {
+		t.Fatalf("expected all %d notifications to the slow client to be dropped but %d were dropped and %d sent",
+			count, slowSess.websock.Dropped(), slowSess.websock.Sent())
+	}
+}
+
+func TestApiNotificationQueueDropsWhenFull(t *testing.T) {
+	q := newApiNotificationQueue(&fakeNotificationWriter{})
+	for i := 0; i < maxApiWebsockQueueLen+10; i++ {
+		q.add(ApiNotification{Offset: i})
+	}
+
+	if q.Queued() != maxApiWebsockQueueLen || q.Dropped() != 10 {
+		t.Fatalf("expected %d queued and 10 dropped but got %d and %d", maxApiWebsockQueueLen, q.Queued(), q.Dropped())
+	}
+}
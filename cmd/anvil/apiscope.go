@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// The scope of an API session limits what the program it was created for may do with the API.
+// It is given by ANVIL_API_SCOPE in the environment of the command, as a comma-separated list
+// of:
+//
+//	full       the session may do everything. This is the default.
+//	readonly   the session may only read: mutating requests fail with 403 Forbidden.
+//	win:<id>   the session may only use the window with the id, and only receives the
+//	           notifications for that window. "win" alone means the window the command
+//	           was run from.
+const (
+	apiScopeEnvVar    = "ANVIL_API_SCOPE"
+	apiScopeFull      = "full"
+	apiScopeReadonly  = "readonly"
+	apiScopeWinPrefix = "win:"
+)
+
+// parseApiScope parses the value of ANVIL_API_SCOPE into the scopes of a session. winId is the
+// id of the window the command was run from, or -1 if it wasn't run from a window.
+func parseApiScope(s string, winId int) (scopes []string, err error) {
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		switch {
+		case f == "" || f == apiScopeFull:
+		case f == apiScopeReadonly:
+			scopes = append(scopes, apiScopeReadonly)
+		case f == "win":
+			if winId < 0 {
+				return nil, fmt.Errorf("the scope 'win' can only be used for commands run from a window")
+			}
+			scopes = append(scopes, fmt.Sprintf("%s%d", apiScopeWinPrefix, winId))
+		case strings.HasPrefix(f, apiScopeWinPrefix):
+			if _, err := strconv.Atoi(f[len(apiScopeWinPrefix):]); err != nil {
+				return nil, fmt.Errorf("invalid window id in scope '%s'", f)
+			}
+			scopes = append(scopes, f)
+		default:
+			return nil, fmt.Errorf("unknown scope '%s'", f)
+		}
+	}
+	return
+}
+
+// apiScopeFromEnv returns the value of ANVIL_API_SCOPE that a command will see, given its
+// environment and the command itself, which may start with an assignment to it.
+func apiScopeFromEnv(env []string, cmd string) (scope string, ok bool) {
+	prefix := apiScopeEnvVar + "="
+	for _, e := range env {
+		if strings.HasPrefix(e, prefix) {
+			scope, ok = e[len(prefix):], true
+		}
+	}
+	if strings.HasPrefix(cmd, prefix) {
+		scope, _, _ = strings.Cut(cmd[len(prefix):], " ")
+		ok = true
+	}
+	return
+}
+
+// setApiScope adds the scope from ANVIL_API_SCOPE to the scopes of the session created for the
+// command. A scope that can't be parsed makes the session read-only.
+func (c CommandExecutor) setApiScope(ctx *CmdContext, ex *execCtx) {
+	s, ok := apiScopeFromEnv(ex.fullEnv(), ex.cmd)
+	if !ok {
+		return
+	}
+
+	winId := -1
+	if w, ok := c.source.(*Window); ok {
+		winId = w.Id
+	}
+
+	scopes, err := parseApiScope(s, winId)
+	if err != nil {
+		editor.AppendError(ctx.Dir, fmt.Sprintf("%s: %v. The API session for the command is read-only", apiScopeEnvVar, err))
+		scopes = []string{apiScopeReadonly}
+	}
+	ex.apiScopes = append(ex.apiScopes, scopes...)
+}
+
+func (s *ApiSession) isReadonly() bool {
+	return s.hasScope(apiScopeReadonly)
+}
+
+// scopedWinId returns the id of the window the session is limited to, if it is.
+func (s *ApiSession) scopedWinId() (id int, ok bool) {
+	for _, sc := range s.scopes {
+		if strings.HasPrefix(sc, apiScopeWinPrefix) {
+			id, err := strconv.Atoi(sc[len(apiScopeWinPrefix):])
+			return id, err == nil
+		}
+	}
+	return
+}
+
+// mayUseWindow returns true if the session may use the window with the id. The id is -1 for
+// the editor tag.
+func (s *ApiSession) mayUseWindow(winId int) bool {
+	id, ok := s.scopedWinId()
+	return !ok || id == winId
+}
+
+// scopeString describes the scope of the session for About.
+func (s *ApiSession) scopeString() string {
+	var scopes []string
+	for _, sc := range s.scopes {
+		if sc == apiScopeReadonly || strings.HasPrefix(sc, apiScopeWinPrefix) {
+			scopes = append(scopes, sc)
+		}
+	}
+	if len(scopes) == 0 {
+		return apiScopeFull
+	}
+	return strings.Join(scopes, ",")
+}
+
+// authorize checks that the scope of the session allows the request. The operations in the
+// body of requests to /execute and /batch are checked when they are performed.
+func (a ApiHandler) authorize(sess *ApiSession, req *http.Request) (status int, msg string, ok bool) {
+	path := req.URL.Path
+	reads := req.Method == http.MethodGet || req.Method == http.MethodHead
+
+	if sess.isReadonly() && !reads && path != "/batch" {
+		return http.StatusForbidden, fmt.Sprintf("The API session is read-only. %s %s is not allowed", req.Method, path), false
+	}
+
+	id, scoped := sess.scopedWinId()
+	if !scoped {
+		return 0, "", true
+	}
+
+	switch path {
+	case "/notifs", "/ws", "/cmds", "/execute", "/batch":
+		return 0, "", true
+	}
+	if strings.HasPrefix(path, "/wins/") {
+		if winId, _ := a.parseInitialNumber(path[6:]); winId == id {
+			return 0, "", true
+		}
+	}
+	return http.StatusForbidden, fmt.Sprintf("The API session may only use window %d. %s %s is not allowed", id, req.Method, path), false
+}
+
+// batchOpAllowed checks that the scope of the session allows the batch operation.
+func (s *ApiSession) batchOpAllowed(op apiBatchOp) *apiBatchError {
+	if s.isReadonly() {
+		switch op.Op {
+		case apiBatchOpGetBody, apiBatchOpGetTag, apiBatchOpGetCursors:
+		default:
+			return &apiBatchError{http.StatusForbidden, fmt.Sprintf("The API session is read-only. The operation '%s' is not allowed", op.Op)}
+		}
+	}
+	if !s.mayUseWindow(op.WinId) {
+		id, _ := s.scopedWinId()
+		return &apiBatchError{http.StatusForbidden, fmt.Sprintf("The API session may only use window %d", id)}
+	}
+	return nil
+}
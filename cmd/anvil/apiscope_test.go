@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseApiScope(t *testing.T) {
+	tests := []struct {
+		scope    string
+		expected []string
+		err      bool
+	}{
+		{"full", nil, false},
+		{"", nil, false},
+		{"readonly", []string{"readonly"}, false},
+		{"win:12", []string{"win:12"}, false},
+		{"win, readonly", []string{"win:3", "readonly"}, false},
+		{"win:x", nil, true},
+		{"everything", nil, true},
+	}
+
+	for _, tc := range tests {
+		scopes, err := parseApiScope(tc.scope, 3)
+		if (err != nil) != tc.err || strings.Join(scopes, ",") != strings.Join(tc.expected, ",") {
+			t.Fatalf("for %q expected %v (error: %v) but got %v, %v", tc.scope, tc.expected, tc.err, scopes, err)
+		}
+	}
+
+	if _, err := parseApiScope("win", -1); err == nil {
+		t.Fatalf("expected the scope 'win' to fail for a command not run from a window")
+	}
+
+	scope, ok := apiScopeFromEnv([]string{"ANVIL_API_SCOPE=full", "HOME=/h"}, "ANVIL_API_SCOPE=readonly ./helper")
+	if !ok || scope != "readonly" {
+		t.Fatalf("expected the assignment in the command to win but got %q, %v", scope, ok)
+	}
+	if _, ok := apiScopeFromEnv([]string{"HOME=/h"}, "./helper"); ok {
+		t.Fatalf("expected no scope")
+	}
+}
+
+func TestApiScopeEnforced(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var own, other *Window
+	onMainGoroutine(func() {
+		own = editor.NewWindow(nil)
+		own.Body.SetText([]byte("own\n"))
+		other = editor.NewWindow(nil)
+		other.Body.SetText([]byte("other\n"))
+	})
+
+	readonly, err := createApiSession("readonly", apiScopeReadonly)
+	if err != nil {
+		t.Fatalf("creating API session failed: %v", err)
+	}
+	defer deleteApiSession(readonly.id)
+	scoped, err := createApiSession("scoped", fmt.Sprintf("win:%d", own.Id))
+	if err != nil {
+		t.Fatalf("creating API session failed: %v", err)
+	}
+	defer deleteApiSession(scoped.id)
+
+	do := func(sess *ApiSession, method, path, body string) int {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Anvil-Sess", string(sess.id))
+		rsp := httptest.NewRecorder()
+		ApiHandler{}.ServeHTTP(rsp, req)
+		return rsp.Code
+	}
+
+	ownBody := fmt.Sprintf("/wins/%d/body", own.Id)
+	otherBody := fmt.Sprintf("/wins/%d/body", other.Id)
+	tests := []struct {
+		name     string
+		sess     *ApiSession
+		method   string
+		path     string
+		body     string
+		expected int
+	}{
+		{"readonly get", readonly, http.MethodGet, otherBody, "", http.StatusOK},
+		{"readonly put", readonly, http.MethodPut, otherBody, "x", http.StatusForbidden},
+		{"readonly execute", readonly, http.MethodPost, "/execute", `{"WinId": -1, "Cmd": "Newcol"}`, http.StatusForbidden},
+		{"readonly batch read", readonly, http.MethodPost, "/batch", fmt.Sprintf(`[{"Op": "getbody", "WinId": %d}]`, other.Id), http.StatusOK},
+		{"readonly batch write", readonly, http.MethodPost, "/batch", fmt.Sprintf(`[{"Op": "putbody", "WinId": %d}]`, other.Id), http.StatusForbidden},
+		{"scoped own", scoped, http.MethodPut, ownBody, "changed\n", http.StatusOK},
+		{"scoped other", scoped, http.MethodGet, otherBody, "", http.StatusForbidden},
+		{"scoped list", scoped, http.MethodGet, "/wins", "", http.StatusForbidden},
+		{"scoped execute other", scoped, http.MethodPost, "/execute", fmt.Sprintf(`{"WinId": %d, "Cmd": "Del"}`, other.Id), http.StatusForbidden},
+		{"scoped batch other", scoped, http.MethodPost, "/batch", fmt.Sprintf(`[{"Op": "gettag", "WinId": %d}]`, other.Id), http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		if code := do(tc.sess, tc.method, tc.path, tc.body); code != tc.expected {
+			t.Fatalf("%s: expected status %d but got %d", tc.name, tc.expected, code)
+		}
+	}
+
+	waitForBody(t, own, "changed\n")
+	waitForBody(t, other, "other\n")
+
+	// Only the notifications for its own window reach the scoped session.
+	onMainGoroutine(func() {
+		other.Body.insertToPieceTable(0, "x")
+		own.Body.insertToPieceTable(0, "y")
+	})
+	var ids []int
+	onMainGoroutine(func() {
+		for _, n := range apiGetAndClearNotifications(scoped.id) {
+			ids = append(ids, n.WinId)
+		}
+	})
+	for _, id := range ids {
+		if id != own.Id {
+			t.Fatalf("expected only notifications for window %d but got %v", own.Id, ids)
+		}
+	}
+	if len(ids) == 0 {
+		t.Fatalf("expected notifications for window %d", own.Id)
+	}
+	if s := scoped.scopeString(); s != fmt.Sprintf("win:%d", own.Id) {
+		t.Fatalf("expected the scope to be shown as win:%d but it is %q", own.Id, s)
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// apiSelectionNotifyDelay is how long after the primary selection of a window body changes
+// that API clients are notified. The changes made while dragging a selection within this time
+// are sent as one notification.
+const apiSelectionNotifyDelay = 100 * time.Millisecond
+
+// notifyApiSelectionChanges schedules a selection changed notification for each window whose
+// primary selection changed since it was last checked. It is called on the main goroutine after
+// each frame and work item, and does nothing unless an API client has a websocket open.
+func (e *Editor) notifyApiSelectionChanges() {
+	if !apiSessions.HasWebsocket() {
+		return
+	}
+
+	for _, w := range e.Windows() {
+		sel := w.primarySelectionRange()
+		if sel == w.apiSeenSelection {
+			continue
+		}
+		w.apiSeenSelection = sel
+		w.Body.schedule(fmt.Sprintf("api-selection-%d", w.Id), apiSelectionNotifyDelay, w.notifyApiSelectionChanged)
+	}
+}
+
+// primarySelectionRange returns the start and end of the primary selection of the body. It is
+// empty if there is none.
+func (w *Window) primarySelectionRange() textRange {
+	if w.Body.primarySel == nil {
+		return textRange{}
+	}
+	return w.Body.primarySel.textRange
+}
+
+func (w *Window) notifyApiSelectionChanged() {
+	sel := w.primarySelectionRange()
+	if sel == w.apiNotifiedSelection {
+		return
+	}
+	w.apiNotifiedSelection = sel
+
+	addApiNotificationToAllSessions(ApiNotification{
+		WinId:      w.Id,
+		Op:         ApiNotificationOpSelectionChanged,
+		Selections: w.apiSelections(),
+	})
+}
+
+// apiSelections returns the selections in the window body, the primary selection first and the
+// others in the order they appear.
+func (w *Window) apiSelections() []apiSelection {
+	var sels []apiSelection
+	add := func(s *selection) {
+		sels = append(sels, apiSelection{s.start, s.end, s.end - s.start})
+	}
+
+	if w.Body.primarySel != nil {
+		add(w.Body.primarySel)
+	}
+	for _, s := range w.Body.selectionsInDisplayOrder() {
+		if s != w.Body.primarySel {
+			add(s)
+		}
+	}
+	return sels
+}
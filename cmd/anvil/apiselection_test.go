@@ -0,0 +1,69 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSelectionChangesAreCoalescedIntoOneApiNotification(t *testing.T) {
+	startHeadlessEditor(t)
+
+	sess, err := createApiSession("test")
+	if err != nil {
+		t.Fatalf("creating API session failed: %v", err)
+	}
+	t.Cleanup(func() { deleteApiSession(sess.id) })
+
+	notifs := make(chan ApiNotification, 10)
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.Body.SetText([]byte("one two three four"))
+		observeApiNotifications(func(n ApiNotification) {
+			if n.Op == ApiNotificationOpSelectionChanged {
+				notifs <- n
+			}
+		})
+	})
+	t.Cleanup(func() { apiNotificationObservers = map[int]func(ApiNotification){} })
+
+	// Without a websocket open no one can receive the notifications, so none are sent.
+	onMainGoroutine(func() {
+		win.Body.setPrimarySelection(0, 3)
+	})
+	select {
+	case n := <-notifs:
+		t.Fatalf("expected no notification without a websocket but got %+v", n)
+	case <-time.After(3 * apiSelectionNotifyDelay):
+	}
+
+	startApiNotificationQueue(sess, &fakeNotificationWriter{})
+
+	// Changes made quickly, like dragging out a selection, are sent as one notification.
+	for end := 5; end <= 7; end++ {
+		end := end
+		onMainGoroutine(func() {
+			win.Body.setPrimarySelection(4, end)
+		})
+	}
+	onMainGoroutine(func() {
+		win.Body.addSelection(NewSelectionPtr(14, 18, Right))
+	})
+
+	select {
+	case n := <-notifs:
+		expected := []apiSelection{{4, 7, 3}, {14, 18, 4}}
+		if n.WinId != win.Id || !reflect.DeepEqual(n.Selections, expected) {
+			t.Fatalf("expected selections %v in window %d but got %v in window %d", expected, win.Id, n.Selections, n.WinId)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("no selection changed notification was sent")
+	}
+
+	select {
+	case n := <-notifs:
+		t.Fatalf("expected one notification but also got %+v", n)
+	case <-time.After(3 * apiSelectionNotifyDelay):
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// This file implements GET and PUT /tag for the editor tag, GET /cols which lists the columns,
+// and GET and PUT /cols/{n}/tag for the tag of the column with index n. Unlike window tags,
+// where Settag only changes the user area, the editor and column tags are replaced entirely.
+
+type apiColumns []apiColumn
+
+// apiColumn describes a column. The columns are listed from left to right, and the index of a
+// column in the list is the n in /cols/{n}/tag.
+type apiColumn struct {
+	Id      int
+	Tag     string
+	Windows []int
+}
+
+func (a ApiHandler) serveEditorTag(rsp http.ResponseWriter, req *http.Request) {
+	a.serveTag(rsp, req, func() *Tag {
+		return &editor.Tag
+	})
+}
+
+func (a ApiHandler) serveColumns(rsp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		msg := fmt.Sprintf("Method %s is not supported for %s", req.Method, req.URL.Path)
+		http.Error(rsp, msg, http.StatusBadRequest)
+		return
+	}
+
+	ch := make(chan apiColumns)
+	editor.WorkChan() <- basicWork{func() {
+		ch <- a.buildColumns()
+	}}
+	cols := <-ch
+
+	contentType, enc, flush := a.getEncoderForHTTPResponse(rsp, req)
+	rsp.Header().Add("Content-Type", string(contentType))
+	enc.Encode(cols)
+	flush()
+}
+
+func (a ApiHandler) buildColumns() apiColumns {
+	cols := apiColumns{}
+	for _, c := range editor.Cols {
+		col := apiColumn{Id: c.Id, Tag: c.Tag.String(), Windows: []int{}}
+		for _, w := range c.Windows {
+			col.Windows = append(col.Windows, w.Id)
+		}
+		cols = append(cols, col)
+	}
+	return cols
+}
+
+func (a ApiHandler) serveColumnTag(colIndex int, rsp http.ResponseWriter, req *http.Request) {
+	a.serveTag(rsp, req, func() *Tag {
+		if colIndex < 0 || colIndex >= len(editor.Cols) {
+			return nil
+		}
+		return &editor.Cols[colIndex].Tag
+	})
+}
+
+// serveTag gets or replaces the text of the tag returned by find, which is called on the main
+// goroutine. find returns nil if there is no such tag.
+func (a ApiHandler) serveTag(rsp http.ResponseWriter, req *http.Request, find func() *Tag) {
+	var data []byte
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var err error
+		data, err = ioutil.ReadAll(req.Body)
+		if err != nil {
+			msg := fmt.Sprintf("Reading request body failed with error %v", err)
+			http.Error(rsp, msg, http.StatusInternalServerError)
+			return
+		}
+	default:
+		msg := fmt.Sprintf("Method %s is not supported for %s", req.Method, req.URL.Path)
+		http.Error(rsp, msg, http.StatusBadRequest)
+		return
+	}
+
+	ch := make(chan []byte)
+	editor.WorkChan() <- basicWork{func() {
+		tag := find()
+		if tag == nil {
+			ch <- nil
+			return
+		}
+		if req.Method == http.MethodPut {
+			log(LogCatgAPI, "APIHandler: setting %s tag to '%s'\n", tag.label, data)
+			tag.SetTextString(string(data))
+		}
+		ch <- tag.Bytes()
+	}}
+	content := <-ch
+
+	if content == nil {
+		msg := fmt.Sprintf("No tag for %s", req.URL.Path)
+		http.Error(rsp, msg, http.StatusNotFound)
+		return
+	}
+
+	if req.Method == http.MethodGet {
+		rsp.Header().Add("Content-Type", encodingTextPlain)
+		rsp.Write(content)
+	}
+}
+
+// notifyApiTagChanged notifies API clients that the editor tag changed.
+func (e *Editor) notifyApiTagChanged(c *TextChange) {
+	addApiNotificationToAllSessions(newTagChangedApiNotification(ApiNotificationOpEditorTagChanged, 0, c))
+}
+
+// notifyApiTagChanged notifies API clients that the tag of the column changed. The tag of a new
+// column is set before it is added to the editor, and no notification is sent for that.
+func (c *Col) notifyApiTagChanged(ch *TextChange) {
+	if c.ed == nil {
+		return
+	}
+	for i, col := range c.ed.Cols {
+		if col == c {
+			addApiNotificationToAllSessions(newTagChangedApiNotification(ApiNotificationOpColTagChanged, i, ch))
+			return
+		}
+	}
+}
+
+func newTagChangedApiNotification(op ApiNotificationOp, col int, c *TextChange) ApiNotification {
+	n := ApiNotification{
+		Op:     op,
+		Col:    col,
+		Offset: c.Offset,
+		Len:    c.Length,
+	}
+	if n.Len < 0 {
+		n.Len = -n.Len
+	}
+	return n
+}
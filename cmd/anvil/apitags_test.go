@@ -0,0 +1,122 @@
+package main
+
+import (
+	"testing"
+
+	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+func TestEditorAndColumnTagsThroughApi(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var notifs []ApiNotification
+	onMainGoroutine(func() {
+		editor.NewCol()
+		editor.Cols[1].NewWindow()
+		observeApiNotifications(func(n ApiNotification) {
+			if n.Op == ApiNotificationOpEditorTagChanged || n.Op == ApiNotificationOpColTagChanged {
+				notifs = append(notifs, n)
+			}
+		})
+	})
+	t.Cleanup(func() { apiNotificationObservers = map[int]func(ApiNotification){} })
+
+	err := anvil.SetEditorTag("Newcol Kill Putall | Mine")
+	if err != nil {
+		t.Fatalf("setting the editor tag failed: %v", err)
+	}
+	tag, err := anvil.EditorTag()
+	if err != nil || tag != "Newcol Kill Putall | Mine" {
+		t.Fatalf("expected the editor tag to be replaced but got %q (%v)", tag, err)
+	}
+
+	err = anvil.SetColumnTag(1, "Delcol Mine")
+	if err != nil {
+		t.Fatalf("setting the column tag failed: %v", err)
+	}
+	tag, err = anvil.ColumnTag(1)
+	if err != nil || tag != "Delcol Mine" {
+		t.Fatalf("expected the column tag to be replaced but got %q (%v)", tag, err)
+	}
+
+	cols, err := anvil.Columns()
+	if err != nil {
+		t.Fatalf("listing the columns failed: %v", err)
+	}
+	onMainGoroutine(func() {
+		if len(cols) != 2 || cols[1].Tag != "Delcol Mine" || cols[1].Id != editor.Cols[1].Id {
+			t.Fatalf("unexpected columns %#v", cols)
+		}
+		if len(cols[0].Windows) != 0 || len(cols[1].Windows) != 1 || cols[1].Windows[0] != editor.Cols[1].Windows[0].Id {
+			t.Fatalf("expected one window in the second column but got %#v", cols)
+		}
+	})
+
+	if _, err = anvil.ColumnTag(2); err == nil {
+		t.Fatalf("expected getting the tag of a missing column to fail")
+	}
+
+	onMainGoroutine(func() {
+		var editorChanged, colChanged bool
+		for _, n := range notifs {
+			editorChanged = editorChanged || n.Op == ApiNotificationOpEditorTagChanged
+			colChanged = colChanged || (n.Op == ApiNotificationOpColTagChanged && n.Col == 1)
+		}
+		if !editorChanged || !colChanged {
+			t.Fatalf("expected notifications that the editor and column tags changed but got %#v", notifs)
+		}
+	})
+}
+
+func TestSettingTheWholeWindowTagRenamesTheFile(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var win *Window
+	var opened []int
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.SetFilenameAndTag("/tmp/a.txt", typeFile)
+		editor.Marks.Set("m", "/tmp/a.txt", 3)
+		observeApiNotifications(func(n ApiNotification) {
+			if n.Op == ApiNotificationOpFileOpened {
+				opened = append(opened, n.WinId)
+			}
+		})
+	})
+	t.Cleanup(func() { apiNotificationObservers = map[int]func(ApiNotification){} })
+
+	err := anvil.SetWindowTag(api.Window{Id: win.Id}, "/tmp/b c.txt Del Snarf Mine | Extra")
+	if err != nil {
+		t.Fatalf("setting the window tag failed: %v", err)
+	}
+
+	onMainGoroutine(func() {
+		if win.file != "/tmp/b c.txt" || win.fileType != typeFile {
+			t.Fatalf("expected the window to hold /tmp/b c.txt but it holds %q", win.file)
+		}
+		if tag := win.Tag.String(); tag != "/tmp/b c.txt Del Snarf Mine | Extra" {
+			t.Fatalf("unexpected tag %q", tag)
+		}
+		if file, _, _ := editor.Marks.Seek("m"); file != "/tmp/b c.txt" {
+			t.Fatalf("expected the mark to move to the new file but it is in %q", file)
+		}
+		if len(opened) != 1 || opened[0] != win.Id {
+			t.Fatalf("expected one file opened notification for the window but got %v", opened)
+		}
+	})
+
+	err = anvil.SetWindowTag(api.Window{Id: win.Id}, "/tmp/bad\npath Del Snarf |")
+	if err == nil {
+		t.Fatalf("expected setting a tag whose path contains a newline to fail")
+	}
+
+	onMainGoroutine(func() {
+		NewCommandExecutor(win).CmdSettag(&CmdContext{RawCommand: "Settag -all '/tmp/dir/ Del Snarf Get | Mine'"})
+		if win.file != "/tmp/dir/" || win.fileType != typeDir {
+			t.Fatalf("expected Settag -all to make the window hold the directory /tmp/dir/ but it holds %q", win.file)
+		}
+		if win.customEdCommandsSet() {
+			t.Fatalf("expected the usual editor commands not to be kept as custom commands but got %q", win.customEdCommands)
+		}
+	})
+}
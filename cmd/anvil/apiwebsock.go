@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Clients can make the requests they would make over HTTP over the websocket of their session
+// instead, so that they only need one connection. A request is a text message holding an
+// apiWebsockRequest encoded as JSON, and the response is sent back as an apiWebsockResponse with
+// the id of the request, between the notifications. Each request is performed on its own
+// goroutine, so a slow request holds up neither the responses to the others nor the
+// notifications.
+
+// apiWebsockRequest is a request made over a websocket. Body is the body of the request as it
+// would be sent over HTTP, encoded as JSON where HTTP requests are.
+type apiWebsockRequest struct {
+	Id     int
+	Method string
+	Path   string
+	Body   string
+}
+
+// apiWebsockResponse is the response to an apiWebsockRequest. Message is always
+// WebsockMessageResponse, which tells it apart from the notifications.
+type apiWebsockResponse struct {
+	Message   WebsockMessageId
+	RequestId int
+	// Status is the HTTP status code of the response.
+	Status int
+	Body   string
+}
+
+// serveWebsocketRequests reads the requests the client of the session sends over the websocket
+// until the websocket is closed.
+func (a ApiHandler) serveWebsocketRequests(id ApiSessionId, ws apiSessionWebsockCtx) {
+	for {
+		typ, buf, err := ws.websock.ReadMessage()
+		if err != nil {
+			log(LogCatgAPI, "APIHandler.serveWebsocketRequests: reading from the websocket of session %s failed: %v\n", id, err)
+			return
+		}
+		if typ != websocket.TextMessage {
+			continue
+		}
+
+		go func() {
+			rsp := apiWebsockResponse{Message: WebsockMessageResponse}
+			var req apiWebsockRequest
+			if err := json.Unmarshal(buf, &req); err != nil {
+				rsp.Status = http.StatusBadRequest
+				rsp.Body = fmt.Sprintf("Decoding the request failed: %v", err)
+			} else {
+				var body []byte
+				rsp.RequestId = req.Id
+				rsp.Status, body = a.Do(id, req.Method, req.Path, []byte(req.Body))
+				rsp.Body = string(body)
+			}
+
+			if err := ws.writeResponse(rsp, time.Now().Add(apiWebsockWriteTimeout)); err != nil {
+				log(LogCatgAPI, "APIHandler.serveWebsocketRequests: writing the response to request %d of session %s failed: %v\n", rsp.RequestId, id, err)
+			}
+		}()
+	}
+}
+
+// Do performs a request for the session as ServeHTTP does, but without an HTTP connection, and
+// returns the status code and body of the response. The bodies are encoded as JSON.
+func (a ApiHandler) Do(id ApiSessionId, method, path string, body []byte) (status int, rspBody []byte) {
+	if path == "/ws" {
+		return http.StatusBadRequest, []byte("A websocket can only be opened over HTTP")
+	}
+
+	req, err := http.NewRequest(method, path, bytes.NewReader(body))
+	if err != nil {
+		return http.StatusBadRequest, []byte(fmt.Sprintf("Invalid request: %v", err))
+	}
+	req.Header.Set("Anvil-Sess", string(id))
+	req.Header.Set("Content-Type", string(encodingApplicationJson))
+	req.Header.Set("Accept", string(encodingApplicationJson))
+
+	var rsp apiResponseBuffer
+	a.ServeHTTP(&rsp, req)
+	if rsp.status == 0 {
+		rsp.status = http.StatusOK
+	}
+	return rsp.status, rsp.body.Bytes()
+}
+
+// apiResponseBuffer is an http.ResponseWriter that keeps the response in memory.
+type apiResponseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *apiResponseBuffer) Header() http.Header {
+	if b.header == nil {
+		b.header = http.Header{}
+	}
+	return b.header
+}
+
+func (b *apiResponseBuffer) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *apiResponseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+func TestApiRequestsOverWebsocket(t *testing.T) {
+	h := newTestHeadless()
+	path := filepath.Join(t.TempDir(), "a.txt")
+	win := h.OpenWindow(path, []byte("text\n"))
+
+	sess, err := createApiSession("test")
+	if err != nil {
+		t.Fatalf("creating API session failed: %v", err)
+	}
+	defer deleteApiSession(sess.id)
+
+	if status, _ := (ApiHandler{}).Do(sess.id, http.MethodGet, "/ws", nil); status != http.StatusBadRequest {
+		t.Fatalf("expected opening a websocket over a websocket to be refused but the status is %d", status)
+	}
+
+	server := httptest.NewServer(ApiHandler{})
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parsing the URL of the server failed: %v", err)
+	}
+	anvil := api.New(string(sess.id), u.Port())
+	anvil.SetTimeout(10 * time.Second)
+
+	notes := make(chan api.Notification, 100)
+	var bodies [4]string
+	var errs [4]error
+	var connErr, putErr error
+	put := false
+
+	h.Await(func() {
+		c, err := anvil.WebsockClient(api.WebsockHandlers{
+			Notification: func(n *api.Notification, err error) {
+				if err == nil {
+					notes <- *n
+				}
+			},
+		})
+		if err != nil {
+			connErr = err
+			return
+		}
+		defer c.Close()
+
+		var wg sync.WaitGroup
+		for i := range bodies {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				bodies[i], errs[i] = c.WindowBody(api.Window{Id: win.Id})
+			}(i)
+		}
+		wg.Wait()
+
+		putErr = c.ExecuteInWin(api.Window{Id: win.Id}, "Put", nil)
+		timeout := time.After(10 * time.Second)
+		for !put {
+			select {
+			case n := <-notes:
+				put = n.WinId == win.Id && n.Op == api.NotificationOpPut
+			case <-timeout:
+				return
+			}
+		}
+	})
+
+	if connErr != nil {
+		t.Fatalf("connecting the websocket failed: %v", connErr)
+	}
+	for i, b := range bodies {
+		if errs[i] != nil || b != "text\n" {
+			t.Fatalf("expected request %d to read the body but got %q, %v", i, b, errs[i])
+		}
+	}
+	if putErr != nil {
+		t.Fatalf("executing Put over the websocket failed: %v", putErr)
+	}
+	if !put {
+		t.Fatalf("expected a notification that the file was saved over the websocket")
+	}
+	if win.IsDirty() {
+		t.Fatalf("expected the window to be saved")
+	}
+}
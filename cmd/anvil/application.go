@@ -15,6 +15,10 @@ type Application struct {
 	metric         *unit.Metric
 	winIdGenerator IdGen
 	colIdGenerator IdGen
+	platformWin    platformWindow
+	// urgent is true when attention was requested and the window has not been focused since.
+	urgent      bool
+	cueCommands cueCommandLimiter
 }
 
 func (a *Application) SetWindow(appWindow *app.Window) {
@@ -47,6 +51,11 @@ func NewApplication() *Application {
 func (a *Application) WindowConfigChanged(cfg *app.Config) {
 	a.winConfig = &app.Config{}
 	*a.winConfig = *cfg
+	if cfg.Focused {
+		a.clearAttention()
+	} else if editor != nil {
+		editor.redactSensitiveWindows()
+	}
 }
 
 func (a *Application) SetWindowSize(sz image.Point) {
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+)
+
+// A file inside an archive is named by the path of the archive and the path of the file
+// inside it separated by archiveMemberSep, like /src/vendor.tar.gz!pkg/file.go. Windows for
+// these files are read-only, and the file is extracted again whenever the window is loaded.
+const archiveMemberSep = "!"
+
+var archiveExtensions = []string{".tar", ".tar.gz", ".tgz", ".zip"}
+
+// maxRemoteTarSize is the largest remote tar archive that files are extracted from. Unlike zip
+// archives, tar archives have no index, so they must be read from the start to find a file.
+const maxRemoteTarSize = 256 * 1024 * 1024
+
+// remoteArchiveBlockSize is how much of a remote archive is read at once.
+const remoteArchiveBlockSize = 256 * 1024
+
+// splitArchiveMemberPath splits path into the path of an archive and the path of a file inside
+// it. ok is false if path doesn't name a file inside an archive with a supported extension.
+func splitArchiveMemberPath(p string) (archive, member string, ok bool) {
+	for i := 0; i < len(p); i++ {
+		j := strings.Index(p[i:], archiveMemberSep)
+		if j < 0 {
+			return
+		}
+		i += j
+		if i+1 < len(p) && hasArchiveExtension(p[:i]) {
+			return p[:i], p[i+1:], true
+		}
+	}
+	return
+}
+
+func isArchiveMemberPath(p string) bool {
+	_, _, ok := splitArchiveMemberPath(p)
+	return ok
+}
+
+func hasArchiveExtension(p string) bool {
+	lower := strings.ToLower(p)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) && len(p) > len(ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadArchiveMemberAsync extracts the file named by the archive member path p, sending its
+// contents in blocks like simpleFs.loadFileAsync.
+func loadArchiveMemberAsync(p string, contents chan []byte, errs chan error, kill chan struct{}) {
+	archive, member, _ := splitArchiveMemberPath(p)
+
+	go func() {
+		r, err := openArchiveMember(archive, member)
+		if err != nil {
+			errs <- err
+			close(contents)
+			close(errs)
+			return
+		}
+		copyBlocks(r, contents, 1024*1024, errs, kill)
+		r.Close()
+		close(errs)
+	}()
+}
+
+// openArchiveMember opens the file member inside archive for reading. Local archives are read
+// directly. Only the parts of remote zip archives needed to find and read the file are read,
+// but remote tar archives are read from the start up to the file.
+func openArchiveMember(archive, member string) (r io.ReadCloser, err error) {
+	src, err := openArchiveSource(archive)
+	if err != nil {
+		return
+	}
+
+	lower := strings.ToLower(archive)
+	if strings.HasSuffix(lower, ".zip") {
+		r, err = openZipMember(src, member)
+	} else {
+		if src.remote && src.size > maxRemoteTarSize {
+			src.Close()
+			return nil, fmt.Errorf("Can't extract %s: the remote archive %s is larger than %d bytes", member, archive, maxRemoteTarSize)
+		}
+		gzipped := strings.HasSuffix(lower, ".gz") || strings.HasSuffix(lower, ".tgz")
+		r, err = openTarMember(src, member, gzipped)
+	}
+
+	if err != nil {
+		src.Close()
+		err = fmt.Errorf("Can't extract %s from %s: %w", member, archive, err)
+	}
+	return
+}
+
+// archiveSource is an archive that can be read at any offset.
+type archiveSource struct {
+	io.ReaderAt
+	io.Closer
+	size   int64
+	remote bool
+}
+
+func openArchiveSource(archive string) (src archiveSource, err error) {
+	sfs, err := GetFs(archive)
+	if err != nil {
+		return
+	}
+
+	if _, ok := sfs.(localFs); ok {
+		var f *os.File
+		f, err = os.Open(archive)
+		if err != nil {
+			return
+		}
+		var fi os.FileInfo
+		fi, err = f.Stat()
+		if err != nil {
+			f.Close()
+			return
+		}
+		return archiveSource{ReaderAt: f, Closer: f, size: fi.Size()}, nil
+	}
+
+	size, err := sfs.fileSize(archive)
+	if err != nil {
+		return
+	}
+	r := &rangeReaderAt{fs: sfs, path: archive, size: size}
+	return archiveSource{ReaderAt: r, Closer: nopCloser{}, size: size, remote: true}, nil
+}
+
+func openZipMember(src archiveSource, member string) (io.ReadCloser, error) {
+	zr, err := zip.NewReader(src, src.size)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range zr.File {
+		if archiveMemberNameMatches(f.Name, member) && !f.FileInfo().IsDir() {
+			r, err := f.Open()
+			if err != nil {
+				return nil, err
+			}
+			return archiveMemberReader{Reader: r, closers: []io.Closer{r, src}}, nil
+		}
+	}
+	return nil, fmt.Errorf("the file is not in the archive")
+}
+
+func openTarMember(src archiveSource, member string, gzipped bool) (io.ReadCloser, error) {
+	var r io.Reader = io.NewSectionReader(src, 0, src.size)
+	closers := []io.Closer{src}
+	if gzipped {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		r = gz
+		closers = append([]io.Closer{gz}, closers...)
+	}
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("the file is not in the archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if archiveMemberNameMatches(hdr.Name, member) && hdr.Typeflag != tar.TypeDir {
+			return archiveMemberReader{Reader: tr, closers: closers}, nil
+		}
+	}
+}
+
+// archiveMemberNameMatches returns true if name, the name of a file in an archive, is member.
+// Archives often store names relative to ./ and users don't type it.
+func archiveMemberNameMatches(name, member string) bool {
+	clean := func(s string) string {
+		return strings.TrimPrefix(path.Clean("/"+s), "/")
+	}
+	return clean(name) == clean(member)
+}
+
+type archiveMemberReader struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (r archiveMemberReader) Close() error {
+	for _, c := range r.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// rangeReaderAt reads a remote file in blocks using loadFileRange, keeping the last few blocks
+// so that the many small reads made by the archive packages don't each need a round trip.
+type rangeReaderAt struct {
+	fs     simpleFs
+	path   string
+	size   int64
+	blocks []rangeBlock
+}
+
+type rangeBlock struct {
+	offset int64
+	data   []byte
+}
+
+const rangeReaderAtMaxBlocks = 4
+
+func (r *rangeReaderAt) ReadAt(p []byte, off int64) (n int, err error) {
+	for n < len(p) {
+		if off+int64(n) >= r.size {
+			return n, io.EOF
+		}
+
+		var b []byte
+		b, err = r.block(off + int64(n))
+		if err != nil {
+			return
+		}
+		n += copy(p[n:], b)
+	}
+	return
+}
+
+// block returns the data of the file from off to the end of the block containing off.
+func (r *rangeReaderAt) block(off int64) ([]byte, error) {
+	start := off - off%remoteArchiveBlockSize
+	for _, b := range r.blocks {
+		if b.offset == start && off-start < int64(len(b.data)) {
+			return b.data[off-start:], nil
+		}
+	}
+
+	log(LogCatgFS, "rangeReaderAt: reading %s at %d\n", r.path, start)
+	data, err := r.fs.loadFileRange(r.path, start, remoteArchiveBlockSize)
+	if err != nil {
+		return nil, err
+	}
+	if off-start >= int64(len(data)) {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	r.blocks = append(r.blocks, rangeBlock{offset: start, data: data})
+	if len(r.blocks) > rangeReaderAtMaxBlocks {
+		r.blocks = r.blocks[1:]
+	}
+	return data[off-start:], nil
+}
+
+// CmdExtractToFile saves the text of a window holding a file inside an archive to a file of its
+// own, and changes the window to hold that file.
+func (c CommandExecutor) CmdExtractToFile(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok || !isArchiveMemberPath(w.file) {
+		editor.AppendError("", "Extract-to-file: must be executed in the window of a file inside an archive")
+		return
+	}
+
+	archive, member, _ := splitArchiveMemberPath(w.file)
+	name := path.Base(member)
+	if len(ctx.Args) > 0 {
+		name = ctx.CombinedArgs()
+	}
+
+	archivePath, err := NewGlobalPath(archive, GlobalPathIsFile)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Extract-to-file: %v", err))
+		return
+	}
+	target, err := NewGlobalPath(name, GlobalPathUnknown)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Extract-to-file: %v", err))
+		return
+	}
+	if !target.IsRemote() && !target.IsAbsolute() {
+		target = target.MakeAbsoluteRelativeTo(archivePath)
+	}
+
+	file := w.file
+	dest := target.String()
+	go func() {
+		// Checking whether the file exists may need an ssh connection.
+		sfs, err := GetFs(dest)
+		var exists bool
+		if err == nil {
+			exists, err = sfs.fileExists(dest)
+		}
+
+		editor.WorkChan() <- basicWork{func() {
+			if err != nil {
+				editor.AppendError("", fmt.Sprintf("Extract-to-file: %v", err))
+				return
+			}
+			if exists {
+				editor.AppendError("", fmt.Sprintf("Extract-to-file: %s already exists", dest))
+				return
+			}
+			if w.file != file {
+				return
+			}
+
+			w.SetFilenameAndTag(dest, typeFile)
+			w.setNotWritable(false)
+			w.Put()
+		}}
+	}()
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitArchiveMemberPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		archive string
+		member  string
+		ok      bool
+	}{
+		{"/src/vendor.tar.gz!pkg/file.go", "/src/vendor.tar.gz", "pkg/file.go", true},
+		{"host:/src/a.ZIP!b.txt", "host:/src/a.ZIP", "b.txt", true},
+		{"/src/a!b.zip!c/d.go", "/src/a!b.zip", "c/d.go", true},
+		{"/src/file.go!regex", "", "", false},
+		{"/src/vendor.tar!", "", "", false},
+		{".zip!file", "", "", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			archive, member, ok := splitArchiveMemberPath(tc.path)
+			if archive != tc.archive || member != tc.member || ok != tc.ok {
+				t.Fatalf("expected %q %q %v but got %q %q %v", tc.archive, tc.member, tc.ok, archive, member, ok)
+			}
+		})
+	}
+}
+
+func writeTestTarGz(t *testing.T, path string, files map[string]string) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, text := range files {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(text)), Typeflag: tar.TypeReg})
+		if err != nil {
+			t.Fatalf("writing tar header failed: %v", err)
+		}
+		tw.Write([]byte(text))
+	}
+	tw.Close()
+	gz.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("writing archive failed: %v", err)
+	}
+}
+
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, text := range files {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatalf("writing zip header failed: %v", err)
+		}
+		w.Write([]byte(text))
+	}
+	zw.Close()
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("writing archive failed: %v", err)
+	}
+}
+
+func readArchiveMember(t *testing.T, archive, member string) string {
+	r, err := openArchiveMember(archive, member)
+	if err != nil {
+		t.Fatalf("opening %s in %s failed: %v", member, archive, err)
+	}
+	defer r.Close()
+	b, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("reading %s in %s failed: %v", member, archive, err)
+	}
+	return string(b)
+}
+
+func TestOpenArchiveMember(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"./pkg/file.go": "package pkg\n",
+		"pkg/other.go":  "package other\n",
+	}
+	tgz := filepath.Join(dir, "vendor.tar.gz")
+	zp := filepath.Join(dir, "vendor.zip")
+	writeTestTarGz(t, tgz, files)
+	writeTestZip(t, zp, files)
+
+	for _, archive := range []string{tgz, zp} {
+		if s := readArchiveMember(t, archive, "pkg/file.go"); s != "package pkg\n" {
+			t.Errorf("expected pkg/file.go in %s to be extracted but got %q", archive, s)
+		}
+		if s := readArchiveMember(t, archive, "pkg/other.go"); s != "package other\n" {
+			t.Errorf("expected pkg/other.go in %s to be extracted but got %q", archive, s)
+		}
+		if _, err := openArchiveMember(archive, "pkg/missing.go"); err == nil || !strings.Contains(err.Error(), "not in the archive") {
+			t.Errorf("expected a missing file to be reported but got %v", err)
+		}
+	}
+}
+
+// countingFs counts the ranges read from files.
+type countingFs struct {
+	localFs
+	reads int
+}
+
+func (f *countingFs) loadFileRange(path string, offset, length int64) ([]byte, error) {
+	f.reads++
+	return f.localFs.loadFileRange(path, offset, length)
+}
+
+func TestZipMemberIsReadWithoutReadingWholeArchive(t *testing.T) {
+	dir := t.TempDir()
+	big := make([]byte, 8*remoteArchiveBlockSize)
+	rand.New(rand.NewSource(1)).Read(big)
+	zp := filepath.Join(dir, "big.zip")
+	writeTestZip(t, zp, map[string]string{"big.bin": string(big), "small.txt": "small\n"})
+
+	fi, err := os.Stat(zp)
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	fs := &countingFs{}
+	r := &rangeReaderAt{fs: fs, path: zp, size: fi.Size()}
+	m, err := openZipMember(archiveSource{ReaderAt: r, Closer: nopCloser{}, size: fi.Size(), remote: true}, "small.txt")
+	if err != nil {
+		t.Fatalf("opening small.txt failed: %v", err)
+	}
+	b, _ := io.ReadAll(m)
+	if string(b) != "small\n" {
+		t.Fatalf("expected small.txt to be extracted but got %q", b)
+	}
+	if fs.reads > 3 {
+		t.Fatalf("expected at most 3 ranges to be read but %d were", fs.reads)
+	}
+}
+
+func TestArchiveMemberWindowIsReadOnlyAndReloadsFromArchive(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	tgz := filepath.Join(dir, "vendor.tar.gz")
+	writeTestTarGz(t, tgz, map[string]string{"pkg/file.go": "line 1\nline 2\n"})
+	file := tgz + "!pkg/file.go"
+
+	waitForBody := func(w *Window) {
+		var body string
+		for i := 0; i < 100; i++ {
+			onMainGoroutine(func() { body = w.Body.String() })
+			if body == "line 1\nline 2\n" {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("expected the file to be extracted into the window but the body is %q", body)
+	}
+
+	var w *Window
+	onMainGoroutine(func() {
+		w = editor.NewWindow(nil)
+		err := w.LoadFileAndGoto(file, seek{line: 2}, selectText, dontGrowBodyIfTooSmall)
+		if err != nil {
+			t.Fatalf("loading %s failed: %v", file, err)
+		}
+	})
+	waitForBody(w)
+
+	var state *WindowState
+	onMainGoroutine(func() {
+		if w.IsWritable() {
+			t.Errorf("expected the window to be read-only")
+		}
+		if err := w.Put(); err == nil || !strings.Contains(err.Error(), "Extract-to-file") {
+			t.Errorf("expected Put to be refused but got %v", err)
+		}
+		if _, err := os.Stat(file); err == nil {
+			t.Errorf("expected Put not to write a file")
+		}
+
+		state = w.State()
+		if state.File != file || state.Body.Text != "" {
+			t.Errorf("expected the state to hold the path and not the text, but it holds %q and %q", state.File, state.Body.Text)
+		}
+	})
+
+	var loaded *Window
+	onMainGoroutine(func() {
+		loaded = editor.NewWindow(nil)
+		loaded.SetState(state)
+	})
+	waitForBody(loaded)
+
+	onMainGoroutine(func() {
+		NewCommandExecutor(loaded).CmdExtractToFile(&CmdContext{Dir: dir})
+	})
+	extracted := filepath.Join(dir, "file.go")
+	for i := 0; i < 100; i++ {
+		if b, err := os.ReadFile(extracted); err == nil && string(b) == "line 1\nline 2\n" {
+			onMainGoroutine(func() {
+				if loaded.file != extracted || !loaded.IsWritable() {
+					t.Errorf("expected the window to hold the extracted file")
+				}
+			})
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected Extract-to-file to write %s", extracted)
+}
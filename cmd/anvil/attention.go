@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"regexp"
+
+	"gioui.org/app"
+)
+
+//go:embed icon/anvil.png
+var defaultIconPng []byte
+
+// platformWindow controls the parts of the operating system window that Gio doesn't
+// expose: the icon, the hint that the window needs the user's attention (the urgency
+// hint on X11, or flashing the taskbar button on Windows), and the position of the mouse
+// pointer. It is only implemented on some platforms; on the others newPlatformWindow
+// returns nil.
+type platformWindow interface {
+	setIcon(img image.Image) error
+	setUrgent(urgent bool) error
+	// warpPointer moves the mouse pointer to pt, in pixels from the top left of the window.
+	warpPointer(pt image.Point) error
+}
+
+// SetView is called when the operating system window backing the application window
+// is created or destroyed.
+func (a *Application) SetView(e app.ViewEvent) {
+	a.platformWin = newPlatformWindow(e)
+	if a.platformWin == nil {
+		return
+	}
+
+	a.setIcon()
+	if a.urgent {
+		a.setUrgent(true)
+	}
+}
+
+func (a *Application) setIcon() {
+	img, err := loadIcon()
+	if err != nil {
+		log(LogCatgApp, "Application: loading icon failed: %v\n", err)
+		return
+	}
+
+	err = a.platformWin.setIcon(img)
+	if err != nil {
+		log(LogCatgApp, "Application: setting icon failed: %v\n", err)
+	}
+}
+
+// loadIcon loads the icon set in the settings, or the default icon if none is set.
+func loadIcon() (image.Image, error) {
+	if settings.General.Icon == "" {
+		return png.Decode(bytes.NewReader(defaultIconPng))
+	}
+
+	f, err := os.Open(settings.General.Icon)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return png.Decode(f)
+}
+
+func (a *Application) focused() bool {
+	return a.winConfig != nil && a.winConfig.Focused
+}
+
+// RequestAttention asks for the user's attention if the application window is not focused, and
+// runs the notification command from the settings to show msg. The request is cleared when the
+// window is focused.
+func (a *Application) RequestAttention(msg string) {
+	if a.focused() {
+		return
+	}
+
+	log(LogCatgApp, "Application: requesting attention: %s\n", msg)
+	runNotifyCommand(msg)
+
+	a.urgent = true
+	a.setUrgent(true)
+}
+
+func (a *Application) clearAttention() {
+	if !a.urgent {
+		return
+	}
+
+	a.urgent = false
+	a.setUrgent(false)
+}
+
+func (a *Application) setUrgent(urgent bool) {
+	if a.platformWin == nil {
+		return
+	}
+
+	err := a.platformWin.setUrgent(urgent)
+	if err != nil {
+		log(LogCatgApp, "Application: setting urgency failed: %v\n", err)
+	}
+}
+
+// JobFinished gives the cue for job finishing, and requests attention if the settings say that
+// the user should be notified when job finishes.
+func (a *Application) JobFinished(job Job) {
+	a.cueJobFinished(job)
+
+	r, ok := job.(JobResulter)
+	if ok && r.Killed() {
+		return
+	}
+
+	if ok && r.Failed() && settings.Notify.OnJobFailure {
+		a.RequestAttention(fmt.Sprintf("%s failed", job.Name()))
+		return
+	}
+
+	if jobNameMatchesNotifyPatterns(job.Name()) {
+		a.RequestAttention(fmt.Sprintf("%s finished", job.Name()))
+	}
+}
+
+func jobNameMatchesNotifyPatterns(name string) bool {
+	for _, p := range settings.Notify.Jobs {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log(LogCatgApp, "Invalid regular expression '%s' in notify jobs setting: %v\n", p, err)
+			continue
+		}
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// runNotifyCommand runs the notification command from the settings, if there is one, with
+// $1 replaced by msg.
+func runNotifyCommand(msg string) {
+	if settings.Notify.Command == "" {
+		return
+	}
+
+	err := runCommandInBackground(substitute(settings.Notify.Command, []string{shellQuote(msg)}), nil)
+	if err != nil {
+		log(LogCatgApp, "Running notify command failed: %v\n", err)
+	}
+}
+
+// runCommandInBackground runs cmd locally without showing its output. done, if not nil, is
+// called from another goroutine when the command ends.
+func runCommandInBackground(cmd string, done func()) error {
+	load := NewDataLoad()
+	ec := execCtx{
+		cmd: cmd,
+		// A non-nil extraEnv makes the command inherit Anvil's environment, which
+		// notification programs need to find the desktop session.
+		extraEnv: []string{},
+		contents: load.Contents,
+		errs:     load.Errs,
+		kill:     load.Kill,
+	}
+
+	var fs localFs
+	err := fs.execAsync(ec)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		contents, errs := load.Contents, load.Errs
+		for contents != nil || errs != nil {
+			select {
+			case _, ok := <-contents:
+				if !ok {
+					contents = nil
+				}
+			case err, ok := <-errs:
+				if !ok {
+					errs = nil
+					break
+				}
+				log(LogCatgApp, "Command '%s' failed: %v\n", cmd, err)
+			}
+		}
+		if done != nil {
+			done()
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !windows && !(((linux && !android) || freebsd || openbsd) && !nox11 && xlib)
+
+package main
+
+import "gioui.org/app"
+
+func newPlatformWindow(e app.ViewEvent) platformWindow {
+	return nil
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"syscall"
+	"unsafe"
+
+	"gioui.org/app"
+)
+
+var (
+	user32                       = syscall.NewLazyDLL("user32.dll")
+	procFlashWindowEx            = user32.NewProc("FlashWindowEx")
+	procCreateIconFromResourceEx = user32.NewProc("CreateIconFromResourceEx")
+	procSendMessageW             = user32.NewProc("SendMessageW")
+	procClientToScreen           = user32.NewProc("ClientToScreen")
+	procSetCursorPos             = user32.NewProc("SetCursorPos")
+)
+
+const (
+	_FLASHW_STOP      = 0
+	_FLASHW_ALL       = 0x3
+	_FLASHW_TIMERNOFG = 0xc
+	_WM_SETICON       = 0x80
+	_ICON_SMALL       = 0
+	_ICON_BIG         = 1
+)
+
+type flashwinfo struct {
+	cbSize    uint32
+	hwnd      uintptr
+	dwFlags   uint32
+	uCount    uint32
+	dwTimeout uint32
+}
+
+// point is the Win32 POINT structure.
+type point struct {
+	x, y int32
+}
+
+// win32Window is the platformWindow for Windows. Attention is requested by flashing the
+// taskbar button.
+type win32Window struct {
+	hwnd uintptr
+}
+
+func newPlatformWindow(e app.ViewEvent) platformWindow {
+	w, ok := e.(app.Win32ViewEvent)
+	if !ok || w.HWND == 0 {
+		return nil
+	}
+
+	return &win32Window{hwnd: w.HWND}
+}
+
+func (w *win32Window) setIcon(img image.Image) error {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	if err != nil {
+		return err
+	}
+	b := buf.Bytes()
+
+	// CreateIconFromResourceEx accepts PNG data as the icon resource.
+	icon, _, err := procCreateIconFromResourceEx.Call(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), 1, 0x00030000, 0, 0, 0)
+	if icon == 0 {
+		return fmt.Errorf("creating icon failed: %v", err)
+	}
+
+	procSendMessageW.Call(w.hwnd, _WM_SETICON, _ICON_BIG, icon)
+	procSendMessageW.Call(w.hwnd, _WM_SETICON, _ICON_SMALL, icon)
+	return nil
+}
+
+func (w *win32Window) setUrgent(urgent bool) error {
+	info := flashwinfo{
+		hwnd:    w.hwnd,
+		dwFlags: _FLASHW_STOP,
+	}
+	info.cbSize = uint32(unsafe.Sizeof(info))
+	if urgent {
+		info.dwFlags = _FLASHW_ALL | _FLASHW_TIMERNOFG
+	}
+
+	procFlashWindowEx.Call(uintptr(unsafe.Pointer(&info)))
+	return nil
+}
+
+func (w *win32Window) warpPointer(pt image.Point) error {
+	p := point{x: int32(pt.X), y: int32(pt.Y)}
+	if r, _, err := procClientToScreen.Call(w.hwnd, uintptr(unsafe.Pointer(&p))); r == 0 {
+		return fmt.Errorf("converting to screen coordinates failed: %v", err)
+	}
+
+	if r, _, err := procSetCursorPos.Call(uintptr(p.x), uintptr(p.y)); r == 0 {
+		return fmt.Errorf("moving the cursor failed: %v", err)
+	}
+	return nil
+}
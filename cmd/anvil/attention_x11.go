@@ -0,0 +1,115 @@
+//go:build ((linux && !android) || freebsd || openbsd) && !nox11 && xlib
+
+package main
+
+/*
+#cgo freebsd openbsd CFLAGS: -I/usr/X11R6/include -I/usr/local/include
+#cgo freebsd openbsd LDFLAGS: -L/usr/X11R6/lib -L/usr/local/lib -lX11
+#cgo linux pkg-config: x11
+
+#include <X11/Xlib.h>
+#include <X11/Xatom.h>
+#include <X11/Xutil.h>
+
+// The display is shared with Gio, which uses it from its own event thread. Gio calls
+// XInitThreads before opening it, so each helper holds the display lock while it uses it.
+
+static void anvil_set_icon(Display *dpy, Window win, unsigned long *data, int n) {
+	XLockDisplay(dpy);
+	Atom icon = XInternAtom(dpy, "_NET_WM_ICON", False);
+	XChangeProperty(dpy, win, icon, XA_CARDINAL, 32, PropModeReplace, (unsigned char *)data, n);
+	XFlush(dpy);
+	XUnlockDisplay(dpy);
+}
+
+static int anvil_set_urgent(Display *dpy, Window win, int urgent) {
+	XLockDisplay(dpy);
+	XWMHints *hints = XGetWMHints(dpy, win);
+	if (hints == NULL) {
+		hints = XAllocWMHints();
+		if (hints == NULL) {
+			XUnlockDisplay(dpy);
+			return 0;
+		}
+	}
+	if (urgent) {
+		hints->flags |= XUrgencyHint;
+	} else {
+		hints->flags &= ~XUrgencyHint;
+	}
+	XSetWMHints(dpy, win, hints);
+	XFree(hints);
+	XFlush(dpy);
+	XUnlockDisplay(dpy);
+	return 1;
+}
+
+static void anvil_warp_pointer(Display *dpy, Window win, int x, int y) {
+	XLockDisplay(dpy);
+	XWarpPointer(dpy, None, win, 0, 0, 0, 0, x, y);
+	XFlush(dpy);
+	XUnlockDisplay(dpy);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"gioui.org/app"
+)
+
+// x11Window is the platformWindow for X11. Under Wayland no platformWindow is available since
+// the compositor protocols for window icons and activation requests are not exposed by Gio.
+//
+// It links Xlib directly, so it is only built with the xlib build tag. Without the tag Anvil
+// builds without the X11 development files, but on X11 the window has no icon, the urgency hint
+// is never set and the pointer is never moved.
+type x11Window struct {
+	display *C.Display
+	window  C.Window
+}
+
+func newPlatformWindow(e app.ViewEvent) platformWindow {
+	x, ok := e.(app.X11ViewEvent)
+	if !ok || x.Display == nil {
+		return nil
+	}
+
+	return &x11Window{display: (*C.Display)(x.Display), window: C.Window(x.Window)}
+}
+
+func (w *x11Window) setIcon(img image.Image) error {
+	b := img.Bounds()
+	// _NET_WM_ICON is the width, the height, then the pixels as ARGB. Xlib expects
+	// format 32 properties as an array of longs.
+	data := make([]C.ulong, 0, 2+b.Dx()*b.Dy())
+	data = append(data, C.ulong(b.Dx()), C.ulong(b.Dy()))
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			data = append(data, C.ulong(uint32(c.A)<<24|uint32(c.R)<<16|uint32(c.G)<<8|uint32(c.B)))
+		}
+	}
+
+	C.anvil_set_icon(w.display, w.window, &data[0], C.int(len(data)))
+	return nil
+}
+
+func (w *x11Window) setUrgent(urgent bool) error {
+	u := C.int(0)
+	if urgent {
+		u = 1
+	}
+	if C.anvil_set_urgent(w.display, w.window, u) == 0 {
+		return fmt.Errorf("allocating window manager hints failed")
+	}
+	return nil
+}
+
+func (w *x11Window) warpPointer(pt image.Point) error {
+	C.anvil_warp_pointer(w.display, w.window, C.int(pt.X), C.int(pt.Y))
+	return nil
+}
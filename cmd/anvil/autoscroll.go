@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"time"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+)
+
+const (
+	// dragAutoScrollInterval is how often the text is scrolled while a selection is dragged
+	// past the top or bottom edge of an editable.
+	dragAutoScrollInterval = 100 * time.Millisecond
+	// dragAutoScrollFullSpeedLines is how many lines past the edge the pointer must be for the
+	// text to scroll at full speed, which is a page per second.
+	dragAutoScrollFullSpeedLines = 5
+)
+
+// dragAutoScroll is the state of automatic scrolling while a selection is being dragged near
+// the top or bottom edge of an editable. The text keeps scrolling while the button is held
+// even if the pointer doesn't move.
+type dragAutoScroll struct {
+	// pos is the last position of the pointer
+	pos  f32.Point
+	rank SelectionRank
+	// execute is true if the selection is being built by dragging the tertiary button
+	execute bool
+}
+
+// dragAutoScrollLines returns how many lines to scroll each dragAutoScrollInterval, and in which
+// direction, when the pointer is dragged at height y in an editable that is heightInLines lines
+// high. Near the edge it is one line, increasing to a page per second as the pointer moves
+// further past the edge. It returns 0 if the pointer is not near the edge.
+func dragAutoScrollLines(y float32, heightInLines, lineHeight int) (lines int, d verticalDirection) {
+	var past float32
+	if y < float32(lineHeight) {
+		past = float32(lineHeight) - y
+		d = Up
+	} else if y > float32((heightInLines-1)*lineHeight) {
+		past = y - float32((heightInLines-1)*lineHeight)
+		d = Down
+	} else {
+		return 0, Down
+	}
+
+	frac := float64(past) / float64(dragAutoScrollFullSpeedLines*lineHeight)
+	if frac > 1 {
+		frac = 1
+	}
+
+	max := float64(heightInLines) * float64(dragAutoScrollInterval) / float64(time.Second)
+	if max < 1 {
+		max = 1
+	}
+
+	lines = 1 + int(math.Round(frac*(max-1)))
+	return
+}
+
+// autoScrollIfDraggedNearEdge starts scrolling the text automatically when a selection is
+// dragged near the top or bottom edge, and stops it when the pointer moves away.
+func (e *editable) autoScrollIfDraggedNearEdge(ps *PointerState, rank SelectionRank, execute bool) {
+	if e.PreventScrolling {
+		return
+	}
+
+	pos := ps.currentPointerEvent.Position
+	if n, _ := dragAutoScrollLines(pos.Y, e.heightInLines(ps.gtx), e.lineHeight()); n == 0 {
+		e.dragAutoScroll = nil
+		return
+	}
+
+	if e.dragAutoScroll == nil {
+		e.dragAutoScroll = &dragAutoScroll{}
+		e.scheduleDragAutoScroll()
+	}
+	e.dragAutoScroll.pos = pos
+	e.dragAutoScroll.rank = rank
+	e.dragAutoScroll.execute = execute
+}
+
+func (e *editable) scheduleDragAutoScroll() {
+	e.scheduleWithPriority("drag-autoscroll", dragAutoScrollInterval, workPriorityInteractive, func() {
+		if e.dragAutoScroll == nil {
+			return
+		}
+		// Scrolling needs the layout constraints, so it is done in the next layout.
+		e.AddOpForNextLayout(e.dragAutoScrollStep)
+		editor.SignalRedrawRequired()
+	})
+}
+
+// dragAutoScrollStep scrolls the text and extends the selection being built to the text that
+// is now under the pointer.
+func (e *editable) dragAutoScrollStep(gtx layout.Context) {
+	a := e.dragAutoScroll
+	if a == nil || e.selectionBeingBuilt == nil || e.PreventScrolling {
+		e.dragAutoScroll = nil
+		return
+	}
+
+	n, d := dragAutoScrollLines(a.pos.Y, e.heightInLines(gtx), e.lineHeight())
+	if n == 0 {
+		e.dragAutoScroll = nil
+		return
+	}
+
+	for i := 0; i < n; i++ {
+		e.ScrollOneLine(gtx, d)
+	}
+	e.extendSelectionBeingBuiltToPosition(gtx, a.pos, a.rank, a.execute)
+	e.scheduleDragAutoScroll()
+}
+
+// extendSelectionBeingBuiltToPosition extends the selection being built to the text at
+// pos in the current viewport.
+func (e *editable) extendSelectionBeingBuiltToPosition(gtx layout.Context, pos f32.Point, rank SelectionRank, execute bool) {
+	ltext, err := e.getOrBuildLayedoutText(gtx, e.visibleText(gtx))
+	if err != nil {
+		return
+	}
+
+	e.extendSelectionBeingBuilt(rank, e.runeIndexOfPosition(pos, *ltext))
+	if execute {
+		e.primarySelPurpose = SelectionPurposeExecute
+	} else {
+		e.lastSearchResult = nil
+	}
+}
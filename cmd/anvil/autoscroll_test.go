@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+)
+
+func TestDragAutoScrollLines(t *testing.T) {
+	tests := []struct {
+		name          string
+		y             float32
+		expectedLines int
+		expectedDir   verticalDirection
+	}{
+		{"middle", 200, 0, Down},
+		{"near top", 5, 1, Up},
+		{"near bottom", 395, 1, Down},
+		{"well above", -200, 4, Up},
+		{"well below", 600, 4, Down},
+		{"a little below", 415, 3, Down},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// 40 lines of 10 pixels
+			lines, dir := dragAutoScrollLines(tc.y, 40, 10)
+			if lines != tc.expectedLines || (lines > 0 && dir != tc.expectedDir) {
+				t.Fatalf("expected %d lines %v but got %d lines %v", tc.expectedLines, tc.expectedDir, lines, dir)
+			}
+		})
+	}
+}
+
+// dragHoldTestWindow returns a window whose body has many numbered lines and a layout context
+// in which its body is 20 lines high. The auto-scroll ticks are performed by autoScrollTick
+// rather than by the scheduler.
+func dragHoldTestWindow(t *testing.T) (*Window, layout.Context) {
+	newTestEditor()
+
+	var buf strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&buf, "line %d\n", i)
+	}
+
+	win := editor.NewWindow(nil)
+	win.Body.SetText([]byte(buf.String()))
+	// Nothing services the work of this scheduler: its timers are only used to see whether a
+	// tick is scheduled.
+	win.Body.Scheduler = NewScheduler(make(chan Work, 10))
+
+	gtx := layout.Context{
+		Ops:         new(op.Ops),
+		Metric:      unit.Metric{PxPerDp: 1, PxPerSp: 1},
+		Constraints: layout.Exact(image.Pt(400, 20*win.Body.lineHeight())),
+	}
+	return win, gtx
+}
+
+// dragTo simulates dragging the primary button to y while building a selection.
+func dragTo(e *editable, gtx layout.Context, y float32) {
+	e.pointerState.gtx = gtx
+	e.pointerState.currentPointerEvent.Position = f32.Point{X: 10, Y: y}
+	e.autoScrollIfDraggedNearEdge(&e.pointerState, PrimarySelection, false)
+}
+
+// autoScrollTick performs the scheduled auto-scroll as if its timer had fired and the next
+// layout had run. It returns false if no auto-scroll was scheduled.
+func autoScrollTick(e *editable, gtx layout.Context) (scheduled bool) {
+	timer, ok := e.Scheduler.timers["drag-autoscroll"]
+	if !ok {
+		return false
+	}
+	timer.Stop()
+	delete(e.Scheduler.timers, "drag-autoscroll")
+
+	if e.dragAutoScroll != nil {
+		e.dragAutoScrollStep(gtx)
+	}
+	return true
+}
+
+func TestDragHeldAtBottomEdgeKeepsScrollingAndExtendingSelection(t *testing.T) {
+	win, gtx := dragHoldTestWindow(t)
+	e := &win.Body.editable
+
+	e.setToOneCursorIndex(0)
+	e.extendSelectionBeingBuilt(PrimarySelection, 5)
+	// Hold the pointer well below the bottom edge without moving it
+	dragTo(e, gtx, float32(30*e.lineHeight()))
+
+	lastTop, lastEnd := 0, 5
+	for i := 0; i < 3; i++ {
+		if !autoScrollTick(e, gtx) {
+			t.Fatalf("tick %d: expected scrolling to be scheduled", i)
+		}
+
+		top, end := e.TopLeftIndex, e.selectionBeingBuilt.end
+		if top <= lastTop {
+			t.Fatalf("tick %d: expected the text to scroll down but the top is %d, previously %d", i, top, lastTop)
+		}
+		if end <= lastEnd || end < top {
+			t.Fatalf("tick %d: expected the selection end to follow the scrolled text but it is %d (top %d)", i, end, top)
+		}
+		lastTop, lastEnd = top, end
+	}
+
+	// Releasing stops scrolling immediately
+	e.stopBuildingSelection()
+	autoScrollTick(e, gtx)
+	if e.TopLeftIndex != lastTop {
+		t.Fatalf("expected scrolling to stop when the button was released")
+	}
+	if autoScrollTick(e, gtx) {
+		t.Fatalf("expected no more scrolling to be scheduled after the button was released")
+	}
+}
+
+func TestDragAutoScrollRespectsPreventScrolling(t *testing.T) {
+	win, gtx := dragHoldTestWindow(t)
+	e := &win.Body.editable
+
+	e.PreventScrolling = true
+	e.setToOneCursorIndex(0)
+	e.extendSelectionBeingBuilt(PrimarySelection, 5)
+	dragTo(e, gtx, float32(30*e.lineHeight()))
+
+	if autoScrollTick(e, gtx) || e.TopLeftIndex != 0 || e.dragAutoScroll != nil {
+		t.Fatalf("expected no scrolling when scrolling is prevented")
+	}
+}
+
+func TestWheelScrollDuringDragExtendsSelection(t *testing.T) {
+	win, gtx := dragHoldTestWindow(t)
+	e := &win.Body.editable
+
+	e.InitPointerEventHandlers()
+	e.setToOneCursorIndex(0)
+	e.extendSelectionBeingBuilt(PrimarySelection, 5)
+
+	e.pointerState.gtx = gtx
+	e.pointerState.currentPointerEvent.Position = f32.Point{X: 10, Y: float32(10 * e.lineHeight())}
+	e.pointerState.currentPointerEvent.Scroll = f32.Point{Y: 1}
+	e.onPointerScroll(&e.pointerState)
+
+	if e.TopLeftIndex == 0 {
+		t.Fatalf("expected the wheel to scroll the text")
+	}
+	if e.selectionBeingBuilt.end <= e.TopLeftIndex {
+		t.Fatalf("expected the selection end to follow the scrolled text but it is %d (top %d)",
+			e.selectionBeingBuilt.end, e.TopLeftIndex)
+	}
+}
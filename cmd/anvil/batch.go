@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gioui.org/layout"
+)
+
+// This file implements POST /batch, which performs a list of operations on windows in order in
+// one work item in the main goroutine, so that the user can't change the windows between them.
+// This lets a tool read the tags of all windows and write them back without a request for each,
+// and without racing with the user's edits. The operations stop at the first one that fails;
+// those before it are not undone.
+//
+// In JSON the request is a list of operations like
+//
+//	[{"Op": "gettag", "WinId": 3}, {"Op": "puttag", "WinId": 3, "Text": "/a/file Del"}]
+//
+// and in CSV each row is an operation with the fields op, winid, text, cursors, cmd and args.
+// The cursors and args are separated by spaces in CSV.
+
+const (
+	// apiBatchOpGetBody returns the body of the window in Text.
+	apiBatchOpGetBody = "getbody"
+	// apiBatchOpPutBody replaces the body of the window with Text.
+	apiBatchOpPutBody = "putbody"
+	// apiBatchOpGetTag returns the tag of the window in Text.
+	apiBatchOpGetTag = "gettag"
+	// apiBatchOpPutTag replaces the tag of the window with Text.
+	apiBatchOpPutTag = "puttag"
+	// apiBatchOpGetCursors returns the rune offsets of the cursors in the window body in Cursors.
+	apiBatchOpGetCursors = "getcursors"
+	// apiBatchOpPutCursors moves the cursors in the window body to the rune offsets in Cursors.
+	// It fails if an offset is outside the body.
+	apiBatchOpPutCursors = "putcursors"
+	// apiBatchOpExecute executes Cmd with Args as if it was clicked in the tag of the window, or
+	// in the editor tag if WinId is -1. Like POST /execute the command runs at the next layout,
+	// so the operations after it in the batch don't see its effects.
+	apiBatchOpExecute = "execute"
+)
+
+type apiBatchOp struct {
+	Op      string          `csv:"op"`
+	WinId   int             `csv:"winid"`
+	Text    string          `csv:"text"`
+	Cursors apiBatchOffsets `csv:"cursors"`
+	Cmd     string          `csv:"cmd"`
+	Args    apiBatchArgs    `csv:"args"`
+}
+
+// apiBatchResult is the result of an operation in a batch. Index is the index of the operation.
+// Error is set if the operation failed, in which case it is the last result.
+type apiBatchResult struct {
+	Index   int             `csv:"index"`
+	Op      string          `csv:"op"`
+	Text    string          `json:",omitempty" csv:"text"`
+	Cursors apiBatchOffsets `json:",omitempty" csv:"cursors"`
+	Error   string          `json:",omitempty" csv:"error"`
+}
+
+// apiBatchOffsets are rune offsets. In CSV they are separated by spaces.
+type apiBatchOffsets []int
+
+func (o apiBatchOffsets) MarshalCSV() ([]byte, error) {
+	s := make([]string, len(o))
+	for i, v := range o {
+		s[i] = strconv.Itoa(v)
+	}
+	return []byte(strings.Join(s, " ")), nil
+}
+
+func (o *apiBatchOffsets) UnmarshalCSV(b []byte) error {
+	*o = nil
+	for _, f := range strings.Fields(string(b)) {
+		v, err := strconv.Atoi(f)
+		if err != nil {
+			return fmt.Errorf("invalid rune offset %q", f)
+		}
+		*o = append(*o, v)
+	}
+	return nil
+}
+
+// apiBatchArgs are the arguments to a command. In CSV they are separated by spaces.
+type apiBatchArgs []string
+
+func (a apiBatchArgs) MarshalCSV() ([]byte, error) {
+	return []byte(strings.Join(a, " ")), nil
+}
+
+func (a *apiBatchArgs) UnmarshalCSV(b []byte) error {
+	*a = strings.Fields(string(b))
+	return nil
+}
+
+// apiBatchError is the reason an operation in a batch failed, and the HTTP status to respond with.
+type apiBatchError struct {
+	status int
+	msg    string
+}
+
+func (a ApiHandler) serveBatch(sess *ApiSession, rsp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		msg := fmt.Sprintf("Method %s is not supported for %s", req.Method, req.URL.Path)
+		http.Error(rsp, msg, http.StatusBadRequest)
+		return
+	}
+
+	var ops []apiBatchOp
+	_, dec, err := a.getDecoder(rsp, req, "op", "winid", "text", "cursors", "cmd", "args")
+	if err == nil {
+		err = dec.Decode(&ops)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Decoding request body failed with error %v", err)
+		http.Error(rsp, msg, http.StatusBadRequest)
+		return
+	}
+
+	var results []apiBatchResult
+	status := http.StatusOK
+	done := make(chan struct{})
+	fn := func() {
+		defer close(done)
+		results = make([]apiBatchResult, 0, len(ops))
+		for i, op := range ops {
+			r := apiBatchResult{Index: i, Op: op.Op}
+			if berr := performApiBatchOp(sess, op, &r); berr != nil {
+				log(LogCatgAPI, "ApiHandler.serveBatch: operation %d (%s) failed: %s\n", i, op.Op, berr.msg)
+				r.Error = berr.msg
+				status = berr.status
+				results = append(results, r)
+				return
+			}
+			results = append(results, r)
+		}
+	}
+
+	editor.WorkChan() <- basicWork{fn}
+	<-done
+
+	contentType, enc, flush := a.getEncoderForHTTPResponse(rsp, req)
+	rsp.Header().Add("Content-Type", string(contentType))
+	rsp.WriteHeader(status)
+	enc.Encode(results)
+	flush()
+}
+
+// performApiBatchOp performs op and stores what it returns in r. It must be called in the main
+// goroutine.
+func performApiBatchOp(sess *ApiSession, op apiBatchOp, r *apiBatchResult) *apiBatchError {
+	if berr := sess.batchOpAllowed(op); berr != nil {
+		return berr
+	}
+
+	if op.Op == apiBatchOpExecute && op.WinId < 0 {
+		log(LogCatgAPI, "ApiHandler.serveBatch: running command '%s %v' in context of editor tag\n", op.Cmd, strings.Join(op.Args, " "))
+		editor.Execute(op.Cmd, op.Args)
+		return nil
+	}
+
+	win := editor.FindWindowForId(op.WinId)
+	if win == nil {
+		return &apiBatchError{http.StatusNotFound, fmt.Sprintf("No window with id %d", op.WinId)}
+	}
+
+	switch op.Op {
+	case apiBatchOpGetBody:
+		if !sess.mayReadBodyOf(win) {
+			return &apiBatchError{http.StatusForbidden, fmt.Sprintf("Window %d is sensitive. Its body may only be read by commands run from that window", win.Id)}
+		}
+		r.Text = string(win.Body.Bytes())
+	case apiBatchOpPutBody:
+		setWindowBodyFromApi(win, []byte(op.Text))
+	case apiBatchOpGetTag:
+		r.Text = string(win.Tag.Bytes())
+	case apiBatchOpPutTag:
+		if err := win.SetWholeTag(op.Text); err != nil {
+			return &apiBatchError{http.StatusBadRequest, fmt.Sprintf("Setting the tag failed: %v", err)}
+		}
+	case apiBatchOpGetCursors:
+		r.Cursors = make(apiBatchOffsets, len(win.Body.CursorIndices))
+		copy(r.Cursors, win.Body.CursorIndices)
+	case apiBatchOpPutCursors:
+		for _, c := range op.Cursors {
+			if c < 0 || c > win.Body.Len() {
+				return &apiBatchError{http.StatusBadRequest, fmt.Sprintf("Cursor offset %d is outside the body of window %d, which has %d runes", c, win.Id, win.Body.Len())}
+			}
+		}
+		if !win.apiCursorMovesAllowed() {
+			log(LogCatgAPI, "ApiHandler.serveBatch: ignoring cursors since following is paused\n")
+			return nil
+		}
+		win.Body.SetCursorIndices(op.Cursors)
+	case apiBatchOpExecute:
+		log(LogCatgAPI, "ApiHandler.serveBatch: adding command '%s %v' in context of window %d for next layout\n", op.Cmd, strings.Join(op.Args, " "), win.Id)
+		win.Tag.AddOpForNextLayout(func(gtx layout.Context) {
+			win.Tag.adapter.execute(&win.Tag.blockEditable.editable, gtx, op.Cmd, op.Args)
+		})
+	default:
+		return &apiBatchError{http.StatusBadRequest, fmt.Sprintf("Unknown operation '%s'", op.Op)}
+	}
+	return nil
+}
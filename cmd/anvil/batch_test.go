@@ -0,0 +1,141 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+func TestBatchThroughApi(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag("/tmp/batch.txt", typeFile)
+		win.Body.SetText([]byte("hello world\n"))
+	})
+	w := api.Window{Id: win.Id}
+
+	var b api.Batch
+	oldTag := b.WindowTag(w)
+	b.SetWindowTag(w, "/tmp/batch.txt Del Snarf | Look &&")
+	body := b.WindowBody(w)
+	b.SetWindowBodyCursors(w, []int{6})
+	cursors := b.WindowBodyCursors(w)
+
+	if err := anvil.RunBatch(&b); err != nil {
+		t.Fatalf("running the batch failed: %v", err)
+	}
+	if !strings.HasPrefix(oldTag.Text, "/tmp/batch.txt") {
+		t.Fatalf("expected the tag to start with the filename but it is %q", oldTag.Text)
+	}
+	if body.Text != "hello world\n" {
+		t.Fatalf("expected the body %q but got %q", "hello world\n", body.Text)
+	}
+	if len(cursors.Cursors) != 1 || cursors.Cursors[0] != 6 {
+		t.Fatalf("expected the cursors to be [6] but they are %v", cursors.Cursors)
+	}
+
+	var newTag string
+	onMainGoroutine(func() {
+		newTag = win.Tag.String()
+	})
+	if !strings.HasSuffix(newTag, "Look &&") {
+		t.Fatalf("expected the tag to be set but it is %q", newTag)
+	}
+
+	// The operations stop at the first one that fails.
+	b = api.Batch{}
+	before := b.WindowTag(w)
+	b.SetWindowBody(api.Window{Id: 9999}, "x")
+	b.SetWindowBody(w, "not set")
+
+	err := anvil.RunBatch(&b)
+	var berr *api.BatchError
+	if !errors.As(err, &berr) {
+		t.Fatalf("expected a BatchError but got %v", err)
+	}
+	if berr.Index != 1 || berr.Status != http.StatusNotFound {
+		t.Fatalf("expected operation 1 to fail with status 404 but got %d with %d", berr.Index, berr.Status)
+	}
+	if before.Text != newTag {
+		t.Fatalf("expected the operation before the failure to be performed but the tag read is %q", before.Text)
+	}
+	var text string
+	onMainGoroutine(func() {
+		text = win.Body.String()
+	})
+	if text != "hello world\n" {
+		t.Fatalf("expected the operation after the failure not to be performed but the body is %q", text)
+	}
+
+	// Moving a cursor outside the body fails rather than being ignored.
+	b = api.Batch{}
+	b.SetWindowBodyCursors(w, []int{2})
+	b.SetWindowBodyCursors(w, []int{3, 100})
+	b.SetWindowBody(w, "not set")
+
+	err = anvil.RunBatch(&b)
+	if !errors.As(err, &berr) {
+		t.Fatalf("expected a BatchError but got %v", err)
+	}
+	if berr.Index != 1 || berr.Status != http.StatusBadRequest {
+		t.Fatalf("expected operation 1 to fail with status 400 but got %d with %d", berr.Index, berr.Status)
+	}
+	var cursorsAfter []int
+	onMainGoroutine(func() {
+		text = win.Body.String()
+		cursorsAfter = append(cursorsAfter, win.Body.CursorIndices...)
+	})
+	if len(cursorsAfter) != 1 || cursorsAfter[0] != 2 {
+		t.Fatalf("expected the cursors to be left at [2] but they are %v", cursorsAfter)
+	}
+	if text != "hello world\n" {
+		t.Fatalf("expected the operation after the failure not to be performed but the body is %q", text)
+	}
+}
+
+func TestBatchInCsv(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag("/tmp/batch.txt", typeFile)
+		win.Body.SetText([]byte("one two three\n"))
+	})
+
+	sess, err := createApiSession("test")
+	if err != nil {
+		t.Fatalf("creating API session failed: %v", err)
+	}
+	defer deleteApiSession(sess.id)
+
+	id := strconv.Itoa(win.Id)
+	ops := "putcursors," + id + ",,4 8,,\n" +
+		"getcursors," + id + ",,,,\n" +
+		"getbody," + id + ",,,,\n"
+	req := httptest.NewRequest(http.MethodPost, "/batch", strings.NewReader(ops))
+	req.Header.Set("Content-Type", string(encodingTextCsv))
+	req.Header.Set("Accept", string(encodingTextCsv))
+	rsp := httptest.NewRecorder()
+
+	ApiHandler{}.serveBatch(sess, rsp, req)
+
+	if rsp.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d: %s", rsp.Code, rsp.Body.String())
+	}
+	expected := "index,op,text,cursors,error\n" +
+		"0,putcursors,,,\n" +
+		"1,getcursors,,4 8,\n" +
+		"2,getbody,\"one two three\n\",,\n"
+	if rsp.Body.String() != expected {
+		t.Fatalf("expected the response\n%q\nbut got\n%q", expected, rsp.Body.String())
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"image/color"
 	"image/gif"
@@ -40,6 +41,10 @@ type blockStyle struct {
 	ErrorBgColor      color.NRGBA
 	ErrorFlashBgColor color.NRGBA
 	PathBasenameColor color.NRGBA
+	EditorAreaColor   color.NRGBA
+	LozengeColor      color.NRGBA
+	// Plain is true if only the basename of the path is colored in a tag.
+	Plain bool
 }
 
 func (t *blockEditable) Init(style blockStyle, editableStyle editableStyle, scheduler *Scheduler) {
@@ -64,8 +69,8 @@ func (t *blockEditable) HandleEvents(gtx layout.Context) {
 		pf := pointer.Filter{
 			Target:  t,
 			Kinds:   pointer.Press | pointer.Drag | pointer.Release | pointer.Scroll,
-			ScrollX: pointer.ScrollRange{-100, -100},
-			ScrollY: pointer.ScrollRange{100, 100},
+			ScrollX: pointer.ScrollRange{-100, 100},
+			ScrollY: pointer.ScrollRange{-100, 100},
 		}
 
 		// Since no keys are specified, this matches events for all keys (a catch-all)
@@ -87,11 +92,19 @@ func (t *blockEditable) HandleEvents(gtx layout.Context) {
 
 		switch e := ev.(type) {
 		case pointer.Event:
+			if e.Kind == pointer.Press {
+				t.adapter.noteUserInteraction()
+			}
 			t.Pointer(gtx, &e)
 		case key.Event:
-			t.Key(gtx, &e)
+			t.adapter.noteUserInteraction()
+			editor.Macros.keyPressed(&e, func() {
+				t.restoreCursorsIfModificationRefused(func() { t.Key(gtx, &e) })
+			})
 		case key.EditEvent:
-			t.InsertText(e.Text)
+			t.adapter.noteUserInteraction()
+			editor.Macros.textInserted(e.Text)
+			t.restoreCursorsIfModificationRefused(func() { t.InsertText(e.Text) })
 		case key.FocusEvent:
 			/*action := "set to"
 			  if !e.Focus {
@@ -102,7 +115,7 @@ func (t *blockEditable) HandleEvents(gtx layout.Context) {
 		case transfer.DataEvent:
 			// Clipboard
 			data := e.Open()
-			t.readTextFromClipboard(data)
+			t.restoreCursorsIfModificationRefused(func() { t.readTextFromClipboard(data) })
 			data.Close()
 		}
 	}
@@ -116,11 +129,6 @@ func (t *blockEditable) readTextFromClipboard(data io.ReadCloser) {
 		return
 	}
 
-	var buf bytes.Buffer
-	for _, t := range editor.LastSelectionsWrittenToClipboard() {
-		buf.WriteString(t)
-	}
-
 	text := string(b)
 	if t.attemptBlockPaste(text) {
 		return
@@ -164,8 +172,8 @@ func (t *blockEditable) drawMaximized(gtx layout.Context) layout.Dimensions {
 }
 
 func (t *blockEditable) drawBackground(gtx layout.Context) {
-	if t.bgimage.img == nil {
-		paint.ColorOp{Color: t.bgcolor}.Add(gtx.Ops)
+	if t.bgimage.img == nil || t.flashed {
+		paint.ColorOp{Color: t.drawnBgColor()}.Add(gtx.Ops)
 		paint.PaintOp{}.Add(gtx.Ops)
 		return
 	}
@@ -224,6 +232,27 @@ func fixLineEndings(s string) string {
 	return strings.ReplaceAll(s, "\r\n", "\n")
 }
 
+func (t *blockEditable) drawnBgColor() color.NRGBA {
+	if t.flashed {
+		return color.NRGBA(t.editable.style.FgColor)
+	}
+	return t.bgcolor
+}
+
+// flashBriefly draws the block with its text and background colors swapped for d. It doesn't
+// change bgcolor, which Tag.SetFlash sets to flash the errors windows, so the two flashes don't
+// interfere.
+func (t *blockEditable) flashBriefly(d time.Duration) {
+	if t.flashed || t.Scheduler == nil {
+		return
+	}
+	t.flashed = true
+	t.flashFgColor = Color(t.bgcolor)
+	t.schedule(fmt.Sprintf("flash-%p", t), d, func() {
+		t.flashed = false
+	})
+}
+
 func (t *blockEditable) SetStyle(style blockStyle, editableStyle editableStyle) {
 	t.style = style
 	t.editable.SetStyle(editableStyle)
@@ -10,6 +10,12 @@ import (
 type Body struct {
 	blockEditable
 	syntaxStyle SyntaxStyle
+	// presenter, if not nil, displays the body instead of the editable.
+	presenter BodyPresenter
+	// presenterText is the text passed to the presenter, kept until the text changes so that it
+	// isn't copied each frame. presenterTextGeneration is the generation it is from.
+	presenterText           []byte
+	presenterTextGeneration int
 }
 
 func (b *Body) Init(style blockStyle, editableStyle editableStyle, syntaxStyle SyntaxStyle, executor *CommandExecutor, finder *FileFinder, owner interface{}, workChan chan Work) {
@@ -58,6 +64,9 @@ func (b *Body) DisableSyntax() {
 }
 
 func (b *Body) layout(gtx layout.Context) layout.Dimensions {
+	if b.presenter != nil {
+		return b.layoutWithPresenter(gtx)
+	}
 	b.blockEditable.maximize = true
 	return b.blockEditable.layout(gtx)
 }
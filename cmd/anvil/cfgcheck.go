@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// configError is an error in a config file. Line and Col are the position of the error counting
+// from 1, or 0 if it is not known.
+type configError struct {
+	File      string
+	Line, Col int
+	Msg       string
+}
+
+func (e configError) Error() string {
+	var buf strings.Builder
+	if e.File != "" {
+		buf.WriteString(e.File)
+		buf.WriteString(":")
+	}
+	if e.Line > 0 {
+		fmt.Fprintf(&buf, "%d:", e.Line)
+		if e.Col > 0 {
+			fmt.Fprintf(&buf, "%d:", e.Col)
+		}
+	}
+	if buf.Len() > 0 {
+		buf.WriteString(" ")
+	}
+	buf.WriteString(e.Msg)
+	return buf.String()
+}
+
+// configErrors is the list of errors found in a config file.
+type configErrors []configError
+
+func (e configErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, ce := range e {
+		msgs[i] = ce.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// withConfigFile sets the file of the config errors in err to file, and returns err as
+// configErrors. Errors that are not config errors are converted to one without a position.
+func withConfigFile(err error, file string) configErrors {
+	var errs configErrors
+	var ce configError
+	switch {
+	case errors.As(err, &errs):
+	case errors.As(err, &ce):
+		errs = configErrors{ce}
+	default:
+		errs = configErrors{{Msg: err.Error()}}
+	}
+
+	r := make(configErrors, len(errs))
+	for i, e := range errs {
+		e.File = file
+		r[i] = e
+	}
+	return r
+}
+
+// lineAndCol returns the line and column, counting from 1, of the byte at offset in text.
+func lineAndCol(text []byte, offset int64) (line, col int) {
+	if offset > int64(len(text)) {
+		offset = int64(len(text))
+	}
+	before := text[:offset]
+	line = bytes.Count(before, []byte("\n")) + 1
+	col = len(before) - bytes.LastIndexByte(before, '\n')
+	return
+}
+
+// jsonConfigError converts an error from decoding the JSON text to a config error at the
+// position the decoder gives.
+func jsonConfigError(text []byte, err error) configError {
+	var offset int64 = -1
+
+	var synErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &synErr):
+		offset = synErr.Offset
+	case errors.As(err, &typeErr):
+		offset = typeErr.Offset
+	}
+
+	msg := strings.TrimPrefix(err.Error(), "json: ")
+	if offset < 0 {
+		return configError{Msg: msg}
+	}
+	line, col := lineAndCol(text, offset)
+	return configError{Line: line, Col: col, Msg: msg}
+}
+
+var tomlErrorPosition = regexp.MustCompile(`^\((\d+), (\d+)\): (.*)$`)
+
+// tomlConfigError converts an error from parsing TOML, which starts with the position of the
+// error like "(3, 7): ", to a config error.
+func tomlConfigError(err error) configError {
+	m := tomlErrorPosition.FindStringSubmatch(err.Error())
+	if m == nil {
+		return configError{Msg: err.Error()}
+	}
+	line, _ := strconv.Atoi(m[1])
+	col, _ := strconv.Atoi(m[2])
+	return configError{Line: line, Col: col, Msg: m[3]}
+}
+
+var jsonUnknownField = regexp.MustCompile(`^json: unknown field "(.*)"$`)
+
+// checkStyleFile checks the style config file at path, including for fields that are not part
+// of the style, which are ignored when it is loaded.
+func checkStyleFile(path string) configErrors {
+	if _, err := ReadStyle(path, &WindowStyle); err != nil {
+		return withConfigFile(err, path)
+	}
+
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return withConfigFile(err, path)
+	}
+
+	var s Style
+	dec := json.NewDecoder(bytes.NewReader(text))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&s)
+	if err == nil {
+		return nil
+	}
+
+	ce := configError{File: path, Msg: err.Error()}
+	if m := jsonUnknownField.FindStringSubmatch(err.Error()); m != nil {
+		ce.Msg = fmt.Sprintf("unknown field %q is ignored", m[1])
+		if i := bytes.Index(text, []byte(strconv.Quote(m[1]))); i >= 0 {
+			ce.Line, ce.Col = lineAndCol(text, int64(i))
+		}
+	}
+	return configErrors{ce}
+}
+
+var tomlUndecodedKey = regexp.MustCompile(`"(?:[^"\\]|\\.)*"`)
+
+// checkSettingsFile checks the settings config file at path, including for keys that are not
+// settings, which are ignored when it is loaded.
+func checkSettingsFile(path string) configErrors {
+	text, err := os.ReadFile(path)
+	if err != nil {
+		return withConfigFile(err, path)
+	}
+
+	var s Settings
+	if err := decodeSettings(text, &s); err != nil {
+		return withConfigFile(err, path)
+	}
+
+	s = Settings{}
+	err = toml.NewDecoder(bytes.NewReader(text)).Strict(true).Decode(&s)
+	if err == nil || !strings.HasPrefix(err.Error(), "undecoded keys: ") {
+		return nil
+	}
+
+	tree, _ := toml.LoadBytes(text)
+	var errs configErrors
+	for _, q := range tomlUndecodedKey.FindAllString(err.Error(), -1) {
+		key, _ := strconv.Unquote(q)
+		ce := configError{File: path, Msg: fmt.Sprintf("unknown setting %q is ignored", key)}
+		if tree != nil {
+			pos := tree.GetPosition(key)
+			ce.Line, ce.Col = pos.Line, pos.Col
+		}
+		errs = append(errs, ce)
+	}
+	return errs
+}
+
+// checkConfigFiles checks the settings, style and plumbing config files and returns a report
+// for +Errors listing each error found with its file and position.
+func checkConfigFiles() string {
+	files := []struct {
+		path  string
+		check func(path string) configErrors
+	}{
+		{SettingsConfigFile(), checkSettingsFile},
+		{StyleConfigFile(), checkStyleFile},
+		{PlumbingConfigFile(), func(path string) configErrors {
+			if _, err := LoadPlumbingRulesFromFile(path); err != nil {
+				return withConfigFile(err, path)
+			}
+			return nil
+		}},
+	}
+
+	var buf bytes.Buffer
+	for _, f := range files {
+		if _, err := os.Stat(f.path); errors.Is(err, os.ErrNotExist) {
+			fmt.Fprintf(&buf, "%s: not present, so the defaults are used\n", f.path)
+			continue
+		}
+
+		errs := f.check(f.path)
+		if len(errs) == 0 {
+			fmt.Fprintf(&buf, "%s: ok\n", f.path)
+			continue
+		}
+		for _, e := range errs {
+			fmt.Fprintf(&buf, "%s\n", e)
+		}
+	}
+	return buf.String()
+}
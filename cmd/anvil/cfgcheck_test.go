@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePlumbingRulesReportsEachError(t *testing.T) {
+	text := `# comment
+match ^a
+do echo a
+match (
+do echo b
+nonsense here
+match ^c
+match ^d
+do echo d
+match ^e
+`
+	_, err := ParsePlumbingRules(strings.NewReader(text))
+	errs, ok := err.(configErrors)
+	if !ok {
+		t.Fatalf("expected config errors but got %v", err)
+	}
+
+	var lines []int
+	for _, e := range errs {
+		lines = append(lines, e.Line)
+	}
+	// The bad regexp, the do without a match, the line that isn't match or do, and the two
+	// matches without a do.
+	expected := []int{4, 5, 6, 7, 10}
+	if !reflect.DeepEqual(lines, expected) {
+		t.Fatalf("expected errors on lines %v but got %v: %v", expected, lines, err)
+	}
+}
+
+func TestConfigErrorPositions(t *testing.T) {
+	dir := t.TempDir()
+
+	stylePath := filepath.Join(dir, "style.js")
+	if err := os.WriteFile(stylePath, []byte("{\n  \"TagFgColor\": \"#000000\",\n  \"TagBgColr\": \"#ffffff\"\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errs := checkStyleFile(stylePath)
+	if len(errs) != 1 || errs[0].Line != 3 || errs[0].Col != 3 || !strings.Contains(errs[0].Msg, "TagBgColr") {
+		t.Fatalf("expected the unknown style field to be reported at 3:3 but got %v", errs)
+	}
+
+	if err := os.WriteFile(stylePath, []byte("{\n  \"PlainTags\": 3\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errs = checkStyleFile(stylePath)
+	if len(errs) != 1 || errs[0].Line != 2 {
+		t.Fatalf("expected the bad style value to be reported on line 2 but got %v", errs)
+	}
+
+	settingsPath := filepath.Join(dir, "settings.toml")
+	if err := os.WriteFile(settingsPath, []byte("[general]\nspill-threshold=10\nspill-treshold=10\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errs = checkSettingsFile(settingsPath)
+	if len(errs) != 1 || errs[0].Line != 3 || !strings.Contains(errs[0].Msg, "general.spill-treshold") {
+		t.Fatalf("expected the unknown setting to be reported on line 3 but got %v", errs)
+	}
+
+	if err := os.WriteFile(settingsPath, []byte("[general]\nspill-threshold=10 x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	errs = checkSettingsFile(settingsPath)
+	if len(errs) != 1 || errs[0].Line != 2 || errs[0].File != settingsPath {
+		t.Fatalf("expected the syntax error to be reported on line 2 but got %v", errs)
+	}
+}
@@ -7,10 +7,13 @@ import (
 	"io/fs"
 	"os"
 	"os/exec"
+	"regexp"
 	"runtime"
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 	"unicode"
 	"unicode/utf8"
 
@@ -20,6 +23,7 @@ import (
 	"gioui.org/layout"
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/jeffwilliams/anvil/internal/escape"
+	"github.com/jeffwilliams/anvil/internal/textcase"
 )
 
 var cmdHistory = NewCommandHistory(100)
@@ -61,14 +65,33 @@ func (c *commandSet) Command(name string) (cmd command, ok bool) {
 	return
 }
 
+// Commands returns the commands in the set ordered by commandNameLess.
 func (c *commandSet) Commands() []command {
 	var l []command
 	for _, v := range c.commands {
 		l = append(l, v)
 	}
+	sort.Slice(l, func(i, j int) bool {
+		return commandNameLess(l[i].name, l[j].name)
+	})
 	return l
 }
 
+// commandNameLess orders command names alphabetically, except that names that don't start with a
+// letter, such as ◊, are ordered after the names that do.
+func commandNameLess(a, b string) bool {
+	la, lb := startsWithLetter(a), startsWithLetter(b)
+	if la != lb {
+		return la
+	}
+	return a < b
+}
+
+func startsWithLetter(s string) bool {
+	r, _ := utf8.DecodeRuneInString(s)
+	return unicode.IsLetter(r)
+}
+
 func NewCommandExecutor(source interface{}) *CommandExecutor {
 	ex := &CommandExecutor{
 		source: source,
@@ -91,28 +114,84 @@ func (c *CommandExecutor) initToplevelCommands() {
 	addCommand("Del", c.CmdDel, "Delete Window", "Del closes the current window.")
 	addCommand("Del!", c.CmdDelForce, "Delete Window without prompt", "Del! closes the current window. If there are unsaved changes, the user is not prompted to save them.")
 	addCommand("Exit", c.CmdExit, "Exit the editor", "Exit exits the editor.")
-	addCommand("New", c.CmdNew, "Make a new window or open a path", "New makes a new window or with an argument opens a path. If a window for that file is already opened, a new window for that file is not created. Otherwise, the window is opened in the column with the most free space. If new is executed with an argument the file or directory with the name of the argument is loaded into the window.")
+	addCommand("New", c.CmdNew, "Make a new window or open a path", "New makes a new window or with an argument opens a path. If a window for that file is already opened, a new window for that file is not created. Otherwise, the window is opened in the column chosen by the window-placement setting, which by default is the column with the most free space. If new is executed with an argument the file or directory with the name of the argument is loaded into the window.")
+	addCommand("Alias", c.CmdAlias, "Define a command alias for the window", "Alias name command... defines an alias in the window it is executed in, so that executing name in the window executes the commands instead. "+
+		"Separate commands with semicolons. In the commands $1 to $9 are replaced with the arguments given to the alias and $* with all of them, as for the aliases in the settings. "+
+		"The aliases of a window are consulted before those in the settings, are shared with its clones made by Zerox, and are saved by Dump. With no arguments Alias lists the aliases of the window and those in the settings.")
+	addCommand("Alias-", c.CmdAliasDelete, "Delete a command alias of the window", "Alias- name... deletes the aliases of the window with the given names.")
+	addCommand("Snap", c.CmdSnap, "Checkpoint the window body", "Snap [name] records the current text of the window body as a checkpoint with the given name, or 'default', replacing any checkpoint with that name. Revert restores it. Checkpoints are cheap: they refer to the text the window already holds rather than copying it. They are shared with clones made by Zerox, are dropped when the window is closed, and are not saved by Dump.")
+	addCommand("Revert", c.CmdRevert, "Restore the window body to a checkpoint", "Revert [name] restores the text of the window body to the checkpoint made by Snap with the given name, or 'default', however many edits were made since. The revert is a single change that one Undo reverses. A checkpoint is only restored into a window holding the same file as when it was made.")
+	addCommand("Snaps", c.CmdSnaps, "List the checkpoints made by Snap", "Snaps lists the checkpoints of the window made by Snap, or of all windows when executed in the editor tag, with their sizes and ages.")
 	addCommand("Acq", c.CmdAcq, "Acquire a path", "Acq 'acquires' it's argument. It performs the same function as ALT+Right Click performs on a text object.")
+	addCommand("Openall", c.CmdOpenall, "Open every file listed in the selection or body", "Openall opens the file or directory named by each line of the selections in the window body, or of the whole body if there are no selections. Each line may end in a seek such as :line:col, as for Acq, and relative paths are relative to the directory of the window. Paths listed more than once are opened once. At most openall-max files are opened; the setting controls the limit. When done, the number of files opened and the lines that could not be opened are written to +Errors. The files are opened one at a time in the background; use Kill Openall to stop opening more files.")
 	addCommand("Newcol", c.CmdNewcol, "Create a column", "Newcol creates a new column.")
-	addCommand("Delcol", c.CmdDelcol, "Delete the column", "Delcol deletes the column in which it is executed.")
+	addCommand("Delcol", c.CmdDelcol, "Delete the column", "Delcol deletes the column in which it is executed. If an argument is passed, it deletes the column with that name or index as listed by Cols instead, so it can be executed anywhere.")
+	addCommand("Diff", c.CmdDiff, "Show the changes to the window since it was saved", "Diff compares the body of the window with its file as it is on disk and shows the differences as a unified diff in the +Diff window of the directory. The header of each hunk ends with the path and line of the hunk in the body, which can be acquired to go to it. Remote files are read over ssh. Files and bodies larger than 8 MB are not compared.")
+	addCommand("Moveto", c.CmdMoveto, "Move the window to another column", "Moveto moves the window it is executed in to the bottom of another column, keeping its contents, selections and scroll position. The argument is either the number of the column as listed by Cols, which counts hidden columns, or the name of the column, which is the first word of its tag. A hidden column is shown. A number past the last column moves the window to the last column and says so in +Errors. The column the window was in is kept even if it becomes empty.")
+	addCommand("Up", c.CmdUp, "Move the window up one position in its column", "Up moves the window it is executed in above the window before it in its column. The windows keep their heights. If the window is already at the top of the column it says so in +Errors.")
+	addCommand("Down", c.CmdDown, "Move the window down one position in its column", "Down moves the window it is executed in below the window after it in its column. The windows keep their heights. If the window is already at the bottom of the column it says so in +Errors.")
+	addCommand("Promote", c.CmdPromote, "Move the window to the top of its column", "Promote moves the window it is executed in to the top of its column. The windows keep their heights.")
 	addCommand("Cut", c.CmdCut, "Cut selected text", "Cut deletes the last selected text and it to the clipboard.")
 	addCommand("Snarf", c.CmdSnarf, "Copy selected text", "Snarf copies the last selected text to the clipboard.")
 	addCommand("Id", c.CmdId, "Show window ID", "Id prints the window ID to the +Errors window. Useful when using the API.")
 	addCommand("Paste", c.CmdPaste, "Paste text", "Paste writes the text from the clipboard to the window.")
+	addCommand("Path", c.CmdPath, "Show the full path in the tag", "When the tag-path-abbreviation setting is set, long paths in window tags are drawn shortened. Path shows the full path in the tag of the window it is executed in, or shortens it again if the full path is already shown. With the argument 'full' it always shows the full path, and with 'short' it always shortens it. The tag holds the full path either way.")
+	addCommand("Slots", c.CmdSlots, "List or paste the clipboard slots", "When more than one selection is cut or copied at once, the text of each selection is kept in a numbered clipboard slot, in the order the selections appear in the text. The slots are only replaced when more than one selection is cut or copied again. "+
+		"With no arguments Slots lists the slots with a preview of each. With the argument 'paste' it pastes the first slot at the first cursor, the second at the second, and so on; with 'rotate' it starts with the second slot, so that pasting over two selections copied together swaps them; with a slot number it pastes that slot at every cursor. "+
+		"If there are selections the pasted slots replace them instead of being inserted at the cursors. When there are more cursors than slots the slots are used again from the first. "+
+		"Ctrl+Shift+V is the same as 'Slots paste' and Ctrl+Alt+V is the same as 'Slots rotate'.")
 	addCommand("Put", c.CmdPut, "Save the window body", "Put writes the contents of the window body to the path that is the leftmost text in the window tag.")
+	addCommand("Enc", c.CmdEnc, "Show or set the encoding and line endings of the window's file", "Enc with no arguments shows the character encoding and line endings of the file in the window in +Errors. They are detected when the file is loaded, and the body is kept as UTF-8 with newline line endings and converted back when it is Put. With arguments Enc sets them instead: a charset (utf-8, utf-16le or utf-16be), bom or nobom to add or remove a byte order mark, and crlf or lf. Put then writes the file in the new encoding and Get reads it in that encoding.")
+	addCommand("Edit-anyway", c.CmdEditAnyway, "Allow changing the body of a window whose file is not writable", "When the file in a window can't be written by the user the window is marked with "+notWritableTagMarker+" in the tag and changes to the body are refused. Edit-anyway allows the body to be changed, although Put will still fail unless the permissions of the file change.")
 	addCommand("Get", c.CmdGet, "Load the window body", "Get reads the contents of the path that is the leftmost text in the window tag and replaces the window body contents with it.")
-	addCommand("Kill", c.CmdKill, "Kill a running job", "Kill kills all the jobs that are currently running that have names matching the arguments to the Kill command. If no argument is provided the first job is killed")
+	addCommand("Peek", c.CmdPeek, "Show the lines around the target of a file name", "Peek shows the lines around the place that its argument, or the file name under the cursor in the window body, refers to, over the window body without opening a window. The file name can include a line, a #rune position or a !regex like one acquired with the mouse, and can be remote. The lines are shown until the next keypress or click; Escape only dismisses them.")
+	addCommand("Jobs", c.CmdJobs, "List the running jobs", "Jobs lists the running jobs in +Errors, one per line, giving the id of each, its name and how long it has been running. The id can be passed to Kill as #id to kill one of several jobs with the same name.")
+	addCommand("Kill", c.CmdKill, "Kill a running job", "Kill kills all the jobs that are currently running that have names matching the arguments to the Kill command. An argument of # followed by a number, as in Kill #3, kills the job with that id as listed by Jobs. If no argument is provided the first job is killed. Killing a job started with < closes its stdin. The output of a command ends with a line saying how it ended and how long it ran. A job that doesn't stop within a few seconds of being killed is removed anyway.")
+	addCommand("Send", c.CmdSend, "Send text to the stdin of the job started with < in the window", "Send writes the selections in the window body, or the line containing the cursor if there are no selections, followed by a newline to the stdin of the job that was started in the window using <. If arguments are given they are sent instead. The stdin of a job started with < stays open until the job finishes or is killed. If more than one such job is started in the window Send writes to the most recently started one, and the earlier jobs no longer receive text from Send. Jobs started with | instead receive the selection followed by the end of input. If no job started with < is running in the window, a Send command registered using the API by a tool such as awin is executed instead.")
 	addCommand("Look", c.CmdLook, "Look for a string in the window body", "Look searches for the next string in the window body that exactly matches the argument to Look.")
+	addCommand("Lookall", c.CmdLookall, "Look for a string in all open windows", "Lookall searches the bodies of all open windows for the argument to Lookall, which is a regular expression if it is surrounded by slashes as in Lookall /re/ and is otherwise matched exactly. With -dirty as the first argument only windows with unsaved changes are searched. Each match is written to +Errors as path:line:col and highlighted in its window for a while. The windows are searched in the background and their matches are listed as each is searched; use Kill Lookall to stop.")
 	addCommand("Keypass", c.CmdKeyPassword, "Specify the password used to decrypt an ssh private key file or log into a host", "Keypass is used to specify the password used to decrypt an ssh private key file. It takes two arguments: the first is the ssh filename and the second is the password. This is needed when an ssh private key file is encrypted and ssh-agent is not being used.")
 	addCommand("Hostpass", c.CmdHostPassword, "Specify the password used to log into an ssh server", "Hostpass is used to specify the password used to log into an ssh server. It takes between two and four arguments. The first argument is the password. The second argument is the hostname or IP address of the server. The third argument is the username for the server; if not specified the current user's name is used. The fourth argument is the TCP port number for the server; if not specified 22 is used.")
-	addCommand("Zerox", c.CmdZerox, "Clone a window", "Zerox opens a second window which is a copy of the current window")
+	addCommand("Sshclr", c.CmdSshclr, "Drop cached ssh connections", "Sshclr closes the cached ssh connections to the host given as the argument, or all of them if there is no argument. Operations still using them fail, and the next use of a remote path connects again. Connections that stop answering keepalive probes are dropped automatically; this is for when that hasn't happened yet.")
+	addCommand("Focusnext", c.CmdFocusnext, "Move the keyboard focus to the next window in the column", "Focusnext moves the keyboard focus to the body of the window below the current window in its column, wrapping around to the top window. Windows made by Zerox count as separate windows. When executed in a column tag it focuses the first window of the column. It is bound to ctrl+alt+down by default; see the keys section of the settings.")
+	addCommand("Focusprev", c.CmdFocusprev, "Move the keyboard focus to the previous window in the column", "Focusprev moves the keyboard focus to the body of the window above the current window in its column, wrapping around to the bottom window. When executed in a column tag it focuses the last window of the column. It is bound to ctrl+alt+up by default.")
+	addCommand("Focusnextcol", c.CmdFocusnextcol, "Move the keyboard focus to the column to the right", "Focusnextcol moves the keyboard focus to the body of the window beside the current window in the next visible column to the right, wrapping around to the leftmost column. It is bound to ctrl+alt+right by default.")
+	addCommand("Focusprevcol", c.CmdFocusprevcol, "Move the keyboard focus to the column to the left", "Focusprevcol moves the keyboard focus to the body of the window beside the current window in the next visible column to the left, wrapping around to the rightmost column. It is bound to ctrl+alt+left by default.")
+	addCommand("Focustag", c.CmdFocustag, "Move the keyboard focus between the tag and body of the window", "Focustag moves the keyboard focus from the body of the current window to its tag, or from the tag to the body. It is bound to ctrl+alt+enter by default.")
+	addCommand("Zerox", c.CmdZerox, "Clone a window", "Zerox opens a second window which is a copy of the current window. With an argument that is a column number as listed by Cols, or the name of a column, the copy is opened in that column, which is shown if it is hidden. With the argument -follow the copy scrolls along with the current window so that both show the same text, until the copy is scrolled itself.")
+	addCommand("Tutorial", c.CmdTutorial, "Practice using Anvil in guided lessons", "Tutorial shows a lesson on using Anvil in a new window, with text to practice on. When the lesson has been done the window is replaced by the next lesson. "+
+		"The lessons cover executing text, searching, acquiring files, multiple cursors, expressions and tags. The number of the lesson reached is kept in the configuration directory, so executing Tutorial again resumes the tutorial. "+
+		"With the argument 'restart' the tutorial starts again from the first lesson, with a lesson number it starts at that lesson, and with 'stop' it stops watching the lesson window.")
 	addCommand("Title", c.CmdTitle, "Set the editor title", "Title sets the title of the editor to it's combined arguments. The title is usually displayed by the OS window manager in the title bar.")
 	addCommand("Syn", c.CmdSyntax, "Enable or disable syntax highlighting, or list supported formats", "Syntax is used to control syntax highlighting for the current window. With the argument 'off' it disables syntax highlighting, and with the argument 'list' it lists the valid supported languages. With any other argument it enables syntax highlighting and highlights the body using the language named by the argument. With no argument it attempts to analyze the text to autodetect the language.")
+	addCommand("Lintws", c.CmdLintws, "Report whitespace problems, or hide or show whitespace hints", "Lintws reports whether the file in the window mixes tabs and spaces for indentation and whether it lacks a newline at the end. With the argument 'off' it hides the whitespace hints in the window, and with the argument 'on' it shows them. The hints are a struck-through return symbol after the last character of a file that doesn't end with a newline, and a faint tint over the indentation of lines indented with whichever of tabs and spaces is less common in the file. In Makefiles, lines indented with tabs are never tinted. The hints can be disabled for all windows with the whitespace-hints setting.")
+	addCommand("Sensitive", c.CmdSensitive, "Mark the window as containing sensitive content", "Sensitive controls whether the window is treated as containing sensitive content such as credentials. The body of a sensitive window is hidden when the editor loses focus or after sensitive-idle-timeout seconds without a key press or click in the window, and shown again on the next one. A sensitive window's body is not saved by Dump, and may only be read through the API by commands run from that window. Windows for files matching the sensitive-patterns setting are sensitive when opened. With the argument 'on' the window is made sensitive, with 'off' it is not, and with no argument it is toggled.")
+	addCommand("Present", c.CmdPresent, "Change how the window body is displayed", "Present changes how the body of the window is displayed to the presenter named by the argument. The 'text' presenter is the normal editable text, and the 'hex' presenter displays the body as a read-only hex dump in which the arrow keys, Page Up, Page Down, Home and End move the selected byte. While the body is displayed by a presenter that doesn't allow editing, changes to the body are refused. With no argument Present lists the presenters and the one in use.")
+	addCommand("Wrap", c.CmdWrap, "Enable or disable wrapping long lines", "Wrap controls whether long lines in the window body are wrapped. With the argument 'on' long lines are wrapped, and with the argument 'off' they are not and the body can instead be scrolled horizontally using Shift and the scroll wheel. With no argument it toggles wrapping.")
+	addCommand("Ws", c.CmdWs, "Show trailing whitespace and tabs", "Ws controls whether whitespace is shown in the window body. With the argument 'on' whitespace at the end of lines is drawn with a background color, and with the argument 'tabs' tabs are also drawn with a faint guide glyph. With the argument 'off' whitespace is not shown, and with no argument it toggles between 'on' and 'off'. The colors are set by the TrailingWhitespaceBgColor and TabGuideColor style fields.")
+	addCommand("Guide", c.CmdGuide, "Show a line-length guide", "Guide draws a vertical line behind the text of the window body at the column given as the argument, measured in widths of a space, as a guide to the length of lines. With the argument 'off' the guide is removed, and with no argument it toggles a guide at column 72. The color is set by the GuideColor style field.")
+	addCommand("Fmt", c.CmdFmt, "Re-wrap paragraphs", "Fmt re-wraps the lines of each selection in the window body, or the paragraph at each cursor if there are no selections, so that no line is longer than the width given as the argument. Without an argument the width is the column of the guide set by Guide, or 72 if there is none. Paragraphs are separated by blank lines, and the indentation and any comment or quote markers like '# ', '// ' or '> ' at the start of a paragraph are kept at the start of each of its lines. The lines after the first line of a list item are indented to line up with its text. The changes are undone together.")
+	addCommand("Nexterr", c.CmdNexterr, "Go to the next diagnostic in +Errors", "Nexterr finds the lines in the +Errors window that name a position in a file, like the path:line:col: message lines written by compilers, and shows the file of the one after the last one visited with the position selected. It wraps around to the first after the last. When run from a window other than an +Errors window, the +Errors window for the directory of the window is used. With an argument such as 'error' or 'warning' only the diagnostics with that severity are visited. The lines are found using the error-patterns setting.")
+	addCommand("Preverr", c.CmdPreverr, "Go to the previous diagnostic in +Errors", "Preverr is like Nexterr but goes to the diagnostic before the last one visited, wrapping around to the last after the first.")
+	addCommand("Etabs", c.CmdEtabs, "Use elastic tab stops", "Etabs controls whether the window body uses elastic tab stops. With elastic tab stops the text between tabs on adjacent lines is lined up in columns, each as wide as its widest cell, instead of the tabs moving to the next multiple of the tab width. This lines up tables and aligned code written with a proportional font. The columns are found in the lines on the screen and the 200 lines before and after them. With the argument 'on' elastic tab stops are used, with 'off' they are not, and with no argument it toggles between them.")
+	addCommand("Cd", c.CmdCd, "Set the directory commands in the window run in", "Cd dir makes the commands executed from the window run in the directory dir instead of the directory of the window's file. The directory is also used for the ANVIL_WIN_GLOBAL_DIR and ANVIL_WIN_LOCAL_DIR environment variables and for the +Errors window the output goes to. A relative directory is relative to the one commands run in now, and a directory on a remote host can be given as host:dir. Cd with no argument shows the directory commands run in, and Cd - goes back to using the directory of the file. The directory is kept when the file is loaded again with Get and is saved by Dump.")
+	addCommand("Tagsrc", c.CmdTagsrc, "Set whether completion uses tags files", "Tagsrc controls whether word completion in the window completes the names in the tags files, like those written by ctags, found in the directory of the window and the directories above it. Completions from tags files are listed with the source 'tags'. With the argument 'on' they are used, with 'off' they are not, which helps in projects with a lot of generated code, and with no argument it toggles between them. The tags setting in the completion section turns them off for all windows.")
+	addCommand("Scrollcursor", c.CmdScrollcursor, "Set whether scrolling moves the cursor", "Scrollcursor controls whether scrolling the window body moves the cursor onto the nearest visible line so that it stays on the screen. "+
+		"The cursor is only moved when there is one cursor and no selections. With the argument 'on' scrolling moves the cursor, and with the argument 'off' it doesn't. With no argument it toggles. "+
+		"Windows start with the scroll-moves-cursor setting.")
 	addCommand("Ansi", c.CmdAnsi, "Enable or disable Ansi colors", "Ansi is used to control whether Ansi terminal color escape sequences cause coloring or not. With no argument or the 'on' it enables coloring. With the argument 'off' it disables coloring.")
 	addCommand("Dump", c.CmdDump, "Save the editor's state to disk", fmt.Sprintf("Dump saves the editor's state to disk: the size of the open windows and the current value of their tags. With an argument the state is written to the file named by the argument. With no argument state is written to the file %s.dump. The state can be loaded using Load", editorName))
 	addCommand("Load", c.CmdLoad, "Load the editor's state from disk", fmt.Sprintf("Load loads the editor's state from disk as written by the Dump command. With an argument the state is read from the file named by the argument. With no argument state is read from the file %s.dump", editorName))
-	addCommand("Putall", c.CmdPutall, "Save all windows", "Putall executes a Put on all open windows, saving all windows.")
+	addCommand("Putall", c.CmdPutall, "Save all windows", "Putall executes a Put on all windows holding files with unsaved changes. Windows whose files don't exist yet are skipped unless the -create argument is given. With the argument -n nothing is saved, and the windows that would be saved are listed with their paths and sizes. A summary of the windows saved, skipped and that failed to save is appended to +Errors.")
+	addCommand("Recovery", c.CmdRecovery, "Recover unsaved changes saved by autosave", "Every autosave-interval seconds the text of windows with unsaved changes is saved to the recovery directory "+RecoveryDir()+", and the saved text is deleted when the file is Put or its window is closed. Sensitive windows are not saved. If Anvil stops without the changes being saved, the files are listed in a +Recovery window the next time it starts. "+
+		"With no arguments Recovery shows the +Recovery window. 'Recovery open FILE' opens the file and, in the same column, the text saved for it. 'Recovery clear' deletes the text saved by earlier sessions. Exiting using Exit deletes the text saved during the session.")
 	addCommand("Recent", c.CmdRecent, "Display recent files", "Recent writes the list of most recently closed files to the Errors window.")
+	addCommand("Reopen", c.CmdReopen, "Reopen the file closed last", "Reopen loads the file whose window was closed most recently into a new window, with the cursor where it was when the window was closed. "+
+		"Running it again reopens the file closed before that one, and so on. The list of closed files is kept in the config directory so it outlives the editor; "+
+		"its length is set by the closed-files-max setting. It can be read through the API at /recent.")
+	addCommand("Macro", c.CmdMacro, "Record and play keyboard macros", "Macro record starts recording the text typed, the editing keys pressed and the commands executed. Macro stop stops recording. "+
+		"Macro play replays the recorded macro in the editable that has the keyboard focus; if a number is given as an argument it is replayed that many times. "+
+		"Each replay is undone as a single change. Macro with no arguments lists the steps of the recorded macro. The recorded macro is saved by Dump.")
 	addCommand("Mark", c.CmdMark, "Add a bookmark", "Mark saves the current cursor position in the window body with the name specified by the argument. If no argument is given it is saved with the name 'def'.")
 	addCommand("Goto", c.CmdGoto, "Jump to a bookmark", "Goto sets the current cursor position in the window body to the named bookmark, created by Mark. If no argument is given it jumps to the bookmark 'def'.")
 	addCommand("Marks", c.CmdMarks, "Display bookmarks", "Marks displays the currently set bookmarks to the Errors window.")
@@ -120,20 +199,31 @@ func (c *CommandExecutor) initToplevelCommands() {
 	addCommand("SaveStyle", c.CmdSaveStyle, "Save current editor style", fmt.Sprintf("SaveStyle saves the editor style information to a file: the current font and size, colors, etc. With one argument the style is saved to the file named by the argument. With no argument it is saved to %s. When the editor is started the style file %s is loaded", StyleConfigFile(), StyleConfigFile()))
 	addCommand("LoadStyle", c.CmdLoadStyle, "Load editor style from file", fmt.Sprintf("LoadStyle loads the editor style information from a file: the current font and size, colors, etc. With one argument the style is loaded from the file named by the argument. With no argument it is loaded from %s. When the editor is started the style file %s is loaded", StyleConfigFile(), StyleConfigFile()))
 	addCommand("LoadPlumbing", c.CmdLoadPlumbing, "Load plumbing rules from file", fmt.Sprintf("LoadPlumbing loads the plumbing rules from a file. With one argument the plumbing is loaded from the file named by the argument. With no argument it is loaded from %s. When the editor is started the plumbing file %s is loaded", PlumbingConfigFile(), PlumbingConfigFile()))
-	addCommand("Help", c.CmdHelp, "Show help", "Help shows a bit of help for the editor. With no argument it lists the main commands and a brief description. With an argument displays information about that topic. The argument may be a command, which displays more detail about the command, or it may be another selected topic.")
+	addCommand("Plumb", c.CmdPlumb, "Plumb text using the plumbing rules", "Plumb tries the plumbing rules on its argument as if it was acquired, and executes the command of the first rule that matches in the context of the window. This is for trying out rules.")
+	addCommand("PlumbDry", c.CmdPlumbDry, "Show which plumbing rule matches text", "PlumbDry tries the plumbing rules on its argument and writes which rule matched first, with its line in the plumbing file, and the command it would execute to the Errors window without executing it.")
+	addCommand("Help", c.CmdHelp, "Show help", "Help shows a bit of help for the editor. With no argument it lists the main commands and a brief description, in alphabetical order with the commands that start with punctuation last, followed by the debug commands. With an argument displays information about that topic. The argument may be a command, which displays more detail about the command, or it may be another selected topic. With the argument -json the commands are written as a JSON array of objects with the fields Name, ShortHelp and Debug.")
 	addCommand("◊", c.CmdInsertLozenge, "Insert a ◊ rune, or surround selection with it", "If there are no selections, insert a ◊ rune at the cursor. If there are selections, insert a ◊ before and after each selection.")
+	addCommand("Upper", c.CmdUpper, "Convert text to upper case", "Upper converts the text in each selection, or the word at each cursor if there are no selections, to upper case. Letters without a single uppercase letter are expanded, so that ß becomes SS. "+caseCommandLocaleHelp)
+	addCommand("Lower", c.CmdLower, "Convert text to lower case", "Lower converts the text in each selection, or the word at each cursor if there are no selections, to lower case. "+caseCommandLocaleHelp)
+	addCommand("Titlecase", c.CmdTitlecase, "Capitalize each word", "Titlecase converts the first letter of each word in each selection, or the word at each cursor if there are no selections, to title case and the other letters to lower case. "+caseCommandLocaleHelp)
+	addCommand("Swapcase", c.CmdSwapcase, "Swap upper and lower case", "Swapcase converts the upper case letters in each selection, or the word at each cursor if there are no selections, to lower case and the lower case letters to upper case. "+caseCommandLocaleHelp)
+	addCommand("Snake", c.CmdSnake, "Convert identifiers to snake_case", "Snake converts the identifiers in each selection, or the identifier at each cursor if there are no selections, to snake_case. Identifiers may be in camelCase, snake_case or kebab-case, and runs of capitals are treated as one word so that parseHTTPResponse becomes parse_http_response. "+caseCommandLocaleHelp)
+	addCommand("Camel", c.CmdCamel, "Convert identifiers to camelCase", "Camel converts the identifiers in each selection, or the identifier at each cursor if there are no selections, to camelCase, so that parse_http_response becomes parseHttpResponse. "+caseCommandLocaleHelp)
+	addCommand("Kebab", c.CmdKebab, "Convert identifiers to kebab-case", "Kebab converts the identifiers in each selection, or the identifier at each cursor if there are no selections, to kebab-case, so that parseHTTPResponse becomes parse-http-response. "+caseCommandLocaleHelp)
 	addCommand("Rot", c.CmdRot, "Rotate selections", "Rot rotates the selections when there are multiple selections. The primary selection moves to the next selection, that one to the next and so on, with the last moving to the primary.")
+	addCommand("Swap", c.CmdSwap, "Exchange the text of two selections", "Swap exchanges the text of the two selections in the window body, such as two arguments of a function, as a single change that one Undo reverses. Each selection is kept around the text it now holds. There must be exactly two selections and they must not overlap.")
 	addCommand("Do", c.CmdDo, "Execute command", "Do executes it's arguments as a command; i.e. as if the arguments were selceted and executed alone. This is useful to execute commands from one window in the context of another window.")
-	addCommand("About", c.CmdAbout, "About the editor", "Print information about the editor, including where some files are expected to be located")
+	addCommand("About", c.CmdAbout, "About the editor", "Print information about the editor, including where some files are expected to be located. The cached SSH connections and the SSH hosts with passwords or settings are listed ordered by host, and the API sessions in the order they were created.")
 	addCommand("Font", c.CmdFont, "Change to next font", "Change to the next font defined in the styles")
 	addCommand("On", c.CmdOn, "Run command on remote host", "Run takes two or more arguments. The first is a host and directory (in the format host:directory) and the remaining arguments are the command and arguments to run.")
 	addCommand("Cmds", c.CmdCmds, "List the recent external commands", "List the most recent external commands executed")
 	addCommand("Cmds*", c.CmdCmdsVerbose, "List the recent external commands verbosely", "List the most recent external commands executed along with the directory they were executed in")
-	addCommand("Wins", c.CmdWins, "List the open windows", "List the filenames of the open windows")
+	addCommand("Wins", c.CmdWins, "List the open windows", "Wins lists the filenames of the open windows, ordered by column from left to right and within a column from top to bottom. With the argument -json the windows are written as a JSON array in the format used by the API for /wins.")
 	addCommand("Undo", c.CmdUndo, "Undo the last change", "Undo the last change")
 	addCommand("Redo", c.CmdRedo, "Redo the last change", "Redo the last change")
-	addCommand("PrintCfg", c.CmdPrintCfg, "Print a sample config file", "Print a sample config file to +Errors. The argument specifies the file to generate:\n  ◊PrintCfg settings.toml◊ generates a settings file\n")
-	addCommand("Only", c.CmdOnly, "Del other windows in this column", "When executed in a window or its tag, close the other windows in this column leaving only this window.")
+	addCommand("PrintCfg", c.CmdPrintCfg, "Print a sample config file", "Print a sample config file to +Errors. The argument specifies the file to generate:\n  ◊PrintCfg settings.toml◊ generates a settings file\n  ◊PrintCfg style◊ generates a style.js file holding the current style\n  ◊PrintCfg plumbing◊ generates a plumbing rules file\n")
+	addCommand("CheckCfg", c.CmdCheckCfg, "Check the config files for errors", "CheckCfg reads the settings.toml, style.js and plumbing files in the config directory again and lists each error found in +Errors with its file, line and column, so that they can be fixed without restarting. Settings and style fields that are not known, which are ignored when the files are loaded, are listed too. The files are only checked; Anvil keeps using the config it loaded.")
+	addCommand("Only", c.CmdOnly, "Del other windows in this column", "When executed in a window or its tag, close the other windows in this column leaving only this window. When executed in a column tag, or passed the name or index of a column as listed by Cols, hide the other columns leaving only that column visible.")
 	addCommand("Clr", c.CmdClr, "Clear (delete) the contents of the window body", "Clear (delete) the contents of the window body")
 	addCommand("Shstr", c.CmdShstr, "Set the 'Shell String' for the current window",
 		`When executed with one or more arguments, set the 'Shell String' for the current window: the template string that is used to build the command run on a remote system. It may contain these substitutions within braces:
@@ -148,17 +238,41 @@ When executed with no arguments, set the Shell String for the current window to
 `)
 
 	addCommand("Dbg", c.CmdDbg, "Internal debugging commands", c.dbgCommandLongHelp())
-	addCommand("Hidecol", c.CmdHideCol, "Hide the column", "Hidecol hides the current column.")
-	addCommand("Showcol", c.CmdShowCol, "Show a column", "Showcol makes the column with the name that matches the first argument visible. If no argument is passed, the first hidden column is made visible")
-	addCommand("Cols", c.CmdCols, "List columns", "Cols lists all the columns")
+	addCommand("Hidecol", c.CmdHideCol, "Hide the column", "Hidecol hides the current column. If an argument is passed, it hides the column with that name or index as listed by Cols instead.")
+	addCommand("Showcol", c.CmdShowCol, "Show a column", "Showcol makes the column with the name or index, as listed by Cols, given as the argument visible. If no argument is passed, the first hidden column is made visible")
+	addCommand("Colleft", c.CmdColleft, "Move the column one position to the left", "Colleft moves the column it is executed in to the left of the visible column before it. The columns keep their widths. When executed in the editor tag, or to move another column, the column is given as an argument by number as listed by Cols or by name, as for Moveto. If the column is already the leftmost it says so in +Errors.")
+	addCommand("Colright", c.CmdColright, "Move the column one position to the right", "Colright moves the column it is executed in to the right of the visible column after it. The columns keep their widths. When executed in the editor tag, or to move another column, the column is given as an argument by number as listed by Cols or by name, as for Moveto. If the column is already the rightmost it says so in +Errors.")
+	addCommand("Colwidth", c.CmdColwidth, "Set or show the width of the column", "Colwidth sets the width of the column in which it is executed to the percentage of the editor width given by the argument, such as 'Colwidth 70' or 'Colwidth 70%'. The remaining width is shared by the other visible columns in proportion to their current widths. With no argument it lists the percentage of the editor width taken by each visible column. The percentages are saved by Dump and restored by Load.")
+	addCommand("Cols", c.CmdCols, "List columns", "Cols lists all the columns with their indexes and names. Either can be passed to Delcol, Hidecol, Showcol and Only to name the column.")
 	addCommand("Cols*", c.CmdColsVerbose, "List columns verbosely", "Cols* lists all the columns verbosely (including the files in each column)")
 	addCommand("Tint", c.CmdTint, "Colorize selections", "Tint is used to color selections of text. When executed with the argument 'list' it shows the pre-defined tint colors. When executed with one argument that is not 'list', it changes the text in all current selections to that color. The argument must be a hex color code in the form #rrggbb or a color name. When executed with no argument and selections present, it removes the coloring for text that overlap the selections. When run with no arguments and no selections it clears all tinting.")
 	addCommand("Fuzz", c.CmdFuzz, "Perform a fuzzy search", `Fuzz performs a fuzzy search through the lines in the window body. The terms for the search are the arguments to the Fuzz command. The lines which match the search are written to a new window for the current directory with the suffix '+Live'.
 
-The Fuzz command is special in that it can be executed dynamically as you type the search terms. If you add the string '◊Fuzz ' to the tag, then as you type the arguments after the command the search is re-executed and the results updated in the +Live window. You can delimit the end of the search arguments using another ◊`)
+The Fuzz command is special in that it can be executed dynamically as you type the search terms. If you add the string '◊Fuzz ' to the tag, then as you type the arguments after the command the search is re-executed and the results updated in the +Live window. You can delimit the end of the search arguments using another ◊. While '◊Fuzz ' is in the tag the search is also re-executed shortly after the window body changes, so the +Live window stays up to date with a body that a command is appending to.`)
+	addCommand("Fuzzf", c.CmdFuzzf, "Perform a fuzzy search for files", "Fuzzf performs a fuzzy search through the paths of the files under the current directory. The terms for the search are the arguments to the Fuzzf command. The best matching paths are written to a window for the current directory with the suffix '+Live', where they may be acquired to open the files. The files are listed from an index of the project containing the directory, which is built in the background the first time it is needed and is refreshed as files change. The index skips .git directories and the files ignored by .gitignore files.")
 	addCommand("Pic", c.CmdPic, "Set background picture", "Pic sets the background picture for the window body. The first argument should be the name of a .png, .gif or .jpeg image. The second argument, if specified, specifies how to scale the image. If the second argument is the word 'fit', without quotes, the image is scaled to the size of the window width. If the second argument is a number followed by the % character (such as 50%) the image is scaled by that percentage.")
 	addCommand("Tab", c.CmdTab, "Set the string inserted when tab is pressed", "Tab sets the string that Anvil inserts when the tab key is pressed. With no argument, sets the tab key to insert the tab character. With one argument it sets the value to insert to that argument. The argument may be quoted with single-quotes, and may contain the escapes \\t, \\n, \\r, \\', \\\", or \\\\.\n\nFor example, to cause the tab insert four spaces, use: Tab '    '. To insert a tab use: Tab '\\t'.")
-	addCommand("Settag", c.CmdSettag, "Set tag", "Settag sets the tag of the current window when executed from a window body or tag, the tag of the current column when executed from a column tag, or the editor when executed from the editor tag. When executed for a window, only the user-editable area is set. This is meant to be used by programs using the API.\n\nThe argument may be quoted with single-quotes.")
+	addCommand("Tabwidth", c.CmdTabwidth, "Set the width of tabs in the window", "Tabwidth sets how many columns apart the tab stops in the window body are, where a column is the width of a space in the current font. With no argument the tab stop interval from the style is used. With the argument ? it reports the tab width, the string inserted when Tab is pressed, and what was guessed about the indentation of the file in the window to +Errors.\n\nWhen a file is loaded into a window Anvil guesses whether it is indented with tabs or spaces and how wide, and sets the tab width and the string inserted by Tab to match unless they were set using Tabwidth or Tab. This can be disabled using the guess-indentation setting.")
+	addCommand("Tidy", c.CmdTidy, "Format the body with the formatter for the window", "Tidy pipes the body of the window through its formatter and replaces the body with the output if the formatter succeeds, reporting the lines that changed to +Errors. The formatter is the one set for the window with Tidy, or the one for the extension of the file in the tidy settings. Put runs the formatter before saving the file.\n\nWith arguments, Tidy sets the formatter for the window to the arguments and runs it. Tidy off stops Put from running the formatter in the window, and Tidy on re-enables it.")
+	addCommand("Head", c.CmdHead, "Show the start of spilled output", "When the output of a command is too large it is written to a temporary file and the window only shows part of it. Head shows the start of the output.")
+	addCommand("Follow", c.CmdFollow, "Append data added to the window's file", "Follow controls whether the window follows its file like tail -f: the file is checked every second and data appended to it is appended to the window body. "+
+		"The window only scrolls to show the new data if the end of the body was visible. While following, the body can't be changed, and "+followTagMarker+" is shown in the tag. If the file shrinks, because it was truncated or rotated, following pauses until Get is executed. "+
+		"With the argument 'on' the window follows its file, with 'off' it doesn't, and with no argument following is toggled. Following is saved by Dump.")
+	addCommand("Sort", c.CmdSort, "Set the order of the entries in a directory window", "Sort lists the entries of the directory shown in the window again in the order named by the argument: 'name' sorts by name, 'mtime' lists the most recently modified entries first, and 'size' lists the largest entries first. The order is kept when the directory is loaded again using Get. With no argument it reports the current order. The default order is set by the dir-sort setting.")
+	addCommand("Touch", c.CmdTouch, "Create a file in a directory window and open it", "Touch creates an empty file for each argument in the directory shown in the window it is executed in, unless the file exists, and opens it. The names are relative to the directory, which may be remote. The listing of the directory is then loaded again and the new file is highlighted briefly. Errors are written to the +Errors window of the directory.")
+	addCommand("Mkdir", c.CmdMkdir, "Create a directory in a directory window", "Mkdir creates a directory for each argument in the directory shown in the window it is executed in. The names are relative to the directory, which may be remote. The listing is then loaded again and the new directory is highlighted briefly.")
+	addCommand("Rm", c.CmdRm, "Remove files from a directory window", "Rm removes the files or empty directories given as arguments from the directory shown in the window it is executed in. Like deleting a window with unsaved changes, the first Rm only says what would be removed; executing Rm again with the same arguments removes them. The listing is then loaded again.")
+	addCommand("Mv", c.CmdMv, "Rename a file in a directory window", "Mv renames the file or directory that is the first argument to the second argument, in the directory shown in the window it is executed in. If the second argument is an existing directory the file is moved into it. Mv refuses to replace an existing file. The listing is then loaded again and the renamed file is highlighted briefly.")
+	addCommand("Dots", c.CmdDots, "Show or hide dotfiles in a directory window", "Dots controls whether entries starting with a dot are listed in the directory shown in the window. With the argument 'on' they are listed, with 'off' they are hidden, and with no argument it is toggled. The default is set by the dir-dots setting.")
+	addCommand("Tail", c.CmdTail, "Keep showing the end of output", "Tail controls whether output appended to the window, such as command output in +Errors, scrolls the window to the end. With the argument 'off' the window stays where it is as output arrives, and Tail is shown in the tag. With 'on' or no argument the window scrolls to the end and keeps showing the end as more output arrives. Pressing Enter at the very end of a +Errors window also turns tailing on. When the output of a command is too large it is written to a temporary file and the window only shows part of it; Tail then shows the end of the output.")
+	addCommand("Pgup", c.CmdPgup, "Show the previous page of spilled output", "When the output of a command is too large it is written to a temporary file and the window only shows part of it. Pgup shows the part of the output before the part currently shown.")
+	addCommand("Pgdn", c.CmdPgdn, "Show the next page of spilled output", "When the output of a command is too large it is written to a temporary file and the window only shows part of it. Pgdn shows the part of the output after the part currently shown.")
+	addCommand("Search", c.CmdSearch, "Search spilled output", "When the output of a command is too large it is written to a temporary file and the window only shows part of it. Search searches forward from the cursor or selection through the whole output for a line matching the regular expression that is the argument, and shows the part of the output containing the match. The regular expression may be surrounded by slashes, as in Search /re/.")
+	addCommand("Extract", c.CmdExtract, "Copy part of spilled output to a new window", "When the output of a command is too large it is written to a temporary file and the window only shows part of it. Extract copies the selected part of the output into a new window. With two arguments it copies the output between those two byte offsets instead.")
+	addCommand("Extract-to-file", c.CmdExtractToFile, "Save a file from inside an archive as a file of its own", "A file inside an archive, acquired using a path like vendor.tar.gz!pkg/file.go, is loaded into a read-only window and can't be saved with Put. Extract-to-file writes the text of the window to a file and changes the window to hold that file. With no argument the file has the same name as the file in the archive and is placed in the directory of the archive. An argument gives another name, relative to the directory of the archive. Existing files are not overwritten.")
+	addCommand("Notify", c.CmdNotify, "Ask for attention", "Notify asks for the user's attention if the Anvil window is not focused, in the same way as for the events listed in the notify section of the settings file. The arguments are used as the message for the notification command. Notify is useful at the end of a chain of commands in an alias, for example: build=\"make; Notify done\".")
+	addCommand("Find", c.CmdFind, "Search files for a regular expression", "Find searches the files under the current directory for lines matching the regular expression that is the first argument, and writes the matching lines to a window for the directory with the suffix '+Find'. The matched text in each line is highlighted, and each line begins with the file and line number of the match so that it may be acquired to open the file at that line. The regular expression may be surrounded by slashes, as in Find /re/, and uses the syntax of Go regular expressions even though the search is run using grep. Any further arguments are the files or directories to search instead of the current directory. When the current directory is remote the search is run on the remote host. Use Kill Find to stop a long search.")
+	addCommand("Settag", c.CmdSettag, "Set tag", "Settag sets the tag of the current window when executed from a window body or tag, the tag of the current column when executed from a column tag, or the editor when executed from the editor tag. When executed for a window, only the user-editable area is set, unless the first argument is -all in which case the whole tag is set, including the path and the editor commands. Changing the path this way changes the file the window holds, like renaming it. This is meant to be used by programs using the API.\n\nThe argument may be quoted with single-quotes.")
 }
 
 func (c *CommandExecutor) dbgCommandLongHelp() string {
@@ -183,7 +297,10 @@ func (c *CommandExecutor) initDebugCommands() {
 	addCommand("Goroutines", c.CmdGoroutines, "Print all goroutines", "Dbg Goroutines is a debug command. It writes all goroutine stacks to the errors window.")
 	addCommand("Logs", c.CmdDbgLogs, "Print internal debug logs", fmt.Sprintf("Dbg Logs displays internal debug logs to the +Errors window. With no arguments it writes logs from all categories. With one or more arguments only those categories are printed. The available categories are:\n  %s",
 		strings.Join(debugLogCategories, "\n  ")))
+	addCommand("Index", c.CmdDbgIndex, "Print the project file indexes", "Dbg Index lists the projects whose files are indexed for Fuzzf, Find and filename completion, with the number of files and directories in each index and how long the last refresh took.")
+	addCommand("Pctbl", c.CmdDbgPctbl, "Check the piece table of the window", "Dbg Pctbl check checks the invariants of the piece table that holds the text of the window body: that the lengths of the pieces add up to the length of the text, that the undo and redo records refer to valid parts of the buffers, and that no transaction was left open. Any violations are written to the +Errors window. Misuse of transactions as it happens is written to the Editable debug log, or panics when Anvil is built with the pctbldebug tag.")
 	addCommand("Pid", c.CmdDbgGetPid, "Print Anvil's PID", "Print the process ID of Anvil")
+	addCommand("Sched", c.CmdDbgSched, "Print the work queued for the main goroutine", "Dbg Sched lists the bands that work for the main goroutine is serviced in, from the interactive band that typing and pointing depend on down to the low band for file indexing and search output. For each band it shows how many items are queued now and the most that were queued, how many items were serviced, how long they waited and how long servicing them took over the last minute.")
 	addCommand("Psrv", c.CmdDbgPsrv, "Start the Go pprof debug server",
 		`This command starts the Go pprof debug http server [1] on localhost port 6060. This server can be used to debug Anvil performance. Once started, some useful URLs to browse are:
 
@@ -209,7 +326,29 @@ func (c CommandExecutor) Do(cmd string, ctx *CmdContext) {
 		return
 	}
 
+	var outputWindow string
+	var err error
+	ctx.Args, outputWindow, err = parseOutputRedirect(ctx.Args)
+	if err == nil && outputWindow != "" {
+		err = checkOutputRedirect(cmd)
+	}
+	if err != nil {
+		editor.AppendError(ctx.Dir, fmt.Sprintf("%s: %v", cmd, err))
+		return
+	}
+	if outputWindow != "" {
+		ctx.OutputWindow = outputWindow
+	}
+
 	log(LogCatgCmd, "CommandExecutor.Do: execute '%s', args %v\n", cmd, ctx.Args)
+	notifyApiNotificationObservers(newCommandApiNotification(c.sourceWindowId(), cmd, ctx.Args))
+
+	switch cmd[0] {
+	case '|', '<', '!':
+		if c.refuseIfBodyCantBeChanged(cmd) {
+			return
+		}
+	}
 
 	switch cmd[0] {
 	case '|':
@@ -233,6 +372,10 @@ func (c CommandExecutor) Do(cmd string, ctx *CmdContext) {
 
 	doer, ok := c.Command(cmd)
 	if ok {
+		if ctx.OutputWindow != "" {
+			editor.AppendError(ctx.Dir, fmt.Sprintf("%s: the output of built-in commands can't be redirected to a window", cmd))
+			return
+		}
 		doer.do(ctx)
 		return
 	}
@@ -273,6 +416,9 @@ type CmdContext struct {
 	Selections  []*selection
 	ShellString string
 	RawCommand  string
+	// OutputWindow is the name of the window that the output of shell commands goes to instead
+	// of +Errors, given as the last argument in the form >win:name.
+	OutputWindow string
 }
 
 func (c CmdContext) CombinedArgs() string {
@@ -339,6 +485,7 @@ func (c CommandExecutor) CmdExit(ctx *CmdContext) {
 	if someNotDeleted {
 		return
 	}
+	autosave.removeSession()
 	Exit(0)
 }
 
@@ -356,8 +503,8 @@ func (c CommandExecutor) CmdNew(ctx *CmdContext) {
 	if path != "" {
 		w = editor.FindWindowForFileAndDisplay(path)
 		if w != nil {
-			// TODO: Warp pointer to here
 			w.SetFocus(ctx.Gtx)
+			w.warpPointerToBody()
 			w.GrowIfBodyTooSmall()
 			return
 		}
@@ -382,6 +529,7 @@ func (c CommandExecutor) CmdNew(ctx *CmdContext) {
 
 	if path == "" {
 		w.SetFocus(ctx.Gtx)
+		w.warpPointerToBody()
 		return
 	}
 
@@ -405,6 +553,7 @@ func (c CommandExecutor) CmdNew(ctx *CmdContext) {
 	}
 
 	w.SetFocus(ctx.Gtx)
+	w.warpPointerToBody()
 }
 
 func (c CommandExecutor) globalizeAndMakeAbsolute(dir, path string) (fullpath string, err error) {
@@ -451,9 +600,11 @@ func (c CommandExecutor) CmdAcq(ctx *CmdContext) {
 		w.markTextAsUnchanged()
 		w.SetFilenameAndTag(path, typeFile)
 		w.SetFocus(ctx.Gtx)
+		w.warpPointerToBody()
 		return
 	}
 
+	HirePlumber()
 	if plumber != nil {
 		plumbed, err := plumber.Plumb(path, &c, ctx)
 		if err != nil {
@@ -482,6 +633,7 @@ func (c CommandExecutor) CmdAcq(ctx *CmdContext) {
 	w = editor.LoadFileOpts(realpath.String(), opts)
 	if w != nil {
 		w.SetFocus(ctx.Gtx)
+		w.warpPointerToBody()
 	}
 }
 
@@ -501,13 +653,26 @@ func (c CommandExecutor) column() *Col {
 	return col
 }
 
+// columnArg returns the column named by the arguments of the command, by name or index as for
+// Editor.FindCol, or if there are none the column the command was executed in.
+func (c CommandExecutor) columnArg(ctx *CmdContext) (*Col, error) {
+	if len(ctx.Args) > 0 {
+		return editor.FindCol(ctx.CombinedArgs())
+	}
+	if col := c.column(); col != nil {
+		return col, nil
+	}
+	return nil, fmt.Errorf("the name or index of a column is needed when not executed in a column or window")
+}
+
 func (c CommandExecutor) CmdDelcol(ctx *CmdContext) {
-	switch v := c.source.(type) {
-	case Col:
-	case *Col:
-		editor.markForRemoval(v)
-		editor.SignalRedrawRequired()
+	col, err := c.columnArg(ctx)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Delcol: %v", err))
+		return
 	}
+	editor.markForRemoval(col)
+	editor.SignalRedrawRequired()
 }
 
 func (c CommandExecutor) CmdNewcol(ctx *CmdContext) {
@@ -529,14 +694,16 @@ func setExitCodeInHistory(e *CommandHistoryEntry, c int) {
 }
 
 func (c CommandExecutor) tryApiUserDefinedCommand(ctx *CmdContext, command string) (handled bool) {
-	winId := -1
-	switch v := c.source.(type) {
-	case Window:
-	case *Window:
-		winId = v.Id
-	}
+	return apiHandleCommand(c.sourceWindowId(), command, ctx.Args)
+}
 
-	return apiHandleCommand(winId, command, ctx.Args)
+// sourceWindowId returns the id of the window the command is executed from, or -1 if it is
+// executed from a column or editor tag.
+func (c CommandExecutor) sourceWindowId() int {
+	if w, ok := c.source.(*Window); ok {
+		return w.Id
+	}
+	return -1
 }
 
 func printErrs(c chan error) (d chan error) {
@@ -631,10 +798,12 @@ func (c CommandExecutor) tryOsCmd(ctx *CmdContext, command string) {
 
 	wl := &WindowDataLoad{
 		DataLoad:          *load,
-		Win:               NewWindowHolderForName(editor.ErrorsFileNameOf(dir)),
-		Jobname:           command,
+		Win:               outputWindowHolder(dir, ctx.OutputWindow),
+		Jobname:           outputJobName(command, ctx.OutputWindow),
 		Tail:              true,
 		GrowBodyBehaviour: growBodyIfTooSmall,
+		SpillThreshold:    int64(settings.General.SpillThreshold),
+		ReportTermination: true,
 	}
 
 	wl.Start(editor.WorkChan())
@@ -643,7 +812,7 @@ func (c CommandExecutor) tryOsCmd(ctx *CmdContext, command string) {
 }
 
 func (c CommandExecutor) tryAlias(ctx *CmdContext, command string) (handled bool) {
-	alias, ok := settings.Alias[command]
+	alias, ok := c.alias(command)
 	if !ok {
 		return
 	}
@@ -764,6 +933,9 @@ func (c CommandExecutor) setExtraEnv(ctx *CmdContext, ex *execCtx) {
 		winGlobalDir = ctx.Dir
 		winLocalDir = localizeDir(ctx.Dir)
 		winPathBase = base(v.file)
+		if v.sensitive {
+			ex.apiScopes = append(ex.apiScopes, apiScopeSensitive)
+		}
 	}
 
 	ex.extraEnv = []string{
@@ -785,6 +957,17 @@ func (c CommandExecutor) setExtraEnv(ctx *CmdContext, ex *execCtx) {
 	for k, v := range settings.Env {
 		ex.extraEnv = append(ex.extraEnv, fmt.Sprintf("%s=%s", k, v))
 	}
+
+	// The environment for the host comes last so that it overrides the [env] section.
+	if g, err := NewGlobalPath(ex.dir, GlobalPathIsDir); err == nil && g.IsRemote() {
+		if hs, ok := sshHostSettingsFor(g.Host()); ok {
+			for k, v := range hs.Env {
+				ex.extraEnv = append(ex.extraEnv, fmt.Sprintf("%s=%s", k, v))
+			}
+		}
+	}
+
+	c.setApiScope(ctx, ex)
 }
 
 func (c CommandExecutor) CmdCut(ctx *CmdContext) {
@@ -810,6 +993,63 @@ func (c CommandExecutor) CmdPaste(ctx *CmdContext) {
 	editor.pasteToFocusedEditable(ctx.Gtx)
 }
 
+func (c CommandExecutor) CmdPath(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return
+	}
+
+	full := !w.Tag.showFullPath
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "full":
+			full = true
+		case "short":
+			full = false
+		default:
+			editor.AppendError("", "Path: the argument must be 'full' or 'short'")
+			return
+		}
+	}
+
+	w.Tag.SetShowFullPath(full)
+}
+
+func (c CommandExecutor) CmdSlots(ctx *CmdContext) {
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", editor.Slots.String())
+		return
+	}
+
+	mode := pasteOneSlot
+	slot := 0
+	switch ctx.Args[0] {
+	case "paste":
+		mode = pasteSlotwise
+	case "rotate":
+		mode = pasteSlotsRotated
+	default:
+		n, err := strconv.Atoi(ctx.Args[0])
+		if err != nil {
+			editor.AppendError("", "Slots: the argument must be 'paste', 'rotate' or a slot number")
+			return
+		}
+		slot = n
+	}
+
+	e := editor.getFocusedEditable()
+	if e == nil {
+		editor.AppendError("", "Slots: no editable has the keyboard focus to paste into")
+		return
+	}
+
+	var err error
+	e.restoreCursorsIfModificationRefused(func() { err = e.PasteSlots(mode, slot) })
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Slots: %v", err))
+	}
+}
+
 func (c CommandExecutor) CmdPut(ctx *CmdContext) {
 	switch v := c.source.(type) {
 	case Window:
@@ -827,6 +1067,20 @@ func (c CommandExecutor) CmdGet(ctx *CmdContext) {
 	}
 }
 
+func (c CommandExecutor) CmdEditAnyway(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Edit-anyway: must be executed in a window")
+		return
+	}
+
+	if w.IsWritable() {
+		editor.AppendError("", fmt.Sprintf("Edit-anyway: %s is writable", w.file))
+		return
+	}
+	w.EditAnyway()
+}
+
 func (c CommandExecutor) CmdKill(ctx *CmdContext) {
 	if len(ctx.Args) == 0 {
 		editor.KillJob("")
@@ -838,6 +1092,14 @@ func (c CommandExecutor) CmdKill(ctx *CmdContext) {
 	}
 }
 
+func (c CommandExecutor) CmdJobs(ctx *CmdContext) {
+	l := editor.jobListing()
+	if l == "" {
+		l = "No jobs are running\n"
+	}
+	editor.AppendError("", l)
+}
+
 func (c CommandExecutor) CmdLook(ctx *CmdContext) {
 	needle := ctx.CombinedArgs()
 	ctx.Editable.SearchAndUpdateEditable(ctx.Gtx, needle, ctx.Editable.firstCursorIndex(), Forward)
@@ -875,17 +1137,69 @@ func (c CommandExecutor) CmdHostPassword(ctx *CmdContext) {
 	editor.AppendError("", fmt.Sprintf("Added host password for %s", hop))
 }
 
+func (c CommandExecutor) CmdSshclr(ctx *CmdContext) {
+	host := ""
+	if len(ctx.Args) > 0 {
+		host = ctx.Args[0]
+	}
+
+	dropped := sshClientCache.Drop(host)
+	if len(dropped) == 0 {
+		if host == "" {
+			editor.AppendError("", "Sshclr: there are no cached ssh connections")
+		} else {
+			editor.AppendError("", fmt.Sprintf("Sshclr: there are no cached ssh connections to %s", host))
+		}
+		return
+	}
+
+	for _, k := range dropped {
+		editor.AppendError("", fmt.Sprintf("Dropped the cached ssh connection to %s", k))
+	}
+}
+
 func (c CommandExecutor) CmdZerox(ctx *CmdContext) {
 	if editor.focusedWindow == nil {
 		return
 	}
 
-	_, err := editor.focusedWindow.Zerox()
+	var col *Col
+	follow := false
+	for _, arg := range ctx.Args {
+		if arg == "-follow" {
+			follow = true
+			continue
+		}
+
+		var (
+			msg string
+			err error
+		)
+		col, msg, err = editor.findColForMoveto(arg)
+		if err != nil {
+			editor.AppendError("", fmt.Sprintf("Zerox: %v", err))
+			return
+		}
+		if msg != "" {
+			editor.AppendError("", fmt.Sprintf("Zerox: %s", msg))
+		}
+	}
+
+	if col != nil {
+		col.SetVisible(true)
+	}
+
+	src := editor.focusedWindow
+	nw, err := src.ZeroxInCol(col)
 	if err != nil {
 		editor.AppendError("", fmt.Sprintf("Zerox failed: %v", err.Error()))
 		return
 	}
 
+	if follow {
+		nw.followScrollOf(src)
+	}
+	nw.warpPointerToBody()
 }
 
 func (c CommandExecutor) CmdTitle(ctx *CmdContext) {
@@ -1058,9 +1372,10 @@ func (c CommandExecutor) gtExecutorForOneSelection(command string, ctx *CmdConte
 	c.setExtraEnv(ctx, &ec)
 
 	ge := &GtExecutor{
-		load:    load,
-		execCtx: ec,
-		sfs:     sfs,
+		load:         load,
+		execCtx:      ec,
+		sfs:          sfs,
+		outputWindow: ctx.OutputWindow,
 	}
 
 	return ge
@@ -1071,6 +1386,8 @@ type GtExecutor struct {
 	execCtx execCtx
 	sfs     simpleFs
 	next    *GtExecutor
+	// outputWindow is the window the output is redirected to, if any.
+	outputWindow string
 }
 
 func (g GtExecutor) StartNext() {
@@ -1091,10 +1408,12 @@ func (g *GtExecutor) Start() {
 
 	wl := &WindowDataLoad{
 		DataLoad:          *g.load,
-		Win:               NewWindowHolderForName(editor.ErrorsFileNameOf(g.execCtx.dir)),
-		Jobname:           g.execCtx.cmd,
+		Win:               outputWindowHolder(g.execCtx.dir, g.outputWindow),
+		Jobname:           outputJobName(g.execCtx.cmd, g.outputWindow),
 		Tail:              true,
 		GrowBodyBehaviour: growBodyIfTooSmall,
+		SpillThreshold:    int64(settings.General.SpillThreshold),
+		ReportTermination: true,
 	}
 
 	wl.Start(editor.WorkChan())
@@ -1123,6 +1442,10 @@ func (j GtExecutorJob) StartNext() {
 	j.executor.StartNext()
 }
 
+func (j GtExecutorJob) Cleanup() {
+	j.winDataLoad.Cleanup()
+}
+
 func (c CommandExecutor) CmdExecLt(command string, ctx *CmdContext) {
 	log(LogCatgCmd, "CommandExecutor.CmdExecLt: running command %s\n", command)
 
@@ -1150,10 +1473,17 @@ func (c CommandExecutor) CmdExecLt(command string, ctx *CmdContext) {
 	}
 	c.setExtraEnv(ctx, &ec)
 
+	// Keep the stdin of the command open so that Send in the window can write to it.
+	win, _ := c.source.(*Window)
+	if win != nil {
+		ec.openStdin = newJobStdin(command)
+	}
+
 	err = sfs.execAsync(ec)
 	if err != nil {
 		log(LogCatgCmd, "CommandExecutor.CmdExecLt: error executing '%s': %v\n", command, err)
 		editor.AppendError(dir, err.Error())
+		ec.openStdin.Close()
 		return
 	}
 
@@ -1164,11 +1494,15 @@ func (c CommandExecutor) CmdExecLt(command string, ctx *CmdContext) {
 		MakeWork: func(job Job, ed *editable, data []byte, first bool) Work {
 			return &edInsertText{job: job, ed: ed, data: data}
 		},
+		Stdin: ec.openStdin,
 	}
 
 	wl.Start(editor.WorkChan())
 
 	editor.AddJob(wl)
+	if win != nil {
+		win.attachJobStdin(ec.openStdin)
+	}
 
 }
 
@@ -1177,12 +1511,22 @@ type EditableModify struct {
 	Jobname  string
 	Editable *editable
 	MakeWork func(job Job, ed *editable, data []byte, first bool) Work
+	// Stdin is the stdin of the job if it is kept open for Send. It is closed when the job
+	// finishes or is killed.
+	Stdin   *jobStdin
+	started time.Time
+	killed  atomic.Bool
 }
 
 func (f *EditableModify) Start(c chan Work) {
+	f.started = time.Now()
 	go f.pump(c)
 }
 
+func (f *EditableModify) StartTime() time.Time {
+	return f.started
+}
+
 func (f *EditableModify) pump(c chan Work) {
 	/*
 		For ssh execution or loading we might not know if there is an error until
@@ -1196,6 +1540,20 @@ func (f *EditableModify) pump(c chan Work) {
 	}
 
 	firstAppend := true
+	var termination jobTermination
+
+	// The output that arrives within a frame is applied as one change.
+	var batch outputBatcher
+	sendBatch := func() {
+		x := batch.take()
+		if len(x) == 0 {
+			return
+		}
+		work := f.MakeWork(f, f.Editable, x, firstAppend)
+		editor.workQueue.waitForRoom(priorityOfWork(work))
+		c <- work
+		firstAppend = false
+	}
 
 	log(LogCatgCmd, "EditableSelectionReplace.pump: started\n")
 FOR:
@@ -1204,6 +1562,7 @@ FOR:
 		case x, ok := <-f.Contents:
 			if !ok {
 				log(LogCatgCmd, "EditableSelectionReplace.pump: contents closed\n")
+				sendBatch()
 				contentsClosed = true
 				f.Contents = nil
 				if workIsDone() {
@@ -1212,9 +1571,11 @@ FOR:
 				break
 			}
 
-			work := f.MakeWork(f, f.Editable, x, firstAppend)
-			c <- work
-			firstAppend = false
+			if batch.add(x) {
+				sendBatch()
+			}
+		case <-batch.ready():
+			sendBatch()
 		case x, ok := <-f.Errs:
 			if !ok {
 				log(LogCatgCmd, "EditableSelectionReplace.pump: errs closed\n")
@@ -1230,15 +1591,35 @@ FOR:
 				log(LogCatgCmd, "  (%T)\n", e)
 			}
 
+			if isTerminationError(x) {
+				termination.err = x
+				break
+			}
+			sendBatch()
 			c <- &winLoadErr{job: f, err: x}
 			//break FOR
 		}
 	}
 
+	sendBatch()
+	f.Stdin.Close()
+
+	// The output replaced text in the editable, so how the command ended is only reported
+	// when it didn't complete normally.
+	termination.started = f.started
+	termination.killed = f.killed.Load()
+	if !termination.clean() {
+		footer := termination.footer(f.Jobname, time.Now())
+		c <- basicWork{func() {
+			editor.AppendError("", strings.TrimSuffix(footer, "\n"))
+		}}
+	}
 	c <- &jobDone{job: f}
 }
 
 func (l *EditableModify) Kill() {
+	l.killed.Store(true)
+	l.Stdin.Close()
 	select {
 	case l.DataLoad.Kill <- struct{}{}:
 	default:
@@ -1249,6 +1630,11 @@ func (l *EditableModify) Name() string {
 	return l.Jobname
 }
 
+// Cleanup closes the stdin of the job, in case the job was removed before it ended.
+func (l *EditableModify) Cleanup() {
+	l.Stdin.Close()
+}
+
 type edAppendToSelection struct {
 	job   Job
 	ed    *editable
@@ -1348,126 +1734,458 @@ func (c CommandExecutor) CmdSyntax(ctx *CmdContext) {
 	}
 }
 
-func (c CommandExecutor) CmdAnsi(ctx *CmdContext) {
-	on := true
+func (c CommandExecutor) CmdLintws(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return
+	}
+
 	if len(ctx.Args) > 0 {
 		switch ctx.Args[0] {
 		case "off":
-			on = false
+			w.whitespaceHintsOff = true
 		case "on":
-			on = true
+			w.whitespaceHintsOff = false
 		default:
+			editor.AppendError("", "Lintws: the argument must be 'on' or 'off'")
 			return
 		}
+		w.updateWhitespaceHints()
+		return
 	}
 
-	if ctx.Editable != nil {
-		ctx.Editable.ColorizeAnsiEscapes(on)
-	}
+	editor.AppendError(ctx.Dir, w.whitespaceLintReport())
 }
 
-func (c CommandExecutor) determineDumpFilename(ctx *CmdContext) string {
-	filename := fmt.Sprintf("%s.dump", editorName)
+func (c CommandExecutor) CmdWrap(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return
+	}
 
-	if len(ctx.Args) >= 1 {
-		filename = ctx.CombinedArgs()
+	wrap := !w.Body.Wrap()
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "off":
+			wrap = false
+		case "on":
+			wrap = true
+		default:
+			editor.AppendError("", "Wrap: the argument must be 'on' or 'off'")
+			return
+		}
 	}
 
-	return filename
+	w.Body.SetWrap(wrap)
 }
 
-func (c CommandExecutor) CmdDump(ctx *CmdContext) {
-	state := application.State()
-	filename := c.determineDumpFilename(ctx)
-
-	err := WriteState(filename, state)
-	if err != nil {
-		editor.AppendError("", fmt.Sprintf("Dump failed: %v", err))
+func (c CommandExecutor) CmdWs(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
 		return
 	}
-}
-
-func (c CommandExecutor) CmdLoad(ctx *CmdContext) {
-	filename := c.determineDumpFilename(ctx)
-	var state ApplicationState
 
-	err := ReadState(filename, &state)
-	if err != nil {
-		editor.AppendError("", fmt.Sprintf("Load failed: %v", err))
-		return
+	d := whitespaceDisplayTrailing
+	if w.Body.wsDisplay != whitespaceDisplayOff {
+		d = whitespaceDisplayOff
+	}
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "off":
+			d = whitespaceDisplayOff
+		case "on":
+			d = whitespaceDisplayTrailing
+		case "tabs":
+			d = whitespaceDisplayTabs
+		default:
+			editor.AppendError("", "Ws: the argument must be 'on', 'tabs' or 'off'")
+			return
+		}
 	}
 
-	application.SetState(&state)
-}
-
-func (c CommandExecutor) CmdProfCpu(ctx *CmdContext) {
-	c.CmdProf(ctx, ProfileCPU)
-}
-
-func (c CommandExecutor) CmdProfHeap(ctx *CmdContext) {
-	c.CmdProf(ctx, ProfileHeap)
+	w.Body.SetWhitespaceDisplay(d)
 }
 
-func (c CommandExecutor) CmdProf(ctx *CmdContext, what ProfileCategory) {
-	if isProfiling() {
-		stopProfiling()
-	} else {
-		startProfiling(what)
+func (c CommandExecutor) CmdGuide(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return
 	}
-}
 
-func (c CommandExecutor) CmdGoroutines(ctx *CmdContext) {
-	buf := make([]byte, 100000)
-	sz := runtime.Stack(buf, true)
-	buf = buf[0:sz]
-	editor.AppendError("", string(buf))
-}
+	col := defaultGuideColumn
+	if w.Body.guideColumn > 0 {
+		col = 0
+	}
+	if len(ctx.Args) > 0 {
+		if ctx.Args[0] == "off" {
+			col = 0
+		} else {
+			n, err := strconv.Atoi(ctx.Args[0])
+			if err != nil || n <= 0 {
+				editor.AppendError("", "Guide: the argument must be a column number or 'off'")
+				return
+			}
+			col = n
+		}
+	}
 
-func (c CommandExecutor) CmdPutall(ctx *CmdContext) {
-	editor.Putall()
+	w.Body.SetGuideColumn(col)
 }
 
-func (c CommandExecutor) CmdRecent(ctx *CmdContext) {
-	s := strings.Join(editor.RecentFiles(), "\n")
-	editor.AppendError("", s)
-}
+func (c CommandExecutor) CmdEtabs(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return
+	}
 
-func (c CommandExecutor) CmdExpr(cmd string, ctx *CmdContext) {
-	handler := ctx.Editable.makeExprHandler()
+	on := !w.Body.elasticTabs
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "on":
+			on = true
+		case "off":
+			on = false
+		default:
+			editor.AppendError("", "Etabs: the argument must be 'on' or 'off'")
+			return
+		}
+	}
 
-	win, _ := c.source.(*Window)
-	executor := NewEditableExprExecutor(ctx.Editable, win, ctx.Dir, handler)
-	executor.Do(cmd)
+	w.Body.SetElasticTabs(on)
 }
 
-func (c CommandExecutor) CmdMark(ctx *CmdContext) {
-	file := ""
-
-	switch v := c.source.(type) {
-	case Window:
-	case *Window:
-		file = v.file
-	default:
+func (c CommandExecutor) CmdCd(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError(ctx.Dir, "Cd: must be executed from a window")
 		return
 	}
 
-	if ctx.Editable == nil {
+	if len(ctx.Args) == 0 {
+		dir, _ := NewFileFinder(w).CommandDir()
+		how := "the directory of the file"
+		if w.workDir != "" {
+			how = "set with Cd"
+		}
+		editor.AppendError(dir, fmt.Sprintf("Cd: commands run in %s (%s)", dir, how))
 		return
 	}
 
-	markName := "def"
-	if len(ctx.Args) > 0 {
-		markName = ctx.Args[0]
+	dir := ctx.Args[0]
+	if dir == "-" {
+		dir = ""
+	}
+	if err := w.SetWorkDir(dir); err != nil {
+		editor.AppendError(ctx.Dir, fmt.Sprintf("Cd: %v", err))
 	}
-
-	editor.Marks.Set(markName, file, ctx.Editable.firstCursorIndex())
 }
 
-func (c CommandExecutor) CmdGoto(ctx *CmdContext) {
-	markName := "def"
-	if len(ctx.Args) > 0 {
-		markName = ctx.Args[0]
-	}
+func (c CommandExecutor) CmdTagsrc(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError(ctx.Dir, "Tagsrc: must be executed from a window")
+		return
+	}
+
+	on := w.tagsCompletionOff
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "on":
+			on = true
+		case "off":
+			on = false
+		default:
+			editor.AppendError(ctx.Dir, "Tagsrc: the argument must be 'on' or 'off'")
+			return
+		}
+	}
+
+	w.SetTagsCompletion(on)
+}
+
+func (c CommandExecutor) CmdFmt(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return
+	}
+
+	width := w.Body.guideColumn
+	if width <= 0 {
+		width = defaultGuideColumn
+	}
+	if len(ctx.Args) > 0 {
+		n, err := strconv.Atoi(ctx.Args[0])
+		if err != nil || n <= 0 {
+			editor.AppendError("", "Fmt: the argument must be a line width")
+			return
+		}
+		width = n
+	}
+
+	w.Body.ReflowSelectionsOrParagraphs(width)
+}
+
+func (c CommandExecutor) CmdNexterr(ctx *CmdContext) {
+	c.goToError("Nexterr", ctx, 1)
+}
+
+func (c CommandExecutor) CmdPreverr(ctx *CmdContext) {
+	c.goToError("Preverr", ctx, -1)
+}
+
+func (c CommandExecutor) goToError(cmd string, ctx *CmdContext, dir int) {
+	w, ok := c.source.(*Window)
+	if !ok || !w.IsErrorsWindow() {
+		w, _ = editor.FindWindowForFile(editor.ErrorsFileNameOf(ctx.Dir))
+		if w == nil {
+			w, _ = editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		}
+	}
+	if w == nil {
+		editor.AppendError("", fmt.Sprintf("%s: there is no +Errors window", cmd))
+		return
+	}
+
+	severity := ""
+	if len(ctx.Args) > 0 {
+		severity = strings.ToLower(ctx.Args[0])
+	}
+
+	err := w.GoToError(ctx.Gtx, dir, severity)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("%s: %v", cmd, err))
+	}
+}
+
+func (c CommandExecutor) CmdScrollcursor(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return
+	}
+
+	on := !w.Body.ScrollMovesCursor()
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "off":
+			on = false
+		case "on":
+			on = true
+		default:
+			editor.AppendError("", "Scrollcursor: the argument must be 'on' or 'off'")
+			return
+		}
+	}
+
+	w.Body.SetScrollMovesCursor(on)
+}
+
+func (c CommandExecutor) CmdSensitive(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return
+	}
+
+	sensitive := !w.IsSensitive()
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "off":
+			sensitive = false
+		case "on":
+			sensitive = true
+		default:
+			editor.AppendError("", "Sensitive: the argument must be 'on' or 'off'")
+			return
+		}
+	}
+
+	w.SetSensitive(sensitive)
+}
+
+func (c CommandExecutor) CmdPresent(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return
+	}
+
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", fmt.Sprintf("Present: the body is shown using the %s presenter. The presenters are: %s",
+			w.Body.PresenterName(), strings.Join(bodyPresenterNames(), ", ")))
+		return
+	}
+
+	err := w.Body.SetPresenter(ctx.Args[0])
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Present: %v", err))
+	}
+	// The next change refused because of the presenter is explained again.
+	w.notWritableNoticeShown = false
+}
+
+func (c CommandExecutor) CmdAnsi(ctx *CmdContext) {
+	on := true
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "off":
+			on = false
+		case "on":
+			on = true
+		default:
+			return
+		}
+	}
+
+	if ctx.Editable != nil {
+		ctx.Editable.ColorizeAnsiEscapes(on)
+	}
+}
+
+func (c CommandExecutor) determineDumpFilename(ctx *CmdContext) string {
+	filename := fmt.Sprintf("%s.dump", editorName)
+
+	if len(ctx.Args) >= 1 {
+		filename = ctx.CombinedArgs()
+	}
+
+	return filename
+}
+
+func (c CommandExecutor) CmdDump(ctx *CmdContext) {
+	state := application.State()
+	filename := c.determineDumpFilename(ctx)
+
+	err := WriteState(filename, state)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Dump failed: %v", err))
+		return
+	}
+}
+
+func (c CommandExecutor) CmdLoad(ctx *CmdContext) {
+	filename := c.determineDumpFilename(ctx)
+	var state ApplicationState
+
+	err := ReadState(filename, &state)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Load failed: %v", err))
+		return
+	}
+
+	application.SetState(&state)
+}
+
+func (c CommandExecutor) CmdProfCpu(ctx *CmdContext) {
+	c.CmdProf(ctx, ProfileCPU)
+}
+
+func (c CommandExecutor) CmdProfHeap(ctx *CmdContext) {
+	c.CmdProf(ctx, ProfileHeap)
+}
+
+func (c CommandExecutor) CmdProf(ctx *CmdContext, what ProfileCategory) {
+	if isProfiling() {
+		stopProfiling()
+	} else {
+		startProfiling(what)
+	}
+}
+
+func (c CommandExecutor) CmdGoroutines(ctx *CmdContext) {
+	buf := make([]byte, 100000)
+	sz := runtime.Stack(buf, true)
+	buf = buf[0:sz]
+	editor.AppendError("", string(buf))
+}
+
+func (c CommandExecutor) CmdPutall(ctx *CmdContext) {
+	var opts putallOptions
+	for _, arg := range ctx.Args {
+		switch arg {
+		case "-n":
+			opts.dryRun = true
+		case "-create":
+			opts.create = true
+		default:
+			editor.AppendError("", fmt.Sprintf("Putall: unknown argument '%s'; expected -n or -create", arg))
+			return
+		}
+	}
+
+	results := editor.Putall(opts)
+	editor.AppendError("", putallReport(results, opts))
+}
+
+func (c CommandExecutor) CmdRecent(ctx *CmdContext) {
+	s := strings.Join(editor.RecentFiles(), "\n")
+	editor.AppendError("", s)
+}
+
+func (c CommandExecutor) CmdExpr(cmd string, ctx *CmdContext) {
+	handler := ctx.Editable.makeExprHandler()
+
+	win, _ := c.source.(*Window)
+	executor := NewEditableExprExecutor(ctx.Editable, win, ctx.Dir, handler)
+	executor.Do(cmd)
+}
+
+func (c CommandExecutor) CmdMark(ctx *CmdContext) {
+	file := ""
+
+	switch v := c.source.(type) {
+	case Window:
+	case *Window:
+		file = v.file
+	default:
+		return
+	}
+
+	if ctx.Editable == nil {
+		return
+	}
+
+	markName := "def"
+	if len(ctx.Args) > 0 {
+		markName = ctx.Args[0]
+	}
+
+	editor.Marks.Set(markName, file, ctx.Editable.firstCursorIndex())
+}
+
+func (c CommandExecutor) CmdMacro(ctx *CmdContext) {
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", editor.Macros.String())
+		return
+	}
+
+	var err error
+	switch ctx.Args[0] {
+	case "record":
+		err = editor.Macros.Record()
+	case "stop":
+		err = editor.Macros.Stop()
+	case "play":
+		count := 1
+		if len(ctx.Args) > 1 {
+			count, err = strconv.Atoi(ctx.Args[1])
+			if err != nil || count < 1 {
+				editor.AppendError("", "Macro: the number of times to play must be a positive integer")
+				return
+			}
+		}
+		err = editor.Macros.Play(ctx.Gtx, count)
+	default:
+		err = fmt.Errorf("unknown argument '%s'. Expected record, stop or play", ctx.Args[0])
+	}
+
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Macro: %v", err))
+	}
+}
+
+func (c CommandExecutor) CmdGoto(ctx *CmdContext) {
+	markName := "def"
+	if len(ctx.Args) > 0 {
+		markName = ctx.Args[0]
+	}
 
 	file, seek, ok := editor.Marks.Seek(markName)
 	if ok {
@@ -1521,6 +2239,8 @@ func (c CommandExecutor) CmdLoadPlumbing(ctx *CmdContext) {
 	}
 
 	log(LogCatgCmd, "Loading plumbing rules from file %s\n", file)
+	// Make sure the rules from the config file, if they are still loading, don't replace these later.
+	HirePlumber()
 	err := HirePlumberUsingFile(file)
 	if err != nil {
 		editor.AppendError("", fmt.Sprintf("Loading plumbing rules from file '%s' failed: %v.", file, err))
@@ -1536,6 +2256,10 @@ func (c CommandExecutor) CmdInsertLozenge(ctx *CmdContext) {
 }
 
 func (c CommandExecutor) CmdHelp(ctx *CmdContext) {
+	if len(ctx.Args) == 1 && ctx.Args[0] == "-json" {
+		c.appendHelpJson()
+		return
+	}
 
 	if len(ctx.Args) > 0 {
 		t := Help(ctx.CombinedArgs())
@@ -1547,28 +2271,126 @@ func (c CommandExecutor) CmdHelp(ctx *CmdContext) {
 		return
 	}
 
+	editor.AppendError("", c.helpText())
+}
+
+// helpText returns the text written by Help when it has no arguments. The commands are ordered
+// by commandNameLess, and the debug commands follow them in their own section.
+func (c CommandExecutor) helpText() string {
 	var text bytes.Buffer
 	fmt.Fprintf(&text, "%s", topLevelHelpString())
 
-	var names []string
-	for k := range c.commands {
-		names = append(names, k)
+	for _, v := range c.Commands() {
+		fmt.Fprintf(&text, "%s  (◊Help %s◊)\n\t%s\n", v.name, v.name, v.shortHelp)
 	}
-	sort.Strings(names)
 
-	for _, k := range names {
-		v := c.commands[k]
-		fmt.Fprintf(&text, "%s  (◊Help %s◊)\n\t%s\n", k, k, v.shortHelp)
+	fmt.Fprintf(&text, "\n=== Debug commands ===\n\nThe following commands are executed as arguments to Dbg.\n\n")
+	for _, v := range c.debugCommandSet.Commands() {
+		fmt.Fprintf(&text, "%s  (◊Help Dbg %s◊)\n\t%s\n", v.name, v.name, v.shortHelp)
 	}
 	text.WriteRune('\n')
 
-	editor.AppendError("", text.String())
+	return text.String()
+}
+
+// helpCommand describes a command in the output of Help -json.
+type helpCommand struct {
+	Name      string
+	ShortHelp string
+	// Debug is true for the commands executed as arguments to Dbg.
+	Debug bool
+}
+
+func (c CommandExecutor) helpCommands() []helpCommand {
+	var cmds []helpCommand
+	for _, v := range c.Commands() {
+		cmds = append(cmds, helpCommand{Name: v.name, ShortHelp: v.shortHelp})
+	}
+	for _, v := range c.debugCommandSet.Commands() {
+		cmds = append(cmds, helpCommand{Name: v.name, ShortHelp: v.shortHelp, Debug: true})
+	}
+	return cmds
+}
+
+func (c CommandExecutor) appendHelpJson() {
+	appendJsonToErrors("Help", c.helpCommands())
+}
+
+// appendJsonToErrors writes v to the +Errors window encoded as JSON in the same way as the API
+// encodes its responses.
+func appendJsonToErrors(cmd string, v interface{}) {
+	var buf bytes.Buffer
+	enc, flush := getEncoder(&buf, encodingApplicationJson)
+	err := enc.Encode(v)
+	flush()
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("%s: encoding as JSON failed: %v", cmd, err))
+		return
+	}
+	editor.AppendError("", buf.String())
 }
 
 func (c CommandExecutor) CmdRot(ctx *CmdContext) {
 	ctx.Editable.RotateSelections()
 }
 
+func (c CommandExecutor) CmdSwap(ctx *CmdContext) {
+	if err := ctx.Editable.SwapSelections(); err != nil {
+		editor.AppendError("", fmt.Sprintf("Swap: %v. Select the two pieces of text to exchange, using Ctrl to add the second selection.", err))
+	}
+}
+
+const caseCommandLocaleHelp = "The conversion is a single change for Undo, and each cursor is left after its converted text. An optional argument names the language whose case rules to use, such as 'tr' for Turkish; by default the language of the LC_ALL, LC_CTYPE or LANG environment variable is used."
+
+func (c CommandExecutor) CmdUpper(ctx *CmdContext) {
+	c.convertCase(ctx, textcase.Upper)
+}
+
+func (c CommandExecutor) CmdLower(ctx *CmdContext) {
+	c.convertCase(ctx, textcase.Lower)
+}
+
+func (c CommandExecutor) CmdTitlecase(ctx *CmdContext) {
+	c.convertCase(ctx, textcase.Title)
+}
+
+func (c CommandExecutor) CmdSwapcase(ctx *CmdContext) {
+	c.convertCase(ctx, textcase.Swap)
+}
+
+func (c CommandExecutor) CmdSnake(ctx *CmdContext) {
+	c.convertCase(ctx, textcase.Snake)
+}
+
+func (c CommandExecutor) CmdCamel(ctx *CmdContext) {
+	c.convertCase(ctx, textcase.Camel)
+}
+
+func (c CommandExecutor) CmdKebab(ctx *CmdContext) {
+	c.convertCase(ctx, textcase.Kebab)
+}
+
+func (c CommandExecutor) convertCase(ctx *CmdContext, convert func(s string, sc unicode.SpecialCase) string) {
+	if ctx.Editable == nil {
+		return
+	}
+
+	lang := ""
+	for _, v := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if lang = os.Getenv(v); lang != "" {
+			break
+		}
+	}
+	if len(ctx.Args) > 0 {
+		lang = ctx.Args[0]
+	}
+
+	sc := textcase.Locale(lang)
+	ctx.Editable.TransformSelectionsOrWords(func(s string) string {
+		return convert(s, sc)
+	})
+}
+
 func (c CommandExecutor) CmdDo(ctx *CmdContext) {
 	if len(ctx.Args) == 0 {
 		return
@@ -1596,6 +2418,7 @@ func (c CommandExecutor) CmdAbout(ctx *CmdContext) {
 	var text bytes.Buffer
 	fmt.Fprintf(&text, "%s was written by Jeff Williams\n\n", strings.Title(editorName))
 	fmt.Fprintf(&text, "Version: %s %s\n", buildVersion, buildTime)
+	HirePlumber()
 	fmt.Fprintf(&text, "Config directory: %s\n", ConfDir)
 	fmt.Fprintf(&text, "Settings file: %s (%s)\n", SettingsConfigFile(), loadedStr(settingsLoadedFromFile))
 	fmt.Fprintf(&text, "Style config file: %s (%s)\n", StyleConfigFile(), loadedStr(styleLoadedFromFile))
@@ -1610,6 +2433,7 @@ func (c CommandExecutor) CmdAbout(ctx *CmdContext) {
 		for i, k := range sshKeys {
 			fmt.Fprintf(&text, "  %s\n", k)
 			if i < len(sshEntries) && len(sshEntries) > 0 {
+				fmt.Fprintf(&text, "    State: %s\n", sshEntries[i].client.State())
 				fmt.Fprintf(&text, "    API listener port: %d\n", sshEntries[i].client.ListenerPort())
 			}
 		}
@@ -1637,6 +2461,16 @@ func (c CommandExecutor) CmdAbout(ctx *CmdContext) {
 		fmt.Fprintf(&text, "No SSH keyfile passwords defined\n")
 	}
 
+	sshHosts := sshHostsWithSettings()
+	if len(sshHosts) > 0 {
+		fmt.Fprintf(&text, "SSH hosts having settings:\n")
+		for _, h := range sshHosts {
+			fmt.Fprintf(&text, "  %s\n", h)
+		}
+	} else {
+		fmt.Fprintf(&text, "No SSH host settings defined\n")
+	}
+
 	apiSessions := getApiSessions()
 	if len(apiSessions) > 0 {
 		fmt.Fprintf(&text, "API sessions:\n")
@@ -1645,7 +2479,10 @@ func (c CommandExecutor) CmdAbout(ctx *CmdContext) {
 			if len(s) > 0 {
 				s = fmt.Sprintf(" user-defined commands: [%s]", s)
 			}
-			fmt.Fprintf(&text, "  %s %s%s\n", e.Cmd(), e.Id(), s)
+			if e.websock != nil {
+				s += fmt.Sprintf(" websocket notifications queued: %d sent: %d dropped: %d", e.websock.Queued(), e.websock.Sent(), e.websock.Dropped())
+			}
+			fmt.Fprintf(&text, "  %s %s scope: %s%s\n", e.Cmd(), e.Id(), e.scopeString(), s)
 		}
 	} else {
 		fmt.Fprintf(&text, "No API sessions\n")
@@ -1701,32 +2538,54 @@ func (c CommandExecutor) CmdPrintCfg(ctx *CmdContext) {
 	fname := ctx.Args[0]
 
 	switch fname {
-	case "settings.toml":
+	case "settings.toml", "settings":
 		editor.AppendError("", GenerateSampleSettings())
+	case "style.js", "style":
+		var buf bytes.Buffer
+		if err := encodeStyle(&buf, WindowStyle); err != nil {
+			editor.AppendError("", fmt.Sprintf("PrintCfg: encoding the style failed: %v", err))
+			return
+		}
+		editor.AppendError("", buf.String())
+	case "plumbing":
+		editor.AppendError("", GenerateSamplePlumbing())
+	default:
+		editor.AppendError("", fmt.Sprintf("PrintCfg: unknown config file '%s'. Use settings.toml, style or plumbing", fname))
 	}
 }
 
+func (c CommandExecutor) CmdCheckCfg(ctx *CmdContext) {
+	editor.AppendError("", checkConfigFiles())
+}
+
 func (c CommandExecutor) CmdWins(ctx *CmdContext) {
-	var paths []string
+	if len(ctx.Args) == 1 && ctx.Args[0] == "-json" {
+		var wins apiWindows
+		for _, win := range editor.Windows() {
+			wins = append(wins, ApiHandler{}.buildWindowOnMainGoroutine(win))
+		}
+		appendJsonToErrors("Wins", wins)
+		return
+	}
+
+	var buf bytes.Buffer
 	for _, win := range editor.Windows() {
 		path, _, _, err := win.Tag.Parts()
 		if err != nil {
-			editor.AppendError("", fmt.Sprintf("(error getting path of window: %v)", err))
+			fmt.Fprintf(&buf, "(error getting path of window: %v)\n", err)
 			continue
 		}
-		paths = append(paths, path)
-	}
-
-	sort.Slice(paths, func(i, j int) bool {
-		return paths[i] < paths[j]
-	})
-
-	for _, path := range paths {
-		editor.AppendError("", path)
+		fmt.Fprintf(&buf, "%s\n", path)
 	}
+	editor.AppendError("", buf.String())
 }
 
 func (c CommandExecutor) CmdOnly(ctx *CmdContext) {
+	if _, ok := c.source.(*Window); !ok || len(ctx.Args) > 0 {
+		c.onlyCol(ctx)
+		return
+	}
+
 	switch v := c.source.(type) {
 	case Window:
 	case *Window:
@@ -1746,9 +2605,31 @@ func (c CommandExecutor) CmdOnly(ctx *CmdContext) {
 	}
 }
 
+// onlyCol hides all the columns but the one named by the arguments or the one Only was
+// executed in.
+func (c CommandExecutor) onlyCol(ctx *CmdContext) {
+	col, err := c.columnArg(ctx)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Only: %v", err))
+		return
+	}
+
+	col.SetVisible(true)
+	for _, o := range editor.Cols {
+		if o != col {
+			o.SetVisible(false)
+		}
+	}
+	editor.SignalRedrawRequired()
+}
+
 func (c CommandExecutor) CmdClr(ctx *CmdContext) {
-	ctx.Editable.SetText([]byte{})
-	ctx.Editable.ClearManualHighlights()
+	if w, ok := c.source.(*Window); ok {
+		w.closeSpills()
+	}
+	if ctx.Editable.replaceText([]byte{}) {
+		ctx.Editable.ClearManualHighlights()
+	}
 }
 
 func (c CommandExecutor) CmdShstr(ctx *CmdContext) {
@@ -1764,52 +2645,115 @@ func (c CommandExecutor) CmdShstr(ctx *CmdContext) {
 		return
 	}
 
-	if len(ctx.Args) == 0 {
-		win.Body.adapter.setShellString("")
-		win.Tag.adapter.setShellString("")
+	if len(ctx.Args) == 0 {
+		win.Body.adapter.setShellString("")
+		win.Tag.adapter.setShellString("")
+		return
+	}
+
+	win.Body.adapter.setShellString(ctx.CombinedArgs())
+	win.Tag.adapter.setShellString(ctx.CombinedArgs())
+}
+
+func (c CommandExecutor) CmdDbg(ctx *CmdContext) {
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", "Dbg expects at least one argument")
+		return
+	}
+
+	doer, ok := c.debugCommandSet.Command(ctx.Args[0])
+	if !ok {
+		editor.AppendError("", fmt.Sprintf("There is no such debug command as %s", ctx.Args[0]))
+		return
+	}
+
+	ctx.Args = ctx.Args[1:]
+	doer.do(ctx)
+}
+
+func (c CommandExecutor) CmdDbgLogs(ctx *CmdContext) {
+	msg := debugLog.String(ctx.Args...)
+	editor.AppendError("", msg)
+}
+
+func (c CommandExecutor) CmdDbgGetPid(ctx *CmdContext) {
+	os.Getpid()
+	msg := fmt.Sprintf("pid: %d", os.Getpid())
+	editor.AppendError("", msg)
+}
+
+func (c CommandExecutor) CmdDbgIndex(ctx *CmdContext) {
+	editor.AppendError("", fileIndexes.String())
+}
+
+func (c CommandExecutor) CmdDbgSched(ctx *CmdContext) {
+	editor.AppendError("", editor.workQueue.String())
+}
+
+func (c CommandExecutor) CmdDbgPctbl(ctx *CmdContext) {
+	if len(ctx.Args) != 1 || ctx.Args[0] != "check" {
+		editor.AppendError("", "Dbg Pctbl: the only subcommand is 'check'")
+		return
+	}
+
+	win, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Dbg Pctbl check: must be executed in a window")
+		return
+	}
+
+	violations := win.Body.text.Check()
+	if len(violations) == 0 {
+		editor.AppendError("", fmt.Sprintf("Dbg Pctbl check: the piece table of window %d is consistent", win.Id))
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Dbg Pctbl check: the piece table of window %d has %d violations:\n", win.Id, len(violations))
+	for _, v := range violations {
+		fmt.Fprintf(&buf, "  %s\n", v)
+	}
+	editor.AppendError("", buf.String())
+}
+
+func (c CommandExecutor) CmdDbgPsrv(ctx *CmdContext) {
+	if len(ctx.Args) > 0 && ctx.Args[0] == "off" {
+		stopPprofDebugServer()
+		return
+	}
+	startPprofDebugServer()
+}
+
+func (c CommandExecutor) CmdHideCol(ctx *CmdContext) {
+	col, err := c.columnArg(ctx)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Hidecol: %v", err))
 		return
 	}
 
-	win.Body.adapter.setShellString(ctx.CombinedArgs())
-	win.Tag.adapter.setShellString(ctx.CombinedArgs())
+	col.SetVisible(false)
 }
 
-func (c CommandExecutor) CmdDbg(ctx *CmdContext) {
+func (c CommandExecutor) CmdShowCol(ctx *CmdContext) {
 	if len(ctx.Args) == 0 {
-		editor.AppendError("", "Dbg expects at least one argument")
+		editor.SetFirstHiddenColVisible()
 		return
 	}
 
-	doer, ok := c.debugCommandSet.Command(ctx.Args[0])
-	if !ok {
-		editor.AppendError("", fmt.Sprintf("There is no such debug command as %s", ctx.Args[0]))
+	col, err := editor.FindCol(ctx.CombinedArgs())
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Showcol: %v", err))
 		return
 	}
-
-	ctx.Args = ctx.Args[1:]
-	doer.do(ctx)
-}
-
-func (c CommandExecutor) CmdDbgLogs(ctx *CmdContext) {
-	msg := debugLog.String(ctx.Args...)
-	editor.AppendError("", msg)
-}
-
-func (c CommandExecutor) CmdDbgGetPid(ctx *CmdContext) {
-	os.Getpid()
-	msg := fmt.Sprintf("pid: %d", os.Getpid())
-	editor.AppendError("", msg)
+	col.SetVisible(true)
 }
 
-func (c CommandExecutor) CmdDbgPsrv(ctx *CmdContext) {
-	if len(ctx.Args) > 0 && ctx.Args[0] == "off" {
-		stopPprofDebugServer()
+func (c CommandExecutor) CmdColwidth(ctx *CmdContext) {
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", editor.formatColWidthPcts())
 		return
 	}
-	startPprofDebugServer()
-}
 
-func (c CommandExecutor) CmdHideCol(ctx *CmdContext) {
 	var col *Col
 	switch v := c.source.(type) {
 	case *Col:
@@ -1819,20 +2763,20 @@ func (c CommandExecutor) CmdHideCol(ctx *CmdContext) {
 	}
 
 	if col == nil {
+		editor.AppendError("", "Colwidth: must be executed in a column or window")
 		return
 	}
 
-	col.SetVisible(false)
-}
-
-func (c CommandExecutor) CmdShowCol(ctx *CmdContext) {
-	if len(ctx.Args) == 0 {
-		editor.SetFirstHiddenColVisible()
+	pct, err := strconv.ParseFloat(strings.TrimSuffix(ctx.Args[0], "%"), 64)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Colwidth: the argument must be a percentage: %v", err))
 		return
 	}
 
-	name := ctx.CombinedArgs()
-	editor.SetColVisible(name)
+	err = editor.SetColWidthPct(col, pct)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Colwidth: %v", err))
+	}
 }
 
 func (c CommandExecutor) CmdCols(ctx *CmdContext) {
@@ -1901,6 +2845,29 @@ func (c CommandExecutor) CmdFuzz(ctx *CmdContext) {
 	win.fuzzySearch.search(ctx.Args)
 }
 
+func (c CommandExecutor) CmdFuzzf(ctx *CmdContext) {
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", "Fuzzf needs search terms as arguments")
+		return
+	}
+
+	dir := ctx.Dir
+	terms := ctx.Args
+	idx := fileIndexes.forDir(dir)
+	idx.whenReady(func() {
+		paths := idx.filesUnder(dir)
+		go func() {
+			results := fuzzyFileResults(terms, paths)
+			editor.WorkChan() <- basicWork{func() {
+				win := editor.FindOrCreateWindow(fmt.Sprintf("%s+Live", dir))
+				win.Body.SetText(results)
+				editor.SetOnlyFlashedWindow(win)
+				win.GrowIfBodyTooSmall()
+			}}
+		}()
+	})
+}
+
 func (c CommandExecutor) CmdPic(ctx *CmdContext) {
 	// Pic file.jpg
 	// Pic file.jpg <scale %> # scale x%
@@ -1955,6 +2922,10 @@ func (c CommandExecutor) CmdPic(ctx *CmdContext) {
 func (c CommandExecutor) CmdTab(ctx *CmdContext) {
 	if len(ctx.Args) == 0 {
 		editor.setInsertWhenTabPressed("\t")
+		// Forget a value set in the window, such as one guessed from the indentation of its file.
+		if win, ok := c.source.(*Window); ok {
+			win.setInsertWhenTabPressed("")
+		}
 		return
 	}
 
@@ -1984,6 +2955,34 @@ func (c CommandExecutor) CmdTab(ctx *CmdContext) {
 	win.setInsertWhenTabPressed(toSet)
 }
 
+func (c CommandExecutor) CmdTabwidth(ctx *CmdContext) {
+	win, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Tabwidth: must be executed in a window")
+		return
+	}
+
+	if len(ctx.Args) == 0 {
+		win.Body.SetTabWidth(0)
+		return
+	}
+
+	if ctx.Args[0] == "?" {
+		editor.AppendError(ctx.Dir, win.indentationReport())
+		return
+	}
+
+	n, err := strconv.Atoi(ctx.Args[0])
+	if err != nil || n < 1 || n > maxTabWidth {
+		editor.AppendError(ctx.Dir, fmt.Sprintf("Tabwidth: the argument must be a number of columns from 1 to %d, or ?", maxTabWidth))
+		return
+	}
+	win.Body.SetTabWidth(n)
+}
+
+// maxTabWidth is the widest tab, in columns, that can be set using Tabwidth.
+const maxTabWidth = 32
+
 func (c CommandExecutor) CmdSettag(ctx *CmdContext) {
 	//userArea := ctx.CombinedArgs()
 
@@ -1993,12 +2992,24 @@ func (c CommandExecutor) CmdSettag(ctx *CmdContext) {
 		userArea = strings.TrimLeft(userArea, " \t\n\r")
 	}
 
+	all := false
+	if userArea == "-all" || strings.HasPrefix(userArea, "-all ") {
+		all = true
+		userArea = strings.TrimLeft(userArea[4:], " \t\n\r")
+	}
+
 	s, err := escape.ExpandEscapesAndUnquote(userArea)
 	if err == nil {
 		userArea = s
 	}
 
 	win, ok := c.source.(*Window)
+	if ok && all {
+		if err := win.SetWholeTag(userArea); err != nil {
+			editor.AppendError("", fmt.Sprintf("Settag: %v", err))
+		}
+		return
+	}
 	if ok {
 		path, edArea, _, err := win.Tag.Parts()
 		if err != nil {
@@ -2017,3 +3028,292 @@ func (c CommandExecutor) CmdSettag(ctx *CmdContext) {
 		t.Tag.SetTextString(userArea)
 	}
 }
+
+// spilledWindow returns the window the command was executed in and the spill in it that the
+// command applies to: the one shown where the cursor is, or the last one started.
+func (c CommandExecutor) spilledWindow(cmd string) (win *Window, s *spill, ok bool) {
+	win, ok = c.source.(*Window)
+	if !ok || len(win.spills) == 0 {
+		editor.AppendError("", fmt.Sprintf("%s only works in windows showing output that was spilled to a file", cmd))
+		return nil, nil, false
+	}
+	return win, win.currentSpill(), true
+}
+
+func (c CommandExecutor) CmdHead(ctx *CmdContext) {
+	win, s, ok := c.spilledWindow("Head")
+	if !ok {
+		return
+	}
+
+	s.following = false
+	err := win.showSpillViewport(s, 0)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Reading spilled output failed: %v", err))
+	}
+}
+
+func (c CommandExecutor) CmdTail(ctx *CmdContext) {
+	win, ok := c.source.(*Window)
+	if !ok {
+		return
+	}
+
+	tail := true
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "off":
+			tail = false
+		case "on":
+			tail = true
+		default:
+			editor.AppendError("", "Tail: the argument must be 'on' or 'off'")
+			return
+		}
+	}
+
+	if tail && !win.tailingSuspended && len(win.spills) > 0 {
+		// Tailing is on but the viewport may have been moved using Head, Pgup or Pgdn.
+		win.showSpillTail(win.currentSpill())
+		return
+	}
+	win.SetTailing(tail)
+}
+
+func (c CommandExecutor) CmdSort(ctx *CmdContext) {
+	win, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Sort: must be executed in a window")
+		return
+	}
+
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", fmt.Sprintf("Sort: the directory is sorted by %s", win.dir.sortOrder()))
+		return
+	}
+
+	err := win.SetDirSort(ctx.Args[0])
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Sort: %v", err))
+	}
+}
+
+func (c CommandExecutor) CmdDots(ctx *CmdContext) {
+	win, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Dots: must be executed in a window")
+		return
+	}
+
+	dots := !win.dir.showDots()
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "off":
+			dots = false
+		case "on":
+			dots = true
+		default:
+			editor.AppendError("", "Dots: the argument must be 'on' or 'off'")
+			return
+		}
+	}
+
+	err := win.SetDirDots(dots)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Dots: %v", err))
+	}
+}
+
+func (c CommandExecutor) CmdFollow(ctx *CmdContext) {
+	win, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Follow: must be executed in a window")
+		return
+	}
+
+	follow := !win.IsFollowing()
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "off":
+			follow = false
+		case "on":
+			follow = true
+		default:
+			editor.AppendError("", "Follow: the argument must be 'on' or 'off'")
+			return
+		}
+	}
+
+	err := win.SetFollowing(follow)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Follow: %v", err))
+	}
+}
+
+func (c CommandExecutor) CmdPgup(ctx *CmdContext) {
+	c.spillPage("Pgup", Up)
+}
+
+func (c CommandExecutor) CmdPgdn(ctx *CmdContext) {
+	c.spillPage("Pgdn", Down)
+}
+
+func (c CommandExecutor) spillPage(cmd string, d verticalDirection) {
+	win, s, ok := c.spilledWindow(cmd)
+	if !ok {
+		return
+	}
+
+	err := win.spillPage(s, d)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Reading spilled output failed: %v", err))
+	}
+}
+
+func (c CommandExecutor) CmdSearch(ctx *CmdContext) {
+	win, s, ok := c.spilledWindow("Search")
+	if !ok {
+		return
+	}
+
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", "Search needs a regular expression as an argument")
+		return
+	}
+
+	expr := ctx.CombinedArgs()
+	if len(expr) > 1 && strings.HasPrefix(expr, "/") && strings.HasSuffix(expr, "/") {
+		expr = expr[1 : len(expr)-1]
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Invalid regular expression: %v", err))
+		return
+	}
+
+	win.searchSpill(s, re)
+}
+
+func (c CommandExecutor) CmdNotify(ctx *CmdContext) {
+	msg := ctx.CombinedArgs()
+	if msg == "" {
+		msg = "Notify"
+	}
+	application.RequestAttention(msg)
+}
+
+func (c CommandExecutor) CmdFind(ctx *CmdContext) {
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", "Find needs a regular expression as an argument")
+		return
+	}
+
+	expr := ctx.Args[0]
+	if len(expr) > 1 && strings.HasPrefix(expr, "/") && strings.HasSuffix(expr, "/") {
+		expr = expr[1 : len(expr)-1]
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Invalid regular expression: %v", err))
+		return
+	}
+	pattern, err := grepPatternFor(expr)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Invalid regular expression: %v", err))
+		return
+	}
+
+	dir := ctx.Dir
+	sfs, err := GetFs(dir)
+	if err != nil {
+		editor.AppendError(dir, err.Error())
+		return
+	}
+
+	name := findWindowNameOf(dir)
+	if win := editor.FindWindowForFileAndDisplay(name); win != nil {
+		win.Body.SetTextStringNoUndo("")
+		win.Body.ClearManualHighlights()
+	}
+
+	load := NewDataLoad()
+	ec := execCtx{
+		dir:      dir,
+		cmd:      grepCommand(pattern, ctx.Args[1:]),
+		contents: load.Contents,
+		errs:     load.Errs,
+		kill:     load.Kill,
+	}
+	// When searching the whole directory, use the files from the project index if there is one
+	// so that grep doesn't have to walk the directory again.
+	if len(ctx.Args) == 1 {
+		if files, ok := indexedFilesForFind(dir); ok {
+			ec.cmd = indexedGrepCommand(pattern)
+			ec.stdin = files
+		}
+	}
+	c.setExtraEnv(ctx, &ec)
+
+	err = sfs.execAsync(ec)
+	if err != nil {
+		log(LogCatgCmd, "CommandExecutor.CmdFind: error executing '%s': %v\n", ec.cmd, err)
+		editor.AppendError(dir, err.Error())
+		return
+	}
+
+	wl := &WindowDataLoad{
+		DataLoad:          *load,
+		Win:               NewWindowHolderForName(name),
+		Jobname:           "Find",
+		GrowBodyBehaviour: growBodyIfTooSmall,
+		LowPriority:       true,
+		Highlighter: &contentHighlighter{
+			matches: func(line []byte) [][]int {
+				return findMatchesInGrepLine(re, line)
+			},
+			// grep only finds the lines that may match, and the results are the lines that do.
+			keep: func(line []byte) bool {
+				return keepGrepLine(re, line)
+			},
+			color: WindowStyle.Syntax.KeywordColor,
+		},
+	}
+
+	wl.Start(editor.WorkChan())
+
+	editor.AddJob(wl)
+}
+
+func (c CommandExecutor) CmdExtract(ctx *CmdContext) {
+	win, s, ok := c.spilledWindow("Extract")
+	if !ok {
+		return
+	}
+
+	start, end := win.spillSelectedRange(s)
+	if len(ctx.Args) >= 2 {
+		a, err1 := strconv.ParseInt(ctx.Args[0], 10, 64)
+		b, err2 := strconv.ParseInt(ctx.Args[1], 10, 64)
+		if err1 != nil || err2 != nil {
+			editor.AppendError("", "Extract expects two byte offsets as arguments")
+			return
+		}
+		start, end = a, b
+	}
+
+	b, err := s.ReadRange(start, end)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Reading spilled output failed: %v", err))
+		return
+	}
+
+	w := editor.NewWindow(nil)
+	if w == nil {
+		return
+	}
+	w.SetFilenameAndTag(fmt.Sprintf("%s+Extract", strings.TrimSuffix(win.file, "+Errors")), typeFile)
+	w.Body.SetText(b)
+	w.markTextAsUnchanged()
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCommandsAreOrdered(t *testing.T) {
+	var set commandSet
+	for _, name := range []string{"Zerox", "◊", "Cmds*", "Acq", "|x", "Cmds", "Undo"} {
+		set.AddCommand(name, nil, "", "")
+	}
+
+	var names []string
+	for _, c := range set.Commands() {
+		names = append(names, c.name)
+	}
+
+	expected := []string{"Acq", "Cmds", "Cmds*", "Undo", "Zerox", "|x", "◊"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v but got %v", expected, names)
+	}
+}
+
+func TestHelpTextIsStable(t *testing.T) {
+	c := NewCommandExecutor(nil)
+
+	text := c.helpText()
+	for i := 0; i < 5; i++ {
+		if c.helpText() != text {
+			t.Fatalf("the help text changed between calls")
+		}
+	}
+
+	// The sections and the commands in them are in a fixed order.
+	order := []string{
+		"=== Misc ===",
+		"=== Commands ===",
+		"About  (◊Help About◊)",
+		"Zerox  (◊Help Zerox◊)",
+		"◊  (◊Help ◊◊)",
+		"=== Debug commands ===",
+		"Goroutines  (◊Help Dbg Goroutines◊)",
+		"Psrv  (◊Help Dbg Psrv◊)",
+	}
+	last := -1
+	for _, s := range order {
+		i := strings.Index(text, s)
+		if i < 0 {
+			t.Fatalf("expected the help text to contain %q", s)
+		}
+		if i < last {
+			t.Fatalf("expected %q to be later in the help text", s)
+		}
+		last = i
+	}
+}
+
+func TestHelpCommandsMatchHelpText(t *testing.T) {
+	c := NewCommandExecutor(nil)
+
+	cmds := c.helpCommands()
+	b, err := json.Marshal(cmds)
+	if err != nil {
+		t.Fatalf("encoding failed: %v", err)
+	}
+	var decoded []helpCommand
+	if err = json.Unmarshal(b, &decoded); err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+
+	text := c.helpText()
+	last := -1
+	debug := false
+	for _, cmd := range decoded {
+		if debug && !cmd.Debug {
+			t.Fatalf("expected the debug commands last but %s follows them", cmd.Name)
+		}
+		debug = cmd.Debug
+
+		line := cmd.Name + "  (◊Help " + cmd.Name + "◊)"
+		if cmd.Debug {
+			line = cmd.Name + "  (◊Help Dbg " + cmd.Name + "◊)"
+		}
+		i := strings.Index(text, line)
+		if i < last {
+			t.Fatalf("expected %s in the same order as the help text", cmd.Name)
+		}
+		last = i
+	}
+}
+
+func TestWinsListsWindowsByColumnThenPosition(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var ids []int
+	onMainGoroutine(func() {
+		left := editor.Cols[0]
+		right := editor.NewColDontPosition()
+
+		add := func(col *Col, name string) {
+			w := col.NewWindowDontPosition()
+			w.SetFilenameAndTag("/tmp/"+name, typeFile)
+			ids = append(ids, w.Id)
+		}
+		add(left, "zz")
+		add(left, "bb")
+		add(right, "aa")
+
+		NewCommandExecutor(nil).CmdWins(&CmdContext{})
+	})
+
+	var out string
+	onMainGoroutine(func() {
+		w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		if w != nil {
+			out = w.Body.String()
+		}
+	})
+
+	expected := "/tmp/zz\n/tmp/bb\n/tmp/aa\n"
+	if out != expected {
+		t.Fatalf("expected %q but got %q", expected, out)
+	}
+
+	onMainGoroutine(func() {
+		w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		w.Body.SetTextStringNoUndo("")
+		NewCommandExecutor(nil).CmdWins(&CmdContext{Args: []string{"-json"}})
+		out = w.Body.String()
+	})
+
+	var wins []apiWindow
+	if err := json.Unmarshal([]byte(out), &wins); err != nil {
+		t.Fatalf("decoding %q failed: %v", out, err)
+	}
+	if len(wins) < 3 {
+		t.Fatalf("expected at least 3 windows but got %d", len(wins))
+	}
+	for i, id := range ids {
+		if wins[i].Id != id {
+			t.Fatalf("expected window %d at %d but got %d", id, i, wins[i].Id)
+		}
+	}
+}
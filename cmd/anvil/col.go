@@ -6,6 +6,7 @@ import (
 	"image/color"
 	"math"
 	"strings"
+	"time"
 
 	"gioui.org/f32"
 	"gioui.org/layout"
@@ -40,8 +41,28 @@ type Col struct {
 	Scheduler *Scheduler
 	workChan  chan Work
 	visible   bool
+
+	// lastInteraction is the time the user last typed or clicked in the column.
+	lastInteraction time.Time
+	// pendingOutputGrowth are the windows that received output while the user was
+	// interacting with the column. They are grown once the user is idle.
+	pendingOutputGrowth []*Window
+	// outputResized are the windows to grow on the next layout because they received output.
+	outputResized []*Window
+	// widthPct is the percentage of the editor width the column was last given by Colwidth or Load.
+	widthPct colWidthPct
+	// windowsTopY is the Y position of the top of the windows within the column at the last layout.
+	windowsTopY int
 }
 
+// interactionIdleDelay is how long after the user last typed or clicked in a column
+// that the column is considered idle, and windows in it may be grown to show output.
+const interactionIdleDelay = 2 * time.Second
+
+// focusedWindowMinLines is the minimum number of body lines the focused window is
+// left with when another window in its column is grown to show output.
+const focusedWindowMinLines = 5
+
 type colLayouter struct {
 	layouter
 	gtx   layout.Context
@@ -70,6 +91,7 @@ func NewCol(style Style) *Col {
 	finder := NewFileFinder(nil)
 	r.Tag.Init(nil, style.tagBlockStyle(), style.tagEditableStyle(), executor, finder, r, r.Scheduler)
 	r.Tag.label = "column"
+	r.Tag.AddTextChangeListener(r.notifyApiTagChanged)
 	r.layoutBox.Init(style.layoutBoxStyle())
 	return r
 }
@@ -116,6 +138,7 @@ func (c *Col) DrawAndListenForEvents(gtx layout.Context) {
 	c.minimizeOtherWindowsExcept(rowHeaderHeight)
 	c.resizeWindows(rowHeaderHeight)
 	c.maximizeWindows(rowHeaderHeight)
+	c.windowsTopY = dims.Size.Y
 	c.layout.setOffsetAndLayoutWindows(gtx, dims.Size.Y)
 	c.removeWindowsMarkedForRemoval()
 	c.centerWindowsMarkedForCentering()
@@ -286,7 +309,7 @@ func (r *Col) positionWindows(rowHeaderHeight float32) {
 }
 
 func (r *Col) resizeWindows(rowHeaderHeight float32) {
-	if len(r.resized) == 0 {
+	if len(r.resized) == 0 && len(r.outputResized) == 0 {
 		return
 	}
 
@@ -301,6 +324,14 @@ func (r *Col) resizeWindows(rowHeaderHeight float32) {
 		ps = p.Grow(r, float32(amt))
 	}
 
+	focused, min := r.focusedWindowAndMinHeight(rowHeaderHeight)
+	for _, w := range r.outputResized {
+		if !w.bodyTooSmall() {
+			continue
+		}
+		ps = p.GrowFromLargest(w, float32(amt), focused, min)
+	}
+
 	r.setWindowsTo(ps)
 
 	for _, w := range toCenter {
@@ -308,6 +339,18 @@ func (r *Col) resizeWindows(rowHeaderHeight float32) {
 	}
 
 	r.resized = nil
+	r.outputResized = nil
+}
+
+// focusedWindowAndMinHeight returns the window in this column that has keyboard focus, or nil
+// if there is none, and the minimum height it may be shrunk to.
+func (r *Col) focusedWindowAndMinHeight(rowHeaderHeight float32) (w Packable, min float32) {
+	if editor == nil || editor.focusedWindow == nil || editor.focusedWindow.col != r {
+		return
+	}
+	w = editor.focusedWindow
+	min = rowHeaderHeight + float32(r.layout.lineHeight()*focusedWindowMinLines)
+	return
 }
 
 func (r *Col) copyWindows() []*Window {
@@ -472,6 +515,8 @@ func (r *Col) removeWindow(w *Window) {
 
 	editor.Completer().DeleteAllFromSource(w.Body.completionSource)
 	editor.AddRecentFile(w.file)
+	editor.follower.remove(w)
+	w.closeSpills()
 }
 
 func (c *Col) markForCentering(w *Window) {
@@ -516,6 +561,93 @@ func (r *Col) Grow(w *Window) {
 	r.resized = append(r.resized, w)
 }
 
+// NoteInteraction records that the user has just typed or clicked in the column.
+func (r *Col) NoteInteraction() {
+	r.lastInteraction = time.Now()
+}
+
+// GrowForOutput grows the window w, which has just received output. If the user has
+// recently interacted with the column the growth is delayed until they have been idle
+// for a while so that the layout doesn't shift under them.
+func (r *Col) GrowForOutput(w *Window) {
+	r.growForOutputAt(w, time.Now())
+}
+
+func (r *Col) growForOutputAt(w *Window, now time.Time) {
+	if settings.Layout.GrowOutputWindowsImmediately {
+		r.Grow(w)
+		return
+	}
+
+	d := idleDelayRemaining(r.lastInteraction, now)
+	if d == 0 {
+		r.growOutputWindow(w)
+		return
+	}
+
+	if !windowsContain(r.pendingOutputGrowth, w) {
+		r.pendingOutputGrowth = append(r.pendingOutputGrowth, w)
+	}
+	r.schedulePendingOutputGrowth(d)
+}
+
+func (r *Col) growOutputWindow(w *Window) {
+	if r.maximizedWindow != nil && len(r.minimizedExcept) == 0 {
+		return
+	}
+	if !windowsContain(r.outputResized, w) {
+		r.outputResized = append(r.outputResized, w)
+	}
+}
+
+func (r *Col) schedulePendingOutputGrowth(d time.Duration) {
+	if r.Scheduler == nil {
+		return
+	}
+	r.Scheduler.AfterFunc(fmt.Sprintf("col-%d-output-growth", r.Id), d, func() {
+		r.applyPendingOutputGrowthAt(time.Now())
+	})
+}
+
+// applyPendingOutputGrowthAt grows the windows that received output while the user was
+// interacting with the column, if the user is now idle. Otherwise it waits longer.
+func (r *Col) applyPendingOutputGrowthAt(now time.Time) {
+	if len(r.pendingOutputGrowth) == 0 {
+		return
+	}
+
+	d := idleDelayRemaining(r.lastInteraction, now)
+	if d > 0 {
+		r.schedulePendingOutputGrowth(d)
+		return
+	}
+
+	for _, w := range r.pendingOutputGrowth {
+		if w.col == r {
+			r.growOutputWindow(w)
+		}
+	}
+	r.pendingOutputGrowth = nil
+}
+
+func windowsContain(l []*Window, w *Window) bool {
+	return slice.SliceContains(l, func(i int) bool { return l[i] == w })
+}
+
+// idleDelayRemaining returns how much longer the user must be idle after interacting
+// at time lastInteraction before it is considered idle at time now.
+func idleDelayRemaining(lastInteraction, now time.Time) time.Duration {
+	if lastInteraction.IsZero() {
+		return 0
+	}
+
+	d := lastInteraction.Add(interactionIdleDelay).Sub(now)
+	if d < 0 {
+		return 0
+	}
+	return d
+}
+
 func (r *Col) MinimizeAllExcept(w *Window) {
 	r.minimizedExcept = append(r.minimizedExcept, w)
 }
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOutputGrowthDeferredWhileInteracting(t *testing.T) {
+	newTestEditor()
+	c := editor.Cols[0]
+	focused := c.NewWindowDontPosition()
+	errs := c.NewWindowDontPosition()
+	focused.TopY, errs.TopY = 0, 400
+	c.vspace = 800
+	const rowHeaderHeight = 20
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	type step struct {
+		at     time.Duration
+		typing bool
+		output bool
+		apply  bool
+		// wantGrown is whether the errors window should have grown once the column is laid
+		// out after this step
+		wantGrown bool
+	}
+
+	steps := []step{
+		{at: 0, typing: true},
+		{at: 500 * time.Millisecond, output: true},
+		{at: 1 * time.Second, typing: true},
+		{at: 1500 * time.Millisecond, output: true},
+		{at: 2500 * time.Millisecond, apply: true},
+		{at: 2900 * time.Millisecond, typing: true},
+		{at: 3 * time.Second, apply: true},
+		{at: 5 * time.Second, apply: true, wantGrown: true},
+	}
+
+	for i, s := range steps {
+		now := start.Add(s.at)
+		if s.typing {
+			c.lastInteraction = now
+		}
+		if s.output {
+			c.growForOutputAt(errs, now)
+		}
+		if s.apply {
+			c.applyPendingOutputGrowthAt(now)
+		}
+		c.resizeWindows(rowHeaderHeight)
+
+		grown := errs.TopY < 400
+		if grown != s.wantGrown {
+			t.Fatalf("step %d: expected the errors window to have grown to be %v but its top is at %d", i, s.wantGrown, errs.TopY)
+		}
+		if focused.TopY != 0 {
+			t.Fatalf("step %d: expected the focused window to stay at the top but it is at %d", i, focused.TopY)
+		}
+	}
+
+	if len(c.pendingOutputGrowth) != 0 {
+		t.Fatalf("expected no pending growth but there were %d windows", len(c.pendingOutputGrowth))
+	}
+	if want := 400 - c.layout.lineHeight()*10; errs.TopY != want {
+		t.Fatalf("expected the errors window to grow once, to the top %d, but its top is at %d", want, errs.TopY)
+	}
+}
+
+func TestOutputGrowthWhenIdle(t *testing.T) {
+	w := &Window{}
+	c := &Col{Windows: []*Window{w}}
+	w.col = c
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.lastInteraction = now.Add(-3 * time.Second)
+	c.growForOutputAt(w, now)
+
+	if !windowsContain(c.outputResized, w) {
+		t.Fatalf("expected window to be queued to grow immediately")
+	}
+	if len(c.pendingOutputGrowth) != 0 {
+		t.Fatalf("expected no pending growth")
+	}
+}
+
+func TestGrowFromLargest(t *testing.T) {
+	tests := []struct {
+		name       string
+		coords     []float32
+		change     int
+		extra      float32
+		protect    int
+		protectMin float32
+		expected   []float32
+	}{
+		{
+			name:     "take from largest below",
+			coords:   []float32{0, 100, 120},
+			change:   1,
+			extra:    50,
+			protect:  -1,
+			expected: []float32{0, 100, 170},
+		},
+		{
+			name:     "take from largest above",
+			coords:   []float32{0, 800, 900},
+			change:   2,
+			extra:    50,
+			protect:  -1,
+			expected: []float32{0, 750, 850},
+		},
+		{
+			name:       "protected window kept at minimum",
+			coords:     []float32{0, 600, 700},
+			change:     2,
+			extra:      200,
+			protect:    0,
+			protectMin: 500,
+			expected:   []float32{0, 500, 520},
+		},
+		{
+			name:     "largest exhausted",
+			coords:   []float32{0, 60, 120, 900},
+			change:   3,
+			extra:    800,
+			protect:  -1,
+			expected: []float32{0, 20, 80, 100},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			wins := make([]*Window, len(tc.coords))
+			ps := make([]Packable, len(tc.coords))
+			for i, c := range tc.coords {
+				wins[i] = &Window{TopY: int(c)}
+				ps[i] = wins[i]
+			}
+
+			var protect Packable
+			if tc.protect >= 0 {
+				protect = wins[tc.protect]
+			}
+
+			p := NewPacker(20, 1000, ps)
+			p.GrowFromLargest(wins[tc.change], tc.extra, protect, tc.protectMin)
+
+			for i, w := range wins {
+				if float32(w.TopY) != tc.expected[i] {
+					t.Fatalf("window %d: expected coord %f but got %d", i, tc.expected[i], w.TopY)
+				}
+			}
+		})
+	}
+}
+
+func TestColwidthRoundTripsThroughDumpAndLoad(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var pcts, loadedPcts []float64
+	var hiddenPct float64
+	var err error
+	onMainGoroutine(func() {
+		editor.hspace = 1000
+		editor.NewCol()
+		hidden := editor.NewCol()
+		editor.NewCol()
+		editor.positionCols()
+		hidden.SetVisible(false)
+		editor.positionCols()
+
+		err = editor.SetColWidthPct(editor.VisibleCols()[0], 70)
+		if err != nil {
+			return
+		}
+		pcts = editor.ColWidthPcts()
+
+		var b []byte
+		b, err = json.Marshal(editor.State())
+		if err != nil {
+			return
+		}
+		var state EditorState
+		err = json.Unmarshal(b, &state)
+		if err != nil {
+			return
+		}
+		for _, c := range state.Cols {
+			if !c.Visible {
+				hiddenPct = c.WidthPct
+			}
+		}
+
+		// Load the state into an editor of a different width.
+		editor.SetState(&state)
+		editor.hspace = 1337
+		editor.positionCols()
+		loadedPcts = editor.ColWidthPcts()
+	})
+
+	if err != nil {
+		t.Fatalf("setting the width or saving the state failed: %v", err)
+	}
+
+	if len(pcts) != 3 || pcts[0] != 70 || math.Abs(pcts[1]+pcts[2]-30) > 1e-9 || pcts[1] != pcts[2] {
+		t.Fatalf("expected the first column to be 70%% and the rest to share 30%% but got %v", pcts)
+	}
+
+	if hiddenPct != 0 {
+		t.Fatalf("expected the hidden column not to have a width but got %v", hiddenPct)
+	}
+
+	if !reflect.DeepEqual(pcts, loadedPcts) {
+		t.Fatalf("expected the loaded widths %v to be %v", loadedPcts, pcts)
+	}
+}
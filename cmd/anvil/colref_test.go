@@ -0,0 +1,117 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestFindColByIndexAndName(t *testing.T) {
+	h := newTestHeadless()
+	first := editor.Cols[0]
+	first.Tag.SetTextStringNoUndo(settings.Layout.ColumnTag)
+	src := editor.NewCol()
+	src.Tag.SetTextStringNoUndo("src New Delcol")
+	other := editor.NewCol()
+	other.Tag.SetTextStringNoUndo("src Delcol")
+	h.Frame()
+
+	tests := []struct {
+		ref      string
+		expected *Col
+		err      string
+	}{
+		{"1", first, ""},
+		{" 3 ", other, ""},
+		{"Col " + strconv.Itoa(first.Id), first, ""},
+		{"4", nil, "there is no column 4"},
+		{"lib", nil, "there is no column named lib"},
+		{"src", nil, "Use the index of one of them instead: 2, 3"},
+	}
+
+	for _, tc := range tests {
+		col, err := editor.FindCol(tc.ref)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Fatalf("for %q expected an error containing %q but got %v", tc.ref, tc.err, err)
+			}
+			continue
+		}
+		if err != nil || col != tc.expected {
+			t.Fatalf("for %q expected column %d but got %v, %v", tc.ref, tc.expected.Id, col, err)
+		}
+	}
+
+	if refs := []string{editor.colRef(0), editor.colRef(1)}; refs[0] != first.Name() || refs[1] != "2" {
+		t.Fatalf("expected the columns to be listed as %q and \"2\" but got %q", first.Name(), refs)
+	}
+}
+
+func TestColumnCommandsFromEditorTag(t *testing.T) {
+	h := newTestHeadless()
+	first := editor.Cols[0]
+	first.Tag.SetTextStringNoUndo(settings.Layout.ColumnTag)
+	lib := editor.NewCol()
+	lib.Tag.SetTextStringNoUndo("lib New Delcol")
+	h.Frame()
+
+	h.Execute(&editor.Tag, "Hidecol lib")
+	if lib.Visible() {
+		t.Fatalf("expected Hidecol lib to hide the column")
+	}
+	h.Execute(&editor.Tag, "Showcol 2")
+	if !lib.Visible() {
+		t.Fatalf("expected Showcol 2 to show the column")
+	}
+
+	h.Execute(&editor.Tag, "Only lib")
+	if first.Visible() || !lib.Visible() {
+		t.Fatalf("expected Only lib to hide the other column")
+	}
+	h.Execute(&editor.Tag, "Showcol")
+
+	h.Execute(&editor.Tag, "Delcol")
+	errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+	if errs == nil || !strings.Contains(errs.Body.String(), "Delcol: the name or index of a column is needed") {
+		t.Fatalf("expected Delcol without a column to report an error in +Errors")
+	}
+
+	h.Execute(&editor.Tag, "Delcol lib")
+	h.Frame()
+	for _, c := range editor.Cols {
+		if c == lib {
+			t.Fatalf("expected Delcol lib to delete the column")
+		}
+	}
+}
+
+func TestColumnCommandsCountHiddenColumns(t *testing.T) {
+	h := newTestHeadless()
+	first := editor.Cols[0]
+	first.Tag.SetTextStringNoUndo(settings.Layout.ColumnTag)
+	hidden := editor.NewCol()
+	hidden.Tag.SetTextStringNoUndo("hidden New Delcol")
+	last := editor.NewCol()
+	last.Tag.SetTextStringNoUndo("last New Delcol")
+	h.Frame()
+	h.Execute(&editor.Tag, "Hidecol hidden")
+
+	if !strings.Contains(editor.ListCols(false, false), "2 hidden (hidden)\n3 last\n") {
+		t.Fatalf("expected Cols to number the hidden column but it lists %q", editor.ListCols(false, false))
+	}
+	col, err := editor.FindCol("3")
+	if err != nil || col != last {
+		t.Fatalf("expected FindCol to find the last column as column 3 but got %v, %v", col, err)
+	}
+	col, msg, err := editor.findColForMoveto("3")
+	if err != nil || msg != "" || col != last {
+		t.Fatalf("expected Moveto to find the last column as column 3 but got %v, %q, %v", col, msg, err)
+	}
+
+	w := first.NewWindow()
+	h.Frame()
+	h.Execute(&w.Tag, "Moveto 2")
+	if w.col != hidden || !hidden.Visible() {
+		t.Fatalf("expected Moveto 2 to move the window to the hidden column and show it")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// colWidthPct records the percentage of the editor width that a column was set to. Since
+// the column's position is stored in pixels the percentage can't be recovered exactly from
+// it, so it is kept as long as the column and editor are still the size they were when it
+// was set.
+type colWidthPct struct {
+	pct    float64
+	width  int
+	hspace float32
+}
+
+// ColWidthPcts returns the percentage of the editor width taken by each visible column, in
+// the order returned by VisibleCols.
+func (e *Editor) ColWidthPcts() []float64 {
+	cols := e.VisibleCols()
+	pcts := make([]float64, len(cols))
+	if e.hspace <= 0 {
+		return pcts
+	}
+
+	for i, c := range cols {
+		w := e.colWidth(i)
+		if c.widthPct.pct > 0 && c.widthPct.width == int(w) && c.widthPct.hspace == e.hspace {
+			pcts[i] = c.widthPct.pct
+			continue
+		}
+		pcts[i] = float64(w) * 100 / float64(e.hspace)
+	}
+	return pcts
+}
+
+// SetColWidthPct resizes the visible column c to take pct percent of the editor width. The
+// remaining width is shared by the other visible columns in proportion to their current widths.
+func (e *Editor) SetColWidthPct(c *Col, pct float64) error {
+	if pct <= 0 || pct >= 100 {
+		return fmt.Errorf("the width must be a percentage greater than 0 and less than 100")
+	}
+
+	cols := e.VisibleCols()
+	if len(cols) < 2 {
+		return fmt.Errorf("there are no other visible columns to take the remaining width")
+	}
+
+	index := -1
+	for i, v := range cols {
+		if v == c {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("the column is not visible")
+	}
+
+	pcts := e.ColWidthPcts()
+	rest := 100 - pcts[index]
+	for i := range pcts {
+		if i == index {
+			continue
+		}
+		if rest > 0 {
+			pcts[i] = pcts[i] * (100 - pct) / rest
+		} else {
+			pcts[i] = (100 - pct) / float64(len(pcts)-1)
+		}
+	}
+	pcts[index] = pct
+
+	e.setColWidthPcts(cols, pcts)
+	return nil
+}
+
+// setColWidthPcts positions the columns cols, which must be visible and in order, so that each
+// takes the corresponding percentage in pcts of the editor width. The percentages are scaled so
+// that they add up to 100.
+func (e *Editor) setColWidthPcts(cols []*Col, pcts []float64) {
+	if e.hspace <= 0 || len(cols) == 0 || len(cols) != len(pcts) {
+		return
+	}
+
+	total := float64(0)
+	for _, p := range pcts {
+		total += p
+	}
+	if total <= 0 {
+		return
+	}
+
+	sizes := make([]float32, len(pcts))
+	for i, p := range pcts {
+		pcts[i] = p * 100 / total
+		sizes[i] = float32(pcts[i] * float64(e.hspace) / 100)
+	}
+
+	ps := make([]Packable, len(cols))
+	for i, c := range cols {
+		ps[i] = c
+	}
+	p := NewPacker(0, e.hspace, ps)
+	p.Resize(sizes)
+
+	for i, c := range cols {
+		c.widthPct = colWidthPct{pct: pcts[i], width: int(e.colWidth(i)), hspace: e.hspace}
+	}
+	e.SignalRedrawRequired()
+}
+
+// applyLoadedColWidthPcts positions the visible columns using the percentages loaded from
+// the state by SetState. It is done during layout since the width of the editor might not
+// be known when the state is loaded.
+func (e *Editor) applyLoadedColWidthPcts() {
+	if !e.colWidthPctsLoaded || e.hspace <= 0 {
+		return
+	}
+	e.colWidthPctsLoaded = false
+
+	cols := e.VisibleCols()
+	pcts := make([]float64, len(cols))
+	for i, c := range cols {
+		if c.widthPct.pct <= 0 {
+			// Older state files only contain the column positions in pixels.
+			return
+		}
+		pcts[i] = c.widthPct.pct
+	}
+
+	e.setColWidthPcts(cols, pcts)
+}
+
+func (e *Editor) formatColWidthPcts() string {
+	var buf bytes.Buffer
+	pcts := e.ColWidthPcts()
+	for i, c := range e.VisibleCols() {
+		fmt.Fprintf(&buf, "%s: %.1f%%\n", c.Name(), pcts[i])
+	}
+	return buf.String()
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jeffwilliams/anvil/internal/words"
+)
+
+// completionSourceCheckInterval is how long completing a word trusts what it knows about the
+// files used as sources of completions before they are looked at again in the background.
+const completionSourceCheckInterval = 2 * time.Second
+
+var errCompletionIndexLoadKilled = errors.New("loading was killed")
+
+// completionIndex holds the sorted names from a file used as a source of completions, like a
+// completion dictionary or a tags file. Such files can be huge, so completing a word never reads
+// the file or even waits to stat it. The file is checked in the background the first time the
+// names are needed, and again when they are needed after completionSourceCheckInterval, and it is
+// loaded by a job if it was modified. Until then the completions are from the file as it was
+// before, if any, and the word completions waiting for the file are refreshed once it is loaded.
+// It is only used by the editor goroutine.
+type completionIndex struct {
+	// kind names the kind of file in messages, like "tags file", and jobName names the job
+	// loading it.
+	kind    string
+	jobName string
+	// path is the path of the file as it was given, and file is the path that is opened.
+	path string
+	file string
+	// source is the source listed for the completions from the file.
+	source string
+	// read reads the names in the file. They must be sorted and unique.
+	read func(r io.Reader) ([]string, error)
+
+	// names are the names in the file as it was when it was modified at mtime.
+	names []string
+	mtime time.Time
+	// checked is when the file was last checked for modifications, and checking is set while
+	// that is done.
+	checked  time.Time
+	checking bool
+	// job is the job loading the file, or nil if it isn't being loaded.
+	job *completionIndexLoadJob
+	// err is the last error checking or loading the file. An error is only reported when it
+	// is different from the last.
+	err string
+}
+
+// completions returns the names in the file that start with prefix. If the file is checked or
+// loaded because of this, the word completion in e, which may be nil, is refreshed once it is
+// loaded.
+func (x *completionIndex) completions(prefix string, e *editable) (comps []words.Completion) {
+	x.update(e)
+
+	i := sort.SearchStrings(x.names, prefix)
+	for _, n := range x.names[i:] {
+		if !strings.HasPrefix(n, prefix) {
+			break
+		}
+		if n != prefix {
+			comps = append(comps, words.NewCompletion(n, x.source))
+		}
+	}
+	return
+}
+
+// update checks the file for modifications in the background unless it was checked recently,
+// and has the word completion in e refreshed once the file is loaded.
+func (x *completionIndex) update(e *editable) {
+	if x.checking || x.job != nil {
+		awaitCompletionSources(e)
+		return
+	}
+	if !x.checked.IsZero() && time.Since(x.checked) < completionSourceCheckInterval {
+		return
+	}
+
+	x.checked = time.Now()
+	x.checking = true
+	awaitCompletionSources(e)
+	go func() {
+		fi, err := os.Stat(x.file)
+		editor.WorkChan() <- basicWork{func() { x.checkDone(fi, err) }}
+	}()
+}
+
+func (x *completionIndex) checkDone(fi os.FileInfo, err error) {
+	x.checking = false
+	if err != nil {
+		x.names = nil
+		x.mtime = time.Time{}
+		x.reportError(err)
+		return
+	}
+	if fi.ModTime().Equal(x.mtime) {
+		return
+	}
+
+	x.job = &completionIndexLoadJob{index: x, mtime: fi.ModTime(), kill: make(chan struct{})}
+	editor.AddJob(x.job)
+	go x.job.run()
+}
+
+func (x *completionIndex) reportError(err error) {
+	if err.Error() == x.err {
+		return
+	}
+	x.err = err.Error()
+	log(LogCatgCompletion, "Loading the %s %s failed: %v\n", x.kind, x.path, err)
+	editor.AppendError("", fmt.Sprintf("Loading the %s %s for completion failed: %v", x.kind, x.path, err))
+}
+
+// completionsAwaitingSources holds the editables whose word completion was begun while a source
+// of completions it used was being checked or loaded. They are refreshed when one is loaded. It
+// is only used by the editor goroutine.
+var completionsAwaitingSources = map[*editable]struct{}{}
+
+func awaitCompletionSources(e *editable) {
+	if e != nil {
+		completionsAwaitingSources[e] = struct{}{}
+	}
+}
+
+func refreshCompletionsAwaitingSources() {
+	for e := range completionsAwaitingSources {
+		e.refreshWordCompletion()
+	}
+	completionsAwaitingSources = map[*editable]struct{}{}
+}
+
+// completionIndexLoadJob loads the names from the file of a completionIndex in the background.
+type completionIndexLoadJob struct {
+	index *completionIndex
+	mtime time.Time
+	kill  chan struct{}
+	once  sync.Once
+	names []string
+	err   error
+}
+
+func (j *completionIndexLoadJob) Name() string {
+	return j.index.jobName
+}
+
+func (j *completionIndexLoadJob) Kill() {
+	j.once.Do(func() { close(j.kill) })
+}
+
+func (j *completionIndexLoadJob) run() {
+	f, err := os.Open(j.index.file)
+	if err == nil {
+		j.names, err = j.index.read(killableReader{f, j.kill})
+		f.Close()
+	}
+	j.err = err
+	editor.WorkChan() <- &completionIndexLoaded{job: j}
+}
+
+// killableReader reads from r until kill is closed.
+type killableReader struct {
+	r    io.Reader
+	kill chan struct{}
+}
+
+func (k killableReader) Read(p []byte) (int, error) {
+	select {
+	case <-k.kill:
+		return 0, errCompletionIndexLoadKilled
+	default:
+	}
+	return k.r.Read(p)
+}
+
+type completionIndexLoaded struct {
+	job *completionIndexLoadJob
+}
+
+func (l completionIndexLoaded) Service() (done bool) {
+	j := l.job
+	x := j.index
+	x.job = nil
+	// The file isn't loaded again until it changes, even if loading it failed or was killed.
+	x.mtime = j.mtime
+
+	switch {
+	case j.err == nil:
+		x.names = j.names
+		x.err = ""
+		log(LogCatgCompletion, "Loaded %d names from %s %s\n", len(x.names), x.kind, x.path)
+		refreshCompletionsAwaitingSources()
+	case errors.Is(j.err, errCompletionIndexLoadKilled):
+		log(LogCatgCompletion, "Loading %s %s was killed\n", x.kind, x.path)
+	default:
+		x.reportError(j.err)
+	}
+	return true
+}
+
+func (l completionIndexLoaded) Job() Job {
+	return l.job
+}
+
+func (l completionIndexLoaded) priority() workPriority {
+	return workPriorityLow
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jeffwilliams/anvil/internal/runes"
+	"github.com/jeffwilliams/anvil/internal/words"
+)
+
+// envVarCompletionSource is the source listed for completions of environment variable names.
+const envVarCompletionSource = "environment"
+
+// dictionaries holds the completion dictionaries by path. It is only used by the editor goroutine.
+var dictionaries = map[string]*completionIndex{}
+
+// dictionaryFor returns the completion dictionary at path, which is a file of words, one per line.
+func dictionaryFor(path string) *completionIndex {
+	d, ok := dictionaries[path]
+	if !ok {
+		file := path
+		if strings.HasPrefix(file, "~/") {
+			if home, err := os.UserHomeDir(); err == nil {
+				file = filepath.Join(home, file[2:])
+			}
+		}
+		d = &completionIndex{
+			kind:    "completion dictionary",
+			jobName: "Dict",
+			path:    path,
+			file:    file,
+			source:  "dictionary:" + path,
+			read:    readDictionary,
+		}
+		dictionaries[path] = d
+	}
+	return d
+}
+
+// readDictionary reads the sorted, unique words in a completion dictionary.
+func readDictionary(r io.Reader) (list []string, err error) {
+	s := bufio.NewScanner(r)
+	for s.Scan() {
+		if w := strings.TrimSpace(s.Text()); w != "" {
+			list = append(list, w)
+		}
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(list)
+	unique := list[:0]
+	for _, w := range list {
+		if len(unique) == 0 || w != unique[len(unique)-1] {
+			unique = append(unique, w)
+		}
+	}
+	return unique, nil
+}
+
+// envVarCompletions returns the names of the environment variables, including those set in the
+// env settings, that start with prefix.
+func envVarCompletions(prefix string) (comps []words.Completion) {
+	names := map[string]bool{}
+	for _, kv := range os.Environ() {
+		name, _, _ := strings.Cut(kv, "=")
+		names[name] = true
+	}
+	for name := range settings.Env {
+		names[name] = true
+	}
+
+	for name := range names {
+		if name != prefix && strings.HasPrefix(name, prefix) {
+			comps = append(comps, words.NewCompletion(name, envVarCompletionSource))
+		}
+	}
+	return
+}
+
+// staticCompletions returns the completions of prefix from the sources configured in the
+// completion settings. Unlike the words in the open windows they don't depend on the size of
+// the window. afterDollar is true if the word being completed follows a $. The word completion
+// in e, which may be nil, is refreshed when a dictionary that was being loaded is loaded.
+func staticCompletions(e *editable, prefix string, afterDollar bool) []words.Completion {
+	var lists [][]words.Completion
+	if prefix != "" {
+		for _, path := range settings.Completion.Dictionaries {
+			lists = append(lists, dictionaryFor(path).completions(prefix, e))
+		}
+	}
+	if afterDollar && settings.Completion.EnvVars {
+		lists = append(lists, envVarCompletions(prefix))
+	}
+	return words.MergeCompletions(lists...)
+}
+
+// followsDollar returns true if the rune before runeIndex is a $.
+func (e *editableModel) followsDollar(runeIndex int) bool {
+	if runeIndex <= 0 {
+		return false
+	}
+	w := runes.NewWalker(e.Bytes())
+	w.SetRunePosCache(runeIndex-1, &e.runeOffsetCache)
+	return w.Rune() == '$'
+}
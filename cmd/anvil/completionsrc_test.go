@@ -0,0 +1,100 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jeffwilliams/anvil/internal/pctbl"
+	"github.com/jeffwilliams/anvil/internal/runes"
+	"github.com/jeffwilliams/anvil/internal/words"
+)
+
+func setCompletionSettingsForTest(t *testing.T, s CompletionSettings) {
+	saved := settings.Completion
+	settings.Completion = s
+	t.Cleanup(func() { settings.Completion = saved })
+}
+
+func completionWords(comps []words.Completion) (w []string) {
+	for _, c := range comps {
+		w = append(w, c.Word())
+	}
+	return
+}
+
+func TestStaticCompletionsFromDictionary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glossary")
+	err := os.WriteFile(path, []byte("anvil\n  anneal \nanvil's\n\nbellows\n"), 0644)
+	if err != nil {
+		t.Fatalf("writing dictionary failed: %v", err)
+	}
+	setCompletionSettingsForTest(t, CompletionSettings{Dictionaries: []string{path}})
+	h := newTestHeadless()
+	dictionaries = map[string]*completionIndex{}
+
+	// Completing doesn't wait for the dictionary to be loaded.
+	if comps := staticCompletions(nil, "an", false); len(comps) != 0 {
+		t.Fatalf("expected no completions before the dictionary is loaded but got %v", completionWords(comps))
+	}
+	waitForCompletionSources(t, h)
+
+	comps := staticCompletions(nil, "an", false)
+	expected := []string{"anneal", "anvil", "anvil's"}
+	got := completionWords(comps)
+	if len(got) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, got)
+		}
+	}
+	if src := comps[0].Sources(); len(src) != 1 || src[0] != "dictionary:"+path {
+		t.Fatalf("expected the dictionary to be the source but got %v", src)
+	}
+
+	if comps := staticCompletions(nil, "", false); len(comps) != 0 {
+		t.Fatalf("expected no dictionary completions for an empty prefix but got %v", completionWords(comps))
+	}
+}
+
+func TestStaticCompletionsOfEnvVars(t *testing.T) {
+	t.Setenv("ANVIL_TEST_COMPLETION_VAR", "1")
+	setCompletionSettingsForTest(t, CompletionSettings{EnvVars: true})
+
+	if comps := staticCompletions(nil, "ANVIL_TEST_COMP", false); len(comps) != 0 {
+		t.Fatalf("expected no environment variables unless the word follows a $ but got %v", completionWords(comps))
+	}
+
+	comps := staticCompletions(nil, "ANVIL_TEST_COMP", true)
+	if len(comps) != 1 || comps[0].Word() != "ANVIL_TEST_COMPLETION_VAR" || comps[0].Sources()[0] != envVarCompletionSource {
+		t.Fatalf("expected the environment variable to be completed but got %v", comps)
+	}
+
+	setCompletionSettingsForTest(t, CompletionSettings{EnvVars: false})
+	if comps := staticCompletions(nil, "ANVIL_TEST_COMP", true); len(comps) != 0 {
+		t.Fatalf("expected no environment variables when env-vars is off but got %v", completionWords(comps))
+	}
+}
+
+func TestFollowsDollar(t *testing.T) {
+	var e editableModel
+	e.text = pctbl.Optimize(pctbl.NewPieceTable([]byte("echo $HOME héllo$X")))
+	e.runeOffsetCache = runes.NewOffsetCache(0)
+
+	tests := []struct {
+		index    int
+		expected bool
+	}{
+		{0, false},
+		{6, true},
+		{5, false},
+		{17, true},
+	}
+	for _, tc := range tests {
+		if e.followsDollar(tc.index) != tc.expected {
+			t.Fatalf("for index %d expected %v", tc.index, tc.expected)
+		}
+	}
+}
@@ -33,7 +33,21 @@ func SshKeyDir() string {
 	return fmt.Sprintf("%s/%s", ConfDir, "sshkeys")
 }
 
+// sshKeysInit loads the ssh keys from the ssh key directory. They aren't needed until the
+// first connection to a remote host is made.
+var sshKeysInit = &lazyInit{
+	name:    "load ssh keys",
+	load:    readSshKeys,
+	install: func() { sshClientCache.AddKeys(loadedSshKeys) },
+}
+
+var loadedSshKeys = map[string][]byte{}
+
 func LoadSshKeys() {
+	sshKeysInit.ensure()
+}
+
+func readSshKeys() {
 	d := SshKeyDir()
 	entries, err := os.ReadDir(d)
 	if err != nil {
@@ -44,7 +58,12 @@ func LoadSshKeys() {
 	for _, e := range entries {
 		log(LogCatgConf, "Loading ssh key %s\n", e.Name())
 		path := filepath.Join(d, e.Name())
-		sshClientCache.AddKeyFromFile(e.Name(), path)
+		key, err := os.ReadFile(path)
+		if err != nil {
+			log(LogCatgConf, "Error reading ssh key %s: %v\n", path, err)
+			continue
+		}
+		loadedSshKeys[e.Name()] = key
 	}
 }
 
@@ -96,6 +115,13 @@ func LoadStyleFromFile(path string, defaults *Style) (s Style, err error) {
 		return
 	}
 
+	err = loadStyleFonts(&s)
+	return
+}
+
+// loadStyleFonts sets the font faces of the fonts in s, loading them from font files if they
+// are not one of the default fonts.
+func loadStyleFonts(s *Style) (err error) {
 	for i, f := range s.Fonts {
 		s.Fonts[i].FontFace = VariableFont
 		if f.FontName != "" {
@@ -120,7 +146,20 @@ func LoadStyleFromFile(path string, defaults *Style) (s Style, err error) {
 	}
 
 	return
+}
 
+// useDefaultFontsUntilLoaded replaces the fonts in s that must be loaded from font files with the
+// default variable font, and returns true if there were any.
+func useDefaultFontsUntilLoaded(s *Style) (replaced bool) {
+	for i, f := range s.Fonts {
+		if f.FontName == "" || f.FontName == "defaultMonoFont" || f.FontName == "defaultVariableFont" {
+			continue
+		}
+		s.Fonts[i].FontName = "defaultVariableFont"
+		s.Fonts[i].FontFace = VariableFont
+		replaced = true
+	}
+	return
 }
 
 func LoadCurrentStyleFromFile(path string, defaults *Style) (err error) {
@@ -129,6 +168,7 @@ func LoadCurrentStyleFromFile(path string, defaults *Style) (err error) {
 		return err
 	}
 	WindowStyle = s
+	styleReplacedSinceStartup = true
 	ansi.InitColors(WindowStyle.Ansi.AsColors())
 	editor.SetStyle(WindowStyle)
 
@@ -149,10 +189,12 @@ func LoadPlumbingRulesFromFile(path string) (rules []PlumbingRule, err error) {
 	if err != nil {
 		return
 	}
+	defer f.Close()
 
 	rules, err = ParsePlumbingRules(f)
-
-	defer f.Close()
+	if err != nil {
+		err = withConfigFile(err, path)
+	}
 	return
 }
 
@@ -160,13 +202,36 @@ func SettingsConfigFile() string {
 	return fmt.Sprintf("%s/%s", ConfDir, "settings.toml")
 }
 
+// TutorialProgressFile holds the number of the lesson of the Tutorial that was started last.
+func TutorialProgressFile() string {
+	return fmt.Sprintf("%s/%s", ConfDir, "tutorial")
+}
+
+// ClosedFilesFile holds the list of files most recently closed, that Reopen reopens.
+func ClosedFilesFile() string {
+	return fmt.Sprintf("%s/%s", ConfDir, "closed-files")
+}
+
+// RecoveryDir holds the text of windows with unsaved changes saved by autosave.
+func RecoveryDir() string {
+	return fmt.Sprintf("%s/%s", ConfDir, "recovery")
+}
+
 type Settings struct {
 	Ssh         SshSettings
 	Typesetting TypesettingSettings
 	Layout      LayoutSettings
 	General     GeneralSettings
+	Notify      NotifySettings
+	Cues        CueSettings
+	Mouse       MouseSettings
+	Keys        KeySettings
+	Completion  CompletionSettings
 	Env         map[string]string
 	Alias       map[string]string
+	// Tidy maps file extensions, like ".go", to the formatter command that Put pipes the body
+	// of windows for those files through before saving them.
+	Tidy map[string]string
 }
 
 type SshSettings struct {
@@ -174,6 +239,22 @@ type SshSettings struct {
 	CloseStdin        bool `toml:"close-stdin"`
 	CacheSize         int
 	ConnectionTimeout int `toml:"conn-timeout"`
+	// KeepaliveInterval is the number of seconds between keepalive probes of cached
+	// connections, or 0 to not probe them.
+	KeepaliveInterval int `toml:"keepalive-interval"`
+	// Hosts holds settings for particular hosts, by hostname. They override the settings above.
+	Hosts map[string]SshHostSettings `toml:"hosts"`
+}
+
+// SshHostSettings are the settings used for one ssh host. Empty fields use the defaults.
+type SshHostSettings struct {
+	Shell string `toml:"shell"`
+	// ShellString is used instead of the shell to run commands, in the same format as the
+	// argument to Shstr.
+	ShellString string            `toml:"shell-string"`
+	Env         map[string]string `toml:"env"`
+	User        string            `toml:"user"`
+	Port        int               `toml:"port"`
 }
 
 type TypesettingSettings struct {
@@ -181,28 +262,212 @@ type TypesettingSettings struct {
 }
 
 func LoadSettingsFromConfigFile(settings *Settings) (err error) {
-	var f *os.File
-	f, err = os.Open(SettingsConfigFile())
+	text, err := os.ReadFile(SettingsConfigFile())
 	if err != nil {
 		return
 	}
-	defer f.Close()
-
-	dec := toml.NewDecoder(f)
 
-	err = dec.Decode(settings)
+	err = decodeSettings(text, settings)
+	if err != nil {
+		err = withConfigFile(err, SettingsConfigFile())
+	}
 	return
+}
+
+// decodeSettings decodes the TOML text into settings. Syntax errors are returned as a
+// configError giving their position.
+func decodeSettings(text []byte, settings *Settings) error {
+	tree, err := toml.LoadBytes(text)
+	if err != nil {
+		return tomlConfigError(err)
+	}
 
+	err = tree.Unmarshal(settings)
+	if err != nil {
+		return configError{Msg: err.Error()}
+	}
+	return nil
 }
 
 type LayoutSettings struct {
 	EditorTag         string `toml:"editor-tag"`
 	ColumnTag         string `toml:"column-tag"`
 	WindowTagUserArea string `toml:"window-tag-user-area"`
+	// GrowOutputWindowsImmediately makes windows that receive output (like +Errors) grow
+	// as soon as output arrives, even if the user is typing in the same column.
+	GrowOutputWindowsImmediately bool `toml:"grow-output-windows-immediately"`
+	// DirtyMarker is shown at the start of the editor area of the tag of windows with unsaved changes.
+	DirtyMarker string `toml:"dirty-marker"`
+	// TagPathAbbreviation is how the path in a window tag is shortened when it is longer than
+	// TagPathMaxLength: none, middle, home or project.
+	TagPathAbbreviation string `toml:"tag-path-abbreviation"`
+	TagPathMaxLength    int    `toml:"tag-path-max-length"`
+	// WindowPlacement is the column new windows are placed in: most-free-space, active-column
+	// or right-most.
+	WindowPlacement string `toml:"window-placement"`
 }
 
 type GeneralSettings struct {
 	ExecuteOnStartup []string `toml:"exec"`
+	// SpillThreshold is the number of bytes of output from a command after which the rest of
+	// the output is written to a temporary file, and the window only shows part of it.
+	SpillThreshold int `toml:"spill-threshold"`
+	// OutputRateLimit is the most bytes of output from a command appended to an +Errors window
+	// each second. The rest is dropped. 0 means no limit.
+	OutputRateLimit int `toml:"output-rate-limit"`
+	// ErrorsMaxSize is the most characters an +Errors window holds. 0 means no limit.
+	ErrorsMaxSize int `toml:"errors-max-size"`
+	// ErrorsRing deletes the start of an +Errors window that is full to make room for more
+	// output, instead of dropping the output.
+	ErrorsRing bool `toml:"errors-ring"`
+	// Icon is the path to a PNG file to use as the icon of the application window.
+	Icon string `toml:"icon"`
+	// WhitespaceHints shows a marker at the end of files that don't end with a newline, and
+	// tints the indentation of lines indented differently from most of the file.
+	WhitespaceHints bool `toml:"whitespace-hints"`
+	// SensitivePatterns are shell patterns matched against the base name of files. Windows
+	// for files that match are sensitive: their bodies are hidden when idle.
+	SensitivePatterns []string `toml:"sensitive-patterns"`
+	// SensitiveIdleTimeout is the number of seconds without interaction after which the
+	// bodies of sensitive windows are hidden.
+	SensitiveIdleTimeout int `toml:"sensitive-idle-timeout"`
+	// RawOutput stops terminal escape sequences other than colors from being removed from
+	// command output shown in +Errors windows, and carriage returns from replacing the line.
+	RawOutput bool `toml:"raw-output"`
+	// DirSort is the order the entries of directories are listed in: name, mtime or size.
+	DirSort string `toml:"dir-sort"`
+	// DirDots is whether entries of directories that start with a dot are listed.
+	DirDots bool `toml:"dir-dots"`
+	// FileIndexMax is the most files kept in the index of a project used by Fuzzf, Find and
+	// filename completion.
+	FileIndexMax int `toml:"file-index-max"`
+	// AtomicSave saves files by writing a temporary file and renaming it over the file.
+	AtomicSave bool `toml:"atomic-save"`
+	// OpenallMax is the most files opened by one Openall command.
+	OpenallMax int `toml:"openall-max"`
+	// AutosaveInterval is how many seconds apart the text of windows with unsaved changes is
+	// saved to the recovery directory. 0 disables autosave.
+	AutosaveInterval int `toml:"autosave-interval"`
+	// GuessIndentation sets what Tab inserts and the tab width of a window from the indentation
+	// of the file loaded into it.
+	GuessIndentation bool `toml:"guess-indentation"`
+	// ScrollPips draws ticks in the scrollbar of windows at the matches of the last search, the
+	// marks in the file and the text coloured with Tint.
+	ScrollPips bool `toml:"scroll-pips"`
+	// TidyTimeout is the number of seconds a formatter run by Tidy or Put may take before it
+	// is killed.
+	TidyTimeout int `toml:"tidy-timeout"`
+	// ClosedFilesMax is the most closed files remembered for Reopen. 0 stops them being
+	// remembered.
+	ClosedFilesMax int `toml:"closed-files-max"`
+	// ErrorPatterns are regular expressions that find the diagnostics in +Errors windows used by
+	// Nexterr and Preverr. The groups named file and line, and optionally col and severity, give
+	// the position and severity.
+	ErrorPatterns []string `toml:"error-patterns"`
+	// CommandFooterAfter is the number of seconds a command that completed with exit 0 must
+	// run for before a footer saying how it ended is written after its output. Commands that
+	// fail always get a footer. 0 means only failed commands get one.
+	CommandFooterAfter int `toml:"command-footer-after"`
+	// CommandFooterAlways writes the footer after the output of every command.
+	CommandFooterAlways bool `toml:"command-footer-always"`
+}
+
+// NotifySettings control when Anvil asks for the user's attention while its window is
+// not focused.
+type NotifySettings struct {
+	// OnJobFailure requests attention when a job finishes with an error.
+	OnJobFailure bool `toml:"on-job-failure"`
+	// Jobs is a list of regular expressions. Attention is requested when a job with a
+	// name that matches one of them finishes.
+	Jobs []string `toml:"jobs"`
+	// Command is a command to run to show a notification when attention is requested.
+	Command string `toml:"command"`
+}
+
+// CueSettings map events, like a command finishing, to cues: a brief flash of the tag of the
+// window where the event happened, a command that is run, or both. Each event is off by default.
+type CueSettings struct {
+	// JobSucceeded is the cue when a command whose output goes to a window exits with status 0.
+	JobSucceeded CueEventSettings `toml:"job-succeeded"`
+	// JobFailed is the cue when such a command fails. Killed commands have no cue.
+	JobFailed CueEventSettings `toml:"job-failed"`
+	// RemotePut is the cue when a file on a remote host is saved.
+	RemotePut CueEventSettings `toml:"remote-put"`
+	// SearchWrapped is the cue when a search continues from the other end of the window.
+	SearchWrapped CueEventSettings `toml:"search-wrapped"`
+	// ExcludeWindows is a list of regular expressions. Events in windows whose file name
+	// matches one of them have no cue.
+	ExcludeWindows []string `toml:"exclude-windows"`
+	// CommandInterval is the fewest milliseconds between the start of two cue commands. Cue
+	// commands for events that occur sooner, or while the last one is running, are not run.
+	CommandInterval int `toml:"command-interval"`
+}
+
+// CueEventSettings is the cue for one event.
+type CueEventSettings struct {
+	Enabled bool `toml:"enabled"`
+	// Flash briefly inverts the colors of the tag of the window where the event happened, or of
+	// the editor tag if there is no such window.
+	Flash bool `toml:"flash"`
+	// Command is run when the event occurs. $1 is replaced with the name of the event.
+	Command string `toml:"command"`
+}
+
+// MouseSettings bind the mouse actions of editables to buttons and modifiers. Each is a list of
+// bindings like "cmd+primary"; see parseMouseBinding.
+type MouseSettings struct {
+	// Select places the cursor and selects text.
+	Select []string `toml:"select"`
+	// Execute executes the text clicked or swept.
+	Execute []string `toml:"execute"`
+	// Acquire opens or searches for the text clicked.
+	Acquire []string `toml:"acquire"`
+	// ExecuteWithArg is pressed while the execute button is held to execute with the last
+	// selection as the argument.
+	ExecuteWithArg []string `toml:"execute-with-arg"`
+	// Cut is clicked while the select button is held to cut the selection.
+	Cut []string `toml:"cut"`
+	// Paste is pressed while the select button is held to paste over the selection.
+	Paste []string `toml:"paste"`
+	// ScrollLines is the number of lines scrolled by each turn of the scroll wheel.
+	ScrollLines int `toml:"scroll-lines"`
+	// PreciseScroll scrolls by the distance in each scroll event instead of by ScrollLines, for
+	// touchpads that send many small scroll events.
+	PreciseScroll bool `toml:"precise-scroll"`
+	// ScrollMovesCursor moves the cursor when the window body is scrolled so that it stays
+	// visible, when there is one cursor and no selections.
+	ScrollMovesCursor bool `toml:"scroll-moves-cursor"`
+	// WarpToNewWindows moves the pointer over the body of a window opened by New, Acq, Zerox or
+	// by acquiring a file, like Acme does.
+	WarpToNewWindows bool `toml:"warp-to-new-windows"`
+}
+
+// KeySettings bind keys to actions that move the keyboard focus between windows. Each setting
+// is a list of bindings like "ctrl+alt+down".
+type KeySettings struct {
+	// FocusNext moves the focus to the next window down in the column.
+	FocusNext []string `toml:"focus-next"`
+	// FocusPrev moves the focus to the next window up in the column.
+	FocusPrev []string `toml:"focus-prev"`
+	// FocusNextCol moves the focus to a window in the column to the right.
+	FocusNextCol []string `toml:"focus-next-col"`
+	// FocusPrevCol moves the focus to a window in the column to the left.
+	FocusPrevCol []string `toml:"focus-prev-col"`
+	// FocusTag moves the focus between the tag and the body of the window.
+	FocusTag []string `toml:"focus-tag"`
+}
+
+// CompletionSettings add sources of words for word completion besides the open windows.
+type CompletionSettings struct {
+	// Dictionaries is a list of files that hold one word per line, such as /usr/share/dict/words
+	// or a project glossary. Each is loaded in the background the first time a word is completed,
+	// and again when it changes.
+	Dictionaries []string `toml:"dictionaries"`
+	// EnvVars completes the names of environment variables when the word follows a $.
+	EnvVars bool `toml:"env-vars"`
+	// Tags completes the names in the tags files found in the directory of the window and the
+	// directories above it.
+	Tags bool `toml:"tags"`
 }
 
 func GenerateSampleSettings() string {
@@ -214,6 +479,140 @@ func GenerateSampleSettings() string {
 # "ado"
 #]
 
+# spill-threshold is the number of bytes of output from a command after which the output
+# is written to a temporary file instead of the +Errors window. In place of the output the
+# window then shows only part of it, and the commands Head, Tail, Pgup, Pgdn, Search and Extract
+# in the window tag can be used to view the rest. When the output of more than one command was
+# written to a file, they apply to the output the cursor is in. Set to 0 to always keep all
+# output in the window.
+# The default is 16777216 (16 MB)
+#spill-threshold=16777216
+
+# output-rate-limit is the most bytes of output from one command that are written to an +Errors
+# window each second, so that a command that writes output very quickly doesn't make Anvil
+# unresponsive. The rest of the output is dropped and a line saying how many bytes were dropped
+# is written in its place. Output written to a temporary file because of spill-threshold is not
+# limited. Set to 0 for no limit.
+# The default is 1048576 (1 MB)
+#output-rate-limit=1048576
+
+# errors-max-size is the most characters an +Errors window holds. Once it is full, further
+# output is dropped, unless errors-ring is true in which case lines are deleted from the start
+# of the window to make room. Set to 0 for no limit.
+# The default is 33554432 (32 M)
+#errors-max-size=33554432
+# The default is false
+#errors-ring=false
+
+# icon is the path to a PNG file to use as the icon of the Anvil window instead of
+# the default icon.
+#icon="/path/to/icon.png"
+
+# whitespace-hints shows a struck-through return symbol at the end of files that don't end
+# with a newline, and faintly tints the indentation of lines indented with tabs in a file
+# mostly indented with spaces, or the reverse. The command Lintws hides or shows them for
+# a single window and reports what was found.
+#whitespace-hints=true
+
+# sensitive-patterns are shell patterns matched against the base name of files. Windows
+# for matching files are sensitive: their text is hidden behind blocks when there has been
+# no key press or click in them for sensitive-idle-timeout seconds, or as soon as the
+# Anvil window loses focus. A key press or click in the window shows it again. The body of
+# a sensitive window is not saved by Dump and can only be read using the API by commands
+# run from a sensitive window. The Sensitive command marks any window as sensitive.
+#sensitive-patterns=["*.env", ".env", "*id_rsa*", "*kubeconfig*"]
+#sensitive-idle-timeout=120
+
+# Command output shown in +Errors windows is cleaned up the way a terminal would show it:
+# escape sequences other than colors, like the ones progress bars use to move the cursor or
+# erase the line, are removed, and a carriage return makes the text after it replace the
+# line. Set raw-output to true to show the output exactly as it was written.
+# The default is false
+#raw-output=false
+
+# dir-sort is the order the entries of a directory are listed in when it is loaded in a
+# window: name, mtime (most recently modified first) or size (largest first). The Sort
+# command changes the order for one window.
+# The default is name
+#dir-sort="name"
+
+# dir-dots controls whether entries of a directory that start with a dot are listed. The
+# Dots command changes it for one window.
+# The default is true
+#dir-dots=true
+
+# file-index-max is the most files kept in the index of a project. The index is used by Fuzzf,
+# Find and filename completion; when a project has more files than this the index is truncated
+# and Find searches the directory itself.
+# The default is 200000
+#file-index-max=200000
+
+# atomic-save makes Put write the file to a temporary file in the same directory and then
+# rename it over the file, so that the file is not left truncated if Anvil or the machine
+# stops part way through saving. The mode and, where permitted, the owner of the file are
+# kept. Files in directories where a temporary file can't be created are written directly.
+# Since the rename replaces the file, other hard links to the file keep the old contents;
+# set atomic-save to false to write files directly instead.
+# The default is true
+#atomic-save=true
+
+# openall-max is the most files that one Openall command opens. The lines after that many are
+# skipped, and the number skipped is reported in +Errors. Set it to 0 for no limit.
+# The default is 100
+#openall-max=100
+
+# autosave-interval is how many seconds apart the text of windows with unsaved changes is saved
+# to the recovery directory in the configuration directory, so that the changes can be recovered
+# using the Recovery command if Anvil stops unexpectedly. Set it to 0 to disable autosave.
+# The default is 30
+#autosave-interval=30
+
+# guess-indentation makes Anvil look at the leading whitespace of a file when it is loaded
+# into a window to guess whether the file is indented with tabs or spaces and by how many
+# columns. The string inserted when Tab is pressed and the tab width of the window are set to
+# match, unless they were already set using the Tab or Tabwidth commands. Use Tabwidth ? to
+# see what was guessed.
+# The default is true
+#guess-indentation=true
+
+# scroll-pips draws small ticks in the scrollbar of a window where the matches of the last search,
+# the marks set with Mark and the text coloured with Tint are in the file. Their colours are set
+# in the ScrollPips section of the style.
+# The default is true
+#scroll-pips=true
+
+# tidy-timeout is how many seconds a formatter run by Put or Tidy may run before it is killed.
+# When it is killed by Put the file is saved without being formatted.
+# The default is 10
+#tidy-timeout=10
+
+# closed-files-max is how many of the files most recently closed are remembered, so that Reopen
+# can open them again, even after Anvil is restarted. 0 stops closed files being remembered.
+# The default is 50
+#closed-files-max=50
+
+# error-patterns are regular expressions used to find diagnostics, like the lines compilers write
+# in the form path:line:col: message, in +Errors windows. Nexterr and Preverr move between them.
+# Each pattern is matched against a line of the window and must have the named groups file and
+# line, and may have the groups col and severity. Lines without a severity are errors. The first
+# pattern that matches a line is used. When this is empty the default patterns below, which match
+# the usual compiler form and the lines of Python tracebacks, are used.
+#error-patterns=[
+# '^\s*(?P<file>[^\s:]+):(?P<line>\d+)(?::(?P<col>\d+))?(?::|$)(?:\s*(?P<severity>error|warning|note|info)\b)?',
+# '^\s*File "(?P<file>[^"]+)", line (?P<line>\d+)'
+#]
+
+# command-footer-after is how many seconds a command must run for before a line like
+# [make: completed with exit 0 after 12.3s] is written to the +Errors window after its output
+# when it succeeds. A command that fails always gets this footer. To also flash the tag of the
+# +Errors window, enable the job-failed cue. 0 means only commands that fail get a footer.
+# The default is 10
+#command-footer-after=10
+
+# command-footer-always writes the footer after every command, even ones that succeed quickly.
+# The default is false
+#command-footer-always=false
+
 [layout]
 # The default part of the editor tag that does not include running commands
 #editor-tag="Newcol Kill Putall Dump Load Exit Help ◊ "
@@ -224,6 +623,182 @@ func GenerateSampleSettings() string {
 # The default part of the window tag that the user can edit
 #window-tag-user-area=" Do Look "
 
+# When a window that is too small receives output (like the +Errors window) it is grown.
+# By default this is delayed while the user is typing or clicking in the same column, and
+# the space is taken from the largest window. Set this to true to grow the window as soon
+# as the output arrives.
+#grow-output-windows-immediately=false
+
+# A marker shown in the tag of a window, just after the path, when the window has changes that
+# haven't been saved. By default there is no marker and only the layout box changes color.
+#dirty-marker="*"
+
+# When the path in a window tag is longer than tag-path-max-length characters it can be drawn
+# shortened so that the commands after it stay in view. Only the drawing changes: the tag
+# still holds the full path, which Put, the API and Dump use. The basename is never shortened.
+# The Path command shows the full path in a window's tag, or shortens it again.
+#
+#   none     the path is not shortened
+#   middle   directories in the middle are replaced with …, like host:/very/…/deep/file.go
+#   home     the home directory is replaced with ~, then directories as for middle
+#   project  the directories above the project root (the one with .git) are replaced with …,
+#            then directories as for middle
+#
+# The default is none
+#tag-path-abbreviation="middle"
+
+# The default is 60
+#tag-path-max-length=60
+
+# The column new windows are placed in, unless a command places them itself:
+#
+#   most-free-space  the column with the fewest windows
+#   active-column    the column of the focused window, or else the one last typed or clicked in
+#   right-most       the right-most column
+#
+# The default is most-free-space
+#window-placement="active-column"
+
+[notify]
+# When an event listed below occurs while the Anvil window is not focused, Anvil asks for
+# attention: the urgency hint is set for the window on X11 when Anvil is built with
+# -tags xlib, and the taskbar button flashes on Windows. The request is cleared when the
+# window is focused. The command Notify can be used at the end of a chain of commands to
+# ask for attention explicitly.
+
+# on-job-failure asks for attention when a command finishes with an error.
+# The default is true
+#on-job-failure=true
+
+# jobs is a list of regular expressions. Anvil asks for attention when a command whose
+# name matches one of them finishes.
+#jobs=["^make", "^go test"]
+
+# command is run when Anvil asks for attention, for example to show a desktop notification.
+# $1 is replaced with a quoted message describing the event.
+#command="notify-send Anvil $1"
+
+[cues]
+# Cues tell that something happened without asking for attention. An event can briefly flash
+# the tag of the window where it happened (or the editor tag), run a command, or both. The
+# events are job-succeeded and job-failed, for commands whose output goes to a window,
+# remote-put, when a file on a remote host is saved, and search-wrapped, when a search
+# continues from the other end of the window. Every event is disabled by default.
+
+# exclude-windows is a list of regular expressions. Events in windows whose file name matches
+# one of them have no cue.
+#exclude-windows=["\\+Live$"]
+
+# Cue commands are not run less than command-interval milliseconds apart, or while the last
+# one is still running, so that a burst of events doesn't start many processes.
+# The default is 1000
+#command-interval=1000
+
+# Each event is configured in its own table. $1 in the command is replaced with the name of
+# the event.
+#[cues.job-failed]
+#enabled=true
+#flash=true
+#command="paplay /usr/share/sounds/freedesktop/stereo/dialog-warning.oga"
+
+[mouse]
+# The mouse section binds the actions performed by clicking in text to mouse buttons. Each
+# setting is a list of bindings. A binding is a button, one of primary (or left), secondary (or
+# right) and tertiary (or middle), optionally preceded by modifiers joined with plus signs: ctrl,
+# shift, alt, cmd or super. For example "ctrl+primary". When more than one binding matches a
+# click the one with the most modifiers is used. The modifiers in a binding are not seen by the
+# action, so a click bound to acquire with alt does not load the file the way alt-acquire does.
+# On a trackpad without a middle button execute can be bound to a modified primary click.
+
+# select places the cursor and selects text.
+#select=["primary"]
+
+# execute executes the word or selection clicked, or the text swept while the button is held.
+#execute=["tertiary", "cmd+primary"]
+
+# acquire opens the file or searches for the text clicked.
+#acquire=["secondary"]
+
+# The remaining settings are chords: they are only used while another button is held.
+
+# execute-with-arg is pressed while the execute button is held to execute the text with the
+# last selection as the argument.
+#execute-with-arg=["primary"]
+
+# cut is clicked while the select button is held to cut the selection.
+#cut=["tertiary"]
+
+# paste is pressed while the select button is held to replace the selection with the clipboard.
+#paste=["secondary"]
+
+# scroll-lines is how many lines each turn of the scroll wheel scrolls.
+# The default is 3
+#scroll-lines=3
+
+# precise-scroll scrolls text by the distance a touchpad reports instead of by scroll-lines for each
+# scroll event, so that scrolling with a touchpad is smooth. It may make scroll wheels scroll slowly.
+# The default is false
+#precise-scroll=false
+
+# scroll-moves-cursor moves the cursor when the text is scrolled so that it stays on the screen, as
+# long as there is only one cursor and no selections. It is off by default because it would move
+# the cursor of a window that was scrolled to look at other text while working at the cursor.
+# The Scrollcursor command changes it for one window.
+# The default is false
+#scroll-moves-cursor=false
+
+# warp-to-new-windows moves the mouse pointer over the body of a window that is opened by New,
+# Acq, Zerox or by acquiring a file name, so that you can type or click in it straight away. On
+# platforms where the pointer can't be moved, such as Wayland or X11 when Anvil is built without
+# -tags xlib, the tag of the window is flashed instead.
+# The default is false
+#warp-to-new-windows=false
+
+[keys]
+# The keys section binds keys to actions that move the keyboard focus between windows. Each
+# setting is a list of bindings. A binding is a key optionally preceded by modifiers joined with
+# plus signs: ctrl, shift, alt, cmd or super. The key is a letter or other character, f1 to f12,
+# or one of up, down, left, right, enter, tab, space, escape, home, end, pageup and pagedown.
+# The modifiers held must be exactly those in the binding. A key that is bound here is not seen
+# by the window that has focus. Set a setting to [] to leave the action unbound. The Focusnext,
+# Focusprev, Focusnextcol, Focusprevcol and Focustag commands perform the same actions.
+
+# focus-next moves the focus to the next window down in the column, wrapping around to the top.
+#focus-next=["ctrl+alt+down"]
+
+# focus-prev moves the focus to the next window up in the column, wrapping around to the bottom.
+#focus-prev=["ctrl+alt+up"]
+
+# focus-next-col moves the focus to the window beside it in the column to the right.
+#focus-next-col=["ctrl+alt+right"]
+
+# focus-prev-col moves the focus to the window beside it in the column to the left.
+#focus-prev-col=["ctrl+alt+left"]
+
+# focus-tag moves the focus from the body of the window to its tag, or from the tag to the body.
+#focus-tag=["ctrl+alt+enter"]
+
+[completion]
+# Word completion (Ctrl-N and Ctrl-P) completes from the words in the open windows, and from the
+# sources below. Completions are listed in +Errors with the sources they came from.
+
+# dictionaries is a list of files holding one word per line. Each file is loaded in the
+# background the first time a word is completed, and again when it changes. Completing doesn't
+# wait for it: the completions listed are refreshed once it is loaded.
+#dictionaries=["/usr/share/dict/words"]
+
+# env-vars completes the names of environment variables, including those in the env table, when
+# the word being completed follows a $.
+# The default is true
+#env-vars=true
+
+# tags completes the names of the symbols in the tags files, like those written by ctags, found
+# in the directory of the window and the directories above it. Each file is loaded in the
+# background the first time a word is completed, and again when it changes. Tagsrc off turns
+# this off for one window.
+# The default is true
+#tags=true
+
 [typesetting]
 # When rendering text show carriage-returns as the "tofu" character (a box)
 # The default is false
@@ -252,15 +827,72 @@ func GenerateSampleSettings() string {
 # conntimeout is the TCP connection timeout for the SSH session in seconds
 #conn-timeout=5
 
+# keepalive-interval is how often, in seconds, cached ssh connections are probed. A connection
+# whose probe isn't answered within conn-timeout seconds, for example after the machine slept,
+# is closed and made again when next used. 0 disables the probes.
+#keepalive-interval=15
+
+# A [ssh.hosts."hostname"] section overrides the settings above for one host. shell replaces
+# the shell; shell-string replaces the whole command line used to run commands, in the same
+# format as the argument to Shstr; env holds environment variables set for commands run on
+# the host, after those in the [env] section; user and port are used when a path doesn't
+# include them. A shell string set using Shstr, and the user and port given to Hostpass,
+# take precedence over these.
+#[ssh.hosts."build.example.com"]
+#shell="bash -l"
+#shell-string="env -i HOME=/home/builder sh -c $'cd \"{Dir}\" && {Cmd} {Args}'"
+#env={GOFLAGS="-mod=vendor"}
+#user="builder"
+#port=2222
+
+# The tidy table maps file extensions to formatter commands. When a window holding a file with
+# one of the extensions is Put, its body is piped through the formatter, which is run in the
+# directory of the window, and is replaced by the output if the formatter succeeds. The
+# Tidy command runs the formatter without saving, and Tidy off disables it for a window.
+#[tidy]
+#".go"="gofmt"
+#".rs"="rustfmt --emit stdout"
+
 # The alias table lists command aliases. The key is the name of the alias and the
 # value are the commands to run separated by semicolon (;).
 [alias]
 `
 }
 
-// DoMatchConfigFileParser knows how to parse a file that has do and match lines, like
-// the plumbing file.
-func parseDoMatchConfigFile(f io.Reader, onMatch func(re *regexp.Regexp), onDo func(do string)) (err error) {
+func GenerateSamplePlumbing() string {
+	return `# Sample anvil plumbing file
+#
+# When text is acquired, the rules are tried in order before the text is opened as a file,
+# and the first rule that matches is used instead. Each rule is a match
+# line holding a regular expression and a do line holding the command to run when the text
+# matches. In the command $0 is the whole match, $1 the first group, $2 the second and so on.
+# The command is an Anvil command or a shell command, and runs in the context of the window the
+# text was acquired in. Use PlumbDry to see which rule matches some text and what it would run.
+# Lines starting with # are comments.
+
+# Open web links in the browser.
+#match ^https?://.*
+#do xdg-open $0
+
+# Show the documentation of Go packages, as in 'go:net/http'.
+#match ^go:(.+)$
+#do go doc $1
+
+# Open the commit with a hash like 'commit 1a2b3c4'.
+#match ^commit ([0-9a-f]{7,40})$
+#do git show $1
+
+# Show GitHub issues, as in 'github.com/foo/bar#123', using gh on another host.
+#match ^github\.com/([^/]+/[^#]+)#([0-9]+)$
+#do On buildhost:/tmp gh issue view -R $1 $2
+`
+}
+
+// parseDoMatchConfigFile parses a file that has do and match lines, like the plumbing file.
+// Parsing continues after an error so that all the errors are found, and they are returned as
+// configErrors giving their lines and the number of the rule, counting match lines from 1, that
+// they are in.
+func parseDoMatchConfigFile(f io.Reader, onMatch func(re *regexp.Regexp, line int), onDo func(do string)) (err error) {
 
 	s := bufio.NewScanner(f)
 
@@ -270,8 +902,22 @@ func parseDoMatchConfigFile(f io.Reader, onMatch func(re *regexp.Regexp), onDo f
 	)
 
 	state := stateExpectMatch
+	var errs configErrors
+	lineNo, matchLineNo := 0, 0
+	rule := 0
+	addRuleError := func(line int, msg string) {
+		if rule > 0 {
+			msg = fmt.Sprintf("rule %d: %s", rule, msg)
+		}
+		errs = append(errs, configError{Line: line, Msg: msg})
+	}
+	addError := func(format string, args ...interface{}) {
+		addRuleError(lineNo, fmt.Sprintf(format, args...))
+	}
+	missingDo := "Expected a line beginning with 'do' after the match"
 
 	for s.Scan() {
+		lineNo++
 		line := s.Text()
 		line = strings.TrimSpace(line)
 		if line == "" || line[0] == '#' {
@@ -280,31 +926,47 @@ func parseDoMatchConfigFile(f io.Reader, onMatch func(re *regexp.Regexp), onDo f
 
 		toks := strings.SplitN(line, " ", 2)
 		if len(toks) < 2 {
-			err = fmt.Errorf("Invalid line: expected a word, a space, then a string. Line is '%s'", line)
-			return
+			addError("Invalid line: expected a word, a space, then a string. Line is '%s'", line)
+			continue
+		}
+
+		if state == stateExpectDo && toks[0] == "match" {
+			addRuleError(matchLineNo, missingDo)
+			state = stateExpectMatch
+		}
+		if toks[0] == "match" {
+			rule++
 		}
 
 		switch state {
 		case stateExpectMatch:
 			if toks[0] != "match" {
-				err = fmt.Errorf("Expected line beginning with 'match' but got '%s'", line)
-				return
+				addError("Expected line beginning with 'match' but got '%s'", line)
+				continue
 			}
 			re, err2 := regexp.Compile(toks[1])
 			if err2 != nil {
-				err = fmt.Errorf("Parsing regexp for line '%s' failed: %v", line, err)
-				return
+				addError("Parsing regexp for line '%s' failed: %v", line, err2)
+				continue
 			}
-			onMatch(re)
+			onMatch(re, lineNo)
+			matchLineNo = lineNo
 			state = stateExpectDo
 		case stateExpectDo:
 			if toks[0] != "do" {
-				err = fmt.Errorf("Expected line beginning with 'do' but got '%s'", line)
-				return
+				addError("Expected line beginning with 'do' but got '%s'", line)
+				continue
 			}
 			onDo(toks[1])
 			state = stateExpectMatch
 		}
 	}
+
+	if state == stateExpectDo {
+		addRuleError(matchLineNo, missingDo)
+	}
+	if len(errs) > 0 {
+		err = errs
+	}
 	return
 }
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+// cueEvent is an event that can be given a cue in the settings. Cues are quieter than requests
+// for attention: they are given whether or not the Anvil window is focused, and only ever
+// flash a tag or run a command.
+type cueEvent string
+
+const (
+	cueJobSucceeded  cueEvent = "job-succeeded"
+	cueJobFailed     cueEvent = "job-failed"
+	cueRemotePut     cueEvent = "remote-put"
+	cueSearchWrapped cueEvent = "search-wrapped"
+)
+
+// cueFlashDuration is how long a tag is flashed for a cue.
+const cueFlashDuration = 200 * time.Millisecond
+
+func (ev cueEvent) settings() CueEventSettings {
+	switch ev {
+	case cueJobSucceeded:
+		return settings.Cues.JobSucceeded
+	case cueJobFailed:
+		return settings.Cues.JobFailed
+	case cueRemotePut:
+		return settings.Cues.RemotePut
+	case cueSearchWrapped:
+		return settings.Cues.SearchWrapped
+	}
+	return CueEventSettings{}
+}
+
+// cueCommandLimiter keeps cue commands from being started too often.
+type cueCommandLimiter struct {
+	last    time.Time
+	running atomic.Bool
+}
+
+// start returns true if a cue command may be started at now, and records that one was.
+func (l *cueCommandLimiter) start(now time.Time, interval time.Duration) bool {
+	if l.running.Load() || (!l.last.IsZero() && now.Sub(l.last) < interval) {
+		return false
+	}
+	l.last = now
+	l.running.Store(true)
+	return true
+}
+
+func (l *cueCommandLimiter) done() {
+	l.running.Store(false)
+}
+
+// Cue gives the cues from the settings for ev, which happened in win. win may be nil if the
+// event didn't happen in a window, in which case the editor tag is flashed.
+func (a *Application) Cue(ev cueEvent, win *Window) {
+	s := ev.settings()
+	if !s.Enabled || (win != nil && windowExcludedFromCues(win)) {
+		return
+	}
+
+	log(LogCatgApp, "Application: cue for %s\n", ev)
+	if s.Flash && editor != nil {
+		if win != nil {
+			win.Tag.flashBriefly(cueFlashDuration)
+		} else {
+			editor.Tag.flashBriefly(cueFlashDuration)
+		}
+	}
+
+	if s.Command != "" {
+		a.runCueCommand(ev, s.Command)
+	}
+}
+
+func (a *Application) runCueCommand(ev cueEvent, cmd string) {
+	interval := time.Duration(settings.Cues.CommandInterval) * time.Millisecond
+	if !a.cueCommands.start(time.Now(), interval) {
+		log(LogCatgApp, "Application: not running the cue command for %s since the last one was too recent\n", ev)
+		return
+	}
+
+	err := runCommandInBackground(substitute(cmd, []string{shellQuote(string(ev))}), a.cueCommands.done)
+	if err != nil {
+		a.cueCommands.done()
+		log(LogCatgApp, "Running cue command failed: %v\n", err)
+	}
+}
+
+func windowExcludedFromCues(win *Window) bool {
+	for _, p := range settings.Cues.ExcludeWindows {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log(LogCatgApp, "Invalid regular expression '%s' in cues exclude-windows setting: %v\n", p, err)
+			continue
+		}
+		if re.MatchString(win.file) {
+			return true
+		}
+	}
+	return false
+}
+
+// cueJobFinished gives the cue for a command that finished. Only commands whose output goes
+// to a window have cues, not jobs like loading files.
+func (a *Application) cueJobFinished(job Job) {
+	if j, ok := job.(*GtExecutorJob); ok {
+		job = j.winDataLoad
+	}
+
+	l, ok := job.(*WindowDataLoad)
+	if !ok || !l.ReportTermination || l.Killed() {
+		return
+	}
+
+	ev := cueJobSucceeded
+	if l.Failed() {
+		ev = cueJobFailed
+	}
+	// Don't create the window if the command never wrote to it.
+	a.Cue(ev, l.Win.win)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func setCueSettingsForTest(t *testing.T, s CueSettings) {
+	saved := settings.Cues
+	settings.Cues = s
+	t.Cleanup(func() { settings.Cues = saved })
+}
+
+func TestCueCommandLimiter(t *testing.T) {
+	var l cueCommandLimiter
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	if !l.start(start, time.Second) {
+		t.Fatalf("expected the first command to start")
+	}
+	if l.start(start.Add(2*time.Second), time.Second) {
+		t.Fatalf("expected no command to start while the last one is running")
+	}
+	l.done()
+	if l.start(start.Add(500*time.Millisecond), time.Second) {
+		t.Fatalf("expected no command to start within the interval")
+	}
+	if !l.start(start.Add(time.Second), time.Second) {
+		t.Fatalf("expected a command to start after the interval")
+	}
+}
+
+func TestCueFlashDoesNotDisturbErrorsFlash(t *testing.T) {
+	startHeadlessEditor(t)
+	setCueSettingsForTest(t, CueSettings{
+		SearchWrapped:  CueEventSettings{Enabled: true, Flash: true},
+		ExcludeWindows: []string{`\+Live$`},
+	})
+
+	var win *Window
+	onMainGoroutine(func() {
+		editor.AppendError("", "an error")
+		win, _ = editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		editor.SetOnlyFlashedWindow(win)
+		application.Cue(cueSearchWrapped, win)
+
+		flashBg := win.Tag.blockEditable.style.ErrorFlashBgColor
+		if !win.Tag.flashed || win.Tag.drawnBgColor() != color.NRGBA(win.Tag.editable.style.FgColor) || win.Tag.fgColor() != Color(flashBg) {
+			t.Fatalf("expected the tag colors to be swapped")
+		}
+	})
+
+	var flashed bool
+	for i := 0; i < 50; i++ {
+		time.Sleep(20 * time.Millisecond)
+		onMainGoroutine(func() { flashed = win.Tag.flashed })
+		if !flashed {
+			break
+		}
+	}
+	if flashed {
+		t.Fatalf("expected the tag colors to be restored")
+	}
+
+	onMainGoroutine(func() {
+		if win.Tag.bgcolor != win.Tag.blockEditable.style.ErrorFlashBgColor || win.Tag.fgColor() != win.Tag.editable.style.FgColor {
+			t.Fatalf("expected the errors window to still be flashed")
+		}
+
+		live := editor.FindOrCreateWindow("+Live")
+		application.Cue(cueSearchWrapped, live)
+		if live.Tag.flashed {
+			t.Fatalf("expected no cue for an excluded window")
+		}
+	})
+}
+
+func TestCueCommandRunsWhenJobFails(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	setCueSettingsForTest(t, CueSettings{
+		JobSucceeded:    CueEventSettings{Enabled: false, Command: "touch " + filepath.Join(dir, "cue-$1")},
+		JobFailed:       CueEventSettings{Enabled: true, Command: "touch " + filepath.Join(dir, "cue-$1")},
+		CommandInterval: 1000,
+	})
+
+	runCommandForTermination(t, dir, "exit 1", nil)
+
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(filepath.Join(dir, "cue-job-failed")); err == nil {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cue-job-failed")); err != nil {
+		t.Fatalf("expected the cue command to run: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cue-job-succeeded")); err == nil {
+		t.Fatalf("expected no cue for a disabled event")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/jeffwilliams/anvil/internal/linediff"
+	"github.com/jeffwilliams/anvil/internal/textenc"
+)
+
+const (
+	// maxDiffSize is the largest file or body, in bytes, that Diff compares.
+	maxDiffSize = 8 * 1024 * 1024
+	// maxDiffChanges is the most lines inserted or deleted that Diff looks for. If more lines
+	// changed, all the lines from the first change to the last are shown as changed.
+	maxDiffChanges = 20000
+	// diffContextLines is the number of unchanged lines shown around each change.
+	diffContextLines = 3
+)
+
+// diffWindowNameOf returns the name of the window that holds the output of Diff for the directory dir.
+func diffWindowNameOf(dir string) string {
+	return fmt.Sprintf("%s+Diff", dir)
+}
+
+func (c CommandExecutor) CmdDiff(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Diff: must be executed in a window")
+		return
+	}
+
+	if w.fileType != typeFile || w.file == "" || IsOutputWindow(w.file) || w.IsLiveWindow() || w.IsFindWindow() || w.IsDiffWindow() {
+		editor.AppendError("", "Diff: the window must hold a file")
+		return
+	}
+
+	if w.Body.Len() > maxDiffSize {
+		editor.AppendError("", fmt.Sprintf("Diff: the body of %s is too large to compare (%s; the limit is %s)", w.file, humanizeSize(int64(w.Body.Len())), humanizeSize(maxDiffSize)))
+		return
+	}
+
+	dir, err := NewFileFinder(w).WindowDir()
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Diff: %v", err))
+		return
+	}
+
+	file, enc := w.file, w.encoding
+	body := w.Body.Bytes()
+	job := NewNamedJob("Diff " + filepath.Base(file))
+	editor.AddJob(job)
+
+	go func() {
+		done := &diffDoneWork{job: job, dir: dir, file: file}
+		defer func() { editor.WorkChan() <- done }()
+
+		disk, truncated, err := readFileUpTo(file, maxDiffSize)
+		if err != nil {
+			done.err = fmt.Errorf("Diff: %s: %v", file, err)
+			return
+		}
+		if truncated {
+			done.err = fmt.Errorf("Diff: %s is too large to compare (the limit is %s)", file, humanizeSize(maxDiffSize))
+			return
+		}
+
+		// The body is kept as UTF-8 with newline line endings, so the file is converted to match.
+		d := textenc.NewDecoder(enc)
+		disk = append(d.Decode(disk), d.Flush()...)
+
+		done.text = unifiedDiff(file, disk, body)
+	}()
+}
+
+// unifiedDiff returns the changes that turn disk, the contents of file, into body as a unified
+// diff. The header of each hunk ends with the address in file of its first line in body so that
+// acquiring it goes to that line. If there are no changes it says so.
+func unifiedDiff(file string, disk, body []byte) string {
+	hunks := linediff.Hunks(disk, linediff.Diff(disk, body, maxDiffChanges), diffContextLines)
+	if len(hunks) == 0 {
+		return fmt.Sprintf("%s: no changes\n", file)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "--- %s (on disk)\n", file)
+	fmt.Fprintf(&buf, "+++ %s (window)\n", file)
+	for _, h := range hunks {
+		fmt.Fprintf(&buf, "@@ -%d,%d +%d,%d @@ %s:%d\n", h.OldStart, h.OldLines, h.NewStart, h.NewLines, file, max(h.NewStart, 1))
+		for _, l := range h.Lines {
+			buf.WriteString(l)
+		}
+	}
+	return buf.String()
+}
+
+// diffDoneWork shows the output of Diff in the +Diff window of the directory, replacing what it
+// showed before.
+type diffDoneWork struct {
+	job  Job
+	dir  string
+	file string
+	text string
+	err  error
+}
+
+func (w *diffDoneWork) Service() (done bool) {
+	if w.err != nil {
+		editor.AppendError("", w.err.Error())
+		return true
+	}
+
+	win := editor.FindOrCreateWindow(diffWindowNameOf(w.dir))
+	win.Body.SetTextStringNoUndo(w.text)
+	win.Body.SetSyntaxLanguage("diff")
+	win.Body.HighlightSyntax()
+	return true
+}
+
+func (w *diffDoneWork) Job() Job {
+	return w.job
+}
+
+func (w *Window) IsDiffWindow() bool {
+	return IsDiffWindow(w.file)
+}
+
+func IsDiffWindow(windowFilename string) bool {
+	return strings.HasSuffix(windowFilename, "+Diff")
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffShowsChangesSinceSave(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "diff.txt")
+	if err := os.WriteFile(path, []byte("1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.LoadFile(path)
+	})
+	waitForBody(t, win, "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n")
+
+	waitForDiff := func(expected string) {
+		t.Helper()
+		var text string
+		for i := 0; i < 250 && !strings.Contains(text, expected); i++ {
+			time.Sleep(20 * time.Millisecond)
+			onMainGoroutine(func() {
+				if w, _ := editor.FindWindowForFile(diffWindowNameOf(dir + "/")); w != nil {
+					text = w.Body.String()
+				} else if w, _ := editor.FindWindowForFile(diffWindowNameOf(dir)); w != nil {
+					text = w.Body.String()
+				}
+			})
+		}
+		if !strings.Contains(text, expected) {
+			t.Fatalf("expected the +Diff window to contain %q but it holds %q", expected, text)
+		}
+	}
+
+	onMainGoroutine(func() {
+		NewCommandExecutor(win).Do("Diff", &CmdContext{})
+	})
+	waitForDiff(path + ": no changes")
+
+	onMainGoroutine(func() {
+		win.Body.insertToPieceTable(win.Body.Len(), "11\n")
+		NewCommandExecutor(win).Do("Diff", &CmdContext{})
+	})
+	waitForDiff("@@ -8,3 +8,4 @@ " + path + ":8\n 8\n 9\n 10\n+11\n")
+}
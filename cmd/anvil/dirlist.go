@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DirEntry is an entry in a directory listed in a window. The name of a subdirectory ends in a
+// path separator. Size and ModTime are zero if they couldn't be found.
+type DirEntry struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+const (
+	dirSortByName  = "name"
+	dirSortByMtime = "mtime"
+	dirSortBySize  = "size"
+)
+
+func isValidDirSort(s string) bool {
+	return s == dirSortByName || s == dirSortByMtime || s == dirSortBySize
+}
+
+// dirListing holds the entries of the directory shown in a window, so that they can be sorted
+// and filtered again without reading the directory.
+type dirListing struct {
+	entries []DirEntry
+	// sort is the order set using the Sort command. If empty the dir-sort setting is used.
+	sort string
+	// dots is whether entries starting with a dot are listed, if dotsSet is true. Otherwise
+	// the dir-dots setting is used.
+	dots    bool
+	dotsSet bool
+	// highlight is the entry that is highlighted briefly after a file operation, and highlighted
+	// is its highlight in the body.
+	highlight   string
+	highlighted *SyntaxInterval
+	// pendingRm are the arguments of an Rm that must be executed again to remove the files.
+	pendingRm string
+}
+
+func (d *dirListing) sortOrder() string {
+	if d.sort != "" {
+		return d.sort
+	}
+	if isValidDirSort(settings.General.DirSort) {
+		return settings.General.DirSort
+	}
+	return dirSortByName
+}
+
+func (d *dirListing) showDots() bool {
+	if d.dotsSet {
+		return d.dots
+	}
+	return settings.General.DirDots
+}
+
+// names returns the names of the entries to list, in order.
+func (d *dirListing) names() []string {
+	entries := make([]DirEntry, 0, len(d.entries))
+	for _, e := range d.entries {
+		if !d.showDots() && strings.HasPrefix(e.Name, ".") {
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	sortDirEntries(entries, d.sortOrder())
+
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	return names
+}
+
+// sortDirEntries sorts entries by name, or with the most recently modified or largest first.
+// Entries that are equal in modification time or size are sorted by name.
+func sortDirEntries(entries []DirEntry, order string) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := &entries[i], &entries[j]
+		switch order {
+		case dirSortByMtime:
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.After(b.ModTime)
+			}
+		case dirSortBySize:
+			if a.Size != b.Size {
+				return a.Size > b.Size
+			}
+		}
+		return a.Name < b.Name
+	})
+}
+
+// parseRemoteDirEntries parses the listing of a remote directory. Each line contains the type
+// of the entry as printed by find -printf %y, its size, its modification time in seconds since
+// the epoch, and its name. Lines produced by the ls fallback have the type ? and the name of
+// directories already ends in a slash.
+func parseRemoteDirEntries(b []byte) (entries []DirEntry) {
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		f := strings.SplitN(string(line), " ", 4)
+		if len(f) < 4 || f[3] == "" {
+			continue
+		}
+
+		e := DirEntry{Name: f[3]}
+		if f[0] == "d" {
+			e.Name += "/"
+		}
+		e.Size, _ = strconv.ParseInt(f[1], 10, 64)
+		if secs, err := strconv.ParseFloat(f[2], 64); err == nil && secs > 0 {
+			e.ModTime = time.Unix(0, int64(secs*float64(time.Second)))
+		}
+		entries = append(entries, e)
+	}
+	return
+}
+
+// appendDirEntries adds entries read from the directory shown in the window to the listing.
+func (w *Window) appendDirEntries(entries []DirEntry) {
+	w.dir.entries = append(w.dir.entries, entries...)
+	w.relistDir()
+}
+
+// relistDir lists the entries of the directory in the body again using the current sort
+// order and dotfile policy.
+func (w *Window) relistDir() {
+	if w.filler == nil {
+		return
+	}
+	w.filler.SetItems(w.dir.names())
+	editor.SignalRedrawRequired()
+}
+
+func (w *Window) SetDirSort(order string) error {
+	if w.fileType != typeDir {
+		return fmt.Errorf("the window is not showing a directory")
+	}
+	if !isValidDirSort(order) {
+		return fmt.Errorf("the order must be one of %s, %s or %s", dirSortByName, dirSortByMtime, dirSortBySize)
+	}
+	w.dir.sort = order
+	w.relistDir()
+	return nil
+}
+
+func (w *Window) SetDirDots(b bool) error {
+	if w.fileType != typeDir {
+		return fmt.Errorf("the window is not showing a directory")
+	}
+	w.dir.dots = b
+	w.dir.dotsSet = true
+	w.relistDir()
+	return nil
+}
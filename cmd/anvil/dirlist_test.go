@@ -0,0 +1,85 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDirListingNames(t *testing.T) {
+	now := time.Now()
+	d := dirListing{
+		entries: []DirEntry{
+			{Name: "b.go", Size: 10, ModTime: now.Add(-time.Hour)},
+			{Name: ".git/", Size: 4096, ModTime: now},
+			{Name: "a.go", Size: 300, ModTime: now.Add(-2 * time.Hour)},
+			{Name: "c.go", Size: 300, ModTime: now.Add(-time.Minute)},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		sort     string
+		dots     bool
+		expected []string
+	}{
+		{"name", dirSortByName, true, []string{".git/", "a.go", "b.go", "c.go"}},
+		{"name without dots", dirSortByName, false, []string{"a.go", "b.go", "c.go"}},
+		{"mtime", dirSortByMtime, true, []string{".git/", "c.go", "b.go", "a.go"}},
+		{"size without dots", dirSortBySize, false, []string{"a.go", "c.go", "b.go"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d.sort = tc.sort
+			d.dots = tc.dots
+			d.dotsSet = true
+			names := d.names()
+			if !reflect.DeepEqual(names, tc.expected) {
+				t.Fatalf("expected %v but got %v", tc.expected, names)
+			}
+		})
+	}
+}
+
+func TestParseRemoteDirEntries(t *testing.T) {
+	b := []byte("d 4096 1700000000.5000000000 src\nf 12 1600000000.0000000000 a file\n? 0 0 old/\n")
+	entries := parseRemoteDirEntries(b)
+
+	expected := []DirEntry{
+		{Name: "src/", Size: 4096, ModTime: time.Unix(1700000000, 500000000)},
+		{Name: "a file", Size: 12, ModTime: time.Unix(1600000000, 0)},
+		{Name: "old/"},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("expected %d entries but got %v", len(expected), entries)
+	}
+	for i, e := range expected {
+		if entries[i].Name != e.Name || entries[i].Size != e.Size || !entries[i].ModTime.Equal(e.ModTime) {
+			t.Fatalf("entry %d: expected %v but got %v", i, e, entries[i])
+		}
+	}
+}
+
+func TestEntriesInDir(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "file"), []byte("12345"), 0644)
+	if err != nil {
+		t.Fatalf("writing file failed: %v", err)
+	}
+	err = os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	if err != nil {
+		t.Fatalf("making directory failed: %v", err)
+	}
+
+	entries, err := entriesInDir(dir)
+	if err != nil {
+		t.Fatalf("entriesInDir failed: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "file" || entries[0].Size != 5 || entries[0].ModTime.IsZero() ||
+		entries[1].Name != "sub"+string(filepath.Separator) {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"github.com/jeffwilliams/anvil/internal/intvl"
+)
+
+// displaySubstitution replaces a range of the text of an editable with other text when it is
+// drawn. The text itself is unchanged, so commands, the API and Dump still see the real text;
+// only the layout, and the mapping between pixel positions and rune indexes, use the
+// substitution.
+//
+// The runes at the start and end of the replacement that are the same as the replaced range
+// map one to one to the runes of the range. The rest of the replacement stands for the rest of
+// the range: a position within it maps to the start of the hidden runes.
+type displaySubstitution struct {
+	// start and end are the rune indexes of the replaced range in the text.
+	start, end int
+	text       string
+	textLen    int
+	// prefix and suffix are the number of runes at the start and end of the replacement that
+	// are the same as the replaced range.
+	prefix, suffix int
+}
+
+// newDisplaySubstitution returns a substitution that draws text in place of replaced, which
+// starts at rune index start.
+func newDisplaySubstitution(start int, replaced, text string) *displaySubstitution {
+	r := []rune(replaced)
+	t := []rune(text)
+
+	s := &displaySubstitution{
+		start:   start,
+		end:     start + len(r),
+		text:    text,
+		textLen: len(t),
+	}
+
+	for s.prefix < len(r) && s.prefix < len(t) && r[s.prefix] == t[s.prefix] {
+		s.prefix++
+	}
+	for s.prefix+s.suffix < len(r) && s.prefix+s.suffix < len(t) && r[len(r)-1-s.suffix] == t[len(t)-1-s.suffix] {
+		s.suffix++
+	}
+	return s
+}
+
+// toDisplay maps the rune index i in the text to the index it is drawn at.
+func (s *displaySubstitution) toDisplay(i int) int {
+	switch {
+	case i < s.start:
+		return i
+	case i >= s.end:
+		return i - (s.end - s.start) + s.textLen
+	case i < s.start+s.prefix:
+		return i
+	case i >= s.end-s.suffix:
+		return s.start + s.textLen - (s.end - i)
+	}
+	return s.start + s.prefix
+}
+
+// toText maps the rune index i in the drawn text to the index in the text.
+func (s *displaySubstitution) toText(i int) int {
+	dispEnd := s.start + s.textLen
+	switch {
+	case i < s.start:
+		return i
+	case i >= dispEnd:
+		return i - s.textLen + (s.end - s.start)
+	case i < s.start+s.prefix:
+		return i
+	case i >= dispEnd-s.suffix:
+		return s.end - (dispEnd - i)
+	}
+	return s.start + s.prefix
+}
+
+// apply returns doc, the whole text of the editable, with the substitution made.
+func (s *displaySubstitution) apply(doc []byte) []byte {
+	before, rest, n := firstNRunes(doc, s.start)
+	if n < s.start {
+		return doc
+	}
+	_, after, n := firstNRunes(rest, s.end-s.start)
+	if n < s.end-s.start {
+		return doc
+	}
+
+	b := make([]byte, 0, len(before)+len(s.text)+len(after))
+	b = append(b, before...)
+	b = append(b, s.text...)
+	return append(b, after...)
+}
+
+// displayedInterval is an interval of the text moved to where it is drawn. The styles are still
+// decided by the original interval.
+type displayedInterval struct {
+	intvl.Interval
+	start, end int
+}
+
+func (d displayedInterval) Start() int {
+	return d.start
+}
+
+func (d displayedInterval) End() int {
+	return d.end
+}
+
+// SetDisplaySubstitution sets the substitution made when the text is drawn, or removes it if s
+// is nil.
+func (e *editable) SetDisplaySubstitution(s *displaySubstitution) {
+	e.displaySubst = s
+	e.invalidateLayedoutText()
+}
+
+// displayIndex maps the rune index i in the text to the index it is drawn at.
+func (e *editable) displayIndex(i int) int {
+	if e.displaySubst == nil {
+		return i
+	}
+	return e.displaySubst.toDisplay(i)
+}
+
+// textIndex maps the rune index i in the drawn text to the index in the text.
+func (e *editable) textIndex(i int) int {
+	if e.displaySubst == nil {
+		return i
+	}
+	return e.displaySubst.toText(i)
+}
+
+// addStyleChange adds the interval i of the text to the style changes, moved to where the text
+// is drawn.
+func (e *editable) addStyleChange(i intvl.Interval) {
+	if e.displaySubst != nil {
+		i = displayedInterval{i, e.displayIndex(i.Start()), e.displayIndex(i.End())}
+	}
+	e.styleSeq.AddWithoutSort(i)
+}
+
+// originalIntervals returns the intervals that the displayedIntervals in c were made from.
+func originalIntervals(c []intvl.Interval) []intvl.Interval {
+	var orig []intvl.Interval
+	for i, v := range c {
+		d, ok := v.(displayedInterval)
+		if !ok {
+			continue
+		}
+		if orig == nil {
+			orig = make([]intvl.Interval, len(c))
+			copy(orig, c)
+		}
+		orig[i] = d.Interval
+	}
+	if orig == nil {
+		return c
+	}
+	return orig
+}
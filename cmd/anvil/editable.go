@@ -68,6 +68,38 @@ type editable struct {
 	syntaxMaxDocSize      int
 	Scheduler             *Scheduler
 	maxSizeLastLayout     image.Point
+	// generation is incremented each time the text changes. API clients use it to detect
+	// that the text changed since they read it.
+	generation int
+	// noWrap is true if long lines are not wrapped. Instead the text can be scrolled horizontally,
+	// and LeftOffset is how many pixels it is scrolled to the left.
+	noWrap     bool
+	LeftOffset int
+	// tabWidth is the number of columns between tab stops, where a column is the width of a
+	// space. If it is 0 the tab stop interval from the style is used.
+	tabWidth int
+	// scrollMovesCursor is whether scrolling moves a lone cursor so that it stays visible. It is
+	// only used if scrollMovesCursorSet is true; otherwise the scroll-moves-cursor setting is.
+	scrollMovesCursor    bool
+	scrollMovesCursorSet bool
+	// scrollRemainder is the distance in pixels scrolled with precise-scroll that didn't add up
+	// to a whole line yet.
+	scrollRemainder float32
+	// wsHints are the whitespace hints drawn in the text, or nil if they are not shown.
+	wsHints *whitespaceHints
+	// redacted is true if the text is drawn as blocks so that it can't be read.
+	redacted bool
+	// displaySubst, if set, replaces part of the text when it is drawn.
+	displaySubst *displaySubstitution
+	// dragAutoScroll is set while the text is scrolled automatically because a selection is
+	// being dragged near the top or bottom edge.
+	dragAutoScroll *dragAutoScroll
+	// flashed is true while a blockEditable is flashed by flashBriefly, and the text is drawn
+	// in flashFgColor rather than the foreground color from the style.
+	flashed      bool
+	flashFgColor Color
+	// peek is the excerpt shown over the text by Peek, or nil if none is shown.
+	peek *peekOverlay
 	// label is a name for this editable used for debugging
 	label                  string
 	completionSource       string
@@ -77,6 +109,20 @@ type editable struct {
 	adapter                adapter
 	syntaxHighlightDelay   time.Duration
 	draggingTertiaryButton bool
+	// boxSelectAnchor is the rune index where the primary button was pressed with Ctrl held to
+	// start a box selection. It is -1 when no box selection is being dragged.
+	boxSelectAnchor int
+	// syntaxChanged is the region of the text changed since the syntax was last highlighted.
+	syntaxChanged syntaxChangedRegion
+	// syntaxTokensCurrent is true if the syntax tokens are those for the whole text as it was
+	// before the changes in syntaxChanged.
+	syntaxTokensCurrent bool
+	// wsDisplay is how whitespace is shown in the text.
+	wsDisplay whitespaceDisplay
+	// guideColumn is the column that the line-length guide is drawn at, or 0 if it isn't drawn.
+	guideColumn int
+	// elasticTabs is true if the tab stops are placed to line up the columns of adjacent lines.
+	elasticTabs bool
 }
 
 type editableStyle struct {
@@ -91,6 +137,12 @@ type editableStyle struct {
 
 	TabStopInterval unit.Dp
 	TextLeftPadding unit.Dp
+
+	// TrailingWhitespaceBgColor and TabGuideColor are used to show whitespace when enabled by Ws.
+	TrailingWhitespaceBgColor Color
+	TabGuideColor             Color
+	// GuideColor is the color of the line-length guide drawn by Guide.
+	GuideColor Color
 }
 
 type deferredPointerEvent struct {
@@ -114,6 +166,7 @@ func (e *editable) Init(style editableStyle) {
 	e.wordCompletion = NewCompletion(e)
 	e.fileCompletion = NewCompletion(e)
 	e.recentlyTypedText.start = -1
+	e.boxSelectAnchor = -1
 }
 
 func (e *editable) SetAdapter(a adapter) {
@@ -130,6 +183,10 @@ func (e *editable) InitPointerEventHandlers() {
 		return
 	}
 
+	// The handlers are registered for the buttons that perform each action by default. The mouse
+	// bindings map the buttons and modifiers the user pressed to these.
+	e.pointerState.SetBindings(currentMouseBindings())
+
 	// Clicks
 	e.pointerState.Handler(PointerEventMatch{pointer.Press, pointer.ButtonPrimary}, e.onPointerPrimaryButtonPress)
 	e.pointerState.Handler(PointerEventMatch{pointer.Press, pointer.ButtonTertiary}, e.onPointerTertiaryButtonPress)
@@ -142,6 +199,11 @@ func (e *editable) InitPointerEventHandlers() {
 	e.pointerState.Handler(PointerEventMatch{pointer.Release, pointer.ButtonPrimary}, e.onPointerRelease)
 	//e.pointerState.Handler(PointerEventMatch{pointer.Release, pointer.ButtonTertiary}, e.onPointerRelease)
 	e.pointerState.Handler(PointerEventMatch{pointer.Release, pointer.ButtonSecondary}, e.onPointerRelease)
+
+	// Chords
+	e.pointerState.ChordHandler(pointer.ButtonTertiary, PointerEventMatch{pointer.Press, pointer.ButtonPrimary}, e.onPointerExecuteWithArgChord)
+	e.pointerState.ChordHandler(pointer.ButtonPrimary, PointerEventMatch{pointer.Release, pointer.ButtonTertiary}, e.onPointerCutChord)
+	e.pointerState.ChordHandler(pointer.ButtonPrimary, PointerEventMatch{pointer.Press, pointer.ButtonSecondary}, e.onPointerPasteChord)
 }
 
 func (e *editable) SetTextString(s string) {
@@ -161,6 +223,17 @@ func (e *editable) SetText(b []byte) {
 	e.textChanged(fireListeners, TextChange{})
 }
 
+// replaceText replaces all of the text with b as one change for Undo, like SetText, unless the
+// modificationGuard refuses the change. It is used for changes asked for by the user, whereas
+// SetText is also used to load the text. It returns false if the change was refused.
+func (e *editable) replaceText(b []byte) bool {
+	if !e.modificationAllowed() {
+		return false
+	}
+	e.SetText(b)
+	return true
+}
+
 func (e *editable) SetTextStringNoReset(s string) {
 	e.editableModel.SetTextStringNoReset(s)
 	e.invalidateLayedoutText()
@@ -181,6 +254,14 @@ func (e *editable) Key(gtx layout.Context, ev *key.Event) {
 		return
 	}
 
+	if e.dismissPeek() && ev.Name == key.NameEscape {
+		return
+	}
+
+	if e.adapter.focusKey(ev) {
+		return
+	}
+
 	e.KeyPress(gtx, ev)
 }
 
@@ -198,6 +279,10 @@ func (e *editable) KeyPress(gtx layout.Context, ev *key.Event) {
 	switch ev.Name {
 	case "⏎", "⌤":
 		// Enter, Numpad Enter
+		if IsErrorsWindow(e.adapter.file()) && len(e.CursorIndices) == 1 && e.firstCursorIndex() == e.text.Len() {
+			e.adapter.resumeTailing()
+		}
+
 		if ev.Modifiers.Contain(key.ModCtrl) {
 
 			w := runes.NewWalker(e.Bytes())
@@ -232,14 +317,14 @@ func (e *editable) KeyPress(gtx layout.Context, ev *key.Event) {
 		// Backspace
 		if e.SelectionsPresent() {
 			e.SetSaveDeletes(false)
-			e.text.StartTransaction()
-			for _, sel := range e.selections {
-				if sel.Len() > 0 {
-					e.deleteFromPieceTableUndoIndex(sel.end-1, 1, e.firstCursorIndex())
+			e.text.RunInTransaction(txOwnerMultiEdit, func() {
+				for _, sel := range e.selections {
+					if sel.Len() > 0 {
+						e.deleteFromPieceTableUndoIndex(sel.end-1, 1, e.firstCursorIndex())
+					}
 				}
-			}
+			})
 			e.SetSaveDeletes(true)
-			e.text.EndTransaction()
 			e.typingInSelectedTextAction = appendTextToSelections
 			break
 		}
@@ -247,15 +332,15 @@ func (e *editable) KeyPress(gtx layout.Context, ev *key.Event) {
 		if len(e.CursorIndices) > 1 {
 			e.SetSaveDeletes(false)
 		}
-		e.text.StartTransaction()
-		for i, ndx := range e.CursorIndices {
-			if ndx > 0 {
-				e.CursorIndices[i]--
-				e.deleteFromPieceTable(e.CursorIndices[i], 1)
-				log(LogCatgEd, "Delete at %d of length %d\n", e.CursorIndices[i], 1)
+		e.text.RunInTransaction(txOwnerMultiEdit, func() {
+			for i, ndx := range e.CursorIndices {
+				if ndx > 0 {
+					e.CursorIndices[i]--
+					e.deleteFromPieceTable(e.CursorIndices[i], 1)
+					log(LogCatgEd, "Delete at %d of length %d\n", e.CursorIndices[i], 1)
+				}
 			}
-		}
-		e.text.EndTransaction()
+		})
 		e.SetSaveDeletes(true)
 	case "⌦":
 		// Delete
@@ -540,7 +625,13 @@ func (e *editable) KeyPress(gtx layout.Context, ev *key.Event) {
 			clearRecentlyTypedText = true
 		}
 	case "V":
-		if ev.Modifiers.Contain(key.ModCtrl) || ev.Modifiers.Contain(key.ModCommand) {
+		if ev.Modifiers.Contain(key.ModCtrl) && ev.Modifiers.Contain(key.ModShift) {
+			e.pasteSlotsFromKey(gtx, pasteSlotwise)
+			clearRecentlyTypedText = true
+		} else if ev.Modifiers.Contain(key.ModCtrl) && ev.Modifiers.Contain(key.ModAlt) {
+			e.pasteSlotsFromKey(gtx, pasteSlotsRotated)
+			clearRecentlyTypedText = true
+		} else if ev.Modifiers.Contain(key.ModCtrl) || ev.Modifiers.Contain(key.ModCommand) {
 			e.adapter.pasteToFocusedEditable(gtx)
 			clearRecentlyTypedText = true
 		}
@@ -830,7 +921,17 @@ func (e *editable) autoIndent() {
 }
 
 func (e *editable) undoOrRedo(gtx layout.Context, undoOrRedo func() []interface{}, shiftDirection int) {
-	if e.writeLock.isLocked() {
+	if !e.applyUndoOrRedo(undoOrRedo, shiftDirection) {
+		return
+	}
+
+	e.makeCursorVisibleByScrolling(gtx)
+}
+
+// applyUndoOrRedo undoes or redoes the last transaction without scrolling to show the cursor.
+// It returns false if the editable couldn't be changed.
+func (e *editable) applyUndoOrRedo(undoOrRedo func() []interface{}, shiftDirection int) (ok bool) {
+	if e.writeLock.isLocked() || !e.modificationAllowed() {
 		return
 	}
 
@@ -855,8 +956,7 @@ func (e *editable) undoOrRedo(gtx layout.Context, undoOrRedo func() []interface{
 		e.notifyTextChangeListeners(NewTextChange(ud.startOfChange, shiftDirection*ud.lengthOfChange))
 	}
 
-	e.makeCursorVisibleByScrolling(gtx)
-	return
+	return true
 }
 
 func (e *editable) moveToEndOfDoc(gtx layout.Context) {
@@ -888,6 +988,8 @@ func (e *editable) makeCursorVisibleByScrolling(gtx layout.Context) {
 		return
 	}
 
+	e.makeCursorVisibleHorizontally(gtx)
+
 	cursorIndex := e.firstCursorIndex()
 
 	w := runes.NewWalker(e.Bytes())
@@ -1063,15 +1165,23 @@ func (e *editable) Pointer(gtx layout.Context, ev *pointer.Event) {
 	e.wordCompletion.Reset()
 	e.fileCompletion.Reset()
 	e.invalidateLayedoutText()
+	if ev.Kind == pointer.Press {
+		e.dismissPeek()
+	}
 	e.InitPointerEventHandlers()
 	e.pointerState.Event(ev, gtx)
 	e.ClearRecentlyTypedText()
 }
 
 func (e *editable) runeIndexOfPointerEvent(ev *pointer.Event, text typeset.Text) int {
-	runeIndex := text.IndexOfPixelCoord(ev.Position)
-	runeIndex += e.TopLeftIndex
-	return runeIndex
+	return e.runeIndexOfPosition(ev.Position, text)
+}
+
+func (e *editable) runeIndexOfPosition(pos f32.Point, text typeset.Text) int {
+	pos.X += float32(e.LeftOffset)
+	runeIndex := text.IndexOfPixelCoord(pos)
+	runeIndex += e.displayIndex(e.TopLeftIndex)
+	return e.textIndex(runeIndex)
 }
 
 type verticalDirection int
@@ -1110,6 +1220,148 @@ func (e *editable) ScrollOneLine(gtx layout.Context, d verticalDirection) {
 
 	e.TopLeftIndex = w.RunePos()
 	e.invalidateLayedoutText()
+	e.keepCursorVisibleAfterScrolling(gtx)
+}
+
+// SetScrollMovesCursor sets whether scrolling moves the cursor so that it stays visible,
+// overriding the scroll-moves-cursor setting.
+func (e *editable) SetScrollMovesCursor(b bool) {
+	e.scrollMovesCursor, e.scrollMovesCursorSet = b, true
+}
+
+func (e *editable) ScrollMovesCursor() bool {
+	if e.scrollMovesCursorSet {
+		return e.scrollMovesCursor
+	}
+	return settings.Mouse.ScrollMovesCursor
+}
+
+// keepCursorVisibleAfterScrolling moves the cursor onto the nearest visible line if scrolling
+// moves the cursor. As explained in makeCursorVisibleByMovingCursor this would break working with
+// several cursors, so it is only done when there is a single cursor and no selections.
+func (e *editable) keepCursorVisibleAfterScrolling(gtx layout.Context) {
+	if !e.ScrollMovesCursor() || len(e.CursorIndices) != 1 || e.selectionBeingBuilt != nil {
+		return
+	}
+	e.makeCursorVisibleByMovingCursor(gtx)
+}
+
+// ScrollHorizontally scrolls the text left or right by the given number of pixels. It
+// does nothing if lines are wrapped.
+func (e *editable) ScrollHorizontally(gtx layout.Context, d horizontalDirection, pixels int) {
+	if e.PreventScrolling || !e.noWrap {
+		return
+	}
+
+	if d == Left {
+		pixels = -pixels
+	}
+	e.setLeftOffset(gtx, e.LeftOffset+pixels)
+}
+
+// setLeftOffset sets how far the text is scrolled to the left, limited so that the end of
+// the longest visible line can't be scrolled out of view.
+func (e *editable) setLeftOffset(gtx layout.Context, off int) {
+	ltext, err := e.getOrBuildLayedoutText(gtx, e.visibleText(gtx))
+	if err == nil {
+		widest := 0
+		for _, l := range ltext.Lines() {
+			if w := l.Width().Ceil(); w > widest {
+				widest = w
+			}
+		}
+		max := widest - e.textWidth(gtx)/2
+		if off > max {
+			off = max
+		}
+	}
+	if off < 0 {
+		off = 0
+	}
+	e.LeftOffset = off
+}
+
+// makeCursorVisibleHorizontally scrolls the text horizontally so that the first cursor is visible.
+func (e *editable) makeCursorVisibleHorizontally(gtx layout.Context) {
+	if !e.noWrap {
+		return
+	}
+
+	x := e.xOfRuneIndex(e.firstCursorIndex())
+	width := e.textWidth(gtx)
+	if x >= e.LeftOffset && x < e.LeftOffset+width-e.lineHeight() {
+		return
+	}
+
+	// Leave some context to the left of the cursor
+	off := x - width/4
+	if x < width*3/4 {
+		off = 0
+	}
+	if off < 0 {
+		off = 0
+	}
+	e.LeftOffset = off
+}
+
+// xOfRuneIndex returns the horizontal position in pixels of the rune at index ndx relative to the
+// start of its line, as it is layed out when lines are not wrapped.
+func (e *editable) xOfRuneIndex(ndx int) int {
+	doc := e.Bytes()
+	w := runes.NewWalker(doc)
+	w.SetRunePosCache(ndx, &e.runeOffsetCache)
+	end := w.BytePos()
+	w.BackwardToStartOfLine()
+
+	constraints := typeset.Constraints{
+		FontFaceId:        e.curFontName(),
+		FontSize:          e.curFontSize(),
+		FontFace:          e.curFont(),
+		ReplaceCRWithTofu: e.adapter.replaceCrWithTofu(),
+	}
+	constraints.TabStopInterval = e.tabStopInterval(application.Metric())
+	constraints.TabStops = e.elasticTabStops(doc, w.BytePos(), 1, constraints)
+
+	t, _ := typeset.Layout(doc[w.BytePos():end], constraints)
+	if t.LineCount() == 0 {
+		return 0
+	}
+	return t.Lines()[0].Width().Ceil()
+}
+
+// SetWrap enables or disables wrapping long lines.
+func (e *editable) SetWrap(wrap bool) {
+	e.noWrap = !wrap
+	if wrap {
+		e.LeftOffset = 0
+	}
+	e.invalidateLayedoutText()
+}
+
+func (e *editable) Wrap() bool {
+	return !e.noWrap
+}
+
+// SetTabWidth sets the number of columns between tab stops. 0 uses the tab stop interval from
+// the style.
+func (e *editable) SetTabWidth(columns int) {
+	e.tabWidth = columns
+	e.invalidateLayedoutText()
+}
+
+func (e *editable) TabWidth() int {
+	return e.tabWidth
+}
+
+// tabStopInterval returns the distance between tab stops in pixels.
+func (e *editable) tabStopInterval(m *unit.Metric) int {
+	if e.tabWidth > 0 {
+		return (fixed.Int26_6(e.tabWidth) * e.spaceWidth()).Round()
+	}
+	if m == nil {
+		return int(e.style.TabStopInterval)
+	}
+	return m.Dp(e.style.TabStopInterval)
 }
 
 func (e *editable) ScrollOnePage(gtx layout.Context, d verticalDirection) {
@@ -1157,6 +1409,7 @@ func (e *editable) ScrollOnePage(gtx layout.Context, d verticalDirection) {
 		e.TopLeftIndex = w.RunePos()
 	}
 	e.invalidateLayedoutText()
+	e.keepCursorVisibleAfterScrolling(gtx)
 }
 
 func (e *editable) layoutPreviousPageBackwardsFrom(gtx layout.Context, runeIndex int) (pageLenInRunes int) {
@@ -1210,6 +1463,8 @@ func (e *editable) relayout(gtx layout.Context) {
 func (e *editable) draw(gtx layout.Context) layout.Dimensions {
 	defer e.indentOnLeft(&gtx).Pop()
 	defer e.postDraw(gtx)
+	// The peek overlay is drawn last so that it is on top of the text and cursor.
+	defer e.drawPeek(gtx)
 
 	// Now that we've finished handling all events, prepare the styles.
 	e.prepareStylesChanges(gtx)
@@ -1219,7 +1474,16 @@ func (e *editable) draw(gtx layout.Context) layout.Dimensions {
 		e.adapter.appendError("", err.Error())
 		return layout.Dimensions{Size: image.Point{X: gtx.Constraints.Max.X, Y: 0}}
 	}
+	if e.LeftOffset > 0 {
+		defer clip.Rect{Max: image.Pt(e.textWidth(gtx), gtx.Constraints.Max.Y)}.Push(gtx.Ops).Pop()
+		defer op.Offset(image.Point{-e.LeftOffset, 0}).Push(gtx.Ops).Pop()
+	}
+
+	e.drawGuide(gtx, *e.layedoutText)
+
+	e.textRender.SetRedact(e.redacted)
 	height := e.renderTextWithStyles(gtx, *e.layedoutText)
+	e.drawMissingFinalNewlineMarker(gtx, *e.layedoutText)
 
 	e.drawCursorIn(gtx, *e.layedoutText)
 
@@ -1246,7 +1510,12 @@ func (e *editable) postDraw(gtx layout.Context) {
 func (e *editable) visibleText(gtx layout.Context) []byte {
 	doc := e.Bytes()
 
-	doc, _ = e.removeFirstNRunes(doc, e.TopLeftIndex)
+	if e.displaySubst != nil {
+		// The rune offset cache is for the text without the substitution.
+		doc = removeFirstNRunes(e.displaySubst.apply(doc), e.displayIndex(e.TopLeftIndex))
+	} else {
+		doc, _ = e.removeFirstNRunes(doc, e.TopLeftIndex)
+	}
 
 	h := e.heightInLines(gtx)
 	w := runes.NewWalker(doc)
@@ -1268,11 +1537,44 @@ func (e *editable) heightInLines(gtx layout.Context) int {
 	return int(math.Floor(float64(pixelHeight) / float64(lineHeight)))
 }
 
+// sizeInCharacters returns approximately how many characters fit across the editable and
+// how many lines fit down it, as of the last time it was drawn. The width of characters is
+// taken to be the width of 'M' in the current font.
+func (e *editable) sizeInCharacters() (cols, rows int) {
+	lh := e.lineHeight()
+	if lh > 0 {
+		rows = e.maxSizeLastLayout.Y / lh
+	}
+
+	constraints := typeset.Constraints{
+		FontFaceId: e.curFontName(),
+		FontSize:   e.curFontSize(),
+		FontFace:   e.curFont(),
+	}
+	t, _ := typeset.Layout([]byte("M"), constraints)
+	if t.LineCount() == 0 {
+		return
+	}
+
+	w := t.Lines()[0].Width().Ceil()
+	if w <= 0 {
+		return
+	}
+
+	width := e.maxSizeLastLayout.X
+	if m := application.Metric(); m != nil {
+		width -= m.Dp(e.style.TextLeftPadding)
+	}
+	cols = width / w
+	return
+}
+
 func (e *editable) layoutText(gtx layout.Context, doc []byte) (text *typeset.Text, err error) {
 
 	//log(LogCatgEd,"editable.layoutText: for %s: called for doc %s\n", e.label, doc)
 
 	constraints := e.textLayoutConstraints(gtx)
+	constraints.TabStops = e.elasticTabStopsForVisibleText(bytes.Count(doc, []byte{'\n'})+1, constraints)
 
 	t, errs := typeset.Layout(doc, constraints)
 	text = &t
@@ -1289,12 +1591,26 @@ func (e *editable) textLayoutConstraints(gtx layout.Context) typeset.Constraints
 		FontFaceId:        e.curFontName(),
 		FontSize:          e.curFontSize(),
 		FontFace:          e.curFont(),
-		WrapWidth:         gtx.Constraints.Max.X - gtx.Metric.Dp(e.style.TextLeftPadding),
-		TabStopInterval:   gtx.Metric.Dp(e.style.TabStopInterval),
+		WrapWidth:         e.wrapWidth(gtx),
+		TabStopInterval:   e.tabStopInterval(&gtx.Metric),
 		MaxHeight:         gtx.Constraints.Max.Y,
 		ExtraLineGap:      gtx.Metric.Dp(e.style.LineSpacing),
 		ReplaceCRWithTofu: e.adapter.replaceCrWithTofu(),
+		ShowTabs:          e.wsDisplay == whitespaceDisplayTabs,
+	}
+}
+
+// wrapWidth returns the width in pixels at which lines are wrapped, or 0 if they are not wrapped.
+func (e *editable) wrapWidth(gtx layout.Context) int {
+	if e.noWrap {
+		return 0
 	}
+	return e.textWidth(gtx)
+}
+
+// textWidth returns the width in pixels of the area the text is drawn in.
+func (e *editable) textWidth(gtx layout.Context) int {
+	return gtx.Constraints.Max.X - gtx.Metric.Dp(e.style.TextLeftPadding)
 }
 
 func (e *editable) invalidateLayedoutText() {
@@ -1345,10 +1661,15 @@ func (e *editable) textChanged(b fireListenersBehaviour, textChange TextChange)
 }
 
 func (e *editable) textChangedButDontClearRuneOffsetCache(b fireListenersBehaviour, textChange TextChange) {
+	e.generation++
+	if e.wsHints != nil {
+		e.wsHints.textChanged()
+	}
 	if e.asyncHighlighter != nil {
 		e.asyncHighlighter.Cancel()
 	}
-	e.schedule("highlight-syntax", e.syntaxHighlightDelay, func() { e.HighlightSyntax() })
+	e.syntaxChanged.add(textChange)
+	e.scheduleWithPriority("highlight-syntax", e.syntaxHighlightDelay, workPriorityHigh, e.highlightSyntaxAfterChange)
 
 	e.schedule("build-completions", 300*time.Millisecond, e.BuildCompletions)
 
@@ -1407,22 +1728,13 @@ func (e *editable) executeSelectedWithAllSelectionsInLastSelectedEditable(ps *Po
 	e.executeSelected(ps, args...)
 }
 
-func (e *editable) onPointerPrimaryButtonPress(ps *PointerState) {
-	if ps.currentPointerEvent.Modifiers&key.ModCommand > 0 {
-		// Treat as a tertiary press
-		ps.pressedButtons = ps.pressedButtons & (^pointer.ButtonPrimary)
-		ps.pressedButtons = ps.pressedButtons | pointer.ButtonTertiary
-		e.onPointerTertiaryButtonPress(ps)
-		return
-	}
-
-	if e.pointerState.pressedButtons.Contain(pointer.ButtonTertiary) {
-		e.ignoreTertiaryRelease = true
+func (e *editable) onPointerExecuteWithArgChord(ps *PointerState) {
+	e.ignoreTertiaryRelease = true
 
-		e.executeSelectedWithAllSelectionsInLastSelectedEditable(ps)
-		return
-	}
+	e.executeSelectedWithAllSelectionsInLastSelectedEditable(ps)
+}
 
+func (e *editable) onPointerPrimaryButtonPress(ps *PointerState) {
 	ev := ps.currentPointerEvent
 	runeIndex := ev.runeIndex
 
@@ -1434,6 +1746,9 @@ func (e *editable) onPointerPrimaryButtonPress(ps *PointerState) {
 		if ev.Modifiers&key.ModAlt == 0 {
 			e.setToOneCursorIndex(runeIndex)
 			e.clearSelections()
+			if ev.Modifiers.Contain(key.ModCtrl) {
+				e.boxSelectAnchor = runeIndex
+			}
 		} else {
 			if e.removeCursorAt(runeIndex) {
 				return
@@ -1546,18 +1861,30 @@ func (e *editable) onPointerTertiaryButtonPress(ps *PointerState) {
 	log(LogCatgEd, "Setting editable where tertiary button was pressed\n")
 }
 
-func (e *editable) onPointerTertiaryButtonRelease(ps *PointerState) {
+// endTertiaryButtonHold is called when the tertiary button is released. It returns true if the
+// release was already handled by a chord and should be ignored.
+func (e *editable) endTertiaryButtonHold() (ignore bool) {
 	e.overridingCursorIndices = nil
 	log(LogCatgEd, "Clearing editable where tertiary button was pressed\n")
 	e.adapter.clearEditableWhereTertiaryButtonHoldStarted()
 
 	if e.ignoreTertiaryRelease {
 		e.ignoreTertiaryRelease = false
+		return true
+	}
+	return false
+}
+
+func (e *editable) onPointerCutChord(ps *PointerState) {
+	if e.endTertiaryButtonHold() {
 		return
 	}
 
-	if e.pointerState.pressedButtons.Contain(pointer.ButtonPrimary) {
-		e.adapter.cutAllSelectionsFromLastSelectedEditable(ps.gtx)
+	e.adapter.cutAllSelectionsFromLastSelectedEditable(ps.gtx)
+}
+
+func (e *editable) onPointerTertiaryButtonRelease(ps *PointerState) {
+	if e.endTertiaryButtonHold() {
 		return
 	}
 
@@ -1589,12 +1916,11 @@ func (e *editable) plumb(gtx layout.Context, obj string) (plumbed bool) {
 	return e.adapter.plumb(e, gtx, obj)
 }
 
-func (e *editable) onPointerSecondaryButtonPress(ps *PointerState) {
-	if e.pointerState.pressedButtons.Contain(pointer.ButtonPrimary) {
-		e.adapter.pasteToFocusedEditable(ps.gtx)
-		return
-	}
+func (e *editable) onPointerPasteChord(ps *PointerState) {
+	e.adapter.pasteToFocusedEditable(ps.gtx)
+}
 
+func (e *editable) onPointerSecondaryButtonPress(ps *PointerState) {
 	const (
 		acquire = iota
 		continuePreviousSearch
@@ -1762,6 +2088,10 @@ func (w determineFilePathAndLoadFileWork) Job() Job {
 	return w.job
 }
 
+func (w determineFilePathAndLoadFileWork) priority() workPriority {
+	return workPriorityInteractive
+}
+
 func (w determineFilePathAndLoadFileWork) Name() string {
 	return filepath.Base(w.path)
 }
@@ -1799,8 +2129,8 @@ func (e *editable) moveCursorTo(gtx layout.Context, seek seek, selectBehaviour s
 				l, r = w.CurrentLineBoundsIncludingNl()
 			}
 		} else {
-			w.Forward(seek.runePos)
-			l, r = w.RunePos(), w.RunePos()+1
+			l = seek.runePosIn(doc)
+			r = l + 1
 		}
 	}
 	e.setToOneCursorIndex(l)
@@ -1845,8 +2175,10 @@ func (e *editable) searchAndUpdateEditable(gtx layout.Context, searchAt int, nee
 		}
 	}
 
+	wrapped := false
 	if pos == -1 {
 		// Wrap the search
+		wrapped = true
 		if direction == Forward {
 			pos, end = e.executeOn.Search(0, needle, direction)
 		} else {
@@ -1862,6 +2194,12 @@ func (e *editable) searchAndUpdateEditable(gtx layout.Context, searchAt int, nee
 	e.executeOn.addPrimarySelection(pos, end)
 	e.executeOn.lastSearchResult = e.executeOn.primarySel
 	e.executeOn.lastSearchTerm = needle
+	// Search again for the pips in the scrollbar, in case the text changed since the last
+	// search in a way that made new matches.
+	e.executeOn.searchPips.invalidate()
+	if wrapped {
+		e.executeOn.adapter.searchWrapped()
+	}
 
 	if e.executeOn != e {
 		// This handles a corner case. If you right click to search from the tag of a window,
@@ -1885,13 +2223,19 @@ func (e *editable) searchAndUpdateEditable(gtx layout.Context, searchAt int, nee
 }
 
 func (e *editable) onPointerPrimaryButtonDrag(ps *PointerState) {
+	if e.boxSelectAnchor >= 0 {
+		e.selectBox(e.boxSelectAnchor, ps.currentPointerEvent.runeIndex)
+		e.lastSearchResult = nil
+		return
+	}
+
 	// Extend the selection from the start to here
 	rank := PrimarySelection
 	if ps.currentPointerEvent.Modifiers&key.ModAlt > 0 && e.SelectionsPresent() {
 		rank = SecondarySelection
 	}
 
-	e.scrollIfPointerEventNearEdge(ps)
+	e.autoScrollIfDraggedNearEdge(ps, rank, false)
 
 	e.extendSelectionBeingBuilt(rank, ps.currentPointerEvent.runeIndex)
 	e.lastSearchResult = nil
@@ -1909,32 +2253,65 @@ func (e *editable) onPointerTertiaryButtonDrag(ps *PointerState) {
 	// Extend the selection from the start to here
 	rank := PrimarySelection
 
-	e.scrollIfPointerEventNearEdge(ps)
+	e.autoScrollIfDraggedNearEdge(ps, rank, true)
 
 	e.extendSelectionBeingBuilt(rank, ps.currentPointerEvent.runeIndex)
 	e.primarySelPurpose = SelectionPurposeExecute
 }
 
-func (e *editable) scrollIfPointerEventNearEdge(ps *PointerState) {
-	if ps.currentPointerEvent.Position.Y < float32(e.lineHeight()) {
-		e.ScrollOneLine(ps.gtx, Up)
-	} else if ps.currentPointerEvent.Position.Y > float32((e.heightInLines(ps.gtx)-1)*e.lineHeight()) {
-		e.ScrollOneLine(ps.gtx, Down)
-	}
+func (e *editable) onPointerRelease(ps *PointerState) {
+	e.stopBuildingSelection()
+	e.boxSelectAnchor = -1
 }
 
-func (e *editable) onPointerRelease(ps *PointerState) {
-	if ps.currentPointerEvent.Modifiers&key.ModCommand > 0 {
-		// Treat as a tertiary release
-		ps.pressedButtons = ps.pressedButtons & (^pointer.ButtonPrimary)
-		ps.pressedButtons = ps.pressedButtons | pointer.ButtonTertiary
-		e.onPointerTertiaryButtonRelease(ps)
-		return
+// selectBox makes a cursor in each line from the line containing the rune index anchor to the
+// line containing pos, at the column of pos. If pos is at a different column than anchor, the
+// text in each line between the two columns is also selected. Lines too short to reach a column
+// are cut off at their end.
+func (e *editable) selectBox(anchor, pos int) {
+	w := runes.NewWalker(e.Bytes())
+	bounds := w.LineBoundsInBox(anchor, pos)
+	w.SetRunePosCache(anchor, &e.runeOffsetCache)
+	anchorCol := w.IndexInLine()
+	w.SetRunePosCache(pos, &e.runeOffsetCache)
+	posCol := w.IndexInLine()
+
+	side := Right
+	if posCol < anchorCol {
+		side = Left
+	}
+
+	e.clearSelections()
+	e.CursorIndices = e.CursorIndices[:0]
+	for _, b := range bounds {
+		if side == Left {
+			e.CursorIndices = append(e.CursorIndices, b[0])
+		} else {
+			e.CursorIndices = append(e.CursorIndices, b[1])
+		}
+		if b[0] < b[1] {
+			e.addSecondarySelection(b[0], b[1], side)
+		}
 	}
-	e.stopBuildingSelection()
 }
 
 func (e *editable) onPointerScroll(ps *PointerState) {
+	scroll := ps.currentPointerEvent.Scroll
+	if ps.currentPointerEvent.Modifiers.Contain(key.ModShift) && scroll.X == 0 {
+		scroll.X, scroll.Y = scroll.Y, 0
+	}
+
+	if scroll.Y == 0 {
+		if scroll.X != 0 && e.noWrap {
+			direction := Right
+			if scroll.X < 0 {
+				direction = Left
+			}
+			e.ScrollHorizontally(ps.gtx, direction, 3*e.lineHeight())
+		}
+		return
+	}
+
 	direction := Down
 	if ps.currentPointerEvent.Scroll.Y > 0 {
 		direction = Down
@@ -1947,9 +2324,42 @@ func (e *editable) onPointerScroll(ps *PointerState) {
 		return
 	}
 
-	for i := 0; i < 3; i++ {
+	for i := e.wheelScrollLines(scroll.Y); i > 0; i-- {
 		e.ScrollOneLine(ps.gtx, direction)
 	}
+
+	// Scrolling while dragging a selection extends it to the text now under the pointer. The
+	// rank only matters when starting a selection, so it doesn't matter which is passed.
+	if e.selectionBeingBuilt != nil && !e.PreventScrolling {
+		e.extendSelectionBeingBuiltToPosition(ps.gtx, ps.currentPointerEvent.Position, PrimarySelection, e.draggingTertiaryButton)
+	}
+}
+
+// wheelScrollLines returns how many lines to scroll for a scroll event that moved dy pixels.
+// Normally each event scrolls the number of lines in the scroll-lines setting. With
+// precise-scroll set the pixels are added up, so that the many small events sent by touchpads
+// scroll smoothly, and a line is scrolled for each line height of them.
+func (e *editable) wheelScrollLines(dy float32) int {
+	if !settings.Mouse.PreciseScroll {
+		return max(settings.Mouse.ScrollLines, 1)
+	}
+
+	if (dy < 0) != (e.scrollRemainder < 0) {
+		// Changing direction starts again.
+		e.scrollRemainder = 0
+	}
+	e.scrollRemainder += dy
+
+	h := float32(e.lineHeight())
+	if h <= 0 {
+		return 0
+	}
+	n := int(e.scrollRemainder / h)
+	e.scrollRemainder -= float32(n) * h
+	if n < 0 {
+		n = -n
+	}
+	return n
 }
 
 func (e *editable) adjustFontSizeOnScroll(direction verticalDirection) {
@@ -1973,14 +2383,16 @@ func (e *editable) prepareStylesChanges(gtx layout.Context) {
 	e.initStyleChangesFromSelections(gtx)
 	e.initStyleChangesFromSyntax(gtx)
 	e.initStyleChangesFromManualHighlighting(gtx)
+	e.initStyleChangesFromWhitespaceHints(gtx)
+	e.initStyleChangesFromWhitespaceDisplay(gtx)
 	e.styleSeq.Sort()
 	e.styleChanges = e.styleSeq.Iter()
-	e.styleChanges.ForwardTo(e.TopLeftIndex)
+	e.styleChanges.ForwardTo(e.displayIndex(e.TopLeftIndex))
 }
 
 func (e *editable) renderTextWithStyles(gtx layout.Context, ltext typeset.Text) int {
 	yoffset := 0
-	lineStartIndex := e.TopLeftIndex
+	lineStartIndex := e.displayIndex(e.TopLeftIndex)
 
 	stack := op.Offset(image.Point{}).Push(gtx.Ops)
 
@@ -2040,7 +2452,7 @@ func (e *editable) renderLineWithStyles(gtx layout.Context, ltext *typeset.Text,
 
 	// In case we are in a selection, draw the text background all the way to the right margin
 	if !isLastLine {
-		e.textRender.DrawTextBgRect(gtx, gtx.Constraints.Max.X-xoffset)
+		e.textRender.DrawTextBgRect(gtx, gtx.Constraints.Max.X-xoffset+e.LeftOffset)
 	}
 
 	*lineStartIndex += lineLen
@@ -2096,12 +2508,14 @@ func (e *editable) findCursorsInSlice(gtx layout.Context, ltext *typeset.Text, c
 	const maxInt = int(^uint(0) >> 1)
 	const invalidCursorIndex = maxInt
 
+	topLeft := e.displayIndex(e.TopLeftIndex)
 	cursorIndicesWithinLine := make([]int, len(cursorIndices))
 	for i, v := range cursorIndices {
-		if v < e.TopLeftIndex {
+		v = e.displayIndex(v)
+		if v < topLeft {
 			cursorIndicesWithinLine[i] = invalidCursorIndex
 		} else {
-			cursorIndicesWithinLine[i] = v - e.TopLeftIndex
+			cursorIndicesWithinLine[i] = v - topLeft
 		}
 	}
 
@@ -2197,14 +2611,14 @@ func (e *editable) findFirstCursorIn(gtx layout.Context, ltext *typeset.Text) (p
 
 func (e *editable) initStyleChangesFromSelections(gtx layout.Context) {
 	for _, s := range e.selections {
-		e.styleSeq.AddWithoutSort(s)
+		e.addStyleChange(s)
 	}
 }
 
 func (e *editable) initStyleChangesFromSyntax(gtx layout.Context) {
 	if e.syntaxTokens != nil {
 		for _, i := range e.syntaxTokens {
-			e.styleSeq.AddWithoutSort(i)
+			e.addStyleChange(i)
 		}
 	}
 
@@ -2213,7 +2627,7 @@ func (e *editable) initStyleChangesFromSyntax(gtx layout.Context) {
 
 func (e *editable) initStyleChangesFromManualHighlighting(gtx layout.Context) {
 	for _, i := range e.manualHighlighting {
-		e.styleSeq.AddWithoutSort(i)
+		e.addStyleChange(i)
 	}
 }
 
@@ -2261,7 +2675,12 @@ func (e *editable) HighlightSyntax() {
 	// it is cancelled and run in the background asynchronously. This is so that typing in a large
 	// document doesn't seem to lag since the highlighting doesn't appear to take so long when it
 	// does run.
+	//
+	// Fourth, when only a small region of the text changed since the last highlighting, only the
+	// lines around it are lexed again (see editable.highlightSyntaxAfterChange).
 
+	e.syntaxChanged = syntaxChangedRegion{}
+	e.syntaxTokensCurrent = false
 	if e.syntaxHighlighter != nil && e.text.Len() < e.syntaxMaxDocSize {
 		var err error
 		toks, err := e.asyncHighlighter.Highlight(string(e.Bytes()))
@@ -2274,6 +2693,7 @@ func (e *editable) HighlightSyntax() {
 				e.syntaxHighlightDelay = 100 * time.Millisecond
 			}
 		}
+		e.syntaxTokensCurrent = err == nil
 		//log(LogCatgEd,"setting syntax tokens to %p after highlighting\n", toks)
 		e.syntaxTokens = toks
 	} else {
@@ -2288,14 +2708,22 @@ func (e *editable) BuildCompletions() {
 	}
 }
 
+func (e *editable) fgColor() Color {
+	if e.flashed {
+		return e.flashFgColor
+	}
+	return e.style.FgColor
+}
+
 func (e *editable) applyStyleFor(c []intvl.Interval) {
 	e.textRender.SetDrawBg(false)
 
 	if c == nil || len(c) == 0 {
 		// Use the default style.
-		e.textRender.SetFgColor(e.style.FgColor)
+		e.textRender.SetFgColor(e.fgColor())
 		return
 	}
+	c = originalIntervals(c)
 
 	// Process selections first. If there are any selections active, don't do syntax
 	// highlighting.
@@ -2324,9 +2752,19 @@ func (e *editable) applyStyleFor(c []intvl.Interval) {
 
 	if !foundSel {
 		for _, intvl := range c {
-			syn, ok := intvl.(*SyntaxInterval)
-			if ok {
-				e.textRender.SetFgColor(syn.Color())
+			switch v := intvl.(type) {
+			case *SyntaxInterval:
+				e.textRender.SetFgColor(v.Color())
+			case *whitespaceTint:
+				e.textRender.SetBgColor(e.whitespaceHintColor(0x28))
+			case *trailingWhitespace:
+				e.textRender.SetBgColor(e.style.TrailingWhitespaceBgColor)
+			}
+		}
+		// Tab guides are drawn faintly even within syntax highlighted text.
+		for _, intvl := range c {
+			if _, ok := intvl.(*tabGuide); ok {
+				e.textRender.SetFgColor(e.style.TabGuideColor)
 			}
 		}
 	}
@@ -2405,22 +2843,18 @@ func (e *editable) drawCursor(gtx layout.Context) {
 func (e *editable) InsertText(text string) {
 	e.invalidateLayedoutText()
 
-	startTransaction := func() {
+	// The number of cursors may change while the text is inserted, so whether a transaction is
+	// needed is decided once before inserting.
+	inTransaction := func(fn func()) {
 		if len(text) == 1 && len(e.CursorIndices) == 1 {
 			// This is likely the user typing just after matching-bracket insertion.
 			// Don't undo both changes together; treat this separately
+			fn()
 			return
 		}
-		e.text.StartTransaction()
 		e.SetSaveDeletes(false)
-	}
-
-	endTransaction := func() {
-		if len(text) == 1 && len(e.CursorIndices) == 1 {
-			return
-		}
+		e.text.RunInTransaction(txOwnerMultiEdit, fn)
 		e.SetSaveDeletes(true)
-		e.text.EndTransaction()
 	}
 
 	if e.SelectionsPresent() {
@@ -2434,16 +2868,16 @@ func (e *editable) InsertText(text string) {
 			return
 		}
 
-		startTransaction()
-		switch e.typingInSelectedTextAction {
-		case appendTextToSelections:
-			e.appendToAllSelections(text)
-		case replaceSelectionsWithText:
-			e.replaceAllSelectionsWith(text)
-		}
+		inTransaction(func() {
+			switch e.typingInSelectedTextAction {
+			case appendTextToSelections:
+				e.appendToAllSelections(text)
+			case replaceSelectionsWithText:
+				e.replaceAllSelectionsWith(text)
+			}
+		})
 
 		e.typingInSelectedTextAction = appendTextToSelections
-		endTransaction()
 		return
 	}
 
@@ -2451,9 +2885,9 @@ func (e *editable) InsertText(text string) {
 		return
 	}
 
-	startTransaction()
-	e.InsertTextAtEachCursor(text)
-	endTransaction()
+	inTransaction(func() {
+		e.InsertTextAtEachCursor(text)
+	})
 }
 
 func (e *editable) InsertTextAtEachCursor(text string) {
@@ -2464,24 +2898,22 @@ func (e *editable) InsertTextAtEachCursor(text string) {
 }
 
 func (e *editable) InsertTextAtCursors(text []string) {
-	e.text.StartTransaction()
 	e.SetSaveDeletes(false)
-
-	if len(text) > len(e.CursorIndices) {
-		for i := len(e.CursorIndices); i < len(text); i++ {
-			e.AddNewCursorBelowLast()
+	e.text.RunInTransaction(txOwnerMultiEdit, func() {
+		if len(text) > len(e.CursorIndices) {
+			for i := len(e.CursorIndices); i < len(text); i++ {
+				e.AddNewCursorBelowLast()
+			}
 		}
-	}
 
-	for i, ndx := range e.CursorIndices {
-		if i < len(text) {
-			e.insertToPieceTable(ndx, text[i])
-			e.CursorIndices[i] += utf8.RuneCountInString(text[i])
+		for i, ndx := range e.CursorIndices {
+			if i < len(text) {
+				e.insertToPieceTable(ndx, text[i])
+				e.CursorIndices[i] += utf8.RuneCountInString(text[i])
+			}
 		}
-	}
-
+	})
 	e.SetSaveDeletes(true)
-	e.text.EndTransaction()
 }
 
 func (e *editable) DelimitSelectionsWithCursors() {
@@ -2650,7 +3082,7 @@ func (e *editable) cutAllSelectedText(gtx layout.Context) {
 		selTexts = append(selTexts, t)
 		buf.WriteString(t)
 	}
-	editor.SetLastSelectionsWrittenToClipboard(selTexts)
+	e.setSlots(selTexts)
 
 	e.StartTransaction()
 	for _, s := range sels {
@@ -2675,7 +3107,7 @@ func (e *editable) copyAllSelectedText(gtx layout.Context) {
 		selTexts = append(selTexts, t)
 		buf.WriteString(t)
 	}
-	editor.SetLastSelectionsWrittenToClipboard(selTexts)
+	e.setSlots(selTexts)
 
 	log(LogCatgEd, "%s: copying this text to clipboard: '%s'\n", e.label, buf.String())
 
@@ -2707,8 +3139,6 @@ func (e *editable) insertToPieceTableUndoIndex(index int, text string, undoIndex
 
 func (e *editable) deleteFromPieceTable(index, length int) {
 	e.deleteFromPieceTableUndoIndex(index, length, index)
-	e.invalidateLayedoutText()
-	e.textChanged(fireListeners, NewTextChange(index, -length))
 }
 
 func (e *editable) deleteFromPieceTableUndoIndex(index, length, undoIndex int) {
@@ -2756,30 +3186,45 @@ func (e *editable) SetFocus(gtx layout.Context) {
 }
 
 func (e *editable) schedule(id string, d time.Duration, f func()) {
+	e.scheduleWithPriority(id, d, workPriorityNormal, f)
+}
+
+func (e *editable) scheduleWithPriority(id string, d time.Duration, p workPriority, f func()) {
 	if e.Scheduler == nil {
 		log(LogCatgEd, "editable: can't schedule %s: scheduler is nil\n", id)
 		return
 	}
 
-	e.Scheduler.AfterFunc(id, d, f)
+	e.Scheduler.AfterFuncWithPriority(id, d, p, f)
 }
 
 func (e *editable) doWordCompletion(ctx completionContext, direction direction) {
-
-	moveCurrentWordToEndOfCompletions := func(comps []words.Completion) {
-		slice.FindAndMoveToEnd(comps, func(i int) bool { return comps[i].Word() == ctx.word })
-	}
-
 	if e.completer != nil {
 		if e.wordCompletion.NeedCompletions() {
-			comps, _ := e.completer.Completions(ctx.prefix)
-			moveCurrentWordToEndOfCompletions(comps)
-			e.wordCompletion.SetCompletions(e.convertCompletionsToWorders(comps))
+			e.wordCompletion.SetCompletions(e.convertCompletionsToWorders(e.wordCompletions(ctx)))
 		}
 		e.wordCompletion.ApplyCompletion(ctx, direction)
 	}
 }
 
+// wordCompletions returns the completions of the prefix in ctx from all of the sources, with the
+// word being completed last.
+func (e *editable) wordCompletions(ctx completionContext) []words.Completion {
+	comps, _ := e.completer.Completions(ctx.prefix)
+	comps = words.MergeCompletions(comps, staticCompletions(e, ctx.prefix, e.followsDollar(ctx.prefixStartIndex)), tagsCompletions(e, ctx.prefix))
+	slice.FindAndMoveToEnd(comps, func(i int) bool { return comps[i].Word() == ctx.word })
+	return comps
+}
+
+// refreshWordCompletion updates the completions of the word completion in progress, after a
+// source of completions that was being loaded when it began was loaded.
+func (e *editable) refreshWordCompletion() {
+	if e.completer == nil || !e.wordCompletion.isCompletionInProgress() {
+		return
+	}
+	e.wordCompletion.refresh(e.convertCompletionsToWorders(e.wordCompletions(e.wordCompletion.context)))
+}
+
 func (e *editable) convertCompletionsToWorders(comps []words.Completion) []Worder {
 	var w []Worder
 	for _, c := range comps {
@@ -2953,21 +3398,20 @@ func (e *editable) AppendToSelection(sel *selection, text string) {
 	e.notifyTextChangeListeners(NewTextChange(sel.Start()+sel.Len(), l))
 }
 
+// attemptBlockPaste is called for a plain paste. If the text pasted is the text of the clipboard
+// slots it pastes the slots at separate cursors instead, guessing that the user wants to paste
+// the selections that were copied together. The explicit slot pastes (see PasteSlots) don't
+// guess.
 func (e *editable) attemptBlockPaste(text string) (success bool) {
-	if len(editor.LastSelectionsWrittenToClipboard()) < 2 || e.SelectionsPresent() {
+	if editor.Slots.Len() < 2 || e.SelectionsPresent() {
 		return
 	}
 
-	var buf bytes.Buffer
-	for _, t := range editor.LastSelectionsWrittenToClipboard() {
-		buf.WriteString(t)
-	}
-
-	if buf.String() != text {
+	if strings.Join(editor.Slots.Texts(), "") != text {
 		return
 	}
 
-	lt := editor.LastSelectionsWrittenToClipboard()
+	lt := editor.Slots.Texts()
 	e.InsertTextAtCursors(lt)
 
 	e.clearSelections()
@@ -2992,9 +3436,15 @@ func (s setSyntaxTokens) Job() Job {
 	return nil
 }
 
+func (s setSyntaxTokens) priority() workPriority {
+	return workPriorityHigh
+}
+
 func (s setSyntaxTokens) Service() (done bool) {
 	log(LogCatgSyntax, "Setting syntax tokens from background\n")
 	s.e.syntaxTokens = s.tokens
+	// If the text changed since the highlighting began the tokens are out of date.
+	s.e.syntaxTokensCurrent = !s.e.syntaxChanged.changed && !s.e.syntaxChanged.unknown
 	return true
 }
 
@@ -3042,21 +3492,21 @@ func (m *matchingBracketInsertion) insertMatchingBrackets(e *editable) {
 	m.savedCursorIndices = make([]int, len(e.CursorIndices))
 	copy(m.savedCursorIndices, e.CursorIndices)
 
-	e.text.StartTransaction()
-	even := true
-	sort.Ints(e.CursorIndices)
-	for i, ndx := range e.CursorIndices {
-		t := m.closer
-		if even {
-			t = m.opener
-		}
+	e.text.RunInTransaction(txOwnerBracketInsertion, func() {
+		even := true
+		sort.Ints(e.CursorIndices)
+		for i, ndx := range e.CursorIndices {
+			t := m.closer
+			if even {
+				t = m.opener
+			}
 
-		text := string(t)
-		e.insertToPieceTable(ndx, text)
-		e.CursorIndices[i] += utf8.RuneCountInString(text)
-		even = !even
-	}
-	e.text.EndTransaction()
+			text := string(t)
+			e.insertToPieceTable(ndx, text)
+			e.CursorIndices[i] += utf8.RuneCountInString(text)
+			even = !even
+		}
+	})
 }
 
 func (m *matchingBracketInsertion) Undo(gtx layout.Context, e *editable) (undone bool) {
@@ -3108,6 +3558,39 @@ func (c *completion) SetCompletions(completions []Worder) {
 	c.completions = completions
 }
 
+// refresh replaces the completions of the completion in progress, keeping the one that is shown.
+func (c *completion) refresh(completions []Worder) {
+	if len(completions) == 0 || sameWords(completions, c.completions) {
+		return
+	}
+
+	shown := c.completions[c.completionToShow].Word()
+	c.completions = completions
+	c.completionToShow = 0
+	for i, w := range completions {
+		if w.Word() == shown {
+			c.completionToShow = i
+			break
+		}
+	}
+
+	if len(c.completions) > 1 {
+		c.editable.showCompletions(c.dir, c.completions)
+	}
+}
+
+func sameWords(a, b []Worder) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Word() != b[i].Word() {
+			return false
+		}
+	}
+	return true
+}
+
 func (c *completion) ApplyCompletion(ctx completionContext, direction direction) {
 	if c.isCompletionInProgress() {
 		delta := 1
@@ -3307,3 +3790,12 @@ func (e *editable) RotateSelections() {
 	e.invalidateLayedoutText()
 	e.textChanged(fireListeners, TextChange{})
 }
+
+func (e *editable) SwapSelections() error {
+	if err := e.editableModel.SwapSelections(); err != nil {
+		return err
+	}
+	e.invalidateLayedoutText()
+	e.textChanged(fireListeners, TextChange{})
+	return nil
+}
@@ -6,9 +6,10 @@ import (
 	"fmt"
 	"image"
 	"image/color"
-	"math"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"gioui.org/f32"
@@ -32,15 +33,30 @@ type Editor struct {
 	focusedWindow                          *Window
 	jobs                                   []Job
 	work                                   chan Work
+	workQueue                              *workQueue
 	recentFiles                            *LRUCache
+	closedFiles                            closedFileList
 	completer                              *words.Completer
 	Marks                                  Marks
+	Macros                                 Macros
 	opsForNextLayout                       OpsForNextLayout
 	redrawRequired                         bool
 	editableWhereTertiaryButtonHoldStarted *editable
 	showBasenamesOnlyInTags                bool
 	insertWhenTabPressed                   string
-	lastSelectionsWrittenToClipboard       []string
+	Slots                                  ClipboardSlots
+	// colWidthPctsLoaded is true when the column widths loaded by SetState still need to be applied.
+	colWidthPctsLoaded bool
+	follower           fileFollower
+	// killTimers holds a timer for each job that was killed but has not ended yet.
+	killTimers map[Job]*time.Timer
+	// jobInfo holds the id and start time of each running job.
+	jobInfo   map[Job]jobInfo
+	lastJobId int
+	// windowJobs holds the running jobs that work on each window.
+	windowJobs map[*Window][]Job
+	// colsTopY is the Y position of the top of the columns at the last layout.
+	colsTopY int
 }
 
 type Job interface {
@@ -48,6 +64,27 @@ type Job interface {
 	Name() string
 }
 
+// JobResulter is implemented by jobs that can report how they finished.
+type JobResulter interface {
+	// Failed returns true if the job finished with an error.
+	Failed() bool
+	// Killed returns true if the job was stopped using Kill.
+	Killed() bool
+}
+
+// JobStartTimer is implemented by jobs that know when they started. For other jobs the time they
+// were added to the editor is used.
+type JobStartTimer interface {
+	StartTime() time.Time
+}
+
+// jobInfo is what the editor records about a running job. The id is unique for the life of the
+// editor, so that it can be used to refer to one of several jobs with the same name.
+type jobInfo struct {
+	id      int
+	started time.Time
+}
+
 type StartNexter interface {
 	// build and add the next job to the editor
 	StartNext()
@@ -67,6 +104,7 @@ func NewEditor(style Style) *Editor {
 
 	e.insertWhenTabPressed = "\t"
 	e.work = make(chan Work)
+	e.workQueue = newWorkQueue(e.work)
 	e.layout.ed = e
 	executor := NewCommandExecutor(e)
 	finder := NewFileFinder(nil)
@@ -74,6 +112,7 @@ func NewEditor(style Style) *Editor {
 	e.Tag.Init(nil, style.tagBlockStyle(), style.tagEditableStyle(), executor, finder, e, scheduler)
 	e.Tag.label = "editor"
 	e.setInitialTag()
+	e.Tag.AddTextChangeListener(e.notifyApiTagChanged)
 	e.completer = words.NewCompleter()
 	return e
 }
@@ -140,20 +179,12 @@ func (e *Editor) NewWindow(col *Col) *Window {
 		return col.NewWindow()
 	}
 
-	cols := e.VisibleCols()
-	if len(cols) == 0 {
+	col = e.colForNewWindow()
+	if col == nil {
 		return nil
 	}
-	leastPopulated := cols[0]
-	count := math.MaxInt
-	for _, c := range cols {
-		if len(c.Windows) < count {
-			leastPopulated = c
-			count = len(c.Windows)
-		}
-	}
 
-	w := leastPopulated.NewWindow()
+	w := col.NewWindow()
 	return w
 }
 
@@ -173,9 +204,11 @@ func (e *Editor) AppendError(dir string, msg string) {
 	if w != nil {
 		w.SetFilenameAndTag(fname, typeFile)
 		w.Append([]byte(msg))
-		w.GrowIfBodyTooSmall()
+		w.GrowForOutputIfBodyTooSmall()
 		w.Body.AddOpForNextLayout(func(gtx layout.Context) {
-			w.Body.moveToEndOfDoc(gtx)
+			if !w.tailingSuspended {
+				w.Body.moveToEndOfDoc(gtx)
+			}
 			// This is to force a redraw
 			w.Body.invalidateLayedoutText()
 			e.SetOnlyFlashedWindow(w)
@@ -224,7 +257,6 @@ func (e *Editor) LoadFileOpts(path string, opts LoadFileOpts) *Window {
 		w.showIfHidden()
 
 		w.GrowIfBodyTooSmall()
-		// TODO: Warp pointer to here
 		w.Body.AddOpForNextLayout(func(gtx layout.Context) {
 			w.Body.moveCursorTo(gtx, opts.GoTo, opts.SelectBehaviour)
 		})
@@ -285,8 +317,14 @@ func (e *Editor) DelWindow(w *Window) {
 	if count == 1 {
 		log(LogCatgEditor, "Editor.DelWindow: sending file closed notification\n")
 		e.notifyFileClosed(w)
+		e.noteFileClosed(w)
 	}
 
+	if w.fuzzySearch != nil {
+		w.fuzzySearch.stopWatching()
+	}
+	autosave.forget(w.file, w)
+
 	application.WinIdGenerator().Free(w.Id)
 	w.col.markForRemoval(w)
 	e.SignalRedrawRequired()
@@ -400,6 +438,7 @@ func (l *editorLayouter) layout(gtx layout.Context) {
 	st := l.offset(0, tagDims.Size.Y)
 	l.drawBottomBorder(gtx)
 	st2 := l.offset(0, gtx.Metric.Dp(l.style.WinBorderWidth))
+	l.ed.colsTopY = tagDims.Size.Y + gtx.Metric.Dp(l.style.WinBorderWidth)
 
 	l.gtx.Constraints.Max.Y -= tagDims.Size.Y
 
@@ -481,6 +520,7 @@ func (e *editorLayouter) drawBottomBorder(gtx layout.Context) {
 
 func (e *Editor) positionCols() {
 	e.packNewCols()
+	e.applyLoadedColWidthPcts()
 	e.spaceColsEvenlyIfWidthChanged()
 }
 
@@ -716,9 +756,44 @@ func (e *Editor) AddJob(j Job) {
 	log(LogCatgEditor, "editor.AddJob called for job %s\n", j.Name())
 
 	e.jobs = append(e.jobs, j)
+	e.addJobInfo(j)
+	e.indexJobWindow(j)
 	e.prependJobToTag(j)
 }
 
+func (e *Editor) addJobInfo(j Job) {
+	if e.jobInfo == nil {
+		e.jobInfo = map[Job]jobInfo{}
+	}
+
+	started := time.Now()
+	if s, ok := j.(JobStartTimer); ok && !s.StartTime().IsZero() {
+		started = s.StartTime()
+	}
+	e.lastJobId++
+	e.jobInfo[j] = jobInfo{id: e.lastJobId, started: started}
+}
+
+// JobId returns the id of the running job j, or 0 if it is not running.
+func (e *Editor) JobId(j Job) int {
+	return e.jobInfo[j].id
+}
+
+// JobStartTime returns when the running job j started.
+func (e *Editor) JobStartTime(j Job) time.Time {
+	return e.jobInfo[j].started
+}
+
+// jobWithId returns the running job with the id, or nil if there is none.
+func (e *Editor) jobWithId(id int) Job {
+	for _, j := range e.jobs {
+		if e.JobId(j) == id {
+			return j
+		}
+	}
+	return nil
+}
+
 func (e *Editor) RemoveJob(job Job) {
 	if job == nil {
 		return
@@ -737,8 +812,15 @@ func (e *Editor) RemoveJob(job Job) {
 	}
 
 	e.jobs = keep
+	delete(e.jobInfo, job)
+	e.unindexJobWindow(job)
+	e.stopKillTimer(job)
 	if found {
 		e.removeJobFromTag(job)
+		if c, ok := job.(JobCleaner); ok {
+			c.Cleanup()
+		}
+		application.JobFinished(job)
 	}
 }
 
@@ -810,23 +892,53 @@ func (e *Editor) prependJobToTag(job Job) {
 	e.Tag.insertToPieceTable(0, s)
 }
 
+// KillJob kills the first job whose name is name, or if name is # followed by a number, the job
+// with that id. If name is empty the first job is killed.
 func (e *Editor) KillJob(name string) {
 	if name == "" {
 		e.killFirstJob()
 		return
 	}
 
+	if id, ok := parseJobId(name); ok {
+		if j := e.jobWithId(id); j != nil {
+			e.killJob(j)
+		} else {
+			e.AppendError("", fmt.Sprintf("Kill: there is no job with id #%d", id))
+		}
+		return
+	}
+
 	for _, j := range e.jobs {
-		if j.Name() == name {
-			j.Kill()
+		if jobNameMatches(j.Name(), name) {
+			e.killJob(j)
 			break
 		}
 	}
 }
 
+// parseJobId parses a job id written as # followed by a number, as shown by the Jobs command.
+func parseJobId(s string) (id int, ok bool) {
+	if !strings.HasPrefix(s, "#") {
+		return
+	}
+	id, err := strconv.Atoi(s[1:])
+	return id, err == nil && id > 0
+}
+
+// jobListing returns a line for each running job giving its id, name and how long it has run.
+func (e *Editor) jobListing() string {
+	var buf bytes.Buffer
+	for _, j := range e.jobs {
+		elapsed := time.Since(e.JobStartTime(j)).Round(time.Second)
+		fmt.Fprintf(&buf, "#%d\t%s\t%s\n", e.JobId(j), j.Name(), elapsed)
+	}
+	return buf.String()
+}
+
 func (e *Editor) killFirstJob() {
 	if len(e.jobs) > 0 {
-		e.jobs[0].Kill()
+		e.killJob(e.jobs[0])
 	}
 }
 
@@ -834,6 +946,11 @@ func (e *Editor) WorkChan() chan Work {
 	return e.work
 }
 
+// ReadyWork returns the channel the work sent to WorkChan is read from, highest priority first.
+func (e *Editor) ReadyWork() chan Work {
+	return e.workQueue.Ready()
+}
+
 // setInitialTag is needed instead of using setTag when initializing to avoid an initialization
 // loop, when the global editor variable is being initialized and it refers back to itself when
 // the Tag editable tries to clear it's selections (and notify the main editor)
@@ -855,16 +972,6 @@ func (e *Editor) jobList() string {
 	return buf.String()
 }
 
-func (e *Editor) Putall() {
-	for _, c := range e.Cols {
-		for _, w := range c.Windows {
-			if w.fileType == typeFile && !w.IsErrorsWindow() {
-				w.Put()
-			}
-		}
-	}
-}
-
 func (e *Editor) Completer() *words.Completer {
 	return e.completer
 }
@@ -1001,12 +1108,53 @@ func (c *LRUCache) All() []string {
 	return r
 }
 
-func (e *Editor) SetColVisible(colName string) {
-	for _, c := range e.Cols {
-		if c.Name() == colName {
-			c.SetVisible(true)
+// FindCol returns the column named by ref, which is either its index counting from 1 at the left,
+// including the hidden columns, or its name as listed by Cols. A name that more than one column
+// has is an error that lists the indexes of those columns.
+func (e *Editor) FindCol(ref string) (*Col, error) {
+	ref = strings.TrimSpace(ref)
+
+	if n, err := strconv.Atoi(ref); err == nil {
+		if n < 1 || n > len(e.Cols) {
+			return nil, fmt.Errorf("there is no column %d; there are %d columns", n, len(e.Cols))
+		}
+		return e.Cols[n-1], nil
+	}
+
+	var named []string
+	var col *Col
+	for i, c := range e.Cols {
+		if c.Name() == ref {
+			named = append(named, strconv.Itoa(i+1))
+			col = c
 		}
 	}
+	switch len(named) {
+	case 0:
+		names := make([]string, len(e.Cols))
+		for i, c := range e.Cols {
+			names[i] = c.Name()
+		}
+		return nil, fmt.Errorf("there is no column named %s. The columns are: %s", ref, strings.Join(names, ", "))
+	case 1:
+		return col, nil
+	}
+	return nil, fmt.Errorf("more than one column is named %s. Use the index of one of them instead: %s", ref, strings.Join(named, ", "))
+}
+
+// colRef returns how FindCol can be given the column at index i: the name of the column if no
+// other column has it, and otherwise its index.
+func (e *Editor) colRef(i int) string {
+	name := e.Cols[i].Name()
+	if _, err := strconv.Atoi(name); err == nil {
+		return strconv.Itoa(i + 1)
+	}
+	for j, c := range e.Cols {
+		if j != i && c.Name() == name {
+			return strconv.Itoa(i + 1)
+		}
+	}
+	return name
 }
 
 func (e *Editor) SetFirstHiddenColVisible() {
@@ -1020,16 +1168,16 @@ func (e *Editor) SetFirstHiddenColVisible() {
 
 func (e *Editor) ListCols(includeFiles, includeShowCommand bool) string {
 	var buf bytes.Buffer
-	for _, c := range e.Cols {
-		buf.WriteString(c.Name())
+	for i, c := range e.Cols {
+		fmt.Fprintf(&buf, "%d %s", i+1, c.Name())
 		if !c.Visible() {
 			buf.WriteString(" (hidden)")
 		}
 		if includeShowCommand {
 			if !c.Visible() {
-				fmt.Fprintf(&buf, " ◊Showcol %s◊", c.Name())
+				fmt.Fprintf(&buf, " ◊Showcol %s◊", e.colRef(i))
 			} else {
-				fmt.Fprintf(&buf, " ◊Hidecol %s◊", c.Name())
+				fmt.Fprintf(&buf, " ◊Hidecol %s◊", e.colRef(i))
 			}
 		}
 		buf.WriteRune('\n')
@@ -1059,11 +1207,3 @@ func (e *Editor) setInsertWhenTabPressed(s string) {
 func (e *Editor) getInsertWhenTabPressed() string {
 	return e.insertWhenTabPressed
 }
-
-func (e *Editor) SetLastSelectionsWrittenToClipboard(t []string) {
-	e.lastSelectionsWrittenToClipboard = t
-}
-
-func (e *Editor) LastSelectionsWrittenToClipboard() []string {
-	return e.lastSelectionsWrittenToClipboard
-}
@@ -1,6 +1,12 @@
 package main
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+)
 
 func TestRemoveTagFromString(t *testing.T) {
 
@@ -213,3 +219,34 @@ func TestSubstitute(t *testing.T) {
 		})
 	}
 }
+
+func TestTailingCanBeSuspendedMidJob(t *testing.T) {
+	newTestEditor()
+
+	win := editor.FindOrCreateWindow("+Errors")
+	holder := NewWindowHolder(win)
+	win.Body.opsForNextLayout = nil
+
+	winLoadGoToEnd{win: holder}.Service()
+	if len(win.Body.opsForNextLayout) != 1 {
+		t.Fatalf("expected appended output to move to the end of the window")
+	}
+
+	win.Body.opsForNextLayout = nil
+	win.SetTailing(false)
+	if !strings.Contains(win.Tag.String(), " Tail ") {
+		t.Fatalf("expected the tag to show that tailing is suspended but it is %q", win.Tag.String())
+	}
+
+	winLoadGoToEnd{win: holder}.Service()
+	if len(win.Body.opsForNextLayout) != 0 {
+		t.Fatalf("expected appended output not to move to the end of the window while tailing is suspended")
+	}
+
+	win.Body.SetText([]byte("output\n"))
+	win.Body.CursorIndices = []int{win.Body.text.Len()}
+	win.Body.KeyPress(layout.Context{}, &key.Event{Name: "⏎"})
+	if !win.IsTailing() {
+		t.Fatalf("expected Enter at the end of the +Errors window to resume tailing")
+	}
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/jeffwilliams/anvil/internal/runes"
+)
+
+// This file implements POST /edits, which applies a set of edits to many files such that
+// either all of the edits are applied or none are. Files that are open in a window are
+// edited in the window body, and the other files are edited on disk.
+
+type apiEditGroup struct {
+	Path string
+	// Generation, if set, is the generation of the window body that the edit offsets refer to.
+	// The edits are rejected if the body has changed since.
+	Generation *int
+	Edits      []apiEdit
+}
+
+// apiEdit replaces Length runes at rune offset Offset with Text.
+type apiEdit struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+type apiEditsResponse struct {
+	Files []apiEditResult
+}
+
+type apiEditResult struct {
+	Path  string
+	Error string `json:",omitempty"`
+}
+
+// editsError is the reason a file can't be edited. Conflict is true if the edits don't
+// apply to the file (HTTP 409) and false if editing the file failed for another reason,
+// like a disk error (HTTP 500).
+type editsError struct {
+	err      error
+	conflict bool
+}
+
+func conflictError(format string, args ...interface{}) *editsError {
+	return &editsError{err: fmt.Errorf(format, args...), conflict: true}
+}
+
+func ioError(err error) *editsError {
+	return &editsError{err: err}
+}
+
+func (a ApiHandler) serveEdits(rsp http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		msg := fmt.Sprintf("Method %s is not supported for %s", req.Method, req.URL.Path)
+		http.Error(rsp, msg, http.StatusBadRequest)
+		return
+	}
+
+	var groups []apiEditGroup
+	_, dec, err := a.getDecoder(rsp, req)
+	if err == nil {
+		err = dec.Decode(&groups)
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Decoding edits failed: %v", err)
+		http.Error(rsp, msg, http.StatusBadRequest)
+		return
+	}
+
+	status, result := applyEdits(groups)
+
+	contentType, enc, flush := a.getEncoderForHTTPResponse(rsp, req)
+	rsp.Header().Add("Content-Type", string(contentType))
+	rsp.WriteHeader(status)
+	enc.Encode(result)
+	flush()
+}
+
+// fileEdit is the state of applying the edits for one file.
+type fileEdit struct {
+	group apiEditGroup
+	err   *editsError
+
+	// win is the window the file is open in, or nil if it is edited on disk.
+	win *Window
+	// savedCursors are the cursor positions in win before the edits, restored if they are rolled back.
+	savedCursors []int
+	// appliedGeneration is the generation of the body of win once the edits were applied. The
+	// edits are only rolled back if the body hasn't changed since.
+	appliedGeneration int
+
+	fs       simpleFs
+	original []byte
+	tmpPath  string
+	renamed  bool
+}
+
+// applyEdits applies the edit groups. First the windows the files are open in are found, and the
+// files that aren't open are written to temporary files off the main goroutine. Then the edits
+// are applied to the windows in one work item, so that neither the user nor anything else sees or
+// changes some of them without the others, and the temporary files are renamed over the originals
+// off the main goroutine, since renaming a remote file takes round trips over the network. If a
+// rename fails the window edits are undone in another work item, unless the window was changed
+// in between.
+func applyEdits(groups []apiEditGroup) (status int, rsp apiEditsResponse) {
+	edits := make([]*fileEdit, len(groups))
+	for i, g := range groups {
+		edits[i] = &fileEdit{group: g}
+	}
+
+	ok := validateEditGroups(edits)
+	if ok {
+		onMainGoroutine(func() {
+			ok = findAndValidateEditWindows(edits)
+		})
+	}
+	if ok {
+		ok = writeTemporaryFiles(edits)
+	}
+	if ok {
+		onMainGoroutine(func() {
+			ok = commitEditsToWindows(edits)
+		})
+		if ok {
+			ok = renameTemporaryFiles(edits)
+			if !ok {
+				onMainGoroutine(func() {
+					rollBackEditsToWindows(edits)
+				})
+			}
+		}
+	}
+	if ok {
+		return http.StatusOK, editsResponse(edits)
+	}
+	rollBackEditsToDisk(edits)
+
+	status = http.StatusConflict
+	for _, e := range edits {
+		if e.err != nil && !e.err.conflict {
+			status = http.StatusInternalServerError
+		}
+	}
+	return status, editsResponse(edits)
+}
+
+// onMainGoroutine runs fn as a work item and waits for it to finish.
+func onMainGoroutine(fn func()) {
+	done := make(chan struct{})
+	editor.WorkChan() <- basicWork{func() {
+		fn()
+		close(done)
+	}}
+	<-done
+}
+
+func editsResponse(edits []*fileEdit) (rsp apiEditsResponse) {
+	rsp.Files = make([]apiEditResult, len(edits))
+	for i, e := range edits {
+		rsp.Files[i].Path = e.group.Path
+		if e.err != nil {
+			rsp.Files[i].Error = e.err.err.Error()
+		}
+	}
+	return
+}
+
+// validateEditGroups checks that the edits in each group are sorted by offset and
+// don't overlap, and that no file is listed twice.
+func validateEditGroups(edits []*fileEdit) (ok bool) {
+	ok = true
+	paths := map[string]bool{}
+	for _, e := range edits {
+		if paths[e.group.Path] {
+			e.err = conflictError("file is listed more than once")
+			ok = false
+			continue
+		}
+		paths[e.group.Path] = true
+
+		sort.SliceStable(e.group.Edits, func(i, j int) bool {
+			return e.group.Edits[i].Offset < e.group.Edits[j].Offset
+		})
+
+		end := 0
+		for _, ed := range e.group.Edits {
+			if ed.Offset < 0 || ed.Length < 0 {
+				e.err = conflictError("edit at offset %d with length %d is invalid", ed.Offset, ed.Length)
+				ok = false
+				break
+			}
+			if ed.Offset < end {
+				e.err = conflictError("edit at offset %d overlaps the previous edit", ed.Offset)
+				ok = false
+				break
+			}
+			end = ed.Offset + ed.Length
+		}
+	}
+	return
+}
+
+// editsEnd returns the end offset of the last edit in the sorted edits.
+func editsEnd(edits []apiEdit) int {
+	if len(edits) == 0 {
+		return 0
+	}
+	l := edits[len(edits)-1]
+	return l.Offset + l.Length
+}
+
+// commitEditsToWindows applies the edits to the windows. The windows are checked again since the
+// user may have opened, closed or changed them while the temporary files were written. It must be
+// called from the main goroutine.
+func commitEditsToWindows(edits []*fileEdit) (ok bool) {
+	wins := make([]*Window, len(edits))
+	for i, e := range edits {
+		wins[i] = e.win
+	}
+	if !findAndValidateEditWindows(edits) {
+		return false
+	}
+	for i, e := range edits {
+		if e.win != wins[i] {
+			e.err = conflictError("file was opened or closed while it was being edited")
+			return false
+		}
+	}
+
+	for _, e := range edits {
+		if e.win != nil {
+			e.savedCursors = append([]int(nil), e.win.Body.CursorIndices...)
+			e.win.applyEdits(e.group.Edits)
+			e.appliedGeneration = e.win.Body.generation
+		}
+	}
+	return true
+}
+
+// findAndValidateEditWindows finds the windows for the files that are open and checks that
+// the edits can be applied to them. It must be called from the main goroutine.
+func findAndValidateEditWindows(edits []*fileEdit) (ok bool) {
+	ok = true
+	for _, e := range edits {
+		e.win, _ = editor.FindWindowForFile(e.group.Path)
+		if e.win == nil {
+			if e.group.Generation != nil {
+				e.err = conflictError("file is not open, so the generation can't be checked")
+				ok = false
+			}
+			continue
+		}
+
+		body := &e.win.Body.editable
+		if e.group.Generation != nil && *e.group.Generation != body.generation {
+			e.err = conflictError("window body is at generation %d, not %d", body.generation, *e.group.Generation)
+			ok = false
+			continue
+		}
+
+		if end := editsEnd(e.group.Edits); end > body.text.Len() {
+			e.err = conflictError("edit ends at offset %d which is past the end of the window body (length %d)", end, body.text.Len())
+			ok = false
+			continue
+		}
+
+		if body.writeLock.isLocked() {
+			e.err = conflictError("window body is locked")
+			ok = false
+			continue
+		}
+
+		if len(e.group.Edits) > 0 {
+			if reason := e.win.bodyModificationRefusedReason(); reason != "" {
+				e.err = conflictError("window body can't be changed: %s", reason)
+				ok = false
+				continue
+			}
+		}
+
+		for _, ed := range e.group.Edits {
+			if body.affectsImmutableRange(ed.Offset, ed.Offset+ed.Length) {
+				e.err = conflictError("edit at offset %d changes text that can't be modified", ed.Offset)
+				ok = false
+				break
+			}
+		}
+	}
+	return
+}
+
+// applyEdits applies the sorted, validated edits to the window body as one transaction.
+func (w *Window) applyEdits(edits []apiEdit) {
+	body := &w.Body.editable
+	body.SetSaveDeletes(false)
+	body.text.RunInTransaction(txOwnerApiEdits, func() {
+		// Apply the edits from last to first so that the offsets of the earlier edits are unchanged.
+		for i := len(edits) - 1; i >= 0; i-- {
+			ed := edits[i]
+			if ed.Length > 0 {
+				body.deleteFromPieceTableUndoIndex(ed.Offset, ed.Length, ed.Offset)
+			}
+			if ed.Text != "" {
+				body.insertToPieceTableUndoIndex(ed.Offset, ed.Text, ed.Offset)
+			}
+		}
+	})
+	body.SetSaveDeletes(true)
+}
+
+// rollBackEditsToWindows undoes the edits applied by commitEditsToWindows. A window whose body
+// changed since is left as it is, since then the last transaction is no longer the one holding
+// the edits. It must be called from the main goroutine.
+func rollBackEditsToWindows(edits []*fileEdit) {
+	for _, e := range edits {
+		if e.win == nil || len(e.group.Edits) == 0 {
+			continue
+		}
+		body := &e.win.Body.editable
+		if body.generation != e.appliedGeneration {
+			if e.err == nil {
+				e.err = conflictError("window body was changed after the edits were applied, so they were not undone")
+			}
+			continue
+		}
+		body.applyUndoOrRedo(body.text.Undo, -1)
+		if e.savedCursors != nil {
+			body.CursorIndices = e.savedCursors
+		}
+	}
+}
+
+// writeTemporaryFiles writes the edited contents of the files that aren't open in windows to
+// temporary files next to them.
+func writeTemporaryFiles(edits []*fileEdit) (ok bool) {
+	for _, e := range edits {
+		if e.win != nil {
+			continue
+		}
+		e.err = e.writeTemporaryFile()
+		if e.err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// renameTemporaryFiles renames the temporary files over the originals.
+func renameTemporaryFiles(edits []*fileEdit) (ok bool) {
+	for _, e := range edits {
+		if e.tmpPath == "" {
+			continue
+		}
+		err := e.fs.rename(e.tmpPath, e.group.Path)
+		if err != nil {
+			e.err = ioError(fmt.Errorf("renaming %s over the file failed: %w", e.tmpPath, err))
+			return false
+		}
+		e.renamed = true
+	}
+	return true
+}
+
+func (e *fileEdit) writeTemporaryFile() *editsError {
+	var err error
+	e.fs, err = GetFs(e.group.Path)
+	if err != nil {
+		return ioError(err)
+	}
+
+	e.original, err = e.fs.loadFile(e.group.Path)
+	if err != nil {
+		return ioError(fmt.Errorf("reading file failed: %w", err))
+	}
+
+	contents, err := applyEditsToBytes(e.original, e.group.Edits)
+	if err != nil {
+		return &editsError{err: err, conflict: true}
+	}
+
+	tmpPath := fmt.Sprintf("%s.anvil-edit-%d", e.group.Path, time.Now().UnixNano())
+	err = e.fs.saveFileLike(tmpPath, e.group.Path, contents)
+	if err != nil {
+		e.fs.remove(tmpPath)
+		return ioError(fmt.Errorf("writing temporary file failed: %w", err))
+	}
+	e.tmpPath = tmpPath
+	return nil
+}
+
+// rollBackEditsToDisk removes the temporary files that were not renamed, and restores the
+// original contents of the files that were.
+func rollBackEditsToDisk(edits []*fileEdit) {
+	for _, e := range edits {
+		if e.tmpPath == "" {
+			continue
+		}
+		if !e.renamed {
+			e.fs.remove(e.tmpPath)
+			continue
+		}
+
+		err := e.fs.saveFile(e.group.Path, e.original)
+		if err != nil && e.err == nil {
+			e.err = ioError(fmt.Errorf("restoring the original contents after another file failed also failed: %w", err))
+		}
+	}
+}
+
+// applyEditsToBytes applies the sorted edits, whose offsets are in runes, to b.
+func applyEditsToBytes(b []byte, edits []apiEdit) ([]byte, error) {
+	w := runes.NewWalker(b)
+	result := make([]byte, 0, len(b))
+	last := 0
+	for _, ed := range edits {
+		w.SetRunePos(ed.Offset)
+		if w.RunePos() < ed.Offset {
+			return nil, fmt.Errorf("edit at offset %d is past the end of the file", ed.Offset)
+		}
+		start := w.BytePos()
+		w.SetRunePos(ed.Offset + ed.Length)
+		if w.RunePos() < ed.Offset+ed.Length {
+			return nil, fmt.Errorf("edit at offset %d with length %d extends past the end of the file", ed.Offset, ed.Length)
+		}
+		end := w.BytePos()
+
+		result = append(result, b[last:start]...)
+		result = append(result, ed.Text...)
+		last = end
+	}
+	result = append(result, b[last:]...)
+	return result, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+func TestApplyEditsRenamesAcrossFiles(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	openPath := filepath.Join(dir, "open.go")
+	closedPath := filepath.Join(dir, "closed.go")
+
+	const openText = "func oldName() {}\n"
+	const closedText = "// héllo\nx := oldName()\ny := oldName()\n"
+	err := os.WriteFile(closedPath, []byte(closedText), 0644)
+	if err != nil {
+		t.Fatalf("writing file failed: %v", err)
+	}
+
+	var win *Window
+	var generation int
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag(openPath, typeFile)
+		win.Body.SetText([]byte(openText))
+		generation = win.Body.generation
+	})
+
+	rename := func(text string) []api.Edit {
+		var edits []api.Edit
+		runes := []rune(text)
+		for i := 0; i+len("oldName") <= len(runes); i++ {
+			if string(runes[i:i+len("oldName")]) == "oldName" {
+				edits = append(edits, api.Edit{Offset: i, Length: len("oldName"), Text: "newName"})
+			}
+		}
+		return edits
+	}
+
+	staleGeneration := generation - 1
+	results, err := anvil.ApplyEdits([]api.EditGroup{
+		{Path: openPath, Generation: &staleGeneration, Edits: rename(openText)},
+		{Path: closedPath, Edits: rename(closedText)},
+	})
+	if err == nil {
+		t.Fatalf("expected edits with a stale generation to fail")
+	}
+	if len(results) != 2 || results[0].Error == "" || results[1].Error != "" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+	assertFileContents(t, closedPath, closedText)
+
+	results, err = anvil.ApplyEdits([]api.EditGroup{
+		{Path: openPath, Generation: &generation, Edits: rename(openText)},
+		{Path: closedPath, Edits: rename(closedText)},
+	})
+	if err != nil {
+		t.Fatalf("applying edits failed: %v", err)
+	}
+
+	var body string
+	onMainGoroutine(func() {
+		body = win.Body.String()
+	})
+	if body != strings.ReplaceAll(openText, "oldName", "newName") {
+		t.Fatalf("unexpected window body %q", body)
+	}
+	assertFileContents(t, closedPath, strings.ReplaceAll(closedText, "oldName", "newName"))
+	assertFileContents(t, openPath, "")
+
+	// An edit past the end of the file on disk rolls back the edit to the window.
+	onMainGoroutine(func() {
+		generation = win.Body.generation
+	})
+	_, err = anvil.ApplyEdits([]api.EditGroup{
+		{Path: openPath, Generation: &generation, Edits: []api.Edit{{Offset: 0, Length: 4, Text: "fn"}}},
+		{Path: closedPath, Edits: []api.Edit{{Offset: 1000, Length: 1, Text: "z"}}},
+	})
+	if err == nil {
+		t.Fatalf("expected edits past the end of the file to fail")
+	}
+	onMainGoroutine(func() {
+		body = win.Body.String()
+	})
+	if body != strings.ReplaceAll(openText, "oldName", "newName") {
+		t.Fatalf("window body was not rolled back: %q", body)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading directory failed: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected temporary files to be removed, but directory contains %v", entries)
+	}
+}
+
+func assertFileContents(t *testing.T, path, expected string) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) && expected == "" {
+		return
+	}
+	if err != nil {
+		t.Fatalf("reading %s failed: %v", path, err)
+	}
+	if string(b) != expected {
+		t.Fatalf("expected %s to contain %q but it contains %q", path, expected, b)
+	}
+}
+
+func TestApplyEditsKeepsFileMode(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	path := filepath.Join(t.TempDir(), "script.sh")
+	err := os.WriteFile(path, []byte("echo old\n"), 0700)
+	if err != nil {
+		t.Fatalf("writing file failed: %v", err)
+	}
+	err = os.Chmod(path, 0751)
+	if err != nil {
+		t.Fatalf("changing the mode of the file failed: %v", err)
+	}
+
+	_, err = anvil.ApplyEdits([]api.EditGroup{
+		{Path: path, Edits: []api.Edit{{Offset: 5, Length: 3, Text: "new"}}},
+	})
+	if err != nil {
+		t.Fatalf("applying edits failed: %v", err)
+	}
+	assertFileContents(t, path, "echo new\n")
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("reading the mode of the file failed: %v", err)
+	}
+	if fi.Mode().Perm() != 0751 {
+		t.Fatalf("expected the file to keep mode 0751 but it has %o", fi.Mode().Perm())
+	}
+}
+
+func TestApplyEditsRefusesWindowsThatCantBeChanged(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	openPath := filepath.Join(dir, "open.txt")
+	closedPath := filepath.Join(dir, "closed.txt")
+	if err := os.WriteFile(closedPath, []byte("closed\n"), 0644); err != nil {
+		t.Fatalf("writing file failed: %v", err)
+	}
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag(openPath, typeFile)
+		win.Body.SetText([]byte("open\n"))
+		win.notWritable = true
+	})
+
+	results, err := anvil.ApplyEdits([]api.EditGroup{
+		{Path: openPath, Edits: []api.Edit{{Offset: 0, Length: 4, Text: "shut"}}},
+		{Path: closedPath, Edits: []api.Edit{{Offset: 0, Length: 6, Text: "opened"}}},
+	})
+	if err == nil {
+		t.Fatalf("expected edits to a window that is not writable to fail")
+	}
+	if len(results) != 2 || !strings.Contains(results[0].Error, "not writable") || results[1].Error != "" {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	var body string
+	onMainGoroutine(func() {
+		body = win.Body.String()
+	})
+	if body != "open\n" {
+		t.Fatalf("expected the window body not to change but it is %q", body)
+	}
+	assertFileContents(t, closedPath, "closed\n")
+}
+
+func TestRollingBackEditsLeavesWindowsChangedSince(t *testing.T) {
+	newTestEditor()
+
+	dir := t.TempDir()
+	keptPath := filepath.Join(dir, "kept.txt")
+	undonePath := filepath.Join(dir, "undone.txt")
+
+	kept := editor.NewWindow(nil)
+	kept.SetFilenameAndTag(keptPath, typeFile)
+	kept.Body.SetText([]byte("one\n"))
+	undone := editor.NewWindow(nil)
+	undone.SetFilenameAndTag(undonePath, typeFile)
+	undone.Body.SetText([]byte("two\n"))
+
+	edits := []*fileEdit{
+		{group: apiEditGroup{Path: keptPath, Edits: []apiEdit{{Offset: 0, Length: 3, Text: "ONE"}}}},
+		{group: apiEditGroup{Path: undonePath, Edits: []apiEdit{{Offset: 0, Length: 3, Text: "TWO"}}}},
+	}
+	if !findAndValidateEditWindows(edits) || !commitEditsToWindows(edits) {
+		t.Fatalf("applying the edits to the windows failed")
+	}
+
+	// The user types into one window before the rename fails.
+	kept.Body.insertToPieceTable(kept.Body.text.Len(), "typed")
+	rollBackEditsToWindows(edits)
+
+	if got := kept.Body.String(); got != "ONE\ntyped" {
+		t.Fatalf("expected the changed window to be left as it is but it is %q", got)
+	}
+	if edits[0].err == nil || !edits[0].err.conflict {
+		t.Fatalf("expected a conflict for the changed window but got %v", edits[0].err)
+	}
+	if got := undone.Body.String(); got != "two\n" {
+		t.Fatalf("expected the edits to be undone but the body is %q", got)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"github.com/jeffwilliams/anvil/internal/intvl"
 	"github.com/jeffwilliams/anvil/internal/pctbl"
 	"github.com/jeffwilliams/anvil/internal/runes"
@@ -28,14 +29,21 @@ type editableModel struct {
 	completer                  *words.Completer
 	// overridingCursorIndices specifies a list of cursor indices
 	// that override where cursors are displayed.
-	overridingCursorIndices  []int
-	wordCompletion           completion
-	fileCompletion           completion
-	manualHighlighting       []*SyntaxInterval
+	overridingCursorIndices []int
+	wordCompletion          completion
+	fileCompletion          completion
+	manualHighlighting      []*SyntaxInterval
+	// searchPips are the matches of the last search shown in the scrollbar.
+	searchPips               searchPipCache
 	runeOffsetCache          runes.OffsetCache
 	matchingBracketInsertion matchingBracketInsertion
 	writeLock                editableWriteLock
 	recentlyTypedText        textRange
+	// modificationGuard, if set, is called before the text is changed by inserting or deleting.
+	// If it returns false the change is not made.
+	modificationGuard func() bool
+	// modificationRefused is set when the modificationGuard refuses a change.
+	modificationRefused bool
 }
 
 func (e *editableModel) SetTextString(s string) {
@@ -69,6 +77,7 @@ func (e *editableModel) resetWhenAllTextReplaced() {
 	e.clearSelections()
 	e.CursorIndices = []int{0}
 	e.TopLeftIndex = 0
+	e.searchPips.invalidate()
 }
 
 func (e *editableModel) SetTextStringNoReset(s string) {
@@ -117,6 +126,17 @@ func (e *editableModel) firstNRunes(doc []byte, n int) (data []byte, runeCount i
 	return
 }
 
+// bytesBetweenRuneIndices returns the part of doc, which is the text of the editable, between
+// the rune offsets start and end.
+func (e *editableModel) bytesBetweenRuneIndices(doc []byte, start, end int) []byte {
+	startByte, err, _ := e.runeOffsetCache.Get(doc, start)
+	if err != nil {
+		log(LogCatgEd, "RuneOffsetCache.Get returned error: %v\n", err)
+	}
+	endByte, _, _ := e.runeOffsetCache.Get(doc, end)
+	return doc[startByte:endByte]
+}
+
 func (e *editableModel) textObjectForAcquireAt(runeIndex int) string {
 	return e.textObjectAt(runeIndex, false)
 }
@@ -211,15 +231,47 @@ func (e *editableModel) itemToComplete(w runes.Walker, runeIndex int, getBounds
 }
 
 func (e *editableModel) shiftItemsDueToTextModification(startOfChange, lengthOfChange int) {
+	if e.writeLock.isLocked() {
+		return
+	}
+	e.adapter.shiftEditorItemsDueToTextModification(startOfChange, lengthOfChange)
+	e.shiftViewItemsDueToTextModification(startOfChange, lengthOfChange)
+}
+
+// shiftViewItemsDueToTextModification shifts the items that belong to this view of the text,
+// but not the items kept by the editor for the file such as marks. Clones of a window share the
+// text but each has its own view of it, so when one clone changes the text the others only shift
+// their view items.
+func (e *editableModel) shiftViewItemsDueToTextModification(startOfChange, lengthOfChange int) {
 	if e.writeLock.isLocked() {
 		return
 	}
 	e.shiftSelectionsDueToTextModification(startOfChange, lengthOfChange)
 	e.shiftSyntaxTokensDueToTextModification(startOfChange, lengthOfChange)
 	e.shiftManualHighlightsDueToTextModification(startOfChange, lengthOfChange)
-	e.adapter.shiftEditorItemsDueToTextModification(startOfChange, lengthOfChange)
 	e.shiftCursorsDueToTextModification(startOfChange, lengthOfChange)
 	e.shiftCompletersDueToTextModification(startOfChange, lengthOfChange)
+	e.searchPips.shiftDueToTextModification(startOfChange, lengthOfChange)
+}
+
+// clampToTextLen moves the cursors, selections and top-left index that are past the end of the
+// text back inside it. It's used when the text shared with a clone was replaced.
+func (e *editableModel) clampToTextLen() {
+	l := e.text.Len()
+	for i, c := range e.CursorIndices {
+		if c > l {
+			e.CursorIndices[i] = l
+		}
+	}
+	if e.TopLeftIndex > l {
+		e.TopLeftIndex = 0
+	}
+	for _, s := range e.selections {
+		if s.End() > l {
+			e.clearSelections()
+			break
+		}
+	}
 }
 
 func (e *editableModel) shiftCursorsDueToTextModification(startOfChange, lengthOfChange int) {
@@ -334,7 +386,7 @@ func (e *editableModel) insertToPieceTableUndoIndex(index int, text string, undo
 	// We actually only care if the user is trying to _start_ typing or pasting somewhere inside
 	// the immutable range. If the paste would overlap the range but starts before it that is fine
 	// because we will shift the immutable range after it.
-	if e.affectsImmutableRange(index, index) {
+	if e.affectsImmutableRange(index, index) || !e.modificationAllowed() {
 		return
 	}
 
@@ -343,6 +395,32 @@ func (e *editableModel) insertToPieceTableUndoIndex(index int, text string, undo
 	e.shiftItemsDueToTextModification(index, l)
 }
 
+func (e *editableModel) modificationAllowed() bool {
+	if e.modificationGuard == nil || e.modificationGuard() {
+		return true
+	}
+	e.modificationRefused = true
+	return false
+}
+
+// restoreCursorsIfModificationRefused calls fn, which handles input from the user. If fn tried
+// to change the text and the modificationGuard refused the change, the cursors are put back
+// where they were since they may have been moved as if the text had changed.
+func (e *editableModel) restoreCursorsIfModificationRefused(fn func()) {
+	if e.modificationGuard == nil {
+		fn()
+		return
+	}
+
+	cursors := make([]int, len(e.CursorIndices))
+	copy(cursors, e.CursorIndices)
+	e.modificationRefused = false
+	fn()
+	if e.modificationRefused {
+		e.CursorIndices = cursors
+	}
+}
+
 func (e *editableModel) deleteFromPieceTable(index, length int) {
 	if e.writeLock.isLocked() {
 		return
@@ -354,7 +432,7 @@ func (e *editableModel) deleteFromPieceTableUndoIndex(index, length, undoIndex i
 	if e.writeLock.isLocked() {
 		return
 	}
-	if e.affectsImmutableRange(index, index+length) {
+	if e.affectsImmutableRange(index, index+length) || !e.modificationAllowed() {
 		return
 	}
 
@@ -500,12 +578,12 @@ func (e *editableModel) InsertBeforeAndAfterAllSelections(before, after string)
 	if e.writeLock.isLocked() {
 		return
 	}
-	e.text.StartTransaction()
-	for _, sel := range e.selections {
-		e.insertToPieceTable(sel.start, before)
-		e.insertToPieceTable(sel.end, after)
-	}
-	e.text.EndTransaction()
+	e.text.RunInTransaction(txOwnerMultiEdit, func() {
+		for _, sel := range e.selections {
+			e.insertToPieceTable(sel.start, before)
+			e.insertToPieceTable(sel.end, after)
+		}
+	})
 }
 
 func (e *editableModel) SetTopLeft(topLeft int) {
@@ -541,6 +619,14 @@ func (e *editableModel) moveCursorToStartOfPrimarySelection() {
 	}
 }
 
+// The owners of piece table transactions started by features whose changes must not be merged
+// with those of another feature. See pctbl.PieceTable.StartTransactionFor.
+const (
+	txOwnerMultiEdit        = "multi-cursor editing"
+	txOwnerBracketInsertion = "matching bracket insertion"
+	txOwnerApiEdits         = "API edits"
+)
+
 func (e *editableModel) StartTransaction() {
 	if e.writeLock.isLocked() {
 		return
@@ -615,6 +701,29 @@ func (e *editableModel) RotateSelections() {
 
 }
 
+// SwapSelections exchanges the text of the two selections as one change, leaving each selection
+// around the text it now holds. It returns an error unless there are exactly two selections that
+// don't overlap.
+func (e *editableModel) SwapSelections() error {
+	if len(e.selections) != 2 {
+		return fmt.Errorf("exactly two selections are needed but there are %d", len(e.selections))
+	}
+	a, b := e.selections[0], e.selections[1]
+	if a.Start() > b.Start() {
+		a, b = b, a
+	}
+	if a.End() > b.Start() {
+		return fmt.Errorf("the selections overlap")
+	}
+	if e.writeLock.isLocked() {
+		return nil
+	}
+
+	// With two selections, rotating them is swapping them.
+	e.RotateSelections()
+	return nil
+}
+
 func (e *editableModel) removeDuplicateCursors() {
 	if e.writeLock.isLocked() {
 		return
@@ -705,6 +814,90 @@ func (e *editable) InsertLozenge() {
 	}
 }
 
+// TransformSelectionsOrWords replaces the text of each selection with the result of calling
+// transform on it. If there are no selections, the word at each cursor is replaced instead,
+// where a word may contain letters, digits, underscores and hyphens. All the replacements
+// are undone together, and each cursor is left after its replaced text.
+func (e *editable) TransformSelectionsOrWords(transform func(string) string) {
+	if e.writeLock.isLocked() {
+		return
+	}
+
+	var ranges []textRange
+	sels := e.selectionsInDisplayOrder()
+	if len(sels) > 0 {
+		for _, s := range sels {
+			ranges = append(ranges, s.textRange)
+		}
+	} else {
+		w := runes.NewWalker(e.Bytes())
+		cursors := make([]int, len(e.CursorIndices))
+		copy(cursors, e.CursorIndices)
+		sort.Ints(cursors)
+		for _, c := range cursors {
+			w.SetRunePosCache(c, &e.runeOffsetCache)
+			r := NewTextRange(w.CurrentBounds(isTransformableWordRune))
+			if len(ranges) > 0 && ranges[len(ranges)-1] == r {
+				continue
+			}
+			ranges = append(ranges, r)
+		}
+	}
+
+	w := runes.NewWalker(e.Bytes())
+	replacements := make([]string, len(ranges))
+	for i, r := range ranges {
+		replacements[i] = string(w.TextBetweenRuneIndicesCache(r.start, r.end, &e.runeOffsetCache))
+		replacements[i] = transform(replacements[i])
+	}
+
+	e.replaceRanges(ranges, replacements, sels)
+}
+
+// replaceRanges replaces the text in each of the sorted, non-overlapping ranges with the
+// replacement at the same index in one transaction, and leaves a cursor after each replaced
+// text. Empty ranges are left as they are. If sels is not empty, sels[i] is set to the text
+// that replaced ranges[i].
+func (e *editable) replaceRanges(ranges []textRange, replacements []string, sels []*selection) {
+	// Replace from the end of the text backwards so that the ranges not yet replaced
+	// don't need to be shifted.
+	e.StartTransaction()
+	e.SetSaveDeletes(false)
+	for i := len(ranges) - 1; i >= 0; i-- {
+		r := ranges[i]
+		if r.Len() == 0 {
+			continue
+		}
+		e.deleteFromPieceTableUndoIndex(r.start, r.Len(), r.start)
+		e.insertToPieceTableUndoIndex(r.start, replacements[i], r.start)
+		if len(sels) > 0 {
+			sels[i].start = r.start
+			sels[i].end = r.start + utf8.RuneCountInString(replacements[i])
+		}
+	}
+	e.SetSaveDeletes(true)
+	e.EndTransaction()
+
+	cursors := make([]int, len(ranges))
+	shift := 0
+	for i, r := range ranges {
+		l := r.Len()
+		if l > 0 {
+			l = utf8.RuneCountInString(replacements[i])
+		}
+		cursors[i] = r.start + shift + l
+		shift += l - r.Len()
+	}
+	if len(cursors) > 0 {
+		e.CursorIndices = cursors
+		e.removeDuplicateCursors()
+	}
+}
+
+func isTransformableWordRune(rn rune) bool {
+	return unicode.IsLetter(rn) || unicode.IsDigit(rn) || unicode.IsMark(rn) || rn == '_' || rn == '-'
+}
+
 func (e *editableModel) Len() int {
 	return e.text.Len()
 }
@@ -719,20 +912,27 @@ func (e *editableModel) AddManualHighlightForEachSelection(color Color) {
 }
 
 func (e *editableModel) AddManualHighlight(start, end int, color Color) {
+	e.addManualHighlight(start, end, color)
+}
+
+// addManualHighlight adds the highlight and returns it so that it can later be removed using
+// RemoveManualHighlights. It returns nil if the highlight wasn't added.
+func (e *editableModel) addManualHighlight(start, end int, color Color) *SyntaxInterval {
 	if e.writeLock.isLocked() {
-		return
+		return nil
 	}
 	if end <= start {
-		return
+		return nil
 	}
 
 	s := NewSyntaxInterval(start, end, color)
 	for _, m := range e.manualHighlighting {
 		if intvl.Overlaps(s, m) {
-			return
+			return nil
 		}
 	}
 	e.manualHighlighting = append(e.manualHighlighting, s)
+	return s
 }
 
 func (e *editableModel) ClearManualHighlights() {
@@ -756,6 +956,15 @@ func (e *editableModel) ClearSelectedManualHighlights() {
 		}
 	}
 
+	e.RemoveManualHighlights(toRemove)
+}
+
+// RemoveManualHighlights removes the highlights returned by addManualHighlight that are still
+// present.
+func (e *editableModel) RemoveManualHighlights(toRemove []*SyntaxInterval) {
+	if e.writeLock.isLocked() {
+		return
+	}
 	if len(toRemove) == 0 {
 		return
 	}
@@ -918,8 +1127,23 @@ func (t readOnlyPieceTable) String() string {
 }
 func (t readOnlyPieceTable) StartTransaction() {
 }
+func (t readOnlyPieceTable) StartTransactionFor(owner string) {
+}
 func (t readOnlyPieceTable) EndTransaction() {
 }
+func (t readOnlyPieceTable) RunInTransaction(owner string, fn func()) {
+	fn()
+}
+func (t readOnlyPieceTable) TransactionDepth() int {
+	return 0
+}
+func (t readOnlyPieceTable) Check() (violations []string) {
+	return nil
+}
+func (t readOnlyPieceTable) StartOuterTransaction() {
+}
+func (t readOnlyPieceTable) EndOuterTransaction() {
+}
 func (t readOnlyPieceTable) TruncateLastInsert(countToRemove int) {
 }
 func (t readOnlyPieceTable) Undo() (undoData []interface{}) {
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"unicode"
+
+	"github.com/jeffwilliams/anvil/internal/textcase"
+)
+
+func TestTransformSelectionsOrWords(t *testing.T) {
+	upper := func(s string) string { return textcase.Upper(s, nil) }
+	snake := func(s string) string { return textcase.Snake(s, nil) }
+
+	tests := []struct {
+		name              string
+		text              string
+		selections        [][2]int
+		cursors           []int
+		transform         func(string) string
+		expected          string
+		expectedCursors   []int
+		expectedSelection [][2]int
+	}{
+		{
+			name:              "selections grow",
+			text:              "die straße und die maße",
+			selections:        [][2]int{{4, 10}, {19, 23}},
+			transform:         upper,
+			expected:          "die STRASSE und die MASSE",
+			expectedCursors:   []int{11, 25},
+			expectedSelection: [][2]int{{4, 11}, {20, 25}},
+		},
+		{
+			name:            "words at cursors",
+			text:            "x := parseHTTPResponse(userID2, ß-ok)",
+			cursors:         []int{8, 27, 33},
+			transform:       snake,
+			expected:        "x := parse_http_response(user_id2, ß_ok)",
+			expectedCursors: []int{24, 33, 39},
+		},
+		{
+			name:            "two cursors in the same word",
+			text:            "fooBar baz",
+			cursors:         []int{1, 4},
+			transform:       snake,
+			expected:        "foo_bar baz",
+			expectedCursors: []int{7},
+		},
+		{
+			name:            "cursor not in a word",
+			text:            "ab  cd",
+			cursors:         []int{3},
+			transform:       upper,
+			expected:        "ab  cd",
+			expectedCursors: []int{3},
+		},
+		{
+			name:            "turkish",
+			text:            "iı",
+			cursors:         []int{0},
+			transform:       func(s string) string { return textcase.Upper(s, unicode.TurkishCase) },
+			expected:        "İI",
+			expectedCursors: []int{2},
+		},
+	}
+
+	startHeadlessEditor(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var text string
+			var cursors []int
+			var sels [][2]int
+			var undone string
+			onMainGoroutine(func() {
+				win := editor.NewWindow(nil)
+				e := &win.Body.editable
+				e.SetText([]byte(tc.text))
+				if tc.cursors != nil {
+					e.CursorIndices = tc.cursors
+				}
+				for _, s := range tc.selections {
+					e.AddSelection(s[0], s[1])
+				}
+
+				e.TransformSelectionsOrWords(tc.transform)
+				text = string(e.Bytes())
+				cursors = append(cursors, e.CursorIndices...)
+				for _, s := range e.selectionsInDisplayOrder() {
+					sels = append(sels, [2]int{s.start, s.end})
+				}
+
+				e.applyUndoOrRedo(e.text.Undo, -1)
+				undone = string(e.Bytes())
+			})
+
+			if text != tc.expected {
+				t.Fatalf("expected text %q but got %q", tc.expected, text)
+			}
+			if !reflect.DeepEqual(cursors, tc.expectedCursors) {
+				t.Fatalf("expected cursors %v but got %v", tc.expectedCursors, cursors)
+			}
+			if !reflect.DeepEqual(sels, tc.expectedSelection) {
+				t.Fatalf("expected selections %v but got %v", tc.expectedSelection, sels)
+			}
+			if text != tc.text && undone != tc.text {
+				t.Fatalf("expected one undo to restore %q but got %q", tc.text, undone)
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/jeffwilliams/anvil/internal/textenc"
+)
+
+// SetEncoding sets the encoding that the file in the window and its clones is written in by Put
+// and read in by Get, in place of the one detected when the file was loaded.
+func (w *Window) SetEncoding(enc textenc.Encoding) {
+	w.encoding, w.encodingSet = enc, true
+	for c := range w.clones {
+		c.encoding, c.encodingSet = enc, true
+	}
+}
+
+func (c CommandExecutor) CmdEnc(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Enc: must be executed in a window")
+		return
+	}
+
+	if w.file == "" || w.fileType != typeFile {
+		editor.AppendError("", "Enc: the window doesn't hold a file")
+		return
+	}
+
+	if len(ctx.Args) == 0 {
+		how := "detected"
+		if w.encodingSet {
+			how = "set with Enc"
+		}
+		editor.AppendError("", fmt.Sprintf("%s: %s (%s)", w.file, w.encoding, how))
+		return
+	}
+
+	enc, err := textenc.Parse(w.encoding, ctx.Args)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Enc: %v", err))
+		return
+	}
+	w.SetEncoding(enc)
+	editor.AppendError("", fmt.Sprintf("%s: the encoding is now %s. Put writes the file in it and Get reloads the file from it.", w.file, enc))
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jeffwilliams/anvil/internal/textenc"
+)
+
+func TestPutPreservesEncoding(t *testing.T) {
+	startHeadlessEditor(t)
+
+	utf16CRLF := textenc.Encoding{Charset: textenc.UTF16LE, BOM: true, CRLF: true}
+	path := filepath.Join(t.TempDir(), "crlf.txt")
+	if err := os.WriteFile(path, textenc.Encode([]byte("one\ntwo é\n"), utf16CRLF), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.LoadFile(path)
+	})
+	waitForBody(t, win, "one\ntwo é\n")
+
+	waitForFile := func(expected []byte) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			b, _ := os.ReadFile(path)
+			if bytes.Equal(b, expected) {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the file to hold %q but it holds %q", expected, b)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	onMainGoroutine(func() {
+		if win.encoding != utf16CRLF {
+			t.Fatalf("expected the encoding %s to be detected but it is %s", utf16CRLF, win.encoding)
+		}
+		win.Body.insertToPieceTable(0, "zero\n")
+		win.Put()
+	})
+	waitForFile(textenc.Encode([]byte("zero\none\ntwo é\n"), utf16CRLF))
+
+	onMainGoroutine(func() {
+		NewCommandExecutor(win).Do("Enc utf-8 lf", &CmdContext{})
+		NewCommandExecutor(win).Do("Enc", &CmdContext{})
+		errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		if errs == nil || !strings.Contains(errs.Body.String(), path+": utf-8 lf (set with Enc)") {
+			t.Fatalf("expected Enc to show the encoding it set")
+		}
+		win.Put()
+	})
+	waitForFile([]byte("zero\none\ntwo é\n"))
+}
+
+func TestPutKeepsMixedLineEndingsAfterDetectSize(t *testing.T) {
+	startHeadlessEditor(t)
+
+	// The start of the file is all CRLF, but a line after it ends with just a newline.
+	file := append(bytes.Repeat([]byte("a crlf line\r\n"), 400), "an lf line\nthe end\r\n"...)
+	path := filepath.Join(t.TempDir(), "mixed.txt")
+	if err := os.WriteFile(path, file, 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.LoadFile(path)
+	})
+	waitForBody(t, win, string(file))
+
+	onMainGoroutine(func() {
+		if win.encoding.CRLF {
+			t.Fatalf("expected the encoding to fall back to lf line endings but it is %s", win.encoding)
+		}
+		win.Put()
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := os.ReadFile(path)
+		if bytes.Equal(b, file) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected Put to write the %d bytes of the file back unchanged but it wrote %d", len(file), len(b))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
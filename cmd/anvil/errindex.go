@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+)
+
+// defaultErrorPatterns are the regular expressions used to find diagnostics in +Errors windows
+// when the error-patterns setting is empty. The first matches the path:line:col: message form
+// used by most compilers, linters and by Lookall, and the second the lines of Python tracebacks.
+var defaultErrorPatterns = []string{
+	`^\s*(?P<file>[^\s:]+):(?P<line>\d+)(?::(?P<col>\d+))?(?::|$)(?:\s*(?P<severity>error|warning|note|info)\b)?`,
+	`^\s*File "(?P<file>[^"]+)", line (?P<line>\d+)`,
+}
+
+// errorEntry is a diagnostic found in an +Errors window: a line that names a position in a file.
+type errorEntry struct {
+	// start is the rune offset of the line in the body, and end that of the newline ending it.
+	start, end int
+	path       string
+	line, col  int
+	severity   string
+}
+
+// errorIndex holds the diagnostics found in the body of an +Errors window, in the order they
+// appear. It is kept up to date as the body is edited: the entries after a change are moved and
+// the lines that were changed are scanned again, as are the lines appended to the body, when the
+// index is next used.
+type errorIndex struct {
+	entries []errorEntry
+	// current is the rune offset of the start of the entry last visited, or -1 if there is none.
+	current int
+	// scannedTo is the rune offset of the start of the first line that hasn't been scanned.
+	scannedTo int
+	// stale are the ranges of the scanned lines that were changed and must be scanned again.
+	stale []textRange
+	// generation is the generation of the body that the index is up to date with.
+	generation int
+	// highlight is the manual highlight of the current entry in the body.
+	highlight *SyntaxInterval
+}
+
+func newErrorIndex(generation int) *errorIndex {
+	return &errorIndex{current: -1, generation: generation}
+}
+
+// errorPatterns compiles the error-patterns setting, or the default patterns if it is empty.
+// Patterns that don't compile or have no file or line group are logged and skipped.
+func errorPatterns() []*regexp.Regexp {
+	pats := settings.General.ErrorPatterns
+	if len(pats) == 0 {
+		pats = defaultErrorPatterns
+	}
+
+	var res []*regexp.Regexp
+	for _, p := range pats {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			log(LogCatgApp, "Invalid regular expression '%s' in error-patterns setting: %v\n", p, err)
+			continue
+		}
+		if re.SubexpIndex("file") < 0 || re.SubexpIndex("line") < 0 {
+			log(LogCatgApp, "The regular expression '%s' in error-patterns setting has no file or line group\n", p)
+			continue
+		}
+		res = append(res, re)
+	}
+	return res
+}
+
+// parseErrorLine returns the diagnostic on the line if one of the patterns matches it.
+func parseErrorLine(pats []*regexp.Regexp, line string) (e errorEntry, ok bool) {
+	for _, re := range pats {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		group := func(name string) string {
+			i := re.SubexpIndex(name)
+			if i < 0 {
+				return ""
+			}
+			return m[i]
+		}
+
+		e.path = group("file")
+		n, err := strconv.Atoi(group("line"))
+		if e.path == "" || err != nil {
+			continue
+		}
+		e.line = n
+		e.col, _ = strconv.Atoi(group("col"))
+		e.severity = strings.ToLower(group("severity"))
+		if e.severity == "" {
+			e.severity = "error"
+		}
+		return e, true
+	}
+	return
+}
+
+// scan brings the entries up to date with text: the lines that were changed since the last scan
+// are scanned again and the complete lines after scannedTo are scanned.
+func (x *errorIndex) scan(text []byte) {
+	pats := errorPatterns()
+	bscanned := byteOffsetOfRune(text, x.scannedTo)
+
+	for _, r := range x.stale {
+		if r.start >= x.scannedTo {
+			continue
+		}
+		start, bstart := startOfLine(text, r.start, byteOffsetOfRune(text, r.start))
+		bend := byteOffsetOfRune(text, min(r.end, x.scannedTo))
+		if i := bytes.IndexByte(text[bend:bscanned], '\n'); i >= 0 {
+			bend += i + 1
+		} else {
+			bend = bscanned
+		}
+		x.replaceEntries(start, start+utf8.RuneCount(text[bstart:bend]), parseErrorLines(pats, text[bstart:bend], start))
+	}
+	x.stale = nil
+
+	// An edit may have joined the first line to be scanned with the last one that was scanned.
+	start, bstart := startOfLine(text, x.scannedTo, bscanned)
+	end := bytes.LastIndexByte(text, '\n') + 1
+	if end < bstart {
+		end = bstart
+	}
+	x.replaceEntries(start, math.MaxInt, parseErrorLines(pats, text[bstart:end], start))
+	x.scannedTo = start + utf8.RuneCount(text[bstart:end])
+}
+
+// startOfLine returns the rune and byte offsets of the start of the line holding the rune at
+// the rune offset start, whose byte offset is bstart.
+func startOfLine(text []byte, start, bstart int) (int, int) {
+	for bstart > 0 && text[bstart-1] != '\n' {
+		_, sz := utf8.DecodeLastRune(text[:bstart])
+		bstart -= sz
+		start--
+	}
+	return start, bstart
+}
+
+// parseErrorLines returns the entries for the complete lines in text, which starts at the rune
+// offset start in the body.
+func parseErrorLines(pats []*regexp.Regexp, text []byte, start int) (entries []errorEntry) {
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n')
+		if i < 0 {
+			break
+		}
+		n := utf8.RuneCount(text[:i])
+		if e, ok := parseErrorLine(pats, string(text[:i])); ok {
+			e.start, e.end = start, start+n
+			entries = append(entries, e)
+		}
+		start += n + 1
+		text = text[i+1:]
+	}
+	return
+}
+
+// replaceEntries replaces the entries that start in the rune range [start, end) with entries.
+func (x *errorIndex) replaceEntries(start, end int, entries []errorEntry) {
+	lo := sort.Search(len(x.entries), func(i int) bool { return x.entries[i].start >= start })
+	hi := sort.Search(len(x.entries), func(i int) bool { return x.entries[i].start >= end })
+	x.entries = append(x.entries[:lo], append(entries, x.entries[hi:]...)...)
+}
+
+// shift updates the index for an insert or delete in the body. The entries after the change are
+// moved, and the entries on lines that were changed are removed until the lines are scanned again.
+func (x *errorIndex) shift(offset, length int) {
+	if offset >= x.scannedTo {
+		return
+	}
+
+	changeEnd := offset
+	if length < 0 {
+		changeEnd = offset - length
+	}
+	move := func(p int) int {
+		switch {
+		case p <= offset:
+			return p
+		case p < changeEnd:
+			return offset
+		}
+		return p + length
+	}
+
+	kept := x.entries[:0]
+	for _, e := range x.entries {
+		if e.start <= changeEnd && offset <= e.end {
+			continue
+		}
+		if e.start > offset {
+			e.start += length
+			e.end += length
+		}
+		kept = append(kept, e)
+	}
+	x.entries = kept
+
+	for i := range x.stale {
+		x.stale[i] = textRange{move(x.stale[i].start), move(x.stale[i].end)}
+	}
+	x.stale = append(x.stale, textRange{offset, offset + max(length, 0)})
+	if x.current > offset {
+		x.current = move(x.current)
+	}
+	x.scannedTo = move(x.scannedTo)
+}
+
+// next returns the index of the entry after (dir 1) or before (dir -1) the current position that
+// has the severity, or any severity if it is empty, wrapping around at the ends.
+func (x *errorIndex) next(dir int, severity string) (int, bool) {
+	n := len(x.entries)
+	if n == 0 {
+		return 0, false
+	}
+
+	// i is the first entry after the current position, or the last one before it.
+	var i int
+	if dir > 0 {
+		i = sort.Search(n, func(i int) bool { return x.entries[i].start > x.current })
+	} else {
+		i = n - 1
+		if x.current >= 0 {
+			i = sort.Search(n, func(i int) bool { return x.entries[i].start >= x.current }) - 1
+		}
+	}
+
+	for range x.entries {
+		i = (i%n + n) % n
+		if severity == "" || x.entries[i].severity == severity {
+			return i, true
+		}
+		i += dir
+	}
+	return 0, false
+}
+
+// errorIndexTextChanged keeps the error index of the window in step with changes to the body.
+// A change that may have replaced all the text leaves the index out of date, so that it is
+// rebuilt when it is next used.
+func (w *Window) errorIndexTextChanged(ch *TextChange) {
+	x := w.errIndex
+	if x == nil || x.generation != w.Body.generation-1 || ch.IsZero() {
+		return
+	}
+	x.shift(ch.Offset, ch.Length)
+	x.generation = w.Body.generation
+}
+
+// appendedToErrorIndex is called after text was appended to the body. The index is still up to
+// date since the new lines are scanned when the index is next used.
+func (w *Window) appendedToErrorIndex(generationBeforeAppend int) {
+	if x := w.errIndex; x != nil && x.generation == generationBeforeAppend {
+		x.generation = w.Body.generation
+	}
+}
+
+// errorIndex returns the index of the diagnostics in the body, rebuilding it if it is absent or
+// out of date and scanning the lines added since it was last used.
+func (w *Window) errorIndex() *errorIndex {
+	if w.errIndex == nil || w.errIndex.generation != w.Body.generation {
+		if w.errIndex != nil {
+			w.Body.RemoveManualHighlights([]*SyntaxInterval{w.errIndex.highlight})
+		}
+		w.errIndex = newErrorIndex(w.Body.generation)
+	}
+	w.errIndex.scan(w.Body.Bytes())
+	return w.errIndex
+}
+
+// GoToError moves to the next (dir 1) or previous (dir -1) diagnostic in the +Errors window
+// that has the severity, or any severity if it is empty. The file it names is shown with the
+// position selected, and the diagnostic is highlighted in the +Errors window.
+func (w *Window) GoToError(gtx layout.Context, dir int, severity string) error {
+	x := w.errorIndex()
+	i, ok := x.next(dir, severity)
+	if !ok {
+		if severity != "" {
+			return fmt.Errorf("there are no diagnostics with severity %s", severity)
+		}
+		return fmt.Errorf("there are no diagnostics")
+	}
+	e := x.entries[i]
+	x.current = e.start
+
+	w.Body.RemoveManualHighlights([]*SyntaxInterval{x.highlight})
+	x.highlight = w.Body.addManualHighlight(e.start, e.end, WindowStyle.Syntax.KeywordColor)
+	w.Body.AddOpForNextLayout(func(gtx layout.Context) {
+		w.Body.moveCursorTo(gtx, seek{seekType: seekToRunePos, runePos: e.start}, dontSelectText)
+	})
+
+	realpath, _, err := NewFileFinder(w).Find(e.path)
+	if err != nil {
+		return err
+	}
+
+	opts := LoadFileOpts{
+		GoTo:              seek{seekType: seekToLineAndCol, line: e.line, col: e.col},
+		SelectBehaviour:   selectText,
+		GrowBodyBehaviour: dontGrowBodyIfTooSmall,
+	}
+	src := editor.LoadFileOpts(realpath.String(), opts)
+	if src != nil {
+		src.SetFocus(gtx)
+		src.warpPointerToBody()
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestErrorIndexScanAndShift(t *testing.T) {
+	x := newErrorIndex(0)
+	text := "building\nmain.go:3:5: undefined: x\n  lib/a.go:10: warning: unused\nFile \"t.py\", line 7, in f\nmain.go:4"
+	x.scan([]byte(text))
+
+	expected := []errorEntry{
+		{start: 9, end: 34, path: "main.go", line: 3, col: 5, severity: "error"},
+		{start: 35, end: 65, path: "lib/a.go", line: 10, severity: "warning"},
+		{start: 66, end: 91, path: "t.py", line: 7, severity: "error"},
+	}
+	check := func(expected []errorEntry) {
+		t.Helper()
+		if len(x.entries) != len(expected) {
+			t.Fatalf("expected %v but got %v", expected, x.entries)
+		}
+		for i := range expected {
+			if x.entries[i] != expected[i] {
+				t.Fatalf("expected %v but got %v", expected, x.entries)
+			}
+		}
+	}
+	check(expected)
+
+	// The last line isn't complete, so it is scanned once it is.
+	text += "\n"
+	x.scan([]byte(text))
+	expected = append(expected, errorEntry{start: 92, end: 101, path: "main.go", line: 4, severity: "error"})
+	check(expected)
+
+	if i, ok := x.next(1, "warning"); !ok || i != 1 {
+		t.Fatalf("expected the first warning to be entry 1 but got %d, %v", i, ok)
+	}
+	if i, ok := x.next(-1, ""); !ok || i != 3 {
+		t.Fatalf("expected the previous entry to wrap around to entry 3 but got %d, %v", i, ok)
+	}
+	x.current = expected[3].start
+	if i, ok := x.next(1, ""); !ok || i != 0 {
+		t.Fatalf("expected the next entry to wrap around to entry 0 but got %d, %v", i, ok)
+	}
+
+	// Inserting a line before the entries moves them.
+	text = "go build\n" + text
+	x.shift(0, 9)
+	for i := range expected {
+		expected[i].start += 9
+		expected[i].end += 9
+	}
+	x.scan([]byte(text))
+	check(expected)
+	if x.current != expected[3].start {
+		t.Fatalf("expected the current entry to move to %d but it is at %d", expected[3].start, x.current)
+	}
+
+	// Changing the line of an entry scans it again.
+	text = strings.Replace(text, "lib/a.go:10", "lib/b.go:12", 1)
+	x.shift(expected[1].start+6, -5)
+	x.shift(expected[1].start+6, 5)
+	x.scan([]byte(text))
+	expected[1].path, expected[1].line = "lib/b.go", 12
+	check(expected)
+
+	// Deleting the line of an entry removes it and moves the ones after it.
+	n := expected[1].end - expected[1].start + 1
+	text = text[:expected[1].start] + text[expected[1].end+1:]
+	x.shift(expected[1].start, -n)
+	x.scan([]byte(text))
+	expected = append(expected[:1], expected[2:]...)
+	for i := 1; i < len(expected); i++ {
+		expected[i].start -= n
+		expected[i].end -= n
+	}
+	check(expected)
+}
+
+func TestNexterrGoesToDiagnostics(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	for _, n := range []string{"a.go", "b.go"} {
+		if err := os.WriteFile(filepath.Join(dir, n), []byte("one\ntwo\nthree\n"), 0644); err != nil {
+			t.Fatalf("writing the file failed: %v", err)
+		}
+	}
+
+	var errs, src *Window
+	var current int
+	var highlighted bool
+	onMainGoroutine(func() {
+		editor.AppendError(dir, "a.go:2:1: expected x\nok\nb.go:3: warning: unused\n")
+		errs, _ = editor.FindWindowForFile(editor.ErrorsFileNameOf(dir))
+		NewCommandExecutor(errs).Do("Nexterr warning", &CmdContext{})
+		src, _ = editor.FindWindowForFile(filepath.Join(dir, "b.go"))
+		current = errs.errIndex.current
+		h := errs.errIndex.highlight
+		highlighted = h != nil && h.Start() == 24 && h.End() == 47
+	})
+
+	if src == nil {
+		t.Fatalf("expected b.go to be opened")
+	}
+	if current != 1 || !highlighted {
+		t.Fatalf("expected the warning to be the current entry and highlighted but the current entry is %d", current)
+	}
+
+	onMainGoroutine(func() {
+		errs.Body.insertToPieceTable(0, "go build\n")
+		NewCommandExecutor(errs).Do("Preverr", &CmdContext{})
+		src, _ = editor.FindWindowForFile(filepath.Join(dir, "a.go"))
+		current = errs.errIndex.current
+		h := errs.errIndex.highlight
+		highlighted = h != nil && h.Start() == 9 && h.End() == 29
+	})
+
+	if src == nil {
+		t.Fatalf("expected a.go to be opened")
+	}
+	if current != 0 || !highlighted {
+		t.Fatalf("expected the first entry to be current and highlighted after the edit but the current entry is %d", current)
+	}
+
+	var n int
+	onMainGoroutine(func() {
+		NewCommandExecutor(errs).Do("Clr", &CmdContext{Editable: &errs.Body.editable})
+		editor.AppendError(dir, "b.go:1:2: again\n")
+		n = len(errs.errorIndex().entries)
+	})
+	if n != 1 {
+		t.Fatalf("expected the index to be rebuilt after Clr with 1 entry but it has %d", n)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+
+	"github.com/jeffwilliams/anvil/internal/typeset"
+	"golang.org/x/image/math/fixed"
+)
+
+// elasticTabsContextLines is how many lines before and after the layed out text are scanned to
+// find the widths of the cells that elastic tab stops line up. Columns that continue further
+// than this may not line up with the part that is out of view, but it keeps the time taken to
+// lay out the text bounded in large files.
+const elasticTabsContextLines = 200
+
+// SetElasticTabs turns elastic tab stops on or off. With elastic tab stops the tab stops of
+// adjacent lines are placed so that the text between tabs lines up in columns as wide as the
+// widest cell, rather than at a fixed interval.
+func (e *editable) SetElasticTabs(b bool) {
+	e.elasticTabs = b
+	e.invalidateLayedoutText()
+}
+
+// elasticTabStops returns the resolver of the elastic tab stops for laying out lines lines of
+// doc, the text of the editable, starting at the byte offset start, which may be in the middle
+// of a line. It returns nil if elastic tab stops are off.
+//
+// The layed out text is built again from all the visible lines whenever it is invalidated, so a
+// change to the width of one cell moves the tabs of the other visible lines in its column block.
+func (e *editable) elasticTabStops(doc []byte, start, lines int, constraints typeset.Constraints) typeset.TabStopResolver {
+	if !e.elasticTabs || e.displaySubst != nil {
+		return nil
+	}
+
+	lineStart := bytes.LastIndexByte(doc[:start], '\n') + 1
+
+	from, first := lineStart, 0
+	for from > 0 && first < elasticTabsContextLines {
+		from = bytes.LastIndexByte(doc[:from-1], '\n') + 1
+		first++
+	}
+
+	to := lineStart
+	for i := 0; i < lines+elasticTabsContextLines && to < len(doc); i++ {
+		n := bytes.IndexByte(doc[to:], '\n')
+		if n < 0 {
+			to = len(doc)
+			break
+		}
+		to += n + 1
+	}
+
+	resolve := typeset.ElasticTabStops(doc[from:to], first, constraints)
+	if lineStart == start {
+		return resolve
+	}
+	// The first line to be layed out is the rest of a wrapped line, which doesn't use the stops.
+	return func(line, cell int) (stop fixed.Int26_6, ok bool) {
+		if line == 0 {
+			return
+		}
+		return resolve(line, cell)
+	}
+}
+
+// elasticTabStopsForVisibleText returns the resolver of the elastic tab stops for laying out
+// the visible text, which has lines lines, or nil if elastic tab stops are off.
+func (e *editable) elasticTabStopsForVisibleText(lines int, constraints typeset.Constraints) typeset.TabStopResolver {
+	if !e.elasticTabs || e.displaySubst != nil {
+		return nil
+	}
+
+	doc := e.Bytes()
+	start, err, _ := e.runeOffsetCache.Get(doc, e.TopLeftIndex)
+	if err != nil {
+		log(LogCatgEd, "RuneOffsetCache.Get returned error: %v\n", err)
+		return nil
+	}
+	return e.elasticTabStops(doc, start, lines, constraints)
+}
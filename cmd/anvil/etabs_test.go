@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jeffwilliams/anvil/internal/typeset"
+	"golang.org/x/image/math/fixed"
+)
+
+// xOfRuneInLine returns the position of the rune at index i of the layed out line.
+func xOfRuneInLine(l typeset.Line, i int) (x fixed.Int26_6) {
+	for _, g := range l.Glyphs()[:i] {
+		x += g.Advance
+	}
+	return
+}
+
+func TestElasticTabStopsLineUpColumns(t *testing.T) {
+	constraints := typeset.Constraints{
+		FontFace:        VariableFont,
+		FontFaceId:      "blah",
+		FontSize:        14,
+		TabStopInterval: 8,
+		MaxHeight:       -1,
+	}
+
+	// The first line is only used for computing the tab stops, as if it were above the window.
+	// It has the widest first cell of the column block.
+	text := []byte("a much wider first cell than the others have\tx\nab\tc\td\nabcdefghijklmnopqrstuvw\tc\td\n\nab\tc\n")
+	start := bytes.IndexByte(text, '\n') + 1
+	constraints.TabStops = typeset.ElasticTabStops(text, 1, constraints)
+
+	lt, errs := typeset.Layout(text[start:], constraints)
+	if len(errs) > 0 {
+		t.Fatalf("layout failed: %v", errs)
+	}
+	lines := lt.Lines()
+	if len(lines) < 4 {
+		t.Fatalf("expected at least 4 lines but got %d", len(lines))
+	}
+
+	// Index 3 is the first rune after the first tab of "ab\tc\td", and 24 of the long line.
+	x0, x1 := xOfRuneInLine(lines[0], 3), xOfRuneInLine(lines[1], 24)
+	if x0 != x1 {
+		t.Fatalf("expected the second cells of adjacent lines to line up but they are at %v and %v", x0, x1)
+	}
+	if xOfRuneInLine(lines[0], 5) != xOfRuneInLine(lines[1], 26) {
+		t.Fatalf("expected the third cells of adjacent lines to line up")
+	}
+
+	// The line above the window is in the same column block, and the empty line ends the block.
+	above := typeset.ElasticTabStops(text, 0, constraints)
+	if stop, ok := above(0, 0); !ok || stop != x0 {
+		t.Fatalf("expected the line above the window to share the tab stop at %v but got %v", x0, stop)
+	}
+	if x := xOfRuneInLine(lines[3], 3); x >= x0 {
+		t.Fatalf("expected the line after the empty line to be in a column block of its own but its tab stop is at %v", x)
+	}
+}
+
+func TestEtabsCommand(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var win *Window
+	var states []bool
+	var resolverAfterOff bool
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.Body.SetText([]byte("a\tb\nlonger\tc\n"))
+		for _, args := range []string{"Etabs", "Etabs", "Etabs on", "Etabs off"} {
+			NewCommandExecutor(win).Do(args, &CmdContext{})
+			states = append(states, win.Body.elasticTabs)
+		}
+		resolverAfterOff = win.Body.elasticTabStops(win.Body.Bytes(), 0, 2, typeset.Constraints{}) != nil
+	})
+
+	expected := []bool{true, false, true, false}
+	for i := range expected {
+		if states[i] != expected[i] {
+			t.Fatalf("expected the elastic tab stops to be %v after each command but they were %v", expected, states)
+		}
+	}
+	if resolverAfterOff {
+		t.Fatalf("expected no tab stop resolver when elastic tab stops are off")
+	}
+}
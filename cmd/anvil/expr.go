@@ -311,8 +311,10 @@ func (ex *EditableExprExecutor) runInterpreterAsync(initialRanges []expr.Range)
 		editor.WorkChan() <- basicWork{func() {
 			ex.editable.writeLock.unlock()
 			ex.editable.SetSaveDeletes(true)
+			// End the transaction only once the write lock is released, since it is ignored while
+			// the lock is held.
+			ex.editable.EndTransaction()
 		}}
-		ex.editable.EndTransaction()
 		finished <- struct{}{}
 		if err != nil {
 			editor.AppendError(ex.dir, err.Error())
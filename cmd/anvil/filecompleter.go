@@ -14,14 +14,7 @@ import (
 // append to the word in the editable.
 //func FilenameCompletionsAsync(ed *editable, word, dir, base string, wordEndIndex int) error {
 func FilenameCompletionsAsync(word, dir, base string, callback CompletionsCallback) error {
-	var ldr FileLoader
-	load, err := ldr.LoadAsync(dir)
-	if err != nil {
-		return err
-	}
-
 	j := FilenameCompletionJob{
-		load:     load,
 		dir:      dir,
 		word:     word,
 		base:     base,
@@ -29,6 +22,22 @@ func FilenameCompletionsAsync(word, dir, base string, callback CompletionsCallba
 		callback: callback,
 	}
 
+	// If the directory is in a project that has been indexed, and hasn't changed since, the
+	// names can be taken from the index instead of listing the directory again.
+	if idx := fileIndexes.containing(dir); idx != nil {
+		if names, ok := idx.entriesOf(dir); ok {
+			go j.complete(names)
+			return nil
+		}
+	}
+
+	var ldr FileLoader
+	load, err := ldr.LoadAsync(dir)
+	if err != nil {
+		return err
+	}
+	j.load = load
+
 	editor.AddJob(&j)
 	go j.run()
 	return nil
@@ -84,7 +93,9 @@ FOR:
 				break
 			}
 			log(LogCatgCompletion, "FilenameCompletionJob: got more filenames\n")
-			fnames = append(fnames, x...)
+			for _, e := range x {
+				fnames = append(fnames, e.Name)
+			}
 
 		case x, ok := <-j.load.Errs:
 			if !ok {
@@ -106,6 +117,10 @@ FOR:
 		return
 	}
 
+	j.complete(fnames)
+}
+
+func (j *FilenameCompletionJob) complete(fnames []string) {
 	fnames = j.filesStartingWithBase(fnames)
 	j.stripBase(fnames)
 	j.prependWord(fnames)
@@ -171,6 +186,10 @@ func (l applyFilenameCompletionsToEditable) Job() Job {
 	return l.job
 }
 
+func (l applyFilenameCompletionsToEditable) priority() workPriority {
+	return workPriorityInteractive
+}
+
 type appendError struct {
 	job Job
 	dir string
@@ -0,0 +1,733 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// fileIndexLocalRefreshInterval is how old the index of a local project may be before it
+	// is refreshed when it is used. Refreshing only lists the directories that changed.
+	fileIndexLocalRefreshInterval = 10 * time.Second
+	// fileIndexRemoteRefreshInterval is how old the index of a remote project may be before it
+	// is refreshed. Remote projects are listed in full over ssh, so this is longer.
+	fileIndexRemoteRefreshInterval = 5 * time.Minute
+)
+
+var errFileIndexKilled = fmt.Errorf("indexing was killed")
+
+// fileIndexes holds the indexes of the projects that have been searched. It is only used from the
+// main goroutine.
+var fileIndexes fileIndexRegistry
+
+type fileIndexRegistry struct {
+	indexes []*fileIndex
+}
+
+// forDir returns the index for the project containing dir, creating it if needed. The index is
+// refreshed in the background if it is out of date, so it might not be ready yet.
+func (r *fileIndexRegistry) forDir(dir string) *fileIndex {
+	idx := r.containing(dir)
+	if idx == nil {
+		remote, err := pathIsRemote(dir)
+		if err != nil {
+			remote = false
+		}
+		idx = newFileIndex(projectRoot(dir, remote), remote)
+		r.indexes = append(r.indexes, idx)
+	}
+	idx.refreshIfStale()
+	return idx
+}
+
+// containing returns the index with the longest root that contains dir.
+func (r *fileIndexRegistry) containing(dir string) (idx *fileIndex) {
+	dir = cleanIndexPath(dir)
+	for _, x := range r.indexes {
+		if _, ok := x.relativePath(dir); ok && (idx == nil || len(x.root) > len(idx.root)) {
+			idx = x
+		}
+	}
+	return
+}
+
+// invalidate is called when the editor changes the file at path, so that its directory is listed
+// again the next time the index is used.
+func (r *fileIndexRegistry) invalidate(file string) {
+	dir := filepath.Dir(file)
+	if remote, _ := pathIsRemote(file); remote {
+		dir = path.Dir(file)
+	}
+
+	for _, idx := range r.indexes {
+		if rel, ok := idx.relativePath(cleanIndexPath(dir)); ok {
+			idx.invalidate(rel)
+		}
+	}
+}
+
+// projectRoot returns the directory that contains dir and has a .git directory. If there is none,
+// or dir is remote, it returns dir.
+func projectRoot(dir string, remote bool) string {
+	dir = cleanIndexPath(dir)
+	if remote {
+		return dir
+	}
+
+	for d := dir; ; {
+		if fi, err := os.Stat(filepath.Join(d, ".git")); err == nil && fi.IsDir() {
+			return d
+		}
+		parent := filepath.Dir(d)
+		if parent == d {
+			return dir
+		}
+		d = parent
+	}
+}
+
+func cleanIndexPath(p string) string {
+	if remote, _ := pathIsRemote(p); remote {
+		if len(p) > 1 && strings.HasSuffix(p, "/") && !strings.HasSuffix(p, ":/") {
+			p = p[:len(p)-1]
+		}
+		return p
+	}
+	return filepath.Clean(p)
+}
+
+// indexedFile is a file in a project. Path is relative to the project root and separated by slashes.
+type indexedFile struct {
+	Path    string
+	ModTime time.Time
+}
+
+// indexedDir is the listing of one directory of a local project. The modification times of the
+// files are from when the directory was listed: the directory is only listed again when its own
+// modification time changes, which happens when entries are added, removed or renamed.
+type indexedDir struct {
+	modTime time.Time
+	files   []indexedFile
+	subdirs []string
+	// names are the names of all the entries in the directory, including ignored ones, with the
+	// names of subdirectories ending in a path separator. They are used to complete filenames.
+	names []string
+	// ignores are the patterns from the .gitignore file in the directory.
+	ignores []ignorePattern
+	// hasIgnored is true if entries of the directory were left out because they are ignored.
+	hasIgnored bool
+}
+
+// fileIndex is a list of the files under a project root, shared by the features that search the
+// files of a project so that they don't each walk the filesystem. It is built and refreshed in
+// the background.
+type fileIndex struct {
+	root   string
+	remote bool
+
+	lock  sync.Mutex
+	dirs  map[string]*indexedDir
+	files []indexedFile
+	// dirty are the directories that the editor changed since they were listed.
+	dirty map[string]bool
+	ready bool
+	// truncated is true if the project has more files than the file-index-max setting.
+	truncated       bool
+	warnedTruncated bool
+	job             *fileIndexJob
+	onReady         []func()
+
+	lastRefresh       time.Time
+	lastDuration      time.Duration
+	lastDirsRescanned int
+	refreshes         int
+}
+
+func newFileIndex(root string, remote bool) *fileIndex {
+	return &fileIndex{
+		root:   root,
+		remote: remote,
+		dirty:  map[string]bool{},
+	}
+}
+
+// relativePath returns p relative to the root of the index, separated by slashes. ok is false
+// if p is not under the root.
+func (x *fileIndex) relativePath(p string) (rel string, ok bool) {
+	if p == x.root {
+		return "", true
+	}
+
+	sep := string(filepath.Separator)
+	if x.remote {
+		sep = "/"
+	}
+	prefix := x.root
+	if !strings.HasSuffix(prefix, sep) {
+		prefix += sep
+	}
+	if !strings.HasPrefix(p, prefix) {
+		return "", false
+	}
+
+	rel = p[len(prefix):]
+	if !x.remote {
+		rel = filepath.ToSlash(rel)
+	}
+	return rel, true
+}
+
+func (x *fileIndex) refreshInterval() time.Duration {
+	if x.remote {
+		return fileIndexRemoteRefreshInterval
+	}
+	return fileIndexLocalRefreshInterval
+}
+
+func (x *fileIndex) invalidate(rel string) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.dirty[rel] = true
+	x.lastRefresh = time.Time{}
+}
+
+// refreshIfStale starts refreshing the index in the background if it is older than the refresh
+// interval or a directory in it was changed by the editor.
+func (x *fileIndex) refreshIfStale() {
+	x.lock.Lock()
+	if x.job != nil || time.Since(x.lastRefresh) < x.refreshInterval() {
+		x.lock.Unlock()
+		return
+	}
+
+	job := &fileIndexJob{index: x, kill: make(chan struct{})}
+	x.job = job
+	first := !x.ready
+	x.lock.Unlock()
+
+	// Refreshing a local index only lists the directories that changed and is quick, so only the
+	// first walk is shown as a job in the editor tag.
+	if first || x.remote {
+		job.shown = true
+		editor.AddJob(job)
+	}
+	go job.run()
+}
+
+// whenReady calls fn on the main goroutine once the index has been built.
+func (x *fileIndex) whenReady(fn func()) {
+	x.lock.Lock()
+	if !x.ready {
+		x.onReady = append(x.onReady, fn)
+		x.lock.Unlock()
+		return
+	}
+	x.lock.Unlock()
+	fn()
+}
+
+func (x *fileIndex) Ready() bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.ready
+}
+
+// Truncated returns true if the project has more files than the index holds.
+func (x *fileIndex) Truncated() bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.truncated
+}
+
+// Files returns the files in the index.
+func (x *fileIndex) Files() []indexedFile {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	return x.files
+}
+
+// holdsAllFilesUnder returns true if the index has every file under dir: it isn't truncated and
+// no files under dir were left out because they are ignored.
+func (x *fileIndex) holdsAllFilesUnder(dir string) bool {
+	rel, ok := x.relativePath(cleanIndexPath(dir))
+	if !ok {
+		return false
+	}
+
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	if x.truncated {
+		return false
+	}
+	for p, d := range x.dirs {
+		if d.hasIgnored && (rel == "" || p == rel || strings.HasPrefix(p, rel+"/")) {
+			return false
+		}
+	}
+	return true
+}
+
+// filesUnder returns the paths of the files in the index under dir, relative to dir.
+func (x *fileIndex) filesUnder(dir string) []string {
+	rel, ok := x.relativePath(cleanIndexPath(dir))
+	if !ok {
+		return nil
+	}
+	prefix := ""
+	if rel != "" {
+		prefix = rel + "/"
+	}
+
+	var paths []string
+	for _, f := range x.Files() {
+		if strings.HasPrefix(f.Path, prefix) {
+			p := f.Path[len(prefix):]
+			if !x.remote {
+				p = filepath.FromSlash(p)
+			}
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// entriesOf returns the names of the entries in the local directory dir, with the names of
+// subdirectories ending in a path separator. ok is false if the directory is not in the index or
+// changed since it was listed.
+func (x *fileIndex) entriesOf(dir string) (names []string, ok bool) {
+	if x.remote {
+		return
+	}
+	rel, ok := x.relativePath(cleanIndexPath(dir))
+	if !ok {
+		return
+	}
+
+	x.lock.Lock()
+	d, ok := x.dirs[rel]
+	x.lock.Unlock()
+	if !ok {
+		return
+	}
+
+	fi, err := os.Stat(dir)
+	if err != nil || !fi.ModTime().Equal(d.modTime) {
+		return nil, false
+	}
+
+	names = make([]string, len(d.names))
+	copy(names, d.names)
+	return names, true
+}
+
+// fileIndexScan is the result of listing the files of a project.
+type fileIndexScan struct {
+	dirs          map[string]*indexedDir
+	files         []indexedFile
+	truncated     bool
+	dirsRescanned int
+	duration      time.Duration
+}
+
+// scan lists the files in the project. For a local project only the directories whose
+// modification time changed since the last scan, or that are in dirty, are listed again.
+func (x *fileIndex) scan(kill chan struct{}, dirty map[string]bool) (result fileIndexScan, err error) {
+	start := time.Now()
+	if x.remote {
+		result, err = x.scanRemote()
+	} else {
+		x.lock.Lock()
+		old := x.dirs
+		x.lock.Unlock()
+
+		s := localIndexScan{
+			root:  x.root,
+			old:   old,
+			dirty: dirty,
+			kill:  kill,
+			max:   settings.General.FileIndexMax,
+			dirs:  map[string]*indexedDir{},
+		}
+		err = s.scan("", nil)
+		result = fileIndexScan{dirs: s.dirs, files: s.files, truncated: s.truncated, dirsRescanned: s.rescanned}
+	}
+
+	sort.Slice(result.files, func(i, j int) bool {
+		return result.files[i].Path < result.files[j].Path
+	})
+	result.duration = time.Since(start)
+	return
+}
+
+func (x *fileIndex) apply(result fileIndexScan) {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	x.dirs = result.dirs
+	x.files = result.files
+	x.truncated = result.truncated
+	x.ready = true
+	x.lastRefresh = time.Now()
+	x.lastDuration = result.duration
+	x.lastDirsRescanned = result.dirsRescanned
+	x.refreshes++
+}
+
+// takeDirty returns the directories marked as changed by the editor, and clears them.
+func (x *fileIndex) takeDirty() map[string]bool {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+	d := x.dirty
+	x.dirty = map[string]bool{}
+	return d
+}
+
+func (x *fileIndex) scanRemote() (result fileIndexScan, err error) {
+	sfs, err := GetFs(x.root)
+	if err != nil {
+		return
+	}
+
+	// GNU find prints the modification times along with the paths. Other systems fall back to
+	// find without them.
+	cmd := `find . -name .git -prune -o -type f -printf "%T@ %P\n" 2>/dev/null || find . -name .git -prune -o -type f -print | sed "s|^\./|0 |"`
+	out, err := sfs.exec(x.root, cmd, "")
+	if err != nil {
+		return
+	}
+
+	result.files, result.truncated = parseRemoteIndexListing(out, settings.General.FileIndexMax)
+	return
+}
+
+// parseRemoteIndexListing parses lines containing a modification time in seconds since the epoch
+// followed by a path. At most max files are returned.
+func parseRemoteIndexListing(out []byte, max int) (files []indexedFile, truncated bool) {
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		f := strings.SplitN(sc.Text(), " ", 2)
+		if len(f) < 2 || f[1] == "" {
+			continue
+		}
+		if len(files) >= max {
+			return files, true
+		}
+
+		file := indexedFile{Path: f[1]}
+		if secs, err := strconv.ParseFloat(f[0], 64); err == nil && secs > 0 {
+			file.ModTime = time.Unix(0, int64(secs*float64(time.Second)))
+		}
+		files = append(files, file)
+	}
+	return
+}
+
+type localIndexScan struct {
+	root      string
+	old       map[string]*indexedDir
+	dirty     map[string]bool
+	kill      chan struct{}
+	max       int
+	dirs      map[string]*indexedDir
+	files     []indexedFile
+	truncated bool
+	rescanned int
+}
+
+// scan adds the directory rel and the directories under it to the result, listing them only if
+// they changed. ignores are the patterns from the .gitignore files of the parent directories.
+func (s *localIndexScan) scan(rel string, ignores []ignorePattern) error {
+	select {
+	case <-s.kill:
+		return errFileIndexKilled
+	default:
+	}
+	yieldToInteractiveWork()
+
+	abs := filepath.Join(s.root, filepath.FromSlash(rel))
+	fi, err := os.Stat(abs)
+	if err != nil {
+		// The directory was removed while walking.
+		return nil
+	}
+
+	d := s.old[rel]
+	if d == nil || !d.modTime.Equal(fi.ModTime()) || s.dirty[rel] {
+		d = listIndexedDir(abs, rel, fi.ModTime(), ignores)
+		s.rescanned++
+	}
+	s.dirs[rel] = d
+
+	if len(s.files)+len(d.files) > s.max {
+		n := s.max - len(s.files)
+		if n < 0 {
+			n = 0
+		}
+		s.files = append(s.files, d.files[:n]...)
+		s.truncated = true
+		return nil
+	}
+	s.files = append(s.files, d.files...)
+
+	ignores = append(ignores[:len(ignores):len(ignores)], d.ignores...)
+	for _, sub := range d.subdirs {
+		err := s.scan(sub, ignores)
+		if err != nil || s.truncated {
+			return err
+		}
+	}
+	return nil
+}
+
+func listIndexedDir(abs, rel string, modTime time.Time, ignores []ignorePattern) *indexedDir {
+	d := &indexedDir{modTime: modTime}
+
+	if b, err := os.ReadFile(filepath.Join(abs, ".gitignore")); err == nil {
+		d.ignores = parseGitignore(b, rel)
+		ignores = append(ignores[:len(ignores):len(ignores)], d.ignores...)
+	}
+
+	entries, err := os.ReadDir(abs)
+	if err != nil {
+		return d
+	}
+
+	for _, e := range entries {
+		name := indexedEntryName(abs, e)
+		d.names = append(d.names, name)
+
+		if e.Name() == ".git" {
+			continue
+		}
+
+		p := e.Name()
+		if rel != "" {
+			p = rel + "/" + p
+		}
+		if isIgnored(ignores, p, e.IsDir()) {
+			d.hasIgnored = true
+			continue
+		}
+
+		if e.IsDir() {
+			d.subdirs = append(d.subdirs, p)
+			continue
+		}
+		// Symbolic links to directories are not walked, and are not files either.
+		if strings.HasSuffix(name, string(filepath.Separator)) {
+			continue
+		}
+
+		f := indexedFile{Path: p}
+		if info, err := e.Info(); err == nil {
+			f.ModTime = info.ModTime()
+		}
+		d.files = append(d.files, f)
+	}
+	return d
+}
+
+// indexedEntryName returns the name of the directory entry e, ending in a path separator if it
+// is a directory or a symbolic link to one. Symbolic links are not followed when walking so that
+// they can't cause loops.
+func indexedEntryName(dir string, e os.DirEntry) string {
+	isDir := e.IsDir()
+	if e.Type()&os.ModeSymlink != 0 {
+		if fi, err := os.Stat(filepath.Join(dir, e.Name())); err == nil {
+			isDir = fi.IsDir()
+		}
+	}
+	if isDir {
+		return e.Name() + string(filepath.Separator)
+	}
+	return e.Name()
+}
+
+// ignorePattern is a pattern from a .gitignore file. Negated patterns are not supported.
+type ignorePattern struct {
+	// dir is the directory containing the .gitignore file, relative to the project root.
+	dir      string
+	pattern  string
+	anchored bool
+	dirOnly  bool
+}
+
+func parseGitignore(b []byte, dir string) (patterns []ignorePattern) {
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+
+		p := ignorePattern{dir: dir}
+		if strings.HasSuffix(line, "/") {
+			p.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		line = strings.TrimPrefix(line, "**/")
+		if strings.Contains(line, "/") {
+			p.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		p.pattern = line
+		patterns = append(patterns, p)
+	}
+	return
+}
+
+func (p ignorePattern) matches(rel string, isDir bool) bool {
+	if p.dirOnly && !isDir {
+		return false
+	}
+
+	if !p.anchored {
+		ok, _ := path.Match(p.pattern, path.Base(rel))
+		return ok
+	}
+
+	if p.dir != "" {
+		if !strings.HasPrefix(rel, p.dir+"/") {
+			return false
+		}
+		rel = rel[len(p.dir)+1:]
+	}
+	ok, _ := path.Match(p.pattern, rel)
+	return ok
+}
+
+func isIgnored(patterns []ignorePattern, rel string, isDir bool) bool {
+	for _, p := range patterns {
+		if p.matches(rel, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileIndexJob builds or refreshes a fileIndex in the background.
+type fileIndexJob struct {
+	index *fileIndex
+	kill  chan struct{}
+	once  sync.Once
+	// shown is true if the job was added to the editor's jobs.
+	shown bool
+}
+
+func (j *fileIndexJob) Name() string {
+	return "Index"
+}
+
+func (j *fileIndexJob) Kill() {
+	j.once.Do(func() { close(j.kill) })
+}
+
+func (j *fileIndexJob) run() {
+	result, err := j.index.scan(j.kill, j.index.takeDirty())
+	editor.WorkChan() <- &fileIndexDone{job: j, result: result, err: err}
+}
+
+type fileIndexDone struct {
+	job    *fileIndexJob
+	result fileIndexScan
+	err    error
+}
+
+func (l fileIndexDone) Service() (done bool) {
+	x := l.job.index
+	x.lock.Lock()
+	x.job = nil
+	x.lock.Unlock()
+
+	if l.err != nil {
+		if l.err != errFileIndexKilled {
+			editor.AppendError("", fmt.Sprintf("Indexing the files in %s failed: %v", x.root, l.err))
+		}
+		// Try again after the refresh interval instead of every time the index is used.
+		x.lock.Lock()
+		x.lastRefresh = time.Now()
+		x.lock.Unlock()
+		return true
+	}
+
+	x.apply(l.result)
+
+	x.lock.Lock()
+	callbacks := x.onReady
+	x.onReady = nil
+	warn := x.truncated && !x.warnedTruncated
+	x.warnedTruncated = x.truncated
+	x.lock.Unlock()
+
+	if warn {
+		editor.AppendError("", fmt.Sprintf("The project %s has more than %d files. Only the first %d are indexed; the file-index-max setting controls the limit.",
+			x.root, settings.General.FileIndexMax, settings.General.FileIndexMax))
+	}
+
+	for _, fn := range callbacks {
+		fn()
+	}
+	return true
+}
+
+func (l fileIndexDone) Job() Job {
+	if l.job.shown {
+		return l.job
+	}
+	return nil
+}
+
+func (l fileIndexDone) priority() workPriority {
+	return workPriorityLow
+}
+
+// String describes the index for Dbg Index.
+func (x *fileIndex) String() string {
+	x.lock.Lock()
+	defer x.lock.Unlock()
+
+	var buf bytes.Buffer
+	kind := "local"
+	if x.remote {
+		kind = "remote"
+	}
+	fmt.Fprintf(&buf, "%s (%s)\n", x.root, kind)
+	if !x.ready {
+		fmt.Fprintf(&buf, "  not built yet\n")
+		return buf.String()
+	}
+	fmt.Fprintf(&buf, "  files: %d", len(x.files))
+	if x.truncated {
+		fmt.Fprintf(&buf, " (truncated)")
+	}
+	fmt.Fprintf(&buf, "\n  directories: %d\n", len(x.dirs))
+	fmt.Fprintf(&buf, "  refreshes: %d, last took %v and listed %d directories, %v ago\n",
+		x.refreshes, x.lastDuration.Round(time.Microsecond), x.lastDirsRescanned, time.Since(x.lastRefresh).Round(time.Second))
+	if x.job != nil {
+		fmt.Fprintf(&buf, "  refreshing now\n")
+	}
+	return buf.String()
+}
+
+func (r *fileIndexRegistry) String() string {
+	if len(r.indexes) == 0 {
+		return "No projects are indexed\n"
+	}
+
+	var buf bytes.Buffer
+	for _, x := range r.indexes {
+		buf.WriteString(x.String())
+	}
+	return buf.String()
+}
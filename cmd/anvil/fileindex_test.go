@@ -0,0 +1,344 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeIndexTestFiles(t testing.TB, root string, files map[string]string) {
+	for name, contents := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatalf("mkdir failed: %v", err)
+		}
+		if err := os.WriteFile(p, []byte(contents), 0644); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+	}
+}
+
+func indexedPaths(files []indexedFile) []string {
+	var paths []string
+	for _, f := range files {
+		paths = append(paths, f.Path)
+	}
+	return paths
+}
+
+func TestFileIndexScan(t *testing.T) {
+	root := t.TempDir()
+	writeIndexTestFiles(t, root, map[string]string{
+		".gitignore":         "*.o\n/build/\n# comment\n!keep.o\n",
+		"main.go":            "",
+		"main.o":             "",
+		"build/out":          "",
+		"sub/build/x.go":     "",
+		"sub/.gitignore":     "gen/\n",
+		"sub/gen/y.go":       "",
+		"sub/lib.go":         "",
+		".git/HEAD":          "",
+		"deep/er/est/z.txt":  "",
+		"deep/er/est/z.tmpo": "",
+	})
+
+	x := newFileIndex(root, false)
+	result, err := x.scan(make(chan struct{}), nil)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+
+	expected := []string{
+		".gitignore",
+		"deep/er/est/z.tmpo",
+		"deep/er/est/z.txt",
+		"main.go",
+		"sub/.gitignore",
+		"sub/build/x.go",
+		"sub/lib.go",
+	}
+	got := indexedPaths(result.files)
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected files %v but got %v", expected, got)
+	}
+	if result.truncated {
+		t.Fatalf("index should not be truncated")
+	}
+}
+
+func TestFileIndexRescansOnlyChangedDirs(t *testing.T) {
+	root := t.TempDir()
+	writeIndexTestFiles(t, root, map[string]string{
+		"a/1": "",
+		"b/2": "",
+		"c/3": "",
+	})
+
+	x := newFileIndex(root, false)
+	result, err := x.scan(make(chan struct{}), nil)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	x.apply(result)
+	if result.dirsRescanned != 4 {
+		t.Fatalf("expected the first scan to list 4 directories but it listed %d", result.dirsRescanned)
+	}
+
+	// Make sure the modification time of the directory changes even on filesystems with a
+	// coarse resolution.
+	writeIndexTestFiles(t, root, map[string]string{"b/new": ""})
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(filepath.Join(root, "b"), later, later); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+
+	result, err = x.scan(make(chan struct{}), nil)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	x.apply(result)
+	if result.dirsRescanned != 1 {
+		t.Fatalf("expected only the changed directory to be listed but %d were", result.dirsRescanned)
+	}
+	if got := strings.Join(indexedPaths(result.files), ","); got != "a/1,b/2,b/new,c/3" {
+		t.Fatalf("unexpected files %s", got)
+	}
+
+	x.invalidate("c")
+	result, err = x.scan(make(chan struct{}), x.takeDirty())
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if result.dirsRescanned != 1 {
+		t.Fatalf("expected only the invalidated directory to be listed but %d were", result.dirsRescanned)
+	}
+}
+
+func TestFileIndexTruncated(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{}
+	for i := 0; i < 10; i++ {
+		files[fmt.Sprintf("d%d/f", i)] = ""
+	}
+	writeIndexTestFiles(t, root, files)
+
+	defer func(max int) { settings.General.FileIndexMax = max }(settings.General.FileIndexMax)
+	settings.General.FileIndexMax = 4
+
+	x := newFileIndex(root, false)
+	result, err := x.scan(make(chan struct{}), nil)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if !result.truncated {
+		t.Fatalf("expected the index to be truncated")
+	}
+	if len(result.files) != 4 {
+		t.Fatalf("expected 4 files but got %d", len(result.files))
+	}
+	// A limit below zero leaves no room for any files.
+	settings.General.FileIndexMax = -1
+	result, err = x.scan(make(chan struct{}), nil)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	if !result.truncated || len(result.files) != 0 {
+		t.Fatalf("expected no files but got %d", len(result.files))
+	}
+}
+
+func TestFileIndexHoldsAllFilesUnder(t *testing.T) {
+	root := t.TempDir()
+	writeIndexTestFiles(t, root, map[string]string{
+		"lib/a.go":       "",
+		"gen/.gitignore": "*.out\n",
+		"gen/x.out":      "",
+		"gen/x.go":       "",
+	})
+
+	x := newFileIndex(root, false)
+	result, err := x.scan(make(chan struct{}), nil)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	x.apply(result)
+
+	// Find would search gen/x.out, which the index leaves out.
+	if x.holdsAllFilesUnder(root) || x.holdsAllFilesUnder(filepath.Join(root, "gen")) {
+		t.Fatalf("expected the index not to hold all the files where some are ignored")
+	}
+	if !x.holdsAllFilesUnder(filepath.Join(root, "lib")) {
+		t.Fatalf("expected the index to hold all the files under lib")
+	}
+}
+
+func TestFileIndexScanKilled(t *testing.T) {
+	root := t.TempDir()
+	writeIndexTestFiles(t, root, map[string]string{"a/1": ""})
+
+	kill := make(chan struct{})
+	close(kill)
+
+	x := newFileIndex(root, false)
+	_, err := x.scan(kill, nil)
+	if err != errFileIndexKilled {
+		t.Fatalf("expected the scan to be killed but got error %v", err)
+	}
+}
+
+func TestFileIndexFilesUnderAndEntriesOf(t *testing.T) {
+	root := t.TempDir()
+	writeIndexTestFiles(t, root, map[string]string{
+		".gitignore":  "*.log\n",
+		"top":         "",
+		"sub/a.go":    "",
+		"sub/b.log":   "",
+		"sub/inner/c": "",
+	})
+
+	x := newFileIndex(root, false)
+	result, err := x.scan(make(chan struct{}), nil)
+	if err != nil {
+		t.Fatalf("scan failed: %v", err)
+	}
+	x.apply(result)
+
+	sub := filepath.Join(root, "sub")
+	got := x.filesUnder(sub)
+	expected := []string{"a.go", filepath.Join("inner", "c")}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected files %v under sub but got %v", expected, got)
+	}
+
+	if x.filesUnder(filepath.Dir(root)) != nil {
+		t.Fatalf("expected no files outside the root")
+	}
+
+	names, ok := x.entriesOf(sub)
+	if !ok {
+		t.Fatalf("expected sub to be in the index")
+	}
+	sort.Strings(names)
+	// Ignored files are still completed.
+	expected = []string{"a.go", "b.log", "inner" + string(filepath.Separator)}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("expected entries %v but got %v", expected, names)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(sub, later, later); err != nil {
+		t.Fatalf("chtimes failed: %v", err)
+	}
+	if _, ok := x.entriesOf(sub); ok {
+		t.Fatalf("expected a changed directory not to be taken from the index")
+	}
+}
+
+func TestParseGitignore(t *testing.T) {
+	patterns := parseGitignore([]byte("*.o\n/vendor\ndocs/*.html\n**/tmp\nout/\n"), "sub")
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"sub/a.o", false, true},
+		{"sub/x/a.o", false, true},
+		{"sub/vendor", true, true},
+		{"sub/x/vendor", true, false},
+		{"sub/docs/a.html", false, true},
+		{"sub/x/docs/a.html", false, false},
+		{"sub/x/tmp", true, true},
+		{"sub/out", true, true},
+		{"sub/out", false, false},
+		{"sub/a.go", false, false},
+	}
+
+	for _, tc := range tests {
+		if got := isIgnored(patterns, tc.path, tc.isDir); got != tc.ignored {
+			t.Errorf("%s (dir %v): expected ignored %v but got %v", tc.path, tc.isDir, tc.ignored, got)
+		}
+	}
+}
+
+func TestParseRemoteIndexListing(t *testing.T) {
+	out := []byte("1700000000.5 a/b.go\n0 c d.txt\n\nbad\n1700000001 e\n")
+
+	files, truncated := parseRemoteIndexListing(out, 10)
+	if truncated {
+		t.Fatalf("listing should not be truncated")
+	}
+	if got := strings.Join(indexedPaths(files), ","); got != "a/b.go,c d.txt,e" {
+		t.Fatalf("unexpected files %s", got)
+	}
+	if files[0].ModTime.Unix() != 1700000000 || !files[1].ModTime.IsZero() {
+		t.Fatalf("unexpected modification times %v and %v", files[0].ModTime, files[1].ModTime)
+	}
+
+	files, truncated = parseRemoteIndexListing(out, 2)
+	if !truncated || len(files) != 2 {
+		t.Fatalf("expected the listing to be truncated to 2 files, got %d truncated %v", len(files), truncated)
+	}
+}
+
+// makeBenchmarkTree creates a tree of 100k files in 1000 directories.
+func makeBenchmarkTree(b *testing.B) string {
+	root := b.TempDir()
+	for d := 0; d < 1000; d++ {
+		dir := filepath.Join(root, fmt.Sprintf("pkg%d", d/100), fmt.Sprintf("dir%d", d))
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatalf("mkdir failed: %v", err)
+		}
+		for f := 0; f < 100; f++ {
+			p := filepath.Join(dir, fmt.Sprintf("file%d.go", f))
+			if err := os.WriteFile(p, nil, 0644); err != nil {
+				b.Fatalf("write failed: %v", err)
+			}
+		}
+	}
+	return root
+}
+
+var fuzzBenchmarkTerms = []string{"dir42", "file7"}
+
+// BenchmarkFuzzFilesWalking measures Fuzzf when the files are listed by walking the tree for each search.
+func BenchmarkFuzzFilesWalking(b *testing.B) {
+	root := makeBenchmarkTree(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var paths []string
+		filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(root, p)
+				paths = append(paths, rel)
+			}
+			return nil
+		})
+		fuzzyFileResults(fuzzBenchmarkTerms, paths)
+	}
+}
+
+// BenchmarkFuzzFilesIndexed measures Fuzzf when the files are taken from an index that is
+// refreshed before each search.
+func BenchmarkFuzzFilesIndexed(b *testing.B) {
+	root := makeBenchmarkTree(b)
+	x := newFileIndex(root, false)
+	result, err := x.scan(make(chan struct{}), nil)
+	if err != nil {
+		b.Fatalf("scan failed: %v", err)
+	}
+	x.apply(result)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		result, _ := x.scan(make(chan struct{}), nil)
+		x.apply(result)
+		fuzzyFileResults(fuzzBenchmarkTerms, x.filesUnder(root))
+	}
+}
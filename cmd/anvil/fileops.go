@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// dirEntryHighlightDuration is how long the entry of a directory listing that was created or
+// renamed by a file operation is highlighted.
+const dirEntryHighlightDuration = 2 * time.Second
+
+func (c CommandExecutor) CmdTouch(ctx *CmdContext) {
+	c.fileOp("Touch", ctx, func(op *fileOp) {
+		for _, name := range ctx.Args {
+			p, ok := op.path(name)
+			if !ok {
+				continue
+			}
+			exists, err := op.fs.fileExists(p)
+			if err == nil && !exists {
+				err = op.fs.saveFile(p, []byte{})
+			}
+			if op.check(name, err) {
+				op.highlight = name
+				op.open = append(op.open, p)
+			}
+		}
+	})
+}
+
+func (c CommandExecutor) CmdMkdir(ctx *CmdContext) {
+	c.fileOp("Mkdir", ctx, func(op *fileOp) {
+		for _, name := range ctx.Args {
+			p, ok := op.path(name)
+			if !ok {
+				continue
+			}
+			if op.check(name, op.fs.mkdir(p)) {
+				op.highlight = strings.TrimSuffix(name, "/") + "/"
+			}
+		}
+	})
+}
+
+func (c CommandExecutor) CmdRm(ctx *CmdContext) {
+	w, ok := c.dirWindowForFileOp("Rm", ctx)
+	if !ok {
+		return
+	}
+
+	// Like deleting a window with unsaved changes, removing files must be confirmed by
+	// executing Rm again with the same files.
+	args := strings.Join(ctx.Args, " ")
+	if w.dir.pendingRm != args {
+		w.dir.pendingRm = args
+		editor.AppendError(w.file, fmt.Sprintf("Rm: execute Rm %s again to remove it from %s", args, w.file))
+		return
+	}
+	w.dir.pendingRm = ""
+
+	c.fileOp("Rm", ctx, func(op *fileOp) {
+		for _, name := range ctx.Args {
+			p, ok := op.path(name)
+			if !ok {
+				continue
+			}
+			isDir, err := op.fs.isDir(p)
+			if err == nil {
+				if isDir {
+					err = op.fs.rmdir(p)
+				} else {
+					err = op.fs.remove(p)
+				}
+			}
+			op.check(name, err)
+		}
+	})
+}
+
+func (c CommandExecutor) CmdMv(ctx *CmdContext) {
+	if len(ctx.Args) != 2 {
+		c.appendFileOpError("Mv", "expected the old and the new name of the file")
+		return
+	}
+
+	c.fileOp("Mv", ctx, func(op *fileOp) {
+		from, ok := op.path(ctx.Args[0])
+		if !ok {
+			return
+		}
+		to, ok := op.path(ctx.Args[1])
+		if !ok {
+			return
+		}
+
+		highlight := ctx.Args[1]
+		// Moving to a directory moves the file into it, like mv.
+		isDir, err := op.fs.isDir(to)
+		if err == nil && isDir {
+			base := op.base(from)
+			to = op.join(to, base)
+			highlight = strings.TrimSuffix(highlight, "/") + "/"
+		}
+
+		exists, err := op.fs.fileExists(to)
+		if err == nil && exists {
+			err = fmt.Errorf("%s already exists", to)
+		}
+		if err == nil {
+			err = op.fs.rename(from, to)
+		}
+		if op.check(ctx.Args[0], err) {
+			op.highlight = highlight
+		}
+	})
+}
+
+// fileOp is a file operation performed by one of the commands that manage the files of the
+// directory shown in a window.
+type fileOp struct {
+	cmd string
+	dir *GlobalPath
+	fs  simpleFs
+	// errs are the errors that occurred, as messages for +Errors.
+	errs []string
+	// highlight is the name relative to the directory to highlight in the listing afterwards.
+	highlight string
+	// open are the files to open afterwards.
+	open []string
+}
+
+// path returns the full path of the file name in the directory. If name can't be used the
+// error is recorded and ok is false.
+func (op *fileOp) path(name string) (p string, ok bool) {
+	g, err := NewGlobalPath(name, GlobalPathUnknown)
+	if err == nil && g.IsRemote() {
+		err = fmt.Errorf("the path must be on the host of the directory")
+	}
+	if !op.check(name, err) {
+		return
+	}
+	if g.IsAbsolute() {
+		return g.GlobalizeRelativeTo(op.dir).String(), true
+	}
+	return g.MakeAbsoluteRelativeTo(op.dir).String(), true
+}
+
+func (op *fileOp) base(p string) string {
+	g, err := NewGlobalPath(p, GlobalPathUnknown)
+	if err != nil {
+		return filepath.Base(p)
+	}
+	return g.Base()
+}
+
+func (op *fileOp) join(dir, name string) string {
+	if op.dir.IsRemote() {
+		return path.Join(dir, name)
+	}
+	return filepath.Join(dir, name)
+}
+
+// check records err, if it is not nil, as an error operating on name. It returns true if err
+// is nil.
+func (op *fileOp) check(name string, err error) bool {
+	if err == nil {
+		return true
+	}
+	op.errs = append(op.errs, fmt.Sprintf("%s %s: %v", op.cmd, name, err))
+	return false
+}
+
+// dirWindowForFileOp returns the directory window that the command cmd was executed in. If it
+// wasn't executed in one, or was given no files, the error is reported and ok is false.
+func (c CommandExecutor) dirWindowForFileOp(cmd string, ctx *CmdContext) (w *Window, ok bool) {
+	w, ok = c.source.(*Window)
+	if !ok || w.fileType != typeDir {
+		c.appendFileOpError(cmd, "only works in a window showing a directory")
+		return nil, false
+	}
+	if len(ctx.Args) == 0 {
+		editor.AppendError(w.file, fmt.Sprintf("%s: expected the names of the files as arguments", cmd))
+		return nil, false
+	}
+	return w, true
+}
+
+func (c CommandExecutor) appendFileOpError(cmd, msg string) {
+	dir := ""
+	if w, ok := c.source.(*Window); ok && w.fileType == typeDir {
+		dir = w.file
+	}
+	editor.AppendError(dir, fmt.Sprintf("%s: %s", cmd, msg))
+}
+
+// fileOp runs fn to perform the file operation of the command cmd in the directory shown by
+// the window the command was executed in. Since the directory may be remote fn is run in the
+// background. Afterwards the errors are listed in the +Errors window of the directory, the
+// listing is loaded again and the entry fn set to highlight is highlighted briefly.
+func (c CommandExecutor) fileOp(cmd string, ctx *CmdContext, fn func(op *fileOp)) {
+	w, ok := c.dirWindowForFileOp(cmd, ctx)
+	if !ok {
+		return
+	}
+
+	dir, err := NewGlobalPath(w.file, GlobalPathIsDir)
+	if err != nil {
+		editor.AppendError(w.file, fmt.Sprintf("%s: %v", cmd, err))
+		return
+	}
+	sfs, err := GetFs(w.file)
+	if err != nil {
+		editor.AppendError(w.file, fmt.Sprintf("%s: %v", cmd, err))
+		return
+	}
+
+	op := &fileOp{cmd: cmd, dir: dir, fs: sfs}
+	go func() {
+		fn(op)
+		editor.WorkChan() <- basicWork{func() { w.fileOpDone(op) }}
+	}()
+}
+
+func (w *Window) fileOpDone(op *fileOp) {
+	dir := op.dir.String()
+	for _, e := range op.errs {
+		editor.AppendError(dir, e)
+	}
+
+	if editor.FindWindowForId(w.Id) == w && w.fileType == typeDir {
+		if op.highlight != "" {
+			w.highlightDirEntryBriefly(op.highlight)
+		}
+		w.Get()
+	}
+
+	for _, p := range op.open {
+		editor.LoadFileOpts(p, LoadFileOpts{GrowBodyBehaviour: growBodyIfTooSmall})
+	}
+}
+
+// highlightDirEntryBriefly highlights the entry for name in the listing of the directory shown
+// in the window for dirEntryHighlightDuration. If name is in a subdirectory, the subdirectory
+// is highlighted.
+func (w *Window) highlightDirEntryBriefly(name string) {
+	name = listedDirEntry(name)
+	if name == "" {
+		return
+	}
+
+	w.dir.highlight = name
+	w.highlightDirEntry()
+	w.Body.schedule("dir-entry-highlight", dirEntryHighlightDuration, func() {
+		w.dir.highlight = ""
+		w.highlightDirEntry()
+		editor.SignalRedrawRequired()
+	})
+}
+
+// highlightDirEntry highlights the entry w.dir.highlight in the listing in the body, removing
+// the previous highlight. It is called whenever the listing is laid out again.
+func (w *Window) highlightDirEntry() {
+	if w.dir.highlighted != nil {
+		w.Body.RemoveManualHighlights([]*SyntaxInterval{w.dir.highlighted})
+		w.dir.highlighted = nil
+	}
+	if w.dir.highlight == "" {
+		return
+	}
+
+	if start, end, ok := findListedDirEntry(w.Body.Bytes(), w.dir.highlight); ok {
+		w.dir.highlighted = w.Body.addManualHighlight(start, end, WindowStyle.Syntax.KeywordColor)
+	}
+}
+
+// listedDirEntry returns the entry that is listed in a directory for the path name relative to
+// the directory: its first element, with a trailing slash if it is a directory. It returns ""
+// if name is not within the directory.
+func listedDirEntry(name string) string {
+	name = filepath.ToSlash(name)
+	isDir := strings.HasSuffix(name, "/")
+	name = path.Clean(name)
+	if path.IsAbs(name) || name == "." || name == ".." || strings.HasPrefix(name, "../") {
+		return ""
+	}
+
+	if i := strings.IndexByte(name, '/'); i >= 0 {
+		return name[:i+1]
+	}
+	if isDir {
+		name += "/"
+	}
+	return name
+}
+
+// findListedDirEntry returns the rune offsets of the entry name in the listing of a directory,
+// where the entries are separated by whitespace.
+func findListedDirEntry(listing []byte, name string) (start, end int, ok bool) {
+	off := 0
+	for {
+		i := bytes.Index(listing[off:], []byte(name))
+		if i < 0 {
+			return
+		}
+		i += off
+		j := i + len(name)
+
+		before, _ := utf8.DecodeLastRune(listing[:i])
+		after, _ := utf8.DecodeRune(listing[j:])
+		if (i == 0 || unicode.IsSpace(before)) && (j == len(listing) || unicode.IsSpace(after)) {
+			start = utf8.RuneCount(listing[:i])
+			return start, start + utf8.RuneCountInString(name), true
+		}
+		off = i + 1
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListedDirEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		expected string
+	}{
+		{"a.go", "a.go"},
+		{"sub/", "sub/"},
+		{"sub/a.go", "sub/"},
+		{"./b", "b"},
+		{"../a.go", ""},
+		{"/tmp/a.go", ""},
+	}
+
+	for _, tc := range tests {
+		if got := listedDirEntry(tc.name); got != tc.expected {
+			t.Fatalf("for %q expected %q but got %q", tc.name, tc.expected, got)
+		}
+	}
+
+	listing := []byte("ab.go\tb.go    é/\nb")
+	for _, tc := range []struct {
+		name       string
+		start, end int
+		ok         bool
+	}{
+		{"b.go", 6, 10, true},
+		{"é/", 14, 16, true},
+		{"b", 17, 18, true},
+		{"a", 0, 0, false},
+	} {
+		start, end, ok := findListedDirEntry(listing, tc.name)
+		if ok != tc.ok || start != tc.start || end != tc.end {
+			t.Fatalf("finding %q: expected %d-%d (%v) but got %d-%d (%v)", tc.name, tc.start, tc.end, tc.ok, start, end, ok)
+		}
+	}
+}
+
+func TestFileOperationsInDirectoryWindow(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.LoadFile(dir)
+	})
+
+	// eventually waits for cond, evaluated on the main goroutine, to become true.
+	eventually := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			var ok bool
+			onMainGoroutine(func() { ok = cond() })
+			if ok {
+				return
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	do := func(cmd string) {
+		onMainGoroutine(func() { NewCommandExecutor(win).Do(cmd, &CmdContext{}) })
+	}
+	errs := func() string {
+		if w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(win.file)); w != nil {
+			return w.Body.String()
+		}
+		return ""
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, name))
+		return err == nil
+	}
+
+	eventually("the directory to load", func() bool { return win.fileType == typeDir })
+
+	do("Touch a.go")
+	eventually("the new file to be opened", func() bool {
+		w, _ := editor.FindWindowForFile(filepath.Join(dir, "a.go"))
+		return w != nil
+	})
+	onMainGoroutine(func() {
+		if win.dir.highlight != "a.go" {
+			t.Fatalf("expected the new file to be highlighted in the listing but %q is", win.dir.highlight)
+		}
+	})
+
+	do("Mkdir sub")
+	eventually("the directory to be made", func() bool { return exists("sub") })
+	do("Mkdir sub")
+	eventually("an error for the existing directory", func() bool { return strings.Contains(errs(), "Mkdir sub:") })
+
+	do("Mv a.go sub")
+	eventually("the file to be moved into the directory", func() bool { return exists("sub/a.go") && !exists("a.go") })
+
+	do("Rm sub/a.go")
+	var e string
+	onMainGoroutine(func() { e = errs() })
+	if !exists("sub/a.go") || !strings.Contains(e, "execute Rm sub/a.go again") {
+		t.Fatalf("expected the first Rm to ask for confirmation but the errors are %q", e)
+	}
+	do("Rm sub/a.go")
+	eventually("the file to be removed", func() bool { return !exists("sub/a.go") })
+
+	onMainGoroutine(func() {
+		other := editor.NewWindow(nil)
+		other.SetFilenameAndTag(filepath.Join(dir, "notes.txt"), typeFile)
+		NewCommandExecutor(other).Do("Touch b.go", &CmdContext{})
+		if w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf("")); w != nil {
+			e = w.Body.String()
+		}
+	})
+	if exists("b.go") || !strings.Contains(e, "Touch: only works in a window showing a directory") {
+		t.Fatalf("expected Touch to refuse to run outside a directory window but the errors are %q", e)
+	}
+}
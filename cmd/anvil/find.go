@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// contentHighlighter highlights parts of the contents loaded into a window as they arrive.
+// The contents are split into complete lines before they are sent to the window so that
+// matches are never split across two chunks.
+type contentHighlighter struct {
+	// matches returns the byte ranges within line that should be highlighted.
+	matches func(line []byte) [][]int
+	// keep, if set, returns false for the lines that should be left out of the contents.
+	keep    func(line []byte) bool
+	color   Color
+	stopped atomic.Bool
+}
+
+// Stop prevents any more highlighting, including for contents that were already sent
+// to the window but not yet appended.
+func (h *contentHighlighter) Stop() {
+	h.stopped.Store(true)
+}
+
+func (h *contentHighlighter) Stopped() bool {
+	return h.stopped.Load()
+}
+
+// highlightsIn returns the byte ranges to highlight in b, relative to the start of b.
+func (h *contentHighlighter) highlightsIn(b []byte) (ranges [][]int) {
+	off := 0
+	for off < len(b) {
+		if h.Stopped() {
+			return nil
+		}
+
+		end := bytes.IndexByte(b[off:], '\n')
+		if end < 0 {
+			end = len(b)
+		} else {
+			end += off
+		}
+
+		for _, m := range h.matches(b[off:end]) {
+			ranges = append(ranges, []int{off + m[0], off + m[1]})
+		}
+		off = end + 1
+	}
+	return
+}
+
+// filterLines returns the lines in b that should be kept in the contents.
+func (h *contentHighlighter) filterLines(b []byte) []byte {
+	if h.keep == nil {
+		return b
+	}
+
+	var kept []byte
+	for off := 0; off < len(b); {
+		end := bytes.IndexByte(b[off:], '\n')
+		if end < 0 {
+			end = len(b)
+		} else {
+			end += off + 1
+		}
+		if h.keep(bytes.TrimSuffix(b[off:end], []byte("\n"))) {
+			kept = append(kept, b[off:end]...)
+		}
+		off = end
+	}
+	return kept
+}
+
+// byteRangesToRuneRanges converts the byte ranges in b to rune ranges. The ranges must be
+// sorted and must not overlap.
+func byteRangesToRuneRanges(b []byte, ranges [][]int) [][]int {
+	result := make([][]int, 0, len(ranges))
+	bytePos, runePos := 0, 0
+	advance := func(to int) int {
+		if to > len(b) {
+			to = len(b)
+		}
+		if to > bytePos {
+			runePos += utf8.RuneCount(b[bytePos:to])
+			bytePos = to
+		}
+		return runePos
+	}
+
+	for _, r := range ranges {
+		start := advance(r[0])
+		end := advance(r[1])
+		result = append(result, []int{start, end})
+	}
+	return result
+}
+
+// findMatchesInGrepLine returns the byte ranges of matches of re in the text of a line of
+// output from grep -n. The filename and line number prefix of the line is not searched.
+func findMatchesInGrepLine(re *regexp.Regexp, line []byte) [][]int {
+	prefixLen := grepLinePrefixLen(line)
+	if prefixLen < 0 {
+		return nil
+	}
+
+	matches := re.FindAllIndex(line[prefixLen:], -1)
+	for _, m := range matches {
+		m[0] += prefixLen
+		m[1] += prefixLen
+	}
+	return matches
+}
+
+// grepLinePrefixLen returns the length of the "file:line:" prefix of a line of grep -n output,
+// or -1 if the line doesn't have one.
+func grepLinePrefixLen(line []byte) int {
+	for i := 0; i < len(line); i++ {
+		if line[i] != ':' {
+			continue
+		}
+
+		j := i + 1
+		for j < len(line) && line[j] >= '0' && line[j] <= '9' {
+			j++
+		}
+		if j > i+1 && j < len(line) && line[j] == ':' {
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// keepGrepLine returns true if the text of a line of output from grep -n matches re. Lines
+// without a "file:line:" prefix, like the errors from grep, are kept.
+func keepGrepLine(re *regexp.Regexp, line []byte) bool {
+	prefixLen := grepLinePrefixLen(line)
+	return prefixLen < 0 || re.Match(line[prefixLen:])
+}
+
+// grepPatternFor returns a POSIX extended regular expression for grep -E that matches every
+// line that the Go regular expression expr matches. Go regular expressions have syntax that
+// grep doesn't, like \d and (?i), so it is translated from the parsed expression. Where that
+// can't be done exactly the pattern matches more, and the lines from grep are then filtered
+// using expr itself so that the results are the same as searching with expr.
+func grepPatternFor(expr string) (string, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+	writeGrepPattern(&buf, re.Simplify())
+	return buf.String(), nil
+}
+
+func writeGrepPattern(buf *strings.Builder, re *syntax.Regexp) {
+	group := func(subs ...*syntax.Regexp) {
+		buf.WriteRune('(')
+		for i, sub := range subs {
+			if i > 0 {
+				buf.WriteRune('|')
+			}
+			writeGrepPattern(buf, sub)
+		}
+		buf.WriteRune(')')
+	}
+
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			writeGrepRune(buf, r, re.Flags&syntax.FoldCase != 0)
+		}
+	case syntax.OpCharClass:
+		writeGrepCharClass(buf, re.Rune)
+	case syntax.OpAnyCharNotNL, syntax.OpAnyChar:
+		// A single . doesn't match a multibyte character in the C locale.
+		buf.WriteString("(.+)")
+	case syntax.OpBeginLine, syntax.OpBeginText:
+		buf.WriteRune('^')
+	case syntax.OpEndLine, syntax.OpEndText:
+		buf.WriteRune('$')
+	case syntax.OpCapture:
+		group(re.Sub...)
+	case syntax.OpStar:
+		group(re.Sub...)
+		buf.WriteRune('*')
+	case syntax.OpPlus:
+		group(re.Sub...)
+		buf.WriteRune('+')
+	case syntax.OpQuest:
+		group(re.Sub...)
+		buf.WriteRune('?')
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			writeGrepPattern(buf, sub)
+		}
+	case syntax.OpAlternate:
+		group(re.Sub...)
+	default:
+		// Empty matches, word boundaries and repeats that Simplify leaves match anything.
+		buf.WriteString("(.*)")
+	}
+}
+
+// writeGrepRune writes a pattern matching r, or r in any case if foldCase is set.
+func writeGrepRune(buf *strings.Builder, r rune, foldCase bool) {
+	write := func(r rune) {
+		switch {
+		case r == '\n':
+			// A line never holds a newline, and one would split the pattern in two.
+			buf.WriteString("()")
+		case strings.ContainsRune(`\.[]()*+?{}|^$`, r):
+			buf.WriteRune('\\')
+			buf.WriteRune(r)
+		default:
+			buf.WriteRune(r)
+		}
+	}
+
+	if !foldCase || unicode.SimpleFold(r) == r {
+		write(r)
+		return
+	}
+
+	buf.WriteRune('(')
+	for f := r; ; {
+		write(f)
+		f = unicode.SimpleFold(f)
+		if f == r {
+			break
+		}
+		buf.WriteRune('|')
+	}
+	buf.WriteRune(')')
+}
+
+// writeGrepCharClass writes a pattern matching the characters in the ranges of class. Only
+// classes of ASCII characters are written as a bracket expression, since a bracket expression
+// doesn't match a multibyte character in the C locale.
+func writeGrepCharClass(buf *strings.Builder, class []rune) {
+	var set [utf8.RuneSelf]bool
+	n := 0
+	for i := 0; i < len(class); i += 2 {
+		if class[i+1] >= utf8.RuneSelf {
+			buf.WriteString("(.+)")
+			return
+		}
+		for r := class[i]; r <= class[i+1]; r++ {
+			// NUL can't be passed in an argument and a newline would split the pattern, but
+			// neither is in the lines of text grep outputs.
+			if r != 0 && r != '\n' {
+				set[r] = true
+				n++
+			}
+		}
+	}
+
+	if n == 0 {
+		buf.WriteString("()")
+		return
+	}
+
+	// ] must come first, and ^ must not, and - must come last.
+	var b strings.Builder
+	if set[']'] {
+		b.WriteRune(']')
+	}
+	for r := rune(1); r < utf8.RuneSelf; r++ {
+		if !set[r] || r == ']' || r == '^' || r == '-' {
+			continue
+		}
+		end := r
+		for end+1 < utf8.RuneSelf && set[end+1] && end+1 != ']' && end+1 != '^' && end+1 != '-' {
+			end++
+		}
+		b.WriteRune(r)
+		if end > r+1 {
+			b.WriteRune('-')
+		}
+		if end > r {
+			b.WriteRune(end)
+		}
+		r = end
+	}
+	if set['^'] {
+		if b.Len() == 0 {
+			// [^] would be a negated class.
+			b.WriteString("\\")
+		}
+		b.WriteRune('^')
+	}
+	if set['-'] {
+		b.WriteRune('-')
+	}
+
+	buf.WriteRune('[')
+	buf.WriteString(b.String())
+	buf.WriteRune(']')
+}
+
+// findWindowNameOf returns the name of the window that holds the results of Find for the directory dir.
+func findWindowNameOf(dir string) string {
+	return fmt.Sprintf("%s+Find", dir)
+}
+
+// grepCommand returns the shell command used to search the files in paths for lines matching
+// the extended regular expression pattern.
+func grepCommand(pattern string, paths []string) string {
+	var buf bytes.Buffer
+	buf.WriteString("grep -rnIH -E --exclude-dir=.git -e ")
+	buf.WriteString(shellQuote(pattern))
+	buf.WriteString(" --")
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	for _, p := range paths {
+		buf.WriteRune(' ')
+		buf.WriteString(shellQuote(p))
+	}
+	// grep exits with status 1 when nothing matched, which is not an error here.
+	buf.WriteString(" || [ $? -eq 1 ]")
+	return buf.String()
+}
+
+// shellQuote quotes s so that it is passed to a command as a single argument by a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// indexedFilesForFind returns the paths of the files under dir from the project index, relative
+// to dir and separated by NUL bytes. ok is false if the index doesn't hold all the files that grep
+// would search under dir, so that Find gives the same results with or without the index.
+func indexedFilesForFind(dir string) (files []byte, ok bool) {
+	idx := fileIndexes.containing(dir)
+	if idx == nil {
+		return
+	}
+	idx.refreshIfStale()
+	if !idx.Ready() || !idx.holdsAllFilesUnder(dir) {
+		return
+	}
+
+	var buf bytes.Buffer
+	for _, p := range idx.filesUnder(dir) {
+		buf.WriteString(p)
+		buf.WriteByte(0)
+	}
+	// A nil stdin would leave grep reading the terminal when there are no files.
+	files = buf.Bytes()
+	if files == nil {
+		files = []byte{}
+	}
+	return files, true
+}
+
+// indexedGrepCommand returns the shell command used to search the files whose NUL separated
+// paths are read from stdin for lines matching the extended regular expression pattern.
+func indexedGrepCommand(pattern string) string {
+	// grep exits with status 1 when nothing matched, which is not an error here. It is turned into
+	// 0 for each grep that xargs runs, since xargs exits with status 123 for any status from 1 to
+	// 125 and then a failed grep couldn't be told from one that found nothing.
+	var buf bytes.Buffer
+	buf.WriteString("grep -nIH -E -e ")
+	buf.WriteString(shellQuote(pattern))
+	buf.WriteString(` -- "$@" || [ $? -eq 1 ]`)
+	return "xargs -0 sh -c " + shellQuote(buf.String()) + " sh"
+}
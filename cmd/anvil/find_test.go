@@ -0,0 +1,169 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestFindMatchesInGrepLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		re       string
+		line     string
+		expected [][]int
+	}{
+		{
+			name:     "single match",
+			re:       "foo",
+			line:     "./a.go:12:x := foo()",
+			expected: [][]int{{15, 18}},
+		},
+		{
+			name:     "prefix not searched",
+			re:       "a",
+			line:     "./a.go:3:bab",
+			expected: [][]int{{10, 11}},
+		},
+		{
+			name:     "colon in text",
+			re:       "b",
+			line:     "x.txt:1:a:2:b",
+			expected: [][]int{{12, 13}},
+		},
+		{
+			name:     "no prefix",
+			re:       "foo",
+			line:     "grep: foo: Permission denied",
+			expected: nil,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			re := regexp.MustCompile(tc.re)
+			actual := findMatchesInGrepLine(re, []byte(tc.line))
+			if len(actual) == 0 && len(tc.expected) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %v but got %v", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestHighlightsAcrossChunks(t *testing.T) {
+	h := &contentHighlighter{
+		matches: func(line []byte) [][]int {
+			return findMatchesInGrepLine(regexp.MustCompile("é+"), line)
+		},
+	}
+	sender := WindowDataLoadSender{load: &WindowDataLoad{Highlighter: h}}
+
+	chunks := []string{"a:1:xé", "é\nb:2:", "é\n", "c:3:"}
+	expected := []string{"", "a:1:xéé\n", "b:2:é\n", ""}
+	for i, c := range chunks {
+		lines := sender.completeLines([]byte(c))
+		if string(lines) != expected[i] {
+			t.Fatalf("chunk %d: expected complete lines %q but got %q", i, expected[i], lines)
+		}
+	}
+
+	data := []byte("a:1:xéé\nb:2:é\n")
+	runes := byteRangesToRuneRanges(data, h.highlightsIn(data))
+	if !reflect.DeepEqual(runes, [][]int{{5, 7}, {12, 13}}) {
+		t.Fatalf("unexpected rune ranges %v", runes)
+	}
+
+	h.Stop()
+	if r := h.highlightsIn(data); r != nil {
+		t.Fatalf("expected no highlights after stopping but got %v", r)
+	}
+}
+
+func TestGrepPatternFindsTheLinesTheExpressionMatches(t *testing.T) {
+	if _, err := exec.LookPath("grep"); err != nil {
+		t.Skip("grep is not installed")
+	}
+
+	lines := []string{"abc", "ABC", "x12y", "xy", "héllo", "HÉLLO", "a.b", "axb", "foo bar", "foobar",
+		"a]b", "a^b", "a-b", `a\b`, "[x]", "tab\there", "x{2}", "end$"}
+	exprs := []string{`\d+`, `(?i)abc`, `(?i)héllo`, `h.llo`, `a\.b`, `\bbar\b`, `foo\s*bar`, `[]^-]`,
+		`[^a-z]`, `\w+\.\w+`, `x\d{2}y`, `^\[x\]$`, `\$$`, `[[:punct:]]`, `\\`, `x\{2\}`, `[é]`, `\pL+`}
+
+	for _, expr := range exprs {
+		t.Run(expr, func(t *testing.T) {
+			pattern, err := grepPatternFor(expr)
+			if err != nil {
+				t.Fatalf("translating failed: %v", err)
+			}
+
+			// The C locale is the one where grep matches bytes rather than characters.
+			cmd := exec.Command("grep", "-E", "-e", pattern)
+			cmd.Env = []string{"LC_ALL=C"}
+			cmd.Stdin = strings.NewReader(strings.Join(lines, "\n") + "\n")
+			out, _ := cmd.Output()
+			found := map[string]bool{}
+			for _, l := range strings.Split(string(out), "\n") {
+				found[l] = true
+			}
+
+			re := regexp.MustCompile(expr)
+			for _, l := range lines {
+				if re.MatchString(l) && !found[l] {
+					t.Fatalf("expected grep -E %q to find the line %q", pattern, l)
+				}
+			}
+		})
+	}
+}
+
+func TestFindKeepsOnlyLinesTheExpressionMatches(t *testing.T) {
+	re := regexp.MustCompile(`\bfoo\b`)
+	h := &contentHighlighter{
+		keep: func(line []byte) bool {
+			return keepGrepLine(re, line)
+		},
+	}
+
+	// grep finds lines containing foo anywhere, and its errors are kept.
+	lines := "a.go:1:foo()\nb.go:2:food\ngrep: c: Permission denied\nd.go:3:x := foo"
+	expected := "a.go:1:foo()\ngrep: c: Permission denied\nd.go:3:x := foo"
+	if kept := string(h.filterLines([]byte(lines))); kept != expected {
+		t.Fatalf("expected the lines %q but got %q", expected, kept)
+	}
+}
+
+func TestIndexedGrepCommandReportsFailures(t *testing.T) {
+	if _, err := exec.LookPath("grep"); err != nil {
+		t.Skip("grep is not installed")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "a.txt"), []byte("foo\n"), 0644); err != nil {
+		t.Fatalf("writing failed: %v", err)
+	}
+
+	grep := func(pattern string, files ...string) (string, error) {
+		cmd := exec.Command("sh", "-c", indexedGrepCommand(pattern))
+		cmd.Dir = dir
+		cmd.Stdin = strings.NewReader(strings.Join(files, "\x00") + "\x00")
+		out, err := cmd.Output()
+		return string(out), err
+	}
+
+	if out, err := grep("foo", "a.txt"); err != nil || out != "a.txt:1:foo\n" {
+		t.Fatalf("expected a match but got %q, %v", out, err)
+	}
+	if out, err := grep("it's", "a.txt"); err != nil || out != "" {
+		t.Fatalf("expected no matches and no error but got %q, %v", out, err)
+	}
+	if _, err := grep("foo", "a.txt", "missing.txt"); err == nil {
+		t.Fatalf("expected grep failing on a missing file to be an error")
+	}
+}
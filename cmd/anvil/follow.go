@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"github.com/jeffwilliams/anvil/internal/textenc"
+)
+
+// followTagMarker is shown in the tag of a window that is following its file.
+const followTagMarker = "⇣"
+
+// followPollInterval is how often the files followed by windows are checked for new data.
+const followPollInterval = time.Second
+
+// followMaxRead is the most data read from a followed file each time it is checked.
+const followMaxRead = 1024 * 1024
+
+// fileFollower watches the files of the windows that are following them, and appends any
+// data added to the end of the files to the windows. While any window is following its file, the
+// files are checked every followPollInterval using the editor's scheduler, like autosave, and
+// each round of checks is done in a background goroutine. It is only used by the main goroutine.
+type fileFollower struct {
+	files     map[*Window]*followedFile
+	scheduler *Scheduler
+	// checking is true from when a round of checks is scheduled until it is done.
+	checking bool
+}
+
+type followedFile struct {
+	path string
+	// offset is the number of bytes of the file that have been read into the window. It is -1
+	// while the window is being loaded, since then the body doesn't hold all of the file yet.
+	offset int64
+	// decoder converts the data appended to the file from the encoding of the window.
+	decoder *textenc.Decoder
+	// stopped is set when following can't continue until the window is loaded again: when the
+	// file shrank, which means it was probably truncated or rotated, or loading it failed.
+	stopped bool
+}
+
+func (f *fileFollower) add(w *Window, path string, offset int64, enc textenc.Encoding) {
+	if f.files == nil {
+		f.files = make(map[*Window]*followedFile)
+	}
+	f.files[w] = &followedFile{path: path, offset: offset, decoder: textenc.NewDecoderAfterStart(enc)}
+	f.schedule()
+}
+
+func (f *fileFollower) remove(w *Window) {
+	delete(f.files, w)
+}
+
+func (f *fileFollower) following(w *Window) (ok bool, stopped bool) {
+	ff, ok := f.files[w]
+	if ok {
+		stopped = ff.stopped
+	}
+	return
+}
+
+// setOffset sets how much of the followed file is loaded in the window and the encoding it is in,
+// and resumes following if it was stopped.
+func (f *fileFollower) setOffset(w *Window, offset int64, enc textenc.Encoding) {
+	ff, ok := f.files[w]
+	if !ok {
+		return
+	}
+	ff.offset = offset
+	ff.decoder = textenc.NewDecoderAfterStart(enc)
+	ff.stopped = false
+}
+
+// schedule starts the next round of checks after followPollInterval, unless one is already
+// scheduled.
+func (f *fileFollower) schedule() {
+	if f.checking {
+		return
+	}
+	if f.scheduler == nil {
+		f.scheduler = NewScheduler(editor.WorkChan())
+	}
+	f.checking = true
+	f.scheduler.AfterFunc("follow", followPollInterval, f.checkAll)
+}
+
+// checkAll checks the followed files that are not being loaded or stopped in a background
+// goroutine, and schedules the next round once it is done. The checks stop when no window is
+// following its file.
+func (f *fileFollower) checkAll() {
+	if len(f.files) == 0 {
+		f.checking = false
+		return
+	}
+
+	files := make(map[*Window]followedFile, len(f.files))
+	for w, ff := range f.files {
+		if ff.offset >= 0 && !ff.stopped {
+			files[w] = *ff
+		}
+	}
+
+	go func() {
+		for w, ff := range files {
+			f.check(w, ff)
+		}
+		editor.WorkChan() <- basicWork{func() {
+			f.checking = false
+			if len(f.files) > 0 {
+				f.schedule()
+			}
+		}}
+	}()
+}
+
+// check reads any data appended to the followed file ff since it was last checked, decodes it,
+// and sends it to the editor to be appended to the window w.
+func (f *fileFollower) check(w *Window, ff followedFile) {
+	sfs, err := GetFs(ff.path)
+	if err != nil {
+		log(LogCatgWin, "fileFollower: getting filesystem for %s failed: %v\n", ff.path, err)
+		return
+	}
+
+	size, err := sfs.fileSize(ff.path)
+	if err != nil {
+		log(LogCatgWin, "fileFollower: getting the size of %s failed: %v\n", ff.path, err)
+		return
+	}
+
+	if size < ff.offset {
+		editor.WorkChan() <- basicWork{func() {
+			f.fileTruncated(w, ff.offset)
+		}}
+		return
+	}
+
+	if size == ff.offset {
+		return
+	}
+
+	data, err := sfs.loadFileRange(ff.path, ff.offset, followMaxRead)
+	if err != nil {
+		log(LogCatgWin, "fileFollower: reading %s failed: %v\n", ff.path, err)
+		return
+	}
+	// The decoder holds back the end of a UTF-16 character or a line ending until the rest is
+	// read, but passes on the start of a UTF-8 character.
+	if ff.decoder.Encoding().Charset == textenc.UTF8 {
+		data = withoutPartialRuneAtEnd(data)
+	}
+	if len(data) == 0 {
+		return
+	}
+
+	crlf := ff.decoder.Encoding().CRLF
+	text := ff.decoder.Decode(data)
+	if crlf && !ff.decoder.Encoding().CRLF {
+		editor.WorkChan() <- basicWork{func() {
+			f.lineEndingsChanged(w, ff.offset)
+		}}
+		return
+	}
+
+	editor.WorkChan() <- basicWork{func() {
+		f.appendData(w, ff.offset, int64(len(data)), text)
+	}}
+}
+
+// appendData appends text, decoded from the n bytes read from the followed file at offset, to the
+// window, unless the window stopped following the file or was loaded again since it was read.
+func (f *fileFollower) appendData(w *Window, offset, n int64, text []byte) {
+	ff, ok := f.files[w]
+	if !ok || ff.offset != offset || ff.stopped {
+		return
+	}
+	ff.offset += n
+
+	if len(text) > 0 {
+		w.appendFollowedData(text)
+	}
+}
+
+func (f *fileFollower) fileTruncated(w *Window, offset int64) {
+	ff, ok := f.files[w]
+	if !ok || ff.offset != offset || ff.stopped {
+		return
+	}
+	ff.stopped = true
+
+	editor.AppendError("", fmt.Sprintf("%s: the file shrank, so it was probably truncated or rotated. Execute Get to load it again and continue following it.", w.file))
+	w.SetTag()
+}
+
+// lineEndingsChanged stops following when a line ending without a carriage return was appended to
+// a file with CRLF line endings, since the window has to be loaded again to hold the file as it is.
+func (f *fileFollower) lineEndingsChanged(w *Window, offset int64) {
+	ff, ok := f.files[w]
+	if !ok || ff.offset != offset || ff.stopped {
+		return
+	}
+	ff.stopped = true
+
+	editor.AppendError("", fmt.Sprintf("%s: a line was appended without a CRLF line ending. Execute Get to load the file again and continue following it.", w.file))
+	w.SetTag()
+}
+
+// withoutPartialRuneAtEnd removes the bytes at the end of data that are only the start of a
+// UTF-8 encoded rune. They are read again along with the rest of the rune on the next check.
+func withoutPartialRuneAtEnd(data []byte) []byte {
+	for i := 1; i < utf8.UTFMax && i <= len(data); i++ {
+		b := data[len(data)-i]
+		if utf8.RuneStart(b) {
+			if !utf8.FullRune(data[len(data)-i:]) {
+				return data[:len(data)-i]
+			}
+			break
+		}
+	}
+	return data
+}
+
+// SetFollowing starts or stops following the file in the window. While following, data appended
+// to the file is appended to the window body, and the body can't be changed.
+func (w *Window) SetFollowing(b bool) error {
+	if !b {
+		editor.follower.remove(w)
+		w.SetTag()
+		return nil
+	}
+
+	if w.fileType != typeFile || w.IsErrorsWindow() {
+		return fmt.Errorf("only windows containing a file can follow it")
+	}
+	if w.IsDirty() {
+		return fmt.Errorf("the window has unsaved changes. Put or Get it first")
+	}
+
+	// The body holds the file as it was last loaded or saved, so encoding it gives the size of
+	// the file then.
+	size := len(textenc.Encode(w.Body.Bytes(), w.encoding))
+	editor.follower.add(w, w.file, int64(size), w.encoding)
+	w.SetTag()
+	return nil
+}
+
+func (w *Window) IsFollowing() bool {
+	ok, _ := editor.follower.following(w)
+	return ok
+}
+
+// pauseFollowingDuringLoad stops appending data to the window from its followed file while the file is
+// being loaded into the window again.
+func (w *Window) pauseFollowingDuringLoad() {
+	editor.follower.setOffset(w, -1, w.encoding)
+}
+
+// stopFollowingAfterFailedLoad stops following the file until the window is loaded again, since
+// the body may not hold all of the file.
+func (w *Window) stopFollowingAfterFailedLoad() {
+	if ff, ok := editor.follower.files[w]; ok {
+		ff.stopped = true
+		w.SetTag()
+	}
+}
+
+// resumeFollowingAfterLoad continues following the file once the first size bytes of it were
+// loaded into the window.
+func (w *Window) resumeFollowingAfterLoad(size int64) {
+	editor.follower.setOffset(w, size, w.encoding)
+	w.SetTag()
+}
+
+func (w *Window) appendFollowedData(data []byte) {
+	atEnd := w.Body.endOfDocVisible()
+	w.Append(data)
+	// The body still holds the same contents as the file.
+	w.markTextAsUnchanged()
+
+	if atEnd && !w.tailingSuspended {
+		w.Body.AddOpForNextLayout(func(gtx layout.Context) {
+			w.Body.moveToEndOfDoc(gtx)
+		})
+	}
+}
+
+// followTagCommands returns the text added to the tag of a window that is following its file.
+func (w *Window) followTagCommands() string {
+	ok, stopped := editor.follower.following(w)
+	if !ok {
+		return ""
+	}
+	if stopped {
+		return " " + followTagMarker + " Get"
+	}
+	return " " + followTagMarker
+}
+
+// endOfDocVisible returns whether the end of the text was visible the last time the editable
+// was drawn. Lines that are wrapped are counted as one line, so it is only approximate.
+func (e *editable) endOfDocVisible() bool {
+	_, rows := e.sizeInCharacters()
+	if rows == 0 {
+		return true
+	}
+
+	doc, _ := e.removeFirstNRunes(e.Bytes(), e.TopLeftIndex)
+	return bytes.Count(doc, []byte("\n")) < rows
+}
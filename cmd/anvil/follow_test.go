@@ -0,0 +1,203 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jeffwilliams/anvil/internal/textenc"
+)
+
+func TestWithoutPartialRuneAtEnd(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"ascii", "abc\n", "abc\n"},
+		{"complete rune", "ab€", "ab€"},
+		{"first byte of rune", "ab\xe2", "ab"},
+		{"two bytes of rune", "ab\xe2\x82", "ab"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got := string(withoutPartialRuneAtEnd([]byte(tc.input)))
+			if got != tc.want {
+				t.Fatalf("expected %q but got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestLocalFsLoadFileRange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(path, []byte("0123456789"), 0644)
+	if err != nil {
+		t.Fatalf("writing file failed: %v", err)
+	}
+
+	var fs localFs
+	size, err := fs.fileSize(path)
+	if err != nil || size != 10 {
+		t.Fatalf("expected the size to be 10 but got %d (error %v)", size, err)
+	}
+
+	data, err := fs.loadFileRange(path, 4, 3)
+	if err != nil || string(data) != "456" {
+		t.Fatalf("expected to read %q but got %q (error %v)", "456", string(data), err)
+	}
+
+	data, err = fs.loadFileRange(path, 8, 100)
+	if err != nil || string(data) != "89" {
+		t.Fatalf("expected to read %q but got %q (error %v)", "89", string(data), err)
+	}
+}
+
+func TestFollowingWindowAppendsData(t *testing.T) {
+	newTestEditor()
+
+	path := filepath.Join(t.TempDir(), "log")
+	win := editor.NewWindow(nil)
+	win.SetFilenameAndTag(path, typeFile)
+	win.Body.SetText([]byte("one\n"))
+	win.markTextAsUnchanged()
+
+	err := win.SetFollowing(true)
+	if err != nil {
+		t.Fatalf("SetFollowing failed: %v", err)
+	}
+	defer win.SetFollowing(false)
+
+	if !strings.Contains(win.Tag.String(), followTagMarker) {
+		t.Fatalf("expected the tag to show the window is following but it is %q", win.Tag.String())
+	}
+	win.UpdateFilenameFromTag()
+	if win.file != path {
+		t.Fatalf("expected the marker not to change the filename but it is %q", win.file)
+	}
+
+	editor.follower.appendData(win, 4, 4, []byte("two\n"))
+	if win.Body.String() != "one\ntwo\n" {
+		t.Fatalf("expected the data to be appended but the body is %q", win.Body.String())
+	}
+	if win.IsDirty() {
+		t.Fatalf("expected the window not to be dirty after appending followed data")
+	}
+
+	// Data read at an older offset is stale and must be ignored.
+	editor.follower.appendData(win, 4, 4, []byte("two\n"))
+	if win.Body.String() != "one\ntwo\n" {
+		t.Fatalf("expected stale data to be ignored but the body is %q", win.Body.String())
+	}
+
+	win.Body.CursorIndices = []int{0}
+	win.Body.restoreCursorsIfModificationRefused(func() { win.Body.InsertText("x") })
+	if win.Body.String() != "one\ntwo\n" {
+		t.Fatalf("expected the body not to change while following but it is %q", win.Body.String())
+	}
+
+	editor.follower.fileTruncated(win, 8)
+	if !strings.Contains(win.Tag.String(), followTagMarker+" Get") {
+		t.Fatalf("expected the tag to offer Get after the file was truncated but it is %q", win.Tag.String())
+	}
+	editor.follower.appendData(win, 8, 6, []byte("three\n"))
+	if win.Body.String() != "one\ntwo\n" {
+		t.Fatalf("expected data not to be appended after the file was truncated but the body is %q", win.Body.String())
+	}
+
+	win.SetFollowing(false)
+	if strings.Contains(win.Tag.String(), followTagMarker) {
+		t.Fatalf("expected the marker to be removed from the tag but it is %q", win.Tag.String())
+	}
+	win.Body.InsertText("x")
+	if win.Body.String() != "xone\ntwo\n" {
+		t.Fatalf("expected the body to change after following stopped but it is %q", win.Body.String())
+	}
+}
+
+func TestFollowingStartsOnceTheStateIsLoaded(t *testing.T) {
+	newTestHeadless()
+
+	// The file doesn't exist, so loading it is done before GetWithSelect returns.
+	path := filepath.Join(t.TempDir(), "log")
+	win := editor.Cols[0].NewWindow()
+	win.SetFilenameAndTag(path, typeFile)
+	state := win.State()
+	state.Follow = true
+
+	loaded := editor.Cols[0].NewWindow()
+	loaded.SetState(state)
+	defer loaded.SetFollowing(false)
+
+	ok, stopped := editor.follower.following(loaded)
+	if !ok || stopped {
+		t.Fatalf("expected the window to be following its file")
+	}
+	if offset := editor.follower.files[loaded].offset; offset != 0 {
+		t.Fatalf("expected following to start from the end of the loaded file but the offset is %d", offset)
+	}
+}
+
+func TestFollowingDecodesAppendedData(t *testing.T) {
+	newTestHeadless()
+
+	path := filepath.Join(t.TempDir(), "log")
+	if err := os.WriteFile(path, []byte("\xef\xbb\xbfone\r\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+	win := editor.Cols[0].NewWindow()
+	win.SetFilenameAndTag(path, typeFile)
+	win.encoding, win.encodingSet = textenc.Encoding{Charset: textenc.UTF8, BOM: true, CRLF: true}, true
+	win.Body.SetText([]byte("one\n"))
+	win.markTextAsUnchanged()
+
+	if err := win.SetFollowing(true); err != nil {
+		t.Fatalf("SetFollowing failed: %v", err)
+	}
+	defer win.SetFollowing(false)
+	// The checks are made by the test rather than by the scheduler.
+	editor.follower.scheduler.stopTimerIfAlreadyCreated("follow")
+	if offset := editor.follower.files[win].offset; offset != 8 {
+		t.Fatalf("expected following to start after the 8 bytes of the file but the offset is %d", offset)
+	}
+
+	check := func() {
+		done := make(chan struct{})
+		go func() {
+			editor.follower.check(win, *editor.follower.files[win])
+			close(done)
+		}()
+		handleWork(<-editor.ReadyWork())
+		<-done
+	}
+
+	// The line ending is split between two checks.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("opening the file failed: %v", err)
+	}
+	defer f.Close()
+	f.WriteString("two\r")
+	check()
+	f.WriteString("\nthree\r\n")
+	check()
+
+	if body := win.Body.String(); body != "one\ntwo\nthree\n" {
+		t.Fatalf("expected the appended lines to be decoded but the body is %q", body)
+	}
+	if offset := editor.follower.files[win].offset; offset != 20 {
+		t.Fatalf("expected the offset to be the 20 bytes of the file but it is %d", offset)
+	}
+
+	f.WriteString("four\n")
+	check()
+	if _, stopped := editor.follower.following(win); !stopped {
+		t.Fatalf("expected following to stop when a line without a CRLF line ending was appended")
+	}
+	if body := win.Body.String(); body != "one\ntwo\nthree\n" {
+		t.Fatalf("expected the line not to be appended but the body is %q", body)
+	}
+}
@@ -105,12 +105,12 @@ func (f FileFinder) Find(fpath string) (fullpath *GlobalPath, existsLocal bool,
 
 func (f FileFinder) winFile() (path *GlobalPath, err error) {
 	var lfs localFs
-	rfs := NewSshFs(sshOptsFromSettings())
 
 	path, err = f.winFileNoCheck()
 	if err != nil {
 		return
 	}
+	rfs := NewSshFs(sshOptsFor(path.Host()))
 
 	if path.dirState == GlobalPathUnknown {
 		var isDir bool
@@ -149,6 +149,18 @@ func (f FileFinder) winFileNoCheck() (path *GlobalPath, err error) {
 			p = p[:len(p)-5]
 			state = GlobalPathIsDir
 		}
+		if strings.HasSuffix(p, "+Find") {
+			p = p[:len(p)-5]
+			state = GlobalPathIsDir
+		}
+		if strings.HasSuffix(p, "+Diff") {
+			p = p[:len(p)-5]
+			state = GlobalPathIsDir
+		}
+		if strings.HasSuffix(p, "+Tutorial") {
+			p = p[:len(p)-9]
+			state = GlobalPathIsDir
+		}
 		if f.win.fileType == typeDir {
 			state = GlobalPathIsDir
 		}
@@ -200,6 +212,12 @@ func (l *FileLoader) Load(path string) (contents []byte, filenames []string, err
 }
 
 func (l *FileLoader) LoadAsync(path string) (load *DataLoad, err error) {
+	if isArchiveMemberPath(path) {
+		load = NewDataLoad()
+		loadArchiveMemberAsync(path, load.Contents, load.Errs, load.Kill)
+		return
+	}
+
 	sfs, err := GetFs(path)
 	if err != nil {
 		return
@@ -213,7 +231,7 @@ func (l *FileLoader) LoadAsync(path string) (load *DataLoad, err error) {
 
 type DataLoad struct {
 	Contents  chan []byte
-	Filenames chan []string
+	Filenames chan []DirEntry
 	Errs      chan error // Will only contain one error
 	Kill      chan struct{}
 }
@@ -223,7 +241,7 @@ func NewDataLoad() *DataLoad {
 		Errs:      make(chan error),
 		Kill:      make(chan struct{}, 1),
 		Contents:  make(chan []byte),
-		Filenames: make(chan []string),
+		Filenames: make(chan []DirEntry),
 	}
 }
 
@@ -276,7 +294,11 @@ func GetFs(path string) (sfs simpleFs, err error) {
 
 	if isRemote {
 		log(LogCatgFS, "GetFs: for %s, using ssh\n", path)
-		r := NewSshFs(sshOptsFromSettings())
+		var host string
+		if g, err := NewGlobalPath(path, GlobalPathUnknown); err == nil {
+			host = g.Host()
+		}
+		r := NewSshFs(sshOptsFor(host))
 		sfs = r
 	} else {
 		log(LogCatgFS, "GetFs: for %s, using local filesystem\n", path)
@@ -287,27 +309,52 @@ func GetFs(path string) (sfs simpleFs, err error) {
 	return
 }
 
-func sshOptsFromSettings() sshFsOpts {
-	return sshFsOpts{
+// sshOptsFor returns the options for the ssh filesystem of host, from the ssh settings and the
+// settings for the host.
+func sshOptsFor(host string) sshFsOpts {
+	opts := sshFsOpts{
 		shell:      settings.Ssh.Shell,
 		closeStdin: settings.Ssh.CloseStdin,
 	}
+
+	if hs, ok := sshHostSettingsFor(host); ok {
+		if hs.Shell != "" {
+			opts.shell = hs.Shell
+		}
+		opts.shellString = hs.ShellString
+	}
+	return opts
 }
 
 type simpleFs interface {
 	fileExists(path string) (ok bool, err error)
 	isDir(path string) (ok bool, err error)
 	isDirAsync(path string, kill chan struct{}) (ok bool, err error)
+	// isWritable returns true if the current user can write the file at path, or create it if it doesn't exist.
+	isWritable(path string) (ok bool, err error)
 	loadFile(path string) (contents []byte, err error)
+	// loadFileRange reads at most length bytes of the file at path starting at byte offset.
+	loadFileRange(path string, offset, length int64) (contents []byte, err error)
+	fileSize(path string) (size int64, err error)
 	loadFileAsync(path string, contents chan []byte, errs chan error, kill chan struct{}) (err error)
 	saveFile(path string, contents []byte) (err error)
 	saveFileAsync(path string, contents []byte, errs chan error, kill chan struct{}) (err error)
+	// saveFileLike writes contents to the new file path, giving it the mode and, where allowed, the
+	// owner of the existing file like.
+	saveFileLike(path, like string, contents []byte) (err error)
+	rename(path, newPath string) (err error)
+	remove(path string) (err error)
+	// mkdir creates the directory path. Its parent must exist.
+	mkdir(path string) (err error)
+	// rmdir removes the directory path, which must be empty.
+	rmdir(path string) (err error)
 	filenamesInDir(path string) (names []string, err error)
-	filenamesInDirAsync(path string, names chan []string, errs chan error, kill chan struct{}) (err error)
+	// filenamesInDirAsync sends the entries in the directory, along with the metadata used to sort them.
+	filenamesInDirAsync(path string, entries chan []DirEntry, errs chan error, kill chan struct{}) (err error)
 	exec(dir, cmd, arg string) (output []byte, err error)
 	//execAsync(dir, cmd, arg string, stdin []byte, contents chan []byte, errs chan error, kill chan struct{}) (err error)
 	execAsync(execCtx) (err error)
-	contentsAsync(path string, names chan []string, contents chan []byte, errs chan error, kill chan struct{}) (err error)
+	contentsAsync(path string, entries chan []DirEntry, contents chan []byte, errs chan error, kill chan struct{}) (err error)
 }
 
 type execCtx struct {
@@ -321,6 +368,22 @@ type execCtx struct {
 	extraEnv    []string
 	done        chan struct{}
 	shellString string
+	apiScopes   []string
+	// openStdin, if not nil and stdin is nil, keeps the stdin of the command open and is given
+	// the pipe that writes to it.
+	openStdin *jobStdin
+	// stderr, if not nil, is sent the standard error of the command, which is otherwise
+	// merged with the standard output into contents. It is closed with contents.
+	stderr chan []byte
+}
+
+// outputChans returns the channels that the standard output and standard error of the command
+// should be copied to in order for them to end up in contents, and stderr if it is set.
+func (c execCtx) outputChans(contents chan []byte) (stdout, stderr chan []byte) {
+	if c.stderr != nil {
+		return splitContentsInto(contents, c.stderr)
+	}
+	return mergeContentsInto(contents)
 }
 
 func (c execCtx) fullEnv() []string {
@@ -354,10 +417,36 @@ func (f localFs) isDirAsync(path string, kill chan struct{}) (ok bool, err error
 	return isDir(path)
 }
 
+func (f localFs) isWritable(path string) (ok bool, err error) {
+	return fileIsWritable(path)
+}
+
 func (f localFs) loadFile(path string) (contents []byte, err error) {
 	return ioutil.ReadFile(path)
 }
 
+func (f localFs) loadFileRange(path string, offset, length int64) (contents []byte, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	_, err = file.Seek(offset, io.SeekStart)
+	if err != nil {
+		return
+	}
+	return io.ReadAll(io.LimitReader(file, length))
+}
+
+func (f localFs) fileSize(path string) (size int64, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	return fi.Size(), nil
+}
+
 func (f localFs) loadFileAsync(path string, contents chan []byte, errs chan error, kill chan struct{}) (err error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -426,7 +515,99 @@ func copyBlocks(source io.Reader, dest chan []byte, blocksize int, errs chan err
 }
 
 func (f localFs) saveFile(path string, contents []byte) (err error) {
-	return ioutil.WriteFile(path, contents, 0664)
+	if !settings.General.AtomicSave {
+		return ioutil.WriteFile(path, contents, 0664)
+	}
+	return saveLocalFileAtomically(path, contents)
+}
+
+func (f localFs) saveFileLike(path, like string, contents []byte) (err error) {
+	fi, err := os.Stat(like)
+	if err != nil {
+		return
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, fi.Mode().Perm())
+	if err != nil {
+		return
+	}
+	defer func() {
+		if cerr := file.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	if _, err = file.Write(contents); err != nil {
+		return
+	}
+	// The mode given to OpenFile is reduced by the umask.
+	if err = file.Chmod(fi.Mode().Perm()); err != nil {
+		return
+	}
+	preserveOwner(file, fi)
+	return
+}
+
+// saveLocalFileAtomically saves the file by writing it to a temporary file in the same directory
+// and renaming that over the file, so that the file is never left truncated if the write is
+// interrupted. The mode and, where allowed, the ownership of the file are kept. New files, files
+// that aren't regular files and files in directories where a temporary file can't be created are
+// written directly.
+func saveLocalFileAtomically(path string, contents []byte) (err error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return ioutil.WriteFile(path, contents, 0664)
+	}
+
+	fi, err := os.Stat(target)
+	if err != nil || !fi.Mode().IsRegular() {
+		return ioutil.WriteFile(path, contents, 0664)
+	}
+
+	dir, name := filepath.Split(target)
+	tmp, err := ioutil.TempFile(dir, "."+name+".anvil-*")
+	if err != nil {
+		log(LogCatgFS, "saveLocalFileAtomically: can't create a temporary file for %s, writing it directly: %v\n", path, err)
+		return ioutil.WriteFile(path, contents, 0664)
+	}
+
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(contents); err != nil {
+		return
+	}
+	if err = tmp.Chmod(fi.Mode().Perm()); err != nil {
+		return
+	}
+	preserveOwner(tmp, fi)
+	if err = tmp.Sync(); err != nil {
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+func (f localFs) rename(path, newPath string) (err error) {
+	return os.Rename(path, newPath)
+}
+
+func (f localFs) remove(path string) (err error) {
+	return os.Remove(path)
+}
+
+func (f localFs) mkdir(path string) (err error) {
+	return os.Mkdir(path, 0775)
+}
+
+func (f localFs) rmdir(path string) (err error) {
+	return os.Remove(path)
 }
 
 func (f localFs) saveFileAsync(path string, contents []byte, errs chan error, kill chan struct{}) (err error) {
@@ -445,31 +626,31 @@ func (f localFs) filenamesInDir(path string) (names []string, err error) {
 	return filenamesInDir(path)
 }
 
-func (f localFs) filenamesInDirAsync(path string, names chan []string, errs chan error, kill chan struct{}) (err error) {
+func (f localFs) filenamesInDirAsync(path string, entries chan []DirEntry, errs chan error, kill chan struct{}) (err error) {
 	// TODO: make this more asynchronous for huge directories
 	go func() {
-		lnames, err := filenamesInDir(path)
+		lentries, err := entriesInDir(path)
 		if err != nil {
 			errs <- err
 			close(errs)
 			return
 		}
 
-		names <- lnames
-		close(names)
+		entries <- lentries
+		close(entries)
 		close(errs)
 	}()
 	return
 }
 
-func (f localFs) contentsAsync(path string, names chan []string, contents chan []byte, errs chan error, kill chan struct{}) (err error) {
+func (f localFs) contentsAsync(path string, entries chan []DirEntry, contents chan []byte, errs chan error, kill chan struct{}) (err error) {
 	isDir, err := f.isDir(path)
 	if err != nil {
 		return
 	}
 
 	if isDir {
-		err = f.filenamesInDirAsync(path, names, errs, kill)
+		err = f.filenamesInDirAsync(path, entries, errs, kill)
 	} else {
 		err = f.loadFileAsync(path, contents, errs, kill)
 	}
@@ -548,10 +729,18 @@ func (f localFs) setupForAsyncExec(c execCtx) (cmd *exec.Cmd, stdout, stderr io.
 		cmd = WindowsCmd(args)
 	} else {
 		cmd = exec.Command("bash", "-c", args)
+		startInOwnProcessGroup(cmd)
 	}
 
 	if c.stdin != nil {
 		cmd.Stdin = bytes.NewBuffer(c.stdin)
+	} else if c.openStdin != nil {
+		var pipe io.WriteCloser
+		pipe, err = cmd.StdinPipe()
+		if err != nil {
+			return
+		}
+		c.openStdin.setPipe(pipe)
 	}
 
 	stdout, err = cmd.StdoutPipe()
@@ -571,14 +760,14 @@ func (f localFs) setupForAsyncExec(c execCtx) (cmd *exec.Cmd, stdout, stderr io.
 	}
 	cmd.Env = append(cmd.Env, fmt.Sprintf("ANVIL_API_PORT=%d", LocalAPIPort()))
 
-	apiSess, err = createApiSession(args)
+	apiSess, err = createApiSession(args, c.apiScopes...)
 	if err != nil {
 		return
 	}
 	cmd.Env = append(cmd.Env, fmt.Sprintf("ANVIL_API_SESS=%s", apiSess.Id()))
 
 	c3, closed := signalWhenComplete(c.contents)
-	c1, c2 := mergeContentsInto(c3)
+	c1, c2 := c.outputChans(c3)
 
 	go copyBlocks(stdout, c1, 1024*1024, c.errs, nil)
 	go copyBlocks(stderr, c2, 1024*1024, c.errs, nil)
@@ -630,6 +819,38 @@ func mergeContentsInto(dest chan []byte) (c1, c2 chan []byte) {
 	return
 }
 
+// splitContentsInto is like mergeContentsInto except that what is sent on c1 is copied to
+// stdoutDest and what is sent on c2 to stderrDest. Both are closed once c1 and c2 are closed.
+func splitContentsInto(stdoutDest, stderrDest chan []byte) (c1, c2 chan []byte) {
+	c1 = make(chan []byte)
+	c2 = make(chan []byte)
+
+	go func() {
+		var eofs [2]bool
+		for !(eofs[0] && eofs[1]) {
+			select {
+			case b, ok := <-c1:
+				if !ok {
+					eofs[0] = true
+					c1 = nil
+					continue
+				}
+				stdoutDest <- b
+			case b, ok := <-c2:
+				if !ok {
+					eofs[1] = true
+					c2 = nil
+					continue
+				}
+				stderrDest <- b
+			}
+		}
+		close(stderrDest)
+		close(stdoutDest)
+	}()
+	return
+}
+
 // signalWhenComplete copies src to dest, and closes sig when src is closed
 func signalWhenComplete(dest chan []byte) (src chan []byte, sig chan struct{}) {
 	src = make(chan []byte)
@@ -663,20 +884,25 @@ func isRemoteFilenameOrDir(path string) (b bool, err error) {
 }
 
 type sshFs struct {
-	shell      string
-	closeStdin bool
+	shell       string
+	closeStdin  bool
+	shellString string
 }
 
 func NewSshFs(opts sshFsOpts) *sshFs {
 	return &sshFs{
-		shell:      opts.shell,
-		closeStdin: opts.closeStdin,
+		shell:       opts.shell,
+		closeStdin:  opts.closeStdin,
+		shellString: opts.shellString,
 	}
 }
 
 type sshFsOpts struct {
 	shell      string
 	closeStdin bool
+	// shellString is used to build commands that are run when the window doesn't have a
+	// shell string set using Shstr.
+	shellString string
 }
 
 func (f *sshFs) getShell() string {
@@ -686,6 +912,13 @@ func (f *sshFs) getShell() string {
 	return f.shell
 }
 
+// shellCommand returns the command that runs script with the remote shell. ssh passes the command
+// to the login shell of the user, so the script is quoted to reach the remote shell as one
+// argument. Paths in the script must be quoted with shellQuote.
+func (f *sshFs) shellCommand(script string) string {
+	return fmt.Sprintf("%s -c %s", f.getShell(), shellQuote(script))
+}
+
 func (f *sshFs) fileExists(path string) (ok bool, err error) {
 	file, session, _, err := f.splitFilenameAndMakeSession(path, nil)
 	if err != nil {
@@ -695,7 +928,7 @@ func (f *sshFs) fileExists(path string) (ok bool, err error) {
 
 	log(LogCatgFS, "sshFs.fileExists: checking for %s\n", path)
 
-	cmd := fmt.Sprintf("%s -c 'if [ -e \"%s\" ]; then echo yes; else echo no; fi'", f.getShell(), file)
+	cmd := f.shellCommand(fmt.Sprintf("if [ -e %s ]; then echo yes; else echo no; fi", shellQuote(file)))
 	log(LogCatgFS, "sshFs.fileExists: running command: %s\n", cmd)
 	b, err := session.Output(cmd)
 	if err != nil {
@@ -719,7 +952,7 @@ func (f *sshFs) isDirAsync(path string, kill chan struct{}) (ok bool, err error)
 	}
 	defer session.Close()
 
-	cmd := fmt.Sprintf("%s -c 'if [ -d \"%s\" ]; then echo yes; else echo no; fi'", f.getShell(), file)
+	cmd := f.shellCommand(fmt.Sprintf("if [ -d %s ]; then echo yes; else echo no; fi", shellQuote(file)))
 	log(LogCatgFS, "sshFs.isDirAsync: running command: %s\n", cmd)
 	b, err := session.Output(cmd)
 	if err != nil {
@@ -759,7 +992,12 @@ func (f *sshFs) splitFilenameAndDial(path string, kill chan struct{}) (client *S
 	log(LogCatgFS, "sshFs: split path %s into %#v\n", path, gpath)
 	file = gpath.Path()
 
-	endpt := SshEndpt{
+	client, err = f.dial(sshEndptOf(gpath), kill)
+	return
+}
+
+func sshEndptOf(gpath *GlobalPath) SshEndpt {
+	return SshEndpt{
 		Dest: SshHop{
 			User: gpath.User(),
 			Host: gpath.Host(),
@@ -771,8 +1009,6 @@ func (f *sshFs) splitFilenameAndDial(path string, kill chan struct{}) (client *S
 			Port: gpath.ProxyPort(),
 		},
 	}
-	client, err = f.dial(endpt, kill)
-	return
 }
 
 func (f *sshFs) dial(endpt SshEndpt, kill chan struct{}) (client *SshClient, err error) {
@@ -781,6 +1017,51 @@ func (f *sshFs) dial(endpt SshEndpt, kill chan struct{}) (client *SshClient, err
 	return
 }
 
+func (f *sshFs) isWritable(path string) (ok bool, err error) {
+	file, session, _, err := f.splitFilenameAndMakeSession(path, nil)
+	if err != nil {
+		return
+	}
+	defer session.Close()
+
+	cmd := f.shellCommand(fmt.Sprintf(`f=%s; if [ ! -e "$f" ]; then f=$(dirname "$f"); fi; if [ -w "$f" ]; then echo yes; else echo no; fi`, shellQuote(file)))
+	log(LogCatgFS, "sshFs.isWritable: running command: %s\n", cmd)
+	b, err := session.Output(cmd)
+	if err != nil {
+		return
+	}
+
+	ok = string(b) == "yes\n"
+	return
+}
+
+func (f *sshFs) loadFileRange(path string, offset, length int64) (contents []byte, err error) {
+	file, session, _, err := f.splitFilenameAndMakeSession(path, nil)
+	if err != nil {
+		return
+	}
+	defer session.Close()
+
+	cmd := f.shellCommand(fmt.Sprintf("tail -c +%d %s | head -c %d", offset+1, shellQuote(file), length))
+	log(LogCatgFS, "sshFs.loadFileRange: running command: %s\n", cmd)
+	return session.Output(cmd)
+}
+
+func (f *sshFs) fileSize(path string) (size int64, err error) {
+	file, session, _, err := f.splitFilenameAndMakeSession(path, nil)
+	if err != nil {
+		return
+	}
+	defer session.Close()
+
+	cmd := f.shellCommand("wc -c < " + shellQuote(file))
+	b, err := session.Output(cmd)
+	if err != nil {
+		return
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(b)), 10, 64)
+}
+
 func (f *sshFs) loadFile(path string) (contents []byte, err error) {
 	file, session, _, err := f.splitFilenameAndMakeSession(path, nil)
 	if err != nil {
@@ -788,8 +1069,7 @@ func (f *sshFs) loadFile(path string) (contents []byte, err error) {
 	}
 	defer session.Close()
 
-	// sh -c 'if [ -d /tmp ]; then echo yes; else echo no; fi'
-	cmd := fmt.Sprintf("%s -c 'cat \"%s\"'", f.getShell(), file)
+	cmd := f.shellCommand("cat " + shellQuote(file))
 	contents, err = session.Output(cmd)
 	if err != nil {
 		return
@@ -806,9 +1086,7 @@ func (f *sshFs) loadFileAsync(path string, contents chan []byte, errs chan error
 			return
 		}
 
-		cmd := fmt.Sprintf("%s -c 'cat \"%s\"'", f.getShell(), file)
-		// Ignore errors?
-		//cmd := fmt.Sprintf("%s -c 'cat \"%s\" 2>/dev/null'", f.getShell(), file)
+		cmd := f.shellCommand("cat " + shellQuote(file))
 
 		stdout, err := session.StdoutPipe()
 		if err != nil {
@@ -833,20 +1111,38 @@ func (f *sshFs) loadFileAsync(path string, contents chan []byte, errs chan error
 }
 
 func (f *sshFs) saveFile(path string, contents []byte) (err error) {
+	return f.runWithStdin(path, f.saveCommand, contents)
+}
+
+func (f *sshFs) saveFileLike(path, like string, contents []byte) (err error) {
+	likeGpath, err := NewGlobalPath(like, GlobalPathUnknown)
+	if err != nil {
+		return
+	}
+
+	cmd := func(file string) string {
+		return f.shellCommand(fmt.Sprintf("cp -p %s %s && cat > %s", shellQuote(likeGpath.Path()), shellQuote(file), shellQuote(file)))
+	}
+	return f.runWithStdin(path, cmd, contents)
+}
+
+// runWithStdin runs the command returned by cmd for the file path on the remote host, with
+// contents as its stdin.
+func (f *sshFs) runWithStdin(path string, cmd func(file string) string, contents []byte) (err error) {
 	file, session, _, err := f.splitFilenameAndMakeSession(path, nil)
 	if err != nil {
 		return
 	}
 	defer session.Close()
 
-	// sh -c 'if [ -d /tmp ]; then echo yes; else echo no; fi'
-	cmd := fmt.Sprintf("%s -c 'cat > \"%s\"'", f.getShell(), file)
+	c := cmd(file)
+	log(LogCatgFS, "sshFs.runWithStdin: running command: %s\n", c)
 	pipe, err := session.StdinPipe()
 	if err != nil {
 		return
 	}
 
-	err = session.Start(cmd)
+	err = session.Start(c)
 	if err != nil {
 		return
 	}
@@ -862,6 +1158,86 @@ func (f *sshFs) saveFile(path string, contents []byte) (err error) {
 	return
 }
 
+// saveCommand returns the command that writes its stdin to file on the remote host. When
+// atomic-save is set the stdin is written to a copy of the file that keeps its mode and owner, and
+// the copy is moved over the file. Symbolic links, new files, and files in directories where the
+// copy can't be made are written directly.
+func (f *sshFs) saveCommand(file string) string {
+	if !settings.General.AtomicSave {
+		return f.shellCommand("cat > " + shellQuote(file))
+	}
+
+	script := `f=%s; t="$(dirname "$f")/.$(basename "$f").anvil-$$"; ` +
+		`if [ -f "$f" ] && [ ! -L "$f" ] && cp -p "$f" "$t" 2>/dev/null; then ` +
+		`if cat > "$t"; then mv -f "$t" "$f"; else rm -f "$t"; exit 1; fi; ` +
+		`else rm -f "$t"; cat > "$f"; fi`
+	return f.shellCommand(fmt.Sprintf(script, shellQuote(file)))
+}
+
+// rename renames the file path to newPath, which must be on the same host.
+func (f *sshFs) rename(path, newPath string) (err error) {
+	file, session, _, err := f.splitFilenameAndMakeSession(path, nil)
+	if err != nil {
+		return
+	}
+	defer session.Close()
+
+	newGpath, err := NewGlobalPath(newPath, GlobalPathUnknown)
+	if err != nil {
+		return
+	}
+
+	cmd := f.shellCommand(fmt.Sprintf("mv -f %s %s", shellQuote(file), shellQuote(newGpath.Path())))
+	log(LogCatgFS, "sshFs.rename: running command: %s\n", cmd)
+	out, err := session.CombinedOutput(cmd)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return
+}
+
+func (f *sshFs) remove(path string) (err error) {
+	file, session, _, err := f.splitFilenameAndMakeSession(path, nil)
+	if err != nil {
+		return
+	}
+	defer session.Close()
+
+	cmd := f.shellCommand("rm -f " + shellQuote(file))
+	log(LogCatgFS, "sshFs.remove: running command: %s\n", cmd)
+	out, err := session.CombinedOutput(cmd)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return
+}
+
+func (f *sshFs) mkdir(path string) (err error) {
+	return f.runOnFile("mkdir", path)
+}
+
+func (f *sshFs) rmdir(path string) (err error) {
+	return f.runOnFile("rmdir", path)
+}
+
+// runOnFile runs the shell command cmd with the file path as its argument on the remote host.
+// If it fails the error includes what it printed.
+func (f *sshFs) runOnFile(cmd, path string) (err error) {
+	file, session, _, err := f.splitFilenameAndMakeSession(path, nil)
+	if err != nil {
+		return
+	}
+	defer session.Close()
+
+	cmd = f.shellCommand(cmd + " " + shellQuote(file))
+	log(LogCatgFS, "sshFs.runOnFile: running command: %s\n", cmd)
+	out, err := session.CombinedOutput(cmd)
+	if err != nil {
+		err = fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return
+}
+
 func (f sshFs) saveFileAsync(path string, contents []byte, errs chan error, kill chan struct{}) (err error) {
 	//return fmt.Errorf("Not implemented yet")
 	go func() {
@@ -872,7 +1248,7 @@ func (f sshFs) saveFileAsync(path string, contents []byte, errs chan error, kill
 			return
 		}
 
-		cmd := fmt.Sprintf("%s -c 'cat > \"%s\"'", f.getShell(), file)
+		cmd := f.saveCommand(file)
 		log(LogCatgFS, "sshFs.saveFileAsync: running command: %s\n", cmd)
 
 		pipe, err := session.StdinPipe()
@@ -928,7 +1304,7 @@ func (f *sshFs) filenamesInDir(path string) (names []string, err error) {
 	}
 	defer session.Close()
 
-	cmd := fmt.Sprintf("%s -c 'ls -Ap \"%s\" | cat'", f.getShell(), file)
+	cmd := f.shellCommand("ls -Ap " + shellQuote(file) + " | cat")
 	b, err := session.Output(cmd)
 	if err != nil {
 		return
@@ -939,7 +1315,7 @@ func (f *sshFs) filenamesInDir(path string) (names []string, err error) {
 	return
 }
 
-func (f *sshFs) filenamesInDirAsync(path string, names chan []string, errs chan error, kill chan struct{}) (err error) {
+func (f *sshFs) filenamesInDirAsync(path string, entries chan []DirEntry, errs chan error, kill chan struct{}) (err error) {
 	file, session, _, err := f.splitFilenameAndMakeSession(path, kill)
 	if err != nil {
 		return
@@ -947,25 +1323,27 @@ func (f *sshFs) filenamesInDirAsync(path string, names chan []string, errs chan
 
 	// TODO: make this more asynchronous for huge directories
 	go func() {
-		cmd := fmt.Sprintf("%s -c 'ls -Ap \"%s\" | cat'", f.getShell(), file)
+		// GNU find can print the type, size and modification time of each entry. Other systems
+		// fall back to ls, and the entries can then only be sorted by name.
+		cmd := f.shellCommand(fmt.Sprintf(`find -L %s -mindepth 1 -maxdepth 1 -printf "%%y %%s %%T@ %%f\n" 2>/dev/null || ls -Ap %s | sed "s/^/? 0 0 /"`,
+			shellQuote(file), shellQuote(file)))
 		b, err := session.Output(cmd)
 		if err != nil {
 			errs <- err
-			close(names)
+			close(entries)
 			close(errs)
 			return
 		}
-		lnames := strings.Split(string(b), "\n")
-		names <- lnames
+		entries <- parseRemoteDirEntries(b)
 		session.Close()
-		close(names)
+		close(entries)
 		close(errs)
 	}()
 
 	return
 }
 
-func (f *sshFs) contentsAsync(path string, names chan []string, contents chan []byte, errs chan error, kill chan struct{}) (err error) {
+func (f *sshFs) contentsAsync(path string, entries chan []DirEntry, contents chan []byte, errs chan error, kill chan struct{}) (err error) {
 	go func() {
 		isDir, err := f.isDirAsync(path, kill)
 		if err != nil {
@@ -974,7 +1352,7 @@ func (f *sshFs) contentsAsync(path string, names chan []string, contents chan []
 		}
 
 		if isDir {
-			err = f.filenamesInDirAsync(path, names, errs, kill)
+			err = f.filenamesInDirAsync(path, entries, errs, kill)
 		} else {
 			err = f.loadFileAsync(path, contents, errs, kill)
 		}
@@ -996,7 +1374,7 @@ func (f sshFs) exec(path, command, arg string) (output []byte, err error) {
 	}
 	defer session.Close()
 
-	cmd := fmt.Sprintf("%s -c 'cd \"%s\" && %s %s'", f.getShell(), dir, command, arg)
+	cmd := f.shellCommand(fmt.Sprintf("cd %s && %s %s", shellQuote(dir), command, arg))
 	log(LogCatgFS, "sshFs.exec: running command: %s\n", cmd)
 	output, err = session.Output(cmd)
 	return
@@ -1006,12 +1384,18 @@ func (f sshFs) execAsync(c execCtx) (err error) {
 	go func() {
 		session, cmd, apiSess, ok := f.setupForAsyncExec(c)
 		if !ok {
+			// Nothing reads the output, so end it here so that the job using it finishes.
+			close(c.contents)
+			closeAsyncExecErrs(c)
 			return
 		}
 
 		err = session.Start(cmd)
 		if err != nil {
 			c.errs <- err
+			// Closing the session ends the output.
+			session.Close()
+			closeAsyncExecErrs(c)
 			if apiSess != nil {
 				deleteApiSession(apiSess.Id())
 			}
@@ -1019,12 +1403,24 @@ func (f sshFs) execAsync(c execCtx) (err error) {
 		}
 
 		forceClosedSession := newSemchan()
+		finished := make(chan struct{})
 
 		go func() {
-			<-c.kill
+			select {
+			case <-c.kill:
+			case <-finished:
+				return
+			}
 			log(LogCatgFS, "sshFs.exec: kill received. Closing session\n")
-			// See https://github.com/golang/go/issues/16597
-			session.Signal(ssh.SIGKILL)
+			// See https://github.com/golang/go/issues/16597. anvsshd kills the process group
+			// of the command when it receives the signal.
+			err := session.Signal(ssh.SIGKILL)
+			if err != nil {
+				log(LogCatgFS, "sshFs.exec: requesting the remote process be killed failed: %v\n", err)
+				forceClosedSession.write()
+				session.Close()
+				return
+			}
 			go func() {
 				time.Sleep(500 * time.Millisecond)
 				forceClosedSession.write()
@@ -1035,20 +1431,18 @@ func (f sshFs) execAsync(c execCtx) (err error) {
 		go func() {
 			log(LogCatgFS, "sshFs.exec: kill: waiting for status\n")
 			err := session.Wait()
+			close(finished)
 			log(LogCatgFS, "sshFs.exec: kill: wait done\n")
 			if err != nil {
 				if forceClosedSession.wasWrittenTo() {
-					err = fmt.Errorf("killed process, but got no response")
+					err = errKilledWithoutResponse
 				}
 
 				log(LogCatgFS, "sshFs.exec: wait error: %v\n", err)
 				log(LogCatgFS, "sshFs.exec: sending error on chan\n")
 				c.errs <- err
 			}
-			close(c.errs)
-			if c.done != nil {
-				close(c.done)
-			}
+			closeAsyncExecErrs(c)
 			if apiSess != nil {
 				deleteApiSession(apiSess.Id())
 			}
@@ -1059,6 +1453,14 @@ func (f sshFs) execAsync(c execCtx) (err error) {
 	return
 }
 
+// closeAsyncExecErrs closes the errors channel of c, and signals that the command is done.
+func closeAsyncExecErrs(c execCtx) {
+	close(c.errs)
+	if c.done != nil {
+		close(c.done)
+	}
+}
+
 type semchan chan struct{}
 
 func newSemchan() semchan {
@@ -1087,17 +1489,32 @@ func (f sshFs) setupForAsyncExec(c execCtx) (session *ssh.Session, cmd string, a
 		c.errs <- err
 		return
 	}
+	defer func() {
+		if !ok {
+			session.Close()
+		}
+	}()
 
 	extra := ""
-	if c.stdin == nil && f.closeStdin {
+	if c.stdin == nil && c.openStdin == nil && f.closeStdin {
 		extra = " 0<&-" // shell command to close stdin
 	}
 
+	if c.shellString == "" {
+		c.shellString = f.shellString
+	}
 	cmd = buildShellString(c, f.getShell(), dir, extra)
 	log(LogCatgFS, "sshFs.exec: running command: %s\n", cmd)
 
 	if c.stdin != nil {
 		session.Stdin = bytes.NewBuffer(c.stdin)
+	} else if c.openStdin != nil {
+		pipe, err := session.StdinPipe()
+		if err != nil {
+			c.errs <- err
+			return
+		}
+		c.openStdin.setPipe(pipe)
 	}
 
 	stdout, err := session.StdoutPipe()
@@ -1119,13 +1536,10 @@ func (f sshFs) setupForAsyncExec(c execCtx) (session *ssh.Session, cmd string, a
 		log(LogCatgFS, "%v\n", err)
 	}
 
-	apiSess, err = createApiSession(fmt.Sprintf("%s %s", c.cmd, c.arg))
+	apiSess, err = createApiSession(fmt.Sprintf("%s %s", c.cmd, c.arg), c.apiScopes...)
 	if err != nil {
 		log(LogCatgFS, "%v\n", err)
 	}
-	session.Setenv("ANVIL_API_PORT", strconv.Itoa(client.ListenerPort()))
-	session.Setenv("ANVIL_API_SESS", string(apiSess.Id()))
-
 	if c.extraEnv != nil {
 		names, values, err := c.extraEnvNamesAndValues()
 		if err != nil {
@@ -1143,7 +1557,13 @@ func (f sshFs) setupForAsyncExec(c execCtx) (session *ssh.Session, cmd string, a
 
 	}
 
-	c1, c2 := mergeContentsInto(c.contents)
+	// These are set after the extra environment so that they refer to the listener
+	// forwarded over the connection actually being used, even if the [env] settings
+	// set them too.
+	session.Setenv("ANVIL_API_PORT", strconv.Itoa(client.ListenerPort()))
+	session.Setenv("ANVIL_API_SESS", string(apiSess.Id()))
+
+	c1, c2 := c.outputChans(c.contents)
 
 	go copyBlocks(stdout, c1, 4096, c.errs, nil)
 	go copyBlocks(stderr, c2, 4096, c.errs, nil)
@@ -1221,25 +1641,43 @@ func isDir(path string) (ok bool, err error) {
 }
 
 func filenamesInDir(path string) (names []string, err error) {
+	entries, err := entriesInDir(path)
+	if err != nil {
+		return
+	}
 
-	entries, err := os.ReadDir(path)
+	names = make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name
+	}
+	return
+}
+
+func entriesInDir(path string) (entries []DirEntry, err error) {
+
+	dirEntries, err := os.ReadDir(path)
 	if err != nil {
 		return
 	}
 
-	names = make([]string, 0, len(entries))
-	for _, e := range entries {
+	entries = make([]DirEntry, 0, len(dirEntries))
+	for _, e := range dirEntries {
 		n := e.Name()
 		if n == "." || n == ".." {
 			continue
 		}
 
+		entry := DirEntry{Name: n}
 		fi, err := os.Stat(filepath.Join(path, e.Name()))
-		if err == nil && fi.IsDir() {
-			n = fmt.Sprintf("%s%c", n, filepath.Separator)
+		if err == nil {
+			if fi.IsDir() {
+				entry.Name = fmt.Sprintf("%s%c", n, filepath.Separator)
+			}
+			entry.Size = fi.Size()
+			entry.ModTime = fi.ModTime()
 		}
 
-		names = append(names, n)
+		entries = append(entries, entry)
 	}
 
 	return
@@ -3,12 +3,44 @@ package main
 import (
 	"os"
 	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/unix"
 )
 
 func WindowsCmd(arg string) *exec.Cmd {
 	return nil
 }
 
+// startInOwnProcessGroup makes cmd start a new process group, so that KillProcess also kills
+// the processes it starts.
+func startInOwnProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// KillProcess kills the process group of p, which was started using startInOwnProcessGroup.
 func KillProcess(p *os.Process) error {
-	return p.Kill()
+	err := syscall.Kill(-p.Pid, syscall.SIGKILL)
+	if err != nil {
+		return p.Kill()
+	}
+	return nil
+}
+
+func localFileIsWritable(path string) (ok bool, err error) {
+	err = unix.Access(path, unix.W_OK)
+	if err == unix.EACCES || err == unix.EROFS {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// preserveOwner gives f the owner and group in fi. Changing the owner usually needs privileges,
+// so failing is not an error.
+func preserveOwner(f *os.File, fi os.FileInfo) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	f.Chown(int(st.Uid), int(st.Gid))
 }
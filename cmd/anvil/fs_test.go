@@ -1,6 +1,25 @@
 package main
 
-import "testing"
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	toml "github.com/pelletier/go-toml"
+	"golang.org/x/crypto/ssh"
+)
 
 func TestGlobalPath(t *testing.T) {
 
@@ -236,3 +255,387 @@ func TestGlobalPathMakeAbsoluteRelativeTo(t *testing.T) {
 		})
 	}
 }
+
+func TestSshHostSettings(t *testing.T) {
+	old := settings.Ssh
+	defer func() { settings.Ssh = old }()
+
+	s := Settings{Ssh: settings.Ssh}
+	err := toml.NewDecoder(strings.NewReader(`
+[ssh]
+shell="sh"
+
+[ssh.hosts."build"]
+shell="bash -l"
+shell-string="env -i sh -c $'cd \"{Dir}\" && {Cmd} {Args}'"
+env={GOFLAGS="-mod=vendor"}
+user="builder"
+port=2222
+`)).Decode(&s)
+	if err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	settings.Ssh = s.Ssh
+
+	opts := sshOptsFor("build")
+	if opts.shell != "bash -l" || !strings.HasPrefix(opts.shellString, "env -i sh") {
+		t.Fatalf("expected the host's shell and shell string but got %#v", opts)
+	}
+	if opts = sshOptsFor("other"); opts.shell != "sh" || opts.shellString != "" {
+		t.Fatalf("expected the global shell for a host without settings but got %#v", opts)
+	}
+
+	var cache SshClientCache
+	if h := cache.completeHop(SshHop{Host: "build"}); h.User != "builder" || h.Port != "2222" {
+		t.Fatalf("expected the host's user and port but got %v", h)
+	}
+	if h := cache.completeHop(SshHop{User: "me", Host: "build", Port: "22"}); h.User != "me" || h.Port != "22" {
+		t.Fatalf("expected the user and port of the path to win but got %v", h)
+	}
+
+	var ec execCtx
+	ec.dir = "build:/src/"
+	CommandExecutor{}.setExtraEnv(&CmdContext{Dir: ec.dir}, &ec)
+	if ec.extraEnv[len(ec.extraEnv)-1] != "GOFLAGS=-mod=vendor" {
+		t.Fatalf("expected the host's environment last but got %v", ec.extraEnv)
+	}
+}
+
+func TestAtomicSaveKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file modes are not kept on Windows")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.sh")
+	err := ioutil.WriteFile(path, []byte("echo old\n"), 0750)
+	if err != nil {
+		t.Fatalf("writing failed: %v", err)
+	}
+	link := filepath.Join(dir, "link.sh")
+	if err = os.Symlink(path, link); err != nil {
+		t.Fatalf("making a link failed: %v", err)
+	}
+
+	err = localFs{}.saveFile(link, []byte("echo new\n"))
+	if err != nil {
+		t.Fatalf("saving failed: %v", err)
+	}
+
+	if b, _ := ioutil.ReadFile(path); string(b) != "echo new\n" {
+		t.Fatalf("expected the file the link points to to be saved but it contains %q", b)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected the link to be kept")
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0750 {
+		t.Fatalf("expected the mode to be kept but it is %v", fi.Mode())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+		t.Fatalf("expected the temporary file to be removed but the directory has %d entries", len(entries))
+	}
+}
+
+func TestAtomicSaveFallsBackToWritingDirectly(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("directory permissions don't stop the user from creating files")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	err := ioutil.WriteFile(path, []byte("old"), 0640)
+	if err != nil {
+		t.Fatalf("writing failed: %v", err)
+	}
+	os.Chmod(dir, 0500)
+	defer os.Chmod(dir, 0700)
+
+	err = localFs{}.saveFile(path, []byte("new"))
+	if err != nil {
+		t.Fatalf("saving failed: %v", err)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "new" {
+		t.Fatalf("expected the file to be written directly but it contains %q", b)
+	}
+}
+
+func TestRemoteSaveCommandKeepsMode(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil || runtime.GOOS == "windows" {
+		t.Skip("the command needs a POSIX shell")
+	}
+
+	// The command run on the remote host is run locally here.
+	save := func(path, contents string) {
+		f := &sshFs{}
+		cmd := exec.Command("sh", "-c", f.saveCommand(path))
+		cmd.Stdin = strings.NewReader(contents)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("saving failed: %v: %s", err, out)
+		}
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "script.sh")
+	err := ioutil.WriteFile(path, []byte("echo old\n"), 0750)
+	if err != nil {
+		t.Fatalf("writing failed: %v", err)
+	}
+
+	save(path, "echo new\n")
+	if b, _ := ioutil.ReadFile(path); string(b) != "echo new\n" {
+		t.Fatalf("expected the file to be saved but it contains %q", b)
+	}
+	if fi, _ := os.Stat(path); fi.Mode().Perm() != 0750 {
+		t.Fatalf("expected the mode to be kept but it is %v", fi.Mode())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("expected the temporary file to be removed but the directory has %d entries", len(entries))
+	}
+
+	newPath := filepath.Join(dir, "new.txt")
+	save(newPath, "new")
+	if b, _ := ioutil.ReadFile(newPath); string(b) != "new" {
+		t.Fatalf("expected a new file to be written but it contains %q", b)
+	}
+}
+
+func TestRemoteCommandsQuotePaths(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil || runtime.GOOS == "windows" {
+		t.Skip("the command needs a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, `it's "$HOME" `+"`date`")
+	f := &sshFs{}
+
+	// The command run on the remote host is run locally here.
+	cmd := exec.Command("sh", "-c", f.saveCommand(path))
+	cmd.Stdin = strings.NewReader("saved")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("saving failed: %v: %s", err, out)
+	}
+	if b, _ := ioutil.ReadFile(path); string(b) != "saved" {
+		t.Fatalf("expected the file to be saved but it contains %q", b)
+	}
+
+	out, err := exec.Command("sh", "-c", f.shellCommand("cat "+shellQuote(path))).CombinedOutput()
+	if err != nil || string(out) != "saved" {
+		t.Fatalf("expected the file to be read but got %q, %v", out, err)
+	}
+}
+
+func TestSshEndpointsOrderedByHost(t *testing.T) {
+	endpts := []SshEndpt{
+		{Dest: SshHop{User: "bob", Host: "zeta", Port: "22"}},
+		{Dest: SshHop{User: "bob", Host: "alpha", Port: "2222"}},
+		{Dest: SshHop{User: "bob", Host: "alpha", Port: "22"}, Proxy: SshHop{User: "al", Host: "gw", Port: "22"}},
+		{Dest: SshHop{User: "amy", Host: "alpha", Port: "22"}},
+		{Dest: SshHop{User: "bob", Host: "alpha", Port: "22"}},
+	}
+	sort.Slice(endpts, func(i, j int) bool {
+		return sshEndptLess(endpts[i], endpts[j])
+	})
+
+	var got []string
+	for _, e := range endpts {
+		got = append(got, e.String())
+	}
+
+	expected := []string{
+		"amy@alpha:22",
+		"bob@alpha:22",
+		"bob@alpha:22%al@gw:22",
+		"bob@alpha:2222",
+		"bob@zeta:22",
+	}
+	if strings.Join(got, " ") != strings.Join(expected, " ") {
+		t.Fatalf("expected %v but got %v", expected, got)
+	}
+}
+
+func TestSshClientCacheDrop(t *testing.T) {
+	cache := NewSshClientCache(5)
+	endpts := []SshEndpt{
+		{Dest: SshHop{User: "bob", Host: "alpha", Port: "22"}},
+		{Dest: SshHop{User: "bob", Host: "beta", Port: "22"}, Proxy: SshHop{User: "bob", Host: "alpha", Port: "22"}},
+		{Dest: SshHop{User: "bob", Host: "gamma", Port: "22"}},
+	}
+	for _, k := range endpts {
+		cache.data[k] = SshClientCacheEntry{client: newSshClient(nil, k)}
+	}
+
+	dropped := cache.Drop("alpha")
+	if len(dropped) != 2 || dropped[0] != endpts[0] || dropped[1] != endpts[1] {
+		t.Fatalf("expected the connections to and through alpha to be dropped but got %v", dropped)
+	}
+	if keys := cache.Keys(); len(keys) != 1 || keys[0] != endpts[2] {
+		t.Fatalf("expected only the connection to gamma to remain but got %v", keys)
+	}
+
+	if dropped := cache.Drop(""); len(dropped) != 1 || len(cache.Keys()) != 0 {
+		t.Fatalf("expected all connections to be dropped but got %v", dropped)
+	}
+}
+
+func TestBrokenSshConnectionIsReplaced(t *testing.T) {
+	oldConfDir, oldCache := ConfDir, sshClientCache
+	t.Cleanup(func() { ConfDir, sshClientCache = oldConfDir, oldCache })
+	ConfDir = t.TempDir()
+
+	endpt := SshEndpt{Dest: SshHop{User: "bob", Host: "127.0.0.1", Port: "1"}}
+	broken := newSshClient(nil, endpt)
+	broken.markBroken(errors.New("no reply to keepalive"))
+	if !broken.Broken() || !strings.HasPrefix(broken.State(), "broken") {
+		t.Fatalf("expected the connection to be broken but its state is %q", broken.State())
+	}
+
+	sshClientCache = NewSshClientCache(5)
+	sshClientCache.data[endpt] = SshClientCacheEntry{client: broken}
+
+	// The broken connection is not returned; a new one is made, which fails here since nothing
+	// listens on the port.
+	client, err := sshClientCache.Get(endpt, nil)
+	if err == nil || client == broken {
+		t.Fatalf("expected a new connection to be attempted instead of using the broken one")
+	}
+	if len(sshClientCache.Keys()) != 0 {
+		t.Fatalf("expected the broken connection to be removed from the cache")
+	}
+}
+
+func TestRemoteCommandApiPortIsForwardedPort(t *testing.T) {
+	oldConfDir, oldCache := ConfDir, sshClientCache
+	t.Cleanup(func() { ConfDir, sshClientCache = oldConfDir, oldCache })
+	ConfDir = t.TempDir()
+
+	const forwardedPort = 4242
+	addr, envs := startTestSshServer(t, forwardedPort)
+
+	c, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            "bob",
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("dialing the server failed: %v", err)
+	}
+
+	host, port, _ := net.SplitHostPort(addr)
+	dir := fmt.Sprintf("bob@%s:%s:/tmp", host, port)
+	gpath, err := NewGlobalPath(dir, GlobalPathIsDir)
+	if err != nil {
+		t.Fatalf("parsing the remote directory failed: %v", err)
+	}
+	endpt := sshEndptOf(gpath)
+	sshClientCache = NewSshClientCache(5)
+	sshClientCache.data[endpt] = SshClientCacheEntry{client: newSshClient(c, endpt), lastUsed: time.Now()}
+	t.Cleanup(func() { c.Close() })
+
+	ex := execCtx{
+		dir:      dir,
+		cmd:      "true",
+		contents: make(chan []byte),
+		errs:     make(chan error),
+	}
+	CommandExecutor{}.setExtraEnv(&CmdContext{Dir: dir}, &ex)
+
+	err = sshFs{}.execAsync(ex)
+	if err != nil {
+		t.Fatalf("executing the command failed: %v", err)
+	}
+	go func() {
+		for range ex.contents {
+		}
+	}()
+	for err := range ex.errs {
+		t.Fatalf("the command failed: %v", err)
+	}
+
+	env := <-envs
+	if env["ANVIL_API_PORT"] != strconv.Itoa(forwardedPort) {
+		t.Fatalf("expected the remote ANVIL_API_PORT to be the forwarded port %d but it is %q", forwardedPort, env["ANVIL_API_PORT"])
+	}
+	if env["ANVIL_API_SESS"] == "" {
+		t.Fatalf("expected the remote ANVIL_API_SESS to be set")
+	}
+}
+
+// startTestSshServer starts an ssh server that accepts any client, reports apiPort as the
+// port of every forwarded listener, and sends the environment of each command executed on
+// envs instead of executing it.
+func startTestSshServer(t *testing.T, apiPort uint32) (addr string, envs chan map[string]string) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating host key failed: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("creating host key signer failed: %v", err)
+	}
+	config := &ssh.ServerConfig{NoClientAuth: true}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	envs = make(chan map[string]string, 10)
+
+	serveSession := func(ch ssh.Channel, reqs <-chan *ssh.Request) {
+		env := map[string]string{}
+		for r := range reqs {
+			switch r.Type {
+			case "env":
+				var kv struct{ Name, Value string }
+				ssh.Unmarshal(r.Payload, &kv)
+				env[kv.Name] = kv.Value
+				r.Reply(true, nil)
+			case "exec":
+				r.Reply(true, nil)
+				envs <- env
+				ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{0}))
+				ch.Close()
+			default:
+				r.Reply(false, nil)
+			}
+		}
+	}
+
+	go func() {
+		for {
+			nConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, chans, reqs, err := ssh.NewServerConn(nConn, config)
+				if err != nil {
+					return
+				}
+				go func() {
+					for r := range reqs {
+						if r.Type == "tcpip-forward" {
+							r.Reply(true, ssh.Marshal(struct{ Port uint32 }{apiPort}))
+							continue
+						}
+						r.Reply(false, nil)
+					}
+				}()
+				for newCh := range chans {
+					if newCh.ChannelType() != "session" {
+						newCh.Reject(ssh.UnknownChannelType, "")
+						continue
+					}
+					ch, reqs, err := newCh.Accept()
+					if err != nil {
+						continue
+					}
+					go serveSession(ch, reqs)
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String(), envs
+}
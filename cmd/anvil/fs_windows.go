@@ -19,6 +19,11 @@ func WindowsCmd(arg string) *exec.Cmd {
 	return cmd
 }
 
+// startInOwnProcessGroup does nothing on Windows, where KillProcess kills the processes started
+// by the process as well.
+func startInOwnProcessGroup(cmd *exec.Cmd) {
+}
+
 func KillProcess(p *os.Process) error {
 	kill := exec.Command("TASKKILL", "/T", "/F", "/PID", strconv.Itoa(p.Pid))
 	err := kill.Run()
@@ -27,3 +32,15 @@ func KillProcess(p *os.Process) error {
 	}
 	return p.Kill()
 }
+
+func localFileIsWritable(path string) (ok bool, err error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	return fi.Mode().Perm()&0200 != 0, nil
+}
+
+// preserveOwner does nothing on Windows, where the ownership of a new file is inherited.
+func preserveOwner(f *os.File, fi os.FileInfo) {
+}
@@ -6,6 +6,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/jeffwilliams/anvil/internal/fuzzy"
 )
@@ -16,8 +17,22 @@ type FuzzySearcher struct {
 	body     *Body
 	keyword  string
 	lastTerm string
+	// watching is true while the keyword is in the tag and the +Live window is open. While it is
+	// true the search is re-executed when the body changes.
+	watching bool
+	// lastSearchTime is how long the last search took. It slows down re-executing the search
+	// for large bodies.
+	lastSearchTime time.Duration
 }
 
+const (
+	// fuzzyWatchDelay is how long after the body changes the search is re-executed while
+	// watching. Changes made during the delay are covered by the same search.
+	fuzzyWatchDelay = 250 * time.Millisecond
+	// fuzzyWatchMaxDelay is the longest delay used for large bodies.
+	fuzzyWatchMaxDelay = 5 * time.Second
+)
+
 func NewFuzzySearcher(win *Window, tag *Tag, body *Body) *FuzzySearcher {
 	s := &FuzzySearcher{
 		tag:     tag,
@@ -27,6 +42,7 @@ func NewFuzzySearcher(win *Window, tag *Tag, body *Body) *FuzzySearcher {
 	}
 
 	tag.AddTextChangeListener(s.tagTextChanged)
+	body.AddTextChangeListener(s.bodyTextChanged)
 
 	return s
 }
@@ -42,6 +58,8 @@ func (f *FuzzySearcher) tagTextChanged(ch *TextChange) {
 	i := strings.LastIndex(userArea, f.keyword)
 
 	if i < 0 {
+		f.stopWatching()
+		f.lastTerm = ""
 		return
 	}
 
@@ -60,7 +78,51 @@ func (f *FuzzySearcher) tagTextChanged(ch *TextChange) {
 
 	terms := strings.Fields(term)
 	f.search(terms)
+	// A +Live window doesn't watch its own body, since the search writes to it.
+	f.watching = len(terms) > 0 && !f.win.IsLiveWindow()
+}
+
+// bodyTextChanged re-executes the search, after a delay, when the body changes while watching.
+func (f *FuzzySearcher) bodyTextChanged(ch *TextChange) {
+	if !f.watching {
+		return
+	}
+	f.body.schedule(fmt.Sprintf("fuzz-watch-%d", f.win.Id), f.watchDelay(), f.researchAfterBodyChange)
+}
+
+// watchDelay returns how long to wait before re-executing the search. The delay grows with the
+// size of the body and the time the last search took so that large bodies that change often
+// aren't searched continuously.
+func (f *FuzzySearcher) watchDelay() time.Duration {
+	d := fuzzyWatchDelay
+	if mb := f.body.text.Len() / (1 << 20); mb > 0 {
+		d *= time.Duration(mb + 1)
+	}
+	if 4*f.lastSearchTime > d {
+		d = 4 * f.lastSearchTime
+	}
+	if d > fuzzyWatchMaxDelay {
+		d = fuzzyWatchMaxDelay
+	}
+	return d
+}
+
+func (f *FuzzySearcher) researchAfterBodyChange() {
+	if !f.watching {
+		return
+	}
+	if editor.FindWindowForId(f.win.Id) != f.win || f.findLiveWindow() == nil {
+		f.stopWatching()
+		return
+	}
 
+	log(LogCatgFuzzy, "Re-executing fuzzy search since the body of window %d changed\n", f.win.Id)
+	f.update(strings.Fields(f.lastTerm), false)
+}
+
+// stopWatching stops re-executing the search when the body changes.
+func (f *FuzzySearcher) stopWatching() {
+	f.watching = false
 }
 
 /*
@@ -72,6 +134,12 @@ then that term's rank is 1000. If no terms match then that line is not shown.
 The ranked lines are then sorted and shown in a +Live window.
 */
 func (f *FuzzySearcher) search(terms []string) {
+	f.update(terms, true)
+}
+
+// update performs the search and writes the results to the +Live window. If show is true the
+// +Live window is flashed and grown so that the results are visible.
+func (f *FuzzySearcher) update(terms []string, show bool) {
 	log(LogCatgFuzzy, "Fuzzy search for %d terms: %v\n", len(terms), terms)
 
 	win := f.findLiveWindow()
@@ -89,15 +157,19 @@ func (f *FuzzySearcher) search(terms []string) {
 
 	// TODO: might be more efficient to just store indexes into the doc here instead of new strings.
 	//lines := []string{}
+	start := time.Now()
 	lines := f.getLines()
 
 	f.rankLines(terms, lines)
+	f.lastSearchTime = time.Since(start)
 
 	c := f.buildLiveWindowContents(lines)
 	win.Body.SetText(c)
 
-	editor.SetOnlyFlashedWindow(win)
-	win.GrowIfBodyTooSmall()
+	if show {
+		editor.SetOnlyFlashedWindow(win)
+		win.GrowIfBodyTooSmall()
+	}
 }
 
 func (f *FuzzySearcher) getLines() (lines []rankedline) {
@@ -170,6 +242,11 @@ func (f *FuzzySearcher) rankLines(terms []string, lines []rankedline) {
 }
 
 func (f *FuzzySearcher) rankLinesUsingSellers(terms []string, lines []rankedline) {
+	rankFuzzyLines(terms, lines)
+}
+
+// rankFuzzyLines scores each line against the terms and sorts the lines from best to worst match.
+func rankFuzzyLines(terms []string, lines []rankedline) {
 	for i, l := range lines {
 		score := fuzzy.CalcScore(terms, l.line, fuzzy.CaseInsensitive)
 		lines[i].rank = int(score.Score * 1000)
@@ -218,3 +295,44 @@ func (f *FuzzySearcher) buildLiveWindowContents(lines []rankedline) []byte {
 
 	return buf.Bytes()
 }
+
+// fuzzyFilesMaxResults is the most files listed by Fuzzf.
+const fuzzyFilesMaxResults = 500
+
+// fuzzyFileResults ranks the file paths against the terms and returns the best matches, one
+// per line.
+func fuzzyFileResults(terms []string, paths []string) []byte {
+	lower := make([]string, len(terms))
+	for i, t := range terms {
+		lower[i] = strings.ToLower(t)
+	}
+
+	// A path scores 0 unless it contains all the runes of at least one term, so the paths that
+	// don't can be skipped cheaply before scoring the rest.
+	var lines []rankedline
+	for _, p := range paths {
+		if containsRunesOfAnyTerm(lower, strings.ToLower(p)) {
+			lines = append(lines, rankedline{line: p})
+		}
+	}
+	rankFuzzyLines(terms, lines)
+
+	var buf bytes.Buffer
+	for i, l := range lines {
+		if l.rank <= 0 || i >= fuzzyFilesMaxResults {
+			break
+		}
+		buf.WriteString(l.line)
+		buf.WriteRune('\n')
+	}
+	return buf.Bytes()
+}
+
+func containsRunesOfAnyTerm(terms []string, s string) bool {
+	for _, t := range terms {
+		if strings.IndexFunc(t, func(r rune) bool { return !strings.ContainsRune(s, r) }) < 0 {
+			return true
+		}
+	}
+	return false
+}
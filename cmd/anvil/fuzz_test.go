@@ -0,0 +1,62 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFuzzLiveWindowFollowsBodyChanges(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag(filepath.Join(dir, "log.txt"), typeFile)
+		win.Body.SetText([]byte("apple\nbanana\n"))
+		path, editorArea, _, _ := win.Tag.Parts()
+		win.Tag.Set(path, editorArea, "◊Fuzz cher◊")
+	})
+
+	live := func() (body string) {
+		onMainGoroutine(func() {
+			if w := win.fuzzySearch.findLiveWindow(); w != nil {
+				body = w.Body.String()
+			}
+		})
+		return
+	}
+
+	waitForLive := func(substr string) bool {
+		for i := 0; i < 20; i++ {
+			if strings.Contains(live(), substr) {
+				return true
+			}
+			time.Sleep(fuzzyWatchDelay / 2)
+		}
+		return false
+	}
+
+	if strings.Contains(live(), "cherry") {
+		t.Fatalf("expected no match before the body changes")
+	}
+
+	onMainGoroutine(func() {
+		win.Body.Append([]byte("cherry\n"))
+	})
+	if !waitForLive("cherry") {
+		t.Fatalf("expected the search to be re-executed when the body changed but the +Live window has %q", live())
+	}
+
+	// Once the command is removed from the tag, body changes no longer re-execute the search.
+	onMainGoroutine(func() {
+		path, editorArea, _, _ := win.Tag.Parts()
+		win.Tag.Set(path, editorArea, "")
+		win.Body.Append([]byte("cherry pie\n"))
+	})
+	if waitForLive("cherry pie") {
+		t.Fatalf("expected the search not to be re-executed after the command was removed")
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"sort"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/paint"
+	"github.com/jeffwilliams/anvil/internal/reflow"
+	"github.com/jeffwilliams/anvil/internal/runes"
+	"github.com/jeffwilliams/anvil/internal/typeset"
+	"golang.org/x/image/math/fixed"
+)
+
+// defaultGuideColumn is the column the line-length guide is drawn at when Guide is run without
+// an argument, and the width that Fmt wraps to when there is no guide.
+const defaultGuideColumn = 72
+
+// SetGuideColumn sets the column that the line-length guide is drawn at. A column of 0 turns the
+// guide off.
+func (e *editable) SetGuideColumn(col int) {
+	if col < 0 {
+		col = 0
+	}
+	e.guideColumn = col
+}
+
+// drawGuide draws a vertical line behind the text at the guide column. The column is measured in
+// widths of the space glyph, so the guide is only exact for monospaced fonts.
+func (e *editable) drawGuide(gtx layout.Context, ltext typeset.Text) {
+	if e.guideColumn <= 0 {
+		return
+	}
+
+	x := (fixed.Int26_6(e.guideColumn) * ltext.SpaceWidth()).Round()
+	if x <= 0 {
+		return
+	}
+
+	defer op.Offset(image.Pt(x, 0)).Push(gtx.Ops).Pop()
+	paint.ColorOp{Color: color.NRGBA(e.style.GuideColor)}.Add(gtx.Ops)
+	st := drawFilledBox(gtx, float32(gtx.Metric.Dp(1)), float32(gtx.Constraints.Max.Y))
+	paint.PaintOp{}.Add(gtx.Ops)
+	st.Pop()
+}
+
+// ReflowSelectionsOrParagraphs re-wraps the lines of text so that they are no wider than width
+// columns, as described in reflow.Text. Each selection is extended to whole lines before it is
+// re-wrapped. If there are no selections, the paragraph at each cursor is re-wrapped instead,
+// which is the run of non-blank lines around it. All the changes are undone together.
+func (e *editable) ReflowSelectionsOrParagraphs(width int) {
+	if e.writeLock.isLocked() {
+		return
+	}
+
+	w := runes.NewWalker(e.Bytes())
+	var ranges []textRange
+	sels := e.selectionsInDisplayOrder()
+	if len(sels) > 0 {
+		for _, s := range sels {
+			ranges = append(ranges, e.linesOfRange(&w, s.textRange))
+		}
+	} else {
+		cursors := make([]int, len(e.CursorIndices))
+		copy(cursors, e.CursorIndices)
+		sort.Ints(cursors)
+		for _, c := range cursors {
+			ranges = append(ranges, e.paragraphAt(&w, c))
+		}
+	}
+
+	ranges, merged := mergeOverlappingRanges(ranges)
+	if merged {
+		// The selections no longer correspond to the ranges. They are cleared rather than left
+		// covering text they didn't select.
+		sels = nil
+		e.clearSelections()
+	}
+
+	replacements := make([]string, len(ranges))
+	for i, r := range ranges {
+		s := string(w.TextBetweenRuneIndicesCache(r.start, r.end, &e.runeOffsetCache))
+		replacements[i] = reflow.Text(s, width, e.tabWidth)
+	}
+
+	e.replaceRanges(ranges, replacements, sels)
+}
+
+// linesOfRange returns r extended to the start of its first line and to the end of its last line,
+// not including the trailing newline.
+func (e *editable) linesOfRange(w *runes.Walker, r textRange) textRange {
+	w.SetRunePosCache(r.start, &e.runeOffsetCache)
+	start, _ := w.CurrentLineBounds()
+
+	last := r.end
+	if r.end > r.start {
+		// A selection that ends just after a newline doesn't include the next line.
+		last = r.end - 1
+	}
+	w.SetRunePosCache(last, &e.runeOffsetCache)
+	_, end := w.CurrentLineBounds()
+	return textRange{start, end}
+}
+
+// paragraphAt returns the range of the paragraph containing the rune at index pos, which is the
+// lines around it up to the nearest blank lines, not including the final newline. If the line at
+// pos is blank the range is just that line.
+func (e *editable) paragraphAt(w *runes.Walker, pos int) textRange {
+	line := func(i int) (start, end int, blank bool) {
+		w.SetRunePosCache(i, &e.runeOffsetCache)
+		start, end = w.CurrentLineBounds()
+		blank = reflow.IsBlank(string(w.TextBetweenRuneIndicesCache(start, end, &e.runeOffsetCache)))
+		return
+	}
+
+	start, end, blank := line(pos)
+	if blank {
+		return textRange{start, end}
+	}
+
+	for start > 0 {
+		s, _, blank := line(start - 1)
+		if blank {
+			break
+		}
+		start = s
+	}
+
+	l := e.text.Len()
+	for end < l {
+		_, en, blank := line(end + 1)
+		if blank {
+			break
+		}
+		end = en
+	}
+	return textRange{start, end}
+}
+
+// mergeOverlappingRanges merges the ranges in the sorted list that overlap or are equal. merged is
+// true if any were merged.
+func mergeOverlappingRanges(ranges []textRange) (result []textRange, merged bool) {
+	for _, r := range ranges {
+		if len(result) > 0 && r.start <= result[len(result)-1].end {
+			last := &result[len(result)-1]
+			if r.end > last.end {
+				last.end = r.end
+			}
+			merged = true
+			continue
+		}
+		result = append(result, r)
+	}
+	return
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestReflowSelectionsOrParagraphs(t *testing.T) {
+	tests := []struct {
+		name              string
+		text              string
+		selections        [][2]int
+		cursors           []int
+		width             int
+		expected          string
+		expectedCursors   []int
+		expectedSelection [][2]int
+	}{
+		{
+			name:            "paragraph at cursor",
+			text:            "one two three\nfour\n\nfive six seven\n",
+			cursors:         []int{2},
+			width:           9,
+			expected:        "one two\nthree\nfour\n\nfive six seven\n",
+			expectedCursors: []int{18},
+		},
+		{
+			name:            "paragraphs at cursors",
+			text:            "one two three\nfour\n\nfive six seven\n",
+			cursors:         []int{2, 22},
+			width:           9,
+			expected:        "one two\nthree\nfour\n\nfive six\nseven\n",
+			expectedCursors: []int{18, 34},
+		},
+		{
+			name:            "cursors in the same paragraph",
+			text:            "a\nb\n",
+			cursors:         []int{0, 2},
+			width:           9,
+			expected:        "a b\n",
+			expectedCursors: []int{3},
+		},
+		{
+			name:              "selection is extended to whole lines",
+			text:              "# aa bb\n# cc\nx y\n",
+			selections:        [][2]int{{3, 9}},
+			width:             20,
+			expected:          "# aa bb cc\nx y\n",
+			expectedCursors:   []int{10},
+			expectedSelection: [][2]int{{0, 10}},
+		},
+	}
+
+	startHeadlessEditor(t)
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var text string
+			var cursors []int
+			var sels [][2]int
+			var undone string
+			onMainGoroutine(func() {
+				win := editor.NewWindow(nil)
+				e := &win.Body.editable
+				e.SetText([]byte(tc.text))
+				if tc.cursors != nil {
+					e.CursorIndices = tc.cursors
+				}
+				for _, s := range tc.selections {
+					e.AddSelection(s[0], s[1])
+				}
+
+				e.ReflowSelectionsOrParagraphs(tc.width)
+				text = string(e.Bytes())
+				cursors = append(cursors, e.CursorIndices...)
+				for _, s := range e.selectionsInDisplayOrder() {
+					sels = append(sels, [2]int{s.start, s.end})
+				}
+
+				e.applyUndoOrRedo(e.text.Undo, -1)
+				undone = string(e.Bytes())
+			})
+
+			if text != tc.expected {
+				t.Fatalf("expected text %q but got %q", tc.expected, text)
+			}
+			if !reflect.DeepEqual(cursors, tc.expectedCursors) {
+				t.Fatalf("expected cursors %v but got %v", tc.expectedCursors, cursors)
+			}
+			if !reflect.DeepEqual(sels, tc.expectedSelection) {
+				t.Fatalf("expected selections %v but got %v", tc.expectedSelection, sels)
+			}
+			if undone != tc.text {
+				t.Fatalf("expected one undo to restore %q but got %q", tc.text, undone)
+			}
+		})
+	}
+}
+
+func TestGuideCommand(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var cols []int
+	onMainGoroutine(func() {
+		win := editor.NewWindow(nil)
+		for _, cmd := range []string{"Guide", "Guide", "Guide 80", "Guide off"} {
+			NewCommandExecutor(win).Do(cmd, &CmdContext{})
+			cols = append(cols, win.Body.guideColumn)
+		}
+	})
+
+	expected := []int{defaultGuideColumn, 0, 80, 0}
+	if !reflect.DeepEqual(cols, expected) {
+		t.Fatalf("expected the guide columns %v but got %v", expected, cols)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"image"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/unit"
+)
+
+// headlessMetric is the metric a headless editor is layed out with: one pixel per dp and sp.
+var headlessMetric = unit.Metric{PxPerDp: 1, PxPerSp: 1}
+
+// headlessQuietPeriod is how long Settle waits for more work before deciding the editor is idle.
+const headlessQuietPeriod = 20 * time.Millisecond
+
+// Headless runs the editor without an application window, so that it can be driven by tests.
+// There is no main loop: the work sent to the editor is serviced, and the editor is layed out
+// as it would be for a FrameEvent, only when the methods of Headless are called. The goroutine
+// calling them takes the place of the main goroutine, so it is the only one that may use the
+// editor and its windows directly.
+type Headless struct {
+	// Size is the size of the frames the editor is layed out in, in pixels.
+	Size image.Point
+	ops  op.Ops
+}
+
+// NewHeadless creates the application and the editor, with one column, without a window.
+func NewHeadless(size image.Point) *Headless {
+	application = NewApplication()
+	application.SetMetric(headlessMetric)
+	editor = NewEditor(WindowStyle)
+	editor.NewCol()
+	return &Headless{Size: size}
+}
+
+// Context returns a layout context for a frame of the editor. It delivers no input events.
+func (h *Headless) Context() layout.Context {
+	h.ops.Reset()
+	return layout.Context{
+		Ops:         &h.ops,
+		Metric:      headlessMetric,
+		Constraints: layout.Exact(h.Size),
+		Now:         time.Now(),
+	}
+}
+
+// Frame lays out the editor as the main loop does for a FrameEvent. This also performs the
+// operations that were waiting for the next layout, such as moving the cursor after a load.
+func (h *Headless) Frame() {
+	layoutWidgets(h.Context())
+	editor.notifyApiSelectionChanges()
+}
+
+// ServiceWork services the work that is ready without waiting for more, as the main loop would,
+// and returns the number of items serviced. Work sent by other goroutines may take a moment to
+// become ready; use Settle to wait for it.
+func (h *Headless) ServiceWork() (n int) {
+	for {
+		select {
+		case w := <-editor.ReadyWork():
+			handleWork(w)
+			n++
+		default:
+			return
+		}
+	}
+}
+
+// Settle services work and lays out frames until no jobs are running and no work arrives for a
+// short while, and returns true, or returns false if the editor is still busy after timeout.
+func (h *Headless) Settle(timeout time.Duration) bool {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	quiet := time.NewTimer(headlessQuietPeriod)
+	defer quiet.Stop()
+
+	for {
+		select {
+		case w := <-editor.ReadyWork():
+			handleWork(w)
+			quiet.Reset(headlessQuietPeriod)
+		case <-quiet.C:
+			h.Frame()
+			if h.ServiceWork() == 0 && len(editor.Jobs()) == 0 {
+				return true
+			}
+			quiet.Reset(headlessQuietPeriod)
+		case <-deadline.C:
+			return false
+		}
+	}
+}
+
+// Await calls fn on another goroutine and services work until it returns. It is used to call
+// code that waits for the main goroutine, such as the API handlers.
+func (h *Headless) Await(fn func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn()
+	}()
+
+	for {
+		select {
+		case w := <-editor.ReadyWork():
+			handleWork(w)
+		case <-done:
+			return
+		}
+	}
+}
+
+// OpenWindow opens a window for the file at path holding text, without reading the file. The
+// text is marked as unchanged, as if it had been loaded from the file.
+func (h *Headless) OpenWindow(path string, text []byte) *Window {
+	w := editor.NewWindow(nil)
+	if w == nil {
+		return nil
+	}
+	w.SetFilenameAndTag(path, typeFile)
+	w.Body.SetText(text)
+	w.markTextAsUnchanged()
+	return w
+}
+
+// Execute executes cmd, with its arguments, as if it was clicked in the tag, which may be the tag
+// of the editor, a column or a window.
+func (h *Headless) Execute(tag *Tag, cmd string) {
+	tag.adapter.execute(&tag.editable, h.Context(), cmd, nil)
+}
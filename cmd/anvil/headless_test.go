@@ -0,0 +1,152 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+func TestHeadlessNewPutGet(t *testing.T) {
+	h := newTestHeadless()
+
+	path := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(path, []byte("one\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+
+	h.Execute(&editor.Tag, "New "+path)
+	settle(t, h)
+
+	win, _ := editor.FindWindowForFile(path)
+	if win == nil {
+		t.Fatalf("expected New to open a window for %s", path)
+	}
+	if s := win.Body.String(); s != "one\n" {
+		t.Fatalf("expected the body to hold the file but it is %q", s)
+	}
+
+	win.Body.insertToPieceTable(4, "two\n")
+	h.Execute(&win.Tag, "Put")
+	settle(t, h)
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading the file failed: %v", err)
+	}
+	if string(b) != "one\ntwo\n" {
+		t.Fatalf("expected Put to save the body but the file holds %q", b)
+	}
+	if win.IsDirty() {
+		t.Fatalf("expected the window not to be dirty after Put")
+	}
+
+	if err := os.WriteFile(path, []byte("changed\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+	h.Execute(&win.Tag, "Get")
+	settle(t, h)
+
+	if s := win.Body.String(); s != "changed\n" {
+		t.Fatalf("expected Get to load the file again but the body is %q", s)
+	}
+}
+
+func TestHeadlessUndoRedoMergesTransactions(t *testing.T) {
+	h := newTestHeadless()
+	win := h.OpenWindow(filepath.Join(t.TempDir(), "a.txt"), []byte("ab\ncd\n"))
+	h.Frame()
+
+	check := func(expected string) {
+		t.Helper()
+		if s := win.Body.String(); s != expected {
+			t.Fatalf("expected the body to be %q but it is %q", expected, s)
+		}
+	}
+
+	// Typing with several cursors is one change, and so is undone and redone at once.
+	win.Body.CursorIndices = []int{0, 3}
+	win.Body.InsertText("xy")
+	check("xyab\nxycd\n")
+
+	h.Execute(&win.Tag, "Undo")
+	check("ab\ncd\n")
+	h.Execute(&win.Tag, "Redo")
+	check("xyab\nxycd\n")
+
+	// A later change is undone on its own.
+	win.Body.setToOneCursorIndex(2)
+	win.Body.InsertText("!")
+	check("xy!ab\nxycd\n")
+	h.Execute(&win.Tag, "Undo")
+	check("xyab\nxycd\n")
+	h.Execute(&win.Tag, "Undo")
+	check("ab\ncd\n")
+}
+
+func TestHeadlessApiOverHttptest(t *testing.T) {
+	h := newTestHeadless()
+	path := filepath.Join(t.TempDir(), "a.txt")
+	win := h.OpenWindow(path, []byte("text\n"))
+
+	sess, err := createApiSession("test")
+	if err != nil {
+		t.Fatalf("creating API session failed: %v", err)
+	}
+	defer deleteApiSession(sess.id)
+
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Anvil-Sess", string(sess.id))
+		rsp := httptest.NewRecorder()
+		h.Await(func() { ApiHandler{}.ServeHTTP(rsp, req) })
+		if rsp.Code != http.StatusOK {
+			t.Fatalf("%s %s: expected status %d but got %d: %s", method, path, http.StatusOK, rsp.Code, rsp.Body)
+		}
+		return rsp
+	}
+
+	var wins []api.Window
+	if err := json.Unmarshal(do(http.MethodGet, "/wins", "").Body.Bytes(), &wins); err != nil {
+		t.Fatalf("decoding the windows failed: %v", err)
+	}
+	found := false
+	for _, w := range wins {
+		found = found || (w.Id == win.Id && w.GlobalPath == path && !w.Dirty)
+	}
+	if !found {
+		t.Fatalf("expected window %d for %s to be listed but got %+v", win.Id, path, wins)
+	}
+
+	body := fmt.Sprintf("/wins/%d/body", win.Id)
+	do(http.MethodPut, body, "replaced\n")
+	if s := do(http.MethodGet, body, "").Body.String(); s != "replaced\n" {
+		t.Fatalf("expected the body read through the API to be the one written but it is %q", s)
+	}
+
+	apiGetAndClearNotifications(sess.id)
+	h.Execute(&win.Tag, "Put")
+	settle(t, h)
+
+	var ops []ApiNotificationOp
+	for _, n := range apiGetAndClearNotifications(sess.id) {
+		if n.WinId == win.Id {
+			ops = append(ops, n.Op)
+		}
+	}
+	var exec, put bool
+	for _, op := range ops {
+		exec = exec || op == ApiNotificationOpExec
+		put = put || op == ApiNotificationOpPut
+	}
+	if !exec || !put {
+		t.Fatalf("expected notifications that Put was executed and the file saved but got %v", ops)
+	}
+}
@@ -67,6 +67,10 @@ ANVIL_WIN_ID	The internal numeric ID of the window. This may be used in the API.
 ANVIL_API_PORT	The TCP port number on which the Anvil REST API is running. Connections to the API should be performed to the local host; if a remote command is executed an SSH tunnel is created so that commands may connect locally.
 
 ANVIL_API_SESS	Session id used to authenticate the client program against the API.
+
+The following environment variable is read by Anvil rather than set:
+
+ANVIL_API_SCOPE	Limits what the API session created for a command may do, so that helper programs that aren't trusted can't change arbitrary windows. It is taken from the environment of Anvil, the [env] settings, or an assignment at the start of the command like ANVIL_API_SCOPE=readonly helper. The value is a comma-separated list of: 'full', the default, which allows everything; 'readonly', which refuses requests that change anything with 403 Forbidden; and 'win:ID', or 'win' for the window the command was run from, which only allows the window with that ID to be used, and only sends notifications about that window. The scope of each session is listed by About.
 `
 	h.addHelp("Environment", s)
 
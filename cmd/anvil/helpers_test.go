@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"net"
+	"testing"
+	"time"
+
+	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+// newTestEditor replaces the application and the editor with new ones that have one column and
+// are not drawn. Nothing services the editor's work unless the test does.
+func newTestEditor() {
+	application = NewApplication()
+	editor = NewEditor(WindowStyle)
+	editor.NewCol()
+}
+
+// newTestHeadless is like newTestEditor, but the editor can be laid out and driven like the
+// main loop does.
+func newTestHeadless() *Headless {
+	h := NewHeadless(image.Pt(800, 600))
+	h.Frame()
+	return h
+}
+
+func settle(t *testing.T, h *Headless) {
+	t.Helper()
+	if !h.Settle(10 * time.Second) {
+		t.Fatalf("the editor is still busy; the jobs are %v", editor.Jobs())
+	}
+}
+
+// startHeadlessEditor creates an editor with no application window, services its work items
+// and serves the API for it. It returns a client for the API.
+func startHeadlessEditor(t *testing.T) api.Anvil {
+	newTestEditor()
+
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go func() {
+		for {
+			select {
+			case w := <-editor.ReadyWork():
+				handleWork(w)
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening failed: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go ServeAPIOnListener(l)
+
+	sess, err := createApiSession("test")
+	if err != nil {
+		t.Fatalf("creating API session failed: %v", err)
+	}
+	t.Cleanup(func() { deleteApiSession(sess.id) })
+
+	return api.New(string(sess.id), fmt.Sprintf("%d", l.Addr().(*net.TCPAddr).Port))
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+
+	"gioui.org/f32"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op"
+	"github.com/jeffwilliams/anvil/internal/typeset"
+)
+
+const (
+	hexBytesPerRow = 16
+	// hexRowLen is the number of runes in a formatted row, including the newline.
+	// A row is an 8 digit offset, two spaces, 16 bytes in hex each followed by a space with
+	// an extra space after the eighth, then the bytes as ASCII between bars.
+	hexRowLen        = 10 + hexBytesPerRow*3 + 1 + 1 + hexBytesPerRow + 1 + 1
+	hexAsciiColumn   = 10 + hexBytesPerRow*3 + 1 + 1
+	hexScrollRows    = 3
+	hexPresenterName = "hex"
+)
+
+// hexPresenter displays a window body as a read-only hex dump. Only the rows that are visible
+// are formatted, so large files are displayed quickly.
+type hexPresenter struct {
+	body *Body
+	// topRow is the first row displayed
+	topRow int
+	// cursor is the offset of the selected byte
+	cursor     int
+	contentLen int
+	// visibleRows is the number of rows that fit in the body as of the last layout
+	visibleRows int
+	// layedoutRows is the visible rows as of the last layout, used to locate clicks.
+	layedoutRows *typeset.Text
+}
+
+func newHexPresenter(b *Body) BodyPresenter {
+	return &hexPresenter{body: b, contentLen: len(b.Bytes())}
+}
+
+func (h *hexPresenter) Name() string {
+	return hexPresenterName
+}
+
+func (h *hexPresenter) Editable() bool {
+	return false
+}
+
+func (h *hexPresenter) ContentChanged(change *TextChange) {
+	h.contentLen = len(h.body.Bytes())
+	h.clamp()
+}
+
+func (h *hexPresenter) rowCount() int {
+	return (h.contentLen + hexBytesPerRow - 1) / hexBytesPerRow
+}
+
+func (h *hexPresenter) clamp() {
+	if h.cursor >= h.contentLen {
+		h.cursor = h.contentLen - 1
+	}
+	if h.cursor < 0 {
+		h.cursor = 0
+	}
+	if h.topRow > h.rowCount()-1 {
+		h.topRow = h.rowCount() - 1
+	}
+	if h.topRow < 0 {
+		h.topRow = 0
+	}
+}
+
+// makeCursorVisible scrolls so that the row containing the cursor is displayed.
+func (h *hexPresenter) makeCursorVisible() {
+	row := h.cursor / hexBytesPerRow
+	if row < h.topRow {
+		h.topRow = row
+	} else if h.visibleRows > 0 && row >= h.topRow+h.visibleRows {
+		h.topRow = row - h.visibleRows + 1
+	}
+}
+
+func (h *hexPresenter) Layout(gtx layout.Context, content []byte) layout.Dimensions {
+	h.contentLen = len(content)
+	lh := h.body.lineHeight()
+	if lh > 0 {
+		h.visibleRows = gtx.Constraints.Max.Y / lh
+	}
+	h.clamp()
+
+	start := h.topRow * hexBytesPerRow
+	end := start + (h.visibleRows+1)*hexBytesPerRow
+	if end > len(content) {
+		end = len(content)
+	}
+
+	constraints := h.body.textLayoutConstraints(gtx)
+	constraints.WrapWidth = 0
+	ltext, errs := typeset.Layout(formatHexRows(content[start:end], start), constraints)
+	for _, err := range errs {
+		log(LogCatgEd, "hexPresenter.Layout: typeset.Layout error: %v\n", err)
+	}
+	h.layedoutRows = &ltext
+
+	defer h.body.indentOnLeft(&gtx).Pop()
+	stack := op.Offset(image.Point{}).Push(gtx.Ops)
+	for i, line := range ltext.Lines() {
+		h.drawRow(gtx, &line, h.topRow+i)
+		op.Offset(image.Point{0, lh}).Add(gtx.Ops)
+	}
+	stack.Pop()
+
+	return layout.Dimensions{Size: gtx.Constraints.Max}
+}
+
+// drawRow draws a formatted row, highlighting the selected byte if it is in the row.
+func (h *hexPresenter) drawRow(gtx layout.Context, line *typeset.Line, row int) {
+	tr := h.body.textRender
+	style := h.body.editable.style
+	tr.SetDrawBg(false)
+	tr.SetFgColor(style.FgColor)
+
+	if h.cursor/hexBytesPerRow != row || h.contentLen == 0 {
+		tr.DrawTextline(gtx, line)
+		return
+	}
+
+	col := h.cursor % hexBytesPerRow
+	hexCol := hexColumnOfByte(col)
+	asciiCol := hexAsciiColumn + col
+
+	stack := op.Offset(image.Point{}).Push(gtx.Ops)
+	draw := func(l *typeset.Line, selected bool) {
+		tr.SetDrawBg(selected)
+		if selected {
+			tr.SetFgColor(style.PrimarySelection.FgColor)
+			tr.SetBgColor(style.PrimarySelection.BgColor)
+		} else {
+			tr.SetFgColor(style.FgColor)
+		}
+		tr.DrawTextline(gtx, l)
+		op.Offset(image.Point{l.Width().Round(), 0}).Add(gtx.Ops)
+	}
+
+	splits := []int{hexCol, hexCol + 2, asciiCol, asciiCol + 1}
+	last := 0
+	for i, s := range splits {
+		first, rest := line.Split(s - last)
+		draw(first, i%2 == 1)
+		if rest == nil {
+			break
+		}
+		line = rest
+		last = s
+	}
+	draw(line, false)
+	stack.Pop()
+
+	tr.SetDrawBg(false)
+	tr.SetFgColor(style.FgColor)
+}
+
+func (h *hexPresenter) HandlePointer(gtx layout.Context, ev *pointer.Event) {
+	switch ev.Kind {
+	case pointer.Scroll:
+		if ev.Scroll.Y > 0 {
+			h.topRow += hexScrollRows
+		} else if ev.Scroll.Y < 0 {
+			h.topRow -= hexScrollRows
+		}
+		h.clamp()
+	case pointer.Press:
+		if ev.Buttons != pointer.ButtonPrimary || h.layedoutRows == nil {
+			return
+		}
+		pos := f32.Pt(ev.Position.X-float32(gtx.Metric.Dp(h.body.editable.style.TextLeftPadding)), ev.Position.Y)
+		i := h.layedoutRows.IndexOfPixelCoord(pos)
+		col := byteColumnAtHexColumn(i % hexRowLen)
+		if col < 0 {
+			return
+		}
+		h.cursor = (h.topRow+i/hexRowLen)*hexBytesPerRow + col
+		h.clamp()
+	}
+}
+
+func (h *hexPresenter) HandleKey(gtx layout.Context, ev *key.Event) {
+	if ev.State != key.Press {
+		return
+	}
+
+	page := h.visibleRows * hexBytesPerRow
+	if page <= 0 {
+		page = hexBytesPerRow
+	}
+
+	switch ev.Name {
+	case key.NameLeftArrow:
+		h.cursor--
+	case key.NameRightArrow:
+		h.cursor++
+	case key.NameUpArrow:
+		if h.cursor >= hexBytesPerRow {
+			h.cursor -= hexBytesPerRow
+		}
+	case key.NameDownArrow:
+		if h.cursor+hexBytesPerRow < h.contentLen {
+			h.cursor += hexBytesPerRow
+		}
+	case key.NamePageUp:
+		h.cursor -= page
+	case key.NamePageDown:
+		h.cursor += page
+	case key.NameHome:
+		h.cursor = 0
+	case key.NameEnd:
+		h.cursor = h.contentLen - 1
+	default:
+		return
+	}
+	h.clamp()
+	h.makeCursorVisible()
+}
+
+// formatHexRows formats b as rows of a hex dump. offset is the offset of the first byte of b and
+// should be a multiple of hexBytesPerRow. Every row, including the last, is hexRowLen runes long.
+func formatHexRows(b []byte, offset int) []byte {
+	var buf bytes.Buffer
+	for len(b) > 0 {
+		n := hexBytesPerRow
+		if n > len(b) {
+			n = len(b)
+		}
+
+		fmt.Fprintf(&buf, "%08x  ", offset)
+		for i := 0; i < hexBytesPerRow; i++ {
+			if i < n {
+				fmt.Fprintf(&buf, "%02x ", b[i])
+			} else {
+				buf.WriteString("   ")
+			}
+			if i == hexBytesPerRow/2-1 {
+				buf.WriteByte(' ')
+			}
+		}
+
+		buf.WriteByte('|')
+		for i := 0; i < hexBytesPerRow; i++ {
+			switch {
+			case i >= n:
+				buf.WriteByte(' ')
+			case b[i] >= 0x20 && b[i] < 0x7f:
+				buf.WriteByte(b[i])
+			default:
+				buf.WriteByte('.')
+			}
+		}
+		buf.WriteString("|\n")
+
+		b = b[n:]
+		offset += n
+	}
+	return buf.Bytes()
+}
+
+// hexColumnOfByte returns the rune index within a formatted row where the hex digits
+// of the byte in column col begin.
+func hexColumnOfByte(col int) int {
+	c := 10 + col*3
+	if col >= hexBytesPerRow/2 {
+		c++
+	}
+	return c
+}
+
+// byteColumnAtHexColumn returns the column of the byte displayed at rune index i within a formatted
+// row, either as hex digits or as ASCII, or -1 if there is no byte displayed there.
+func byteColumnAtHexColumn(i int) int {
+	if i >= hexAsciiColumn && i < hexAsciiColumn+hexBytesPerRow {
+		return i - hexAsciiColumn
+	}
+	for col := 0; col < hexBytesPerRow; col++ {
+		c := hexColumnOfByte(col)
+		if i >= c && i < c+2 {
+			return col
+		}
+	}
+	return -1
+}
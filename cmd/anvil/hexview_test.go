@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFormatHexRows(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    []byte
+		offset   int
+		expected string
+	}{
+		{
+			name:     "empty",
+			input:    []byte{},
+			expected: "",
+		},
+		{
+			name:     "full row",
+			input:    []byte("0123456789abcdef"),
+			offset:   0x20,
+			expected: "00000020  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66 |0123456789abcdef|\n",
+		},
+		{
+			name:     "partial row with unprintable bytes",
+			input:    []byte("a\x00\nb"),
+			expected: "00000000  61 00 0a 62                                      |a..b            |\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s := string(formatHexRows(tc.input, tc.offset))
+			if s != tc.expected {
+				t.Fatalf("expected\n%q\nbut got\n%q", tc.expected, s)
+			}
+			for _, row := range strings.SplitAfter(s, "\n") {
+				if row != "" && len(row) != hexRowLen {
+					t.Fatalf("expected rows to be %d long but got %d", hexRowLen, len(row))
+				}
+			}
+		})
+	}
+}
+
+func TestByteColumnAtHexColumn(t *testing.T) {
+	row := string(formatHexRows([]byte("0123456789abcdef"), 0))
+	for col := 0; col < hexBytesPerRow; col++ {
+		i := hexColumnOfByte(col)
+		if got := byteColumnAtHexColumn(i + 1); got != col {
+			t.Fatalf("expected hex digits at %d to be column %d but got %d", i+1, col, got)
+		}
+		if got := byteColumnAtHexColumn(hexAsciiColumn + col); got != col {
+			t.Fatalf("expected ascii at %d to be column %d but got %d", hexAsciiColumn+col, col, got)
+		}
+		if row[hexAsciiColumn+col] != "0123456789abcdef"[col] {
+			t.Fatalf("unexpected ascii column in row %q", row)
+		}
+	}
+	if got := byteColumnAtHexColumn(2); got != -1 {
+		t.Fatalf("expected the offset to have no byte column but got %d", got)
+	}
+}
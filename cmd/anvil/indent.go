@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// maxIndentGuessBytes is how much of the start of a file is examined to guess its indentation.
+const maxIndentGuessBytes = 256 * 1024
+
+// indentGuess is the style of indentation guessed from the leading whitespace of a file.
+type indentGuess struct {
+	counts indentCounts
+	// tabs is true if the file is indented with tabs rather than spaces.
+	tabs bool
+	// width is the number of columns in one level of indentation, or 0 if it couldn't be guessed.
+	width int
+}
+
+// guessIndentation guesses how text is indented. If tabsRequired is true the text is in a
+// language where tabs are significant, and is assumed to be indented with them. ok is false if
+// none of the lines are indented.
+func guessIndentation(text []byte, tabsRequired bool) (g indentGuess, ok bool) {
+	if len(text) > maxIndentGuessBytes {
+		text = text[:maxIndentGuessBytes]
+	}
+
+	g.counts = countIndentation(text)
+	if g.counts.Tabs == 0 && g.counts.Spaces == 0 {
+		return
+	}
+	ok = true
+
+	g.tabs = tabsRequired || g.counts.Tabs > g.counts.Spaces
+	if g.tabs {
+		g.width = guessTabWidth(text)
+	} else {
+		g.width = guessSpaceIndentWidth(text)
+	}
+	return
+}
+
+// guessSpaceIndentWidth returns the most common increase in the number of leading spaces from
+// one line to the next, which is usually one level of indentation.
+func guessSpaceIndentWidth(text []byte) int {
+	var increases [9]int
+	smallest := 0
+	prev := 0
+	forEachLine(text, func(line []byte, runeOffset int) {
+		if len(bytes.TrimLeft(line, " \t")) == 0 {
+			return
+		}
+		if lineIndentation(line) == '\t' {
+			prev = 0
+			return
+		}
+
+		n := len(line) - len(bytes.TrimLeft(line, " "))
+		if d := n - prev; d >= 2 && d <= 8 {
+			increases[d]++
+		}
+		if n > 0 && (smallest == 0 || n < smallest) {
+			smallest = n
+		}
+		prev = n
+	})
+
+	width := 0
+	for d := 2; d <= 8; d++ {
+		if increases[d] > increases[width] {
+			width = d
+		}
+	}
+	if width == 0 && smallest <= 8 {
+		width = smallest
+	}
+	return width
+}
+
+// guessTabWidth returns the width of a tab in a file indented with tabs. This can only be told
+// when some lines are indented by a tab followed by spaces, which is done when a tab is wider than
+// one level of indentation, in which case tabs are assumed to be 8 columns. Otherwise it returns 0.
+func guessTabWidth(text []byte) int {
+	width := 0
+	forEachLine(text, func(line []byte, runeOffset int) {
+		if lineIndentation(line) != '\t' {
+			return
+		}
+		ws := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+		if bytes.HasSuffix(ws, []byte("\t    ")) && !bytes.HasSuffix(ws, []byte("\t        ")) {
+			width = 8
+		}
+	})
+	return width
+}
+
+// insertWhenTabPressed returns the string that Tab should insert in a file indented this way.
+func (g indentGuess) insertWhenTabPressed() string {
+	if g.tabs || g.width == 0 {
+		return "\t"
+	}
+	return strings.Repeat(" ", g.width)
+}
+
+func (g indentGuess) String() string {
+	var s string
+	switch {
+	case g.tabs && g.width == 0:
+		s = "indented with tabs"
+	case g.tabs:
+		s = fmt.Sprintf("indented with tabs %d columns wide", g.width)
+	case g.width == 0:
+		s = "indented with spaces"
+	default:
+		s = fmt.Sprintf("indented with %d spaces", g.width)
+	}
+	return fmt.Sprintf("%s (%v)", s, g.counts)
+}
+
+// guessIndentation sets the string inserted when Tab is pressed and the tab width of the window
+// from the indentation of the text in the body. It is only done the first time a file is loaded
+// into the window, and values already set using the Tab or Tabwidth commands are kept.
+func (w *Window) guessIndentation() {
+	if !settings.General.GuessIndentation || w.fileType != typeFile || w.indentGuessed {
+		return
+	}
+	w.indentGuessed = true
+
+	g, ok := guessIndentation(w.Body.Bytes(), tabsRequiredForFile(w.file))
+	if !ok {
+		return
+	}
+	w.indentGuess = &g
+
+	if w.insertWhenTabPressed == "" {
+		w.insertWhenTabPressed = g.insertWhenTabPressed()
+	}
+	if w.Body.TabWidth() == 0 && g.width > 0 {
+		w.Body.SetTabWidth(g.width)
+	}
+}
+
+// indentationReport describes the tab settings of the window and what was guessed about the
+// indentation of its file.
+func (w *Window) indentationReport() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "Tabwidth: %s: ", w.file)
+	if n := w.Body.TabWidth(); n > 0 {
+		fmt.Fprintf(&buf, "tabs are %d columns wide", n)
+	} else {
+		buf.WriteString("tabs use the tab stop interval from the style")
+	}
+
+	tab := w.insertWhenTabPressed
+	if tab == "" {
+		tab = editor.getInsertWhenTabPressed()
+	}
+	fmt.Fprintf(&buf, "; Tab inserts %s", escapeTabInsert(tab))
+
+	switch {
+	case w.indentGuess != nil:
+		fmt.Fprintf(&buf, "; the file is %v", *w.indentGuess)
+	case w.indentGuessed:
+		buf.WriteString("; the file has no indented lines")
+	default:
+		buf.WriteString("; the indentation of the file was not guessed")
+	}
+	return buf.String()
+}
+
+// escapeTabInsert quotes s the way it would be given to the Tab command.
+func escapeTabInsert(s string) string {
+	s = strings.ReplaceAll(s, "\\", "\\\\")
+	s = strings.ReplaceAll(s, "\t", "\\t")
+	s = strings.ReplaceAll(s, "'", "\\'")
+	return "'" + s + "'"
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/math/fixed"
+)
+
+func TestGuessIndentation(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		tabsRequired bool
+		ok           bool
+		tabs         bool
+		width        int
+	}{
+		{"none", "a\nb\n", false, false, false, 0},
+		{"tabs", "func f() {\n\tif x {\n\t\ty()\n\t}\n}\n", false, true, true, 0},
+		{"two spaces", "a:\n  b:\n    c: 1\n  d: 2\n", false, true, false, 2},
+		{"four spaces", "def f():\n    if x:\n        y()\n    return\n\ndef g():\n    pass\n", false, true, false, 4},
+		{"continuation lines", "x = f(a,\n      b)\nif x:\n    y()\n    z()\n", false, true, false, 4},
+		{"gnu style", "int f()\n{\n  if (x)\n    {\n      y();\n\tz();\n\t  w();\n\t    v();\n\t}\n}\n", false, true, true, 8},
+		{"eight column tabs", "f()\n{\n\tif (x) {\n\t    y();\n\t\tz();\n\t}\n}\n", false, true, true, 8},
+		{"makefile", "all:\n\tgo build\n  x\n  y\n", true, true, true, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			g, ok := guessIndentation([]byte(tc.text), tc.tabsRequired)
+			if ok != tc.ok || g.tabs != tc.tabs || g.width != tc.width {
+				t.Fatalf("expected ok=%v tabs=%v width=%d but got ok=%v tabs=%v width=%d", tc.ok, tc.tabs, tc.width, ok, g.tabs, g.width)
+			}
+		})
+	}
+}
+
+func TestGuessedIndentationSetsTabAndTabwidth(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	onMainGoroutine(func() {
+		w := editor.NewWindow(nil)
+		w.SetFilenameAndTag(filepath.Join(dir, "a.py"), typeFile)
+		w.Body.SetText([]byte("def f():\n    if x:\n        y()\n"))
+		w.guessIndentation()
+
+		if w.getInsertWhenTabPressed() != "    " {
+			t.Errorf("expected Tab to insert 4 spaces but it inserts %q", w.getInsertWhenTabPressed())
+		}
+		if w.Body.TabWidth() != 4 {
+			t.Errorf("expected a tab width of 4 but it is %d", w.Body.TabWidth())
+		}
+		expected := (fixed.Int26_6(4) * w.Body.spaceWidth()).Round()
+		if i := w.Body.tabStopInterval(nil); i != expected {
+			t.Errorf("expected tab stops %d pixels apart but they are %d", expected, i)
+		}
+
+		// A tab width set by the user is kept when the file is loaded again.
+		ctx := &CmdContext{Dir: dir, Args: []string{"8"}}
+		NewCommandExecutor(w).CmdTabwidth(ctx)
+		w.guessIndentation()
+		if w.Body.TabWidth() != 8 {
+			t.Errorf("expected a tab width of 8 but it is %d", w.Body.TabWidth())
+		}
+
+		ctx.Args = []string{"?"}
+		NewCommandExecutor(w).CmdTabwidth(ctx)
+		errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(dir))
+		if errs == nil {
+			t.Fatalf("expected Tabwidth ? to write to +Errors")
+		}
+		report := errs.Body.String()
+		for _, s := range []string{"tabs are 8 columns wide", "Tab inserts '    '", "indented with 4 spaces (0 lines indented with tabs, 2 with spaces)"} {
+			if !strings.Contains(report, s) {
+				t.Errorf("expected the report %q to contain %q", report, s)
+			}
+		}
+
+		ctx.Args = nil
+		NewCommandExecutor(w).CmdTabwidth(ctx)
+		if w.Body.TabWidth() != 0 || w.Body.tabStopInterval(nil) != int(w.Body.editable.style.TabStopInterval) {
+			t.Errorf("expected Tabwidth without arguments to use the style")
+		}
+	})
+}
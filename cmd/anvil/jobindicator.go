@@ -0,0 +1,144 @@
+package main
+
+import (
+	"image"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+)
+
+// JobWindower is implemented by jobs that work on the text of a window, like loading a file
+// into it or replacing a selection in it with the output of a command. While such a job runs
+// the layout box of the window shows that the window is busy.
+type JobWindower interface {
+	// JobWindow returns the window the job works on, or nil if it isn't known yet.
+	JobWindow() *Window
+}
+
+// jobIndicatorFrameInterval is how often the activity indicator in the layout box of a window
+// with running jobs moves. Frames are only requested while there are jobs.
+const jobIndicatorFrameInterval = 250 * time.Millisecond
+
+// jobIndicatorMaxPips is the most jobs counted by the pips under the activity indicator.
+const jobIndicatorMaxPips = 4
+
+func (l *WindowDataLoad) JobWindow() *Window {
+	if l.Win.win != nil || !l.Win.LoadByName() {
+		return l.Win.win
+	}
+	// The output of commands goes to a window found by name, which is created when the first
+	// output arrives. If it already exists the job is shown on it.
+	w, _ := editor.FindWindowForFile(l.Win.winName)
+	return w
+}
+
+func (j GtExecutorJob) JobWindow() *Window {
+	return j.winDataLoad.JobWindow()
+}
+
+func (f *EditableModify) JobWindow() *Window {
+	return editor.windowOfEditable(f.Editable)
+}
+
+// windowOfEditable returns the window whose tag or body is ed, or nil if there is none.
+func (e *Editor) windowOfEditable(ed *editable) *Window {
+	for _, w := range e.Windows() {
+		if ed == &w.Body.editable || ed == &w.Tag.editable {
+			return w
+		}
+	}
+	return nil
+}
+
+// indexJobWindow records that the job works on its window, if it has one.
+func (e *Editor) indexJobWindow(j Job) {
+	jw, ok := j.(JobWindower)
+	if !ok {
+		return
+	}
+	w := jw.JobWindow()
+	if w == nil {
+		return
+	}
+	if e.windowJobs == nil {
+		e.windowJobs = map[*Window][]Job{}
+	}
+	e.windowJobs[w] = append(e.windowJobs[w], j)
+}
+
+// unindexJobWindow removes the job from the jobs of its window.
+func (e *Editor) unindexJobWindow(j Job) {
+	for w, jobs := range e.windowJobs {
+		for i, x := range jobs {
+			if x != j {
+				continue
+			}
+			jobs = append(jobs[:i], jobs[i+1:]...)
+			if len(jobs) == 0 {
+				delete(e.windowJobs, w)
+			} else {
+				e.windowJobs[w] = jobs
+			}
+			return
+		}
+	}
+}
+
+// JobsOfWindow returns the running jobs that work on the window.
+func (e *Editor) JobsOfWindow(w *Window) []Job {
+	return e.windowJobs[w]
+}
+
+// killJobs kills the running jobs that work on the window.
+func (w *Window) killJobs() {
+	jobs := append([]Job(nil), editor.JobsOfWindow(w)...)
+	for _, j := range jobs {
+		editor.killJob(j)
+	}
+}
+
+// drawJobIndicator draws the activity indicator in the layout box of a window that has running
+// jobs: a square that moves around the inside of the box, and a pip along the bottom for each
+// job when there is more than one. It asks for the next frame of the animation.
+func (l *layoutBox) drawJobIndicator(gtx layout.Context, w, h int) {
+	if l.window == nil || editor == nil {
+		return
+	}
+	n := len(editor.JobsOfWindow(l.window))
+	if n == 0 {
+		return
+	}
+
+	paint.ColorOp{Color: l.style.FgColor}.Add(gtx.Ops)
+
+	sz := w / 3
+	if sz < 2 {
+		sz = 2
+	}
+	corners := []image.Point{
+		{2, 2},
+		{w - 2 - sz, 2},
+		{w - 2 - sz, h - 2 - sz},
+		{2, h - 2 - sz},
+	}
+	frame := gtx.Now.UnixNano() / int64(jobIndicatorFrameInterval)
+	p := corners[frame%int64(len(corners))]
+	st := clip.Rect{Min: p, Max: p.Add(image.Pt(sz, sz))}.Push(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	st.Pop()
+
+	if n > 1 {
+		pips := min(n, jobIndicatorMaxPips)
+		for i := 0; i < pips; i++ {
+			x := 2 + i*(w-4)/jobIndicatorMaxPips
+			st := clip.Rect{Min: image.Pt(x, h-2), Max: image.Pt(x+max((w-4)/jobIndicatorMaxPips-1, 1), h)}.Push(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+			st.Pop()
+		}
+	}
+
+	gtx.Execute(op.InvalidateCmd{At: gtx.Now.Add(jobIndicatorFrameInterval)})
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestJobsOfWindow(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var win, other *Window
+	var load *WindowDataLoad
+	var modify *EditableModify
+	var counts []int
+	var killed bool
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		other = editor.NewWindow(nil)
+
+		load = &WindowDataLoad{DataLoad: *NewDataLoad(), Jobname: "load", Win: NewWindowHolder(win)}
+		modify = &EditableModify{DataLoad: *NewDataLoad(), Jobname: "modify", Editable: &win.Body.editable}
+		editor.AddJob(load)
+		editor.AddJob(modify)
+		counts = append(counts, len(editor.JobsOfWindow(win)), len(editor.JobsOfWindow(other)))
+
+		editor.RemoveJob(modify)
+		counts = append(counts, len(editor.JobsOfWindow(win)))
+
+		win.killJobs()
+		killed = load.Killed()
+		editor.RemoveJob(load)
+		counts = append(counts, len(editor.JobsOfWindow(win)))
+	})
+
+	expected := []int{2, 0, 1, 0}
+	for i := range expected {
+		if counts[i] != expected[i] {
+			t.Fatalf("expected the window to have %v jobs as they were added and removed but it had %v", expected, counts)
+		}
+	}
+	if !killed {
+		t.Fatalf("expected the job of the window to be killed")
+	}
+}
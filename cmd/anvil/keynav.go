@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+)
+
+// focusAction is an action bound to a key that moves the keyboard focus between windows rather
+// than acting on the editable that has focus.
+type focusAction int
+
+const (
+	focusNextWindow focusAction = iota
+	focusPrevWindow
+	focusNextCol
+	focusPrevCol
+	focusTagOrBody
+)
+
+// KeyBindings map presses of keys, together with the modifiers held, to focus actions. They are
+// checked before a key press is handled by the editable that has focus.
+type KeyBindings struct {
+	actions []boundKeyAction
+}
+
+// keyBinding is a key, named as by gio, and the exact modifiers that must be held when it is
+// pressed.
+type keyBinding struct {
+	name key.Name
+	mods key.Modifiers
+}
+
+type boundKeyAction struct {
+	binding keyBinding
+	action  focusAction
+}
+
+var keyNames = map[string]key.Name{
+	"up":       key.NameUpArrow,
+	"down":     key.NameDownArrow,
+	"left":     key.NameLeftArrow,
+	"right":    key.NameRightArrow,
+	"enter":    key.NameReturn,
+	"return":   key.NameReturn,
+	"tab":      key.NameTab,
+	"space":    key.NameSpace,
+	"escape":   key.NameEscape,
+	"esc":      key.NameEscape,
+	"home":     key.NameHome,
+	"end":      key.NameEnd,
+	"pageup":   key.NamePageUp,
+	"pagedown": key.NamePageDown,
+}
+
+// parseKeyBinding parses a binding like "ctrl+alt+down": any number of modifiers followed by a
+// key, separated by plus signs. The key is one of the names in keyNames, a function key like
+// "f5", or a single character.
+func parseKeyBinding(s string) (b keyBinding, err error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "+")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if i == len(parts)-1 {
+			b.name, err = parseKeyName(p, s)
+			return
+		}
+
+		m, ok := mouseModifierNames[p]
+		if !ok {
+			err = fmt.Errorf("unknown modifier '%s' in '%s'", p, s)
+			return
+		}
+		b.mods |= m
+	}
+	return
+}
+
+func parseKeyName(p, binding string) (key.Name, error) {
+	if n, ok := keyNames[p]; ok {
+		return n, nil
+	}
+
+	if n, err := strconv.Atoi(strings.TrimPrefix(p, "f")); err == nil && p[0] == 'f' && n >= 1 && n <= 12 {
+		return key.Name(strings.ToUpper(p)), nil
+	}
+
+	if len([]rune(p)) == 1 {
+		return key.Name(strings.ToUpper(p)), nil
+	}
+	return "", fmt.Errorf("unknown key '%s' in '%s'", p, binding)
+}
+
+func (b keyBinding) matches(ev *key.Event) bool {
+	return b.name == ev.Name && b.mods == ev.Modifiers
+}
+
+// NewKeyBindings builds the bindings from the key settings. Bindings that can't be parsed are
+// left out and reported in the returned error.
+func NewKeyBindings(s KeySettings) (*KeyBindings, error) {
+	var kb KeyBindings
+	var errs []string
+
+	add := func(name string, bindings []string, action focusAction) {
+		for _, str := range bindings {
+			b, err := parseKeyBinding(str)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+			kb.actions = append(kb.actions, boundKeyAction{binding: b, action: action})
+		}
+	}
+
+	add("focus-next", s.FocusNext, focusNextWindow)
+	add("focus-prev", s.FocusPrev, focusPrevWindow)
+	add("focus-next-col", s.FocusNextCol, focusNextCol)
+	add("focus-prev-col", s.FocusPrevCol, focusPrevCol)
+	add("focus-tag", s.FocusTag, focusTagOrBody)
+
+	if len(errs) > 0 {
+		return &kb, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return &kb, nil
+}
+
+// lookup returns the action bound to the key press ev, if there is one.
+func (kb *KeyBindings) lookup(ev *key.Event) (a focusAction, ok bool) {
+	for _, b := range kb.actions {
+		if b.binding.matches(ev) {
+			return b.action, true
+		}
+	}
+	return
+}
+
+var (
+	keyBindingsOnce sync.Once
+	keyBindings     *KeyBindings
+)
+
+// currentKeyBindings returns the bindings built from the key settings, building them the first
+// time it is called.
+func currentKeyBindings() *KeyBindings {
+	keyBindingsOnce.Do(func() {
+		var err error
+		keyBindings, err = NewKeyBindings(settings.Keys)
+		if err != nil {
+			editor.AppendError("", fmt.Sprintf("Invalid bindings in the key settings were ignored: %v", err))
+		}
+	})
+	return keyBindings
+}
+
+// moveFocus performs the focus action a starting from the window from. If from is nil the
+// focus moves to a window in the column col, or in the first column if col is also nil.
+// It returns false if there is no window to move the focus to.
+func (e *Editor) moveFocus(from *Window, col *Col, a focusAction) bool {
+	if from == nil {
+		if a == focusTagOrBody {
+			return false
+		}
+		w := e.firstWindowForFocus(col, a == focusPrevWindow)
+		if w == nil {
+			return false
+		}
+		w.focusFromKeyboard(false)
+		return true
+	}
+
+	switch a {
+	case focusNextWindow, focusPrevWindow:
+		wins := from.col.windowsInFocusOrder()
+		i := indexOfWindow(wins, from)
+		if i < 0 || len(wins) < 2 {
+			return false
+		}
+		if a == focusNextWindow {
+			i = (i + 1) % len(wins)
+		} else {
+			i = (i - 1 + len(wins)) % len(wins)
+		}
+		wins[i].focusFromKeyboard(false)
+	case focusNextCol, focusPrevCol:
+		cols := e.colsForFocus()
+		i := -1
+		for j, c := range cols {
+			if c == from.col {
+				i = j
+			}
+		}
+		if i < 0 || len(cols) < 2 {
+			return false
+		}
+		if a == focusNextCol {
+			i = (i + 1) % len(cols)
+		} else {
+			i = (i - 1 + len(cols)) % len(cols)
+		}
+		cols[i].windowAtY(from.TopY).focusFromKeyboard(false)
+	case focusTagOrBody:
+		from.focusFromKeyboard(e.focusedEditable != &from.Tag.editable)
+	}
+	return true
+}
+
+// firstWindowForFocus returns the first window of col, or the last if last is true. If col is
+// nil or has no windows the first visible column with windows is used instead.
+func (e *Editor) firstWindowForFocus(col *Col, last bool) *Window {
+	wins := col.windowsInFocusOrder()
+	if len(wins) == 0 {
+		cols := e.colsForFocus()
+		if len(cols) == 0 {
+			return nil
+		}
+		wins = cols[0].windowsInFocusOrder()
+	}
+	if last {
+		return wins[len(wins)-1]
+	}
+	return wins[0]
+}
+
+// colsForFocus returns the visible columns that have windows, from left to right.
+func (e *Editor) colsForFocus() []*Col {
+	var cols []*Col
+	for _, c := range e.Cols {
+		if c.Visible() && len(c.windowsInFocusOrder()) > 0 {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// windowsInFocusOrder returns the windows of the column from top to bottom, followed by those
+// that are not positioned yet. Clones made by Zerox are separate windows.
+func (c *Col) windowsInFocusOrder() []*Window {
+	if c == nil {
+		return nil
+	}
+	wins := make([]*Window, 0, len(c.Windows)+len(c.unpositioned))
+	wins = append(wins, c.Windows...)
+	return append(wins, c.unpositioned...)
+}
+
+// windowAtY returns the positioned window of the column that spans the height y, or the first
+// window if none does. The column must have windows.
+func (c *Col) windowAtY(y int) *Window {
+	wins := c.windowsInFocusOrder()
+	w := wins[0]
+	for _, x := range c.Windows {
+		if x.TopY <= y {
+			w = x
+		}
+	}
+	return w
+}
+
+func indexOfWindow(wins []*Window, w *Window) int {
+	for i, x := range wins {
+		if x == w {
+			return i
+		}
+	}
+	return -1
+}
+
+// focusFromKeyboard gives the tag or body of the window the keyboard focus at the next layout.
+// Columns don't scroll, so to bring the window into view it takes the place of the maximized
+// window in its column if there is one, or it is grown if its body is too small to see.
+func (w *Window) focusFromKeyboard(tag bool) {
+	if c := w.col; c != nil {
+		if max := c.MaximizedWindow(); max != nil && max != w {
+			c.MinimizeAllExcept(w)
+		} else if c.windowIndex(w) >= 0 && w.BodyHeight() < w.layout.lineHeight() {
+			c.Grow(w)
+		}
+	}
+
+	ed := &w.Body.blockEditable
+	if tag {
+		ed = &w.Tag.blockEditable
+	}
+	ed.AddOpForNextLayout(func(gtx layout.Context) {
+		ed.SetFocus(gtx)
+	})
+	editor.SignalRedrawRequired()
+}
+
+func (c CommandExecutor) CmdFocusnext(ctx *CmdContext) {
+	c.moveFocus("Focusnext", focusNextWindow)
+}
+
+func (c CommandExecutor) CmdFocusprev(ctx *CmdContext) {
+	c.moveFocus("Focusprev", focusPrevWindow)
+}
+
+func (c CommandExecutor) CmdFocusnextcol(ctx *CmdContext) {
+	c.moveFocus("Focusnextcol", focusNextCol)
+}
+
+func (c CommandExecutor) CmdFocusprevcol(ctx *CmdContext) {
+	c.moveFocus("Focusprevcol", focusPrevCol)
+}
+
+func (c CommandExecutor) CmdFocustag(ctx *CmdContext) {
+	c.moveFocus("Focustag", focusTagOrBody)
+}
+
+// moveFocus performs the focus action a starting from the window the command was executed in,
+// or from the window that has focus when it was executed in a column or editor tag.
+func (c CommandExecutor) moveFocus(cmd string, a focusAction) {
+	var from *Window
+	var col *Col
+	switch v := c.source.(type) {
+	case *Window:
+		from = v
+	case *Col:
+		col = v
+	}
+	if from == nil && col == nil {
+		from = editor.focusedWindow
+	}
+
+	if !editor.moveFocus(from, col, a) {
+		editor.AppendError("", fmt.Sprintf("%s: there is no window to move the focus to", cmd))
+	}
+}
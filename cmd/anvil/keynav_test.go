@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+func TestParseKeyBinding(t *testing.T) {
+	tests := []struct {
+		binding  string
+		expected keyBinding
+		err      bool
+	}{
+		{"ctrl+alt+down", keyBinding{key.NameDownArrow, key.ModCtrl | key.ModAlt}, false},
+		{" Shift + F5 ", keyBinding{"F5", key.ModShift}, false},
+		{"cmd+j", keyBinding{"J", key.ModCommand}, false},
+		{"hyper+down", keyBinding{}, true},
+		{"ctrl+f13", keyBinding{}, true},
+	}
+
+	for _, tc := range tests {
+		b, err := parseKeyBinding(tc.binding)
+		if (err != nil) != tc.err || (!tc.err && b != tc.expected) {
+			t.Fatalf("parsing %q: expected %+v (error %v) but got %+v (%v)", tc.binding, tc.expected, tc.err, b, err)
+		}
+	}
+}
+
+func TestFocusMovesBetweenWindowsAndColumns(t *testing.T) {
+	startHeadlessEditor(t)
+	gtx := layout.Context{Ops: new(op.Ops)}
+
+	var a, b, clone, other *Window
+	onMainGoroutine(func() {
+		a = editor.Cols[0].NewWindow()
+		b = editor.Cols[0].NewWindow()
+		clone, _ = b.Zerox()
+		other = editor.NewCol().NewWindow()
+	})
+	if clone == nil || clone.col != editor.Cols[0] {
+		t.Fatalf("expected the clone to be made in the first column")
+	}
+
+	// layoutAll performs the operations queued for the next layout, as drawing the windows would.
+	layoutAll := func() {
+		for _, w := range []*Window{a, b, clone, other} {
+			w.Tag.opsForNextLayout.Perform(gtx)
+			w.Body.opsForNextLayout.Perform(gtx)
+		}
+	}
+	do := func(cmd string, from *Window) {
+		t.Helper()
+		onMainGoroutine(func() {
+			NewCommandExecutor(from).Do(cmd, &CmdContext{})
+			layoutAll()
+		})
+	}
+
+	do("Focusnext", a)
+	if editor.focusedWindow != b {
+		t.Fatalf("expected Focusnext to focus the second window")
+	}
+	do("Focusnext", b)
+	if editor.focusedWindow != clone {
+		t.Fatalf("expected Focusnext to focus the clone as a window of its own")
+	}
+	do("Focusnext", clone)
+	if editor.focusedWindow != a {
+		t.Fatalf("expected Focusnext to wrap around to the first window")
+	}
+	do("Focusprev", a)
+	if editor.focusedWindow != clone {
+		t.Fatalf("expected Focusprev to wrap around to the last window")
+	}
+
+	do("Focusnextcol", a)
+	if editor.focusedWindow != other {
+		t.Fatalf("expected Focusnextcol to focus the window in the second column")
+	}
+	do("Focusnextcol", other)
+	if editor.focusedWindow != a {
+		t.Fatalf("expected Focusnextcol to wrap around to the first column")
+	}
+
+	do("Focustag", a)
+	if editor.focusedEditable != &a.Tag.editable {
+		t.Fatalf("expected Focustag to focus the tag of the window")
+	}
+	do("Focustag", a)
+	if editor.focusedEditable != &a.Body.editable {
+		t.Fatalf("expected Focustag to move the focus back to the body")
+	}
+
+	// The default key binding moves the focus before the body sees the key.
+	onMainGoroutine(func() {
+		a.Body.SetText([]byte("one\ntwo\n"))
+		a.Body.setToOneCursorIndex(0)
+		a.Body.Key(gtx, &key.Event{Name: key.NameDownArrow, Modifiers: key.ModCtrl | key.ModAlt, State: key.Press})
+		layoutAll()
+	})
+	if editor.focusedWindow != b || a.Body.firstCursorIndex() != 0 {
+		t.Fatalf("expected ctrl+alt+down to focus the next window without moving the cursor")
+	}
+}
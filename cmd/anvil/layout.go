@@ -8,6 +8,7 @@ import (
 	"gioui.org/unit"
 	"github.com/jeffwilliams/anvil/internal/runes"
 	"github.com/jeffwilliams/anvil/internal/typeset"
+	"golang.org/x/image/math/fixed"
 )
 
 type OpsForNextLayout []LayoutOp
@@ -119,6 +120,7 @@ type layouter struct {
 	lineSpacing      unit.Dp
 	cachedFontSize   int
 	cachedLineHeight int
+	cachedSpaceWidth fixed.Int26_6
 	cachedMetric     unit.Metric
 }
 
@@ -193,9 +195,26 @@ func (l *layouter) lineHeight() int {
 	return lh
 }
 
+// spaceWidth returns the width of the space glyph in the current font.
+func (l *layouter) spaceWidth() fixed.Int26_6 {
+	size := l.curFontSize()
+	if l.cachedSpaceWidth != 0 {
+		return l.cachedSpaceWidth
+	}
+
+	w, err := typeset.CalculateAdvance(' ', l.curFont(), size)
+	if err != nil {
+		log(LogCatgUI, "spaceWidth: error calculating width: %v\n", err)
+		return fixed.I(size / 2)
+	}
+	l.cachedSpaceWidth = w
+	return w
+}
+
 func (l *layouter) invalidateCache() {
 	l.cachedFontSize = 0
 	l.cachedLineHeight = 0
+	l.cachedSpaceWidth = 0
 }
 
 func (l *layouter) lineSpacingScaled() int {
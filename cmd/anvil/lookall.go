@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jeffwilliams/anvil/internal/runes"
+)
+
+// lookallHighlightDuration is how long the matches found by Lookall stay highlighted in their
+// windows.
+const lookallHighlightDuration = 30 * time.Second
+
+// lookallMaxMatchesPerWindow is the most matches Lookall lists for one window.
+const lookallMaxMatchesPerWindow = 1000
+
+// lastLookall is the most recent Lookall. Its highlights are removed when another Lookall is
+// started. It is only used by the editor goroutine.
+var lastLookall *lookallJob
+
+// parseLookallArgs parses the arguments to Lookall: an optional -dirty flag followed by the term
+// to look for. The term is a regular expression if it is surrounded by slashes.
+func parseLookallArgs(args []string) (re *regexp.Regexp, dirtyOnly bool, err error) {
+	if len(args) > 0 && args[0] == "-dirty" {
+		dirtyOnly = true
+		args = args[1:]
+	}
+
+	term := strings.Join(args, " ")
+	if term == "" {
+		err = fmt.Errorf("a term to look for is required")
+		return
+	}
+
+	if len(term) > 1 && strings.HasPrefix(term, "/") && strings.HasSuffix(term, "/") {
+		re, err = regexp.Compile(term[1 : len(term)-1])
+		if err != nil {
+			err = fmt.Errorf("invalid regular expression: %v", err)
+		}
+		return
+	}
+
+	re = regexp.MustCompile(regexp.QuoteMeta(term))
+	return
+}
+
+func (c CommandExecutor) CmdLookall(ctx *CmdContext) {
+	re, dirtyOnly, err := parseLookallArgs(ctx.Args)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Lookall: %v", err))
+		return
+	}
+
+	if lastLookall != nil {
+		lastLookall.Kill()
+		lastLookall.removeHighlights()
+	}
+
+	job := &lookallJob{
+		re:         re,
+		kill:       make(chan struct{}),
+		highlights: map[*Window][]*SyntaxInterval{},
+	}
+
+	// The bodies are copied here, on the editor goroutine, so that the search can run in the
+	// background without racing with edits.
+	for _, w := range editor.Windows() {
+		if w.file == "" || w.IsErrorsWindow() || (dirtyOnly && !w.IsDirty()) {
+			continue
+		}
+		job.bodies = append(job.bodies, lookallBody{
+			win:        w,
+			name:       w.file,
+			text:       w.Body.Bytes(),
+			generation: w.Body.generation,
+		})
+	}
+
+	lastLookall = job
+	editor.AddJob(job)
+	go job.run()
+}
+
+// lookallJob searches the bodies of the open windows for Lookall. The matches in each window are
+// written to +Errors and highlighted as soon as that window is searched.
+type lookallJob struct {
+	re     *regexp.Regexp
+	bodies []lookallBody
+	kill   chan struct{}
+	once   sync.Once
+
+	// The fields below are only used by the editor goroutine.
+	matches    int
+	windows    int
+	highlights map[*Window][]*SyntaxInterval
+}
+
+// lookallBody is a copy of the body of a window taken when Lookall was started.
+type lookallBody struct {
+	win        *Window
+	name       string
+	text       []byte
+	generation int
+}
+
+// lookallMatch is a match in a body. start and end are rune offsets, and line and col count
+// from 1.
+type lookallMatch struct {
+	start, end int
+	line, col  int
+}
+
+func (j *lookallJob) Name() string {
+	return "Lookall"
+}
+
+func (j *lookallJob) Kill() {
+	j.once.Do(func() { close(j.kill) })
+}
+
+func (j *lookallJob) killed() bool {
+	select {
+	case <-j.kill:
+		return true
+	default:
+		return false
+	}
+}
+
+func (j *lookallJob) run() {
+	for _, b := range j.bodies {
+		if j.killed() {
+			break
+		}
+		yieldToInteractiveWork()
+		matches, truncated := b.search(j.re)
+		if len(matches) > 0 {
+			editor.WorkChan() <- lookallFound{job: j, body: b, matches: matches, truncated: truncated}
+		}
+	}
+	editor.WorkChan() <- lookallDone{job: j}
+}
+
+// search returns the non-empty matches of re in the body, and whether there were more than
+// lookallMaxMatchesPerWindow.
+func (b lookallBody) search(re *regexp.Regexp) (matches []lookallMatch, truncated bool) {
+	w := runes.NewWalker(b.text)
+	line, lineStart, pos := 1, 0, 0
+	for _, loc := range re.FindAllIndex(b.text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		if len(matches) == lookallMaxMatchesPerWindow {
+			truncated = true
+			break
+		}
+
+		skipped := b.text[pos:loc[0]]
+		line += bytes.Count(skipped, []byte{'\n'})
+		if i := bytes.LastIndexByte(skipped, '\n'); i >= 0 {
+			lineStart = pos + i + 1
+		}
+		pos = loc[0]
+
+		w.ForwardBytes(loc[0] - w.BytePos())
+		start := w.RunePos()
+		w.ForwardBytes(loc[1] - loc[0])
+
+		matches = append(matches, lookallMatch{
+			start: start,
+			end:   w.RunePos(),
+			line:  line,
+			col:   utf8.RuneCount(b.text[lineStart:loc[0]]) + 1,
+		})
+	}
+	return
+}
+
+// found lists the matches in +Errors and highlights them if the window still exists and its
+// body hasn't changed since it was copied.
+func (j *lookallJob) found(b lookallBody, matches []lookallMatch, truncated bool) {
+	j.windows++
+	j.matches += len(matches)
+
+	var buf strings.Builder
+	for _, m := range matches {
+		fmt.Fprintf(&buf, "%s:%d:%d\n", b.name, m.line, m.col)
+	}
+	if truncated {
+		fmt.Fprintf(&buf, "%s: only the first %d matches are listed\n", b.name, lookallMaxMatchesPerWindow)
+	}
+	editor.AppendError("", buf.String())
+
+	if editor.FindWindowForId(b.win.Id) != b.win || b.win.Body.generation != b.generation {
+		return
+	}
+	for _, m := range matches {
+		if h := b.win.Body.addManualHighlight(m.start, m.end, WindowStyle.Syntax.KeywordColor); h != nil {
+			j.highlights[b.win] = append(j.highlights[b.win], h)
+		}
+	}
+}
+
+func (j *lookallJob) removeHighlights() {
+	for w, hls := range j.highlights {
+		w.Body.RemoveManualHighlights(hls)
+	}
+	j.highlights = map[*Window][]*SyntaxInterval{}
+	editor.SignalRedrawRequired()
+}
+
+func (j *lookallJob) summary() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "Lookall: %d matches in %d of %d windows", j.matches, j.windows, len(j.bodies))
+	if j.killed() {
+		buf.WriteString(", stopped by Kill")
+	}
+	buf.WriteRune('\n')
+	return buf.String()
+}
+
+// lookallFound and lookallDone are serviced in the low band, which keeps them in order.
+type lookallFound struct {
+	job       *lookallJob
+	body      lookallBody
+	matches   []lookallMatch
+	truncated bool
+}
+
+func (l lookallFound) Service() (done bool) {
+	if !l.job.killed() {
+		l.job.found(l.body, l.matches, l.truncated)
+	}
+	return false
+}
+
+func (l lookallFound) Job() Job {
+	return l.job
+}
+
+func (l lookallFound) priority() workPriority {
+	return workPriorityLow
+}
+
+type lookallDone struct {
+	job *lookallJob
+}
+
+func (l lookallDone) Service() (done bool) {
+	editor.AppendError("", l.job.summary())
+	job := l.job
+	time.AfterFunc(lookallHighlightDuration, func() {
+		editor.WorkChan() <- basicWork{job.removeHighlights}
+	})
+	return true
+}
+
+func (l lookallDone) Job() Job {
+	return l.job
+}
+
+func (l lookallDone) priority() workPriority {
+	return workPriorityLow
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLookallArgs(t *testing.T) {
+	re, dirtyOnly, err := parseLookallArgs([]string{"-dirty", "a.b", "c"})
+	if err != nil || !dirtyOnly || re.String() != `a\.b c` {
+		t.Fatalf("expected a literal term for dirty windows but got %v, %v, %v", re, dirtyOnly, err)
+	}
+
+	re, dirtyOnly, err = parseLookallArgs([]string{"/a.b/"})
+	if err != nil || dirtyOnly || re.String() != "a.b" {
+		t.Fatalf("expected a regular expression but got %v, %v, %v", re, dirtyOnly, err)
+	}
+
+	if _, _, err = parseLookallArgs([]string{"-dirty"}); err == nil {
+		t.Fatalf("expected a missing term to fail")
+	}
+	if _, _, err = parseLookallArgs([]string{"/(/"}); err == nil {
+		t.Fatalf("expected an invalid regular expression to fail")
+	}
+}
+
+func TestLookallBodySearch(t *testing.T) {
+	b := lookallBody{text: []byte("héllo wörld\nsay héllo\n\nhéllo")}
+	matches, truncated := b.search(regexp.MustCompile("héllo"))
+
+	expected := []lookallMatch{
+		{start: 0, end: 5, line: 1, col: 1},
+		{start: 16, end: 21, line: 2, col: 5},
+		{start: 23, end: 28, line: 4, col: 1},
+	}
+	if truncated || len(matches) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, matches)
+	}
+	for i := range expected {
+		if matches[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, matches)
+		}
+	}
+
+	b = lookallBody{text: []byte(strings.Repeat("x", lookallMaxMatchesPerWindow+1))}
+	matches, truncated = b.search(regexp.MustCompile("x"))
+	if !truncated || len(matches) != lookallMaxMatchesPerWindow {
+		t.Fatalf("expected the matches to be truncated but got %d matches", len(matches))
+	}
+}
+
+func TestLookallListsAndHighlightsMatchesInOpenWindows(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var clean, dirty *Window
+	onMainGoroutine(func() {
+		clean = editor.NewWindow(nil)
+		clean.SetFilenameAndTag("/tmp/lookall/clean.go", typeFile)
+		clean.Body.SetText([]byte("func needle() {}\n"))
+		clean.markTextAsUnchanged()
+
+		dirty = editor.NewWindow(nil)
+		dirty.SetFilenameAndTag("/tmp/lookall/dirty.go", typeFile)
+		dirty.Body.SetText([]byte("x\n  needle()\n"))
+
+		NewCommandExecutor(clean).CmdLookall(&CmdContext{Args: []string{"needle"}})
+	})
+
+	summary := waitForLookall(t)
+	if !strings.Contains(summary, "/tmp/lookall/clean.go:1:6\n") || !strings.Contains(summary, "/tmp/lookall/dirty.go:2:3\n") {
+		t.Fatalf("expected the matches in both windows to be listed but got %q", summary)
+	}
+	if !strings.Contains(summary, "Lookall: 2 matches in 2 of 2 windows") {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+
+	onMainGoroutine(func() {
+		if len(clean.Body.manualHighlighting) != 1 || len(dirty.Body.manualHighlighting) != 1 {
+			t.Fatalf("expected the matches to be highlighted")
+		}
+		h := dirty.Body.manualHighlighting[0]
+		if h.Start() != 4 || h.End() != 10 {
+			t.Fatalf("expected the match in the dirty window to be highlighted at [4,10) but it was at [%d,%d)", h.Start(), h.End())
+		}
+
+		errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		errs.Body.SetTextStringNoUndo("")
+		NewCommandExecutor(clean).CmdLookall(&CmdContext{Args: []string{"-dirty", "/need.e/"}})
+
+		if len(clean.Body.manualHighlighting) != 0 {
+			t.Fatalf("expected the highlights of the previous Lookall to be removed")
+		}
+	})
+
+	summary = waitForLookall(t)
+	if strings.Contains(summary, "clean.go") || !strings.Contains(summary, "/tmp/lookall/dirty.go:2:3\n") {
+		t.Fatalf("expected only the dirty window to be searched but got %q", summary)
+	}
+}
+
+// waitForLookall waits for Lookall to write its summary to +Errors and returns the contents of
+// +Errors.
+func waitForLookall(t *testing.T) (errs string) {
+	for i := 0; i < 50 && !strings.Contains(errs, "Lookall:"); i++ {
+		time.Sleep(20 * time.Millisecond)
+		onMainGoroutine(func() {
+			if w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf("")); w != nil {
+				errs = w.Body.String()
+			}
+		})
+	}
+	if !strings.Contains(errs, "Lookall:") {
+		t.Fatalf("Lookall didn't finish")
+	}
+	return
+}
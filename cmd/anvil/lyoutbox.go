@@ -27,6 +27,9 @@ type layoutBox struct {
 	lastGrowPointerEventPress *pointer.Event
 	lastGrowYOffset           int
 	eventInterceptor          *events.EventInterceptor
+	// pressKilledJobs is true if the last press killed the jobs of the window, so that
+	// releasing the button doesn't grow it.
+	pressKilledJobs bool
 }
 
 type layoutBoxStyle struct {
@@ -96,6 +99,13 @@ func (l *layoutBox) onPointerPrimaryButtonPress(ps *PointerState) {
 	log(LogCatgWin, "primary button press on layout box at %s\n", ps.currentPointerEvent.Position)
 	l.pressPos = ps.currentPointerEvent.Position
 	l.dragging = false
+	l.pressKilledJobs = false
+
+	if l.window != nil && ps.currentPointerEvent.Modifiers&key.ModAlt != 0 && len(editor.JobsOfWindow(l.window)) > 0 {
+		l.window.killJobs()
+		l.pressKilledJobs = true
+		return
+	}
 
 	if l.col != nil {
 		if ps.currentPointerEvent.Modifiers&key.ModShift != 0 {
@@ -132,7 +142,10 @@ func (l *layoutBox) onPointerDrag(ps *PointerState) {
 
 func (l *layoutBox) onPointerRelease(ps *PointerState) {
 	log(LogCatgWin, "button release for %s on layout box. col: %p, window %p\n", ps.currentPointerEvent.Buttons, l.col, l.window)
-	if l.dragging {
+	if l.pressKilledJobs {
+		l.pressKilledJobs = false
+		l.dragging = false
+	} else if l.dragging {
 		// For some reason button release doesn't indicate which button was released...
 		if l.pressPos.Y != ps.currentPointerEvent.Position.Y {
 			if l.window != nil {
@@ -194,12 +207,23 @@ func (l *layoutBox) draw(gtx layout.Context) layout.Dimensions {
 	// Append the operation that fills the clip region with the pen
 	paint.PaintOp{}.Add(gtx.Ops)
 	st2.Pop()
+
+	if l.window != nil && !l.window.IsWritable() {
+		// Draw a bar across the box to show the file is not writable
+		h := int(l.lineHeight())
+		st3 := clip.Rect{Min: image.Pt(0, h/2-1), Max: image.Pt(gw, h/2+1)}.Push(gtx.Ops)
+		paint.ColorOp{Color: l.style.FgColor}.Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		st3.Pop()
+	}
+
+	l.drawJobIndicator(gtx, gw, int(l.lineHeight()))
 	return layout.Dimensions{Size: image.Point{X: gw, Y: int(l.lineHeight())}}
 }
 
 func (l *layoutBox) bgColor() color.NRGBA {
 	bgColor := l.style.BgColor
-	if l.window != nil && l.window.bodyChangedFromDisk() && !l.window.IsErrorsWindow() && l.window.fileType != typeDir {
+	if l.window != nil && l.window.IsDirty() {
 		bgColor = l.style.UnsavedBgColor
 	}
 	return bgColor
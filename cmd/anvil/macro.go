@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+)
+
+// Macros records a sequence of editing operations performed by the user so that it
+// can be replayed later. The operations recorded are the logical ones performed by the
+// editables (inserting text, the keys that move cursors, delete text or search, and
+// executed commands) rather than pointer events, so that replaying doesn't depend on where
+// the text happens to be on the screen.
+type Macros struct {
+	recording bool
+	playing   bool
+	// handlingKey is true while a recorded key is being handled, so that commands executed
+	// as a result of the key aren't recorded as well.
+	handlingKey bool
+	steps       []MacroStep
+	last        []MacroStep
+}
+
+type MacroStepKind int
+
+const (
+	MacroInsertText MacroStepKind = iota
+	MacroKey
+	MacroCommand
+)
+
+type MacroStep struct {
+	Kind MacroStepKind
+	// Text is the inserted text for MacroInsertText steps, and the command for MacroCommand steps.
+	Text      string        `json:",omitempty"`
+	Args      []string      `json:",omitempty"`
+	Key       key.Name      `json:",omitempty"`
+	Modifiers key.Modifiers `json:",omitempty"`
+}
+
+type MacroState struct {
+	Steps []MacroStep
+}
+
+func (m *Macros) Record() error {
+	if m.recording {
+		return fmt.Errorf("a macro is already being recorded")
+	}
+	if m.playing {
+		return fmt.Errorf("can't record a macro while one is playing")
+	}
+	m.recording = true
+	m.steps = nil
+	return nil
+}
+
+// Stop stops recording and makes the recorded steps the macro that Play replays.
+func (m *Macros) Stop() error {
+	if !m.recording {
+		return fmt.Errorf("no macro is being recorded")
+	}
+	m.recording = false
+	m.last = m.steps
+	m.steps = nil
+	return nil
+}
+
+// Play replays the last recorded macro count times. Each step is applied to the editable
+// that has keyboard focus when the step is replayed, and each repetition is undone as a
+// single change in the editables it modifies.
+func (m *Macros) Play(gtx layout.Context, count int) error {
+	if m.recording {
+		return fmt.Errorf("can't play a macro while one is being recorded")
+	}
+	if m.playing {
+		return fmt.Errorf("a macro is already playing")
+	}
+	if len(m.last) == 0 {
+		return fmt.Errorf("no macro has been recorded")
+	}
+
+	m.playing = true
+	defer func() { m.playing = false }()
+
+	for i := 0; i < count; i++ {
+		err := m.playOnce(gtx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Macros) playOnce(gtx layout.Context) error {
+	var inTransaction []*editable
+	defer func() {
+		for _, e := range inTransaction {
+			e.text.EndOuterTransaction()
+		}
+	}()
+
+	for _, s := range m.last {
+		e := editor.getFocusedEditable()
+		if e == nil {
+			return fmt.Errorf("no editable has the keyboard focus to play the macro in")
+		}
+
+		if !editablesContain(inTransaction, e) {
+			e.text.StartOuterTransaction()
+			inTransaction = append(inTransaction, e)
+		}
+
+		s.apply(gtx, e)
+	}
+	return nil
+}
+
+func editablesContain(l []*editable, e *editable) bool {
+	for _, v := range l {
+		if v == e {
+			return true
+		}
+	}
+	return false
+}
+
+func (s MacroStep) apply(gtx layout.Context, e *editable) {
+	switch s.Kind {
+	case MacroInsertText:
+		e.InsertText(s.Text)
+	case MacroKey:
+		e.Key(gtx, &key.Event{Name: s.Key, Modifiers: s.Modifiers, State: key.Press})
+	case MacroCommand:
+		e.adapter.execute(e, gtx, s.Text, s.Args)
+	}
+}
+
+// keyPressed records the key ev if a macro is being recorded, and then calls handle to handle it.
+func (m *Macros) keyPressed(ev *key.Event, handle func()) {
+	if !m.recording || m.playing || ev.State != key.Press || !isRecordableKey(ev) {
+		handle()
+		return
+	}
+
+	m.steps = append(m.steps, MacroStep{Kind: MacroKey, Key: ev.Name, Modifiers: ev.Modifiers})
+	m.handlingKey = true
+	defer func() { m.handlingKey = false }()
+	handle()
+}
+
+// isRecordableKey returns false for keys whose effect depends on the layout of the text on
+// the screen or on the state of the mouse, which wouldn't be the same when the macro is played.
+func isRecordableKey(ev *key.Event) bool {
+	switch ev.Name {
+	case key.NameCtrl, key.NameShift, key.NameAlt, key.NameSuper, key.NameCommand,
+		key.NamePageUp, key.NamePageDown:
+		return false
+	case "E", "Y":
+		return !ev.Modifiers.Contain(key.ModCtrl)
+	}
+	return true
+}
+
+func (m *Macros) textInserted(text string) {
+	if !m.recording || m.playing {
+		return
+	}
+
+	l := len(m.steps)
+	if l > 0 && m.steps[l-1].Kind == MacroInsertText {
+		m.steps[l-1].Text += text
+		return
+	}
+	m.steps = append(m.steps, MacroStep{Kind: MacroInsertText, Text: text})
+}
+
+func (m *Macros) commandExecuted(cmd string, args []string) {
+	if !m.recording || m.playing || m.handlingKey {
+		return
+	}
+
+	if isMacroCommand(cmd) {
+		return
+	}
+
+	m.steps = append(m.steps, MacroStep{Kind: MacroCommand, Text: cmd, Args: args})
+}
+
+func isMacroCommand(cmd string) bool {
+	f := strings.Fields(cmd)
+	return len(f) > 0 && f[0] == "Macro"
+}
+
+func (m *Macros) String() string {
+	var buf strings.Builder
+	for _, s := range m.last {
+		switch s.Kind {
+		case MacroInsertText:
+			fmt.Fprintf(&buf, "insert %q\n", s.Text)
+		case MacroKey:
+			if s.Modifiers != 0 {
+				fmt.Fprintf(&buf, "key %s-%s\n", s.Modifiers, s.Key)
+			} else {
+				fmt.Fprintf(&buf, "key %s\n", s.Key)
+			}
+		case MacroCommand:
+			fmt.Fprintf(&buf, "execute %s\n", strings.Join(append([]string{s.Text}, s.Args...), " "))
+		}
+	}
+	return buf.String()
+}
+
+func (m *Macros) State() MacroState {
+	return MacroState{Steps: m.last}
+}
+
+func (m *Macros) SetState(state MacroState) {
+	m.last = state.Steps
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"gioui.org/io/key"
+	"gioui.org/layout"
+)
+
+func TestMacroRecordAndPlay(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var text, undone, reloaded string
+	var steps []MacroStep
+	onMainGoroutine(func() {
+		win := editor.NewWindow(nil)
+		e := &win.Body.editable
+		e.SetText([]byte("a\nb\nc\nd\n"))
+		e.CursorIndices = []int{0}
+		editor.setFocusedEditable(e, win)
+
+		press := func(name key.Name, mods key.Modifiers) {
+			ev := key.Event{Name: name, Modifiers: mods, State: key.Press}
+			editor.Macros.keyPressed(&ev, func() { e.Key(layout.Context{}, &ev) })
+		}
+		typ := func(s string) {
+			editor.Macros.textInserted(s)
+			e.InsertText(s)
+		}
+
+		editor.Macros.Record()
+		typ("(")
+		press(key.NameEnd, 0)
+		typ(")")
+		press(key.NameShift, 0)
+		press(key.NameRightArrow, 0)
+		editor.Macros.commandExecuted("Macro stop", nil)
+		editor.Macros.Stop()
+		steps = editor.Macros.State().Steps
+
+		editor.Macros.Play(layout.Context{}, 2)
+		text = string(e.Bytes())
+
+		e.applyUndoOrRedo(e.text.Undo, -1)
+		undone = string(e.Bytes())
+
+		// The macro is saved in the state and can be played after loading it.
+		b, err := json.Marshal(editor.State())
+		if err != nil {
+			t.Errorf("marshalling the state failed: %v", err)
+			return
+		}
+		var state EditorState
+		err = json.Unmarshal(b, &state)
+		if err != nil {
+			t.Errorf("unmarshalling the state failed: %v", err)
+			return
+		}
+		editor.Macros.SetState(MacroState{})
+		editor.Macros.SetState(state.Macro)
+		e.applyUndoOrRedo(e.text.Undo, -1)
+		e.CursorIndices = []int{8}
+		editor.Macros.Play(layout.Context{}, 1)
+		reloaded = string(e.Bytes())
+	})
+
+	expectedSteps := []MacroStep{
+		{Kind: MacroInsertText, Text: "("},
+		{Kind: MacroKey, Key: key.NameEnd},
+		{Kind: MacroInsertText, Text: ")"},
+		{Kind: MacroKey, Key: key.NameRightArrow},
+	}
+	if !reflect.DeepEqual(steps, expectedSteps) {
+		t.Fatalf("expected steps %#v but got %#v", expectedSteps, steps)
+	}
+
+	if text != "(a)\n(b)\n(c)\nd\n" {
+		t.Fatalf("text after playing is %q", text)
+	}
+	if undone != "(a)\n(b)\nc\nd\n" {
+		t.Fatalf("text after undoing one play is %q", undone)
+	}
+	if reloaded != "(a)\nb\nc\n(d)\n" {
+		t.Fatalf("text after playing the reloaded macro is %q", reloaded)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"runtime"
 	"runtime/debug"
+	"time"
 
 	"gioui.org/app"
 	"gioui.org/io/event"
@@ -19,6 +20,7 @@ import (
 	"github.com/jeffwilliams/anvil/internal/ansi"
 	adebug "github.com/jeffwilliams/anvil/internal/debug"
 	"github.com/jeffwilliams/anvil/internal/expr"
+	"github.com/jeffwilliams/anvil/internal/pctbl"
 	"github.com/jeffwilliams/anvil/internal/typeset"
 	"github.com/ogier/pflag"
 )
@@ -30,6 +32,7 @@ var optLoadDumpfile = pflag.StringP("load", "l", "", "Load state from the specif
 var optChdir = pflag.StringP("cd", "d", "", "Change directory to the specified path before starting")
 var optDebugStdout = pflag.BoolP("dbg", "b", false, "Print debug logs to stdout")
 var optPixelSizeFonts = pflag.BoolP("fonts-in-pixels", "f", false, "Consider font sizes in pixels instead of device independent units")
+var optProfileStartup = pflag.Bool("profile-startup", false, "Print how long each phase of startup took once the first frame is drawn")
 
 // TODO: Remove before merging from pre-release to master
 var optAbsWinPath = pflag.BoolP("abs-window-path", "a", false, "When a window is opened for a local file, make the path absolute in the tag. When this flag is not set, paths are not changed (the classic behaviour). ")
@@ -47,20 +50,22 @@ func main() {
 	if *optProfile {
 		startProfiling(ProfileCPU)
 	}
-
-	printSyntaxLexerParseErrors()
+	startupTiming.phase("parse options")
 
 	LoadSettings()
+	startupTiming.phase("load settings")
 	LoadStyle()
-	HirePlumber()
+	startupTiming.phase("load style")
 	ansi.InitColors(WindowStyle.Ansi.AsColors())
 	application = NewApplication()
 	editor = NewEditor(WindowStyle)
+	startupTiming.phase("create editor")
 
-	LoadSshKeys()
 	initDebugging()
+	startDeferredInitialization()
 
 	go ServeLocalAPI()
+	startupTiming.phase("start API")
 
 	var w app.Window
 	application.SetWindow(&w)
@@ -86,18 +91,72 @@ func parseAndValidateOptions() {
 
 var styleLoadedFromFile bool
 
+// LoadStyle loads the style from the style config file. Fonts that must be loaded from font files are
+// replaced by the default font until they are loaded in the background by styleFontsInit, so that
+// they don't delay the first frame.
 func LoadStyle() {
-	style, err := LoadStyleFromConfigFile(&WindowStyle)
+	style, err := ReadStyle(StyleConfigFile(), &WindowStyle)
 	if err != nil {
 		log(LogCatgApp, "Loading style from config file failed: %v", err)
 		return
 	}
 
+	fonts := make([]FontStyle, len(style.Fonts))
+	copy(fonts, style.Fonts)
+	if useDefaultFontsUntilLoaded(&style) {
+		pendingStyleFonts = fonts
+	}
+	// Only the default fonts are left to load, which doesn't require reading any files.
+	loadStyleFonts(&style)
+
 	log(LogCatgApp, "Loaded style from config file %s\n", StyleConfigFile())
 	WindowStyle = style
 	styleLoadedFromFile = true
 }
 
+// pendingStyleFonts are the fonts from the style config file, some of which are being loaded
+// by styleFontsInit. It is nil if there are none to load.
+var pendingStyleFonts []FontStyle
+var loadedStyleFontsErr error
+
+// styleReplacedSinceStartup is set when a style is loaded using a command, in which case the
+// fonts from the style config file are no longer wanted.
+var styleReplacedSinceStartup bool
+
+// styleFontsInit loads the fonts named in the style config file from font files.
+var styleFontsInit = &lazyInit{
+	name: "load style fonts",
+	load: func() {
+		if pendingStyleFonts == nil {
+			return
+		}
+		s := Style{Fonts: pendingStyleFonts}
+		loadedStyleFontsErr = loadStyleFonts(&s)
+	},
+	install: installLoadedStyleFonts,
+}
+
+func installLoadedStyleFonts() {
+	if pendingStyleFonts == nil {
+		return
+	}
+	if loadedStyleFontsErr != nil {
+		log(LogCatgApp, "Loading fonts from style config file failed: %v", loadedStyleFontsErr)
+		return
+	}
+
+	if styleReplacedSinceStartup {
+		return
+	}
+
+	fonts := make([]FontStyle, len(pendingStyleFonts))
+	copy(fonts, pendingStyleFonts)
+	WindowStyle.Fonts = fonts
+	if editor != nil {
+		editor.SetStyle(WindowStyle)
+	}
+}
+
 var settingsLoadedFromFile bool
 var settings = Settings{
 	Ssh: SshSettings{
@@ -105,11 +164,60 @@ var settings = Settings{
 		CacheSize:         5,
 		CloseStdin:        false,
 		ConnectionTimeout: 5,
+		KeepaliveInterval: 15,
+	},
+	General: GeneralSettings{
+		SpillThreshold:       16 * 1024 * 1024,
+		OutputRateLimit:      1024 * 1024,
+		ErrorsMaxSize:        32 * 1024 * 1024,
+		WhitespaceHints:      true,
+		SensitivePatterns:    []string{"*.env", ".env", "*id_rsa*", "*kubeconfig*"},
+		SensitiveIdleTimeout: 120,
+		DirSort:              dirSortByName,
+		DirDots:              true,
+		FileIndexMax:         200000,
+		AtomicSave:           true,
+		OpenallMax:           100,
+		AutosaveInterval:     30,
+		GuessIndentation:     true,
+		TidyTimeout:          10,
+		ScrollPips:           true,
+		ClosedFilesMax:       50,
+		CommandFooterAfter:   10,
+	},
+	Notify: NotifySettings{
+		OnJobFailure: true,
+	},
+	Cues: CueSettings{
+		CommandInterval: 1000,
+	},
+	Completion: CompletionSettings{
+		EnvVars: true,
+		Tags:    true,
+	},
+	Mouse: MouseSettings{
+		Select:         []string{"primary"},
+		Execute:        []string{"tertiary", "cmd+primary"},
+		Acquire:        []string{"secondary"},
+		ExecuteWithArg: []string{"primary"},
+		Cut:            []string{"tertiary"},
+		Paste:          []string{"secondary"},
+		ScrollLines:    3,
+	},
+	Keys: KeySettings{
+		FocusNext:    []string{"ctrl+alt+down"},
+		FocusPrev:    []string{"ctrl+alt+up"},
+		FocusNextCol: []string{"ctrl+alt+right"},
+		FocusPrevCol: []string{"ctrl+alt+left"},
+		FocusTag:     []string{"ctrl+alt+enter"},
 	},
 	Layout: LayoutSettings{
-		EditorTag:         "Newcol Kill Putall Dump Load Exit Help ◊",
-		ColumnTag:         "New Cut Paste Snarf Zerox Delcol",
-		WindowTagUserArea: " Do Look ",
+		EditorTag:           "Newcol Kill Putall Dump Load Exit Help ◊",
+		ColumnTag:           "New Cut Paste Snarf Zerox Delcol",
+		WindowTagUserArea:   " Do Look ",
+		TagPathAbbreviation: tagPathAbbrevNone,
+		TagPathMaxLength:    60,
+		WindowPlacement:     windowPlacementMostFreeSpace,
 	},
 }
 
@@ -128,8 +236,33 @@ func LoadSettings() {
 
 var plumbingLoadedFromFile bool
 
+// plumberInit compiles the plumbing rules from the plumbing config file. They aren't needed
+// until the first attempt to plumb.
+var plumberInit = &lazyInit{
+	name: "compile plumbing rules",
+	load: func() {
+		loadedPlumbingRules, loadedPlumbingRulesErr = LoadPlumbingRulesFromFile(PlumbingConfigFile())
+	},
+	install: installLoadedPlumbingRules,
+}
+
+var loadedPlumbingRules []PlumbingRule
+var loadedPlumbingRulesErr error
+
+// HirePlumber makes sure the plumbing rules from the plumbing config file have been loaded.
 func HirePlumber() {
-	HirePlumberUsingFile(PlumbingConfigFile())
+	plumberInit.ensure()
+}
+
+func installLoadedPlumbingRules() {
+	if loadedPlumbingRulesErr != nil {
+		log(LogCatgApp, "Loading plumbing rules from config file failed: %v\n", loadedPlumbingRulesErr)
+		return
+	}
+
+	log(LogCatgApp, "Loaded plumbing rules from config file %s\n", PlumbingConfigFile())
+	plumber = NewPlumber(loadedPlumbingRules)
+	plumbingLoadedFromFile = true
 }
 
 func HirePlumberUsingFile(path string) error {
@@ -162,6 +295,8 @@ var WindowStyle = Style{
 	TagFgColor:                MustParseHexColor("#f0f0f0"),
 	TagBgColor:                MustParseHexColor("#263859"),
 	TagPathBasenameColor:      MustParseHexColor("#f4a660"),
+	TagEditorAreaColor:        MustParseHexColor("#9aa3b5"),
+	TagLozengeColor:           MustParseHexColor("#8fbfdc"),
 	BodyFgColor:               MustParseHexColor("#f0f0f0"),
 	BodyBgColor:               MustParseHexColor("#17223B"),
 	LayoutBoxFgColor:          MustParseHexColor("#9b2226"),
@@ -185,6 +320,9 @@ var WindowStyle = Style{
 	TabStopInterval:           30, // in pixels
 	LineSpacing:               0,
 	TextLeftPadding:           3,
+	TrailingWhitespaceBgColor: MustParseHexColor("#4a2f3a"),
+	TabGuideColor:             MustParseHexColor("#3d4a66"),
+	GuideColor:                MustParseHexColor("#2c3a5c"),
 	Syntax: SyntaxStyle{
 		// Colors borrowed from vim jellybeans color scheme https://github.com/nanotech/jellybeans.vim/blob/master/colors/jellybeans.vim
 		KeywordColor:      MustParseHexColor("#8fbfdc"), // jellybeans color for PreProc
@@ -201,6 +339,11 @@ var WindowStyle = Style{
 		InsertedColor: MustParseHexColor("#51a151"),
 		DeletedColor:  MustParseHexColor("#ca6565"),
 	},
+	ScrollPips: ScrollPipStyle{
+		SearchMatchColor: MustParseHexColor("#f4a660"),
+		MarkColor:        MustParseHexColor("#8fbfdc"),
+		TintColor:        MustParseHexColor("#c6b6ee"),
+	},
 	Ansi: AnsiStyle{
 		Colors: [16]Color{
 			MustParseHexColor("#000000"),
@@ -328,19 +471,22 @@ func loop(w *app.Window) {
 
 	go func() {
 		for {
+			// Window events, which carry the user's typing and pointing, are handled before
+			// any work that is ready at the same time.
 			select {
 			case e := <-events:
 				handleEvent(e)
 				acks <- struct{}{}
-			case w := <-editor.WorkChan():
-				done := w.Service()
-				if done && w.Job() != nil {
-					editor.RemoveJob(w.Job())
-					if sn, ok := w.Job().(StartNexter); ok {
-						sn.StartNext()
-					}
-				}
+				continue
+			default:
+			}
 
+			select {
+			case e := <-events:
+				handleEvent(e)
+				acks <- struct{}{}
+			case w := <-editor.ReadyWork():
+				handleWork(w)
 				appWindow.Invalidate()
 			}
 		}
@@ -349,6 +495,21 @@ func loop(w *app.Window) {
 	app.Main()
 }
 
+// handleWork performs w on the editor goroutine. If w completes its job the job is removed,
+// and the next job after it is started.
+func handleWork(w Work) {
+	start := time.Now()
+	done := w.Service()
+	editor.workQueue.serviced(w, time.Since(start))
+	if done && w.Job() != nil {
+		editor.RemoveJob(w.Job())
+		if sn, ok := w.Job().(StartNexter); ok {
+			sn.StartNext()
+		}
+	}
+	editor.notifyApiSelectionChanges()
+}
+
 var focusSet bool
 
 func handleEvent(e event.Event) {
@@ -365,6 +526,8 @@ func handleEvent(e event.Event) {
 
 		gtx := app.NewContext(&ops, e)
 		layoutWidgets(gtx)
+		editor.notifyApiSelectionChanges()
+		defer startupTiming.firstFrameDrawn()
 
 		if !focusSet && window != nil {
 			window.SetFocus(gtx)
@@ -376,6 +539,8 @@ func handleEvent(e event.Event) {
 	case app.ConfigEvent:
 		log(LogCatgUI, "window config changed: %v\n", e.Config)
 		application.WindowConfigChanged(&e.Config)
+	case app.ViewEvent:
+		application.SetView(e)
 	}
 }
 
@@ -403,6 +568,7 @@ func initializeEditorIfNeeded() {
 	}
 
 	executeStartupCommands()
+	startAutosave()
 }
 
 //go:embed font/InputMonoCondensed-ExtraLight.ttf
@@ -457,6 +623,13 @@ func Exit(code int) {
 	if *optProfile {
 		stopProfiling()
 	}
+	if editor != nil {
+		for _, w := range editor.Windows() {
+			for _, s := range w.spills {
+				s.Close()
+			}
+		}
+	}
 	os.Exit(code)
 }
 
@@ -486,6 +659,9 @@ func initDebugging() {
 	expr.Debug = func(message string, args ...interface{}) {
 		log(LogCatgExpr, message, args...)
 	}
+	pctbl.ReportViolation = func(message string) {
+		log(LogCatgEd, "Piece table transaction misuse: %s\n", message)
+	}
 }
 
 func executeStartupCommands() {
@@ -96,6 +96,15 @@ func (m *Marks) SetState(state MarkState) {
 	m.marks = state.Marks
 }
 
+// RenameFile makes the marks in the file oldName refer to the file newName.
+func (m *Marks) RenameFile(oldName, newName string) {
+	for _, mark := range m.marks {
+		if mark.FileName == oldName {
+			mark.FileName = newName
+		}
+	}
+}
+
 func (m *Marks) ShiftDueToTextModification(fileName string, startOfChange, lengthOfChange int) {
 	for _, mark := range m.marks {
 		if mark.FileName == fileName {
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+	"sync"
+
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+)
+
+// MouseBindings map presses of the physical mouse buttons, together with the modifiers held, to
+// the actions of editables. The handlers of an editable are registered for the buttons that
+// perform each action by default: the primary button selects, the tertiary button executes and
+// the secondary button acquires. A press that is bound to an action acts as a press of that
+// button until it is released.
+//
+// Chords are bound the same way. A chord binding is only used when the button the chord starts
+// with is held, and it acts as the button that completes the chord.
+type MouseBindings struct {
+	actions []boundMouseAction
+	chords  []boundMouseAction
+}
+
+// mouseBinding is a physical button and the modifiers that must be held when it is pressed.
+type mouseBinding struct {
+	button pointer.Buttons
+	mods   key.Modifiers
+}
+
+type boundMouseAction struct {
+	binding mouseBinding
+	// button is the button whose handlers are run for a press of the binding.
+	button pointer.Buttons
+	// held is the button that must be held for a chord binding to be used.
+	held pointer.Buttons
+}
+
+// boundPress is what a press of a physical button was resolved to.
+type boundPress struct {
+	button pointer.Buttons
+	held   pointer.Buttons
+	// mods are the modifiers that are part of the binding. They are hidden from the handlers
+	// so that, for example, binding execute to cmd+primary doesn't also make it execute with
+	// the behaviour of a cmd-click.
+	mods key.Modifiers
+}
+
+var mouseButtonNames = map[string]pointer.Buttons{
+	"primary":   pointer.ButtonPrimary,
+	"left":      pointer.ButtonPrimary,
+	"secondary": pointer.ButtonSecondary,
+	"right":     pointer.ButtonSecondary,
+	"tertiary":  pointer.ButtonTertiary,
+	"middle":    pointer.ButtonTertiary,
+}
+
+var mouseModifierNames = map[string]key.Modifiers{
+	"ctrl":    key.ModCtrl,
+	"shift":   key.ModShift,
+	"alt":     key.ModAlt,
+	"cmd":     key.ModCommand,
+	"command": key.ModCommand,
+	"super":   key.ModSuper,
+}
+
+// parseMouseBinding parses a binding like "cmd+primary": any number of modifiers followed by a
+// button, separated by plus signs.
+func parseMouseBinding(s string) (b mouseBinding, err error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(s)), "+")
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if i == len(parts)-1 {
+			var ok bool
+			b.button, ok = mouseButtonNames[p]
+			if !ok {
+				err = fmt.Errorf("unknown mouse button '%s' in '%s'", p, s)
+			}
+			return
+		}
+
+		m, ok := mouseModifierNames[p]
+		if !ok {
+			err = fmt.Errorf("unknown modifier '%s' in '%s'", p, s)
+			return
+		}
+		b.mods |= m
+	}
+	return
+}
+
+func (b mouseBinding) matches(button pointer.Buttons, mods key.Modifiers) bool {
+	return b.button == button && mods.Contain(b.mods)
+}
+
+// NewMouseBindings builds the bindings from the mouse settings. Bindings that can't be parsed
+// are left out and reported in the returned error.
+func NewMouseBindings(s MouseSettings) (*MouseBindings, error) {
+	var mb MouseBindings
+	var errs []string
+
+	add := func(list *[]boundMouseAction, name string, bindings []string, button, held pointer.Buttons) {
+		for _, str := range bindings {
+			b, err := parseMouseBinding(str)
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", name, err))
+				continue
+			}
+			*list = append(*list, boundMouseAction{binding: b, button: button, held: held})
+		}
+	}
+
+	add(&mb.actions, "select", s.Select, pointer.ButtonPrimary, 0)
+	add(&mb.actions, "execute", s.Execute, pointer.ButtonTertiary, 0)
+	add(&mb.actions, "acquire", s.Acquire, pointer.ButtonSecondary, 0)
+	add(&mb.chords, "execute-with-arg", s.ExecuteWithArg, pointer.ButtonPrimary, pointer.ButtonTertiary)
+	add(&mb.chords, "cut", s.Cut, pointer.ButtonTertiary, pointer.ButtonPrimary)
+	add(&mb.chords, "paste", s.Paste, pointer.ButtonSecondary, pointer.ButtonPrimary)
+
+	if len(errs) > 0 {
+		return &mb, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return &mb, nil
+}
+
+// resolvePress returns what a press of the physical button with the modifiers mods acts as, when
+// the buttons in held are already held. If a chord can be completed it takes precedence.
+// Otherwise the action binding with the most modifiers that match is used. A press that matches
+// no binding resolves to a zero button, which has no handlers.
+func (mb *MouseBindings) resolvePress(button pointer.Buttons, mods key.Modifiers, held pointer.Buttons) boundPress {
+	best := func(list []boundMouseAction, chord bool) (p boundPress, ok bool) {
+		most := -1
+		for _, a := range list {
+			if chord && !held.Contain(a.held) {
+				continue
+			}
+			if !a.binding.matches(button, mods) {
+				continue
+			}
+			if n := bits.OnesCount32(uint32(a.binding.mods)); n > most {
+				most = n
+				p = boundPress{button: a.button, held: a.held, mods: a.binding.mods}
+				ok = true
+			}
+		}
+		return
+	}
+
+	if held != 0 {
+		if p, ok := best(mb.chords, true); ok {
+			return p
+		}
+	}
+	p, _ := best(mb.actions, false)
+	return p
+}
+
+var (
+	mouseBindingsOnce sync.Once
+	mouseBindings     *MouseBindings
+)
+
+// currentMouseBindings returns the bindings built from the mouse settings, building them the
+// first time it is called.
+func currentMouseBindings() *MouseBindings {
+	mouseBindingsOnce.Do(func() {
+		var err error
+		mouseBindings, err = NewMouseBindings(settings.Mouse)
+		if err != nil {
+			editor.AppendError("", fmt.Sprintf("Invalid bindings in the mouse settings were ignored: %v", err))
+		}
+	})
+	return mouseBindings
+}
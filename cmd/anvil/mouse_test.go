@@ -0,0 +1,203 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	toml "github.com/pelletier/go-toml"
+)
+
+func TestParseMouseBinding(t *testing.T) {
+	tests := []struct {
+		s      string
+		button pointer.Buttons
+		mods   key.Modifiers
+		ok     bool
+	}{
+		{"primary", pointer.ButtonPrimary, 0, true},
+		{"Middle", pointer.ButtonTertiary, 0, true},
+		{"cmd+primary", pointer.ButtonPrimary, key.ModCommand, true},
+		{"ctrl + shift + right", pointer.ButtonSecondary, key.ModCtrl | key.ModShift, true},
+		{"hyper+left", 0, 0, false},
+		{"ctrl+fourth", 0, 0, false},
+		{"ctrl", 0, 0, false},
+	}
+
+	for _, tc := range tests {
+		b, err := parseMouseBinding(tc.s)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: expected ok %v but got error %v", tc.s, tc.ok, err)
+			continue
+		}
+		if tc.ok && (b.button != tc.button || b.mods != tc.mods) {
+			t.Errorf("%s: expected button %v and modifiers %v but got %v and %v", tc.s, tc.button, tc.mods, b.button, b.mods)
+		}
+	}
+}
+
+func TestMouseSettingsDecodedOverDefaults(t *testing.T) {
+	s := Settings{Mouse: settings.Mouse}
+	err := toml.NewDecoder(strings.NewReader("[mouse]\nexecute=[\"ctrl+left\"]\n")).Decode(&s)
+	if err != nil {
+		t.Fatalf("decoding failed: %v", err)
+	}
+	if strings.Join(s.Mouse.Execute, ",") != "ctrl+left" {
+		t.Fatalf("expected execute to be replaced but it is %v", s.Mouse.Execute)
+	}
+	if strings.Join(s.Mouse.Select, ",") != "primary" {
+		t.Fatalf("expected select to keep its default but it is %v", s.Mouse.Select)
+	}
+
+	_, err = NewMouseBindings(MouseSettings{Select: []string{"left", "bogus"}})
+	if err == nil || !strings.Contains(err.Error(), "select") {
+		t.Fatalf("expected an error naming the select setting but got %v", err)
+	}
+}
+
+// mouseTestState returns a PointerState using the bindings from s, with handlers that record the
+// names of the handlers called in calls.
+func mouseTestState(t *testing.T, s MouseSettings, calls *[]string) *PointerState {
+	b, err := NewMouseBindings(s)
+	if err != nil {
+		t.Fatalf("building bindings failed: %v", err)
+	}
+
+	var ps PointerState
+	ps.SetBindings(b)
+	record := func(name string) PointerEventHandler {
+		return func(ps *PointerState) {
+			call := name
+			if ps.currentPointerEvent.Modifiers != 0 {
+				call += "+mods"
+			}
+			*calls = append(*calls, call)
+		}
+	}
+	ps.Handler(PointerEventMatch{pointer.Press, pointer.ButtonPrimary}, record("select"))
+	ps.Handler(PointerEventMatch{pointer.Release, pointer.ButtonPrimary}, record("select-release"))
+	ps.Handler(PointerEventMatch{pointer.Press, pointer.ButtonTertiary}, record("execute"))
+	ps.Handler(PointerEventMatch{pointer.Release, pointer.ButtonTertiary}, record("execute-release"))
+	ps.Handler(PointerEventMatch{pointer.Press, pointer.ButtonSecondary}, record("acquire"))
+	ps.Handler(PointerEventMatch{pointer.Release, pointer.ButtonSecondary}, record("acquire-release"))
+	ps.ChordHandler(pointer.ButtonTertiary, PointerEventMatch{pointer.Press, pointer.ButtonPrimary}, record("execute-with-arg"))
+	ps.ChordHandler(pointer.ButtonPrimary, PointerEventMatch{pointer.Release, pointer.ButtonTertiary}, record("cut"))
+	ps.ChordHandler(pointer.ButtonPrimary, PointerEventMatch{pointer.Press, pointer.ButtonSecondary}, record("paste"))
+	return &ps
+}
+
+type mouseTestEvent struct {
+	kind    pointer.Kind
+	buttons pointer.Buttons
+	mods    key.Modifiers
+}
+
+func sendMouseTestEvents(ps *PointerState, events []mouseTestEvent) {
+	for _, e := range events {
+		ev := pointer.Event{Kind: e.kind, Buttons: e.buttons, Modifiers: e.mods}
+		ps.Event(&ev, ps.gtx)
+		ps.InvokeHandlers()
+	}
+}
+
+func TestDefaultMouseBindings(t *testing.T) {
+	const (
+		prim = pointer.ButtonPrimary
+		sec  = pointer.ButtonSecondary
+		ter  = pointer.ButtonTertiary
+	)
+
+	tests := []struct {
+		name     string
+		events   []mouseTestEvent
+		expected string
+	}{
+		{
+			"click",
+			[]mouseTestEvent{{pointer.Press, prim, key.ModAlt}, {pointer.Release, 0, key.ModAlt}},
+			"select+mods,select-release+mods",
+		},
+		{
+			"command-click executes",
+			[]mouseTestEvent{{pointer.Press, prim, key.ModCommand}, {pointer.Release, 0, key.ModCommand}},
+			"execute,execute-release",
+		},
+		{
+			"command released before the button",
+			[]mouseTestEvent{{pointer.Press, prim, key.ModCommand}, {pointer.Release, 0, 0}},
+			"execute,execute-release",
+		},
+		{
+			"cut and paste",
+			[]mouseTestEvent{
+				{pointer.Press, prim, 0},
+				{pointer.Press, prim | ter, 0},
+				{pointer.Release, prim, 0},
+				{pointer.Press, prim | sec, 0},
+				{pointer.Release, prim, 0},
+				{pointer.Release, 0, 0},
+			},
+			"select,execute,cut,paste,acquire-release,select-release",
+		},
+		{
+			"select released before the cut chord",
+			[]mouseTestEvent{
+				{pointer.Press, prim, 0},
+				{pointer.Press, prim | ter, 0},
+				{pointer.Release, ter, 0},
+				{pointer.Release, 0, 0},
+			},
+			"select,execute,select-release,execute-release",
+		},
+		{
+			"execute with argument",
+			[]mouseTestEvent{
+				{pointer.Press, ter, 0},
+				{pointer.Press, ter | prim, 0},
+				{pointer.Release, ter, 0},
+				{pointer.Release, 0, 0},
+			},
+			"execute,execute-with-arg,select-release,execute-release",
+		},
+	}
+
+	for _, tc := range tests {
+		var calls []string
+		ps := mouseTestState(t, settings.Mouse, &calls)
+		sendMouseTestEvents(ps, tc.events)
+		if got := strings.Join(calls, ","); got != tc.expected {
+			t.Errorf("%s: expected handlers %s but got %s", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestRemappedMouseBindings(t *testing.T) {
+	var calls []string
+	ps := mouseTestState(t, MouseSettings{
+		Select:         []string{"left"},
+		Execute:        []string{"ctrl+left"},
+		Acquire:        []string{"alt+left"},
+		ExecuteWithArg: []string{"right"},
+		Paste:          []string{"shift+right"},
+	}, &calls)
+
+	sendMouseTestEvents(ps, []mouseTestEvent{
+		// The middle button isn't bound to anything.
+		{pointer.Press, pointer.ButtonTertiary, 0},
+		{pointer.Release, 0, 0},
+		{pointer.Press, pointer.ButtonPrimary, key.ModCtrl | key.ModShift},
+		{pointer.Press, pointer.ButtonPrimary | pointer.ButtonSecondary, 0},
+		{pointer.Release, pointer.ButtonPrimary, 0},
+		{pointer.Release, 0, 0},
+		{pointer.Press, pointer.ButtonPrimary, key.ModAlt},
+		{pointer.Release, 0, key.ModAlt},
+		{pointer.Press, pointer.ButtonPrimary, 0},
+		{pointer.Press, pointer.ButtonPrimary | pointer.ButtonSecondary, key.ModShift},
+	})
+
+	expected := "execute+mods,execute-with-arg,select-release,execute-release,acquire,acquire-release,select,paste"
+	if got := strings.Join(calls, ","); got != expected {
+		t.Fatalf("expected handlers %s but got %s", expected, got)
+	}
+}
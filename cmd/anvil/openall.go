@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// openallEntry is a path listed in the text given to Openall, and the seek at the end of it.
+type openallEntry struct {
+	path string
+	seek seek
+}
+
+// parseOpenallLines returns an entry for each distinct non-empty line of text. Lines whose seek
+// can't be parsed are returned in errs.
+func parseOpenallLines(text string) (entries []openallEntry, errs []string) {
+	seen := map[string]bool{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || seen[line] {
+			continue
+		}
+		seen[line] = true
+
+		path, seek, err := parseSeekFromFilename(line)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", line, err))
+			continue
+		}
+		entries = append(entries, openallEntry{path: path, seek: seek})
+	}
+	return
+}
+
+func (c CommandExecutor) CmdOpenall(ctx *CmdContext) {
+	win, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Openall: must be executed in a window")
+		return
+	}
+
+	var text string
+	if ctx.Editable.SelectionsPresent() {
+		var buf strings.Builder
+		for _, sel := range ctx.Editable.selectionsInDisplayOrder() {
+			buf.WriteString(ctx.Editable.textOfSelection(sel))
+			buf.WriteRune('\n')
+		}
+		text = buf.String()
+	} else {
+		text = ctx.Editable.String()
+	}
+
+	entries, errs := parseOpenallLines(text)
+
+	job := &openallJob{
+		finder: NewFileFinder(win),
+		dir:    ctx.Dir,
+		kill:   make(chan struct{}),
+		seen:   map[string]bool{},
+		failed: errs,
+	}
+
+	max := settings.General.OpenallMax
+	if max > 0 && len(entries) > max {
+		job.skipped = len(entries) - max
+		entries = entries[:max]
+	}
+	job.entries = entries
+
+	editor.AddJob(job)
+	go job.run()
+}
+
+// openallJob opens the files listed for Openall. Each file is opened by a separate piece of work
+// so that the editor stays responsive while many files are opened.
+type openallJob struct {
+	finder  *FileFinder
+	dir     string
+	entries []openallEntry
+	kill    chan struct{}
+	once    sync.Once
+
+	// The fields below are only used by the editor goroutine.
+	seen    map[string]bool
+	opened  int
+	failed  []string
+	skipped int
+}
+
+func (j *openallJob) Name() string {
+	return "Openall"
+}
+
+func (j *openallJob) Kill() {
+	j.once.Do(func() { close(j.kill) })
+}
+
+func (j *openallJob) killed() bool {
+	select {
+	case <-j.kill:
+		return true
+	default:
+		return false
+	}
+}
+
+func (j *openallJob) run() {
+	for _, e := range j.entries {
+		if j.killed() {
+			break
+		}
+		editor.WorkChan() <- openallOpen{job: j, entry: e}
+	}
+	editor.WorkChan() <- openallDone{job: j}
+}
+
+func (j *openallJob) open(e openallEntry) {
+	realpath, existsLocal, err := j.finder.Find(e.path)
+	if err != nil {
+		j.failed = append(j.failed, fmt.Sprintf("%s: %v", e.path, err))
+		return
+	}
+
+	path := realpath.String()
+	if j.seen[path] {
+		return
+	}
+	j.seen[path] = true
+
+	if !realpath.IsRemote() && !existsLocal {
+		j.failed = append(j.failed, fmt.Sprintf("%s: the file does not exist", path))
+		return
+	}
+
+	var opts LoadFileOpts
+	if !e.seek.empty() {
+		opts = LoadFileOpts{GoTo: e.seek, SelectBehaviour: selectText, GrowBodyBehaviour: dontGrowBodyIfTooSmall}
+	}
+	if editor.LoadFileOpts(path, opts) == nil {
+		j.failed = append(j.failed, fmt.Sprintf("%s: opening the file failed", path))
+		return
+	}
+	j.opened++
+}
+
+func (j *openallJob) summary() string {
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "Openall: opened %d files", j.opened)
+	if len(j.failed) > 0 {
+		fmt.Fprintf(&buf, ", %d failed", len(j.failed))
+	}
+	if j.killed() {
+		buf.WriteString(", stopped by Kill")
+	}
+	if j.skipped > 0 {
+		fmt.Fprintf(&buf, ", skipped the last %d since at most %d are opened (see the openall-max setting)", j.skipped, settings.General.OpenallMax)
+	}
+	buf.WriteRune('\n')
+	for _, f := range j.failed {
+		fmt.Fprintf(&buf, "  %s\n", f)
+	}
+	return buf.String()
+}
+
+type openallOpen struct {
+	job   *openallJob
+	entry openallEntry
+}
+
+func (l openallOpen) Service() (done bool) {
+	if !l.job.killed() {
+		l.job.open(l.entry)
+	}
+	return false
+}
+
+func (l openallOpen) Job() Job {
+	return l.job
+}
+
+type openallDone struct {
+	job *openallJob
+}
+
+func (l openallDone) Service() (done bool) {
+	editor.AppendError(l.job.dir, l.job.summary())
+	return true
+}
+
+func (l openallDone) Job() Job {
+	return l.job
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOpenallLines(t *testing.T) {
+	entries, errs := parseOpenallLines("a.go\n\n  b.go:12:3  \na.go\nc.go!(\n")
+
+	if len(errs) != 1 || !strings.HasPrefix(errs[0], "c.go!(") {
+		t.Fatalf("expected an error for the bad regex but got %v", errs)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries but got %d: %v", len(entries), entries)
+	}
+	if entries[0].path != "a.go" || !entries[0].seek.empty() {
+		t.Fatalf("expected a.go without a seek but got %v", entries[0])
+	}
+	if entries[1].path != "b.go" || entries[1].seek.line != 12 || entries[1].seek.col != 3 {
+		t.Fatalf("expected b.go at 12:3 but got %v", entries[1])
+	}
+}
+
+func TestOpenallOpensListedFiles(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	for _, name := range []string{"a.txt", "b.txt", "list"} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte("one\ntwo\n"), 0644)
+		if err != nil {
+			t.Fatalf("writing file failed: %v", err)
+		}
+	}
+	err := os.Mkdir(filepath.Join(dir, "sub"), 0755)
+	if err != nil {
+		t.Fatalf("making directory failed: %v", err)
+	}
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag(filepath.Join(dir, "list"), typeFile)
+		win.Body.SetText([]byte("a.txt\nb.txt:2\n./a.txt\nsub\nmissing.txt\n"))
+		NewCommandExecutor(win).CmdOpenall(&CmdContext{Dir: dir, Editable: &win.Body.editable})
+	})
+
+	var summary string
+	for i := 0; i < 50 && summary == ""; i++ {
+		time.Sleep(20 * time.Millisecond)
+		onMainGoroutine(func() {
+			if w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(dir)); w != nil {
+				summary = w.Body.String()
+			}
+		})
+	}
+
+	if !strings.Contains(summary, "opened 3 files, 1 failed") || !strings.Contains(summary, "missing.txt") {
+		t.Fatalf("unexpected summary %q", summary)
+	}
+
+	onMainGoroutine(func() {
+		for _, name := range []string{"a.txt", "b.txt", "sub"} {
+			if _, count := editor.FindWindowForFile(filepath.Join(dir, name)); count != 1 {
+				t.Errorf("expected one window for %s but there are %d", name, count)
+			}
+		}
+	})
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// outputBatchInterval is how long output from a job is gathered before it is appended to a
+	// window, so that output arriving in many small chunks within a frame is appended once.
+	outputBatchInterval = 16 * time.Millisecond
+	// maxOutputBatch is the most bytes of output gathered before they are appended anyway.
+	maxOutputBatch = 256 * 1024
+)
+
+// outputBatcher gathers the chunks of output from a job that arrive within outputBatchInterval
+// of the first so that they are appended to the window as one change instead of one per chunk.
+type outputBatcher struct {
+	buf   []byte
+	timer *time.Timer
+	armed bool
+}
+
+// add appends x to the output gathered so far. It returns true if enough is gathered that it
+// should be appended now.
+func (b *outputBatcher) add(x []byte) (full bool) {
+	b.buf = append(b.buf, x...)
+	if !b.armed {
+		if b.timer == nil {
+			b.timer = time.NewTimer(outputBatchInterval)
+		} else {
+			b.timer.Reset(outputBatchInterval)
+		}
+		b.armed = true
+	}
+	return len(b.buf) >= maxOutputBatch
+}
+
+// ready returns a channel that receives when the gathered output should be appended, or nil if
+// none is gathered.
+func (b *outputBatcher) ready() <-chan time.Time {
+	if !b.armed {
+		return nil
+	}
+	return b.timer.C
+}
+
+// take returns the output gathered so far and starts gathering again.
+func (b *outputBatcher) take() []byte {
+	if b.armed {
+		b.timer.Stop()
+		b.armed = false
+	}
+	x := b.buf
+	b.buf = nil
+	return x
+}
+
+// outputRateLimiter caps how many bytes of output from a job are appended to a window each
+// second. The output over the cap is dropped, and a marker saying how much was dropped is
+// written before the next output that is let through.
+type outputRateLimiter struct {
+	// rate is the most bytes let through each second. If it is 0 all output is let through.
+	rate    int
+	second  time.Time
+	used    int
+	dropped int64
+	// endsWithNewline is true if the last output let through ended a line.
+	endsWithNewline bool
+}
+
+func newOutputRateLimiter(rate int) outputRateLimiter {
+	return outputRateLimiter{rate: rate, endsWithNewline: true}
+}
+
+// limit returns the part of x that may be appended at the time now. It is cut at the end of a
+// line if one fits.
+func (l *outputRateLimiter) limit(x []byte, now time.Time) []byte {
+	if l.rate <= 0 || len(x) == 0 {
+		return x
+	}
+
+	if now.Sub(l.second) >= time.Second {
+		l.second = now
+		l.used = 0
+	}
+
+	keep := len(x)
+	if room := l.rate - l.used; keep > room {
+		keep = max(room, 0)
+		if i := bytes.LastIndexByte(x[:keep], '\n'); i >= 0 {
+			keep = i + 1
+		} else if l.used > 0 {
+			// Rather than split the line, wait for the next second.
+			keep = 0
+		} else {
+			for keep > 0 && !utf8.RuneStart(x[keep]) {
+				keep--
+			}
+		}
+	}
+
+	if keep == 0 {
+		l.dropped += int64(len(x))
+		return nil
+	}
+
+	var out []byte
+	if l.dropped > 0 {
+		out = l.marker()
+	}
+	l.dropped += int64(len(x) - keep)
+	x = x[:keep]
+	l.used += len(x)
+	l.endsWithNewline = x[len(x)-1] == '\n'
+	return append(out, x...)
+}
+
+// flush returns the marker for the output dropped since the last output let through, if any.
+// It is called when the output ends.
+func (l *outputRateLimiter) flush() []byte {
+	if l.dropped == 0 {
+		return nil
+	}
+	return l.marker()
+}
+
+func (l *outputRateLimiter) marker() []byte {
+	m := fmt.Sprintf("…output truncated (%d bytes dropped)…\n", l.dropped)
+	if !l.endsWithNewline {
+		m = "\n" + m
+	}
+	l.dropped = 0
+	l.endsWithNewline = true
+	return []byte(m)
+}
+
+// errorsWindowFullMarker is appended to an +Errors window that reached errors-max-size when
+// errors-ring is not set. Nothing more is appended until the window has room again.
+const errorsWindowFullMarker = "…output truncated (the window is full; see errors-max-size)…\n"
+
+// limitErrorsWindowSize returns the part of b to append to the body of the +Errors window c so
+// that it holds no more than errors-max-size characters. If errors-ring is set the start of the
+// body is deleted to make room instead.
+func (c *Window) limitErrorsWindowSize(b []byte) []byte {
+	limit := settings.General.ErrorsMaxSize
+	if limit <= 0 || !c.IsErrorsWindow() {
+		return b
+	}
+
+	size, n := c.Body.text.Len(), utf8.RuneCount(b)
+	if size+n <= limit {
+		c.errorsWindowFull = false
+		return b
+	}
+
+	if !settings.General.ErrorsRing {
+		if c.errorsWindowFull {
+			return nil
+		}
+		c.errorsWindowFull = true
+		if text := c.Body.Bytes(); len(text) > 0 && text[len(text)-1] != '\n' {
+			return []byte("\n" + errorsWindowFullMarker)
+		}
+		return []byte(errorsWindowFullMarker)
+	}
+
+	// Delete whole lines from the start so that the body is left three quarters full, so that
+	// it doesn't have to be trimmed again on the next append.
+	if n >= limit*3/4 {
+		b = lastRunes(b, limit*3/4)
+		c.Body.deleteFromPieceTable(0, size)
+		return b
+	}
+	trim := size + n - limit*3/4
+	text := c.Body.Bytes()
+	off := byteOffsetOfRune(text, trim)
+	if off > 0 && text[off-1] == '\n' {
+		// The trimmed text already ends a line.
+	} else if i := bytes.IndexByte(text[off:], '\n'); i >= 0 {
+		trim += utf8.RuneCount(text[off : off+i+1])
+	} else {
+		trim = size
+	}
+	c.Body.deleteFromPieceTable(0, trim)
+	return b
+}
+
+// lastRunes returns the end of b starting at the first whole line within its last n runes, or
+// just its last n runes if they are all one line.
+func lastRunes(b []byte, n int) []byte {
+	b = b[byteOffsetOfRune(b, utf8.RuneCount(b)-n):]
+	if i := bytes.IndexByte(b, '\n'); i >= 0 && i+1 < len(b) {
+		b = b[i+1:]
+	}
+	return b
+}
+
+// byteOffsetOfRune returns the byte offset in b of the rune with index n.
+func byteOffsetOfRune(b []byte, n int) int {
+	off := 0
+	for i := 0; i < n && off < len(b); i++ {
+		_, sz := utf8.DecodeRune(b[off:])
+		off += sz
+	}
+	return off
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOutputRateLimiter(t *testing.T) {
+	l := newOutputRateLimiter(10)
+	start := time.Now()
+
+	tests := []struct {
+		data     string
+		at       time.Duration
+		expected string
+	}{
+		{"abc\ndef\nghi\n", 0, "abc\ndef\n"},
+		{"jkl\n", 100 * time.Millisecond, ""},
+		{"mno\n", time.Second, "…output truncated (8 bytes dropped)…\nmno\n"},
+		{"pqrstuvwxyz", 1500 * time.Millisecond, ""},
+		{"pqrstuvwxyz", 2 * time.Second, "…output truncated (11 bytes dropped)…\npqrstuvwxy"},
+	}
+
+	for _, tc := range tests {
+		got := string(l.limit([]byte(tc.data), start.Add(tc.at)))
+		if got != tc.expected {
+			t.Fatalf("limiting %q: expected %q but got %q", tc.data, tc.expected, got)
+		}
+	}
+
+	if got := string(l.flush()); got != "\n…output truncated (1 bytes dropped)…\n" {
+		t.Fatalf("expected the marker for the dropped output at the end but got %q", got)
+	}
+	if got := l.flush(); got != nil {
+		t.Fatalf("expected no marker when nothing more was dropped but got %q", got)
+	}
+}
+
+func TestFastOutputIsBatchedAndLimited(t *testing.T) {
+	newTestEditor()
+
+	old := settings.General.OutputRateLimit
+	settings.General.OutputRateLimit = 1000
+	defer func() { settings.General.OutputRateLimit = old }()
+
+	load := NewDataLoad()
+	wl := &WindowDataLoad{
+		DataLoad: *load,
+		Win:      NewWindowHolderForName("/tmp/+Errors"),
+		Jobname:  "yes",
+		Tail:     true,
+	}
+
+	const chunks = 20000
+	go func() {
+		for i := 0; i < chunks; i++ {
+			load.Contents <- []byte("y\n")
+		}
+		close(load.Contents)
+		close(load.Errs)
+	}()
+
+	c := make(chan Work)
+	wl.Start(c)
+
+	var out bytes.Buffer
+	items := 0
+	for w := range c {
+		items++
+		if d, ok := w.(*winLoadData); ok {
+			out.Write(d.data)
+		}
+		if _, ok := w.(*winLoadDone); ok {
+			break
+		}
+	}
+
+	if items > chunks/100 {
+		t.Fatalf("expected the %d chunks of output to be sent in far fewer work items but %d were sent", chunks, items)
+	}
+
+	// All the output is either in the window or counted as dropped.
+	marker := regexp.MustCompile(`…output truncated \((\d+) bytes dropped\)…\n`)
+	dropped := 0
+	for _, m := range marker.FindAllStringSubmatch(out.String(), -1) {
+		n, _ := strconv.Atoi(m[1])
+		dropped += n
+	}
+	kept := marker.ReplaceAllString(out.String(), "")
+	if dropped == 0 || strings.Count(kept, "y\n")*2 != len(kept) || len(kept)+dropped != chunks*2 {
+		t.Fatalf("expected %d bytes to be kept or dropped but %d were kept and %d dropped", chunks*2, len(kept), dropped)
+	}
+}
+
+func TestErrorsWindowSizeIsLimited(t *testing.T) {
+	newTestEditor()
+
+	oldMax, oldRing := settings.General.ErrorsMaxSize, settings.General.ErrorsRing
+	defer func() { settings.General.ErrorsMaxSize, settings.General.ErrorsRing = oldMax, oldRing }()
+	settings.General.ErrorsMaxSize = 20
+
+	win := editor.NewWindow(nil)
+	win.SetFilenameAndTag("+Errors", typeFile)
+
+	win.Append([]byte("one\ntwo\nthree\n"))
+	win.Append([]byte("four\nfive\n"))
+	win.Append([]byte("six\n"))
+	if win.Body.String() != "one\ntwo\nthree\n"+errorsWindowFullMarker {
+		t.Fatalf("expected the output past the limit to be dropped but the body is %q", win.Body.String())
+	}
+
+	settings.General.ErrorsRing = true
+	win.Body.SetTextStringNoUndo("one\ntwo\nthree\n")
+	win.Append([]byte("four\nfive\n"))
+	if win.Body.String() != "four\nfive\n" {
+		t.Fatalf("expected the start of the body to be deleted to make room but the body is %q", win.Body.String())
+	}
+}
@@ -321,6 +321,70 @@ func (p Packer) Grow(change Packable, extra float32) []Packable {
 	return p.all
 }
 
+// GrowFromLargest increases the size of the specified packable by up to `extra`. Unlike Grow
+// the space is taken from the largest of the other packables first, and only once that one
+// can't give up any more space are others shrunk. No packable is shrunk below the header height,
+// and `protect` (which may be nil) is not shrunk below `protectMin`.
+func (p Packer) GrowFromLargest(change Packable, extra float32, protect Packable, protectMin float32) []Packable {
+	ci := p.itemIndex(change)
+	if ci < 0 {
+		return p.all
+	}
+
+	sizes := make([]float32, len(p.all))
+	for i := range p.all {
+		sizes[i] = p.ItemSize(i)
+	}
+
+	for extra > 0 {
+		donor, avail := p.largestDonor(sizes, ci, protect, protectMin)
+		if donor < 0 {
+			break
+		}
+
+		amt := avail
+		if amt > extra {
+			amt = extra
+		}
+		log(LogCatgPack, "GrowFromLargest: taking %f from item %d\n", amt, donor)
+		sizes[donor] -= amt
+		sizes[ci] += amt
+		extra -= amt
+	}
+
+	coord := float32(0)
+	for i, w := range p.all {
+		w.SetPackingCoord(round(coord))
+		coord += sizes[i]
+	}
+
+	return p.all
+}
+
+// largestDonor returns the index of the packable other than the one at index `except` that
+// has the most space that could be given up, and how much space that is. If no packable
+// has any space to give up the returned index is -1.
+func (p Packer) largestDonor(sizes []float32, except int, protect Packable, protectMin float32) (index int, avail float32) {
+	index = -1
+	for i, sz := range sizes {
+		if i == except {
+			continue
+		}
+		a := sz - p.minSizeOf(p.all[i], protect, protectMin)
+		if a > avail {
+			index, avail = i, a
+		}
+	}
+	return
+}
+
+func (p Packer) minSizeOf(item, protect Packable, protectMin float32) float32 {
+	if item == protect && protectMin > p.headerHeight {
+		return protectMin
+	}
+	return p.headerHeight
+}
+
 func (pk Packer) itemIndex(p Packable) int {
 	for i, n := range pk.all {
 		if p == n {
@@ -391,6 +455,52 @@ func (p *Packer) SpaceEvenly() []Packable {
 	return p.all
 }
 
+// Resize sets the packables to the given sizes, in order, starting at coordinate 0.
+func (p *Packer) Resize(sizes []float32) []Packable {
+	coord := float32(0)
+	for i, w := range p.all {
+		w.SetPackingCoord(round(coord))
+		if i < len(sizes) {
+			coord += sizes[i]
+		}
+	}
+
+	return p.all
+}
+
+// MoveToIndex moves the packable at index from so that it is at index to, shifting the packables
+// between them. Each packable keeps its size.
+func (p *Packer) MoveToIndex(from, to int) []Packable {
+	if from < 0 || from >= len(p.all) || to < 0 || to >= len(p.all) || from == to {
+		return p.all
+	}
+
+	sizes := make([]float32, len(p.all))
+	for i := range p.all {
+		sizes[i] = p.ItemSize(i)
+		if sizes[i] < 0 {
+			sizes[i] = 0
+		}
+	}
+
+	moveElement(p.all, from, to)
+	moveElement(sizes, from, to)
+
+	return p.Resize(sizes)
+}
+
+// moveElement moves the element of items at index from to index to, shifting the elements
+// between them.
+func moveElement[T any](items []T, from, to int) {
+	item := items[from]
+	if from < to {
+		copy(items[from:to], items[from+1:to+1])
+	} else {
+		copy(items[to+1:from+1], items[to:from])
+	}
+	items[to] = item
+}
+
 // RepackItemsBelowLimit adjusts the packables so that any that are
 // not visible because their coordinate is below the max space for packing
 // are moved up so they are visible. Other items that would then overlap
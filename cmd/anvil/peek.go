@@ -0,0 +1,302 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"github.com/jeffwilliams/anvil/internal/typeset"
+)
+
+const (
+	// peekContextLines is the number of lines shown before and after the target of a peek.
+	peekContextLines = 10
+	// peekMaxFileSize is the most of a file that is read to find the target of a peek.
+	peekMaxFileSize = 4 * 1024 * 1024
+)
+
+// peekOverlay is the excerpt of a file shown over an editable by Peek. It is not a window: it
+// is drawn on top of the text and dismissed by the next keypress or click.
+type peekOverlay struct {
+	title string
+	lines []string
+	// target is the index in lines of the line the text object refers to.
+	target int
+	// anchor is the rune index in the editable the overlay is drawn next to.
+	anchor int
+}
+
+func (c CommandExecutor) CmdPeek(ctx *CmdContext) {
+	e := ctx.Editable
+	if e == nil {
+		editor.AppendError("", "Peek: must be executed in a window")
+		return
+	}
+
+	anchor := e.firstCursorIndex()
+	obj := strings.Join(ctx.Args, " ")
+	if obj == "" {
+		obj = e.textObjectForAcquireAt(anchor)
+	}
+	if obj == "" {
+		editor.AppendError("", "Peek: there is no file name under the cursor")
+		return
+	}
+
+	e.peekAt(obj, anchor)
+}
+
+// peekAt finds the file named by the text object obj, which may include a seek like the ones
+// acquired with the mouse, and shows the lines around the place it refers to over e next to
+// the rune at anchor. The file is found and read in a goroutine since it may be remote.
+func (e *editable) peekAt(obj string, anchor int) {
+	partialPath, seek, err := parseSeekFromFilename(obj)
+	if err != nil {
+		e.adapter.appendError("", fmt.Sprintf("Peek: %v", err))
+		return
+	}
+
+	j := NewNamedJob("Peek " + filepath.Base(partialPath))
+	e.adapter.addJob(j)
+	go func() {
+		w := peekLoadedWork{job: j, editable: e, anchor: anchor}
+		defer func() { e.adapter.doWork(w) }()
+
+		path, _ := e.adapter.findFile(partialPath)
+		if path == nil {
+			return
+		}
+
+		text, err := readForPeek(path.String())
+		if err != nil {
+			w.err = fmt.Errorf("Peek: %s: %v", path, err)
+			return
+		}
+
+		lines, first, target := peekExcerpt(text, seek, peekContextLines)
+		w.overlay = &peekOverlay{
+			title:  fmt.Sprintf("%s:%d", path, first+target+1),
+			lines:  lines,
+			target: target,
+		}
+	}()
+}
+
+// readForPeek reads the file at path, or the first peekMaxFileSize bytes of it.
+func readForPeek(path string) ([]byte, error) {
+	b, _, err := readFileUpTo(path, peekMaxFileSize)
+	return b, err
+}
+
+// readFileUpTo reads the local or remote file at path, or the first limit bytes of it, in which
+// case truncated is true.
+func readFileUpTo(path string, limit int) (b []byte, truncated bool, err error) {
+	var ldr FileLoader
+	load, err := ldr.LoadAsync(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var buf bytes.Buffer
+	contents, errs, filenames := load.Contents, load.Errs, load.Filenames
+	for contents != nil || errs != nil || filenames != nil {
+		select {
+		case b, ok := <-contents:
+			if !ok {
+				contents = nil
+				continue
+			}
+			buf.Write(b)
+			if buf.Len() > limit {
+				load.Kill <- struct{}{}
+				return buf.Bytes()[:limit], true, nil
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if err != nil && err != io.EOF {
+				return nil, false, err
+			}
+		case _, ok := <-filenames:
+			if !ok {
+				filenames = nil
+				continue
+			}
+			load.Kill <- struct{}{}
+			return nil, false, fmt.Errorf("is a directory")
+		}
+	}
+	return buf.Bytes(), false, nil
+}
+
+// peekExcerpt returns the lines of text from context lines before the line that seek refers
+// to until context lines after it. first is the index in text of the first line returned, and
+// target is the index in lines of the line seek refers to. An empty seek refers to the first
+// line.
+func peekExcerpt(text []byte, s seek, context int) (lines []string, first, target int) {
+	all := strings.Split(string(text), "\n")
+	if len(all) > 1 && all[len(all)-1] == "" {
+		all = all[:len(all)-1]
+	}
+
+	line := 0
+	switch s.seekType {
+	case seekToLineAndCol:
+		line = s.line - 1
+	case seekToRunePos, seekToBytePos, seekToPercent:
+		line = lineOfRune(text, s.runePosIn(text))
+	case seekToRegex:
+		if s.regex != nil {
+			if loc := s.regex.FindIndex(text); loc != nil {
+				line = bytes.Count(text[:loc[0]], []byte("\n"))
+			}
+		}
+	}
+
+	if line >= len(all) {
+		line = len(all) - 1
+	}
+	if line < 0 {
+		line = 0
+	}
+
+	first = line - context
+	if first < 0 {
+		first = 0
+	}
+	last := line + context + 1
+	if last > len(all) {
+		last = len(all)
+	}
+	return all[first:last], first, line - first
+}
+
+// lineOfRune returns the index of the line that contains the rune at runePos in text.
+func lineOfRune(text []byte, runePos int) (line int) {
+	for i := 0; i < runePos && len(text) > 0; i++ {
+		r, sz := utf8.DecodeRune(text)
+		if r == '\n' {
+			line++
+		}
+		text = text[sz:]
+	}
+	return
+}
+
+type peekLoadedWork struct {
+	job      Job
+	editable *editable
+	anchor   int
+	overlay  *peekOverlay
+	err      error
+}
+
+func (w peekLoadedWork) Service() (done bool) {
+	if w.err != nil {
+		w.editable.adapter.appendError("", w.err.Error())
+		return true
+	}
+	if w.overlay == nil {
+		return true
+	}
+
+	w.overlay.anchor = w.anchor
+	w.editable.peek = w.overlay
+	editor.SignalRedrawRequired()
+	return true
+}
+
+func (w peekLoadedWork) Job() Job {
+	return w.job
+}
+
+// dismissPeek removes the peek overlay, if one is shown, and returns true if there was one.
+func (e *editable) dismissPeek() bool {
+	if e.peek == nil {
+		return false
+	}
+	e.peek = nil
+	return true
+}
+
+// drawPeek draws the peek overlay, if one is shown, below the line of its anchor, or above it
+// if there isn't room below. The excerpt is layed out separately from the text of the
+// editable and isn't wrapped; long lines are clipped at the edge of the overlay.
+func (e *editable) drawPeek(gtx layout.Context) {
+	p := e.peek
+	if p == nil || e.layedoutText == nil {
+		return
+	}
+
+	doc := p.title + "\n" + strings.Join(p.lines, "\n")
+	constraints := e.textLayoutConstraints(gtx)
+	constraints.WrapWidth = 0
+	constraints.MaxHeight = 0
+	ltext, errs := typeset.Layout([]byte(doc), constraints)
+	for _, err := range errs {
+		log(LogCatgEd, "typeset.Layout error: %v\n", err)
+	}
+
+	pad := gtx.Metric.Dp(unit.Dp(4))
+	border := gtx.Metric.Dp(unit.Dp(1))
+	lh := e.lineHeight()
+	width := e.textWidth(gtx)
+	height := len(ltext.Lines())*lh + 2*pad
+	if width <= 2*pad || height > gtx.Constraints.Max.Y {
+		height = gtx.Constraints.Max.Y
+	}
+
+	y := 0
+	pos := e.findCursorsInSlice(gtx, e.layedoutText, []int{p.anchor}, -1, -1)
+	if len(pos) > 0 {
+		y = pos[0].Y + lh
+		if y+height > gtx.Constraints.Max.Y {
+			y = pos[0].Y - height
+		}
+		if y < 0 {
+			y = 0
+		}
+	}
+
+	defer op.Offset(image.Pt(0, y)).Push(gtx.Ops).Pop()
+
+	stack := drawFilledBox(gtx, float32(width), float32(height))
+	paint.ColorOp{Color: color.NRGBA(e.style.BgColor)}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	stack.Pop()
+
+	stack = drawBox(gtx, float32(width), float32(height), float32(border))
+	paint.ColorOp{Color: color.NRGBA(e.style.FgColor)}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	stack.Pop()
+
+	defer clip.Rect{Min: image.Pt(pad, pad), Max: image.Pt(width-pad, height-pad)}.Push(gtx.Ops).Pop()
+	defer op.Offset(image.Pt(pad, pad)).Push(gtx.Ops).Pop()
+
+	tr := NewTextRenderer(e.layouter.curFont(), e.layouter.curFontSize(), e.lineSpacingScaled, e.style.FgColor, e.lineHeight)
+	tr.SetTabStopInterval(e.tabStopInterval(&gtx.Metric))
+	for i, line := range ltext.Lines() {
+		if i == p.target+1 {
+			tr.SetFgColor(e.style.PrimarySelection.FgColor)
+			tr.SetBgColor(e.style.PrimarySelection.BgColor)
+			tr.DrawTextBgRect(gtx, width-2*pad)
+			tr.SetDrawBg(false)
+		} else {
+			tr.SetFgColor(e.style.FgColor)
+		}
+		tr.DrawTextline(gtx, &line)
+		op.Offset(image.Pt(0, lh)).Add(gtx.Ops)
+	}
+}
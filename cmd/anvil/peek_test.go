@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestPeekExcerpt(t *testing.T) {
+	text := []byte("a\nb\nc\nd\né\nf\ng\n")
+
+	tests := []struct {
+		name          string
+		seek          seek
+		context       int
+		lines         []string
+		first, target int
+	}{
+		{"line", seek{seekType: seekToLineAndCol, line: 4}, 1, []string{"c", "d", "é"}, 2, 1},
+		{"no seek", seek{}, 2, []string{"a", "b", "c"}, 0, 0},
+		{"line past end", seek{seekType: seekToLineAndCol, line: 50}, 1, []string{"f", "g"}, 5, 1},
+		{"rune pos", seek{seekType: seekToRunePos, runePos: 10}, 0, []string{"f"}, 5, 0},
+		{"regex", seek{seekType: seekToRegex, regex: regexp.MustCompile(`é`)}, 1, []string{"d", "é", "f"}, 3, 1},
+		{"regex not found", seek{seekType: seekToRegex, regex: regexp.MustCompile(`z`)}, 0, []string{"a"}, 0, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			lines, first, target := peekExcerpt(text, tc.seek, tc.context)
+			if !reflect.DeepEqual(lines, tc.lines) || first != tc.first || target != tc.target {
+				t.Fatalf("expected %q, %d, %d but got %q, %d, %d", tc.lines, tc.first, tc.target, lines, first, target)
+			}
+		})
+	}
+}
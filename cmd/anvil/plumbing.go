@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"regexp"
 )
@@ -16,7 +17,8 @@ Plumbing file format:
 
 
 <regex> can contain submatches which can be referenced by $0 (the entire match), $1 (first group), $2, etc.
-<command> is an anvil or shell command.
+<command> is an anvil or shell command. It is executed in the context of the window the text was
+acquired in, so for example a rule can run a command on another host using On.
 
 */
 
@@ -40,32 +42,53 @@ func (p Plumber) Plumb(obj string, executor *CommandExecutor, ctx *CmdContext) (
 	return false, nil
 }
 
+// Find returns the first rule that matches obj, its index in the rules, and the command it
+// would execute, without executing it.
+func (p Plumber) Find(obj string) (rule PlumbingRule, index int, cmd string, ok bool) {
+	for i, r := range p.rules {
+		if cmd, ok = r.Expand(obj); ok {
+			return r, i, cmd, true
+		}
+	}
+	return
+}
+
 type PlumbingRule struct {
 	Match *regexp.Regexp
 	Do    string
+	// Line is the line of the match line of the rule in the file it was loaded from.
+	Line int
 }
 
-func (rule PlumbingRule) Try(obj string, executor *CommandExecutor, ctx *CmdContext) (matched bool) {
+// Expand returns the command of the rule with the submatches of obj substituted, if the rule
+// matches obj.
+func (rule PlumbingRule) Expand(obj string) (cmd string, matched bool) {
 	submatches := rule.Match.FindStringSubmatchIndex(obj)
 	if submatches == nil {
 		return
 	}
 
-	matched = true
-	cmd := []byte{}
-	cmd = rule.Match.Expand(cmd, []byte(rule.Do), []byte(obj), submatches)
+	return string(rule.Match.Expand(nil, []byte(rule.Do), []byte(obj), submatches)), true
+}
+
+func (rule PlumbingRule) Try(obj string, executor *CommandExecutor, ctx *CmdContext) (matched bool) {
+	cmd, matched := rule.Expand(obj)
+	if !matched {
+		return
+	}
 
 	log(LogCatgPlumb, "Plumber: executing '%s'\n", cmd)
 
-	executor.Do(string(cmd), ctx)
+	executor.Do(cmd, ctx)
 	return
 }
 
 func ParsePlumbingRules(f io.Reader) (rules []PlumbingRule, err error) {
 	var rule PlumbingRule
 
-	onMatch := func(re *regexp.Regexp) {
+	onMatch := func(re *regexp.Regexp, line int) {
 		rule.Match = re
+		rule.Line = line
 	}
 
 	onDo := func(do string) {
@@ -76,3 +99,50 @@ func ParsePlumbingRules(f io.Reader) (rules []PlumbingRule, err error) {
 	err = parseDoMatchConfigFile(f, onMatch, onDo)
 	return
 }
+
+func (c CommandExecutor) CmdPlumb(ctx *CmdContext) {
+	obj := ctx.CombinedArgs()
+	if obj == "" {
+		editor.AppendError("", "Plumb: the text to plumb must be given as the argument")
+		return
+	}
+
+	HirePlumber()
+	if plumber == nil {
+		editor.AppendError("", "Plumb: there are no plumbing rules")
+		return
+	}
+
+	// The arguments were the text to plumb; they are not arguments to the command of the rule.
+	pctx := *ctx
+	pctx.Args = nil
+	if ok, _ := plumber.Plumb(obj, &c, &pctx); !ok {
+		editor.AppendError("", fmt.Sprintf("Plumb: no rule matches '%s'", obj))
+	}
+}
+
+func (c CommandExecutor) CmdPlumbDry(ctx *CmdContext) {
+	obj := ctx.CombinedArgs()
+	if obj == "" {
+		editor.AppendError("", "PlumbDry: the text to plumb must be given as the argument")
+		return
+	}
+
+	HirePlumber()
+	if plumber == nil {
+		editor.AppendError("", "PlumbDry: there are no plumbing rules")
+		return
+	}
+
+	rule, i, cmd, ok := plumber.Find(obj)
+	if !ok {
+		editor.AppendError("", fmt.Sprintf("PlumbDry: no rule matches '%s'", obj))
+		return
+	}
+
+	where := ""
+	if rule.Line > 0 {
+		where = fmt.Sprintf(" on line %d", rule.Line)
+	}
+	editor.AppendError("", fmt.Sprintf("PlumbDry: rule %d%s (match %s) matches '%s' and would execute: %s", i+1, where, rule.Match, obj, cmd))
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPlumbRunsEditorCommandsWithSubmatches(t *testing.T) {
+	oldConfDir, oldPlumber, oldInit := ConfDir, plumber, plumberInit
+	t.Cleanup(func() { ConfDir, plumber, plumberInit = oldConfDir, oldPlumber, oldInit })
+
+	ConfDir = t.TempDir()
+	rules := "match ^note:([a-z]+)$\ndo Mark $1\n\nmatch ^github\\.com/([^/]+/[^#]+)#([0-9]+)$\ndo On buildhost:/tmp gh issue view -R $1 $2\n"
+	if err := os.WriteFile(PlumbingConfigFile(), []byte(rules), 0644); err != nil {
+		t.Fatalf("writing plumbing file failed: %v", err)
+	}
+	plumber = nil
+	plumberInit = resetLazyInit(plumberInit)
+
+	startHeadlessEditor(t)
+
+	var win *Window
+	var errs string
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag("/tmp/plumbed.txt", typeFile)
+		ctx := &CmdContext{Editable: &win.Body.editable}
+
+		NewCommandExecutor(win).Do("PlumbDry github.com/foo/bar#123", ctx)
+		NewCommandExecutor(win).Do("Plumb note:here", ctx)
+		NewCommandExecutor(win).Do("Plumb nothing", ctx)
+
+		if w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf("")); w != nil {
+			errs = w.Body.String()
+		}
+	})
+
+	expected := "PlumbDry: rule 2 on line 4 (match ^github\\.com/([^/]+/[^#]+)#([0-9]+)$) matches 'github.com/foo/bar#123' and would execute: On buildhost:/tmp gh issue view -R foo/bar 123\n"
+	if !strings.Contains(errs, expected) {
+		t.Fatalf("expected PlumbDry to report the rule and command but the errors are %q", errs)
+	}
+	if !strings.Contains(errs, "Plumb: no rule matches 'nothing'") {
+		t.Fatalf("expected Plumb to report that no rule matched but the errors are %q", errs)
+	}
+
+	var file string
+	onMainGoroutine(func() { file, _, _ = editor.Marks.Seek("here") })
+	if file != "/tmp/plumbed.txt" {
+		t.Fatalf("expected the rule to set the mark 'here' in the window but it is in %q", file)
+	}
+}
+
+func TestPlumbingRuleErrorsNameTheRule(t *testing.T) {
+	_, err := ParsePlumbingRules(strings.NewReader("match ^a\ndo echo a\nmatch (\ndo echo b\n"))
+	if err == nil || !strings.Contains(err.Error(), "3: rule 2: Parsing regexp") {
+		t.Fatalf("expected the error to give the line and the rule but got %v", err)
+	}
+}
@@ -4,16 +4,25 @@ import (
 	"time"
 
 	"gioui.org/f32"
+	"gioui.org/io/key"
 	"gioui.org/io/pointer"
 	"gioui.org/layout"
 )
 
 type PointerState struct {
-	pressedButtons      pointer.Buttons
+	// pressedButtons are the buttons held before the current event. If there are mouse bindings
+	// these are the buttons that the held physical buttons act as.
+	pressedButtons pointer.Buttons
+	// physicalButtons are the physical buttons held before the current event.
+	physicalButtons     pointer.Buttons
 	currentPointerEvent pointerEvent
 	lastPointerEvent    pointerEvent
 	lastPressEvent      pointerEvent
 	handlers            map[PointerEventMatch]PointerEventHandler
+	chordHandlers       map[chordMatch]PointerEventHandler
+	bindings            *MouseBindings
+	// bound holds what each held physical button was resolved to by the bindings when it was pressed.
+	bound map[pointer.Buttons]boundPress
 	// consecutiveClicks is the number of consecutive clicks on the same button within a small duration
 	consecutiveClicks int
 	gtx               layout.Context
@@ -24,6 +33,8 @@ type pointerEvent struct {
 	set       bool
 	pointer.Event
 	button pointer.Buttons
+	// held is the button that must still be held for the event to be part of a chord.
+	held pointer.Buttons
 }
 
 type PointerEventMatch struct {
@@ -31,13 +42,72 @@ type PointerEventMatch struct {
 	button pointer.Buttons
 }
 
+// chordMatch matches an event for a button while another button, held, is held.
+type chordMatch struct {
+	PointerEventMatch
+	held pointer.Buttons
+}
+
 func (ps *PointerState) Event(ev *pointer.Event, gtx layout.Context) {
 	ps.currentPointerEvent.Event = *ev
 	ps.currentPointerEvent.set = true
 	ps.currentPointerEvent.button = ps.pointerButtonJustManipulated()
+	ps.currentPointerEvent.held = 0
+	if ps.bindings != nil {
+		ps.applyBindings()
+	}
 	ps.gtx = gtx
 }
 
+// SetBindings makes the state map the physical buttons to the buttons the handlers are
+// registered for using the bindings.
+func (ps *PointerState) SetBindings(b *MouseBindings) {
+	ps.bindings = b
+}
+
+// applyBindings replaces the physical button of the current event with the button it acts as.
+// A press is resolved using the bindings, and the physical button then acts the same way until
+// it is released.
+func (ps *PointerState) applyBindings() {
+	if ps.bound == nil {
+		ps.bound = map[pointer.Buttons]boundPress{}
+	}
+
+	ev := &ps.currentPointerEvent
+	switch ev.Kind {
+	case pointer.Press:
+		b := ps.bindings.resolvePress(ev.button, ev.Modifiers, ps.pressedButtons)
+		ps.bound[ev.button] = b
+		ev.button, ev.held = b.button, b.held
+		ev.Modifiers &^= b.mods
+	case pointer.Release:
+		if b, ok := ps.bound[ev.button]; ok {
+			ev.button, ev.held = b.button, b.held
+			ev.Modifiers &^= b.mods
+		}
+	case pointer.Drag:
+		var mods key.Modifiers
+		ev.button = ps.boundButtons(ev.button)
+		for _, b := range ps.bound {
+			mods |= b.mods
+		}
+		ev.Modifiers &^= mods
+	}
+}
+
+// boundButtons returns the buttons that the physical buttons act as.
+func (ps *PointerState) boundButtons(physical pointer.Buttons) (buttons pointer.Buttons) {
+	if ps.bindings == nil {
+		return physical
+	}
+	for p, b := range ps.bound {
+		if physical.Contain(p) {
+			buttons |= b.button
+		}
+	}
+	return
+}
+
 func (ps *PointerState) SetRuneIndexOfCurrentEvent(runeIndex int) {
 	ps.currentPointerEvent.runeIndex = runeIndex
 }
@@ -53,6 +123,26 @@ func (ps *PointerState) Handler(m PointerEventMatch, handler PointerEventHandler
 	ps.handlers[m] = handler
 }
 
+// ChordHandler registers a handler for events matching m while the button held is held. It is
+// used instead of the handler for m when the mouse bindings resolved the press as a chord.
+func (ps *PointerState) ChordHandler(held pointer.Buttons, m PointerEventMatch, handler PointerEventHandler) {
+	if ps.chordHandlers == nil {
+		ps.chordHandlers = make(map[chordMatch]PointerEventHandler)
+	}
+	ps.chordHandlers[chordMatch{m, held}] = handler
+}
+
+func (ps *PointerState) handlerFor(m PointerEventMatch) (fn PointerEventHandler, ok bool) {
+	held := ps.currentPointerEvent.held
+	if held != 0 && ps.pressedButtons.Contain(held) {
+		if fn, ok = ps.chordHandlers[chordMatch{m, held}]; ok {
+			return
+		}
+	}
+	fn, ok = ps.handlers[m]
+	return
+}
+
 func (ps *PointerState) InvokeHandlers() {
 	if ps.handlers == nil || !ps.currentPointerEvent.set {
 		return
@@ -71,12 +161,18 @@ func (ps *PointerState) InvokeHandlers() {
 
 	ps.updateConsecutiveClicks()
 
-	if fn, ok := ps.handlers[m]; ok {
+	if fn, ok := ps.handlerFor(m); ok {
 		fn(ps)
 	}
 
 	ps.updateHistoricalEvents()
-	ps.pressedButtons = ps.currentPointerEvent.Buttons
+	ps.physicalButtons = ps.currentPointerEvent.Buttons
+	for p := range ps.bound {
+		if !ps.physicalButtons.Contain(p) {
+			delete(ps.bound, p)
+		}
+	}
+	ps.pressedButtons = ps.boundButtons(ps.physicalButtons)
 }
 
 func (ps *PointerState) isZeroDistanceDrag() bool {
@@ -136,9 +232,9 @@ type PointerEventHandler func(ps *PointerState)
 func (ps PointerState) pointerButtonJustManipulated() pointer.Buttons {
 	ev := &ps.currentPointerEvent.Event
 	if ev.Kind == pointer.Press {
-		return ev.Buttons & (^ps.pressedButtons)
+		return ev.Buttons & (^ps.physicalButtons)
 	} else if ev.Kind == pointer.Release {
-		return ps.pressedButtons ^ ev.Buttons
+		return ps.physicalButtons ^ ev.Buttons
 	} else if ev.Kind == pointer.Drag {
 		return ev.Buttons
 	}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"sort"
+	"strings"
+
+	"gioui.org/io/event"
+	"gioui.org/io/key"
+	"gioui.org/io/pointer"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+)
+
+// BodyPresenter is an alternate way of displaying the text of a window body. When a window body
+// has a presenter the presenter draws the body and receives its pointer and key events instead
+// of the editable. The text of the body is still loaded, saved and changed the usual way and
+// the presenter is passed the current contents each time it is laid out.
+type BodyPresenter interface {
+	// Name is the name used to select the presenter with the Present command, and to record it in a Dump.
+	Name() string
+	// Editable returns true if the body text may be changed while the presenter is active.
+	// Changes to the body are refused if this is false.
+	Editable() bool
+	Layout(gtx layout.Context, content []byte) layout.Dimensions
+	HandlePointer(gtx layout.Context, ev *pointer.Event)
+	HandleKey(gtx layout.Context, ev *key.Event)
+	// ContentChanged is called when the body text changes.
+	ContentChanged(change *TextChange)
+}
+
+// defaultPresenterName is the name of the normal, editable, presentation of a body.
+const defaultPresenterName = "text"
+
+// bodyPresenters maps the names of the presenters to functions that create them.
+var bodyPresenters = map[string]func(b *Body) BodyPresenter{
+	"hex": newHexPresenter,
+}
+
+func bodyPresenterNames() []string {
+	names := []string{defaultPresenterName}
+	for n := range bodyPresenters {
+		names = append(names, n)
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// SetPresenter changes how the body is displayed to the presenter with the given name.
+func (b *Body) SetPresenter(name string) error {
+	b.presenterText = nil
+	if name == "" || name == defaultPresenterName {
+		b.presenter = nil
+		return nil
+	}
+
+	fn, ok := bodyPresenters[name]
+	if !ok {
+		return fmt.Errorf("no presenter named '%s'. The presenters are: %s", name, strings.Join(bodyPresenterNames(), ", "))
+	}
+	b.presenter = fn(b)
+	return nil
+}
+
+// PresenterName returns the name of the presenter used to display the body.
+func (b *Body) PresenterName() string {
+	if b.presenter == nil {
+		return defaultPresenterName
+	}
+	return b.presenter.Name()
+}
+
+// IsEditable returns false if the body is displayed by a presenter that doesn't allow editing.
+func (b *Body) IsEditable() bool {
+	return b.presenter == nil || b.presenter.Editable()
+}
+
+func (b *Body) presenterContentChanged(change *TextChange) {
+	if b.presenter != nil {
+		b.presenter.ContentChanged(change)
+	}
+}
+
+// textForPresenter returns the text of the body, copying it only when it changed since the last
+// time.
+func (b *Body) textForPresenter() []byte {
+	if b.presenterText == nil || b.presenterTextGeneration != b.generation {
+		b.presenterText = b.Bytes()
+		b.presenterTextGeneration = b.generation
+	}
+	return b.presenterText
+}
+
+func (b *Body) layoutWithPresenter(gtx layout.Context) layout.Dimensions {
+	t := &b.blockEditable
+	t.prepareForLayout()
+
+	for {
+		pf := pointer.Filter{
+			Target:  t,
+			Kinds:   pointer.Press | pointer.Drag | pointer.Release | pointer.Scroll,
+			ScrollX: pointer.ScrollRange{Min: -100, Max: 100},
+			ScrollY: pointer.ScrollRange{Min: -100, Max: 100},
+		}
+		kf := key.Filter{Focus: t, Optional: key.ModCtrl | key.ModShift | key.ModAlt | key.ModCommand}
+
+		ev, ok := gtx.Event(pf, kf)
+		if !ok {
+			break
+		}
+
+		switch e := ev.(type) {
+		case pointer.Event:
+			if e.Kind == pointer.Press {
+				t.adapter.noteUserInteraction()
+				t.SetFocus(gtx)
+			}
+			b.presenter.HandlePointer(gtx, &e)
+		case key.Event:
+			t.adapter.noteUserInteraction()
+			b.presenter.HandleKey(gtx, &e)
+		}
+	}
+
+	r := image.Rectangle{Max: gtx.Constraints.Max}
+	stack := clip.Rect(r).Push(gtx.Ops)
+	t.drawBackground(gtx)
+	t.textRender.SetRedact(t.redacted)
+	b.presenter.Layout(gtx, b.textForPresenter())
+	event.Op(gtx.Ops, t)
+	stack.Pop()
+
+	t.dims = layout.Dimensions{Size: gtx.Constraints.Max}
+	return t.dims
+}
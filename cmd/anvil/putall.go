@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jeffwilliams/anvil/internal/textenc"
+)
+
+// putallStatus is what Putall did, or would do, with a window.
+type putallStatus int
+
+const (
+	putallSaved putallStatus = iota
+	// putallWouldSave is the status of a window that a dry run would save.
+	putallWouldSave
+	// putallSkippedNew is the status of a window whose file doesn't exist yet and that was not
+	// saved because the create option wasn't set.
+	putallSkippedNew
+	putallFailed
+)
+
+// putallOptions control what Putall does.
+type putallOptions struct {
+	// dryRun reports the windows that would be saved without saving them.
+	dryRun bool
+	// create saves windows whose files don't exist yet.
+	create bool
+}
+
+// putallResult is what Putall did with one window. Bytes is the number of bytes that were, or
+// would be, written. Err is set if the status is putallFailed.
+type putallResult struct {
+	Win    *Window
+	Path   string
+	Bytes  int
+	Status putallStatus
+	Err    error
+}
+
+// Putall saves the windows holding files that have unsaved changes, and returns what it did with
+// each of them. A window that fails to save doesn't stop the others from being saved.
+func (e *Editor) Putall(opts putallOptions) (results []putallResult) {
+	for _, c := range e.Cols {
+		for _, w := range c.Windows {
+			if w.fileType != typeFile || w.IsErrorsWindow() || w.IsFindWindow() || w.IsDiffWindow() || !w.IsDirty() {
+				continue
+			}
+			results = append(results, w.putForPutall(opts))
+		}
+	}
+	return
+}
+
+func (w *Window) putForPutall(opts putallOptions) putallResult {
+	r := putallResult{
+		Win:   w,
+		Path:  w.file,
+		Bytes: len(textenc.Encode(w.Body.Bytes(), w.encoding)),
+	}
+
+	if !opts.create {
+		exists, err := putallFileExists(w.file)
+		if err != nil {
+			r.Status, r.Err = putallFailed, err
+			return r
+		}
+		if !exists {
+			r.Status = putallSkippedNew
+			return r
+		}
+	}
+
+	if opts.dryRun {
+		r.Status = putallWouldSave
+		return r
+	}
+
+	if err := w.Put(); err != nil {
+		r.Status, r.Err = putallFailed, err
+		return r
+	}
+	r.Status = putallSaved
+	return r
+}
+
+func putallFileExists(path string) (bool, error) {
+	sfs, err := GetFs(path)
+	if err != nil {
+		return false, err
+	}
+	return sfs.fileExists(path)
+}
+
+// putallReport describes the results of Putall for the +Errors window: a line for each window
+// that wasn't simply saved, followed by a summary line.
+func putallReport(results []putallResult, opts putallOptions) string {
+	var b strings.Builder
+	var saved, skipped, failed int
+	for _, r := range results {
+		switch r.Status {
+		case putallSaved:
+			saved++
+		case putallWouldSave:
+			saved++
+			fmt.Fprintf(&b, "would save %s (%d bytes)\n", r.Path, r.Bytes)
+		case putallSkippedNew:
+			skipped++
+			if opts.dryRun {
+				fmt.Fprintf(&b, "would skip %s (%d bytes) since it doesn't exist\n", r.Path, r.Bytes)
+			} else {
+				fmt.Fprintf(&b, "skipped %s since it doesn't exist\n", r.Path)
+			}
+		case putallFailed:
+			failed++
+			fmt.Fprintf(&b, "failed to save %s: %v\n", r.Path, r.Err)
+		}
+	}
+
+	if opts.dryRun {
+		fmt.Fprintf(&b, "Putall -n: would save %d, would skip %d, would fail %d", saved, skipped, failed)
+	} else {
+		fmt.Fprintf(&b, "Putall: saved %d, skipped %d, failed %d", saved, skipped, failed)
+	}
+	if skipped > 0 {
+		b.WriteString(". Execute Putall -create to save the files that don't exist yet")
+	}
+	return b.String()
+}
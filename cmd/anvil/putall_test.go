@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPutallSkipsNewFilesAndDryRun(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.txt")
+	if err := os.WriteFile(existing, []byte("old\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+	missing := filepath.Join(dir, "missing.txt")
+
+	var win, newWin *Window
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.LoadFile(existing)
+	})
+	waitForBody(t, win, "old\n")
+
+	statuses := func(results []putallResult) map[string]putallStatus {
+		m := map[string]putallStatus{}
+		for _, r := range results {
+			m[r.Path] = r.Status
+		}
+		return m
+	}
+
+	var dryRun, put []putallResult
+	var report string
+	onMainGoroutine(func() {
+		win.Body.insertToPieceTable(0, "new ")
+		newWin = editor.Cols[0].NewWindow()
+		newWin.SetFilenameAndTag(missing, typeFile)
+		newWin.Body.insertToPieceTable(0, "created\n")
+
+		dryRun = editor.Putall(putallOptions{dryRun: true})
+		report = putallReport(dryRun, putallOptions{dryRun: true})
+		put = editor.Putall(putallOptions{})
+	})
+
+	if s := statuses(dryRun); len(s) != 2 || s[existing] != putallWouldSave || s[missing] != putallSkippedNew {
+		t.Fatalf("unexpected dry run results %v", s)
+	}
+	if !strings.Contains(report, "would save "+existing+" (8 bytes)") || !strings.Contains(report, "would save 1, would skip 1, would fail 0") {
+		t.Fatalf("unexpected dry run report %q", report)
+	}
+	if s := statuses(put); len(s) != 2 || s[existing] != putallSaved || s[missing] != putallSkippedNew {
+		t.Fatalf("unexpected Putall results %v", s)
+	}
+
+	waitForFileContents(t, existing, "new old\n")
+	if _, err := os.Stat(missing); err == nil {
+		t.Fatalf("expected %s not to be created", missing)
+	}
+
+	onMainGoroutine(func() {
+		NewCommandExecutor(newWin).Do("Putall -create", &CmdContext{})
+	})
+	waitForFileContents(t, missing, "created\n")
+}
+
+func waitForFileContents(t *testing.T, path, expected string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := os.ReadFile(path)
+		if string(b) == expected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %s to hold %q but it holds %q", path, expected, b)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
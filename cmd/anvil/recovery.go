@@ -0,0 +1,437 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recoveryIndexFile is the name of the file in the recovery directory that lists the snapshots.
+const recoveryIndexFile = "index.json"
+
+// recoverySnapshot describes the saved body of a window with unsaved changes.
+type recoverySnapshot struct {
+	// Hash names the file in the recovery directory holding the body. It is derived from Path.
+	Hash  string
+	Path  string
+	Saved time.Time
+}
+
+func recoveryHash(path string) string {
+	h := sha256.Sum256([]byte(path))
+	return hex.EncodeToString(h[:16])
+}
+
+func recoveryWindowFile() string {
+	return fmt.Sprintf("%s/+Recovery", ConfDir)
+}
+
+// autosaver periodically writes the bodies of windows with unsaved changes to the recovery
+// directory, so that the changes can be recovered if Anvil stops unexpectedly. The snapshots are
+// written by a background job; the autosaver itself is only used from the main goroutine.
+type autosaver struct {
+	dir string
+	// snapshots are the snapshots written during this session, by path.
+	snapshots map[string]recoverySnapshot
+	// previous are the snapshots left by earlier sessions that haven't been replaced.
+	previous map[string]recoverySnapshot
+	// generations are the generations of the window bodies when they were last written, by path.
+	generations map[string]int
+	// removals are the paths whose snapshots are to be removed by the next job.
+	removals  map[string]bool
+	job       *autosaveJob
+	lastErr   string
+	scheduler *Scheduler
+}
+
+var autosave = newAutosaver("")
+
+func newAutosaver(dir string) *autosaver {
+	return &autosaver{
+		dir:         dir,
+		snapshots:   map[string]recoverySnapshot{},
+		previous:    map[string]recoverySnapshot{},
+		generations: map[string]int{},
+		removals:    map[string]bool{},
+	}
+}
+
+// startAutosave loads the snapshots left by earlier sessions in the background, shows them in a
+// +Recovery window if there are any, and starts saving snapshots every autosave-interval seconds.
+func startAutosave() {
+	autosave.dir = RecoveryDir()
+	go func() {
+		previous, err := loadRecoveryIndex(autosave.dir)
+		editor.WorkChan() <- basicWork{func() {
+			if err != nil {
+				editor.AppendError("", fmt.Sprintf("Reading the recovery index failed: %v", err))
+			}
+			for _, s := range previous {
+				autosave.previous[s.Path] = s
+			}
+			if len(previous) > 0 {
+				autosave.showRecoveryWindow()
+			}
+			autosave.schedule()
+		}}
+	}()
+}
+
+func (a *autosaver) schedule() {
+	if settings.General.AutosaveInterval <= 0 {
+		return
+	}
+	if a.scheduler == nil {
+		a.scheduler = NewScheduler(editor.WorkChan())
+	}
+	a.scheduler.AfterFunc("autosave", time.Duration(settings.General.AutosaveInterval)*time.Second, func() {
+		a.save()
+		a.schedule()
+	})
+}
+
+// autosaveable returns true if the body of the window w should be saved while it has unsaved changes.
+// Sensitive windows are not saved, and nor are windows like +Errors that don't hold a file.
+func autosaveable(w *Window) bool {
+	return w.IsDirty() && w.fileType == typeFile && w.file != "" && !w.IsSensitive() &&
+		!strings.HasPrefix(filepath.Base(w.file), "+")
+}
+
+// save starts a job that writes snapshots of the windows whose bodies changed since they were last
+// saved, and removes the snapshots of files that no longer have unsaved changes. If a job is
+// already running nothing is done; the changes are saved the next time.
+func (a *autosaver) save() {
+	if a.job != nil || a.dir == "" {
+		return
+	}
+
+	var writes []autosaveWrite
+	dirty := map[string]bool{}
+	for _, w := range editor.Windows() {
+		if !autosaveable(w) || dirty[w.file] {
+			continue
+		}
+		dirty[w.file] = true
+		if g, ok := a.generations[w.file]; ok && g == w.Body.generation {
+			continue
+		}
+		a.generations[w.file] = w.Body.generation
+		writes = append(writes, autosaveWrite{path: w.file, body: w.Body.Bytes()})
+	}
+
+	for path := range a.snapshots {
+		if !dirty[path] {
+			a.removals[path] = true
+		}
+	}
+
+	a.startJob(writes)
+}
+
+// forget removes the snapshot of the file path unless a window other than closing still has
+// unsaved changes to it. It is called when a window is saved or closed.
+func (a *autosaver) forget(path string, closing *Window) {
+	if _, ok := a.snapshots[path]; !ok {
+		return
+	}
+	for _, w := range editor.Windows() {
+		if w != closing && w.file == path && autosaveable(w) {
+			return
+		}
+	}
+	a.removals[path] = true
+	if a.job == nil {
+		a.startJob(nil)
+	}
+}
+
+// clearPrevious removes the snapshots left by earlier sessions.
+func (a *autosaver) clearPrevious() {
+	for path := range a.previous {
+		a.removals[path] = true
+	}
+	a.previous = map[string]recoverySnapshot{}
+	if a.job == nil {
+		a.startJob(nil)
+	}
+}
+
+func (a *autosaver) startJob(writes []autosaveWrite) {
+	if len(writes) == 0 && len(a.removals) == 0 {
+		return
+	}
+
+	now := time.Now()
+	for i := range writes {
+		s := recoverySnapshot{Hash: recoveryHash(writes[i].path), Path: writes[i].path, Saved: now}
+		writes[i].hash = s.Hash
+		a.snapshots[s.Path] = s
+		delete(a.previous, s.Path)
+	}
+
+	var removals []string
+	for path := range a.removals {
+		removals = append(removals, recoveryHash(path))
+		delete(a.snapshots, path)
+		delete(a.previous, path)
+		delete(a.generations, path)
+	}
+	a.removals = map[string]bool{}
+
+	a.job = &autosaveJob{
+		dir:      a.dir,
+		writes:   writes,
+		removals: removals,
+		index:    a.index(),
+		kill:     make(chan struct{}),
+	}
+	go a.job.run()
+}
+
+// index returns the snapshots of this and earlier sessions ordered by path.
+func (a *autosaver) index() []recoverySnapshot {
+	var l []recoverySnapshot
+	for _, s := range a.previous {
+		l = append(l, s)
+	}
+	for _, s := range a.snapshots {
+		l = append(l, s)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Path < l[j].Path
+	})
+	return l
+}
+
+func (a *autosaver) jobDone(j *autosaveJob, err error) {
+	a.job = nil
+	if err != nil {
+		// Write the files again the next time.
+		for _, w := range j.writes {
+			delete(a.generations, w.path)
+		}
+		if err.Error() != a.lastErr {
+			editor.AppendError("", fmt.Sprintf("Autosave: %v", err))
+		}
+		a.lastErr = err.Error()
+		return
+	}
+	a.lastErr = ""
+
+	if len(a.removals) > 0 {
+		a.startJob(nil)
+	}
+}
+
+// removeSession removes the snapshots written during this session. It is called when the editor
+// exits after the user has confirmed that unsaved changes may be discarded. The snapshots left by
+// earlier sessions are kept.
+func (a *autosaver) removeSession() {
+	if a.dir == "" {
+		return
+	}
+	for _, s := range a.snapshots {
+		os.Remove(filepath.Join(a.dir, s.Hash))
+	}
+	a.snapshots = map[string]recoverySnapshot{}
+
+	index := filepath.Join(a.dir, recoveryIndexFile)
+	if len(a.previous) == 0 {
+		os.Remove(index)
+		return
+	}
+	if b, err := json.MarshalIndent(a.index(), "", "  "); err == nil {
+		writeFileAtomically(index, b, 0600)
+	}
+}
+
+func (a *autosaver) recoveryText() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "These files had unsaved changes that were saved to %s. Execute the Recovery open command under a file to open the file with its saved text next to it, and ◊Recovery clear◊ to delete the saved text left by earlier sessions.\n\n", a.dir)
+	for _, s := range a.index() {
+		_, earlier := a.previous[s.Path]
+		when := "this session"
+		if earlier {
+			when = "an earlier session"
+		}
+		fmt.Fprintf(&buf, "%s\n\tsaved %s by %s\n\t◊Recovery open %s◊\n", s.Path, s.Saved.Format("2006-01-02 15:04:05"), when, s.Path)
+	}
+	return buf.String()
+}
+
+func (a *autosaver) showRecoveryWindow() {
+	w := editor.FindOrCreateWindow(recoveryWindowFile())
+	if w == nil {
+		return
+	}
+	w.SetFilenameAndTag(recoveryWindowFile(), typeFile)
+	w.Body.SetTextString(a.recoveryText())
+	w.markTextAsUnchanged()
+}
+
+// open opens the file path and, next to it in the same column, its saved text.
+func (a *autosaver) open(path string) error {
+	s, ok := a.snapshots[path]
+	if !ok {
+		s, ok = a.previous[path]
+	}
+	if !ok {
+		return fmt.Errorf("there is no saved text for %s", path)
+	}
+
+	w := editor.LoadFile(path)
+	opts := LoadFileOpts{GrowBodyBehaviour: growBodyIfTooSmall}
+	if w != nil {
+		opts.InCol = w.col
+	}
+	editor.LoadFileOpts(filepath.Join(a.dir, s.Hash), opts)
+	return nil
+}
+
+type autosaveWrite struct {
+	path string
+	hash string
+	body []byte
+}
+
+// autosaveJob writes snapshots and the recovery index in the background.
+type autosaveJob struct {
+	dir      string
+	writes   []autosaveWrite
+	removals []string
+	index    []recoverySnapshot
+	kill     chan struct{}
+	once     sync.Once
+}
+
+func (j *autosaveJob) Name() string {
+	return "Autosave"
+}
+
+func (j *autosaveJob) Kill() {
+	j.once.Do(func() { close(j.kill) })
+}
+
+func (j *autosaveJob) run() {
+	err := j.write()
+	editor.WorkChan() <- autosaveDone{job: j, err: err}
+}
+
+func (j *autosaveJob) write() error {
+	err := os.MkdirAll(j.dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	for _, w := range j.writes {
+		select {
+		case <-j.kill:
+			return fmt.Errorf("killed")
+		default:
+		}
+		err = writeFileAtomically(filepath.Join(j.dir, w.hash), w.body, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, h := range j.removals {
+		os.Remove(filepath.Join(j.dir, h))
+	}
+
+	if len(j.index) == 0 {
+		os.Remove(filepath.Join(j.dir, recoveryIndexFile))
+		return nil
+	}
+
+	b, err := json.MarshalIndent(j.index, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomically(filepath.Join(j.dir, recoveryIndexFile), b, 0600)
+}
+
+// writeFileAtomically writes the file by writing a temporary file and renaming it, so that a
+// partly written file is never left behind.
+func writeFileAtomically(path string, b []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	err := os.WriteFile(tmp, b, perm)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+type autosaveDone struct {
+	job *autosaveJob
+	err error
+}
+
+func (l autosaveDone) Service() (done bool) {
+	autosave.jobDone(l.job, l.err)
+	return true
+}
+
+func (l autosaveDone) Job() Job {
+	return l.job
+}
+
+// loadRecoveryIndex returns the snapshots listed in the index in dir whose files still exist.
+func loadRecoveryIndex(dir string) ([]recoverySnapshot, error) {
+	b, err := os.ReadFile(filepath.Join(dir, recoveryIndexFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var index []recoverySnapshot
+	err = json.Unmarshal(b, &index)
+	if err != nil {
+		return nil, err
+	}
+
+	var l []recoverySnapshot
+	for _, s := range index {
+		if _, err := os.Stat(filepath.Join(dir, s.Hash)); err == nil {
+			l = append(l, s)
+		}
+	}
+	return l, nil
+}
+
+func (c CommandExecutor) CmdRecovery(ctx *CmdContext) {
+	if len(ctx.Args) == 0 {
+		autosave.showRecoveryWindow()
+		return
+	}
+
+	switch ctx.Args[0] {
+	case "open":
+		if len(ctx.Args) < 2 {
+			editor.AppendError("", "Recovery: open needs the file as an argument")
+			return
+		}
+		err := autosave.open(strings.Join(ctx.Args[1:], " "))
+		if err != nil {
+			editor.AppendError("", fmt.Sprintf("Recovery: %v", err))
+		}
+	case "clear":
+		autosave.clearPrevious()
+		if w, _ := editor.FindWindowForFile(recoveryWindowFile()); w != nil {
+			w.Body.SetTextString(autosave.recoveryText())
+			w.markTextAsUnchanged()
+		}
+	default:
+		editor.AppendError("", "Recovery: the argument must be 'open' or 'clear'")
+	}
+}
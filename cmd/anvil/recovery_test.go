@@ -0,0 +1,113 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAutosaveWritesAndRemovesSnapshots(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	saved := autosave
+	autosave = newAutosaver(filepath.Join(dir, "recovery"))
+	t.Cleanup(func() { autosave = saved })
+
+	path := filepath.Join(dir, "a.txt")
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag(path, typeFile)
+		win.Body.SetText([]byte("unsaved text\n"))
+		autosave.save()
+	})
+
+	waitForJob := func() {
+		for i := 0; i < 50; i++ {
+			var running bool
+			onMainGoroutine(func() {
+				running = autosave.job != nil
+			})
+			if !running {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatalf("the autosave job didn't finish")
+	}
+	waitForJob()
+
+	snapshot := filepath.Join(autosave.dir, recoveryHash(path))
+	b, err := os.ReadFile(snapshot)
+	if err != nil || string(b) != "unsaved text\n" {
+		t.Fatalf("expected the body to be saved but got %q, %v", b, err)
+	}
+
+	index, err := loadRecoveryIndex(autosave.dir)
+	if err != nil || len(index) != 1 || index[0].Path != path {
+		t.Fatalf("expected the index to list %s but got %v, %v", path, index, err)
+	}
+
+	// An unchanged body is not written again.
+	onMainGoroutine(func() {
+		autosave.save()
+		if autosave.job != nil {
+			t.Errorf("expected no job to be started for an unchanged body")
+		}
+	})
+
+	onMainGoroutine(func() {
+		win.markTextAsUnchanged()
+		autosave.forget(path, nil)
+	})
+	waitForJob()
+
+	if _, err := os.Stat(snapshot); !os.IsNotExist(err) {
+		t.Fatalf("expected the snapshot to be removed after saving")
+	}
+	if index, _ := loadRecoveryIndex(autosave.dir); len(index) != 0 {
+		t.Fatalf("expected the index to be empty but got %v", index)
+	}
+}
+
+func TestAutosaveSkipsSensitiveAndSpecialWindows(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	onMainGoroutine(func() {
+		w := editor.NewWindow(nil)
+		w.SetFilenameAndTag(filepath.Join(dir, "secret.txt"), typeFile)
+		w.Body.SetText([]byte("password\n"))
+		w.SetSensitive(true)
+		if autosaveable(w) {
+			t.Errorf("expected a sensitive window not to be saved")
+		}
+
+		w.SetSensitive(false)
+		if !autosaveable(w) {
+			t.Errorf("expected a dirty window to be saved")
+		}
+
+		w.SetFilenameAndTag(filepath.Join(dir, "+Find"), typeFile)
+		if autosaveable(w) {
+			t.Errorf("expected a +Find window not to be saved")
+		}
+	})
+}
+
+func TestRecoveryTextListsPreviousSnapshots(t *testing.T) {
+	a := newAutosaver("/cfg/recovery")
+	saved := time.Date(2026, 1, 2, 3, 4, 5, 0, time.Local)
+	a.previous["/src/b.go"] = recoverySnapshot{Hash: recoveryHash("/src/b.go"), Path: "/src/b.go", Saved: saved}
+	a.snapshots["/src/a.go"] = recoverySnapshot{Hash: recoveryHash("/src/a.go"), Path: "/src/a.go", Saved: saved}
+
+	text := a.recoveryText()
+	i := strings.Index(text, "/src/a.go\n\tsaved 2026-01-02 03:04:05 by this session\n\t◊Recovery open /src/a.go◊\n")
+	j := strings.Index(text, "/src/b.go\n\tsaved 2026-01-02 03:04:05 by an earlier session\n\t◊Recovery open /src/b.go◊\n")
+	if i < 0 || j < 0 || j < i {
+		t.Fatalf("unexpected recovery text %q", text)
+	}
+}
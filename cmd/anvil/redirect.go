@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// outputRedirectPrefix starts the last argument of a command whose output goes to the named
+// window instead of +Errors, as in make >win:+build.
+const outputRedirectPrefix = ">win:"
+
+// outputWindows holds the names of the windows that output was redirected to. Like +Errors they
+// can be deleted without saving. It is only used by the editor goroutine.
+var outputWindows = map[string]bool{}
+
+// parseOutputRedirect removes the redirection from the end of args and returns the name of the
+// window it names, or "" if the output is not redirected.
+func parseOutputRedirect(args []string) (newargs []string, win string, err error) {
+	newargs = args
+	for i, a := range args {
+		if !strings.HasPrefix(a, outputRedirectPrefix) {
+			continue
+		}
+		if i != len(args)-1 {
+			err = fmt.Errorf("%s must be the last argument", outputRedirectPrefix)
+			return
+		}
+		win = a[len(outputRedirectPrefix):]
+		if win == "" {
+			err = fmt.Errorf("%s must be followed by the name of a window", outputRedirectPrefix)
+			return
+		}
+		newargs = args[:i]
+	}
+	return
+}
+
+// checkOutputRedirect returns an error if the output of cmd, which includes its prefix, can't be
+// redirected to a window.
+func checkOutputRedirect(cmd string) error {
+	switch cmd[0] {
+	case '|', '<':
+		return fmt.Errorf("the output of %c commands replaces the selection, so it can't be redirected to a window", cmd[0])
+	case '!':
+		return fmt.Errorf("the output of ! expressions can't be redirected to a window")
+	}
+	return nil
+}
+
+// outputWindowFileName returns the name of the window output redirected to win from a command
+// run in dir goes to. Like +Errors, names that start with + are in the directory dir.
+func outputWindowFileName(dir, win string) string {
+	if !strings.HasPrefix(win, "+") {
+		return win
+	}
+	if strings.HasSuffix(dir, "/") || strings.HasSuffix(dir, "\\") {
+		dir = dir[:len(dir)-1]
+	}
+	return dir + win
+}
+
+// outputWindowHolder returns the holder for the window that the output of a command run in dir
+// goes to: the window named by win, or the +Errors window of dir if win is empty.
+func outputWindowHolder(dir, win string) WindowHolder {
+	if win == "" {
+		return NewWindowHolderForName(editor.ErrorsFileNameOf(dir))
+	}
+	name := outputWindowFileName(dir, win)
+	outputWindows[name] = true
+	return NewWindowHolderForName(name)
+}
+
+// outputJobName returns the name of the job running command. If the output is redirected the
+// name ends with the redirection, so that the jobs writing to different windows can be told
+// apart and killed separately.
+func outputJobName(command, win string) string {
+	if win == "" {
+		return command
+	}
+	return command + outputRedirectPrefix + win
+}
+
+// jobNameMatches returns true if the job named jobName is selected by the name given to Kill.
+// A job whose output is redirected is selected by its full name or by its command alone.
+func jobNameMatches(jobName, name string) bool {
+	if jobName == name {
+		return true
+	}
+	cmd, _, redirected := strings.Cut(jobName, outputRedirectPrefix)
+	return redirected && cmd == name
+}
+
+// IsOutputWindow returns true if the window holds the output of commands: +Errors or a window
+// that output was redirected to.
+func IsOutputWindow(windowFilename string) bool {
+	return IsErrorsWindow(windowFilename) || outputWindows[windowFilename]
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOutputRedirect(t *testing.T) {
+	tests := []struct {
+		args         []string
+		expectedArgs []string
+		expectedWin  string
+		expectErr    bool
+	}{
+		{args: []string{"-v", "./..."}, expectedArgs: []string{"-v", "./..."}},
+		{args: []string{"-v", ">win:+test"}, expectedArgs: []string{"-v"}, expectedWin: "+test"},
+		{args: []string{">win:/tmp/+lint"}, expectedArgs: []string{}, expectedWin: "/tmp/+lint"},
+		{args: []string{">win:+test", "-v"}, expectErr: true},
+		{args: []string{">win:"}, expectErr: true},
+	}
+
+	for _, tc := range tests {
+		args, win, err := parseOutputRedirect(tc.args)
+		if tc.expectErr {
+			if err == nil {
+				t.Fatalf("expected parsing %v to fail", tc.args)
+			}
+			continue
+		}
+		if err != nil || win != tc.expectedWin || !reflect.DeepEqual(args, tc.expectedArgs) {
+			t.Fatalf("for %v expected %v and window %q but got %v, %q, %v", tc.args, tc.expectedArgs, tc.expectedWin, args, win, err)
+		}
+	}
+}
+
+func TestOutputWindowFileNameAndJobName(t *testing.T) {
+	if n := outputWindowFileName("/src/proj/", "+build"); n != "/src/proj+build" {
+		t.Fatalf("expected the window to be in the directory but got %s", n)
+	}
+	if n := outputWindowFileName("/src/proj", "/tmp/+lint"); n != "/tmp/+lint" {
+		t.Fatalf("expected a full name to be kept but got %s", n)
+	}
+
+	name := outputJobName("make", "+build")
+	if name != "make>win:+build" {
+		t.Fatalf("unexpected job name %s", name)
+	}
+	for _, n := range []string{"make", "make>win:+build"} {
+		if !jobNameMatches(name, n) {
+			t.Fatalf("expected %s to select the job %s", n, name)
+		}
+	}
+	if jobNameMatches(name, "mak") || jobNameMatches("make", "make>win:+build") {
+		t.Fatalf("expected other names not to select the job")
+	}
+}
+
+func TestCommandOutputRedirectedToNamedWindow(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	onMainGoroutine(func() {
+		NewCommandExecutor(nil).Do("echo built >win:+build", &CmdContext{Dir: dir})
+	})
+
+	name := outputWindowFileName(dir, "+build")
+	var out string
+	for i := 0; i < 150 && !strings.Contains(out, "completed"); i++ {
+		time.Sleep(20 * time.Millisecond)
+		onMainGoroutine(func() {
+			if w, _ := editor.FindWindowForFile(name); w != nil {
+				out = w.Body.String()
+			}
+		})
+	}
+	if !strings.HasPrefix(out, "built\n[echo>win:+build: completed ") {
+		t.Fatalf("expected the output in the named window but got %q", out)
+	}
+
+	onMainGoroutine(func() {
+		w, _ := editor.FindWindowForFile(name)
+		if w.IsDirty() || !w.CanDelete() {
+			t.Fatalf("expected the output window to be deletable without saving")
+		}
+		if errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(dir)); errs != nil && strings.Contains(errs.Body.String(), "built") {
+			t.Fatalf("expected no output in +Errors")
+		}
+	})
+}
+
+func TestOutputRedirectRejectedForBuiltinsAndPipes(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	onMainGoroutine(func() {
+		NewCommandExecutor(nil).Do("Look x >win:+out", &CmdContext{Dir: dir})
+		NewCommandExecutor(nil).Do("|sort >win:+out", &CmdContext{Dir: dir})
+
+		errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(dir))
+		if errs == nil {
+			t.Fatalf("expected the redirections to be rejected in +Errors")
+		}
+		text := errs.Body.String()
+		if !strings.Contains(text, "Look: the output of built-in commands can't be redirected") ||
+			!strings.Contains(text, "|sort: the output of | commands replaces the selection") {
+			t.Fatalf("unexpected errors %q", text)
+		}
+	})
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"github.com/jeffwilliams/anvil/internal/linediff"
+	"github.com/jeffwilliams/anvil/internal/runes"
+)
+
+// maxReloadDiffChanges is the most lines inserted or deleted that are looked for when finding
+// the lines of a reloaded file that changed. If more lines changed, all the lines from the first
+// change to the last are replaced. Finding the changes takes memory proportional to the square of
+// this.
+const maxReloadDiffChanges = 1000
+
+// maxUndoableReloadSize is the most bytes that reloading a file may delete and insert in the
+// body and still be undoable. Larger reloads reset the body and its undo history as loading a
+// file does, so that the text kept for undo doesn't grow without bound.
+var maxUndoableReloadSize = 8 * 1024 * 1024
+
+// bodyReload is the new text of a reloaded body and how it differs from the text of the body when
+// the reload began. The differences are found by the loader so that the main goroutine only has
+// to apply them.
+type bodyReload struct {
+	data []byte
+	// old is the text of the body at generation, and diffs are the changes from old to data.
+	old        []byte
+	generation int
+	diffs      []linediff.Edit
+}
+
+// newBodyReload returns the reload of the body from the text old, the text of the body at
+// generation, to data. It may be called from any goroutine.
+func newBodyReload(old []byte, generation int, data []byte) bodyReload {
+	return bodyReload{
+		data:       data,
+		old:        old,
+		generation: generation,
+		diffs:      linediff.Diff(old, data, maxReloadDiffChanges),
+	}
+}
+
+// reloadBody replaces the text of the body with the current contents of the window's file.
+// If the change is small enough it is made by editing the lines that differ, so that it can be
+// undone, and the cursors and selections in the unchanged text keep their line and column.
+// Otherwise, or if the body changed since the reload began, the body is reset and the cursor is
+// moved to goTo.
+func (w *Window) reloadBody(r bodyReload, goTo seek, selectBehaviour selectBehaviour) {
+	switch {
+	case w.Body.generation != r.generation:
+		log(LogCatgWin, "Window.reloadBody: %s was edited while it was reloaded. Resetting the body\n", w.file)
+	case w.reloadBodyByEditing(r):
+		return
+	default:
+		log(LogCatgWin, "Window.reloadBody: the changes to %s are too large to undo. Resetting the body\n", w.file)
+	}
+
+	w.Body.SetTextStringNoUndo(string(r.data))
+	if !goTo.empty() {
+		w.Body.AddOpForNextLayout(func(gtx layout.Context) {
+			w.Body.moveCursorTo(gtx, goTo, selectBehaviour)
+		})
+	}
+}
+
+// reloadBodyByEditing changes the text of the body as one transaction that edits only the lines
+// that differ. It returns false if the changes are too large or can't be made.
+func (w *Window) reloadBodyByEditing(r bodyReload) bool {
+	body := &w.Body.editable
+	if body.writeLock.isLocked() || body.immutableRange.Len() != 0 {
+		return false
+	}
+
+	// The body must match the file even if the file can't be written, so the guard that refuses
+	// changes to such windows is not consulted.
+	guard := body.modificationGuard
+	body.modificationGuard = nil
+	defer func() { body.modificationGuard = guard }()
+	return w.editBodyByDiffs(r.old, r.diffs)
+}
+
+// editBodyByDiffs applies diffs, the differences between old, the current text of the body, and
+// the new text, to the body as one transaction. It returns false if the changes are too large.
+func (w *Window) editBodyByDiffs(old []byte, diffs []linediff.Edit) bool {
+	body := &w.Body.editable
+	size := 0
+	for _, d := range diffs {
+		size += d.End - d.Start + len(d.Text)
+	}
+	if size > maxUndoableReloadSize {
+		return false
+	}
+	if len(diffs) == 0 {
+		return true
+	}
+
+	edits := make([]apiEdit, len(diffs))
+	walk := runes.NewWalker(old)
+	for i, d := range diffs {
+		walk.ForwardBytes(d.Start - walk.BytePos())
+		start := walk.RunePos()
+		walk.ForwardBytes(d.End - d.Start)
+		edits[i] = apiEdit{Offset: start, Length: walk.RunePos() - start, Text: string(d.Text)}
+	}
+
+	topLeft := topLeftAfterEdits(body.TopLeftIndex, edits)
+
+	// The outer transaction keeps the changes from being merged with the text typed before them.
+	body.text.StartOuterTransaction()
+	w.applyEdits(edits)
+	body.text.EndOuterTransaction()
+
+	body.SetTopLeft(topLeft)
+	return true
+}
+
+// topLeftAfterEdits returns where the top-left index topLeft is after the sorted edits are
+// applied. If the edits change the text at topLeft, it moves to the start of that edit.
+func topLeftAfterEdits(topLeft int, edits []apiEdit) int {
+	shift := 0
+	for _, e := range edits {
+		if e.Offset >= topLeft {
+			break
+		}
+		if e.Offset+e.Length > topLeft {
+			topLeft = e.Offset
+			break
+		}
+		shift += utf8.RuneCountInString(e.Text) - e.Length
+	}
+	return topLeft + shift
+}
+
+// winReloadData replaces the body of the window with the contents of its file once they are
+// all loaded.
+type winReloadData struct {
+	job             Job
+	win             WindowHolder
+	reload          bodyReload
+	goTo            seek
+	selectBehaviour selectBehaviour
+}
+
+func (l winReloadData) Service() (done bool) {
+	win := l.win.Get()
+	if win != nil {
+		win.reloadBody(l.reload, l.goTo, l.selectBehaviour)
+	}
+	return false
+}
+
+func (l winReloadData) Job() Job {
+	return l.job
+}
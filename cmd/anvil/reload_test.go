@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForBody waits until the body of win holds text.
+func waitForBody(t *testing.T, win *Window, text string) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var body string
+		onMainGoroutine(func() { body = win.Body.String() })
+		if body == text {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the body to be %q but it is %q", text, body)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestGetKeepsUndoHistoryAndCursor(t *testing.T) {
+	startHeadlessEditor(t)
+
+	path := filepath.Join(t.TempDir(), "reload.txt")
+	if err := os.WriteFile(path, []byte("one\ntwo\nthree\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.LoadFile(path)
+	})
+	waitForBody(t, win, "one\ntwo\nthree\n")
+
+	onMainGoroutine(func() {
+		win.Body.insertToPieceTable(8, "3 ")
+	})
+
+	if err := os.WriteFile(path, []byte("zero\none\ntwo\nthree!\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+	onMainGoroutine(func() {
+		// The cursor is on the "w" of "two".
+		win.Body.CursorIndices = []int{5}
+		win.Get()
+	})
+	waitForBody(t, win, "zero\none\ntwo\nthree!\n")
+
+	onMainGoroutine(func() {
+		if c := win.Body.firstCursorIndex(); c != 10 {
+			t.Errorf("expected the cursor to stay on the \"w\" of \"two\" at 10 but it is at %d", c)
+		}
+		if win.IsDirty() {
+			t.Errorf("expected the window to be clean after Get")
+		}
+
+		win.Body.applyUndoOrRedo(win.Body.text.Undo, -1)
+		if body := win.Body.String(); body != "one\ntwo\n3 three\n" {
+			t.Errorf("expected one undo to return to the text from before Get but the body is %q", body)
+		}
+
+		win.Body.applyUndoOrRedo(win.Body.text.Undo, -1)
+		if body := win.Body.String(); body != "one\ntwo\nthree\n" {
+			t.Errorf("expected another undo to undo the edit made before Get but the body is %q", body)
+		}
+	})
+}
+
+func TestGetResetsLargeReloads(t *testing.T) {
+	startHeadlessEditor(t)
+
+	old := maxUndoableReloadSize
+	maxUndoableReloadSize = 4
+	t.Cleanup(func() { maxUndoableReloadSize = old })
+
+	path := filepath.Join(t.TempDir(), "reload.txt")
+	if err := os.WriteFile(path, []byte("one\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.LoadFile(path)
+	})
+	waitForBody(t, win, "one\n")
+
+	if err := os.WriteFile(path, []byte("two\nthree\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+	onMainGoroutine(func() { win.Get() })
+	waitForBody(t, win, "two\nthree\n")
+
+	onMainGoroutine(func() {
+		win.Body.applyUndoOrRedo(win.Body.text.Undo, -1)
+		if body := win.Body.String(); body != "two\nthree\n" {
+			t.Errorf("expected a large reload to discard the undo history but the body is %q after undo", body)
+		}
+	})
+}
+
+func TestFailedGetKeepsUnsavedChanges(t *testing.T) {
+	newTestHeadless()
+	path := filepath.Join(t.TempDir(), "reload.txt")
+	win := editor.Cols[0].NewWindow()
+	win.SetFilenameAndTag(path, typeFile)
+	win.Body.SetTextString("one\n")
+	win.markTextAsUnchanged()
+	win.Body.insertToPieceTable(0, "unsaved ")
+	if !win.IsDirty() {
+		t.Fatalf("expected the window to be dirty after the edit")
+	}
+
+	// The file stops arriving part-way, as when an ssh connection drops.
+	load := NewDataLoad()
+	wl := &WindowDataLoad{
+		DataLoad: *load,
+		Win:      NewWindowHolder(win),
+		Jobname:  "reload.txt",
+		Reload:   true,
+	}
+	go func() {
+		load.Contents <- []byte("on")
+		load.Errs <- errors.New("connection lost")
+		close(load.Contents)
+		close(load.Filenames)
+		close(load.Errs)
+	}()
+
+	c := make(chan Work)
+	wl.Start(c)
+	for w := range c {
+		w.Service()
+		if _, ok := w.(*winLoadDone); ok {
+			break
+		}
+	}
+
+	if body := win.Body.String(); body != "unsaved one\n" {
+		t.Fatalf("expected the body to be left as it was but it is %q", body)
+	}
+	if !win.IsDirty() {
+		t.Fatalf("expected the unsaved changes to still be marked as unsaved")
+	}
+	errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(errorsDirOfWindow(win)))
+	if errs == nil || !strings.Contains(errs.Body.String(), "the window was left as it was") {
+		t.Fatalf("expected the failure to be reported in +Errors")
+	}
+}
+
+func TestReloadDiffIsFoundByTheLoader(t *testing.T) {
+	newTestHeadless()
+	win := editor.Cols[0].NewWindow()
+	win.Body.SetTextString("one\ntwo\n")
+
+	reload := func(edit func()) *winReloadData {
+		load := NewDataLoad()
+		wl := &WindowDataLoad{
+			DataLoad:         *load,
+			Win:              NewWindowHolder(win),
+			Jobname:          "reload.txt",
+			Reload:           true,
+			ReloadOld:        win.Body.Bytes(),
+			ReloadGeneration: win.Body.generation,
+		}
+		go func() {
+			load.Contents <- []byte("one\n2\n")
+			close(load.Contents)
+			close(load.Filenames)
+			close(load.Errs)
+		}()
+
+		var r *winReloadData
+		c := make(chan Work)
+		wl.Start(c)
+		for w := range c {
+			if x, ok := w.(*winReloadData); ok {
+				r = x
+				edit()
+			}
+			w.Service()
+			if _, ok := w.(*winLoadDone); ok {
+				break
+			}
+		}
+		return r
+	}
+
+	r := reload(func() {})
+	if r == nil || len(r.reload.diffs) != 1 {
+		t.Fatalf("expected the loader to send the one line that changed")
+	}
+	if body := win.Body.String(); body != "one\n2\n" {
+		t.Fatalf("expected the body to be reloaded but it is %q", body)
+	}
+	win.Body.applyUndoOrRedo(win.Body.text.Undo, -1)
+	if body := win.Body.String(); body != "one\ntwo\n" {
+		t.Fatalf("expected the reload to be undoable but the body is %q after undo", body)
+	}
+
+	// The changes found by the loader don't apply to a body edited while it was loading.
+	reload(func() { win.Body.insertToPieceTable(0, "zero\n") })
+	if body := win.Body.String(); body != "one\n2\n" {
+		t.Fatalf("expected the body edited during the reload to be reset but it is %q", body)
+	}
+}
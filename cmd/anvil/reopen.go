@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// closedFile is a file whose last window was closed, with where the cursor was when it was closed.
+type closedFile struct {
+	File   string    `json:"file"`
+	Cursor int       `json:"cursor"`
+	Closed time.Time `json:"closed"`
+}
+
+// closedFileList is the list of files most recently closed, most recent first, that Reopen
+// reopens. It is saved to ClosedFilesFile whenever it changes so that it outlives the editor.
+type closedFileList struct {
+	files  []closedFile
+	loaded bool
+}
+
+func (l *closedFileList) load() {
+	if l.loaded {
+		return
+	}
+	l.loaded = true
+
+	b, err := os.ReadFile(ClosedFilesFile())
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log(LogCatgConf, "Loading the list of closed files failed: %v\n", err)
+		}
+		return
+	}
+
+	err = json.Unmarshal(b, &l.files)
+	if err != nil {
+		log(LogCatgConf, "Loading the list of closed files failed: %v\n", err)
+		l.files = nil
+	}
+	l.truncate()
+}
+
+func (l *closedFileList) save() {
+	b, err := json.MarshalIndent(l.files, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(ConfDir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(ClosedFilesFile(), b, 0644)
+	}
+	if err != nil {
+		log(LogCatgConf, "Saving the list of closed files failed: %v\n", err)
+	}
+}
+
+func (l *closedFileList) truncate() {
+	max := settings.General.ClosedFilesMax
+	if max < 0 {
+		max = 0
+	}
+	if len(l.files) > max {
+		l.files = l.files[:max]
+	}
+}
+
+// add puts f at the front of the list, removing any earlier entry for the same file.
+func (l *closedFileList) add(f closedFile) {
+	if settings.General.ClosedFilesMax <= 0 {
+		return
+	}
+	l.load()
+	l.remove(f.File)
+	l.files = append([]closedFile{f}, l.files...)
+	l.truncate()
+	l.save()
+}
+
+func (l *closedFileList) remove(file string) {
+	for i, f := range l.files {
+		if f.File == file {
+			l.files = append(l.files[:i], l.files[i+1:]...)
+			return
+		}
+	}
+}
+
+// all returns a copy of the list, most recently closed first.
+func (l *closedFileList) all() []closedFile {
+	l.load()
+	return append([]closedFile(nil), l.files...)
+}
+
+// pop removes and returns the most recently closed file for which skip returns false.
+func (l *closedFileList) pop(skip func(file string) bool) (f closedFile, ok bool) {
+	l.load()
+	for i := range l.files {
+		if skip(l.files[i].File) {
+			continue
+		}
+		f, ok = l.files[i], true
+		l.files = append(l.files[:i], l.files[i+1:]...)
+		l.save()
+		return
+	}
+	return
+}
+
+// noteFileClosed records that the last window for the file of w is being closed so that
+// Reopen can open it again.
+func (e *Editor) noteFileClosed(w *Window) {
+	if w.fileType != typeFile || w.file == "" || IsOutputWindow(w.file) || w.IsLiveWindow() || w.IsFindWindow() || w.IsDiffWindow() || w.IsTutorialWindow() {
+		return
+	}
+
+	e.closedFiles.add(closedFile{
+		File:   w.file,
+		Cursor: w.Body.firstCursorIndex(),
+		Closed: time.Now(),
+	})
+}
+
+func (c CommandExecutor) CmdReopen(ctx *CmdContext) {
+	isOpen := func(file string) bool {
+		w, _ := editor.FindWindowForFile(file)
+		return w != nil
+	}
+
+	f, ok := editor.closedFiles.pop(isOpen)
+	if !ok {
+		editor.AppendError("", "Reopen: there are no closed files to reopen")
+		return
+	}
+
+	opts := LoadFileOpts{
+		GoTo:              seek{seekType: seekToRunePos, runePos: f.Cursor},
+		SelectBehaviour:   dontSelectText,
+		GrowBodyBehaviour: growBodyIfTooSmall,
+	}
+	editor.LoadFileOpts(f.File, opts)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestReopenWalksBackThroughClosedFiles(t *testing.T) {
+	oldConfDir := ConfDir
+	t.Cleanup(func() { ConfDir = oldConfDir })
+	ConfDir = t.TempDir()
+
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	for _, p := range []string{a, b} {
+		if err := os.WriteFile(p, []byte("some text\n"), 0644); err != nil {
+			t.Fatalf("writing the file failed: %v", err)
+		}
+	}
+
+	open := func(path string) *Window {
+		var win *Window
+		onMainGoroutine(func() {
+			win = editor.Cols[0].NewWindow()
+			win.LoadFile(path)
+		})
+		waitForBody(t, win, "some text\n")
+		return win
+	}
+	closeWin := func(win *Window) {
+		onMainGoroutine(func() {
+			editor.DelWindow(win)
+			editor.Cols[0].removeWindowsMarkedForRemoval()
+		})
+	}
+
+	// a is closed twice but is only listed once.
+	closeWin(open(a))
+	win := open(b)
+	onMainGoroutine(func() { win.Body.SetCursorIndex(0, 5) })
+	closeWin(win)
+	closeWin(open(a))
+
+	var list closedFileList
+	files := list.all()
+	if len(files) != 2 || files[0].File != a || files[1].File != b || files[1].Cursor != 5 {
+		t.Fatalf("expected the saved list to hold %s then %s with the cursor at 5 but it is %+v", a, b, files)
+	}
+
+	reopen := func(expected string) {
+		t.Helper()
+		var win *Window
+		onMainGoroutine(func() {
+			NewCommandExecutor(editor).Do("Reopen", &CmdContext{})
+			win, _ = editor.FindWindowForFile(expected)
+		})
+		if win == nil {
+			t.Fatalf("expected Reopen to open %s", expected)
+		}
+	}
+	reopen(a)
+	reopen(b)
+
+	onMainGoroutine(func() { files = editor.closedFiles.all() })
+	if len(files) != 0 {
+		t.Fatalf("expected the reopened files to be removed from the list but it is %+v", files)
+	}
+}
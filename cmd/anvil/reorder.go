@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// windowIndex returns the position of w among the positioned windows of the column, or -1 if it
+// is not one of them.
+func (c *Col) windowIndex(w *Window) int {
+	for i, x := range c.Windows {
+		if x == w {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveWindowToIndex moves w so that it is at position i among the windows of the column, counting
+// from 0 at the top. The windows keep their heights. It returns an error if w is not positioned
+// in the column or i is not a valid position.
+func (c *Col) moveWindowToIndex(w *Window, i int) error {
+	from := c.windowIndex(w)
+	if from < 0 {
+		return fmt.Errorf("the window has not been positioned in its column yet")
+	}
+	if i < 0 || i >= len(c.Windows) {
+		return fmt.Errorf("there is no position %d in the column", i+1)
+	}
+	if from == i {
+		return nil
+	}
+
+	log(LogCatgCol, "Col.moveWindowToIndex: moving window %d from position %d to %d\n", w.Id, from, i)
+	ps := c.asPackables(c.Windows)
+	p := NewPacker(0, c.vspace, ps)
+	ps = p.MoveToIndex(from, i)
+	c.setWindowsTo(ps)
+	if c.ed != nil {
+		c.ed.SignalRedrawRequired()
+	}
+	return nil
+}
+
+// visibleColIndex returns the position of col in cols, or -1 if it is not one of them.
+func visibleColIndex(cols []*Col, col *Col) int {
+	for i, c := range cols {
+		if c == col {
+			return i
+		}
+	}
+	return -1
+}
+
+// moveColToIndex moves the visible column col so that it is at position i among the visible
+// columns, counting from 0 at the left. The columns keep their widths.
+func (e *Editor) moveColToIndex(col *Col, i int) error {
+	cols := e.VisibleCols()
+	from := visibleColIndex(cols, col)
+	if from < 0 {
+		return fmt.Errorf("the column is not visible")
+	}
+	if i < 0 || i >= len(cols) {
+		return fmt.Errorf("there is no column %d", i+1)
+	}
+	if from == i {
+		return nil
+	}
+
+	log(LogCatgEditor, "Editor.moveColToIndex: moving column %d from position %d to %d\n", col.Id, from, i)
+	ps := e.asPackables(cols)
+	p := NewPacker(0, e.hspace, ps)
+	ps = p.MoveToIndex(from, i)
+
+	newCols := make([]*Col, 0, len(e.Cols))
+	for _, c := range e.Cols {
+		if !c.Visible() {
+			newCols = append(newCols, c)
+		}
+	}
+	for _, c := range ps {
+		newCols = append(newCols, c.(*Col))
+	}
+	e.Cols = newCols
+	e.SignalRedrawRequired()
+	return nil
+}
+
+// moveWindowInCol implements the commands that move the window they are executed in within its
+// column. newIndex returns the position to move the window to given its current position.
+func (c CommandExecutor) moveWindowInCol(name string, newIndex func(i int) int) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", fmt.Sprintf("%s: must be executed in a window", name))
+		return
+	}
+
+	col := w.col
+	from := col.windowIndex(w)
+	if from < 0 {
+		editor.AppendError("", fmt.Sprintf("%s: the window has not been positioned in its column yet", name))
+		return
+	}
+	to := newIndex(from)
+	if to < 0 || to >= len(col.Windows) || to == from {
+		end := "top"
+		if to > from {
+			end = "bottom"
+		}
+		editor.AppendError("", fmt.Sprintf("%s: the window is already at the %s of its column", name, end))
+		return
+	}
+
+	if err := col.moveWindowToIndex(w, to); err != nil {
+		editor.AppendError("", fmt.Sprintf("%s: %v", name, err))
+	}
+}
+
+func (c CommandExecutor) CmdUp(ctx *CmdContext) {
+	c.moveWindowInCol("Up", func(i int) int { return i - 1 })
+}
+
+func (c CommandExecutor) CmdDown(ctx *CmdContext) {
+	c.moveWindowInCol("Down", func(i int) int { return i + 1 })
+}
+
+func (c CommandExecutor) CmdPromote(ctx *CmdContext) {
+	c.moveWindowInCol("Promote", func(i int) int { return 0 })
+}
+
+// colForReorder returns the column that the column reordering commands operate on. That is the
+// column named by arg, as for Editor.FindCol, if it is not empty; otherwise the column the
+// command was executed in.
+func (c CommandExecutor) colForReorder(arg string) (*Col, error) {
+	if arg != "" {
+		return editor.FindCol(arg)
+	}
+
+	switch s := c.source.(type) {
+	case *Window:
+		return s.col, nil
+	case *Col:
+		return s, nil
+	}
+	return nil, fmt.Errorf("a column number or name is required when not executed in a column or window")
+}
+
+// moveCol implements the commands that move a column left or right by delta positions.
+func (c CommandExecutor) moveCol(name string, ctx *CmdContext, delta int) {
+	col, err := c.colForReorder(strings.TrimSpace(ctx.CombinedArgs()))
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("%s: %v", name, err))
+		return
+	}
+
+	cols := editor.VisibleCols()
+	from := visibleColIndex(cols, col)
+	if from < 0 {
+		editor.AppendError("", fmt.Sprintf("%s: the column is not visible", name))
+		return
+	}
+
+	to := from + delta
+	if to < 0 {
+		editor.AppendError("", fmt.Sprintf("%s: the column is already the leftmost", name))
+		return
+	}
+	if to >= len(cols) {
+		editor.AppendError("", fmt.Sprintf("%s: the column is already the rightmost", name))
+		return
+	}
+
+	if err := editor.moveColToIndex(col, to); err != nil {
+		editor.AppendError("", fmt.Sprintf("%s: %v", name, err))
+	}
+}
+
+func (c CommandExecutor) CmdColleft(ctx *CmdContext) {
+	c.moveCol("Colleft", ctx, -1)
+}
+
+func (c CommandExecutor) CmdColright(ctx *CmdContext) {
+	c.moveCol("Colright", ctx, 1)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReorderWindowsInColumn(t *testing.T) {
+	startHeadlessEditor(t)
+
+	onMainGoroutine(func() {
+		col := editor.Cols[0]
+		col.vspace = 300
+		a := col.NewWindowDontPosition()
+		b := col.NewWindowDontPosition()
+		c := col.NewWindowDontPosition()
+		a.TopY, b.TopY, c.TopY = 0, 100, 150
+		editor.focusedWindow = c
+
+		checkOrder := func(when string, wins []*Window, tops []int) {
+			t.Helper()
+			for i, w := range wins {
+				if col.Windows[i] != w || w.TopY != tops[i] {
+					t.Fatalf("%s: expected window %d at position %d with top %d but window %d is there with top %d",
+						when, w.Id, i, tops[i], col.Windows[i].Id, col.Windows[i].TopY)
+				}
+			}
+		}
+
+		NewCommandExecutor(a).Do("Down", &CmdContext{})
+		// The windows keep their heights of 100, 50 and 150.
+		checkOrder("after Down", []*Window{b, a, c}, []int{0, 50, 150})
+
+		NewCommandExecutor(c).Do("Promote", &CmdContext{})
+		checkOrder("after Promote", []*Window{c, b, a}, []int{0, 150, 200})
+
+		NewCommandExecutor(a).Do("Up", &CmdContext{})
+		checkOrder("after Up", []*Window{c, a, b}, []int{0, 150, 250})
+
+		if editor.focusedWindow != c {
+			t.Fatalf("expected the focused window to stay focused")
+		}
+
+		NewCommandExecutor(c).Do("Up", &CmdContext{})
+		checkOrder("after Up at the top", []*Window{c, a, b}, []int{0, 150, 250})
+		errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		if errs == nil || !strings.Contains(errs.Body.String(), "Up: the window is already at the top of its column") {
+			t.Fatalf("expected moving the top window up to be reported")
+		}
+	})
+}
+
+func TestReorderColumns(t *testing.T) {
+	startHeadlessEditor(t)
+
+	onMainGoroutine(func() {
+		editor.hspace = 1000
+		a := editor.Cols[0]
+		b := editor.NewColDontPosition()
+		hidden := editor.NewColDontPosition()
+		c := editor.NewColDontPosition()
+		b.LeftX, c.LeftX = 300, 700
+		hidden.SetVisible(false)
+		c.Tag.SetTextStringNoUndo("Logs New Cut")
+
+		checkOrder := func(when string, cols []*Col, lefts []int) {
+			t.Helper()
+			visible := editor.VisibleCols()
+			for i, c := range cols {
+				if visible[i] != c || c.LeftX != lefts[i] {
+					t.Fatalf("%s: expected column %d at position %d with left %d but column %d is there with left %d",
+						when, c.Id, i, lefts[i], visible[i].Id, visible[i].LeftX)
+				}
+			}
+		}
+
+		NewCommandExecutor(a).Do("Colright", &CmdContext{})
+		// The columns keep their widths of 300, 400 and 300.
+		checkOrder("after Colright", []*Col{b, a, c}, []int{0, 400, 700})
+
+		NewCommandExecutor(editor).Do("Colleft Logs", &CmdContext{})
+		checkOrder("after Colleft", []*Col{b, c, a}, []int{0, 400, 700})
+
+		if len(editor.Cols) != 4 || editor.Cols[0] != hidden {
+			t.Fatalf("expected the hidden column to be kept")
+		}
+
+		NewCommandExecutor(editor).Do("Colleft 1", &CmdContext{})
+		checkOrder("after Colleft of the leftmost", []*Col{b, c, a}, []int{0, 400, 700})
+		errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		if errs == nil || !strings.Contains(errs.Body.String(), "Colleft: the column is already the leftmost") {
+			t.Fatalf("expected moving the leftmost column left to be reported")
+		}
+	})
+}
@@ -19,6 +19,11 @@ func NewScheduler(work chan Work) *Scheduler {
 // AfterFunc waits for the duration to elapse and then calls f in its own goroutine.
 // If there is already a timer started for "id" it is stopped and a new one created (the durection is reset).
 func (s *Scheduler) AfterFunc(id string, d time.Duration, f func()) {
+	s.AfterFuncWithPriority(id, d, workPriorityNormal, f)
+}
+
+// AfterFuncWithPriority is like AfterFunc but f is called as work in the band p.
+func (s *Scheduler) AfterFuncWithPriority(id string, d time.Duration, p workPriority, f func()) {
 	s.init()
 	t, ok := s.timers[id]
 	if ok {
@@ -26,7 +31,7 @@ func (s *Scheduler) AfterFunc(id string, d time.Duration, f func()) {
 	}
 
 	t = time.AfterFunc(d, func() {
-		s.work <- scheduledWork{f, id, s}
+		s.work <- scheduledWork{f, id, s, p}
 	})
 	s.timers[id] = t
 }
@@ -51,11 +56,13 @@ type scheduledWork struct {
 	f  func()
 	id string
 	s  *Scheduler
+	p  workPriority
 }
 
 func (w scheduledWork) Service() (done bool) {
-	w.f()
+	// Remove the timer before calling f so that f may schedule itself again.
 	delete(w.s.timers, w.id)
+	w.f()
 	return true
 }
 
@@ -63,6 +70,10 @@ func (w scheduledWork) Job() Job {
 	return nil
 }
 
+func (w scheduledWork) priority() workPriority {
+	return w.p
+}
+
 type basicWork struct {
 	f func()
 }
@@ -27,6 +27,9 @@ type scrollbar struct {
 	//dragging     bool
 	pointerState     PointerState
 	eventInterceptor *events.EventInterceptor
+	// pipSources produce the pips drawn over the scrollbar.
+	pipSources []scrollPipSource
+	pips       []scrollPip
 }
 
 type scrollbarStyle struct {
@@ -35,6 +38,8 @@ type scrollbarStyle struct {
 	GutterWidth unit.Dp
 	LineSpacing unit.Dp
 	Fonts       []FontStyle
+	// PipColors are the colours of the pips, by kind.
+	PipColors [tintPip + 1]color.NRGBA
 }
 
 func (b *scrollbar) Init(style scrollbarStyle, windowBody *Body) {
@@ -158,9 +163,43 @@ func (b *scrollbar) draw(gtx layout.Context) layout.Dimensions {
 	paint.PaintOp{}.Add(gtx.Ops)
 	st.Pop()
 
+	b.drawPips(gtx)
+
 	return layout.Dimensions{Size: image.Point{X: gtx.Metric.Dp(b.style.GutterWidth), Y: gtx.Constraints.Max.Y}}
 }
 
+// addPipSource registers a feature that shows pips in the scrollbar.
+func (b *scrollbar) addPipSource(src scrollPipSource) {
+	b.pipSources = append(b.pipSources, src)
+}
+
+// drawPips draws a short tick across the scrollbar for each pip, at the same fraction of the
+// height of the scrollbar as the pip is of the text.
+func (b *scrollbar) drawPips(gtx layout.Context) {
+	if !settings.General.ScrollPips || len(b.pipSources) == 0 {
+		return
+	}
+
+	b.pips = b.pips[:0]
+	for _, src := range b.pipSources {
+		b.pips = src(b.pips)
+	}
+
+	w := gtx.Metric.Dp(b.style.GutterWidth - 1)
+	h := gtx.Metric.Dp(unit.Dp(2))
+	maxY := gtx.Constraints.Max.Y - h
+	for _, p := range b.pips {
+		if p.index < 0 || p.index > b.windowBody.Len() {
+			continue
+		}
+		y := int(b.windowBody.documentFraction(p.index) * float32(maxY))
+		st := clip.Rect{Min: image.Pt(0, y), Max: image.Pt(w, y+h)}.Push(gtx.Ops)
+		paint.ColorOp{Color: b.style.PipColors[p.kind]}.Add(gtx.Ops)
+		paint.PaintOp{}.Add(gtx.Ops)
+		st.Pop()
+	}
+}
+
 func (b scrollbar) buttonPositions(gtx layout.Context) (top, bottom int) {
 	bdy := b.windowBody
 	textLen := len(bdy.Bytes())
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+
+	"gioui.org/f32"
+)
+
+func TestScrollMovesCursor(t *testing.T) {
+	win, gtx := dragHoldTestWindow(t)
+	e := &win.Body.editable
+
+	scroll := func(dy float32) {
+		e.pointerState.gtx = gtx
+		e.pointerState.currentPointerEvent.Scroll = f32.Point{Y: dy}
+		e.onPointerScroll(&e.pointerState)
+	}
+
+	onMainGoroutine(func() {
+		e.InitPointerEventHandlers()
+		e.setToOneCursorIndex(0)
+
+		scroll(1)
+		if e.TopLeftIndex == 0 || e.firstCursorIndex() != 0 {
+			t.Fatalf("expected scrolling to leave the cursor at 0 but it is at %d (top %d)", e.firstCursorIndex(), e.TopLeftIndex)
+		}
+
+		NewCommandExecutor(win).Do("Scrollcursor on", &CmdContext{})
+		scroll(1)
+		if e.firstCursorIndex() != e.TopLeftIndex {
+			t.Fatalf("expected the cursor to be pulled down to the top line %d but it is at %d", e.TopLeftIndex, e.firstCursorIndex())
+		}
+
+		// Several cursors are left where they are.
+		e.CursorIndices = []int{0, 1}
+		scroll(1)
+		if e.CursorIndices[0] != 0 || e.CursorIndices[1] != 1 {
+			t.Fatalf("expected the cursors not to move but they are %v", e.CursorIndices)
+		}
+	})
+}
+
+func TestPreciseScrollAddsUpDistance(t *testing.T) {
+	win, gtx := dragHoldTestWindow(t)
+	e := &win.Body.editable
+
+	old := settings.Mouse.PreciseScroll
+	settings.Mouse.PreciseScroll = true
+	defer func() { settings.Mouse.PreciseScroll = old }()
+
+	onMainGoroutine(func() {
+		e.InitPointerEventHandlers()
+		e.pointerState.gtx = gtx
+		h := float32(e.lineHeight())
+
+		e.pointerState.currentPointerEvent.Scroll = f32.Point{Y: h * 0.6}
+		e.onPointerScroll(&e.pointerState)
+		if e.TopLeftIndex != 0 {
+			t.Fatalf("expected less than a line of scrolling not to scroll but the top is %d", e.TopLeftIndex)
+		}
+
+		e.onPointerScroll(&e.pointerState)
+		if e.TopLeftIndex != len("line 0\n") {
+			t.Fatalf("expected the text to be scrolled by one line but the top is %d", e.TopLeftIndex)
+		}
+	})
+}
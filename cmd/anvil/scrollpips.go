@@ -0,0 +1,126 @@
+package main
+
+// maxSearchPips is the most matches of the last search that are shown as pips in the scrollbar.
+const maxSearchPips = 1000
+
+// scrollPipKind is what a scroll pip marks. The scrollbar style gives each kind its colour.
+type scrollPipKind int
+
+const (
+	searchMatchPip scrollPipKind = iota
+	markPip
+	tintPip
+)
+
+// scrollPip is a tick drawn in the scrollbar at the relative position of a place in the body, so
+// that places of interest in a long file can be seen and jumped to.
+type scrollPip struct {
+	kind scrollPipKind
+	// index is the rune index in the body of the place.
+	index int
+}
+
+// scrollPipSource appends the pips for one feature to pips and returns the result.
+type scrollPipSource func(pips []scrollPip) []scrollPip
+
+// searchPipCache holds the positions of the matches of the last search term in the text. They
+// are found when first needed after the search changes and are shifted when the text is
+// modified rather than searched for again.
+type searchPipCache struct {
+	term      string
+	positions []int
+	valid     bool
+}
+
+func (c *searchPipCache) invalidate() {
+	c.valid = false
+	c.positions = c.positions[:0]
+}
+
+// shiftDueToTextModification moves the positions after the change. Matches that were deleted
+// are removed.
+func (c *searchPipCache) shiftDueToTextModification(startOfChange, lengthOfChange int) {
+	if !c.valid {
+		return
+	}
+	kept := c.positions[:0]
+	for _, p := range c.positions {
+		if p >= startOfChange {
+			if lengthOfChange < 0 && p < startOfChange-lengthOfChange {
+				continue
+			}
+			p += lengthOfChange
+		}
+		kept = append(kept, p)
+	}
+	c.positions = kept
+}
+
+// searchMatchPositions returns the rune indexes of the first maxSearchPips matches of the last
+// search term in the text.
+func (e *editable) searchMatchPositions() []int {
+	c := &e.searchPips
+	if c.valid && c.term == e.lastSearchTerm {
+		return c.positions
+	}
+
+	c.invalidate()
+	c.term = e.lastSearchTerm
+	c.valid = true
+	if c.term == "" {
+		return nil
+	}
+
+	for pos := 0; len(c.positions) < maxSearchPips && pos <= e.Len(); {
+		start, end := e.Search(pos, c.term, Forward)
+		if start < 0 {
+			break
+		}
+		c.positions = append(c.positions, start)
+		pos = max(end, start+1)
+	}
+	return c.positions
+}
+
+// documentFraction returns how far through the text the rune at index is, from 0 at the start to
+// 1 at the end. It doesn't need the text to be layed out.
+func (e *editable) documentFraction(index int) float32 {
+	l := e.Len()
+	if l == 0 {
+		return 0
+	}
+	return float32(min(index, l)) / float32(l)
+}
+
+// addScrollPipSources registers the features that show pips in the scrollbar of the window.
+func (w *Window) addScrollPipSources() {
+	w.scrollbar.addPipSource(w.searchScrollPips)
+	w.scrollbar.addPipSource(w.markScrollPips)
+	w.scrollbar.addPipSource(w.tintScrollPips)
+}
+
+func (w *Window) searchScrollPips(pips []scrollPip) []scrollPip {
+	for _, p := range w.Body.searchMatchPositions() {
+		pips = append(pips, scrollPip{kind: searchMatchPip, index: p})
+	}
+	return pips
+}
+
+func (w *Window) markScrollPips(pips []scrollPip) []scrollPip {
+	if w.file == "" {
+		return pips
+	}
+	for _, m := range editor.Marks.marks {
+		if editor.windowFilesAreSame(m.FileName, w.file) {
+			pips = append(pips, scrollPip{kind: markPip, index: m.Index})
+		}
+	}
+	return pips
+}
+
+func (w *Window) tintScrollPips(pips []scrollPip) []scrollPip {
+	for _, h := range w.Body.manualHighlighting {
+		pips = append(pips, scrollPip{kind: tintPip, index: h.Start()})
+	}
+	return pips
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScrollPipsFollowTextChanges(t *testing.T) {
+	startHeadlessEditor(t)
+
+	onMainGoroutine(func() {
+		win := editor.NewWindow(nil)
+		win.SetFilenameAndTag("/tmp/pips.txt", typeFile)
+		win.Body.SetText([]byte("foo bar\nfoo baz\nqux foo\n"))
+		win.Body.lastSearchTerm = "foo"
+
+		pipIndexes := func(src scrollPipSource) (indexes []int) {
+			for _, p := range src(nil) {
+				indexes = append(indexes, p.index)
+			}
+			return
+		}
+
+		if got := pipIndexes(win.searchScrollPips); !reflect.DeepEqual(got, []int{0, 8, 20}) {
+			t.Fatalf("expected pips at the matches of the search but got %v", got)
+		}
+
+		// Inserting before the second match shifts it and the match after it, and deleting the
+		// first match removes its pip.
+		win.Body.insertToPieceTable(8, "12")
+		win.Body.deleteFromPieceTable(0, 3)
+		if got := pipIndexes(win.searchScrollPips); !reflect.DeepEqual(got, []int{7, 19}) {
+			t.Fatalf("expected the pips to be shifted by the changes but got %v", got)
+		}
+
+		editor.Marks.Set("m", win.file, 4)
+		editor.Marks.Set("elsewhere", "/tmp/other.txt", 2)
+		defer editor.Marks.Clear()
+		if got := pipIndexes(win.markScrollPips); !reflect.DeepEqual(got, []int{4}) {
+			t.Fatalf("expected a pip for the mark in the file only but got %v", got)
+		}
+
+		win.Body.AddManualHighlight(10, 14, Color{R: 0xff, A: 0xff})
+		if got := pipIndexes(win.tintScrollPips); !reflect.DeepEqual(got, []int{10}) {
+			t.Fatalf("expected a pip for the tinted text but got %v", got)
+		}
+
+		if f := win.Body.documentFraction(win.Body.Len() / 2); f < 0.45 || f > 0.55 {
+			t.Fatalf("expected the middle of the text to be half way through it but got %v", f)
+		}
+	})
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"github.com/jeffwilliams/anvil/internal/expr"
+	"github.com/jeffwilliams/anvil/internal/runes"
 	"regexp"
 	"strconv"
 	"strings"
@@ -13,6 +14,10 @@ type seek struct {
 	line, col int
 	runePos   int
 	regex     *regexp.Regexp
+	// bytePos is the byte offset for seekToBytePos, and percent the percentage of the text
+	// for seekToPercent.
+	bytePos int
+	percent int
 }
 
 type seekType int
@@ -21,12 +26,16 @@ const (
 	seekToLineAndCol seekType = iota
 	seekToRunePos
 	seekToRegex
+	seekToBytePos
+	seekToPercent
 )
 
 func parseSeekFromFilename(path string) (seeklessPath string, seek seek, err error) {
 	/*
 	   file
 	   file#rune
+	   file#bbyte
+	   file%percent
 	   file!regex
 
 	   file:line
@@ -48,12 +57,41 @@ func parseSeekFromFilename(path string) (seeklessPath string, seek seek, err err
 
 	parts := strings.SplitN(path, ":", 5)
 
+	// parsePercent parses the percentage at the end of path. The % is only taken to start a
+	// seek if only digits follow it, since it is common in file names.
+	parsePercent := func(path string, start int) {
+		i := strings.LastIndexByte(path[start:], '%')
+		if i < 0 {
+			return
+		}
+		i += start
+		digits := path[i+1:]
+		if i >= 1 && digits != "" && strings.Trim(digits, "0123456789") == "" {
+			seeklessPath = path[:i]
+			seek.percent, _ = strconv.Atoi(digits)
+			seek.seekType = seekToPercent
+		}
+	}
+
 	parseRuneIndexOrRegex := func(path string) {
 		seeklessPath = path
-		i := strings.IndexAny(path, "#!")
+		// Don't mistake the ! in archive.zip!file for a regex.
+		start := 0
+		if archive, _, ok := splitArchiveMemberPath(path); ok {
+			start = len(archive) + len(archiveMemberSep)
+		}
+		i := strings.IndexAny(path[start:], "#!")
+		if i < 0 {
+			parsePercent(path, start)
+			return
+		}
+		i += start
 		if i >= 1 && len(path) > i+1 {
 			seeklessPath = path[:i]
-			if path[i] == '#' {
+			if path[i] == '#' && path[i+1] == 'b' {
+				seek.bytePos, _ = strconv.Atoi(path[i+2:])
+				seek.seekType = seekToBytePos
+			} else if path[i] == '#' {
 				seek.runePos, _ = strconv.Atoi(path[i+1:])
 				seek.seekType = seekToRunePos
 			} else if path[i] == '!' {
@@ -184,6 +222,21 @@ func parseSeekFromFilename(path string) (seeklessPath string, seek seek, err err
 	return
 }
 
+// runePosIn returns the rune offset in text that a seek to a rune offset, byte offset or
+// percentage refers to. Offsets outside the text are moved to its start or end.
+func (s seek) runePosIn(text []byte) int {
+	w := runes.NewWalker(text)
+	switch s.seekType {
+	case seekToBytePos:
+		w.ForwardBytes(max(min(s.bytePos, len(text)), 0))
+	case seekToPercent:
+		w.ForwardBytes(len(text) * max(min(s.percent, 100), 0) / 100)
+	default:
+		w.Forward(s.runePos)
+	}
+	return w.RunePos()
+}
+
 func (s seek) empty() bool {
 	return s.line == 0 && s.col == 0 && s.seekType == 0
 }
@@ -202,6 +202,106 @@ func TestSeekParse(t *testing.T) {
 				col:  20,
 			},
 		},
+		{
+			name:                 "vendor.tar.gz!pkg/file.go:42",
+			input:                "vendor.tar.gz!pkg/file.go:42",
+			expectedSeeklessName: "vendor.tar.gz!pkg/file.go",
+			expectedSeek: seek{
+				line: 42,
+			},
+		},
+		{
+			name:                 "vendor.zip!pkg/file.go",
+			input:                "vendor.zip!pkg/file.go",
+			expectedSeeklessName: "vendor.zip!pkg/file.go",
+			expectedSeek:         seek{},
+		},
+		{
+			name:                 "vendor.zip!pkg/file.go!test",
+			input:                "vendor.zip!pkg/file.go!test",
+			expectedSeeklessName: "vendor.zip!pkg/file.go",
+			expectedSeek: seek{
+				seekType: seekToRegex,
+				regex:    regexp.MustCompile(`test`),
+			},
+		},
+		{
+			name:                 "file.c#b1234",
+			input:                "file.c#b1234",
+			expectedSeeklessName: "file.c",
+			expectedSeek: seek{
+				seekType: seekToBytePos,
+				bytePos:  1234,
+			},
+		},
+		{
+			name:                 "file.c%80",
+			input:                "file.c%80",
+			expectedSeeklessName: "file.c",
+			expectedSeek: seek{
+				seekType: seekToPercent,
+				percent:  80,
+			},
+		},
+		{
+			name:                 "report%2Fdraft.txt",
+			input:                "report%2Fdraft.txt",
+			expectedSeeklessName: "report%2Fdraft.txt",
+			expectedSeek:         seek{},
+		},
+		{
+			name:                 "host:file.c#b10",
+			input:                "host:file.c#b10",
+			expectedSeeklessName: "host:file.c",
+			expectedSeek: seek{
+				seekType: seekToBytePos,
+				bytePos:  10,
+			},
+		},
+		{
+			name:                 "192.168.1.2:5001:file.c%50",
+			input:                "192.168.1.2:5001:file.c%50",
+			expectedSeeklessName: "192.168.1.2:5001:file.c",
+			expectedSeek: seek{
+				seekType: seekToPercent,
+				percent:  50,
+			},
+		},
+		{
+			name:                 "C:\\dir\\file.c:12",
+			input:                "C:\\dir\\file.c:12",
+			expectedSeeklessName: "C:\\dir\\file.c",
+			expectedSeek: seek{
+				line: 12,
+			},
+		},
+		{
+			name:                 "C:\\dir\\file.c:12:3",
+			input:                "C:\\dir\\file.c:12:3",
+			expectedSeeklessName: "C:\\dir\\file.c",
+			expectedSeek: seek{
+				line: 12,
+				col:  3,
+			},
+		},
+		{
+			name:                 "C:\\dir\\file.c#b10",
+			input:                "C:\\dir\\file.c#b10",
+			expectedSeeklessName: "C:\\dir\\file.c",
+			expectedSeek: seek{
+				seekType: seekToBytePos,
+				bytePos:  10,
+			},
+		},
+		{
+			name:                 "C:\\dir\\file.c%25",
+			input:                "C:\\dir\\file.c%25",
+			expectedSeeklessName: "C:\\dir\\file.c",
+			expectedSeek: seek{
+				seekType: seekToPercent,
+				percent:  25,
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -245,3 +345,28 @@ func seeksEqual(a, b seek) bool {
 	return true
 
 }
+
+func TestSeekRunePosIn(t *testing.T) {
+	text := []byte("héllo\nworld\n")
+
+	tests := []struct {
+		name     string
+		seek     seek
+		expected int
+	}{
+		{"rune", seek{seekType: seekToRunePos, runePos: 3}, 3},
+		{"rune past end", seek{seekType: seekToRunePos, runePos: 100}, 12},
+		{"byte after multibyte rune", seek{seekType: seekToBytePos, bytePos: 3}, 2},
+		{"byte past end", seek{seekType: seekToBytePos, bytePos: 100}, 12},
+		{"negative byte", seek{seekType: seekToBytePos, bytePos: -4}, 0},
+		{"percent", seek{seekType: seekToPercent, percent: 50}, 5},
+		{"percent past end", seek{seekType: seekToPercent, percent: 150}, 12},
+		{"zero percent", seek{seekType: seekToPercent}, 0},
+	}
+
+	for _, tc := range tests {
+		if p := tc.seek.runePosIn(text); p != tc.expected {
+			t.Fatalf("%s: expected rune %d but got %d", tc.name, tc.expected, p)
+		}
+	}
+}
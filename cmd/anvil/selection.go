@@ -311,6 +311,7 @@ func (e *editableModel) shiftTextRangeDueToTextModificationBounds(r *textRange,
 
 func (e *editable) stopBuildingSelection() {
 	e.selectionBeingBuilt = nil
+	e.dragAutoScroll = nil
 
 	newSel := make([]*selection, 0, len(e.selections))
 
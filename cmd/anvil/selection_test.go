@@ -1,6 +1,10 @@
 package main
 
-import "testing"
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
 
 func TestOverlap(t *testing.T) {
 	// [20,25) (half open)
@@ -31,3 +35,65 @@ func TestOverlap(t *testing.T) {
 	}
 
 }
+
+func TestSelectBox(t *testing.T) {
+	win := newZeroxTestWindow(t, "abcdef\nab\nabcdef\n")
+	body := &win.Body.editable
+
+	// Dragging straight down makes a cursor in each line, at the end of the short line.
+	body.selectBox(3, 13)
+	if !reflect.DeepEqual(body.CursorIndices, []int{3, 9, 13}) || body.SelectionsPresent() {
+		t.Fatalf("expected only the cursors [3 9 13] but got %v and %d selections", body.CursorIndices, len(body.selections))
+	}
+
+	// Dragging up and left selects the columns between the drag points in each line.
+	body.selectBox(13, 1)
+	var sels []textRange
+	for _, s := range body.selections {
+		sels = append(sels, s.textRange)
+	}
+	if expected := []textRange{{1, 3}, {8, 9}, {11, 13}}; !reflect.DeepEqual(sels, expected) {
+		t.Fatalf("expected the selections %v but got %v", expected, sels)
+	}
+	if !reflect.DeepEqual(body.CursorIndices, []int{1, 8, 11}) {
+		t.Fatalf("expected the cursors at the left of the box but got %v", body.CursorIndices)
+	}
+}
+
+func TestSwapSelections(t *testing.T) {
+	win := newZeroxTestWindow(t, "f(alpha, b) // x")
+	body := &win.Body.editable
+
+	if err := body.SwapSelections(); err == nil {
+		t.Fatalf("expected swapping without two selections to fail")
+	}
+
+	// The cursors are on the space after the comma and on the x.
+	body.CursorIndices = []int{8, 15}
+	body.addSecondarySelection(9, 10, Right)
+	body.addSecondarySelection(2, 7, Right)
+
+	if err := body.SwapSelections(); err != nil {
+		t.Fatalf("swapping failed: %v", err)
+	}
+	if s := body.String(); s != "f(b, alpha) // x" {
+		t.Fatalf("expected the selections to be swapped but the text is %q", s)
+	}
+
+	var texts []string
+	for _, sel := range body.selections {
+		texts = append(texts, body.textOfSelection(sel))
+	}
+	sort.Strings(texts)
+	if !reflect.DeepEqual(texts, []string{"alpha", "b"}) {
+		t.Fatalf("expected the selections to be kept around the swapped text but they hold %q", texts)
+	}
+	if !reflect.DeepEqual(body.CursorIndices, []int{4, 15}) {
+		t.Fatalf("expected the cursors to stay on the same text but they are at %v", body.CursorIndices)
+	}
+
+	body.applyUndoOrRedo(body.text.Undo, -1)
+	if s := body.String(); s != "f(alpha, b) // x" {
+		t.Fatalf("expected the swap to be undone as one change but the text is %q", s)
+	}
+}
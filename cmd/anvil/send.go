@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/jeffwilliams/anvil/internal/runes"
+)
+
+// jobStdin is the stdin of a job started with < in a window. The stdin is kept open while the job
+// runs so that text can be written to it using Send.
+type jobStdin struct {
+	jobName string
+	lock    sync.Mutex
+	pipe    io.WriteCloser
+	closed  bool
+}
+
+func newJobStdin(jobName string) *jobStdin {
+	return &jobStdin{jobName: jobName}
+}
+
+// setPipe is called with the pipe that writes to the stdin of the job once the job is started.
+func (s *jobStdin) setPipe(w io.WriteCloser) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		w.Close()
+		return
+	}
+	s.pipe = w
+}
+
+func (s *jobStdin) write(b []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return fmt.Errorf("the job %s has finished", s.jobName)
+	}
+	if s.pipe == nil {
+		return fmt.Errorf("the job %s has not started yet", s.jobName)
+	}
+	_, err := s.pipe.Write(b)
+	return err
+}
+
+// Close closes the stdin of the job. It is called when the job is killed or finishes.
+func (s *jobStdin) Close() {
+	if s == nil {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	if s.pipe != nil {
+		s.pipe.Close()
+		s.pipe = nil
+	}
+}
+
+func (s *jobStdin) isClosed() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.closed
+}
+
+// attachJobStdin makes Send in the window write to the stdin of the job. Only the job started most
+// recently is attached; a job started earlier that is still running is detached from the window.
+func (w *Window) attachJobStdin(s *jobStdin) {
+	if w.sendStdin != nil && !w.sendStdin.isClosed() {
+		editor.AppendError("", fmt.Sprintf("Send: %s no longer receives Send from window %d since %s was started in it", w.sendStdin.jobName, w.Id, s.jobName))
+	}
+	w.sendStdin = s
+}
+
+func (c CommandExecutor) CmdSend(ctx *CmdContext) {
+	win, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Send: must be executed in a window")
+		return
+	}
+
+	if win.sendStdin == nil || win.sendStdin.isClosed() {
+		// Tools like awin register their own Send command using the API.
+		if c.tryApiUserDefinedCommand(ctx, "Send") {
+			return
+		}
+		editor.AppendError(ctx.Dir, "Send: no job started with < is running in the window")
+		return
+	}
+
+	text := strings.Join(ctx.Args, " ")
+	if len(ctx.Args) == 0 {
+		text = textToSend(ctx.Editable)
+	}
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+
+	err := win.sendStdin.write([]byte(text))
+	if err != nil {
+		editor.AppendError(ctx.Dir, fmt.Sprintf("Send: %v", err))
+	}
+}
+
+// textToSend returns the text of the selections in e, each ending in a newline, or the line
+// containing the first cursor if there are no selections.
+func textToSend(e *editable) string {
+	if e.SelectionsPresent() {
+		var buf strings.Builder
+		for _, sel := range e.selectionsInDisplayOrder() {
+			t := e.textOfSelection(sel)
+			buf.WriteString(t)
+			if !strings.HasSuffix(t, "\n") {
+				buf.WriteRune('\n')
+			}
+		}
+		return buf.String()
+	}
+
+	w := runes.NewWalker(e.Bytes())
+	w.SetRunePosCache(e.firstCursorIndex(), &e.runeOffsetCache)
+	start, end := w.CurrentLineBounds()
+	return string(w.TextBetweenRuneIndicesCache(start, end, &e.runeOffsetCache))
+}
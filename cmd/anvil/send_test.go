@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendWritesToStdinOfJob(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag(filepath.Join(dir, "out.txt"), typeFile)
+		win.Body.SetText([]byte("hello\nworld\n"))
+		win.Body.SetCursorIndices([]int{6})
+	})
+
+	ctx := func() *CmdContext {
+		return &CmdContext{Dir: dir, Editable: &win.Body.editable}
+	}
+
+	onMainGoroutine(func() {
+		NewCommandExecutor(win).CmdExecLt("cat", ctx())
+	})
+
+	body := func() (s string) {
+		onMainGoroutine(func() {
+			s = win.Body.String()
+		})
+		return
+	}
+
+	waitForBody := func(substr string) bool {
+		for i := 0; i < 50; i++ {
+			if strings.Contains(body(), substr) {
+				return true
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		return false
+	}
+
+	// The job may not have started yet when Send is first executed.
+	for i := 0; i < 50; i++ {
+		var started bool
+		onMainGoroutine(func() {
+			started = win.sendStdin != nil && win.sendStdin.write(nil) == nil
+		})
+		if started {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Without a selection the line containing the cursor is sent.
+	onMainGoroutine(func() {
+		NewCommandExecutor(win).CmdSend(ctx())
+	})
+	if !waitForBody("world\nworld\n") {
+		t.Fatalf("expected the current line to be echoed by the job but the body is %q", body())
+	}
+
+	onMainGoroutine(func() {
+		NewCommandExecutor(win).CmdSend(&CmdContext{Dir: dir, Editable: &win.Body.editable, Args: []string{"again"}})
+	})
+	if !waitForBody("again\n") {
+		t.Fatalf("expected the arguments to be echoed by the job but the body is %q", body())
+	}
+
+	var stdin *jobStdin
+	onMainGoroutine(func() {
+		stdin = win.sendStdin
+		editor.KillJob("cat")
+	})
+	for i := 0; i < 50 && !stdin.isClosed(); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := stdin.write([]byte("late\n")); err == nil {
+		t.Fatalf("expected writing after the job was killed to fail")
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// apiScopeSensitive is the scope an API session needs to read the body of a sensitive window.
+// Sessions are given it when the command that created them was run from a sensitive window.
+const apiScopeSensitive = "sensitive"
+
+// redactedTagMarker is shown in the tag of a sensitive window while its body is hidden.
+const redactedTagMarker = "▓"
+
+// matchesSensitivePattern returns true if the base name of path matches one of the
+// sensitive-patterns from the settings.
+func matchesSensitivePattern(path string) bool {
+	base := filepath.Base(path)
+	for _, p := range settings.General.SensitivePatterns {
+		if ok, _ := filepath.Match(p, base); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// updateSensitive makes the window sensitive if its file matches the sensitive-patterns,
+// unless it was explicitly made sensitive or not using the Sensitive command.
+func (w *Window) updateSensitive() {
+	if w.sensitiveSetByUser {
+		return
+	}
+	w.setSensitive(w.fileType == typeFile && matchesSensitivePattern(w.file))
+}
+
+// SetSensitive makes the window sensitive or not, overriding the sensitive-patterns.
+func (w *Window) SetSensitive(b bool) {
+	w.sensitiveSetByUser = true
+	w.setSensitive(b)
+}
+
+func (w *Window) setSensitive(b bool) {
+	if w.sensitive == b {
+		return
+	}
+	w.sensitive = b
+	w.lastInteraction = time.Now()
+	if !b {
+		w.setRedacted(false)
+	}
+}
+
+func (w *Window) IsSensitive() bool {
+	return w.sensitive
+}
+
+// setRedacted hides or shows the text of the window body. It only changes how the body is
+// drawn; the text is unchanged.
+func (w *Window) setRedacted(b bool) {
+	if w.Body.redacted == b {
+		return
+	}
+	w.Body.redacted = b
+	w.SetTag()
+}
+
+// noteInteraction is called when a key is pressed or a button clicked in the window.
+func (w *Window) noteInteraction() {
+	if !w.sensitive {
+		return
+	}
+	w.lastInteraction = time.Now()
+	w.setRedacted(false)
+}
+
+// redactIfIdle hides the body of a sensitive window if there has been no interaction with it
+// for the sensitive-idle-timeout. Otherwise it asks for a frame when the timeout expires, so
+// that this is checked again then even if nothing else is happening.
+func (w *Window) redactIfIdle(gtx layout.Context) {
+	if !w.sensitive || w.Body.redacted || settings.General.SensitiveIdleTimeout <= 0 {
+		return
+	}
+
+	deadline := w.lastInteraction.Add(time.Duration(settings.General.SensitiveIdleTimeout) * time.Second)
+	if !gtx.Now.Before(deadline) {
+		w.setRedacted(true)
+		return
+	}
+	gtx.Execute(op.InvalidateCmd{At: deadline})
+}
+
+// redactSensitiveWindows hides the bodies of all sensitive windows. It is called when the
+// application window loses focus.
+func (e *Editor) redactSensitiveWindows() {
+	for _, w := range e.Windows() {
+		if w.sensitive {
+			w.setRedacted(true)
+		}
+	}
+}
+
+// mayReadBodyOf returns true if the API session sess may read the body of w.
+func (s *ApiSession) mayReadBodyOf(w *Window) bool {
+	return !w.sensitive || s.hasScope(apiScopeSensitive)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+func TestMatchesSensitivePattern(t *testing.T) {
+	old := settings.General.SensitivePatterns
+	t.Cleanup(func() { settings.General.SensitivePatterns = old })
+	settings.General.SensitivePatterns = []string{"*.env", ".env", "*id_rsa*"}
+
+	tests := []struct {
+		path     string
+		expected bool
+	}{
+		{"/home/u/project/prod.env", true},
+		{"/home/u/project/.env", true},
+		{"/home/u/.ssh/id_rsa", true},
+		{"/home/u/.ssh/id_rsa.pub", true},
+		{"/home/u/project/env.go", false},
+		{"/home/u/project.env/main.go", false},
+	}
+
+	for _, tc := range tests {
+		if actual := matchesSensitivePattern(tc.path); actual != tc.expected {
+			t.Errorf("for %s expected %v but got %v", tc.path, tc.expected, actual)
+		}
+	}
+}
+
+func TestSensitiveBodyNotReadableThroughApiWithoutScope(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.SetFilenameAndTag(filepath.Join(t.TempDir(), "a.txt"), typeFile)
+		win.Body.SetText([]byte("password=hunter2\n"))
+	})
+
+	_, err := anvil.WindowBody(api.Window{Id: win.Id})
+	if err != nil {
+		t.Fatalf("reading the body of a window that isn't sensitive failed: %v", err)
+	}
+
+	onMainGoroutine(func() {
+		win.SetSensitive(true)
+	})
+
+	_, err = anvil.WindowBody(api.Window{Id: win.Id})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("expected reading the body of a sensitive window to be forbidden but got %v", err)
+	}
+
+	sess := &ApiSession{scopes: []string{apiScopeSensitive}}
+	if !sess.mayReadBodyOf(win) {
+		t.Fatalf("expected a session with the sensitive scope to be able to read the body")
+	}
+}
+
+func TestSensitiveBodyNotSavedInState(t *testing.T) {
+	newTestEditor()
+
+	win := editor.NewWindow(nil)
+	win.SetFilenameAndTag("+Scratch", typeUnknown)
+	win.Body.SetText([]byte("token=abc\n"))
+	win.SetSensitive(true)
+
+	state := win.State()
+	if strings.Contains(state.Body.Text, "token") {
+		t.Fatalf("expected the body of a sensitive window not to be saved but got %q", state.Body.Text)
+	}
+	if !state.Sensitive || !state.SensitiveSetByUser {
+		t.Fatalf("expected the window to be saved as sensitive")
+	}
+
+	if !win.IsSensitive() || win.Body.redacted {
+		t.Fatalf("expected the body not to be redacted before the window is idle")
+	}
+	editor.redactSensitiveWindows()
+	if !win.Body.redacted || !strings.Contains(win.Tag.String(), redactedTagMarker) {
+		t.Fatalf("expected the body to be redacted and the tag to show it")
+	}
+	if win.Body.String() != "token=abc\n" {
+		t.Fatalf("expected redacting not to change the body but it is %q", win.Body.String())
+	}
+	win.noteInteraction()
+	if win.Body.redacted {
+		t.Fatalf("expected an interaction to show the body again")
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+)
+
+// slotPreviewLen is the most runes of a slot shown by the Slots command.
+const slotPreviewLen = 40
+
+// ClipboardSlots holds the texts of the selections that were cut or copied together, one slot per
+// selection in the order the selections appear in the text. The slots are kept apart from the
+// clipboard and are only replaced when more than one selection is cut or copied, so copying a
+// single piece of text in between doesn't lose them. Text cut or copied from the body of a
+// sensitive window is not put in the slots.
+type ClipboardSlots struct {
+	texts []string
+	// from is the window the texts were cut or copied from, if any. The texts aren't shown by
+	// Slots if it became sensitive since.
+	from *Window
+}
+
+type slotPasteMode int
+
+const (
+	// pasteSlotwise pastes the first slot at the first cursor, the second at the second, and so on.
+	pasteSlotwise slotPasteMode = iota
+	// pasteSlotsRotated is like pasteSlotwise but starts with the second slot, so the last slot is
+	// pasted at the first cursor. Pasting over two selections copied together swaps them.
+	pasteSlotsRotated
+	// pasteOneSlot pastes the same slot at all cursors.
+	pasteOneSlot
+)
+
+// Set replaces the slots with texts, if there is more than one text.
+func (s *ClipboardSlots) Set(texts []string) {
+	s.setFrom(texts, nil)
+}
+
+func (s *ClipboardSlots) setFrom(texts []string, w *Window) {
+	if len(texts) < 2 {
+		return
+	}
+	s.texts, s.from = texts, w
+}
+
+// setSlots puts the texts of the selections cut or copied from e in the clipboard slots, unless
+// e is the body of a sensitive window.
+func (e *editable) setSlots(texts []string) {
+	w := editor.windowOfEditable(e)
+	if w != nil && w.IsSensitive() && e == &w.Body.editable {
+		return
+	}
+	editor.Slots.setFrom(texts, w)
+}
+
+func (s *ClipboardSlots) Texts() []string {
+	return s.texts
+}
+
+func (s *ClipboardSlots) Len() int {
+	return len(s.texts)
+}
+
+// forCursors returns the texts to paste at n cursors, starting with the slot at index first. When
+// there are more cursors than slots the slots are cycled; when there are fewer the extra slots
+// aren't pasted.
+func (s *ClipboardSlots) forCursors(n, first int) []string {
+	texts := make([]string, n)
+	for i := range texts {
+		texts[i] = s.texts[(first+i)%len(s.texts)]
+	}
+	return texts
+}
+
+// textsToPaste returns the texts to paste at n cursors for the mode. slot is the number of the
+// slot to paste, starting at 1, for pasteOneSlot.
+func (s *ClipboardSlots) textsToPaste(mode slotPasteMode, n, slot int) ([]string, error) {
+	if len(s.texts) == 0 {
+		return nil, fmt.Errorf("there are no clipboard slots. Cut or copy more than one selection to fill them")
+	}
+
+	switch mode {
+	case pasteSlotsRotated:
+		return s.forCursors(n, 1), nil
+	case pasteOneSlot:
+		if slot < 1 || slot > len(s.texts) {
+			return nil, fmt.Errorf("there is no slot %d. There are %d slots", slot, len(s.texts))
+		}
+		texts := make([]string, n)
+		for i := range texts {
+			texts[i] = s.texts[slot-1]
+		}
+		return texts, nil
+	default:
+		return s.forCursors(n, 0), nil
+	}
+}
+
+// String lists the slots with a preview of the text in each. The previews are hidden if the
+// window the texts were cut or copied from became sensitive.
+func (s *ClipboardSlots) String() string {
+	if len(s.texts) == 0 {
+		return "There are no clipboard slots. Cut or copy more than one selection to fill them.\n"
+	}
+
+	hidden := s.from != nil && s.from.IsSensitive()
+	var buf strings.Builder
+	for i, t := range s.texts {
+		preview := t
+		if utf8.RuneCountInString(preview) > slotPreviewLen {
+			preview = string([]rune(preview)[:slotPreviewLen]) + "…"
+		}
+		preview = strconv.Quote(preview)
+		if hidden {
+			preview = strings.Repeat(redactedTagMarker, 3)
+		}
+		fmt.Fprintf(&buf, "%d\t%s\t(%d characters)\n", i+1, preview, utf8.RuneCountInString(t))
+	}
+	return buf.String()
+}
+
+// PasteSlots pastes the clipboard slots according to mode. If there are selections, each
+// selection is replaced by one slot; otherwise a slot is inserted at each cursor. The pasted
+// texts are selected. Like a plain paste, nothing is done if the modificationGuard refuses to
+// change the text.
+func (e *editable) PasteSlots(mode slotPasteMode, slot int) error {
+	if !e.modificationAllowed() {
+		return nil
+	}
+
+	ranges := e.slotPasteRanges()
+	texts, err := editor.Slots.textsToPaste(mode, len(ranges), slot)
+	if err != nil {
+		return err
+	}
+
+	e.replaceRangesWithTexts(ranges, texts)
+	return nil
+}
+
+// slotPasteRanges returns the ranges that PasteSlots replaces: the selections if there are any,
+// otherwise an empty range at each cursor. They are in the order they appear in the text.
+func (e *editable) slotPasteRanges() (ranges []textRange) {
+	if e.SelectionsPresent() {
+		for _, s := range e.selectionsInDisplayOrder() {
+			ranges = append(ranges, s.textRange)
+		}
+		return
+	}
+
+	cursors := make([]int, len(e.CursorIndices))
+	copy(cursors, e.CursorIndices)
+	sort.Ints(cursors)
+	for i, c := range cursors {
+		if i > 0 && c == cursors[i-1] {
+			continue
+		}
+		ranges = append(ranges, textRange{c, c})
+	}
+	return
+}
+
+// replaceRangesWithTexts replaces each range with the text at the same index in texts as one
+// change to undo, and selects the inserted texts. The ranges must be in order and not overlap.
+func (e *editable) replaceRangesWithTexts(ranges []textRange, texts []string) {
+	e.clearSelections()
+
+	var inserted []textRange
+	delta := 0
+	e.StartTransaction()
+	for i, r := range ranges {
+		start := r.start + delta
+		if r.Len() > 0 {
+			e.deleteFromPieceTable(start, r.Len())
+		}
+		e.insertToPieceTable(start, texts[i])
+		l := utf8.RuneCountInString(texts[i])
+		inserted = append(inserted, textRange{start, start + l})
+		delta += l - r.Len()
+	}
+	e.EndTransaction()
+
+	cursors := make([]int, len(inserted))
+	for i, r := range inserted {
+		cursors[i] = r.end
+	}
+	e.SetCursorIndices(cursors)
+
+	for _, r := range inserted {
+		if r.Len() > 0 {
+			e.addSecondarySelection(r.start, r.end, Right)
+		}
+	}
+}
+
+func (e *editable) pasteSlotsFromKey(gtx layout.Context, mode slotPasteMode) {
+	err := e.PasteSlots(mode, 0)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Paste: %v", err))
+		return
+	}
+	e.makeCursorVisibleByScrolling(gtx)
+}
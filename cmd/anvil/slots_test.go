@@ -0,0 +1,161 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func newSlotsTestWindow(text string) *Window {
+	newTestEditor()
+
+	win := editor.NewWindow(nil)
+	win.Body.SetText([]byte(text))
+	return win
+}
+
+func TestClipboardSlotsSurviveSingleCopies(t *testing.T) {
+	var s ClipboardSlots
+	s.Set([]string{"a", "b"})
+	s.Set([]string{"single"})
+	if strings.Join(s.Texts(), ",") != "a,b" {
+		t.Fatalf("expected a single text not to replace the slots but they are %v", s.Texts())
+	}
+	s.Set([]string{"c", "d", "e"})
+	if strings.Join(s.Texts(), ",") != "c,d,e" {
+		t.Fatalf("expected the slots to be replaced but they are %v", s.Texts())
+	}
+}
+
+func TestClipboardSlotsTextsToPaste(t *testing.T) {
+	var s ClipboardSlots
+	if _, err := s.textsToPaste(pasteSlotwise, 2, 0); err == nil {
+		t.Fatalf("expected pasting with no slots to fail")
+	}
+
+	s.Set([]string{"a", "b"})
+
+	tests := []struct {
+		mode     slotPasteMode
+		cursors  int
+		slot     int
+		expected string
+	}{
+		{pasteSlotwise, 2, 0, "a,b"},
+		{pasteSlotwise, 3, 0, "a,b,a"},
+		{pasteSlotwise, 1, 0, "a"},
+		{pasteSlotsRotated, 2, 0, "b,a"},
+		{pasteSlotsRotated, 3, 0, "b,a,b"},
+		{pasteOneSlot, 3, 2, "b,b,b"},
+	}
+
+	for _, tc := range tests {
+		texts, err := s.textsToPaste(tc.mode, tc.cursors, tc.slot)
+		if err != nil {
+			t.Fatalf("mode %d with %d cursors failed: %v", tc.mode, tc.cursors, err)
+		}
+		if got := strings.Join(texts, ","); got != tc.expected {
+			t.Errorf("mode %d with %d cursors: expected %s but got %s", tc.mode, tc.cursors, tc.expected, got)
+		}
+	}
+
+	if _, err := s.textsToPaste(pasteOneSlot, 1, 3); err == nil {
+		t.Fatalf("expected pasting a slot that doesn't exist to fail")
+	}
+}
+
+func TestPasteSlotsRotatedSwapsSelections(t *testing.T) {
+	win := newSlotsTestWindow("one two three")
+	body := &win.Body
+
+	body.addSecondarySelection(0, 3, Right)
+	body.addSecondarySelection(8, 13, Right)
+	editor.Slots.Set([]string{body.textOfSelection(body.selections[0]), body.textOfSelection(body.selections[1])})
+
+	err := body.PasteSlots(pasteSlotsRotated, 0)
+	if err != nil {
+		t.Fatalf("pasting failed: %v", err)
+	}
+	if body.String() != "three two one" {
+		t.Fatalf("expected the selections to be swapped but the text is %q", body.String())
+	}
+	if len(body.selections) != 2 || body.textOfSelection(body.selections[0]) != "three" {
+		t.Fatalf("expected the pasted texts to be selected")
+	}
+
+	body.applyUndoOrRedo(body.text.Undo, -1)
+	if body.String() != "one two three" {
+		t.Fatalf("expected the paste to be undone as one change but the text is %q", body.String())
+	}
+}
+
+func TestPasteSlotsAtCursors(t *testing.T) {
+	win := newSlotsTestWindow("x\ny\nz\n")
+	body := &win.Body
+	editor.Slots.Set([]string{"1", "2"})
+
+	body.SetCursorIndices([]int{4, 0, 2})
+	err := body.PasteSlots(pasteSlotwise, 0)
+	if err != nil {
+		t.Fatalf("pasting failed: %v", err)
+	}
+	if body.String() != "1x\n2y\n1z\n" {
+		t.Fatalf("expected the slots to be pasted in order and cycled but the text is %q", body.String())
+	}
+
+	body.clearSelections()
+	body.SetCursorIndices([]int{0, 3})
+	err = body.PasteSlots(pasteOneSlot, 2)
+	if err != nil {
+		t.Fatalf("pasting failed: %v", err)
+	}
+	if body.String() != "21x\n22y\n1z\n" {
+		t.Fatalf("expected slot 2 to be pasted at each cursor but the text is %q", body.String())
+	}
+}
+
+func TestSlotsListing(t *testing.T) {
+	var s ClipboardSlots
+	s.Set([]string{"short", strings.Repeat("long ", 20) + "\n"})
+
+	l := s.String()
+	if !strings.Contains(l, "1\t\"short\"\t(5 characters)") {
+		t.Fatalf("unexpected listing of the first slot: %q", l)
+	}
+	if !strings.Contains(l, "…\"\t(101 characters)") {
+		t.Fatalf("expected the second slot to be truncated: %q", l)
+	}
+}
+
+func TestSlotsKeepSensitiveTextPrivate(t *testing.T) {
+	win := newSlotsTestWindow("secret\n")
+	other := editor.NewWindow(nil)
+
+	win.SetSensitive(true)
+	win.Body.setSlots([]string{"sec", "ret"})
+	if editor.Slots.Len() != 0 {
+		t.Fatalf("expected text from a sensitive window not to be put in the slots but they are %v", editor.Slots.Texts())
+	}
+
+	other.Body.setSlots([]string{"pass", "word"})
+	other.SetSensitive(true)
+	if l := editor.Slots.String(); strings.Contains(l, "pass") || strings.Contains(l, "word") {
+		t.Fatalf("expected the previews of text from a window that became sensitive to be hidden: %q", l)
+	}
+}
+
+func TestPasteSlotsIsRefusedByAReadOnlyPresenter(t *testing.T) {
+	win := newSlotsTestWindow("x\n")
+	editor.Slots.Set([]string{"1", "2"})
+	if err := win.Body.SetPresenter("hex"); err != nil {
+		t.Fatalf("setting the presenter failed: %v", err)
+	}
+
+	body := &win.Body.editable
+	body.CursorIndices = []int{0}
+	if err := body.PasteSlots(pasteSlotwise, 0); err != nil {
+		t.Fatalf("PasteSlots failed: %v", err)
+	}
+	if body.String() != "x\n" {
+		t.Fatalf("expected the body shown by the hex presenter not to change but it is %q", body.String())
+	}
+}
@@ -0,0 +1,536 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+)
+
+// spillViewportSize is the approximate number of bytes of a spill file shown in the window
+// body at once.
+const spillViewportSize = 256 * 1024
+
+// spillRefreshDelay is how often the window body is refreshed while it follows the end
+// of a spill file that is growing.
+const spillRefreshDelay = 250 * time.Millisecond
+
+// spill holds the output of a job that was too large to keep in a window body. The full output
+// is written to a temporary file, and the window body shows a viewport of part of it which is
+// read from the file on demand.
+type spill struct {
+	lock   sync.Mutex
+	file   *os.File
+	path   string
+	size   int64
+	closed bool
+
+	// viewStart and viewEnd are the byte offsets in the file of the text shown in the window body.
+	viewStart, viewEnd int64
+	// following is true when the viewport should move to the end of the file as it grows.
+	following bool
+	// region is the range of runes in the window body that shows the viewport and the footer.
+	// It is only used by the editor goroutine.
+	region textRange
+}
+
+func newSpill() (*spill, error) {
+	f, err := os.CreateTemp("", "anvil-spill-*")
+	if err != nil {
+		return nil, err
+	}
+
+	return &spill{file: f, path: f.Name(), following: true}, nil
+}
+
+// Write appends b to the end of the spill file. It is safe to call concurrently with
+// the read methods.
+func (s *spill) Write(b []byte) (n int, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+
+	n, err = s.file.WriteAt(b, s.size)
+	s.size += int64(n)
+	return
+}
+
+func (s *spill) Size() int64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.size
+}
+
+// ReadRange reads the bytes in the range [start,end) from the spill file.
+func (s *spill) ReadRange(start, end int64) ([]byte, error) {
+	size := s.Size()
+	if start < 0 {
+		start = 0
+	}
+	if end > size {
+		end = size
+	}
+	if start >= end {
+		return []byte{}, nil
+	}
+
+	b := make([]byte, end-start)
+	n, err := s.file.ReadAt(b, start)
+	if err == io.EOF {
+		err = nil
+	}
+	return b[:n], err
+}
+
+// Close closes and removes the spill file.
+func (s *spill) Close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.file.Close()
+	os.Remove(s.path)
+}
+
+// viewportAt reads roughly one viewport worth of text beginning near byte offset start,
+// adjusted so that it begins and ends on line boundaries.
+func (s *spill) viewportAt(start int64) (text []byte, vstart, vend int64, err error) {
+	size := s.Size()
+	if start > size-spillViewportSize {
+		start = size - spillViewportSize
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	end := start + spillViewportSize
+	b, err := s.ReadRange(start, end)
+	if err != nil {
+		return
+	}
+
+	lo, hi := alignToLines(b, start == 0, start+int64(len(b)) >= size)
+	return b[lo:hi], start + int64(lo), start + int64(hi), nil
+}
+
+// alignToLines returns the bounds of the part of b that begins at the start of a line and
+// ends at the end of a line. If atStart is true b is known to begin at the start of a line,
+// and if atEnd is true b is known to end at the end of a line (or of the file). If b doesn't
+// contain a full line it is returned unchanged.
+func alignToLines(b []byte, atStart, atEnd bool) (lo, hi int) {
+	lo, hi = 0, len(b)
+	if !atStart {
+		if i := bytes.IndexByte(b, '\n'); i >= 0 && i+1 < len(b) {
+			lo = i + 1
+		}
+	}
+	if !atEnd {
+		if i := bytes.LastIndexByte(b[lo:], '\n'); i >= 0 {
+			hi = lo + i + 1
+		}
+	}
+	return
+}
+
+// Search searches forward from byte offset from for a line matching re. If a match
+// is found, the byte offsets of the match within the spill file are returned.
+func (s *spill) Search(re *regexp.Regexp, from int64) (start, end int64, found bool, err error) {
+	size := s.Size()
+	if from < 0 {
+		from = 0
+	}
+
+	r := bufio.NewReaderSize(io.NewSectionReader(s.file, from, size-from), 64*1024)
+	off := from
+	for {
+		var line []byte
+		line, err = r.ReadBytes('\n')
+		if len(line) > 0 {
+			if m := re.FindIndex(line); m != nil {
+				return off + int64(m[0]), off + int64(m[1]), true, nil
+			}
+			off += int64(len(line))
+		}
+		if err == io.EOF {
+			return 0, 0, false, nil
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *spill) footer() string {
+	return fmt.Sprintf("◊ Output spilled to %s (%s). Showing bytes %d-%d ◊\n", s.path, humanizeSize(s.Size()), s.viewStart, s.viewEnd)
+}
+
+func humanizeSize(n int64) string {
+	switch {
+	case n >= 1024*1024*1024:
+		return fmt.Sprintf("%.1f GB", float64(n)/(1024*1024*1024))
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.1f KB", float64(n)/1024)
+	}
+	return fmt.Sprintf("%d B", n)
+}
+
+// jobOutput is the text in a window body written by a job whose output may be spilled, so
+// that it can be replaced by a viewport of the spill file if it is. The output of other jobs
+// may be interleaved with it, so it is a list of ranges.
+type jobOutput struct {
+	ranges []textRange
+	// win is the window the text is in, once some was written.
+	win *Window
+}
+
+// noteJobOutput records that the runes from start to end of the body were written by the job
+// whose output is out.
+func (w *Window) noteJobOutput(out *jobOutput, start, end int) {
+	w.updateSpillRegions()
+	if start == end {
+		return
+	}
+
+	found := false
+	for _, o := range w.jobOutputs {
+		if o == out {
+			found = true
+			break
+		}
+	}
+	if !found {
+		w.jobOutputs = append(w.jobOutputs, out)
+		out.win = w
+	}
+
+	if l := len(out.ranges); l > 0 && out.ranges[l-1].end == start {
+		out.ranges[l-1].end = end
+		return
+	}
+	out.ranges = append(out.ranges, textRange{start, end})
+}
+
+// forgetJobOutput stops keeping track of the text written by the job whose output is out, such
+// as when the job is done.
+func (w *Window) forgetJobOutput(out *jobOutput) {
+	for i, o := range w.jobOutputs {
+		if o == out {
+			w.jobOutputs = append(w.jobOutputs[:i], w.jobOutputs[i+1:]...)
+			return
+		}
+	}
+}
+
+// spillRegionsTextChanged keeps the regions of the body that show the spills, and the text
+// written by jobs whose output may be spilled, in step with changes to the body. A change that
+// may have replaced all the text leaves them out of date, and they are reset when next used.
+func (w *Window) spillRegionsTextChanged(ch *TextChange) {
+	if w.spillRegionsGen != w.Body.generation-1 || ch.IsZero() {
+		return
+	}
+	w.spillRegionsGen = w.Body.generation
+
+	for _, s := range w.spills {
+		shiftTextRange(&s.region, ch.Offset, ch.Length)
+	}
+	for _, o := range w.jobOutputs {
+		for i := range o.ranges {
+			shiftTextRange(&o.ranges[i], ch.Offset, ch.Length)
+		}
+	}
+}
+
+// appendedToSpillRegions is called after text was appended to the body, which leaves the
+// regions where they were.
+func (w *Window) appendedToSpillRegions(generationBeforeAppend int) {
+	if w.spillRegionsGen == generationBeforeAppend {
+		w.spillRegionsGen = w.Body.generation
+	}
+}
+
+// updateSpillRegions resets the regions of the spills and the text written by jobs if they are
+// out of date. The text of the jobs is then unknown, and the spills are shown at the end of the
+// body when they are next shown.
+func (w *Window) updateSpillRegions() {
+	if w.spillRegionsGen == w.Body.generation {
+		return
+	}
+	w.spillRegionsGen = w.Body.generation
+
+	l := w.Body.text.Len()
+	for _, s := range w.spills {
+		s.region = textRange{l, l}
+	}
+	for _, o := range w.jobOutputs {
+		o.ranges = nil
+	}
+}
+
+// shiftTextRange moves r to account for an insert (length > 0) or delete (length < 0) of text at
+// offset. Text inserted at the start of r is before it, and text inserted at the end is after it.
+func shiftTextRange(r *textRange, offset, length int) {
+	if length > 0 {
+		if r.start >= offset {
+			r.start += length
+		}
+		if r.end > offset || r.end < r.start {
+			r.end += length
+		}
+		return
+	}
+
+	changeEnd := offset - length
+	move := func(p int) int {
+		switch {
+		case p <= offset:
+			return p
+		case p < changeEnd:
+			return offset
+		}
+		return p + length
+	}
+	r.start, r.end = move(r.start), move(r.end)
+}
+
+// startSpill makes the window body show a viewport of the spill file s in place of the text
+// written by the job whose output is out, leaving the rest of the body as it is.
+func (w *Window) startSpill(s *spill, out *jobOutput) {
+	w.updateSpillRegions()
+	pos := w.Body.text.Len()
+	if out != nil {
+		w.forgetJobOutput(out)
+		// Deleting from the last range back leaves the earlier ranges where they are.
+		for i := len(out.ranges) - 1; i >= 0; i-- {
+			r := out.ranges[i]
+			if r.end > r.start {
+				w.Body.deleteFromPieceTable(r.start, r.end-r.start)
+			}
+			pos = r.start
+		}
+	}
+
+	s.region = textRange{pos, pos}
+	w.spills = append(w.spills, s)
+	w.SetTag()
+	w.showSpillTail(s)
+	if w.tailingSuspended {
+		s.following = false
+	}
+}
+
+// hasSpill returns true if s is one of the spills shown in the window.
+func (w *Window) hasSpill(s *spill) bool {
+	for _, x := range w.spills {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// currentSpill returns the spill whose region of the body holds the primary selection or the
+// cursor, or the last spill started if none does.
+func (w *Window) currentSpill() *spill {
+	w.updateSpillRegions()
+	ndx := w.Body.firstCursorIndex()
+	if w.Body.primarySel != nil {
+		ndx = w.Body.primarySel.start
+	}
+	for _, s := range w.spills {
+		if ndx >= s.region.start && ndx <= s.region.end {
+			return s
+		}
+	}
+	return w.spills[len(w.spills)-1]
+}
+
+// closeSpills stops showing viewports of spill files in the body, and removes the files.
+func (w *Window) closeSpills() {
+	if len(w.spills) == 0 {
+		return
+	}
+	for _, s := range w.spills {
+		s.Close()
+	}
+	w.spills = nil
+	w.SetTag()
+}
+
+func (w *Window) spillGrew(s *spill) {
+	if !s.following {
+		return
+	}
+
+	w.Body.scheduleWithPriority("spill-refresh", spillRefreshDelay, workPriorityLow, w.refreshFollowingSpills)
+}
+
+// refreshFollowingSpills shows the end of the spills that follow the end of their file.
+func (w *Window) refreshFollowingSpills() {
+	for _, s := range w.spills {
+		if s.following {
+			w.showSpillTail(s)
+		}
+	}
+}
+
+func (w *Window) showSpillTail(s *spill) {
+	s.following = true
+	w.showSpillViewport(s, s.Size())
+	w.Body.AddOpForNextLayout(func(gtx layout.Context) {
+		if s.region.end >= w.Body.text.Len() {
+			w.Body.moveToEndOfDoc(gtx)
+			return
+		}
+		w.Body.setToOneCursorIndex(s.region.end)
+		w.Body.makeCursorVisibleByScrolling(gtx)
+	})
+}
+
+// showSpillViewport shows the viewport of the spill file s that begins near byte offset start
+// in the region of the body for s.
+func (w *Window) showSpillViewport(s *spill, start int64) error {
+	text, vstart, vend, err := s.viewportAt(start)
+	if err != nil {
+		return err
+	}
+	s.viewStart, s.viewEnd = vstart, vend
+
+	var buf bytes.Buffer
+	buf.Write(text)
+	if len(text) > 0 && text[len(text)-1] != '\n' {
+		buf.WriteRune('\n')
+	}
+	buf.WriteString(s.footer())
+
+	w.replaceSpillRegion(s, buf.String())
+	return nil
+}
+
+// replaceSpillRegion replaces the text in the region of the body for s with text.
+func (w *Window) replaceSpillRegion(s *spill, text string) {
+	w.updateSpillRegions()
+	start := s.region.start
+	if n := s.region.end - start; n > 0 {
+		w.Body.deleteFromPieceTable(start, n)
+	}
+	w.Body.insertToPieceTable(start, text)
+	s.region = textRange{start, start + utf8.RuneCountInString(text)}
+}
+
+// spillRegionBytes returns the text in the region of the body for s.
+func (w *Window) spillRegionBytes(s *spill) []byte {
+	w.updateSpillRegions()
+	b := w.Body.Bytes()
+	lo := byteOffsetOfRune(b, s.region.start)
+	return b[lo : lo+byteOffsetOfRune(b[lo:], s.region.end-s.region.start)]
+}
+
+func (w *Window) spillPage(s *spill, d verticalDirection) error {
+	s.following = false
+	if d == Up {
+		return w.showSpillViewport(s, s.viewStart-spillViewportSize)
+	}
+
+	if s.viewEnd >= s.Size() {
+		w.showSpillTail(s)
+		return nil
+	}
+	return w.showSpillViewport(s, s.viewEnd)
+}
+
+// spillOffsetOfRuneIndex returns the byte offset in the spill file s of the text at rune index
+// ndx in the window body.
+func (w *Window) spillOffsetOfRuneIndex(s *spill, ndx int) int64 {
+	b := w.spillRegionBytes(s)
+	ndx -= s.region.start
+	if ndx < 0 {
+		ndx = 0
+	}
+	off := s.viewStart + int64(byteOffsetOfRune(b, ndx))
+	if off > s.viewEnd {
+		off = s.viewEnd
+	}
+	return off
+}
+
+// searchSpill searches the spill file s for re beginning after the primary selection or the
+// cursor, and shows the viewport that contains the match with the match selected.
+func (w *Window) searchSpill(s *spill, re *regexp.Regexp) {
+	from := w.spillOffsetOfRuneIndex(s, w.Body.firstCursorIndex())
+	if w.Body.primarySel != nil {
+		from = w.spillOffsetOfRuneIndex(s, w.Body.primarySel.end)
+	}
+
+	go func() {
+		start, end, found, err := s.Search(re, from)
+		editor.WorkChan() <- basicWork{func() {
+			if !w.hasSpill(s) {
+				return
+			}
+
+			if err != nil {
+				editor.AppendError("", fmt.Sprintf("Searching spilled output failed: %v", err))
+				return
+			}
+			if !found {
+				editor.AppendError("", fmt.Sprintf("No match for %s in spilled output", re))
+				return
+			}
+			w.showSpillMatch(s, start, end)
+		}}
+	}()
+}
+
+func (w *Window) showSpillMatch(s *spill, start, end int64) {
+	s.following = false
+	err := w.showSpillViewport(s, start-spillViewportSize/4)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Reading spilled output failed: %v", err))
+		return
+	}
+
+	b := w.spillRegionBytes(s)
+	rstart := s.region.start + utf8RuneCountOfPrefix(b, int(start-s.viewStart))
+	rend := s.region.start + utf8RuneCountOfPrefix(b, int(end-s.viewStart))
+
+	w.Body.AddOpForNextLayout(func(gtx layout.Context) {
+		w.Body.setToOneCursorIndex(rstart)
+		w.Body.setPrimarySelection(rstart, rend)
+		w.Body.makeCursorVisibleByScrolling(gtx)
+	})
+}
+
+func utf8RuneCountOfPrefix(b []byte, n int) int {
+	if n > len(b) {
+		n = len(b)
+	}
+	if n < 0 {
+		n = 0
+	}
+	return utf8.RuneCount(b[:n])
+}
+
+// spillSelectedRange returns the range of bytes in the spill file s that corresponds to the
+// primary selection in the body. If there is no selection the whole viewport is returned.
+func (w *Window) spillSelectedRange(s *spill) (start, end int64) {
+	if w.Body.primarySel == nil {
+		return s.viewStart, s.viewEnd
+	}
+
+	return w.spillOffsetOfRuneIndex(s, w.Body.primarySel.start), w.spillOffsetOfRuneIndex(s, w.Body.primarySel.end)
+}
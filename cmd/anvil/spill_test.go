@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestAlignToLines(t *testing.T) {
+	tests := []struct {
+		name           string
+		input          string
+		atStart, atEnd bool
+		expected       string
+	}{
+		{"whole", "a\nb\n", true, true, "a\nb\n"},
+		{"partial first line", "xa\nb\n", false, true, "b\n"},
+		{"partial last line", "a\nb\nc", true, false, "a\nb\n"},
+		{"partial both", "xa\nb\nc", false, false, "b\n"},
+		{"no newline", "abc", false, false, "abc"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			b := []byte(tc.input)
+			lo, hi := alignToLines(b, tc.atStart, tc.atEnd)
+			if string(b[lo:hi]) != tc.expected {
+				t.Fatalf("expected %q but got %q", tc.expected, b[lo:hi])
+			}
+		})
+	}
+}
+
+func TestSpill(t *testing.T) {
+	s, err := newSpill()
+	if err != nil {
+		t.Fatalf("creating spill failed: %v", err)
+	}
+	defer s.Close()
+
+	var buf bytes.Buffer
+	for i := 0; i < 100000; i++ {
+		fmt.Fprintf(&buf, "line %d\n", i)
+	}
+	// Write in pieces that don't end on line boundaries
+	b := buf.Bytes()
+	for len(b) > 0 {
+		n := 1000
+		if n > len(b) {
+			n = len(b)
+		}
+		s.Write(b[:n])
+		b = b[n:]
+	}
+
+	if s.Size() != int64(buf.Len()) {
+		t.Fatalf("expected size %d but got %d", buf.Len(), s.Size())
+	}
+
+	text, vstart, vend, err := s.viewportAt(12345)
+	if err != nil {
+		t.Fatalf("reading viewport failed: %v", err)
+	}
+	if !bytes.Equal(text, buf.Bytes()[vstart:vend]) {
+		t.Fatalf("viewport text doesn't match the range %d-%d", vstart, vend)
+	}
+	if buf.Bytes()[vstart-1] != '\n' || text[len(text)-1] != '\n' {
+		t.Fatalf("viewport is not aligned to lines")
+	}
+
+	start, end, found, err := s.Search(regexp.MustCompile(`line 9876\d\n`), 0)
+	if err != nil || !found {
+		t.Fatalf("expected to find match but found=%v err=%v", found, err)
+	}
+	if got := string(buf.Bytes()[start:end]); got != "line 98760\n" {
+		t.Fatalf("expected match 'line 98760' but got %q", got)
+	}
+
+	_, _, found, _ = s.Search(regexp.MustCompile(`line 5\n`), start)
+	if found {
+		t.Fatalf("expected no match after offset %d", start)
+	}
+}
+
+func TestSpillsReplaceOnlyTheOutputOfTheirJob(t *testing.T) {
+	newTestHeadless()
+	win := editor.Cols[0].NewWindow()
+	win.SetFilenameAndTag(filepath.Join(t.TempDir(), "+Errors"), typeFile)
+	win.Append([]byte("earlier\n"))
+	defer win.closeSpills()
+
+	type job struct {
+		load *DataLoad
+		work chan Work
+	}
+	start := func(name string) job {
+		load := NewDataLoad()
+		wl := &WindowDataLoad{
+			DataLoad:       *load,
+			Win:            NewWindowHolder(win),
+			Jobname:        name,
+			SpillThreshold: 100,
+		}
+		c := make(chan Work)
+		wl.Start(c)
+		return job{load, c}
+	}
+	// write sends x as the output of j and services the work until it is in the window.
+	write := func(j job, x string) {
+		j.load.Contents <- []byte(x)
+		for w := range j.work {
+			w.Service()
+			switch w.(type) {
+			case *winLoadData, *winSpillStarted, *winSpillGrew:
+				return
+			}
+		}
+	}
+
+	a, b := start("a"), start("b")
+	write(a, "a1\n")
+	write(b, "b1\n")
+	write(a, strings.Repeat("a2\n", 40))
+	if len(win.spills) != 1 {
+		t.Fatalf("expected the output of a to be spilled")
+	}
+	body := win.Body.String()
+	if !strings.HasPrefix(body, "earlier\na1\na2\n") || strings.Count(body, "a1\n") != 1 || !strings.HasSuffix(body, "◊\nb1\n") {
+		t.Fatalf("expected the output of a to be replaced by its spill and the rest kept but the body is %q", body)
+	}
+
+	write(b, strings.Repeat("b2\n", 40))
+	write(a, "a3\n")
+	if len(win.spills) != 2 {
+		t.Fatalf("expected each job to have its own spill but there are %d", len(win.spills))
+	}
+	win.refreshFollowingSpills()
+
+	body = win.Body.String()
+	if !strings.HasPrefix(body, "earlier\n") || strings.Count(body, "◊ Output spilled to") != 2 {
+		t.Fatalf("expected the earlier output and a region for each spill but the body is %q", body)
+	}
+	if strings.Count(body, "a3\n") != 1 || strings.Count(body, "b1\n") != 1 {
+		t.Fatalf("expected the output written after the second spill to be kept but the body is %q", body)
+	}
+	if i, j := strings.Index(body, "a3\n"), strings.Index(body, "b1\n"); i > j {
+		t.Fatalf("expected the region of a to stay before the region of b but the body is %q", body)
+	}
+
+	for _, j := range []job{a, b} {
+		close(j.load.Contents)
+		close(j.load.Errs)
+		for w := range j.work {
+			w.Service()
+			if _, ok := w.(*winLoadDone); ok {
+				break
+			}
+		}
+	}
+	if len(win.jobOutputs) != 0 {
+		t.Fatalf("expected the output of the jobs to be forgotten once they are done")
+	}
+}
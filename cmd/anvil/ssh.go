@@ -2,10 +2,11 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net"
 	"os"
 	"runtime"
+	"sort"
+	"strconv"
 	"sync"
 	"time"
 
@@ -15,8 +16,10 @@ import (
 
 var sshClientCache = NewSshClientCache(settings.Ssh.CacheSize)
 
-// TODO: Support closing the connections after some delay.
-// Also detect a network issue and re-open the connection.
+// SshClientCache holds the open ssh connections by endpoint. Each cached connection is probed
+// with keepalive requests; when one goes unanswered, for example because the machine slept
+// or the network changed, the connection is marked broken and closed, and the next Get for
+// its endpoint connects again.
 type SshClientCache struct {
 	data             map[SshEndpt]SshClientCacheEntry
 	max              int
@@ -38,6 +41,8 @@ func NewSshClientCache(max int) *SshClientCache {
 }
 
 func (cache *SshClientCache) Get(endpt SshEndpt, kill chan struct{}) (client *SshClient, err error) {
+	LoadSshKeys()
+
 	cache.lock.Lock()
 	defer cache.lock.Unlock()
 	defer func() {
@@ -50,15 +55,36 @@ func (cache *SshClientCache) Get(endpt SshEndpt, kill chan struct{}) (client *Ss
 		return
 	}
 
-	if !cache.isValid(e.client.Client()) {
-		// reconnect
-		client, err = cache.add(endpt, kill)
+	if !e.client.Broken() {
+		if perr := e.client.probe(sshProbeTimeout()); perr != nil {
+			e.client.markBroken(perr)
+		}
+	}
+
+	if e.client.Broken() {
+		client, err = cache.reconnect(endpt, e.client, kill)
 		return
 	}
 
+	e.lastUsed = time.Now()
+	cache.data[endpt] = e
 	client = e.client
-	if err != nil {
-		log(LogCatgSsh, "SshClientCache.Get: error: %v\n", err)
+	return
+}
+
+// reconnect replaces the broken connection old to endpt with a new one. If the API was served
+// over the old connection it is served over the new one too.
+func (cache *SshClientCache) reconnect(endpt SshEndpt, old *SshClient, kill chan struct{}) (client *SshClient, err error) {
+	log(LogCatgSsh, "SshClientCache: reconnecting to %s: %v\n", endpt, old.BrokenErr())
+	cache.remove(endpt)
+
+	client, err = cache.add(endpt, kill)
+	if err != nil || old.userData == nil {
+		return
+	}
+
+	if serr := (sshFs{}).maybeServeAPIOverSshClient(client); serr != nil {
+		log(LogCatgSsh, "SshClientCache: serving the API over the new connection to %s failed: %v\n", endpt, serr)
 	}
 	return
 }
@@ -74,12 +100,34 @@ func prefixWithSshEndpt(endpt SshEndpt, msg string, err error) error {
 	}
 }
 
-func (cache *SshClientCache) isValid(client *ssh.Client) bool {
-	// See https://datatracker.ietf.org/doc/html/draft-ssh-global-requests-ok-00 section 4.1 (active keepalive)
-	status, payload, err := client.SendRequest("keep-alive@implementation.example.com", true, []byte("keep-alive"))
-	log(LogCatgSsh, "cache.isValid: %v, %v, %v\n", status, payload, err)
+// sshProbeTimeout is how long to wait for the reply to a keepalive request before the
+// connection is considered broken.
+func sshProbeTimeout() time.Duration {
+	return time.Duration(settings.Ssh.ConnectionTimeout) * time.Second
+}
+
+// keepAlive probes client every keepalive-interval seconds until it is removed from the cache
+// or a probe fails, in which case it is marked broken.
+func (cache *SshClientCache) keepAlive(client *SshClient) {
+	interval := time.Duration(settings.Ssh.KeepaliveInterval) * time.Second
+	if interval <= 0 {
+		return
+	}
 
-	return err == nil
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-client.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if err := client.probe(sshProbeTimeout()); err != nil {
+			client.markBroken(err)
+			return
+		}
+	}
 }
 
 func (cache *SshClientCache) add(endpt SshEndpt, kill chan struct{}) (client *SshClient, err error) {
@@ -92,13 +140,41 @@ func (cache *SshClientCache) add(endpt SshEndpt, kill chan struct{}) (client *Ss
 		return
 	}
 
-	client = &SshClient{client: c, endpt: endpt}
+	client = newSshClient(c, endpt)
+	go cache.keepAlive(client)
 
 	cache.data[endpt] =
 		SshClientCacheEntry{client: client, lastUsed: time.Now()}
 	return
 }
 
+// remove removes the connection to endpt from the cache and stops probing it. The connection
+// itself is left open for the operations that are still using it.
+func (cache *SshClientCache) remove(endpt SshEndpt) {
+	if e, ok := cache.data[endpt]; ok {
+		e.client.stopKeepAlive()
+		delete(cache.data, endpt)
+	}
+}
+
+// Drop closes and removes the cached connections to or through host, or all of them if host is
+// empty, and returns their endpoints ordered by sshEndptLess. Operations using the connections
+// fail, and the next use of an endpoint connects again.
+func (cache *SshClientCache) Drop(host string) (dropped []SshEndpt) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
+
+	for _, k := range cache.sortedKeys() {
+		if host != "" && k.Dest.Host != host && k.Proxy.Host != host {
+			continue
+		}
+		cache.data[k].client.close()
+		cache.remove(k)
+		dropped = append(dropped, k)
+	}
+	return
+}
+
 func (cache *SshClientCache) rmLeastRecentlyUsed() {
 	var minK SshEndpt
 	var minTime time.Time
@@ -110,7 +186,7 @@ func (cache *SshClientCache) rmLeastRecentlyUsed() {
 		}
 	}
 
-	delete(cache.data, minK)
+	cache.remove(minK)
 }
 
 func (cache *SshClientCache) dial(endpt SshEndpt, kill chan struct{}) (client *ssh.Client, err error) {
@@ -156,6 +232,15 @@ func (cache *SshClientCache) dial(endpt SshEndpt, kill chan struct{}) (client *s
 }
 
 func (cache *SshClientCache) completeHop(h SshHop) SshHop {
+	if hs, ok := sshHostSettingsFor(h.Host); ok {
+		if h.User == "" {
+			h.User = hs.User
+		}
+		if h.Port == "" && hs.Port != 0 {
+			h.Port = strconv.Itoa(hs.Port)
+		}
+	}
+
 	if h.User == "" {
 		if runtime.GOOS == "windows" {
 			h.User = os.Getenv("USERNAME")
@@ -262,15 +347,15 @@ func (cache *SshClientCache) SetKeyfilePassword(filename, password string) {
 	cache.invalidateKeyfileAuths()
 }
 
-func (cache *SshClientCache) AddKeyFromFile(filename string, path string) (err error) {
-	key, err := ioutil.ReadFile(path)
-	if err != nil {
-		return
-	}
+// AddKeys adds the keys in the map, which maps key names to the contents of the key files.
+func (cache *SshClientCache) AddKeys(keys map[string][]byte) {
+	cache.lock.Lock()
+	defer cache.lock.Unlock()
 
-	cache.keys[filename] = key
+	for name, key := range keys {
+		cache.keys[name] = key
+	}
 	cache.invalidateKeyfileAuths()
-	return nil
 }
 
 func (cache *SshClientCache) invalidateKeyfileAuths() {
@@ -335,24 +420,33 @@ func (cache *SshClientCache) sshAgentSigners() ([]ssh.Signer, error) {
 	return agent.NewClient(conn).Signers()
 }
 
+// Keys returns the endpoints of the cached connections ordered by sshEndptLess.
 func (cache *SshClientCache) Keys() []SshEndpt {
 	cache.lock.Lock()
 	defer cache.lock.Unlock()
 
+	return cache.sortedKeys()
+}
+
+func (cache *SshClientCache) sortedKeys() []SshEndpt {
 	keys := make([]SshEndpt, 0, len(cache.data))
 	for k := range cache.data {
 		keys = append(keys, k)
 	}
+	sort.Slice(keys, func(i, j int) bool {
+		return sshEndptLess(keys[i], keys[j])
+	})
 	return keys
 }
 
+// Entries returns the cached connections in the same order as Keys.
 func (cache *SshClientCache) Entries() []SshClientCacheEntry {
 	cache.lock.Lock()
 	defer cache.lock.Unlock()
 
 	entries := make([]SshClientCacheEntry, 0, len(cache.data))
-	for _, v := range cache.data {
-		entries = append(entries, v)
+	for _, k := range cache.sortedKeys() {
+		entries = append(entries, cache.data[k])
 	}
 	return entries
 }
@@ -365,6 +459,9 @@ func (cache *SshClientCache) HopPasswordEndpoints() []SshHop {
 	for k := range cache.sshHopPasswords {
 		keys = append(keys, k)
 	}
+	sort.Slice(keys, func(i, j int) bool {
+		return sshHopLess(keys[i], keys[j])
+	})
 	return keys
 }
 
@@ -376,6 +473,7 @@ func (cache *SshClientCache) KeyfilesWithPasswords() []string {
 	for k := range cache.keyfilePasswords {
 		keys = append(keys, k)
 	}
+	sort.Strings(keys)
 	return keys
 }
 
@@ -407,6 +505,25 @@ func (k SshHop) String() string {
 	return fmt.Sprintf("%s@%s:%s", k.User, k.Host, k.Port)
 }
 
+// sshHopLess orders hops by host, then user, then port.
+func sshHopLess(a, b SshHop) bool {
+	if a.Host != b.Host {
+		return a.Host < b.Host
+	}
+	if a.User != b.User {
+		return a.User < b.User
+	}
+	return a.Port < b.Port
+}
+
+// sshEndptLess orders endpoints by their destination, then by their proxy.
+func sshEndptLess(a, b SshEndpt) bool {
+	if a.Dest != b.Dest {
+		return sshHopLess(a.Dest, b.Dest)
+	}
+	return sshHopLess(a.Proxy, b.Proxy)
+}
+
 type SshClientCacheEntry struct {
 	client   *SshClient
 	lastUsed time.Time
@@ -418,12 +535,92 @@ type SshClient struct {
 	listener     net.Listener
 	listenerPort int
 	userData     interface{}
+	// stop is closed when the client is removed from the cache, to stop the keepalive probes.
+	stop     chan struct{}
+	stopOnce sync.Once
+	// stateLock protects broken and brokenErr. broken is set when a keepalive probe fails.
+	stateLock sync.Mutex
+	broken    bool
+	brokenErr error
 }
 
-func (s SshClient) Client() *ssh.Client {
+func newSshClient(c *ssh.Client, endpt SshEndpt) *SshClient {
+	return &SshClient{client: c, endpt: endpt, stop: make(chan struct{})}
+}
+
+func (s *SshClient) Client() *ssh.Client {
 	return s.client
 }
 
+// probe sends a keepalive request over the connection and returns an error if it fails or
+// isn't answered within timeout. A server that doesn't know the request still answers it.
+func (s *SshClient) probe(timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		// See https://datatracker.ietf.org/doc/html/draft-ssh-global-requests-ok-00 section 4.1 (active keepalive)
+		_, _, err := s.client.SendRequest("keepalive@openssh.com", true, nil)
+		errs <- err
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-errs:
+		log(LogCatgSsh, "SshClient.probe: %s: %v\n", s.endpt, err)
+		return err
+	case <-timer.C:
+		return fmt.Errorf("no reply to keepalive within %s", timeout)
+	}
+}
+
+// markBroken marks the connection as broken because of err and closes it, so that operations
+// that are waiting on it fail promptly rather than hanging.
+func (s *SshClient) markBroken(err error) {
+	s.stateLock.Lock()
+	if s.broken {
+		s.stateLock.Unlock()
+		return
+	}
+	s.broken, s.brokenErr = true, err
+	s.stateLock.Unlock()
+
+	log(LogCatgSsh, "SshClient: connection to %s is broken: %v\n", s.endpt, err)
+	s.close()
+}
+
+// Broken returns true if a keepalive probe of the connection failed.
+func (s *SshClient) Broken() bool {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	return s.broken
+}
+
+// BrokenErr returns the error that made the connection broken, or nil.
+func (s *SshClient) BrokenErr() error {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	return s.brokenErr
+}
+
+// State describes the state of the connection for About.
+func (s *SshClient) State() string {
+	if err := s.BrokenErr(); err != nil {
+		return fmt.Sprintf("broken (%v); reconnects on next use", err)
+	}
+	return "connected"
+}
+
+func (s *SshClient) stopKeepAlive() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+func (s *SshClient) close() {
+	s.stopKeepAlive()
+	if s.client != nil {
+		s.client.Close()
+	}
+}
+
 func (s *SshClient) Listener() (net.Listener, error) {
 	if s.listener != nil {
 		return s.listener, nil
@@ -461,3 +658,23 @@ func (s *SshClient) NewSession() (*ssh.Session, error) {
 	err = prefixWithSshEndpt(s.endpt, "SshClient.NewSession", err)
 	return sess, err
 }
+
+// sshHostSettingsFor returns the settings from the [ssh.hosts] section of the settings for host.
+func sshHostSettingsFor(host string) (hs SshHostSettings, ok bool) {
+	if host == "" {
+		return
+	}
+	hs, ok = settings.Ssh.Hosts[host]
+	return
+}
+
+// sshHostsWithSettings returns the hosts that have settings in the [ssh.hosts] section of the
+// settings, sorted.
+func sshHostsWithSettings() []string {
+	hosts := make([]string, 0, len(settings.Ssh.Hosts))
+	for h := range settings.Ssh.Hosts {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	return hosts
+}
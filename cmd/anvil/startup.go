@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// startupTimer records how long each phase of startup takes. Phases on the main goroutine follow
+// one another, and background phases overlap them. With --profile-startup the phases are printed
+// once the first frame has been drawn, along with the time from start to the first frame, and
+// background phases that finish later are printed as they finish.
+type startupTimer struct {
+	lock       sync.Mutex
+	start      time.Time
+	last       time.Time
+	phases     []startupPhase
+	firstFrame bool
+}
+
+type startupPhase struct {
+	name string
+	dur  time.Duration
+	// background is true for phases performed on a background goroutine, which overlap the others
+	background bool
+}
+
+var startupTiming = newStartupTimer()
+
+func newStartupTimer() *startupTimer {
+	now := time.Now()
+	return &startupTimer{start: now, last: now}
+}
+
+// phase records that the phase named name finished now. It began when the previous phase finished.
+func (t *startupTimer) phase(name string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.phaseLocked(name)
+}
+
+func (t *startupTimer) phaseLocked(name string) {
+	now := time.Now()
+	t.phases = append(t.phases, startupPhase{name: name, dur: now.Sub(t.last)})
+	t.last = now
+}
+
+// background records that the phase named name, performed on a background goroutine, took d.
+func (t *startupTimer) background(name string, d time.Duration) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	p := startupPhase{name: name, dur: d, background: true}
+	t.phases = append(t.phases, p)
+	if t.firstFrame && *optProfileStartup {
+		fmt.Printf("startup: after first frame: %s\n", p)
+	}
+}
+
+// firstFrameDrawn records the time the first frame was drawn and prints the report if requested.
+func (t *startupTimer) firstFrameDrawn() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.firstFrame {
+		return
+	}
+	t.phaseLocked("first frame")
+	t.firstFrame = true
+	if *optProfileStartup {
+		t.report(os.Stdout)
+	}
+}
+
+func (t *startupTimer) report(w io.Writer) {
+	fmt.Fprintf(w, "startup: phases:\n")
+	for _, p := range t.phases {
+		fmt.Fprintf(w, "  %s\n", p)
+	}
+	fmt.Fprintf(w, "startup: first frame drawn %s after start\n", t.last.Sub(t.start).Round(time.Microsecond))
+}
+
+func (p startupPhase) String() string {
+	s := fmt.Sprintf("%-36s %10s", p.name, p.dur.Round(time.Microsecond))
+	if p.background {
+		s += " (background)"
+	}
+	return s
+}
+
+// lazyInit is initialization that is deferred so that it doesn't delay the first frame. It has two
+// steps: load, which may be slow and must not change the state of the editor, and install, which
+// makes the loaded result take effect. It is begun on a background goroutine after startup, but
+// if the result is needed before that finishes, ensure completes it immediately.
+type lazyInit struct {
+	name        string
+	load        func()
+	install     func()
+	loadOnce    sync.Once
+	installOnce sync.Once
+}
+
+// ensure loads and installs the result if that hasn't been done yet, and waits if it is being
+// done by another goroutine.
+func (l *lazyInit) ensure() {
+	l.loadOnce.Do(l.timedLoad)
+	l.installOnce.Do(l.install)
+}
+
+// startInBackground loads the result on a background goroutine and then installs it on the
+// main goroutine using the editor's work channel.
+func (l *lazyInit) startInBackground() {
+	go func() {
+		l.loadOnce.Do(l.timedLoad)
+		editor.WorkChan() <- basicWork{l.ensure}
+	}()
+}
+
+// prefetch loads the result on a background goroutine, but leaves installing it until it is
+// needed.
+func (l *lazyInit) prefetch() {
+	go l.loadOnce.Do(l.timedLoad)
+}
+
+func (l *lazyInit) timedLoad() {
+	start := time.Now()
+	l.load()
+	startupTiming.background(l.name, time.Since(start))
+}
+
+// startDeferredInitialization begins the initialization that was left until after startup.
+func startDeferredInitialization() {
+	go printSyntaxLexerParseErrors()
+	styleFontsInit.startInBackground()
+	plumberInit.startInBackground()
+	sshKeysInit.prefetch()
+}
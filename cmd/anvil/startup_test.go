@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// resetLazyInit returns a copy of l that hasn't been performed yet.
+func resetLazyInit(l *lazyInit) *lazyInit {
+	return &lazyInit{name: l.name, load: l.load, install: l.install}
+}
+
+// serviceWork services the editor's work items until the test ends.
+func serviceWork(t *testing.T) {
+	stop := make(chan struct{})
+	t.Cleanup(func() { close(stop) })
+	go func() {
+		for {
+			select {
+			case w := <-editor.WorkChan():
+				w.Service()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+func TestLazyInitNeededBeforeBackgroundLoadFinishes(t *testing.T) {
+	newTestEditor()
+
+	loads, installs := 0, 0
+	l := &lazyInit{
+		name: "test",
+		load: func() {
+			time.Sleep(20 * time.Millisecond)
+			loads++
+		},
+		install: func() { installs++ },
+	}
+
+	l.startInBackground()
+	l.ensure()
+	if loads != 1 || installs != 1 {
+		t.Fatalf("expected 1 load and 1 install when needed but got %d and %d", loads, installs)
+	}
+
+	// The work item sent by the background goroutine must not install it again.
+	w := <-editor.WorkChan()
+	w.Service()
+	if loads != 1 || installs != 1 {
+		t.Fatalf("expected 1 load and 1 install in total but got %d and %d", loads, installs)
+	}
+}
+
+func TestPlumbingWorksImmediatelyAfterStartup(t *testing.T) {
+	newTestEditor()
+	serviceWork(t)
+
+	oldConfDir, oldPlumber, oldInit := ConfDir, plumber, plumberInit
+	t.Cleanup(func() { ConfDir, plumber, plumberInit = oldConfDir, oldPlumber, oldInit })
+
+	ConfDir = t.TempDir()
+	err := os.WriteFile(PlumbingConfigFile(), []byte("match ^issue([0-9]+)$\ndo Look $1\n"), 0644)
+	if err != nil {
+		t.Fatalf("writing plumbing file failed: %v", err)
+	}
+
+	plumber = nil
+	plumberInit = resetLazyInit(plumberInit)
+	plumberInit.startInBackground()
+
+	HirePlumber()
+	if plumber == nil || len(plumber.rules) != 1 || !plumber.rules[0].Match.MatchString("issue12") {
+		t.Fatalf("expected the plumbing rules to be loaded when first needed but got %+v", plumber)
+	}
+}
+
+func TestSshKeysLoadedForFirstConnection(t *testing.T) {
+	oldConfDir, oldInit, oldCache := ConfDir, sshKeysInit, sshClientCache
+	t.Cleanup(func() { ConfDir, sshKeysInit, sshClientCache = oldConfDir, oldInit, oldCache })
+
+	ConfDir = t.TempDir()
+	err := os.Mkdir(SshKeyDir(), 0755)
+	if err != nil {
+		t.Fatalf("creating ssh key directory failed: %v", err)
+	}
+	err = os.WriteFile(filepath.Join(SshKeyDir(), "id_test"), []byte("key"), 0600)
+	if err != nil {
+		t.Fatalf("writing ssh key failed: %v", err)
+	}
+
+	sshClientCache = NewSshClientCache(1)
+	loadedSshKeys = map[string][]byte{}
+	sshKeysInit = resetLazyInit(sshKeysInit)
+	sshKeysInit.prefetch()
+
+	// Getting a client loads the keys before connecting. The connection itself fails.
+	sshClientCache.Get(SshEndpt{Dest: SshHop{Host: "127.0.0.1", Port: "1"}}, nil)
+
+	if string(sshClientCache.keys["id_test"]) != "key" {
+		t.Fatalf("expected the ssh keys to be loaded before the first connection")
+	}
+}
+
+func TestStartupTimerFirstFrameWhileBackgroundPhasesFinish(t *testing.T) {
+	timer := newStartupTimer()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			timer.background("background", time.Millisecond)
+		}
+		close(done)
+	}()
+	timer.firstFrameDrawn()
+	timer.firstFrameDrawn()
+	<-done
+
+	frames := 0
+	for _, p := range timer.phases {
+		if p.name == "first frame" {
+			frames++
+		}
+	}
+	if frames != 1 || len(timer.phases) != 101 {
+		t.Fatalf("expected the first frame to be recorded once among %d phases, but it was recorded %d times", len(timer.phases), frames)
+	}
+}
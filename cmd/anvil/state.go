@@ -5,9 +5,11 @@ import (
 	"fmt"
 	"image"
 	"os"
+	"strings"
 	"time"
 
 	"gioui.org/app"
+	"github.com/jeffwilliams/anvil/internal/textenc"
 )
 
 type ApplicationState struct {
@@ -63,14 +65,22 @@ type EditorState struct {
 	Cols        []*ColState
 	RecentFiles []string
 	Marks       MarkState
+	Macro       MacroState
 }
 
 func (e *Editor) State() *EditorState {
 	edTag := e.Tag.State()
 
 	var cols []*ColState
+	pcts := e.ColWidthPcts()
+	vis := 0
 	for _, c := range e.Cols {
-		cols = append(cols, c.State())
+		cs := c.State()
+		if c.Visible() && vis < len(pcts) {
+			cs.WidthPct = pcts[vis]
+			vis++
+		}
+		cols = append(cols, cs)
 	}
 
 	// Remove any running jobs, since they won't be running after load.
@@ -81,6 +91,7 @@ func (e *Editor) State() *EditorState {
 		Cols:        cols,
 		RecentFiles: editor.recentFiles.All(),
 		Marks:       editor.Marks.State(),
+		Macro:       editor.Macros.State(),
 	}
 
 	//e.focusedEditable
@@ -122,12 +133,14 @@ func (e *Editor) SetState(state *EditorState) error {
 		editor.Cols[0].SetVisible(true)
 		editor.ensureFirstVisibleColIsLeftJustified()
 	}
+	editor.colWidthPctsLoaded = true
 
 	for _, f := range state.RecentFiles {
 		editor.AddRecentFile(f)
 	}
 
 	editor.Marks.SetState(state.Marks)
+	editor.Macros.SetState(state.Macro)
 
 	return nil
 }
@@ -153,6 +166,8 @@ type ColState struct {
 	LeftX   int
 	Windows []*WindowState
 	Visible bool
+	// WidthPct is the percentage of the editor width taken by the column. It is 0 for hidden columns.
+	WidthPct float64
 }
 
 func (c *Col) State() *ColState {
@@ -177,6 +192,7 @@ func (c *Col) SetState(state *ColState) error {
 	c.Tag.SetState(state.Tag)
 	c.LeftX = state.LeftX
 	c.visible = state.Visible
+	c.widthPct = colWidthPct{pct: state.WidthPct}
 
 	for _, w := range state.Windows {
 		win := c.NewWindowDontPosition()
@@ -194,6 +210,18 @@ type WindowState struct {
 	Id                 int
 	CloneIds           []int
 	ManualHighlighting []ManualHighlightingInterval
+	Presenter          string
+	Sensitive          bool
+	SensitiveSetByUser bool
+	Follow             bool
+	Aliases            map[string]string
+	Encoding           string
+	EncodingSet        bool
+	// WorkDir is the directory commands executed from the window run in, as set by Cd.
+	WorkDir string
+	// TagsCompletionOff is true if word completion doesn't complete from tags files, as set by
+	// Tagsrc.
+	TagsCompletionOff bool
 }
 
 type ManualHighlightingInterval struct {
@@ -210,7 +238,7 @@ func (w *Window) State() *WindowState {
 	}
 
 	attemptSavingContents := true
-	if w.fileType == typeDir {
+	if w.fileType == typeDir || w.sensitive {
 		attemptSavingContents = false
 	}
 
@@ -230,6 +258,15 @@ func (w *Window) State() *WindowState {
 		Id:                 w.Id,
 		CloneIds:           cloneIds,
 		ManualHighlighting: manualHighlighting,
+		Presenter:          w.Body.PresenterName(),
+		Sensitive:          w.sensitive,
+		SensitiveSetByUser: w.sensitiveSetByUser,
+		Follow:             w.IsFollowing(),
+		Aliases:            w.aliases,
+		Encoding:           w.encoding.String(),
+		EncodingSet:        w.encodingSet,
+		WorkDir:            w.workDir,
+		TagsCompletionOff:  w.tagsCompletionOff,
 	}
 }
 
@@ -239,10 +276,29 @@ func (w *Window) SetState(state *WindowState) error {
 	}
 	w.Tag.SetState(state.Tag)
 	w.TopY = state.TopY
+	w.workDir = state.WorkDir
+	w.tagsCompletionOff = state.TagsCompletionOff
 	w.initialTagUserArea = ""
 	w.SetFilenameAndTag(state.File, state.FileType)
+	if state.SensitiveSetByUser {
+		w.SetSensitive(state.Sensitive)
+	}
+	if state.Encoding != "" {
+		enc, err := textenc.Parse(textenc.Encoding{}, strings.Fields(state.Encoding))
+		if err != nil {
+			log(LogCatgApp, "Window.SetState: %v\n", err)
+		} else {
+			w.encoding, w.encodingSet = enc, state.EncodingSet
+		}
+	}
 	w.Body.SetState(state.Body)
-	if state.Body.Text == "" {
+	if state.Follow {
+		// Following starts once the file is loaded below, which may be done before GetWithSelect
+		// returns.
+		editor.follower.add(w, w.file, -1, w.encoding)
+	}
+	if state.Body.Text == "" || state.Follow {
+		// A followed file may have grown since the state was saved.
 		w.GetWithSelect(dontSelectText, dontGrowBodyIfTooSmall)
 	}
 
@@ -251,8 +307,20 @@ func (w *Window) SetState(state *WindowState) error {
 		w.Body.manualHighlighting[i] = NewSyntaxInterval(v.Start, v.End, v.Color)
 	}
 
+	err := w.Body.SetPresenter(state.Presenter)
+	if err != nil {
+		log(LogCatgApp, "Window.SetState: %v\n", err)
+	}
+
+	if state.Follow {
+		w.SetTag()
+	}
+
 	application.WinIdGenerator().Free(w.Id)
 	w.Id = state.Id
+	if len(state.Aliases) > 0 {
+		w.aliases = state.Aliases
+	}
 
 	// The clone we are searching for may not have been loaded yet.
 	// But as we load more windows from the state dump we will eventually
@@ -263,6 +331,7 @@ func (w *Window) SetState(state *WindowState) error {
 			w.addClone(clone)
 			clone.addClone(w)
 			w.Body.text = clone.Body.text
+			w.aliases = clone.sharedAliases()
 		}
 	}
 
@@ -277,18 +346,33 @@ type BodyState struct {
 	BackgroundImage  string
 	BgImgScalingType int
 	BgImgFraction    float32
+	NoWrap           bool
+	LeftOffset       int
+	TabWidth         int
+	// WhitespaceDisplay is how whitespace is shown, as set by Ws.
+	WhitespaceDisplay int
+	// GuideColumn is the column of the line-length guide, as set by Guide.
+	GuideColumn int
+	// ElasticTabs is true if the body uses elastic tab stops, as set by Etabs.
+	ElasticTabs bool
 }
 
 const MaxWindowBodyLenToDump = 4096
 
 func (b *Body) State(attemptSavingContents bool) *BodyState {
 	state := &BodyState{
-		CursorIndices:    b.CursorIndices,
-		TopLeftIndex:     b.TopLeftIndex,
-		FontIndex:        b.curFontIndex,
-		BackgroundImage:  b.bgimage.filename,
-		BgImgScalingType: int(b.bgimage.scalingType),
-		BgImgFraction:    b.bgimage.fraction,
+		CursorIndices:     b.CursorIndices,
+		TopLeftIndex:      b.TopLeftIndex,
+		FontIndex:         b.curFontIndex,
+		BackgroundImage:   b.bgimage.filename,
+		BgImgScalingType:  int(b.bgimage.scalingType),
+		BgImgFraction:     b.bgimage.fraction,
+		NoWrap:            b.noWrap,
+		LeftOffset:        b.LeftOffset,
+		TabWidth:          b.tabWidth,
+		WhitespaceDisplay: int(b.wsDisplay),
+		GuideColumn:       b.guideColumn,
+		ElasticTabs:       b.elasticTabs,
 	}
 
 	if attemptSavingContents {
@@ -314,6 +398,12 @@ func (b *Body) SetState(state *BodyState) error {
 	b.CursorIndices = state.CursorIndices
 	b.TopLeftIndex = state.TopLeftIndex
 	b.curFontIndex = state.FontIndex
+	b.noWrap = state.NoWrap
+	b.LeftOffset = state.LeftOffset
+	b.tabWidth = state.TabWidth
+	b.wsDisplay = whitespaceDisplay(state.WhitespaceDisplay)
+	b.SetGuideColumn(state.GuideColumn)
+	b.SetElasticTabs(state.ElasticTabs)
 
 	var err error
 	if state.BackgroundImage != "" {
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"image/color"
+	"io"
 	"os"
 	"strings"
 
@@ -18,6 +20,9 @@ type Style struct {
 	TagFgColor                Color
 	TagBgColor                Color
 	TagPathBasenameColor      Color
+	TagEditorAreaColor        Color
+	TagLozengeColor           Color
+	PlainTags                 bool
 	BodyFgColor               Color
 	BodyBgColor               Color
 	LayoutBoxFgColor          Color
@@ -40,9 +45,13 @@ type Style struct {
 	ErrorsTagFlashBgColor     Color
 	TabStopInterval           unit.Dp
 	Syntax                    SyntaxStyle
+	ScrollPips                ScrollPipStyle
 	Ansi                      AnsiStyle
 	LineSpacing               unit.Dp
 	TextLeftPadding           unit.Dp
+	TrailingWhitespaceBgColor Color
+	TabGuideColor             Color
+	GuideColor                Color
 }
 
 type FontStyle struct {
@@ -65,6 +74,14 @@ type SyntaxStyle struct {
 	DeletedColor      Color
 }
 
+// ScrollPipStyle holds the colours of the ticks drawn in the scrollbar at the places of interest
+// in the body.
+type ScrollPipStyle struct {
+	SearchMatchColor Color
+	MarkColor        Color
+	TintColor        Color
+}
+
 type AnsiStyle struct {
 	Colors [16]Color
 }
@@ -94,8 +111,11 @@ func (s Style) tagEditableStyle() editableStyle {
 			FgColor: s.ExecutionSelectionFgColor,
 			BgColor: s.ExecutionSelectionBgColor,
 		},
-		TabStopInterval: s.TabStopInterval,
-		TextLeftPadding: s.TextLeftPadding,
+		TabStopInterval:           s.TabStopInterval,
+		TextLeftPadding:           s.TextLeftPadding,
+		TrailingWhitespaceBgColor: s.TrailingWhitespaceBgColor,
+		TabGuideColor:             s.TabGuideColor,
+		GuideColor:                s.GuideColor,
 	}
 }
 
@@ -105,6 +125,9 @@ func (s Style) tagBlockStyle() blockStyle {
 		ErrorBgColor:      color.NRGBA(s.ErrorsTagBgColor),
 		ErrorFlashBgColor: color.NRGBA(s.ErrorsTagFlashBgColor),
 		PathBasenameColor: color.NRGBA(s.TagPathBasenameColor),
+		EditorAreaColor:   color.NRGBA(s.TagEditorAreaColor),
+		LozengeColor:      color.NRGBA(s.TagLozengeColor),
+		Plain:             s.PlainTags,
 	}
 }
 
@@ -153,6 +176,11 @@ func (s Style) scrollbarStyle() scrollbarStyle {
 		BgColor:     color.NRGBA(s.ScrollBgColor),
 		GutterWidth: s.GutterWidth,
 		Fonts:       s.Fonts,
+		PipColors: [...]color.NRGBA{
+			searchMatchPip: color.NRGBA(s.ScrollPips.SearchMatchColor),
+			markPip:        color.NRGBA(s.ScrollPips.MarkColor),
+			tintPip:        color.NRGBA(s.ScrollPips.TintColor),
+		},
 	}
 }
 
@@ -201,20 +229,22 @@ func ParseHexColor(s string) (c Color, err error) {
 	return
 }
 
+// ReadStyle reads the style from the file at path. Fields missing from the file are taken from
+// defaults. Errors decoding the file are returned as configErrors giving their position.
 func ReadStyle(path string, defaults *Style) (s Style, err error) {
 	if defaults != nil {
 		s = *defaults
 	}
 
-	file, e := os.Open(path)
-	if e != nil {
-		err = e
+	text, err := os.ReadFile(path)
+	if err != nil {
 		return
 	}
-	defer file.Close()
 
-	enc := json.NewDecoder(file)
-	err = enc.Decode(&s)
+	err = json.NewDecoder(bytes.NewReader(text)).Decode(&s)
+	if err != nil {
+		err = withConfigFile(jsonConfigError(text, err), path)
+	}
 	return
 }
 
@@ -228,7 +258,11 @@ func WriteStyle(path string, s Style) error {
 	}
 	defer file.Close()
 
-	enc := json.NewEncoder(file)
+	return encodeStyle(file, s)
+}
+
+func encodeStyle(w io.Writer, s Style) error {
+	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
 	return enc.Encode(s)
 }
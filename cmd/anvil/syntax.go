@@ -51,6 +51,14 @@ type Highlighter interface {
 	SetStyle(style SyntaxStyle)
 }
 
+// PrefixHighlighter is a Highlighter that can stop lexing once it reaches an offset in the
+// text. Since the lexer still sees the text after the offset, constructs such as comments that
+// continue past it are lexed as they would be for the whole text.
+type PrefixHighlighter interface {
+	// HighlightPrefix returns the tokens of text that start before the rune offset limit.
+	HighlightPrefix(text []rune, limit int, ctx context.Context) (seq []intvl.Interval, err error)
+}
+
 type AnalyzingHighlighter interface {
 	SetAnalyse(analyse bool)
 }
@@ -223,11 +231,21 @@ type synHighlighter struct {
 }
 
 func (s synHighlighter) Highlight(text string, ctx context.Context) (seq []intvl.Interval, err error) {
+	return s.highlight([]rune(text), -1, ctx)
+}
+
+func (s synHighlighter) HighlightPrefix(text []rune, limit int, ctx context.Context) (seq []intvl.Interval, err error) {
+	return s.highlight(text, limit, ctx)
+}
+
+// highlight returns the tokens of the text that start before limit, or all of them if limit
+// is negative.
+func (s synHighlighter) highlight(runes []rune, limit int, ctx context.Context) (seq []intvl.Interval, err error) {
 	deadline, deadlineDefined := ctx.Deadline()
 
 	started := time.Now()
 	log(LogCatgSyntax, "synHighlighter.Highlight: called\n")
-	lexer := s.lexer(text)
+	lexer := s.lexer()
 
 	if lexer == nil {
 		log(LogCatgSyntax, "synHighlighter.Highlight: no lexer found\n")
@@ -235,8 +253,6 @@ func (s synHighlighter) Highlight(text string, ctx context.Context) (seq []intvl
 		return
 	}
 
-	runes := []rune(text)
-
 	iter := lexer.Tokenise(runes)
 
 LOOP:
@@ -247,7 +263,7 @@ LOOP:
 			return
 		}
 
-		if tok.Type == syn.EOFType {
+		if tok.Type == syn.EOFType || (limit >= 0 && tok.Start >= limit) {
 			break
 		}
 
@@ -318,7 +334,7 @@ LOOP:
 	return
 }
 
-func (s synHighlighter) lexer(text string) *syn.Lexer {
+func (s synHighlighter) lexer() *syn.Lexer {
 	if s.language == "" && s.filename == "" {
 		return nil
 	}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/jeffwilliams/anvil/internal/intvl"
+)
+
+// Most lexers return to their initial state at the start of most lines, so when only a small
+// region of the text changes the syntax tokens can be updated by lexing just the lines around
+// the change again and splicing the new tokens in with the existing ones, which were already
+// shifted to account for the change.
+const (
+	// syntaxIncrementalContextLines is the number of lines before a change that are lexed again
+	// along with it. Their new tokens must match the existing ones, which shows that the lexer
+	// was in its initial state at the line the lexing started from.
+	syntaxIncrementalContextLines = 2
+	// syntaxIncrementalMaxLines is the number of lines past a change that are lexed looking for a
+	// sync point, a line from which the new tokens agree with the existing ones.
+	syntaxIncrementalMaxLines = 50
+	// syntaxIncrementalSyncLines is the number of lines past a sync point that the new and the
+	// existing tokens must agree on.
+	syntaxIncrementalSyncLines = 3
+	// syntaxIncrementalMaxChange is the size in runes of the largest changed region that is
+	// highlighted incrementally.
+	syntaxIncrementalMaxChange = 64 * 1024
+	// syntaxIncrementalTimeout is how long lexing the changed region may take before the whole
+	// document is highlighted instead.
+	syntaxIncrementalTimeout = 50 * time.Millisecond
+)
+
+// syntaxChangedRegion is the region of the text that changed since the syntax was last
+// highlighted, as rune offsets into the current text.
+type syntaxChangedRegion struct {
+	start, end int
+	// changed is true if a change was recorded.
+	changed bool
+	// unknown is true if the text changed in a way that isn't known.
+	unknown bool
+}
+
+func (r *syntaxChangedRegion) add(c TextChange) {
+	if c.IsZero() {
+		r.unknown = true
+		return
+	}
+
+	start, end := c.Offset, c.Offset
+	if c.Length > 0 {
+		end += c.Length
+		if r.changed {
+			if r.start > c.Offset {
+				r.start += c.Length
+			}
+			if r.end >= c.Offset {
+				r.end += c.Length
+			}
+		}
+	} else if r.changed {
+		r.start = shiftForDelete(r.start, c.Offset, -c.Length)
+		r.end = shiftForDelete(r.end, c.Offset, -c.Length)
+	}
+
+	if !r.changed || start < r.start {
+		r.start = start
+	}
+	if !r.changed || end > r.end {
+		r.end = end
+	}
+	r.changed = true
+}
+
+func shiftForDelete(i, offset, length int) int {
+	switch {
+	case i >= offset+length:
+		return i - length
+	case i > offset:
+		return offset
+	}
+	return i
+}
+
+// incremental returns true if the changes are known and small enough to highlight only the
+// changed region.
+func (r syntaxChangedRegion) incremental() bool {
+	return r.changed && !r.unknown && r.end-r.start <= syntaxIncrementalMaxChange
+}
+
+// highlightSyntaxAfterChange highlights the syntax after the text changed. If the existing
+// tokens were up to date before the change and only a small region changed, just that region
+// is lexed again. Otherwise the whole document is highlighted.
+func (e *editable) highlightSyntaxAfterChange() {
+	r := e.syntaxChanged
+	h, ok := e.syntaxHighlighter.(PrefixHighlighter)
+	if ok && e.syntaxTokensCurrent && r.incremental() && e.text.Len() < e.syntaxMaxDocSize {
+		ctx, cancel := context.WithTimeout(context.Background(), syntaxIncrementalTimeout)
+		toks, ok := highlightChangedRegion(ctx, h, []rune(string(e.Bytes())), e.syntaxTokens, r.start, r.end)
+		cancel()
+		if ok {
+			e.syntaxTokens = toks
+			e.syntaxChanged = syntaxChangedRegion{}
+			// Highlighting stays quick while the changes can be highlighted incrementally, even
+			// in a document that is too large to highlight completely without a delay.
+			e.syntaxHighlightDelay = 1 * time.Millisecond
+			return
+		}
+		log(LogCatgSyntax, "highlighting changed region failed; highlighting the whole document\n")
+	}
+	e.HighlightSyntax()
+}
+
+// highlightChangedRegion lexes the lines of text around the region from changeStart to
+// changeEnd again, up to a bounded number of lines past it, and splices the new tokens into
+// toks, which must be the tokens for the text before the change shifted to account for it. It
+// returns false if no sync point is found past the change, such as when the change opens or
+// closes a multi-line comment or string.
+func highlightChangedRegion(ctx context.Context, h PrefixHighlighter, text []rune, toks []intvl.Interval, changeStart, changeEnd int) (result []intvl.Interval, ok bool) {
+	if changeStart < 0 || changeEnd > len(text) || changeStart > changeEnd {
+		return
+	}
+
+	// The lexing starts at a line that no existing token spans, so that the lexer can begin in
+	// its initial state.
+	start := lineStartBefore(text, changeStart, syntaxIncrementalContextLines)
+	for {
+		t := tokenSpanning(toks, start)
+		if t == nil {
+			break
+		}
+		start = lineStartBefore(text, t.Start(), 0)
+	}
+	if changeEnd-start > syntaxIncrementalMaxChange {
+		return
+	}
+
+	limit := changeEnd
+	for i := 0; i <= syntaxIncrementalMaxLines; i++ {
+		limit = nextLineStart(text, limit)
+	}
+
+	seq, err := h.HighlightPrefix(text[start:], limit-start, ctx)
+	if err != nil {
+		return
+	}
+	for _, t := range seq {
+		if i, ok := t.(*SyntaxInterval); ok {
+			i.start += start
+			i.end += start
+		}
+	}
+
+	contextEnd := lineStartBefore(text, changeStart, 0)
+	if !sameTokensIn(seq, toks, start, contextEnd) {
+		return
+	}
+
+	sync := -1
+	if limit == len(text) {
+		// The rest of the document was lexed
+		sync = limit
+	} else {
+		p := lineStartBefore(text, changeEnd, 0)
+		if p < changeEnd {
+			p = nextLineStart(text, changeEnd)
+		}
+		for ; p < limit; p = nextLineStart(text, p) {
+			q := p
+			for i := 0; i < syntaxIncrementalSyncLines; i++ {
+				q = nextLineStart(text, q)
+			}
+			if q > limit {
+				break
+			}
+			if tokenSpanning(seq, p) == nil && tokenSpanning(toks, p) == nil && sameTokensIn(seq, toks, p, q) {
+				sync = p
+				break
+			}
+		}
+	}
+	if sync < 0 {
+		return
+	}
+
+	before := toks[:firstTokenFrom(toks, start)]
+	changed := seq[:firstTokenFrom(seq, sync)]
+	after := toks[firstTokenFrom(toks, sync):]
+
+	result = make([]intvl.Interval, 0, len(before)+len(changed)+len(after))
+	result = append(result, before...)
+	result = append(result, changed...)
+	result = append(result, after...)
+	return result, true
+}
+
+// lineStartBefore returns the start of the line n lines before the one containing the rune
+// offset i.
+func lineStartBefore(text []rune, i, n int) int {
+	for {
+		for i > 0 && text[i-1] != '\n' {
+			i--
+		}
+		if n == 0 || i == 0 {
+			return i
+		}
+		n--
+		i--
+	}
+}
+
+// nextLineStart returns the start of the line after the one containing the rune offset i, or
+// the length of text if it is the last line.
+func nextLineStart(text []rune, i int) int {
+	for i < len(text) && text[i] != '\n' {
+		i++
+	}
+	if i < len(text) {
+		i++
+	}
+	return i
+}
+
+// firstTokenFrom returns the index of the first of the tokens, which are sorted by their start,
+// that starts at or after offset.
+func firstTokenFrom(toks []intvl.Interval, offset int) int {
+	return sort.Search(len(toks), func(i int) bool { return toks[i].Start() >= offset })
+}
+
+// tokenSpanning returns the token that starts before offset and ends after it, or nil if there
+// is none.
+func tokenSpanning(toks []intvl.Interval, offset int) intvl.Interval {
+	// Tokens don't overlap, so only the token just before offset can span it.
+	i := firstTokenFrom(toks, offset)
+	if i > 0 && toks[i-1].End() > offset {
+		return toks[i-1]
+	}
+	return nil
+}
+
+// sameTokensIn returns true if a and b have the same tokens between the offsets from and to,
+// and none of them extend past to.
+func sameTokensIn(a, b []intvl.Interval, from, to int) bool {
+	a = a[firstTokenFrom(a, from):firstTokenFrom(a, to)]
+	b = b[firstTokenFrom(b, from):firstTokenFrom(b, to)]
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].End() > to || !sameToken(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameToken(a, b intvl.Interval) bool {
+	if a.Start() != b.Start() || a.End() != b.End() {
+		return false
+	}
+	sa, ok1 := a.(*SyntaxInterval)
+	sb, ok2 := b.(*SyntaxInterval)
+	return ok1 == ok2 && (!ok1 || sa.color == sb.color)
+}
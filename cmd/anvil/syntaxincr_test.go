@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jeffwilliams/anvil/internal/intvl"
+)
+
+func TestSyntaxChangedRegion(t *testing.T) {
+	var r syntaxChangedRegion
+	r.add(NewTextChange(10, 2))
+	r.add(NewTextChange(4, 3))
+	if r.start != 4 || r.end != 15 {
+		t.Fatalf("after two inserts expected the region 4-15 but got %d-%d", r.start, r.end)
+	}
+	r.add(NewTextChange(2, -4))
+	if r.start != 2 || r.end != 11 {
+		t.Fatalf("after a delete expected the region 2-11 but got %d-%d", r.start, r.end)
+	}
+	r.add(NewTextChange(20, -1))
+	if r.start != 2 || r.end != 20 {
+		t.Fatalf("after a delete past the region expected the region 2-20 but got %d-%d", r.start, r.end)
+	}
+	if !r.incremental() {
+		t.Fatalf("expected the known changes to be highlighted incrementally")
+	}
+	r.add(TextChange{})
+	if r.incremental() {
+		t.Fatalf("expected an unknown change to need the whole document highlighted")
+	}
+}
+
+// syntaxTestHighlighter returns a highlighter for Go source.
+func syntaxTestHighlighter() *synHighlighter {
+	return &synHighlighter{style: WindowStyle.Syntax, filename: "test.go"}
+}
+
+// editForSyntaxTest replaces the length runes of text at offset with insert, and shifts the
+// tokens for the text as an editable does for the delete and the insert. It returns the new
+// text and the changed region.
+func editForSyntaxTest(text []rune, toks []intvl.Interval, offset, length int, insert string) (result []rune, start, end int) {
+	var r syntaxChangedRegion
+	ins := []rune(insert)
+	shift := func(offset, length int) {
+		for _, t := range toks {
+			i := t.(*SyntaxInterval)
+			i.start, i.end = computeShiftNeededDueToTextModification(i, offset, length)
+		}
+		r.add(NewTextChange(offset, length))
+	}
+
+	result = append(result, text[:offset]...)
+	result = append(result, ins...)
+	result = append(result, text[offset+length:]...)
+	if length > 0 {
+		shift(offset, -length)
+	}
+	if len(ins) > 0 {
+		shift(offset, len(ins))
+	}
+	return result, r.start, r.end
+}
+
+func TestHighlightChangedRegion(t *testing.T) {
+	src := `package main
+
+import "fmt"
+
+// main prints a greeting.
+func main() {
+	name := "world"
+	count := 42
+	fmt.Printf("hello %s %d\n", name, count)
+}
+
+func other() int {
+	return 7
+}
+`
+	// Make the document longer than the lines lexed past a change so that the lexing stops at a
+	// sync point rather than at the end of the document, and end it with a comment and a string
+	// that an unclosed one before them would extend to.
+	src += strings.Repeat("var v = 1\n", syntaxIncrementalMaxLines+10)
+	src += "/* end */\nvar s = `end`\n"
+
+	tests := []struct {
+		name   string
+		at     string
+		length int
+		insert string
+		ok     bool
+	}{
+		{"type in an identifier", "count := 42", 0, "x", true},
+		{"delete a keyword", "return 7", 6, "", true},
+		{"start a string", "count := 42", 0, `"`, true},
+		{"insert a line", "func other", 0, "var v = 1\n", true},
+		{"open a block comment", "count := 42", 0, "/* ", false},
+		{"open a raw string", "name :=", 0, "`", false},
+	}
+
+	h := syntaxTestHighlighter()
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			toks, err := h.Highlight(src, context.Background())
+			if err != nil || len(toks) == 0 {
+				t.Fatalf("highlighting failed: %v", err)
+			}
+
+			text := []rune(src)
+			offset := len([]rune(src[:strings.Index(src, tc.at)]))
+			text, start, end := editForSyntaxTest(text, toks, offset, tc.length, tc.insert)
+
+			got, ok := highlightChangedRegion(context.Background(), h, text, toks, start, end)
+			if ok != tc.ok {
+				t.Fatalf("expected the region to be highlighted incrementally to be %v but was %v", tc.ok, ok)
+			}
+			if !ok {
+				return
+			}
+
+			expected, _ := h.Highlight(string(text), context.Background())
+			if !sameTokensIn(got, expected, 0, len(text)) {
+				t.Fatalf("the tokens differ from highlighting the whole document:\ngot      %v\nexpected %v", tokensString(got), tokensString(expected))
+			}
+		})
+	}
+}
+
+func tokensString(toks []intvl.Interval) string {
+	var b strings.Builder
+	for _, t := range toks {
+		fmt.Fprintf(&b, "%d-%d ", t.Start(), t.End())
+	}
+	return b.String()
+}
+
+// makeSyntaxBenchmarkSource returns Go source of at least size bytes.
+func makeSyntaxBenchmarkSource(size int) string {
+	var b strings.Builder
+	b.WriteString("package main\n\nimport \"fmt\"\n\n")
+	for i := 0; b.Len() < size; i++ {
+		fmt.Fprintf(&b, `// function%[1]d computes a value.
+func function%[1]d(a, b int) (int, error) {
+	/* check the arguments */
+	if a > b {
+		return 0, fmt.Errorf("a %%d is greater than b %%d", a, b)
+	}
+	total := 0
+	for i := a; i < b; i++ {
+		total += i * %[1]d
+	}
+	return total, nil
+}
+
+`, i)
+	}
+	return b.String()
+}
+
+// syntaxBenchmarkEdit prepares a ~1MB Go document in which a single character was typed in
+// the middle, with the tokens from before the edit shifted to account for it.
+func syntaxBenchmarkEdit(tb testing.TB) (h *synHighlighter, src []byte, toks []intvl.Interval, start, end int) {
+	h = syntaxTestHighlighter()
+	s := makeSyntaxBenchmarkSource(1024 * 1024)
+	toks, err := h.Highlight(s, context.Background())
+	if err != nil {
+		tb.Fatalf("highlighting failed: %v", err)
+	}
+
+	text := []rune(s)
+	offset := len([]rune(s[:strings.Index(s[len(s)/2:], "total := 0")+len(s)/2]))
+	text, start, end = editForSyntaxTest(text, toks, offset+len("total"), 0, "s")
+	return h, []byte(string(text)), toks, start, end
+}
+
+func TestHighlightChangedRegionOfLargeDocument(t *testing.T) {
+	h, src, toks, start, end := syntaxBenchmarkEdit(t)
+	got, ok := highlightChangedRegion(context.Background(), h, []rune(string(src)), toks, start, end)
+	if !ok {
+		t.Fatalf("expected the edit to be highlighted incrementally")
+	}
+	expected, _ := h.Highlight(string(src), context.Background())
+	if !sameTokensIn(got, expected, 0, len([]rune(string(src)))) {
+		t.Fatalf("the tokens differ from highlighting the whole document")
+	}
+}
+
+// BenchmarkHighlightWholeDocumentAfterEdit measures highlighting a ~1MB Go document after a
+// single character was typed, by highlighting the whole document.
+func BenchmarkHighlightWholeDocumentAfterEdit(b *testing.B) {
+	h, src, _, _, _ := syntaxBenchmarkEdit(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		h.Highlight(string(src), context.Background())
+	}
+}
+
+// BenchmarkHighlightChangedRegionAfterEdit measures highlighting a ~1MB Go document after a
+// single character was typed, by highlighting only the changed region.
+func BenchmarkHighlightChangedRegionAfterEdit(b *testing.B) {
+	h, src, toks, start, end := syntaxBenchmarkEdit(b)
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		_, ok := highlightChangedRegion(context.Background(), h, []rune(string(src)), toks, start, end)
+		if !ok {
+			b.Fatalf("expected the edit to be highlighted incrementally")
+		}
+	}
+}
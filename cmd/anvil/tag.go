@@ -9,6 +9,12 @@ import (
 type Tag struct {
 	blockEditable
 	flash bool
+	// showFullPath is set using the Path command to stop the path from being shortened.
+	showFullPath bool
+	// pathAbbrev is the last path shortened and how it was shortened.
+	pathAbbrev struct {
+		path, abbrev string
+	}
 }
 
 func (t *Tag) Init(body *Body, style blockStyle, editableStyle editableStyle, executor *CommandExecutor, finder *FileFinder, owner interface{}, scheduler *Scheduler) {
@@ -23,7 +29,8 @@ func (t *Tag) Init(body *Body, style blockStyle, editableStyle editableStyle, ex
 		executor:   executor,
 		owner:      owner,
 	})
-	t.AddTextChangeListener(t.highlightBasenameOnTextChange)
+	t.AddTextChangeListener(t.highlightPartsOnTextChange)
+	t.AddTextChangeListener(t.abbreviatePathOnTextChange)
 }
 
 func (t Tag) Parts() (path, editorArea, userArea string, err error) {
@@ -47,8 +54,7 @@ func (t *Tag) Set(path, editorArea, userArea string) {
 	t.immutableRange.end = editorAreaLen + pathLen
 
 	t.setBgColor(path)
-	t.ClearManualHighlights()
-	t.highlightBasename(path)
+	t.highlightParts()
 }
 
 func (t *Tag) setBgColor(path string) {
@@ -124,6 +130,7 @@ func (t *Tag) SetStyle(style blockStyle, editableStyle editableStyle) {
 	t.blockEditable.SetStyle(style, editableStyle)
 	path, _, _, _ := t.Parts()
 	t.setBgColor(path)
+	t.highlightParts()
 }
 
 func (t *Tag) SetFlash(b bool) {
@@ -154,12 +161,48 @@ func (t *Tag) highlightBasename(path string) {
 	t.AddManualHighlight(start, end, Color(t.style.PathBasenameColor))
 }
 
-func (t *Tag) highlightBasenameOnTextChange(ch *TextChange) {
+// highlightParts colors the basename of the path in the tag. Unless the style asks for plain
+// tags it also dims the editor area, so that it is easy to see where the user area starts, and
+// colors the commands delimited by ◊ in the user area.
+func (t *Tag) highlightParts() {
 	t.ClearManualHighlights()
-	path, _, _, err := t.Parts()
+	inBytes, inRunes, err := t.calcParts()
 	if err != nil {
 		return
 	}
 
-	t.highlightBasename(path)
+	s := t.String()
+	t.highlightBasename(inBytes.path.Section(s))
+	if t.style.Plain {
+		return
+	}
+
+	// The basename highlight includes the space that starts the editor area.
+	t.AddManualHighlight(inRunes.editorArea[0]+1, inRunes.editorArea[1], Color(t.style.EditorAreaColor))
+	for _, l := range lozengeSpans(inBytes.userArea.Section(s)) {
+		t.AddManualHighlight(inRunes.userArea[0]+l[0], inRunes.userArea[0]+l[1], Color(t.style.LozengeColor))
+	}
+}
+
+func (t *Tag) highlightPartsOnTextChange(ch *TextChange) {
+	t.highlightParts()
+}
+
+// lozengeSpans returns the rune offsets of the text in s delimited by pairs of ◊, including the
+// ◊ at each end. A ◊ without a closing one is not included.
+func lozengeSpans(s string) (spans []section) {
+	start := -1
+	i := 0
+	for _, r := range s {
+		if r == '◊' {
+			if start < 0 {
+				start = i
+			} else {
+				spans = append(spans, section{start, i + 1})
+				start = -1
+			}
+		}
+		i++
+	}
+	return
 }
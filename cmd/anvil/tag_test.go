@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLozengeSpans(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected []section
+	}{
+		{"Look Get", nil},
+		{" ◊Fuzz x◊ Look", []section{{1, 9}}},
+		{"◊a◊ é ◊b◊ ◊", []section{{0, 3}, {6, 9}}},
+	}
+
+	for _, tc := range tests {
+		if spans := lozengeSpans(tc.text); !reflect.DeepEqual(spans, tc.expected) {
+			t.Errorf("for %q expected %v but got %v", tc.text, tc.expected, spans)
+		}
+	}
+}
+
+func TestTagHighlightsParts(t *testing.T) {
+	win := newZeroxTestWindow(t, "")
+	tagHighlights := func() (hl [][2]int) {
+		for _, h := range win.Tag.manualHighlighting {
+			hl = append(hl, [2]int{h.Start(), h.End()})
+		}
+		return
+	}
+
+	// The tag is "/tmp/a.txt Del |◊Fuzz x◊ ◊".
+	win.Tag.Set("/tmp/a.txt", " Del |", "◊Fuzz x◊ ◊")
+	expected := [][2]int{{5, 11}, {11, 16}, {16, 24}}
+	if hl := tagHighlights(); !reflect.DeepEqual(hl, expected) {
+		t.Fatalf("expected the highlights %v but got %v", expected, hl)
+	}
+
+	win.Tag.SetCursorIndices([]int{26})
+	win.Tag.InsertText("◊")
+	expected = append(expected, [2]int{25, 27})
+	if hl := tagHighlights(); !reflect.DeepEqual(hl, expected) {
+		t.Fatalf("expected the highlights to be updated to %v after typing but got %v", expected, hl)
+	}
+
+	style := WindowStyle
+	style.PlainTags = true
+	win.Tag.SetStyle(style.tagBlockStyle(), style.tagEditableStyle())
+	expected = [][2]int{{5, 11}}
+	if hl := tagHighlights(); !reflect.DeepEqual(hl, expected) {
+		t.Fatalf("expected only the basename to be highlighted in plain tags but got %v", hl)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// The ways the path in a window tag can be shortened, set by the tag-path-abbreviation setting.
+const (
+	tagPathAbbrevNone    = "none"
+	tagPathAbbrevMiddle  = "middle"
+	tagPathAbbrevHome    = "home"
+	tagPathAbbrevProject = "project"
+)
+
+func isValidTagPathAbbrev(s string) bool {
+	return s == tagPathAbbrevNone || s == tagPathAbbrevMiddle || s == tagPathAbbrevHome || s == tagPathAbbrevProject
+}
+
+// tagPathEllipsis stands for the directories left out of a shortened path.
+const tagPathEllipsis = "…"
+
+// abbreviatePath returns path shortened as mode says if it is longer than max runes. The home
+// and project modes replace the home directory with ~, or the directories above the project
+// root with the ellipsis, and then all modes remove directories from the middle of the path
+// until it fits. The basename is never shortened, so the result may still be longer than max.
+func abbreviatePath(path, mode string, max int) string {
+	if mode == tagPathAbbrevNone || !isValidTagPathAbbrev(mode) || max <= 0 || utf8.RuneCountInString(path) <= max {
+		return path
+	}
+
+	g, err := NewGlobalPath(path, GlobalPathUnknown)
+	if err != nil || !strings.HasSuffix(path, g.Path()) {
+		return path
+	}
+	host := path[:len(path)-len(g.Path())]
+	p := g.Path()
+
+	if !g.IsRemote() {
+		switch mode {
+		case tagPathAbbrevHome:
+			p = homeRelativePath(p)
+		case tagPathAbbrevProject:
+			p = projectRelativePath(p)
+		}
+	}
+
+	if utf8.RuneCountInString(host+p) > max {
+		p = truncatePathMiddle(p, max-utf8.RuneCountInString(host))
+	}
+	return host + p
+}
+
+func pathSeparatorOf(p string) string {
+	if !strings.Contains(p, "/") && strings.Contains(p, `\`) {
+		return `\`
+	}
+	return "/"
+}
+
+func homeRelativePath(p string) string {
+	home, err := os.UserHomeDir()
+	if err != nil || home == "" {
+		return p
+	}
+	home = strings.TrimSuffix(home, pathSeparatorOf(home))
+	if p != home && !strings.HasPrefix(p, home+pathSeparatorOf(p)) {
+		return p
+	}
+	return "~" + p[len(home):]
+}
+
+func projectRelativePath(p string) string {
+	sep := pathSeparatorOf(p)
+	dir := filepath.Dir(p)
+	if strings.HasSuffix(p, sep) {
+		dir = p
+	}
+
+	root := projectRoot(dir, false)
+	if fi, err := os.Stat(filepath.Join(root, ".git")); err != nil || !fi.IsDir() {
+		return p
+	}
+	parent := filepath.Dir(root)
+	if parent == root || !strings.HasPrefix(p, parent) {
+		return p
+	}
+	return tagPathEllipsis + sep + strings.TrimPrefix(p[len(parent):], sep)
+}
+
+// truncatePathMiddle replaces directories in the middle of p with the ellipsis until p is no
+// longer than max runes, keeping the first element, the basename and as many directories after
+// the first and before the basename as fit.
+func truncatePathMiddle(p string, max int) string {
+	sep := pathSeparatorOf(p)
+	body := strings.TrimSuffix(p, sep)
+	trailing := p[len(body):]
+
+	i := strings.LastIndex(body, sep)
+	if i < 0 {
+		return p
+	}
+	base := body[i+1:] + trailing
+	elems := strings.Split(body[:i], sep)
+	// The first element is empty for an absolute path, or is something like ~ or C:.
+	first, middle := elems[0], elems[1:]
+	if len(middle) == 0 {
+		return p
+	}
+
+	build := func(front, back int) string {
+		parts := []string{first}
+		parts = append(parts, middle[:front]...)
+		parts = append(parts, tagPathEllipsis)
+		parts = append(parts, middle[len(middle)-back:]...)
+		return strings.Join(parts, sep) + sep + base
+	}
+
+	front, back := 0, 0
+	for takeFront := true; front+back < len(middle)-1; takeFront = !takeFront {
+		f, b := front, back
+		if takeFront {
+			f++
+		} else {
+			b++
+		}
+		if utf8.RuneCountInString(build(f, b)) > max {
+			break
+		}
+		front, back = f, b
+	}
+	return build(front, back)
+}
+
+// abbreviatePathOnTextChange draws the path in the tag shortened as the tag-path-abbreviation
+// setting says. The text of the tag keeps the full path, so executing, Put, the API and Dump
+// all use it.
+func (t *Tag) abbreviatePathOnTextChange(ch *TextChange) {
+	t.abbreviatePath()
+}
+
+func (t *Tag) abbreviatePath() {
+	path, _, _, err := t.Parts()
+	if err != nil || t.showFullPath || path == "" {
+		t.SetDisplaySubstitution(nil)
+		return
+	}
+
+	if path != t.pathAbbrev.path {
+		t.pathAbbrev.path = path
+		t.pathAbbrev.abbrev = abbreviatePath(path, settings.Layout.TagPathAbbreviation, settings.Layout.TagPathMaxLength)
+	}
+
+	if t.pathAbbrev.abbrev == path {
+		t.SetDisplaySubstitution(nil)
+		return
+	}
+	t.SetDisplaySubstitution(newDisplaySubstitution(0, path, t.pathAbbrev.abbrev))
+}
+
+// SetShowFullPath stops or starts shortening the path in the tag.
+func (t *Tag) SetShowFullPath(b bool) {
+	t.showFullPath = b
+	t.abbreviatePath()
+}
+
+// PathIsAbbreviated returns true if the path in the tag is drawn shortened.
+func (t *Tag) PathIsAbbreviated() bool {
+	return t.displaySubst != nil
+}
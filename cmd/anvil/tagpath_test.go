@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAbbreviatePath(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	proj := filepath.Join(t.TempDir(), "anvil")
+	err := os.MkdirAll(filepath.Join(proj, ".git"), 0755)
+	if err != nil {
+		t.Fatalf("making the project failed: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		mode     string
+		max      int
+		expected string
+	}{
+		{"short enough", "/a/b/file.go", tagPathAbbrevMiddle, 20, "/a/b/file.go"},
+		{"none", "/very/long/path/to/some/deep/file.go", tagPathAbbrevNone, 10, "/very/long/path/to/some/deep/file.go"},
+		{"middle", "/very/long/path/to/some/deep/file.go", tagPathAbbrevMiddle, 22, "/very/…/deep/file.go"},
+		{"remote", "host:/very/long/path/to/some/deep/file.go", tagPathAbbrevMiddle, 27, "host:/very/…/deep/file.go"},
+		{"basename is kept", "/very/long/a-very-long-file-name.go", tagPathAbbrevMiddle, 5, "/…/a-very-long-file-name.go"},
+		{"directory", "/very/long/path/to/some/deep/", tagPathAbbrevMiddle, 18, "/very/…/some/deep/"},
+		{"home", home + "/src/x/file.go", tagPathAbbrevHome, 5, "~/…/file.go"},
+		{"home fits", home + "/src/x/file.go", tagPathAbbrevHome, 20, "~/src/x/file.go"},
+		{"project", proj + "/cmd/anvil/main.go", tagPathAbbrevProject, 30, "…/anvil/cmd/anvil/main.go"},
+		{"project outside", "/not/a/project/dir/file.go", tagPathAbbrevProject, 19, "/not/…/dir/file.go"},
+	}
+
+	for _, tc := range tests {
+		got := abbreviatePath(tc.path, tc.mode, tc.max)
+		if got != tc.expected {
+			t.Errorf("%s: expected %s but got %s", tc.name, tc.expected, got)
+		}
+	}
+}
+
+func TestDisplaySubstitutionMapping(t *testing.T) {
+	text := "/very/long/path/file.go Del |"
+	s := newDisplaySubstitution(0, "/very/long/path/file.go", "/very/…/file.go")
+	disp := string(s.apply([]byte(text)))
+	if disp != "/very/…/file.go Del |" {
+		t.Fatalf("expected the path to be replaced but got %q", disp)
+	}
+
+	// Each rune of the text that isn't hidden is drawn as the same rune.
+	hidden := [2]int{len("/very/"), len("/very/long/path/")}
+	for i, r := range []rune(text) {
+		if i >= hidden[0] && i < hidden[1] {
+			continue
+		}
+		d := s.toDisplay(i)
+		if s.toText(d) != i || []rune(disp)[d] != r {
+			t.Errorf("text index %d (%c) is drawn at %d (%c)", i, r, d, []rune(disp)[d])
+		}
+	}
+
+	ellipsis := strings.Index(disp, "…")
+	if got := s.toText(ellipsis); got != len("/very/") {
+		t.Errorf("expected the ellipsis to map to the first hidden rune but it maps to %d", got)
+	}
+	if got := s.toText(len([]rune(disp)) - 1); got != len(text)-1 {
+		t.Errorf("expected the last rune to map to the end of the text but it maps to %d", got)
+	}
+}
+
+func TestTagKeepsFullPathWhenAbbreviated(t *testing.T) {
+	newTestEditor()
+
+	old := settings.Layout
+	settings.Layout.TagPathAbbreviation = tagPathAbbrevMiddle
+	settings.Layout.TagPathMaxLength = 20
+	defer func() { settings.Layout = old }()
+
+	w := editor.NewWindow(nil)
+	path := "/very/long/path/to/some/deep/file.go"
+	w.SetFilenameAndTag(path, typeFile)
+
+	if !strings.HasPrefix(w.Tag.String(), path+" ") {
+		t.Fatalf("expected the tag text to keep the full path but it is %q", w.Tag.String())
+	}
+	if !w.Tag.PathIsAbbreviated() {
+		t.Fatalf("expected the path to be abbreviated")
+	}
+	disp := string(w.Tag.displaySubst.apply(w.Tag.Bytes()))
+	if !strings.HasPrefix(disp, "/very/…/deep/file.go ") {
+		t.Fatalf("expected the abbreviated path to be drawn but got %q", disp)
+	}
+
+	// A click on the basename in the drawn text is a click on the basename in the tag.
+	base := strings.Index(path, "file.go")
+	if got := w.Tag.textIndex(len([]rune("/very/…/deep/"))); got != base {
+		t.Fatalf("expected a click on the basename to map to %d but it maps to %d", base, got)
+	}
+
+	w.SetFilenameAndTag("/a/file.go", typeFile)
+	if w.Tag.PathIsAbbreviated() {
+		t.Fatalf("expected a short path not to be abbreviated")
+	}
+
+	w.SetFilenameAndTag(path, typeFile)
+	w.Tag.SetShowFullPath(true)
+	if w.Tag.PathIsAbbreviated() {
+		t.Fatalf("expected the full path to be shown")
+	}
+}
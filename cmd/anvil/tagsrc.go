@@ -0,0 +1,111 @@
+package main
+
+import (
+	"time"
+
+	"github.com/jeffwilliams/anvil/internal/ctags"
+	"github.com/jeffwilliams/anvil/internal/words"
+)
+
+// tagsCompletionSource is the source listed for completions from tags files.
+const tagsCompletionSource = "tags"
+
+// tagsIndexes holds the names from the tags files used for completion by path. They are shared by
+// all windows. It is only used by the editor goroutine.
+var tagsIndexes = map[string]*completionIndex{}
+
+func tagsIndexFor(path string) *completionIndex {
+	x, ok := tagsIndexes[path]
+	if !ok {
+		x = &completionIndex{
+			kind:    "tags file",
+			jobName: "Tags",
+			path:    path,
+			file:    path,
+			source:  tagsCompletionSource,
+			read:    ctags.Names,
+		}
+		tagsIndexes[path] = x
+	}
+	return x
+}
+
+// tagsFilesByDir holds the tags files found for completion in each directory and the directories
+// above it. It is only used by the editor goroutine.
+var tagsFilesByDir = map[string]*tagsFiles{}
+
+// tagsFiles are the tags files found for a directory. Finding them stats a file in every
+// directory up to the root, so it is done in the background, like checking a completionIndex.
+type tagsFiles struct {
+	paths    []string
+	checked  time.Time
+	checking bool
+}
+
+// tagsFilesFor returns the tags files last found for dir, and looks for them again in the
+// background if that wasn't done recently. The word completion in e is refreshed once the
+// tags files that are found are loaded.
+func tagsFilesFor(dir string, e *editable) []string {
+	f, ok := tagsFilesByDir[dir]
+	if !ok {
+		f = &tagsFiles{}
+		tagsFilesByDir[dir] = f
+	}
+
+	if f.checking || (!f.checked.IsZero() && time.Since(f.checked) < completionSourceCheckInterval) {
+		return f.paths
+	}
+
+	f.checked = time.Now()
+	f.checking = true
+	awaitCompletionSources(e)
+	go func() {
+		paths, err := ctags.FindAll(dir)
+		editor.WorkChan() <- basicWork{func() {
+			f.checking = false
+			if err != nil {
+				log(LogCatgCompletion, "Finding the tags files for %s failed: %v\n", dir, err)
+				return
+			}
+			f.paths = paths
+			// Start loading the files now rather than at the next completion.
+			for _, path := range paths {
+				tagsIndexFor(path).update(nil)
+			}
+		}}
+	}()
+	return f.paths
+}
+
+// tagsCompletions returns the completions of prefix from the tags files in the directory of the
+// window holding the editable and the directories above it. Remote windows and windows where it
+// was turned off with Tagsrc have none. Tags files that haven't been found or loaded yet are
+// looked for and loaded in the background, and the word completion in the editable is refreshed
+// once they are loaded.
+func tagsCompletions(e *editable, prefix string) []words.Completion {
+	if prefix == "" || !settings.Completion.Tags {
+		return nil
+	}
+
+	w := editor.windowOfEditable(e)
+	if w == nil || w.tagsCompletionOff {
+		return nil
+	}
+
+	p, err := NewFileFinder(w).winFileNoCheck()
+	if err != nil || p.IsRemote() {
+		return nil
+	}
+
+	paths := tagsFilesFor(p.Dir().Path(), e)
+	lists := make([][]words.Completion, len(paths))
+	for i, path := range paths {
+		lists[i] = tagsIndexFor(path).completions(prefix, e)
+	}
+	return words.MergeCompletions(lists...)
+}
+
+// SetTagsCompletion sets whether word completion in the window completes from tags files.
+func (w *Window) SetTagsCompletion(on bool) {
+	w.tagsCompletionOff = !on
+}
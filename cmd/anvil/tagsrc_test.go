@@ -0,0 +1,144 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// waitForCompletionSources services the editor until the files used as sources of completions
+// are no longer being found, checked or loaded.
+func waitForCompletionSources(t *testing.T, h *Headless) {
+	t.Helper()
+	busy := func() bool {
+		for _, f := range tagsFilesByDir {
+			if f.checking {
+				return true
+			}
+		}
+		for _, m := range []map[string]*completionIndex{tagsIndexes, dictionaries} {
+			for _, x := range m {
+				if x.checking || x.job != nil {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < 500 && busy(); i++ {
+		settle(t, h)
+	}
+	if busy() {
+		t.Fatalf("the sources of completions are still being loaded")
+	}
+}
+
+// forgetCompletionSourceChecks makes the next completion look at the files used as sources of
+// completions again, as if they were last checked long ago.
+func forgetCompletionSourceChecks() {
+	for _, f := range tagsFilesByDir {
+		f.checked = time.Time{}
+	}
+	for _, m := range []map[string]*completionIndex{tagsIndexes, dictionaries} {
+		for _, x := range m {
+			x.checked = time.Time{}
+		}
+	}
+}
+
+func TestTagsCompletions(t *testing.T) {
+	h := newTestHeadless()
+	tagsIndexes = map[string]*completionIndex{}
+	tagsFilesByDir = map[string]*tagsFiles{}
+
+	root := t.TempDir()
+	tags := filepath.Join(root, "tags")
+	if err := os.WriteFile(tags, []byte("!_TAG_FILE_SORTED\t1\nOpen\ta.go\t3\nOpenFile\ta.go\t9\nClose\ta.go\t12\n"), 0644); err != nil {
+		t.Fatalf("writing the tags file failed: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(root, "sub"), 0755); err != nil {
+		t.Fatalf("creating the directory failed: %v", err)
+	}
+	win := h.OpenWindow(filepath.Join(root, "sub", "b.go"), []byte("package sub\n"))
+
+	complete := func(prefix string) (words []string) {
+		t.Helper()
+		forgetCompletionSourceChecks()
+		tagsCompletions(&win.Body.editable, prefix)
+		waitForCompletionSources(t, h)
+		for _, c := range tagsCompletions(&win.Body.editable, prefix) {
+			if !reflect.DeepEqual(c.Sources(), []string{tagsCompletionSource}) {
+				t.Fatalf("expected the source of %s to be %q but it is %v", c.Word(), tagsCompletionSource, c.Sources())
+			}
+			words = append(words, c.Word())
+		}
+		return
+	}
+
+	if words := complete("Op"); !reflect.DeepEqual(words, []string{"Open", "OpenFile"}) {
+		t.Fatalf("expected the names in the tags file above the window but got %v", words)
+	}
+
+	// The file is loaded again once it is modified.
+	if err := os.WriteFile(tags, []byte("Opener\ta.go\t1\n"), 0644); err != nil {
+		t.Fatalf("writing the tags file failed: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(tags, later, later); err != nil {
+		t.Fatalf("changing the modification time failed: %v", err)
+	}
+	if words := complete("Op"); !reflect.DeepEqual(words, []string{"Opener"}) {
+		t.Fatalf("expected the names from the modified tags file but got %v", words)
+	}
+
+	h.Execute(&win.Tag, "Tagsrc off")
+	if words := complete("Op"); len(words) != 0 {
+		t.Fatalf("expected no completions after Tagsrc off but got %v", words)
+	}
+	h.Execute(&win.Tag, "Tagsrc")
+	if words := complete("Op"); len(words) != 1 {
+		t.Fatalf("expected Tagsrc to turn completion from tags files back on but got %v", words)
+	}
+}
+
+func TestWordCompletionRefreshedWhenTagsFileIsLoaded(t *testing.T) {
+	h := newTestHeadless()
+	tagsIndexes = map[string]*completionIndex{}
+	tagsFilesByDir = map[string]*tagsFiles{}
+
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "tags"), []byte("Open\ta.go\t3\nOpenFile\ta.go\t9\n"), 0644); err != nil {
+		t.Fatalf("writing the tags file failed: %v", err)
+	}
+	win := h.OpenWindow(filepath.Join(root, "b.go"), []byte("Openly\nOp"))
+	body := &win.Body.editable
+	body.BuildCompletions()
+	body.SetCursorIndex(0, body.text.Len())
+
+	// The tags file hasn't been found yet, so only the word in the window is completed, without
+	// waiting.
+	body.doWordCompletion(body.wordObjectToComplete(body.firstCursorIndex()), Forward)
+	if got := win.Body.String(); got != "Openly\nOpenly" {
+		t.Fatalf("expected the word in the window to be completed but the body is %q", got)
+	}
+
+	waitForCompletionSources(t, h)
+	var got []string
+	for _, w := range body.wordCompletion.completions {
+		got = append(got, w.Word())
+	}
+	if !reflect.DeepEqual(got, []string{"Open", "OpenFile", "Openly"}) {
+		t.Fatalf("expected the completion in progress to be refreshed with the tags but got %v", got)
+	}
+	if shown := body.wordCompletion.completions[body.wordCompletion.completionToShow].Word(); shown != "Openly" {
+		t.Fatalf("expected the completion shown to stay Openly but it is %s", shown)
+	}
+
+	body.doWordCompletion(body.wordObjectToComplete(body.firstCursorIndex()), Forward)
+	if got := win.Body.String(); got != "Openly\nOpen" {
+		t.Fatalf("expected the next completion to be the first from the tags file but the body is %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// errKilledWithoutResponse is returned when a remote job was killed but the remote host never
+// said that the process ended, so it might still be running.
+var errKilledWithoutResponse = errors.New("killed process, but got no response")
+
+// jobTermination records how a job that ran a command ended, so that it can be reported after
+// the output of the job.
+type jobTermination struct {
+	started time.Time
+	// err is the error returned when waiting for the command to end, if any.
+	err    error
+	killed bool
+}
+
+// isTerminationError returns true if err says how a command ended rather than reporting a
+// problem reading its output.
+func isTerminationError(err error) bool {
+	var exitErr *exec.ExitError
+	var sshExitErr *ssh.ExitError
+	var sshMissingErr *ssh.ExitMissingError
+	return errors.As(err, &exitErr) || errors.As(err, &sshExitErr) || errors.As(err, &sshMissingErr) ||
+		errors.Is(err, errKilledWithoutResponse) || errors.Is(err, io.EOF)
+}
+
+// clean returns true if the command ran to the end and exited with status 0.
+func (t jobTermination) clean() bool {
+	return !t.killed && t.err == nil
+}
+
+// reason describes why the command ended.
+func (t jobTermination) reason() string {
+	var exitErr *exec.ExitError
+	var sshExitErr *ssh.ExitError
+	var sshMissingErr *ssh.ExitMissingError
+
+	switch {
+	case t.killed && errors.Is(t.err, errKilledWithoutResponse):
+		return "killed by user, but the remote host did not confirm that the process ended"
+	case t.killed:
+		return "killed by user"
+	case t.err == nil:
+		return "completed with exit 0"
+	case errors.As(t.err, &exitErr):
+		if exitErr.ExitCode() < 0 {
+			return fmt.Sprintf("terminated (%v)", exitErr)
+		}
+		return fmt.Sprintf("completed with exit %d", exitErr.ExitCode())
+	case errors.As(t.err, &sshExitErr):
+		if sshExitErr.Signal() != "" {
+			return fmt.Sprintf("terminated by signal %s", sshExitErr.Signal())
+		}
+		return fmt.Sprintf("completed with exit %d", sshExitErr.ExitStatus())
+	case errors.As(t.err, &sshMissingErr), errors.Is(t.err, io.EOF):
+		return "connection lost"
+	}
+	return fmt.Sprintf("ended with error: %v", t.err)
+}
+
+// reported returns true if a footer is written for the job when it ended at end. Commands that
+// succeed only get one if they ran for longer than the command-footer-after setting, unless the
+// command-footer-always setting is on.
+func (t jobTermination) reported(end time.Time) bool {
+	if !t.clean() || settings.General.CommandFooterAlways {
+		return true
+	}
+	after := settings.General.CommandFooterAfter
+	return after > 0 && end.Sub(t.started) >= time.Duration(after)*time.Second
+}
+
+// footer returns the line written after the output of the job named name when it ended at end.
+func (t jobTermination) footer(name string, end time.Time) string {
+	return fmt.Sprintf("[%s: %s after %s]\n", name, t.reason(), formatJobDuration(end.Sub(t.started)))
+}
+
+func formatJobDuration(d time.Duration) string {
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	return d.Round(100 * time.Millisecond).String()
+}
+
+// jobKillTimeout is how long a killed job has to end before it is removed from the editor anyway.
+var jobKillTimeout = 5 * time.Second
+
+// JobCleaner is implemented by jobs that hold resources that must be released when they end.
+type JobCleaner interface {
+	// Cleanup is called once, on the editor goroutine, after the job is removed from the editor.
+	Cleanup()
+}
+
+// killJob kills job, and removes it from the editor if it is still running after jobKillTimeout.
+// This makes sure that a killed job is cleaned up even if it stopped sending work.
+func (e *Editor) killJob(job Job) {
+	job.Kill()
+
+	if e.killTimers == nil {
+		e.killTimers = map[Job]*time.Timer{}
+	}
+	if _, ok := e.killTimers[job]; ok {
+		return
+	}
+
+	timeout := jobKillTimeout
+	e.killTimers[job] = time.AfterFunc(timeout, func() {
+		e.WorkChan() <- basicWork{func() {
+			if _, ok := e.killTimers[job]; !ok {
+				return
+			}
+			e.AppendError("", fmt.Sprintf("[%s: killed by user, but it did not stop within %s]", job.Name(), timeout))
+			e.RemoveJob(job)
+		}}
+	})
+}
+
+// stopKillTimer stops the timer started when job was killed, if there is one.
+func (e *Editor) stopKillTimer(job Job) {
+	if t, ok := e.killTimers[job]; ok {
+		t.Stop()
+		delete(e.killTimers, job)
+	}
+}
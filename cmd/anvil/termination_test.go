@@ -0,0 +1,336 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func TestJobTerminationReason(t *testing.T) {
+	exitErr := exec.Command("sh", "-c", "exit 3").Run()
+	if _, ok := exitErr.(*exec.ExitError); !ok {
+		t.Fatalf("expected an exit error but got %v", exitErr)
+	}
+
+	tests := []struct {
+		name   string
+		t      jobTermination
+		reason string
+		clean  bool
+	}{
+		{"exit 0", jobTermination{}, "completed with exit 0", true},
+		{"exit 3", jobTermination{err: exitErr}, "completed with exit 3", false},
+		{"killed", jobTermination{err: exitErr, killed: true}, "killed by user", false},
+		{"killed remote", jobTermination{err: errKilledWithoutResponse, killed: true}, "killed by user, but the remote host did not confirm that the process ended", false},
+		{"connection lost", jobTermination{err: &ssh.ExitMissingError{}}, "connection lost", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if !isTerminationError(tc.t.err) && tc.t.err != nil {
+				t.Fatalf("expected %v to be a termination error", tc.t.err)
+			}
+			if r := tc.t.reason(); r != tc.reason {
+				t.Fatalf("expected %q but got %q", tc.reason, r)
+			}
+			if tc.t.clean() != tc.clean {
+				t.Fatalf("expected clean to be %v", tc.clean)
+			}
+		})
+	}
+}
+
+// runCommandForTermination runs command in dir and returns the body of the errors window for dir
+// once it contains a footer.
+func runCommandForTermination(t *testing.T, dir, command string, whileRunning func()) string {
+	onMainGoroutine(func() {
+		NewCommandExecutor(nil).tryOsCmd(&CmdContext{Dir: dir}, command)
+	})
+
+	if whileRunning != nil {
+		whileRunning()
+	}
+
+	footer := regexp.MustCompile(`\[.*: .* after .*\]\n$`)
+	var out string
+	for i := 0; i < 150; i++ {
+		onMainGoroutine(func() {
+			if w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(dir)); w != nil {
+				out = w.Body.String()
+			}
+		})
+		if footer.MatchString(out) {
+			return out
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected the output to end with a footer but it is %q", out)
+	return ""
+}
+
+func TestCommandOutputEndsWithFooter(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	out := runCommandForTermination(t, dir, "printf partial; exit 2", nil)
+
+	if !strings.HasPrefix(out, "partial\n[printf partial; exit 2: completed with exit 2 after ") {
+		t.Fatalf("unexpected output %q", out)
+	}
+	if strings.Contains(out, "exit status") {
+		t.Fatalf("expected the exit status only in the footer but got %q", out)
+	}
+}
+
+func TestFailedCommandFlashesErrorsTagOnlyWithCue(t *testing.T) {
+	startHeadlessEditor(t)
+
+	flashedAfterFailure := func(dir string) (flashed bool) {
+		runCommandForTermination(t, dir, "exit 1", nil)
+		for i := 0; i < 50 && !flashed; i++ {
+			onMainGoroutine(func() {
+				w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(dir))
+				flashed = w.Tag.flashed || w.Tag.flash
+			})
+			time.Sleep(10 * time.Millisecond)
+		}
+		return
+	}
+
+	setCueSettingsForTest(t, CueSettings{})
+	if flashedAfterFailure(t.TempDir()) {
+		t.Fatalf("expected the tag of the +Errors window not to flash when the job-failed cue is off")
+	}
+
+	settings.Cues.JobFailed = CueEventSettings{Enabled: true, Flash: true}
+	if !flashedAfterFailure(t.TempDir()) {
+		t.Fatalf("expected the job-failed cue to flash the tag of the +Errors window")
+	}
+}
+
+func TestFooterOnlyForFailedOrSlowCommands(t *testing.T) {
+	startHeadlessEditor(t)
+
+	saved := settings.General
+	t.Cleanup(func() { settings.General = saved })
+	settings.General.CommandFooterAfter = 10
+	settings.General.CommandFooterAlways = false
+
+	dir := t.TempDir()
+	onMainGoroutine(func() {
+		NewCommandExecutor(nil).tryOsCmd(&CmdContext{Dir: dir}, "echo quiet")
+	})
+	for i := 0; i < 150; i++ {
+		var n int
+		onMainGoroutine(func() {
+			n = len(editor.Jobs())
+		})
+		if n == 0 {
+			break
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	var out string
+	onMainGoroutine(func() {
+		if w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(dir)); w != nil {
+			out = w.Body.String()
+		}
+	})
+	if out != "quiet\n" {
+		t.Fatalf("expected no footer after a command that succeeded quickly but got %q", out)
+	}
+
+	settings.General.CommandFooterAlways = true
+	out = runCommandForTermination(t, dir, "echo loud", nil)
+	if !strings.HasPrefix(out, "quiet\nloud\n[echo loud: completed with exit 0 after ") {
+		t.Fatalf("unexpected output %q", out)
+	}
+
+	start := time.Now()
+	slow := jobTermination{started: start.Add(-11 * time.Second)}
+	settings.General.CommandFooterAlways = false
+	if !slow.reported(start) || (jobTermination{started: start}).reported(start) {
+		t.Fatalf("expected only the command that ran for longer than command-footer-after to be reported")
+	}
+}
+
+func TestKilledCommandReportsKillAndLeavesNoGoroutines(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	before := runtime.NumGoroutine()
+
+	out := runCommandForTermination(t, dir, "echo started; sleep 30", func() {
+		// Kill the job once it has written some output.
+		for i := 0; i < 50; i++ {
+			var started bool
+			onMainGoroutine(func() {
+				w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(dir))
+				started = w != nil && strings.Contains(w.Body.String(), "started")
+			})
+			if started {
+				break
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		onMainGoroutine(func() {
+			editor.KillJob("echo started; sleep 30")
+		})
+	})
+
+	if !strings.Contains(out, "started\n[echo started; sleep 30: killed by user after ") {
+		t.Fatalf("unexpected output %q", out)
+	}
+
+	onMainGoroutine(func() {
+		if len(editor.Jobs()) != 0 {
+			t.Errorf("expected the job to be removed but there are %d jobs", len(editor.Jobs()))
+		}
+	})
+
+	var after int
+	for i := 0; i < 100; i++ {
+		after = runtime.NumGoroutine()
+		if after <= before {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("expected at most %d goroutines after the job ended but there are %d", before, after)
+}
+
+func TestKillingJobBeforeItStartsEndsIt(t *testing.T) {
+	startHeadlessEditor(t)
+
+	dir := t.TempDir()
+	out := runCommandForTermination(t, dir, "sleep 30", func() {
+		onMainGoroutine(func() {
+			editor.KillJob("")
+		})
+	})
+
+	if !strings.HasPrefix(out, "[sleep 30: killed by user after ") {
+		t.Fatalf("unexpected output %q", out)
+	}
+}
+
+type abandonedJob struct {
+	killed   int
+	cleanups int
+}
+
+func (j *abandonedJob) Kill() {
+	j.killed++
+}
+
+func (j *abandonedJob) Name() string {
+	return "Abandoned"
+}
+
+func (j *abandonedJob) Cleanup() {
+	j.cleanups++
+}
+
+func TestKilledJobThatNeverEndsIsCleanedUp(t *testing.T) {
+	startHeadlessEditor(t)
+
+	saved := jobKillTimeout
+	jobKillTimeout = 50 * time.Millisecond
+	t.Cleanup(func() { jobKillTimeout = saved })
+
+	job := &abandonedJob{}
+	onMainGoroutine(func() {
+		editor.AddJob(job)
+		editor.KillJob("Abandoned")
+		editor.KillJob("Abandoned")
+	})
+
+	var removed bool
+	for i := 0; i < 50 && !removed; i++ {
+		time.Sleep(20 * time.Millisecond)
+		onMainGoroutine(func() {
+			removed = len(editor.Jobs()) == 0
+		})
+	}
+	if !removed {
+		t.Fatalf("expected the killed job to be removed")
+	}
+
+	onMainGoroutine(func() {
+		// The job's last work arriving late doesn't clean it up again.
+		handleWork(jobDone{job: job})
+
+		if job.killed != 2 || job.cleanups != 1 {
+			t.Errorf("expected 2 kills and 1 cleanup but got %d and %d", job.killed, job.cleanups)
+		}
+		w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		if w == nil || !strings.Contains(w.Body.String(), "[Abandoned: killed by user, but it did not stop within 50ms]") {
+			t.Errorf("expected the job to be reported as not stopping")
+		}
+	})
+}
+
+func TestJobThatEndsAfterKillIsCleanedUpOnce(t *testing.T) {
+	startHeadlessEditor(t)
+
+	job := &abandonedJob{}
+	onMainGoroutine(func() {
+		editor.AddJob(job)
+		editor.KillJob("Abandoned")
+		handleWork(jobDone{job: job})
+
+		if job.cleanups != 1 {
+			t.Errorf("expected 1 cleanup but got %d", job.cleanups)
+		}
+		if _, ok := editor.killTimers[job]; ok {
+			t.Errorf("expected the kill timer to be stopped")
+		}
+	})
+}
+
+func TestKillJobById(t *testing.T) {
+	startHeadlessEditor(t)
+
+	jobs := []*abandonedJob{{}, {}, {}}
+	onMainGoroutine(func() {
+		for _, j := range jobs {
+			editor.AddJob(j)
+		}
+		t.Cleanup(func() {
+			onMainGoroutine(func() {
+				for _, j := range jobs {
+					editor.RemoveJob(j)
+				}
+			})
+		})
+
+		id := editor.JobId(jobs[1])
+		if id == 0 || id != editor.JobId(jobs[0])+1 || editor.JobId(jobs[2]) != id+1 {
+			t.Fatalf("expected the jobs to have increasing ids but they are %d, %d and %d",
+				editor.JobId(jobs[0]), id, editor.JobId(jobs[2]))
+		}
+
+		NewCommandExecutor(editor).Do("Jobs", &CmdContext{})
+		w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		if w == nil || !strings.Contains(w.Body.String(), fmt.Sprintf("#%d\tAbandoned\t", id)) {
+			t.Fatalf("expected Jobs to list the job with id %d", id)
+		}
+
+		NewCommandExecutor(editor).Do(fmt.Sprintf("Kill #%d", id), &CmdContext{})
+		if jobs[0].killed != 0 || jobs[1].killed != 1 || jobs[2].killed != 0 {
+			t.Fatalf("expected only the second job to be killed but the kills are %d, %d and %d",
+				jobs[0].killed, jobs[1].killed, jobs[2].killed)
+		}
+
+		editor.KillJob("Abandoned")
+		if jobs[0].killed != 1 {
+			t.Fatalf("expected killing by name to kill the first job with the name")
+		}
+	})
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"bytes"
 	"image"
+	"unicode"
 
 	"gioui.org/layout"
 	"gioui.org/op"
@@ -35,6 +36,7 @@ type TextRenderer struct {
 	tabStopInterval          int
 	shaper                   *text.Shaper
 	cachedTextColumnLayouter cachedTextColumnLayouter
+	redact                   bool
 }
 
 type TextShapers map[text.FontFace]*text.Shaper
@@ -84,6 +86,12 @@ func (tr *TextRenderer) SetTabStopInterval(i int) {
 	tr.tabStopInterval = i
 }
 
+// SetRedact sets whether text is drawn as solid blocks rather than as glyphs, so that it takes
+// the same space but can't be read.
+func (tr *TextRenderer) SetRedact(b bool) {
+	tr.redact = b
+}
+
 func (tr *TextRenderer) DrawTextline(gtx layout.Context, line *typeset.Line) {
 	tr.drawTextBackground(gtx, line)
 	tr.drawTextForeground(gtx, line)
@@ -104,6 +112,11 @@ func (tr *TextRenderer) DrawTextBgRect(gtx layout.Context, width int) {
 }
 
 func (tr *TextRenderer) drawTextForeground(gtx layout.Context, line *typeset.Line) {
+	if tr.redact {
+		tr.drawRedactedForeground(gtx, line)
+		return
+	}
+
 	ascent := line.Ascent().Round()
 	paint.ColorOp{Color: color.NRGBA(tr.fgColor)}.Add(gtx.Ops)
 
@@ -120,6 +133,27 @@ func (tr *TextRenderer) drawTextForeground(gtx layout.Context, line *typeset.Lin
 	stack.Pop()
 }
 
+// drawRedactedForeground draws a block over each glyph in the line that is not whitespace.
+func (tr *TextRenderer) drawRedactedForeground(gtx layout.Context, line *typeset.Line) {
+	paint.ColorOp{Color: color.NRGBA(tr.fgColor)}.Add(gtx.Ops)
+
+	h := tr.lineHeight()
+	runes := line.Runes()
+	var x fixed.Int26_6
+	ri := 0
+	for _, g := range line.Glyphs() {
+		isSpace := ri < len(runes) && unicode.IsSpace(runes[ri])
+		ri += int(g.Runes)
+		if !isSpace && g.Advance > 0 {
+			r := image.Rect(x.Round(), h/5, (x + g.Advance).Round(), h*4/5)
+			stack := clip.Rect(r).Push(gtx.Ops)
+			paint.PaintOp{}.Add(gtx.Ops)
+			stack.Pop()
+		}
+		x += g.Advance
+	}
+}
+
 func (tr *TextRenderer) shape(line *typeset.Line) clip.PathSpec {
 	return tr.shaper.Shape(line.Glyphs())
 }
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jeffwilliams/anvil/internal/linediff"
+	"github.com/jeffwilliams/anvil/internal/runes"
+)
+
+func (c CommandExecutor) CmdTidy(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Tidy: must be executed in a window")
+		return
+	}
+
+	if len(ctx.Args) == 1 {
+		switch ctx.Args[0] {
+		case "off":
+			w.tidyOff = true
+			return
+		case "on":
+			w.tidyOff = false
+			return
+		}
+	}
+
+	if len(ctx.Args) > 0 {
+		w.tidyCmd = strings.Join(ctx.Args, " ")
+		w.tidyOff = false
+	}
+
+	cmd := w.formatter()
+	if cmd == "" {
+		editor.AppendError("", fmt.Sprintf("Tidy: there is no formatter for %s. Set one with Tidy <command> or in the tidy settings.", w.file))
+		return
+	}
+	w.tidy(cmd, "Tidy", nil)
+}
+
+// formatter returns the formatter command for the window: the one set with Tidy, or else the
+// one for the extension of its file in the tidy settings. It returns "" if there is none.
+func (w *Window) formatter() string {
+	if w.tidyCmd != "" {
+		return w.tidyCmd
+	}
+	ext := filepath.Ext(w.file)
+	if ext == "" {
+		return ""
+	}
+	if cmd, ok := settings.Tidy[ext]; ok {
+		return cmd
+	}
+	return settings.Tidy[ext[1:]]
+}
+
+// tidyCommand returns the formatter that Put runs on the body before saving it, or "" if the
+// body should be saved as it is.
+func (w *Window) tidyCommand() string {
+	if w.tidyOff || w.fileType != typeFile || w.file == "" || isArchiveMemberPath(w.file) {
+		return ""
+	}
+	return w.formatter()
+}
+
+// tidy pipes the body of the window through the formatter cmd, which is run in the directory of
+// the window and so on the remote host for remote windows. If the formatter succeeds the body
+// is replaced by its output. Otherwise what the formatter wrote to stderr is appended to
+// +Errors. Either way then, if it is not nil, is called afterwards. purpose prefixes the
+// messages about the formatter.
+func (w *Window) tidy(cmd, purpose string, then func()) {
+	finish := func(err error) {
+		editor.AppendError("", fmt.Sprintf("%s: %v", purpose, err))
+		if then != nil {
+			then()
+		}
+	}
+
+	dir, err := NewFileFinder(w).WindowDir()
+	if err != nil {
+		finish(err)
+		return
+	}
+
+	sfs, err := GetFs(dir)
+	if err != nil {
+		finish(err)
+		return
+	}
+
+	stdin := w.Body.Bytes()
+	if stdin == nil {
+		// A nil stdin would leave the formatter reading the stdin of Anvil.
+		stdin = []byte{}
+	}
+
+	load := NewDataLoad()
+	stderr := make(chan []byte)
+	ec := execCtx{
+		dir:      dir,
+		cmd:      cmd,
+		stdin:    stdin,
+		contents: load.Contents,
+		errs:     load.Errs,
+		kill:     load.Kill,
+		stderr:   stderr,
+	}
+	CommandExecutor{source: w}.setExtraEnv(&CmdContext{Dir: dir}, &ec)
+
+	err = sfs.execAsync(ec)
+	if err != nil {
+		finish(err)
+		return
+	}
+
+	job := &tidyJob{name: fmt.Sprintf("%s %s", cmd, filepath.Base(w.file)), kill: load.Kill}
+	editor.AddJob(job)
+
+	done := &tidyDoneWork{
+		job:        job,
+		win:        w,
+		cmd:        cmd,
+		purpose:    purpose,
+		input:      stdin,
+		generation: w.Body.generation,
+		then:       then,
+	}
+	go func() {
+		defer func() { editor.WorkChan() <- done }()
+		done.output, done.stderr, done.err = collectTidyOutput(load, stderr, job)
+	}()
+}
+
+// collectTidyOutput reads the output of a formatter. If the formatter runs for longer than the
+// tidy-timeout setting it is killed, and the rest of its output is discarded.
+func collectTidyOutput(load *DataLoad, stderr chan []byte, job Job) (output, errOutput []byte, err error) {
+	var timeout <-chan time.Time
+	if settings.General.TidyTimeout > 0 {
+		t := time.NewTimer(time.Duration(settings.General.TidyTimeout) * time.Second)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	var out, errOut bytes.Buffer
+	contents, errs := load.Contents, load.Errs
+	for contents != nil || stderr != nil || errs != nil {
+		select {
+		case b, ok := <-contents:
+			if !ok {
+				contents = nil
+				continue
+			}
+			out.Write(b)
+		case b, ok := <-stderr:
+			if !ok {
+				stderr = nil
+				continue
+			}
+			errOut.Write(b)
+		case e, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			if e != nil && e != io.EOF && err == nil {
+				err = e
+			}
+		case <-timeout:
+			job.Kill()
+			// Don't wait for a formatter that ignores being killed; drain what is left so the
+			// goroutines copying its output can finish.
+			go drainTidyOutput(contents, stderr, errs)
+			return nil, errOut.Bytes(), fmt.Errorf("timed out after %d seconds", settings.General.TidyTimeout)
+		}
+	}
+	return out.Bytes(), errOut.Bytes(), err
+}
+
+func drainTidyOutput(contents, stderr chan []byte, errs chan error) {
+	for contents != nil || stderr != nil || errs != nil {
+		select {
+		case _, ok := <-contents:
+			if !ok {
+				contents = nil
+			}
+		case _, ok := <-stderr:
+			if !ok {
+				stderr = nil
+			}
+		case _, ok := <-errs:
+			if !ok {
+				errs = nil
+			}
+		}
+	}
+}
+
+type tidyJob struct {
+	name string
+	kill chan struct{}
+}
+
+func (j *tidyJob) Name() string {
+	return j.name
+}
+
+func (j *tidyJob) Kill() {
+	select {
+	case j.kill <- struct{}{}:
+	default:
+	}
+}
+
+// tidyDoneWork replaces the body of the window with the output of the formatter once it has
+// finished.
+type tidyDoneWork struct {
+	job     Job
+	win     *Window
+	cmd     string
+	purpose string
+	input   []byte
+	// generation is the generation of the body when the formatter was started. The body is not
+	// replaced if it was changed while the formatter ran.
+	generation int
+	output     []byte
+	stderr     []byte
+	err        error
+	then       func()
+}
+
+func (w *tidyDoneWork) Service() (done bool) {
+	w.apply()
+	if w.then != nil {
+		w.then()
+	}
+	return true
+}
+
+func (w *tidyDoneWork) apply() {
+	body := &w.win.Body.editable
+
+	if w.err != nil {
+		msg := fmt.Sprintf("%s: formatting %s with '%s' failed: %v", w.purpose, w.win.file, w.cmd, w.err)
+		if len(w.stderr) > 0 {
+			msg = fmt.Sprintf("%s\n%s", msg, bytes.TrimRight(w.stderr, "\n"))
+		}
+		editor.AppendError("", msg)
+		return
+	}
+
+	if body.generation != w.generation {
+		editor.AppendError("", fmt.Sprintf("%s: %s changed while '%s' was running, so it was not formatted", w.purpose, w.win.file, w.cmd))
+		return
+	}
+
+	if body.writeLock.isLocked() || body.immutableRange.Len() != 0 {
+		editor.AppendError("", fmt.Sprintf("%s: %s is locked, so it was not formatted", w.purpose, w.win.file))
+		return
+	}
+
+	if reason := w.win.bodyModificationRefusedReason(); reason != "" {
+		editor.AppendError("", fmt.Sprintf("%s: %s was not formatted: %s", w.purpose, w.win.file, reason))
+		return
+	}
+
+	diffs := linediff.Diff(w.input, w.output, maxReloadDiffChanges)
+	if len(diffs) == 0 {
+		if w.then == nil {
+			editor.AppendError("", fmt.Sprintf("%s: '%s' made no changes to %s", w.purpose, w.cmd, w.win.file))
+		}
+		return
+	}
+
+	if !w.win.editBodyByDiffs(w.input, diffs) {
+		w.win.Body.replaceKeepingLineCols(w.output)
+	}
+
+	if w.then == nil {
+		editor.AppendError("", fmt.Sprintf("%s: '%s' changed %s at %s", w.purpose, w.cmd, w.win.file, describeDiffLines(w.input, diffs)))
+	}
+}
+
+func (w *tidyDoneWork) Job() Job {
+	return w.job
+}
+
+// replaceKeepingLineCols replaces the text with text as one change for Undo, keeping the cursors
+// at the same lines and columns and the same line at the top.
+func (b *Body) replaceKeepingLineCols(text []byte) {
+	old := b.Bytes()
+	cursors := runes.LineColsOfRunePositions(old, b.CursorIndices)
+	top := runes.LineColsOfRunePositions(old, []int{b.TopLeftIndex})[0]
+
+	if !b.replaceText(text) {
+		return
+	}
+
+	b.SetCursorIndices(runes.RunePositionsOfLineCols(text, cursors))
+	b.SetTopLeft(runes.RunePositionsOfLineCols(text, []runes.LineCol{{Line: top.Line, Col: 1}})[0])
+}
+
+// describeDiffLines lists the lines of old that diffs change, like "line 3, lines 10-12".
+func describeDiffLines(old []byte, diffs []linediff.Edit) string {
+	var parts []string
+	line, pos := 1, 0
+	for _, d := range diffs {
+		line += bytes.Count(old[pos:d.Start], []byte("\n"))
+		pos = d.Start
+		last := line + bytes.Count(old[d.Start:d.End], []byte("\n")) - 1
+		if last <= line {
+			parts = append(parts, fmt.Sprintf("line %d", line))
+		} else {
+			parts = append(parts, fmt.Sprintf("lines %d-%d", line, last))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
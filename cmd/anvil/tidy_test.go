@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPutRunsFormatter(t *testing.T) {
+	startHeadlessEditor(t)
+
+	path := filepath.Join(t.TempDir(), "notes.up")
+	if err := os.WriteFile(path, []byte("one\ntwo\n"), 0644); err != nil {
+		t.Fatalf("writing the file failed: %v", err)
+	}
+	settings.Tidy = map[string]string{".up": "tr a-z A-Z"}
+	defer func() { settings.Tidy = nil }()
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.Cols[0].NewWindow()
+		win.LoadFile(path)
+	})
+	waitForBody(t, win, "one\ntwo\n")
+
+	onMainGoroutine(func() {
+		win.Body.insertToPieceTable(win.Body.Len(), "three\n")
+		win.Body.SetCursorIndices([]int{5})
+		win.Put()
+	})
+	waitForBody(t, win, "ONE\nTWO\nTHREE\n")
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		b, _ := os.ReadFile(path)
+		if string(b) == "ONE\nTWO\nTHREE\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the formatted body to be saved but the file holds %q", b)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	onMainGoroutine(func() {
+		if win.Body.firstCursorIndex() != 5 {
+			t.Fatalf("expected the cursor to stay at line 2 column 2 but it is at %d", win.Body.firstCursorIndex())
+		}
+		win.Body.text.Undo()
+		if win.Body.String() != "one\ntwo\nthree\n" {
+			t.Fatalf("expected one undo to reverse the formatting but the body is %q", win.Body.String())
+		}
+
+		NewCommandExecutor(win).Do("Tidy off", &CmdContext{})
+		if win.tidyCommand() != "" {
+			t.Fatalf("expected Tidy off to disable the formatter")
+		}
+		NewCommandExecutor(win).Do("Tidy sh -c 'echo bad >&2; exit 1'", &CmdContext{})
+	})
+
+	var errs string
+	for i := 0; i < 250 && !strings.Contains(errs, "bad"); i++ {
+		time.Sleep(20 * time.Millisecond)
+		onMainGoroutine(func() {
+			if w, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf("")); w != nil {
+				errs = w.Body.String()
+			}
+		})
+	}
+	if !strings.Contains(errs, "failed") || !strings.Contains(errs, "bad") {
+		t.Fatalf("expected the failure and stderr of the formatter in +Errors but got %q", errs)
+	}
+	onMainGoroutine(func() {
+		if win.Body.String() != "one\ntwo\nthree\n" {
+			t.Fatalf("expected a failed formatter not to change the body but it is %q", win.Body.String())
+		}
+	})
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	_ "embed"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+)
+
+// tutorialToml holds the lessons of the tutorial. Lessons are added by editing the file; the
+// driver below doesn't know about any particular lesson.
+//
+//go:embed tutorial.toml
+var tutorialToml []byte
+
+type tutorialLesson struct {
+	Name  string        `toml:"name"`
+	Text  string        `toml:"text"`
+	Check tutorialCheck `toml:"check"`
+}
+
+// tutorialCheck decides when a lesson is done. Only one of Exec and Opened may be set; if neither
+// is, the lesson is done when the body of the lesson window contains all of Contains and none of
+// Absent.
+type tutorialCheck struct {
+	Exec     string   `toml:"exec"`
+	Opened   string   `toml:"opened"`
+	Contains []string `toml:"contains"`
+	Absent   []string `toml:"absent"`
+}
+
+// parseTutorialLessons parses the lessons and replaces $CONFDIR in them.
+func parseTutorialLessons(data []byte) (lessons []tutorialLesson, err error) {
+	var l struct {
+		Lesson []tutorialLesson `toml:"lesson"`
+	}
+	err = toml.Unmarshal(data, &l)
+	if err != nil {
+		return
+	}
+	if len(l.Lesson) == 0 {
+		err = fmt.Errorf("there are no lessons")
+		return
+	}
+
+	expand := func(s string) string {
+		return strings.ReplaceAll(s, "$CONFDIR", ConfDir)
+	}
+	for i := range l.Lesson {
+		c := &l.Lesson[i].Check
+		if c.Exec != "" && c.Opened != "" {
+			err = fmt.Errorf("lesson %d checks both exec and opened", i+1)
+			return
+		}
+		l.Lesson[i].Text = expand(l.Lesson[i].Text)
+		c.Opened = expand(c.Opened)
+	}
+	lessons = l.Lesson
+	return
+}
+
+// done returns true if the notification n completes the lesson shown in win.
+func (c tutorialCheck) done(win *Window, n ApiNotification) bool {
+	switch {
+	case c.Exec != "":
+		return n.Op == ApiNotificationOpExec && n.WinId == win.Id && len(n.Cmd) > 0 && n.Cmd[0] == c.Exec
+	case c.Opened != "":
+		if n.Op != ApiNotificationOpFileOpened {
+			return false
+		}
+		w := editor.FindWindowForId(n.WinId)
+		return w != nil && editor.windowFilesAreSame(w.file, c.Opened)
+	}
+
+	if n.WinId != win.Id || (n.Op != ApiNotificationOpInsert && n.Op != ApiNotificationOpDelete) {
+		return false
+	}
+	body := win.Body.String()
+	for _, s := range c.Contains {
+		if !strings.Contains(body, s) {
+			return false
+		}
+	}
+	for _, s := range c.Absent {
+		if strings.Contains(body, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// tutorial shows the lessons one at a time, each in a new window, and watches the API
+// notifications to decide when the lesson being shown is done.
+type tutorial struct {
+	lessons []tutorialLesson
+	// current is the index of the lesson being shown.
+	current       int
+	win           *Window
+	stopObserving func()
+}
+
+// runningTutorial is the tutorial being shown, if any. It is only used from the main goroutine.
+var runningTutorial *tutorial
+
+func tutorialWindowFile() string {
+	return fmt.Sprintf("%s/+Tutorial", ConfDir)
+}
+
+// startTutorial shows the lesson with index first. If the tutorial is already running its
+// lesson window is replaced.
+func startTutorial(lessons []tutorialLesson, first int) {
+	t := runningTutorial
+	if t == nil {
+		t = &tutorial{}
+		t.stopObserving = observeApiNotifications(t.notified)
+		runningTutorial = t
+	}
+	t.lessons = lessons
+	t.show(first)
+}
+
+func (t *tutorial) show(i int) {
+	old := t.win
+	var col *Col
+	if old != nil {
+		col = old.col
+	}
+
+	w := editor.NewWindow(col)
+	if w == nil {
+		editor.AppendError("", "Tutorial: there is no column to show the lesson in")
+		t.stop()
+		return
+	}
+	w.SetFilenameAndTag(tutorialWindowFile(), typeUnknown)
+	w.Body.SetText([]byte(t.lessonText(i)))
+	w.GrowIfBodyTooSmall()
+	editor.notifyFileOpened(w)
+
+	t.win = w
+	t.current = i
+	if old != nil {
+		editor.DelWindow(old)
+	}
+	saveTutorialProgress(i + 1)
+}
+
+func (t *tutorial) lessonText(i int) string {
+	l := t.lessons[i]
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "Lesson %d of %d: %s\n\n", i+1, len(t.lessons), l.Name)
+	buf.WriteString(l.Text)
+	buf.WriteString("\nThe next lesson starts as soon as this one is done. Middle-click ◊Tutorial stop◊ to stop the tutorial")
+	if i+1 < len(t.lessons) {
+		fmt.Fprintf(&buf, " or ◊Tutorial %d◊ to skip this lesson", i+2)
+	}
+	buf.WriteString(".\n")
+	return buf.String()
+}
+
+func (t *tutorial) notified(n ApiNotification) {
+	if t.win == nil {
+		return
+	}
+
+	if n.Op == ApiNotificationOpFileClosed && n.WinId == t.win.Id {
+		// The lesson window was deleted. The progress is kept so the tutorial can be resumed.
+		t.stop()
+		return
+	}
+
+	if !t.lessons[t.current].Check.done(t.win, n) {
+		return
+	}
+
+	if t.current+1 < len(t.lessons) {
+		t.show(t.current + 1)
+		return
+	}
+
+	editor.DelWindow(t.win)
+	t.stop()
+	removeTutorialProgress()
+	editor.AppendError("", "You have finished the tutorial. Middle-click ◊Help◊ to read about the other commands, or ◊Tutorial restart◊ to go through the lessons again.")
+}
+
+// stop stops watching for the lessons to be done. The lesson window is left open.
+func (t *tutorial) stop() {
+	t.stopObserving()
+	t.win = nil
+	if runningTutorial == t {
+		runningTutorial = nil
+	}
+}
+
+// loadTutorialProgress returns the number of the lesson that was started last, or 0 if none
+// was.
+func loadTutorialProgress() int {
+	b, err := os.ReadFile(TutorialProgressFile())
+	if err != nil {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+func saveTutorialProgress(lesson int) {
+	err := os.MkdirAll(ConfDir, 0755)
+	if err == nil {
+		err = os.WriteFile(TutorialProgressFile(), []byte(fmt.Sprintf("%d\n", lesson)), 0644)
+	}
+	if err != nil {
+		log(LogCatgConf, "Saving the progress of the tutorial failed: %v\n", err)
+	}
+}
+
+func removeTutorialProgress() {
+	err := os.Remove(TutorialProgressFile())
+	if err != nil && !os.IsNotExist(err) {
+		log(LogCatgConf, "Removing the progress of the tutorial failed: %v\n", err)
+	}
+}
+
+func (c CommandExecutor) CmdTutorial(ctx *CmdContext) {
+	lessons, err := parseTutorialLessons(tutorialToml)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Tutorial: loading the lessons failed: %v", err))
+		return
+	}
+
+	first := loadTutorialProgress()
+	if len(ctx.Args) > 0 {
+		switch ctx.Args[0] {
+		case "stop":
+			if runningTutorial != nil {
+				runningTutorial.stop()
+			}
+			return
+		case "restart":
+			first = 1
+		default:
+			first, err = strconv.Atoi(ctx.Args[0])
+			if err != nil || first < 1 || first > len(lessons) {
+				editor.AppendError("", fmt.Sprintf("Tutorial: the argument must be 'stop', 'restart' or a lesson number from 1 to %d", len(lessons)))
+				return
+			}
+		}
+	}
+	if first < 1 || first > len(lessons) {
+		first = 1
+	}
+
+	startTutorial(lessons, first-1)
+}
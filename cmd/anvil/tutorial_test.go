@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func setupTutorialTest(t *testing.T) {
+	newTestEditor()
+
+	old := ConfDir
+	ConfDir = t.TempDir()
+	t.Cleanup(func() {
+		ConfDir = old
+		if runningTutorial != nil {
+			runningTutorial.stop()
+		}
+	})
+}
+
+func TestTutorialLessonsAreNotDoneWhenShown(t *testing.T) {
+	setupTutorialTest(t)
+
+	lessons, err := parseTutorialLessons(tutorialToml)
+	if err != nil {
+		t.Fatalf("parsing the lessons failed: %v", err)
+	}
+
+	for i, l := range lessons {
+		if strings.Contains(l.Text, "$CONFDIR") || strings.Contains(l.Check.Opened, "$CONFDIR") {
+			t.Errorf("lesson %s: $CONFDIR was not replaced", l.Name)
+		}
+
+		startTutorial(lessons, i)
+		win := runningTutorial.win
+		n := ApiNotification{WinId: win.Id, Op: ApiNotificationOpInsert}
+		if l.Check.done(win, n) {
+			t.Errorf("lesson %s is done as soon as it is shown", l.Name)
+		}
+	}
+}
+
+func TestTutorialAdvancesAndResumes(t *testing.T) {
+	setupTutorialTest(t)
+
+	lessons, err := parseTutorialLessons(tutorialToml)
+	if err != nil {
+		t.Fatalf("parsing the lessons failed: %v", err)
+	}
+
+	CommandExecutor{}.CmdTutorial(&CmdContext{})
+	first := runningTutorial.win
+	if !strings.HasPrefix(first.Body.String(), "Lesson 1 of") {
+		t.Fatalf("expected the first lesson but the window has %q", first.Body.String())
+	}
+
+	// Executing a different command, or the right one from another window, isn't enough.
+	notifyApiNotificationObservers(newCommandApiNotification(first.Id, "ls", nil))
+	notifyApiNotificationObservers(newCommandApiNotification(first.Id+100, lessons[0].Check.Exec, nil))
+	if runningTutorial.current != 0 {
+		t.Fatalf("expected the tutorial to still be on the first lesson")
+	}
+
+	notifyApiNotificationObservers(newCommandApiNotification(first.Id, lessons[0].Check.Exec, nil))
+	second := runningTutorial.win
+	if second == first || runningTutorial.current != 1 {
+		t.Fatalf("expected the second lesson to be shown in a new window")
+	}
+	if loadTutorialProgress() != 2 {
+		t.Fatalf("expected the progress to be saved as lesson 2 but it is %d", loadTutorialProgress())
+	}
+
+	body := second.Body.String()
+	second.Body.SetText([]byte(body + strings.Join(lessons[1].Check.Contains, "\n")))
+	third := runningTutorial.win
+	if runningTutorial.current != 2 {
+		t.Fatalf("expected the body change to finish the second lesson")
+	}
+
+	// Deleting the lesson window stops the tutorial and removes its observer. The windows of the
+	// earlier lessons are removed first, as they would be by the next layout.
+	third.col.removeWindowsMarkedForRemoval()
+	editor.DelWindow(third)
+	if runningTutorial != nil || len(apiNotificationObservers) != 0 {
+		t.Fatalf("expected the tutorial to stop when its window is deleted")
+	}
+
+	CommandExecutor{}.CmdTutorial(&CmdContext{})
+	if !strings.HasPrefix(runningTutorial.win.Body.String(), "Lesson 3 of") {
+		t.Fatalf("expected the tutorial to resume at lesson 3 but the window has %q", runningTutorial.win.Body.String())
+	}
+
+	CommandExecutor{}.CmdTutorial(&CmdContext{Args: []string{"stop"}})
+	if runningTutorial != nil || len(apiNotificationObservers) != 0 {
+		t.Fatalf("expected Tutorial stop to remove the observer")
+	}
+}
+
+func TestTutorialFinishes(t *testing.T) {
+	setupTutorialTest(t)
+
+	lessons, err := parseTutorialLessons([]byte(`
+[[lesson]]
+name = "One"
+text = "type one\n"
+[lesson.check]
+contains = ["one!"]
+
+[[lesson]]
+name = "Two"
+text = "delete the cat\n"
+[lesson.check]
+absent = ["cat"]
+`))
+	if err != nil {
+		t.Fatalf("parsing the lessons failed: %v", err)
+	}
+
+	startTutorial(lessons, 0)
+	win := runningTutorial.win
+	win.Body.SetText([]byte("one!"))
+
+	win = runningTutorial.win
+	if !strings.Contains(win.Body.String(), "Lesson 2 of 2: Two") {
+		t.Fatalf("expected the second lesson but the window has %q", win.Body.String())
+	}
+	if strings.Contains(win.Body.String(), "◊Tutorial 3◊") {
+		t.Fatalf("the last lesson should not offer to skip to a lesson that doesn't exist")
+	}
+
+	win.Body.SetText([]byte("delete the dog"))
+	if runningTutorial != nil || len(apiNotificationObservers) != 0 {
+		t.Fatalf("expected the tutorial to stop when the last lesson is done")
+	}
+	if _, err := os.Stat(TutorialProgressFile()); !os.IsNotExist(err) {
+		t.Fatalf("expected the progress to be removed when the tutorial is finished, got %v", err)
+	}
+}
+
+func TestParseTutorialLessonsErrors(t *testing.T) {
+	if _, err := parseTutorialLessons([]byte("")); err == nil {
+		t.Fatalf("expected an error when there are no lessons")
+	}
+
+	_, err := parseTutorialLessons([]byte("[[lesson]]\nname=\"x\"\n[lesson.check]\nexec=\"a\"\nopened=\"b\"\n"))
+	if err == nil {
+		t.Fatalf("expected an error when a lesson checks both exec and opened")
+	}
+}
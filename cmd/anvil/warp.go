@@ -0,0 +1,57 @@
+package main
+
+import (
+	"image"
+
+	"gioui.org/layout"
+)
+
+// warpPointerToBody moves the mouse pointer over the body of the window once it has been laid
+// out, if the warp-to-new-windows setting is on, so that the user can type or click in a window
+// they just opened without having to find it. Where the pointer can't be moved the keyboard focus
+// is moved to the body and the tag of the window is flashed instead.
+func (w *Window) warpPointerToBody() {
+	if !settings.Mouse.WarpToNewWindows {
+		return
+	}
+
+	if w.col != nil {
+		w.col.SetVisible(true)
+	}
+	w.Body.AddOpForNextLayout(func(gtx layout.Context) {
+		if application != nil && application.WarpPointer(w.warpPoint(gtx)) {
+			return
+		}
+		w.Body.SetFocus(gtx)
+		w.Tag.flashBriefly(cueFlashDuration)
+	})
+}
+
+// warpPoint is the position in the application window that the pointer is warped to: a little
+// inside the first line of the body.
+func (w *Window) warpPoint(gtx layout.Context) image.Point {
+	p := w.bodyOrigin
+	if w.col != nil {
+		p.X += w.col.LeftX
+		p.Y += w.col.windowsTopY
+		if w.col.ed != nil {
+			p.Y += w.col.ed.colsTopY
+		}
+	}
+	return p.Add(image.Pt(gtx.Metric.Dp(w.Body.editable.style.TextLeftPadding)+w.Body.lineHeight(), w.Body.lineHeight()/2))
+}
+
+// WarpPointer moves the mouse pointer to pt, in pixels from the top left of the application
+// window. It returns false if the pointer can't be moved on this platform.
+func (a *Application) WarpPointer(pt image.Point) bool {
+	if a.platformWin == nil {
+		return false
+	}
+
+	err := a.platformWin.warpPointer(pt)
+	if err != nil {
+		log(LogCatgApp, "Application: moving the pointer failed: %v\n", err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"image"
+	"testing"
+
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+func TestWarpFallsBackToFocusAndFlash(t *testing.T) {
+	startHeadlessEditor(t)
+	gtx := layout.Context{Ops: new(op.Ops)}
+
+	old := settings.Mouse.WarpToNewWindows
+	defer func() { settings.Mouse.WarpToNewWindows = old }()
+
+	var win *Window
+	var focused *Window
+	var flashed bool
+	onMainGoroutine(func() {
+		settings.Mouse.WarpToNewWindows = true
+		editor.Cols[0].NewWindow()
+		win = editor.Cols[0].NewWindow()
+
+		// The headless editor has no operating system window, so the pointer can't be moved.
+		win.warpPointerToBody()
+		win.Body.opsForNextLayout.Perform(gtx)
+		focused = editor.focusedWindow
+		flashed = win.Tag.flashed
+	})
+
+	if focused != win {
+		t.Fatalf("expected the window to be focused when the pointer can't be moved")
+	}
+	if !flashed {
+		t.Fatalf("expected the tag of the window to be flashed when the pointer can't be moved")
+	}
+}
+
+func TestWarpPoint(t *testing.T) {
+	startHeadlessEditor(t)
+	gtx := layout.Context{Ops: new(op.Ops)}
+
+	var pt, expected image.Point
+	onMainGoroutine(func() {
+		win := editor.Cols[0].NewWindow()
+		editor.colsTopY = 20
+		win.col.LeftX = 100
+		win.col.windowsTopY = 15
+		win.bodyOrigin = image.Pt(8, 40)
+
+		lh := win.Body.lineHeight()
+		pad := gtx.Metric.Dp(win.Body.editable.style.TextLeftPadding)
+		expected = image.Pt(100+8+pad+lh, 20+15+40+lh/2)
+		pt = win.warpPoint(gtx)
+	})
+
+	if pt != expected {
+		t.Fatalf("expected the pointer to be warped to %v but it is %v", expected, pt)
+	}
+}
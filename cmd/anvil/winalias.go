@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sharedAliases returns the command aliases defined in the window, creating them if there are
+// none, so that a clone of the window can share them.
+func (w *Window) sharedAliases() map[string]string {
+	if w.aliases == nil {
+		w.aliases = map[string]string{}
+	}
+	return w.aliases
+}
+
+// alias returns the command that the alias name stands for when executed from the source of the
+// command. The aliases defined in a window come before those in the settings.
+func (c CommandExecutor) alias(name string) (cmd string, ok bool) {
+	if w, isWin := c.source.(*Window); isWin {
+		if cmd, ok = w.aliases[name]; ok {
+			return
+		}
+	}
+	cmd, ok = settings.Alias[name]
+	return
+}
+
+func (c CommandExecutor) CmdAlias(ctx *CmdContext) {
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", c.listAliases())
+		return
+	}
+
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Alias: aliases can only be defined in a window")
+		return
+	}
+	if len(ctx.Args) < 2 {
+		editor.AppendError("", fmt.Sprintf("Alias: the command that %s stands for is required", ctx.Args[0]))
+		return
+	}
+
+	w.sharedAliases()[ctx.Args[0]] = strings.Join(ctx.Args[1:], " ")
+}
+
+func (c CommandExecutor) CmdAliasDelete(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Alias-: aliases can only be deleted in a window")
+		return
+	}
+	if len(ctx.Args) == 0 {
+		editor.AppendError("", "Alias-: the name of the alias to delete is required")
+		return
+	}
+
+	for _, name := range ctx.Args {
+		if _, ok := w.aliases[name]; !ok {
+			editor.AppendError("", fmt.Sprintf("Alias-: the window has no alias named %s", name))
+			continue
+		}
+		delete(w.aliases, name)
+	}
+}
+
+// listAliases returns the aliases defined in the source window followed by those in the
+// settings.
+func (c CommandExecutor) listAliases() string {
+	var buf strings.Builder
+	writeAliases := func(title string, aliases map[string]string) {
+		fmt.Fprintf(&buf, "%s:\n", title)
+		if len(aliases) == 0 {
+			buf.WriteString("  none\n")
+			return
+		}
+		names := make([]string, 0, len(aliases))
+		for name := range aliases {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(&buf, "  %s: %s\n", name, aliases[name])
+		}
+	}
+
+	if w, ok := c.source.(*Window); ok {
+		writeAliases("Window aliases", w.aliases)
+	}
+	writeAliases("Global aliases", settings.Alias)
+	return buf.String()
+}
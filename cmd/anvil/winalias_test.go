@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWindowAliases(t *testing.T) {
+	win := newZeroxTestWindow(t, "text\n")
+	clone, err := win.Zerox()
+	if err != nil {
+		t.Fatalf("Zerox failed: %v", err)
+	}
+
+	oldAliases := settings.Alias
+	settings.Alias = map[string]string{"t": "global", "g": "global"}
+	t.Cleanup(func() { settings.Alias = oldAliases })
+
+	exec := NewCommandExecutor(win)
+	exec.Do("Alias t go test ./... -run $1", &CmdContext{})
+	// The arguments given to an alias are substituted as for the aliases in the settings.
+	exec.Do("Alias def Alias $1 $2 $3", &CmdContext{})
+	exec.Do("def v go vet", &CmdContext{})
+
+	if cmd, ok := exec.alias("t"); !ok || cmd != "go test ./... -run $1" {
+		t.Fatalf("expected the window alias to come before the one in the settings but got %q", cmd)
+	}
+	if cmd, ok := exec.alias("g"); !ok || cmd != "global" {
+		t.Fatalf("expected the alias in the settings to be found but got %q", cmd)
+	}
+	if cmd, ok := NewCommandExecutor(clone).alias("v"); !ok || cmd != "go vet" {
+		t.Fatalf("expected the clone to share the aliases of the window but got %q", cmd)
+	}
+
+	list := exec.listAliases()
+	if !strings.Contains(list, "Window aliases:\n  def: Alias $1 $2 $3\n  t: go test ./... -run $1\n  v: go vet\n") ||
+		!strings.Contains(list, "Global aliases:\n  g: global\n  t: global\n") {
+		t.Fatalf("unexpected alias list:\n%s", list)
+	}
+
+	if state := win.State(); state.Aliases["v"] != "go vet" {
+		t.Fatalf("expected the aliases to be saved in the window state but got %v", state.Aliases)
+	}
+
+	NewCommandExecutor(clone).Do("Alias- t", &CmdContext{})
+	if cmd, _ := exec.alias("t"); cmd != "global" {
+		t.Fatalf("expected deleting the window alias to leave the one in the settings but got %q", cmd)
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"gioui.org/op/clip"
 	"gioui.org/op/paint"
 	"github.com/jeffwilliams/anvil/internal/events"
+	"github.com/jeffwilliams/anvil/internal/textenc"
 )
 
 // Window is a single window in the editor, with it's own tag and body.
@@ -38,17 +39,83 @@ type Window struct {
 	file                          string
 	fileType                      fileType
 	filler                        *FillEditableWithItemList
+	dir                           dirListing
 	initialTagUserArea            string
 	setFocusOnNextLayout          bool
 	tagShowsBodyAsChangedFromDisk bool
-	bodyDims                      layout.Dimensions
-	clones                        map[*Window]struct{}
-	allowDirtyDelete              bool
-	packingCoordChangedListeners  []func(oldVal, newVal int)
-	customEdCommands              string
-	fuzzySearch                   *FuzzySearcher
-	onlyShowBasenamesInTag        bool
-	insertWhenTabPressed          string
+	apiNotifiedDirty              bool
+	// apiSeenSelection is the primary selection of the body when it was last checked for changes,
+	// and apiNotifiedSelection is the one last sent to API clients.
+	apiSeenSelection     textRange
+	apiNotifiedSelection textRange
+	bodyDims             layout.Dimensions
+	// bodyOrigin is the position of the top left of the body within the column at the last layout.
+	bodyOrigin                   image.Point
+	clones                       map[*Window]struct{}
+	allowDirtyDelete             bool
+	packingCoordChangedListeners []func(oldVal, newVal int)
+	customEdCommands             string
+	// aliases are the command aliases defined in the window with Alias. They are shared with
+	// the clones of the window.
+	aliases map[string]string
+	// snaps are the checkpoints of the body made with Snap, by name. Like the text they are
+	// shared with the clones of the window. They aren't saved by Dump.
+	snaps                  map[string]*bodySnap
+	fuzzySearch            *FuzzySearcher
+	onlyShowBasenamesInTag bool
+	insertWhenTabPressed   string
+	// indentGuessed is true once the indentation of the file in the window was guessed, and
+	// indentGuess is the guess, or nil if the file has no indented lines.
+	indentGuessed bool
+	indentGuess   *indentGuess
+	// spills hold the output of the jobs whose output loaded into the window was too large. The
+	// body only shows part of each, in a region of its own. jobOutputs are the jobs writing to the
+	// window whose output may be spilled, and spillRegionsGen is the generation of the body that
+	// the regions of the spills and jobs are for.
+	spills          []*spill
+	jobOutputs      []*jobOutput
+	spillRegionsGen int
+	// whitespaceHintsOff hides the whitespace hints in this window even if they are enabled in the settings.
+	whitespaceHintsOff bool
+	// sensitive windows hide their body when idle. sensitiveSetByUser is true if this was set
+	// using the Sensitive command rather than by matching the sensitive-patterns setting.
+	sensitive          bool
+	sensitiveSetByUser bool
+	lastInteraction    time.Time
+	// tailingSuspended stops output appended to the window from scrolling to the end of the body.
+	tailingSuspended bool
+	// mode is set by a tool that drives the window through the API.
+	mode windowMode
+	// sendStdin is the stdin of the job most recently started with < in the window. Send writes to it.
+	sendStdin *jobStdin
+	// notWritable is true when the user can't write the file in the window. Changes to the body are
+	// refused unless editAnyway is set by the Edit-anyway command.
+	notWritable            bool
+	editAnyway             bool
+	notWritableNoticeShown bool
+	// encoding is the character encoding and line endings of the file in the window. The body is
+	// kept as UTF-8 with newline line endings and converted to encoding when it is Put. It is
+	// detected when the file is loaded unless encodingSet is true because the user set it with Enc.
+	encoding    textenc.Encoding
+	encodingSet bool
+	// tidyCmd is the formatter set for the window with Tidy. When it is empty the formatter for
+	// the extension of the file in the tidy settings is used. tidyOff disables the formatter.
+	tidyCmd string
+	tidyOff bool
+	// scrollFollow links the top of the body to that of the clone it was made from with Zerox -follow.
+	scrollFollow *scrollFollow
+	// errorsWindowFull is true once output appended to an +Errors window was dropped because the
+	// window reached errors-max-size.
+	errorsWindowFull bool
+	// errIndex is the index of the diagnostics in the body of an +Errors window used by Nexterr and
+	// Preverr. It is built the first time one of them is used in the window.
+	errIndex *errorIndex
+	// workDir is the directory commands executed from the window run in, set with Cd. When it is
+	// empty they run in the directory of the window's file.
+	workDir string
+	// tagsCompletionOff is true if word completion in the window doesn't complete from tags
+	// files, set with Tagsrc.
+	tagsCompletionOff bool
 }
 
 type fileType int
@@ -91,9 +158,15 @@ func NewWindow(row *Col, style Style) *Window {
 	w.Body.Init(style.bodyBlockStyle(), style.bodyEditableStyle(), style.Syntax, executor, finder, w, row.workChan)
 	w.layoutBox.Init(style.layoutBoxStyle())
 	w.scrollbar.Init(style.scrollbarStyle(), &w.Body)
-	w.Body.AddTextChangeListener(w.redrawClonesOnTextChange)
+	w.addScrollPipSources()
+	w.Body.AddTextChangeListener(w.updateClonesOnTextChange)
 	w.Body.AddTextChangeListener(w.disallowDirtyDelete)
 	w.Body.AddTextChangeListener(w.notifyApiBodyChanged)
+	w.Body.AddTextChangeListener(w.notifyApiDirtyOnTextChange)
+	w.Body.AddTextChangeListener(w.Body.presenterContentChanged)
+	w.Body.AddTextChangeListener(w.errorIndexTextChanged)
+	w.Body.AddTextChangeListener(w.spillRegionsTextChanged)
+	w.Body.modificationGuard = w.allowBodyModification
 	w.setupInterception()
 	w.AddPackingCoordChangeListener(w.layoutBox.WindowPackingCoordChanged)
 	w.Body.completer = editor.Completer()
@@ -149,6 +222,8 @@ func (c *Window) Layout(gtx layout.Context) layout.Dimensions {
 	//	c.Body.text.IsMarked())
 
 	c.layout.layout(gtx)
+	c.updateFollowPause()
+	c.updateScrollFollowers(gtx)
 
 	// In case the Tag's file has changed, update our file from it.
 	c.UpdateFilenameFromTag()
@@ -158,6 +233,8 @@ func (c *Window) Layout(gtx layout.Context) layout.Dimensions {
 	}
 	c.tagShowsBodyAsChangedFromDisk = c.bodyChangedFromDisk()
 
+	c.redactIfIdle(gtx)
+
 	// Window takes up all available space.
 	return layout.Dimensions{Size: gtx.Constraints.Max}
 }
@@ -166,6 +243,11 @@ func (w *Window) bodyChangedFromDisk() bool {
 	return !w.Body.text.IsMarked()
 }
 
+// IsDirty returns true if the window holds a file with changes that haven't been saved.
+func (w *Window) IsDirty() bool {
+	return w.bodyChangedFromDisk() && !IsOutputWindow(w.file) && w.fileType != typeDir
+}
+
 func (l *windowLayouter) layout(gtx layout.Context) {
 
 	l.gtx = gtx
@@ -185,6 +267,7 @@ func (l *windowLayouter) layout(gtx layout.Context) {
 	// Translate all later draw operations so they are below the tag
 	gtx.Constraints.Max.Y = gtx.Constraints.Max.Y - tagDims.Size.Y
 	op.Offset(image.Point{0, tagDims.Size.Y}).Add(gtx.Ops)
+	l.window.bodyOrigin = image.Pt(gutterDims.Size.X, l.window.TopY+tagDims.Size.Y)
 	l.window.bodyDims = l.window.Body.layout(gtx)
 
 	// Draw a line (border) at the bottom of the window
@@ -327,7 +410,7 @@ func (c *Window) SetTag() {
 	var t string
 	if c.customEdCommandsSet() {
 		t = c.customEdCommands
-	} else if c.IsErrorsWindow() {
+	} else if c.IsErrorsWindow() || c.IsFindWindow() || c.IsDiffWindow() {
 		t = c.edCommandsForErrorsWindow()
 	} else if c.fileType == typeFile {
 		t = c.edCommandsForFile()
@@ -335,6 +418,25 @@ func (c *Window) SetTag() {
 		t = c.edCommandsForDir()
 	}
 
+	if len(c.spills) > 0 {
+		t = strings.TrimSuffix(t, " |") + " Head Tail Pgup Pgdn Search Extract |"
+	} else if c.tailingSuspended {
+		t = strings.TrimSuffix(t, " |") + " Tail |"
+	}
+
+	if c.fileType == typeFile && c.notWritable {
+		// The marker goes after the commands since the filename must be followed by " Del Snarf"
+		t = strings.TrimRight(t, " |") + " " + notWritableTagMarker + c.notWritableTagCommands() + " |"
+	}
+
+	if f := c.followTagCommands(); f != "" {
+		t = strings.TrimRight(t, " |") + f + " |"
+	}
+
+	if c.Body.redacted {
+		t = " " + redactedTagMarker + t
+	}
+
 	userArea, err := c.userArea(c.file)
 
 	if err != nil {
@@ -372,10 +474,14 @@ func (c *Window) setTagToBasename() (ok bool) {
 func (c *Window) edCommandsForFile() string {
 	//log(LogCatgWin,"Window.fileTag: body marked: %v\n", c.Body.text.IsMarked())
 	put := ""
+	marker := ""
 	if !c.Body.text.IsMarked() {
 		put = "Put"
+		if settings.Layout.DirtyMarker != "" {
+			marker = " " + settings.Layout.DirtyMarker
+		}
 	}
-	return fmt.Sprintf(" Del Snarf %s |", put)
+	return fmt.Sprintf("%s Del Snarf %s |", marker, put)
 }
 
 func (c *Window) edCommandsForDir() string {
@@ -414,6 +520,7 @@ func (c *Window) userArea(path string) (string, error) {
 // contents on disk. This is used to decide whether to display the Put command.
 func (w *Window) markTextAsUnchanged() {
 	w.Body.text.Mark()
+	w.updateApiDirty()
 }
 
 func (w *Window) LoadFile(path string) error {
@@ -421,10 +528,24 @@ func (w *Window) LoadFile(path string) error {
 }
 
 func (w *Window) LoadFileAndGoto(path string, goTo seek, selectBehaviour selectBehaviour, growBodyBehaviour growBodyBehaviour) error {
+	return w.loadFileAndGoto(path, goTo, selectBehaviour, growBodyBehaviour, false)
+}
+
+// loadFileAndGoto loads the file at path into the window body. If reload is set the body
+// is replaced with the file contents as a change that can be undone once they are loaded,
+// rather than being cleared now.
+func (w *Window) loadFileAndGoto(path string, goTo seek, selectBehaviour selectBehaviour, growBodyBehaviour growBodyBehaviour, reload bool) error {
 	var ldr FileLoader
 
-	w.Body.SetTextString("")
-	w.markTextAsUnchanged()
+	w.pauseFollowingDuringLoad()
+	if !reload {
+		w.Body.SetTextString("")
+		w.markTextAsUnchanged()
+	}
+
+	if path != w.file {
+		w.encoding, w.encodingSet = textenc.Encoding{}, false
+	}
 
 	filetype := typeUnknown
 	loadData := true
@@ -435,8 +556,13 @@ func (w *Window) LoadFileAndGoto(path string, goTo seek, selectBehaviour selectB
 		if ok && errors.Is(pe, fs.ErrNotExist) {
 			filetype = typeFile
 			loadData = false
+			if reload {
+				w.reloadBody(newBodyReload(w.Body.Bytes(), w.Body.generation, nil), goTo, selectBehaviour)
+				w.markTextAsUnchanged()
+			}
 		} else {
 			log(LogCatgWin, "Window.Load: error: %T %v\n", err, err)
+			w.stopFollowingAfterFailedLoad()
 			return err
 		}
 	}
@@ -449,12 +575,24 @@ func (w *Window) LoadFileAndGoto(path string, goTo seek, selectBehaviour selectB
 			Goto:              goTo,
 			SelectBehaviour:   selectBehaviour,
 			GrowBodyBehaviour: growBodyBehaviour,
+			Reload:            reload,
+			DecodeContents:    true,
+		}
+		if reload {
+			wl.ReloadOld, wl.ReloadGeneration = w.Body.Bytes(), w.Body.generation
+		}
+		if w.encodingSet {
+			enc := w.encoding
+			wl.Encoding = &enc
 		}
 		wl.Start(editor.WorkChan())
 		editor.AddJob(wl)
+	} else {
+		w.resumeFollowingAfterLoad(0)
 	}
 
 	w.SetFilenameAndTag(path, filetype)
+	w.checkWritable()
 
 	w.RemoveUndoHistoryFromTag()
 
@@ -466,13 +604,28 @@ func (w *Window) RemoveUndoHistoryFromTag() {
 }
 
 func (w *Window) Put() error {
+	if cmd := w.tidyCommand(); cmd != "" {
+		w.tidy(cmd, "Put", func() { w.save() })
+		return nil
+	}
+	return w.save()
+}
+
+// save writes the body to the file in the window.
+func (w *Window) save() error {
 	if w.file == "" {
 		editor.AppendError("", "Can't Put: filename is empty")
 		return fmt.Errorf("Can't Put with an empty filename")
 	}
 
+	if isArchiveMemberPath(w.file) {
+		msg := fmt.Sprintf("Can't Put %s: files inside archives can't be changed. Execute Extract-to-file to save the text as a file of its own.", w.file)
+		editor.AppendError("", msg)
+		return errors.New(msg)
+	}
+
 	var ldr FileLoader
-	b := w.Body.Bytes()
+	b := textenc.Encode(w.Body.Bytes(), w.encoding)
 
 	//err := ldr.Save(w.file, b)
 	save, err := ldr.SaveAsync(w.file, b)
@@ -485,6 +638,7 @@ func (w *Window) Put() error {
 	ws := &WindowDataSave{
 		Jobname: filepath.Base(w.file),
 		Win:     w,
+		File:    w.file,
 		errs:    save.Errs,
 		kill:    save.Kill,
 	}
@@ -501,7 +655,10 @@ func (w *Window) Get() error {
 func (w *Window) GetWithSelect(selectBehaviour selectBehaviour, growBodyBehaviour growBodyBehaviour) error {
 	ci := w.Body.blockEditable.firstCursorIndex()
 
-	err := w.LoadFileAndGoto(w.file, seek{seekType: seekToRunePos, runePos: ci}, selectBehaviour, growBodyBehaviour)
+	// Reloading a file keeps the undo history, so that the text from before the Get can be
+	// restored.
+	reload := w.fileType == typeFile && w.Body.text.Len() > 0
+	err := w.loadFileAndGoto(w.file, seek{seekType: seekToRunePos, runePos: ci}, selectBehaviour, growBodyBehaviour, reload)
 	if err != nil {
 		return err
 	}
@@ -514,6 +671,8 @@ type FillEditableWithItemList struct {
 	items     []string
 	render    *TextRenderer
 	lastWidth int
+	// afterFill, if not nil, is called after the items are laid out in the editable again.
+	afterFill func()
 }
 
 func NewFillEditableWithItemList(l *layouter, style *Style, items []string) *FillEditableWithItemList {
@@ -537,6 +696,11 @@ func (f *FillEditableWithItemList) AppendItems(items []string) {
 	f.lastWidth = 0 // Force a redraw
 }
 
+func (f *FillEditableWithItemList) SetItems(items []string) {
+	f.items = items
+	f.lastWidth = 0 // Force a redraw
+}
+
 func (f *FillEditableWithItemList) preDrawHook(e *editable, gtx layout.Context) {
 	w := gtx.Constraints.Max.X
 	if w == f.lastWidth {
@@ -549,6 +713,9 @@ func (f *FillEditableWithItemList) preDrawHook(e *editable, gtx layout.Context)
 	b = append(b, '\n')
 	e.SetText(b)
 	f.lastWidth = w
+	if f.afterFill != nil {
+		f.afterFill()
+	}
 }
 
 func (c *Window) SetContents(file string, typ fileType, b []byte) {
@@ -560,7 +727,74 @@ func (c *Window) SetFilenameAndTag(file string, t fileType) {
 	c.file = c.ensureDirEndsInSlash(file, t)
 	c.setBodyCompletionSource()
 	c.fileType = t
+	c.updateWhitespaceHints()
+	c.updateSensitive()
 	c.SetTag()
+	c.updateApiDirty()
+}
+
+// SetWholeTag replaces the complete tag of the window: the path, the editor commands and the
+// user area. If the path is different the window now holds the file at that path, as if the
+// file was renamed.
+func (c *Window) SetWholeTag(tag string) error {
+	path, edArea, userArea, err := parseWholeTag(tag)
+	if err != nil {
+		return err
+	}
+
+	if path != c.file {
+		oldFile := c.file
+		t := typeFile
+		if strings.HasSuffix(path, "/") || strings.HasSuffix(path, "\\") {
+			t = typeDir
+		}
+		c.SetFilenameAndTag(path, t)
+		c.noteFileRenamed(oldFile)
+	}
+
+	// The editor commands are only kept as custom commands if they differ from those the
+	// window would show anyway, so that they still change when the window is saved.
+	c.initialTagUserArea = ""
+	c.customEdCommands = ""
+	c.SetTag()
+	if _, generated, _, err := c.Tag.Parts(); err != nil || generated != edArea {
+		c.customEdCommands = edArea
+	}
+	c.Tag.Set(c.file, edArea, userArea)
+	return nil
+}
+
+// parseWholeTag splits a complete window tag into its parts, and returns an error if it is not
+// a valid tag.
+func parseWholeTag(tag string) (path, edArea, userArea string, err error) {
+	if tag == "" {
+		err = fmt.Errorf("Tag is empty")
+		return
+	}
+
+	parts, _, err := calculateTagParts(tag)
+	if err != nil {
+		return
+	}
+
+	path = parts.path.Section(tag)
+	if strings.ContainsAny(path, "\r\n") {
+		err = fmt.Errorf("Path %q contains a newline", path)
+		return
+	}
+	edArea = parts.editorArea.Section(tag)
+	userArea = parts.userArea.Section(tag)
+	return
+}
+
+// noteFileRenamed updates what the editor keeps by file name after the file of the window was
+// changed from oldFile, and tells API clients that the window holds a new file.
+func (c *Window) noteFileRenamed(oldFile string) {
+	if oldFile != "" {
+		editor.Marks.RenameFile(oldFile, c.file)
+	}
+	editor.AddRecentFile(c.file)
+	editor.notifyFileOpened(c)
 }
 
 func (c *Window) ensureDirEndsInSlash(file string, t fileType) string {
@@ -606,17 +840,45 @@ func (c *Window) UpdateFilenameFromTag() {
 	c.setBodyCompletionSource()
 }
 
-func (c *Window) Append(b []byte) {
+// Append appends b to the body and returns the rune index it was appended at. Less than b is
+// appended to a full +Errors window.
+func (c *Window) Append(b []byte) (start int) {
+	b = c.limitErrorsWindowSize(b)
+	start = c.Body.text.Len()
+	if len(b) == 0 {
+		return
+	}
+	gen := c.Body.generation
 	c.Body.Append(b)
+	c.appendedToErrorIndex(gen)
+	c.appendedToSpillRegions(gen)
+	return
+}
+
+// deleteLastLineOfBody deletes the text after the last newline in the body. This is how a
+// carriage return in command output is shown, so that the text after it replaces the line.
+func (c *Window) deleteLastLineOfBody() {
+	b := c.Body.Bytes()
+	n := utf8.RuneCount(b[bytes.LastIndexByte(b, '\n')+1:])
+	if n == 0 {
+		return
+	}
+	c.Body.deleteFromPieceTable(c.Body.text.Len()-n, n)
 }
 
 func (c *Window) Zerox() (nw *Window, err error) {
+	return c.ZeroxInCol(nil)
+}
+
+// ZeroxInCol is like Zerox but opens the clone in col. If col is nil the column is chosen as for
+// any new window.
+func (c *Window) ZeroxInCol(col *Col) (nw *Window, err error) {
 	if c.fileType == typeDir {
 		err = fmt.Errorf("not allowed on directories\n")
 		return
 	}
 
-	nw = editor.NewWindow(nil)
+	nw = editor.NewWindow(col)
 	if nw == nil {
 		err = fmt.Errorf("failed to create window\n")
 		return
@@ -625,6 +887,11 @@ func (c *Window) Zerox() (nw *Window, err error) {
 	// The body of the new window and the current window will share the same piece table
 	nw.Body.text = c.Body.text
 
+	nw.notWritable = c.notWritable
+	nw.editAnyway = c.editAnyway
+	nw.encoding, nw.encodingSet = c.encoding, c.encodingSet
+	nw.aliases = c.sharedAliases()
+	nw.snaps = c.sharedSnaps()
 	nw.SetFilenameAndTag(c.file, c.fileType)
 
 	c.addClone(nw)
@@ -643,11 +910,24 @@ func (c *Window) BodyHeight() int {
 }
 
 func (w *Window) GrowIfBodyTooSmall() {
-	if w.BodyHeight() < w.layout.lineHeight()*9 && w.col != nil {
+	if w.bodyTooSmall() && w.col != nil {
 		w.col.Grow(w)
 	}
 }
 
+// GrowForOutputIfBodyTooSmall is like GrowIfBodyTooSmall but is meant to be used when the window
+// grows because output arrived rather than by a request from the user. The growth is delayed
+// while the user is interacting with the column.
+func (w *Window) GrowForOutputIfBodyTooSmall() {
+	if w.bodyTooSmall() && w.col != nil {
+		w.col.GrowForOutput(w)
+	}
+}
+
+func (w *Window) bodyTooSmall() bool {
+	return w.BodyHeight() < w.layout.lineHeight()*9
+}
+
 func (w *Window) addClone(c *Window) {
 	if w.clones == nil {
 		w.clones = make(map[*Window]struct{})
@@ -668,7 +948,13 @@ func (w *Window) hasClones() bool {
 	return len(w.clones) > 0
 }
 
-func (w *Window) redrawClonesOnTextChange(ch *TextChange) {
+// updateClonesOnTextChange keeps the clones of the window consistent with a change made to the
+// text through this window. The clones share the piece table with this window, so the text
+// itself is already changed; only the state of each view of the text (the top-left index,
+// cursors, selections and highlighting) has to be adjusted. It's done immediately rather than
+// at the next layout so that commands executed in a clone before it is drawn again see
+// positions that match the text.
+func (w *Window) updateClonesOnTextChange(ch *TextChange) {
 	for c := range w.clones {
 		if c == w {
 			continue
@@ -677,20 +963,27 @@ func (w *Window) redrawClonesOnTextChange(ch *TextChange) {
 		// Don't notify us.
 		c.Body.textChanged(dontFireListeners, *ch)
 
-		c.Body.AddOpForNextLayout(func(gtx layout.Context) {
-			if ch.Length != 0 {
-				log(LogCatgWin, "redrawClonesOnTextChange: changing top left index of editable from %d to %d\n", c.Body.TopLeftIndex, c.Body.TopLeftIndex+ch.Length)
-				w.shiftClonesTopLeftDueToTextModification(&c.Body, ch)
-				c.Body.shiftItemsDueToTextModification(ch.Offset, ch.Length)
-			}
-			// This is to force a redraw
-			c.Body.invalidateLayedoutText()
-		})
+		if ch.Length != 0 {
+			log(LogCatgWin, "updateClonesOnTextChange: shifting items of clone %d for change at %d of length %d\n", c.Id, ch.Offset, ch.Length)
+			w.shiftClonesTopLeftDueToTextModification(&c.Body, ch)
+			c.Body.shiftViewItemsDueToTextModification(ch.Offset, ch.Length)
+		} else {
+			// The whole text might have been replaced.
+			c.Body.clampToTextLen()
+		}
+		c.scrollFollowTopLeftShifted()
+		c.Body.invalidateLayedoutText()
 	}
 }
 
 func (w *Window) shiftClonesTopLeftDueToTextModification(cloneBody *Body, ch *TextChange) {
-	if cloneBody.TopLeftIndex >= ch.Offset {
+	ndx := cloneBody.TopLeftIndex
+	switch {
+	case ndx < ch.Offset:
+	case ch.Length < 0 && ndx < ch.Offset-ch.Length:
+		// The text at the top-left was deleted.
+		cloneBody.TopLeftIndex = ch.Offset
+	default:
 		cloneBody.TopLeftIndex += ch.Length
 	}
 }
@@ -702,6 +995,7 @@ func (w *Window) removeFromAllClones() {
 		}
 
 		c.removeClone(w)
+		c.stopFollowingScrollOf(w)
 	}
 }
 
@@ -718,6 +1012,37 @@ func (w *Window) IsErrorsWindow() bool {
 	return IsErrorsWindow(w.file)
 }
 
+// SetTailing sets whether output appended to the window scrolls the body to the end. When
+// tailing is resumed the body is scrolled to the end immediately.
+func (c *Window) SetTailing(b bool) {
+	if c.tailingSuspended == !b {
+		return
+	}
+	c.tailingSuspended = !b
+	c.SetTag()
+
+	if len(c.spills) > 0 {
+		for _, s := range c.spills {
+			if b {
+				c.showSpillTail(s)
+			} else {
+				s.following = false
+			}
+		}
+		return
+	}
+
+	if b {
+		c.Body.AddOpForNextLayout(func(gtx layout.Context) {
+			c.Body.moveToEndOfDoc(gtx)
+		})
+	}
+}
+
+func (c *Window) IsTailing() bool {
+	return !c.tailingSuspended
+}
+
 func IsErrorsWindow(windowFilename string) bool {
 	return strings.HasSuffix(windowFilename, "+Errors")
 }
@@ -730,8 +1055,24 @@ func IsLiveWindow(windowFilename string) bool {
 	return strings.HasSuffix(windowFilename, "+Live")
 }
 
+func (w *Window) IsFindWindow() bool {
+	return IsFindWindow(w.file)
+}
+
+func IsFindWindow(windowFilename string) bool {
+	return strings.HasSuffix(windowFilename, "+Find")
+}
+
+func (w *Window) IsTutorialWindow() bool {
+	return IsTutorialWindow(w.file)
+}
+
+func IsTutorialWindow(windowFilename string) bool {
+	return strings.HasSuffix(windowFilename, "+Tutorial")
+}
+
 func (w *Window) CanDelete() bool {
-	if w.IsErrorsWindow() || w.IsLiveWindow() || w.fileType == typeDir {
+	if IsOutputWindow(w.file) || w.IsLiveWindow() || w.IsFindWindow() || w.IsDiffWindow() || w.IsTutorialWindow() || w.fileType == typeDir {
 		return true
 	}
 
@@ -772,6 +1113,39 @@ func (w *Window) notifyApiBodyChanged(c *TextChange) {
 	addApiNotificationToAllSessions(n)
 }
 
+func (w *Window) notifyApiDirtyOnTextChange(c *TextChange) {
+	w.updateApiDirty()
+}
+
+// updateApiDirty notifies API clients if the window, or one of its clones which share its text,
+// went from having no unsaved changes to having some, or the reverse, since they were last told.
+func (w *Window) updateApiDirty() {
+	if w.apiNotifiedDirty != w.IsDirty() {
+		w.notifyDirtyChanged()
+	}
+	for c := range w.clones {
+		if c != w && c.apiNotifiedDirty != c.IsDirty() {
+			c.notifyDirtyChanged()
+		}
+	}
+}
+
+// notifyDirtyChanged notifies API clients that the window went from having no unsaved changes
+// to having some, or the reverse.
+func (w *Window) notifyDirtyChanged() {
+	w.apiNotifiedDirty = w.IsDirty()
+
+	n := ApiNotification{
+		WinId: w.Id,
+		Op:    ApiNotificationOpClean,
+	}
+	if w.apiNotifiedDirty {
+		n.Op = ApiNotificationOpDirty
+	}
+
+	addApiNotificationToAllSessions(n)
+}
+
 func (w *Window) notifyPut() {
 	n := ApiNotification{
 		WinId: w.Id,
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"gioui.org/layout"
+)
+
+func newZeroxTestWindow(t testing.TB, text string) *Window {
+	newTestEditor()
+
+	win := editor.NewWindow(nil)
+	win.SetFilenameAndTag("/tmp/zerox.txt", typeFile)
+	win.Body.SetText([]byte(text))
+	return win
+}
+
+func TestZeroxClonesShareTextAndKeepTheirOwnView(t *testing.T) {
+	win := newZeroxTestWindow(t, "line one\nline two\nline three\n")
+
+	clone, err := win.Zerox()
+	if err != nil {
+		t.Fatalf("Zerox failed: %v", err)
+	}
+	if clone.Body.text != win.Body.text {
+		t.Fatalf("expected the clone to share the piece table of the window")
+	}
+
+	clone.Body.CursorIndices = []int{18}
+	clone.Body.TopLeftIndex = 9
+	win.Body.CursorIndices = []int{0}
+
+	win.Body.InsertText("new ")
+	if clone.Body.String() != "new line one\nline two\nline three\n" {
+		t.Fatalf("expected the clone to show the change but it has %q", clone.Body.String())
+	}
+	if clone.Body.CursorIndices[0] != 22 || clone.Body.TopLeftIndex != 13 {
+		t.Fatalf("expected the clone's cursor and top-left to shift by 4 but they are %d and %d",
+			clone.Body.CursorIndices[0], clone.Body.TopLeftIndex)
+	}
+	if win.Body.CursorIndices[0] != 4 {
+		t.Fatalf("expected the window's cursor to be after the insert but it is at %d", win.Body.CursorIndices[0])
+	}
+
+	win.Body.applyUndoOrRedo(win.Body.text.Undo, -1)
+	if clone.Body.String() != "line one\nline two\nline three\n" {
+		t.Fatalf("expected the undo to be seen in the clone but it has %q", clone.Body.String())
+	}
+	if clone.Body.CursorIndices[0] != 18 || clone.Body.TopLeftIndex != 9 {
+		t.Fatalf("expected the undo to shift the clone's cursor and top-left back but they are %d and %d",
+			clone.Body.CursorIndices[0], clone.Body.TopLeftIndex)
+	}
+
+	// Delete a range containing the clone's top-left index.
+	win.Body.deleteFromPieceTable(5, 10)
+	if clone.Body.TopLeftIndex != 5 {
+		t.Fatalf("expected the clone's top-left to move to the start of the deleted text but it is %d", clone.Body.TopLeftIndex)
+	}
+	if clone.Body.CursorIndices[0] != 8 {
+		t.Fatalf("expected the clone's cursor to shift left by 10 but it is %d", clone.Body.CursorIndices[0])
+	}
+
+	win.Body.SetText([]byte("x"))
+	if clone.Body.CursorIndices[0] > 1 || clone.Body.TopLeftIndex != 0 {
+		t.Fatalf("expected the clone's view to be inside the replaced text but the cursor is %d and the top-left %d",
+			clone.Body.CursorIndices[0], clone.Body.TopLeftIndex)
+	}
+}
+
+func TestZeroxCloneEditsShiftMarksOnce(t *testing.T) {
+	win := newZeroxTestWindow(t, "0123456789")
+	clone, err := win.Zerox()
+	if err != nil {
+		t.Fatalf("Zerox failed: %v", err)
+	}
+
+	editor.Marks.Set("a", win.file, 5)
+	clone.Body.CursorIndices = []int{0}
+	clone.Body.InsertText("ab")
+
+	_, goTo, ok := editor.Marks.Seek("a")
+	if !ok || goTo.runePos != 7 {
+		t.Fatalf("expected the mark to shift by 2 to 7 but it is %d (ok %v)", goTo.runePos, ok)
+	}
+}
+
+func TestZeroxFollowScrollsWithSource(t *testing.T) {
+	win := newZeroxTestWindow(t, "line one\nline two\nline three\nline four\n")
+	clone, err := win.Zerox()
+	if err != nil {
+		t.Fatalf("Zerox failed: %v", err)
+	}
+	clone.followScrollOf(win)
+
+	win.Body.TopLeftIndex = 9
+	win.updateScrollFollowers(layout.Context{})
+	if clone.Body.TopLeftIndex != 9 {
+		t.Fatalf("expected the clone to scroll to 9 with the window but its top-left is %d", clone.Body.TopLeftIndex)
+	}
+
+	// A change to the text shifts the top-left of the clone without breaking the link.
+	win.Body.CursorIndices = []int{0}
+	win.Body.InsertText("new ")
+	win.updateScrollFollowers(layout.Context{})
+	if !clone.FollowsScrollOf(win) {
+		t.Fatalf("expected a change to the text not to break the link")
+	}
+
+	win.Body.TopLeftIndex = 22
+	win.updateScrollFollowers(layout.Context{})
+	if clone.Body.TopLeftIndex != 22 {
+		t.Fatalf("expected the clone to scroll to 22 with the window but its top-left is %d", clone.Body.TopLeftIndex)
+	}
+
+	// Scrolling the clone itself breaks the link.
+	clone.Body.TopLeftIndex = 0
+	win.Body.TopLeftIndex = 13
+	win.updateScrollFollowers(layout.Context{})
+	if clone.FollowsScrollOf(win) || clone.Body.TopLeftIndex != 0 {
+		t.Fatalf("expected scrolling the clone to break the link but it follows: %v, top-left %d",
+			clone.FollowsScrollOf(win), clone.Body.TopLeftIndex)
+	}
+}
+
+func TestZeroxFollowLinkRemovedWhenSourceCloses(t *testing.T) {
+	win := newZeroxTestWindow(t, "text\n")
+	clone, err := win.Zerox()
+	if err != nil {
+		t.Fatalf("Zerox failed: %v", err)
+	}
+	clone.followScrollOf(win)
+
+	win.removeFromAllClones()
+	if clone.scrollFollow != nil {
+		t.Fatalf("expected the link to be removed when the window it follows is closed")
+	}
+}
+
+func TestZeroxInCol(t *testing.T) {
+	win := newZeroxTestWindow(t, "text\n")
+	col := editor.NewCol()
+
+	clone, err := win.ZeroxInCol(col)
+	if err != nil {
+		t.Fatalf("Zerox failed: %v", err)
+	}
+	if clone.col != col {
+		t.Fatalf("expected the clone to be opened in the new column")
+	}
+}
+
+const zeroxBenchmarkBodySize = 8 * 1024 * 1024
+
+func zeroxBenchmarkBody() []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < zeroxBenchmarkBodySize; i++ {
+		fmt.Fprintf(&buf, "%08d some line of a large log file\n", i)
+	}
+	return buf.Bytes()
+}
+
+// BenchmarkZerox compares making a clone that shares the piece table of the window with making a
+// window holding a copy of the text.
+func BenchmarkZerox(b *testing.B) {
+	body := zeroxBenchmarkBody()
+
+	b.Run("shared", func(b *testing.B) {
+		win := newZeroxTestWindow(b, "")
+		win.Body.SetText(body)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := win.Zerox(); err != nil {
+				b.Fatalf("Zerox failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("copied", func(b *testing.B) {
+		win := newZeroxTestWindow(b, "")
+		win.Body.SetText(body)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			nw := editor.NewWindow(nil)
+			nw.SetFilenameAndTag(win.file, win.fileType)
+			nw.Body.SetText(win.Body.Bytes())
+		}
+	})
+}
+
+// BenchmarkInsertWithClones measures inserting a large paste into a window with a number of clones.
+func BenchmarkInsertWithClones(b *testing.B) {
+	body := zeroxBenchmarkBody()
+	paste := string(body[:256*1024])
+
+	for _, clones := range []int{0, 1, 4} {
+		b.Run(fmt.Sprintf("clones=%d", clones), func(b *testing.B) {
+			win := newZeroxTestWindow(b, "")
+			win.Body.SetText(body)
+			for i := 0; i < clones; i++ {
+				if _, err := win.Zerox(); err != nil {
+					b.Fatalf("Zerox failed: %v", err)
+				}
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				win.Body.CursorIndices = []int{zeroxBenchmarkBodySize / 2}
+				win.Body.InsertText(paste)
+			}
+		})
+	}
+}
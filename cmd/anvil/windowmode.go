@@ -0,0 +1,71 @@
+package main
+
+import (
+	"gioui.org/layout"
+)
+
+// windowMode makes a window that a tool drives through the API behave like a terminal. It is
+// set using PUT /wins/1/mode.
+type windowMode struct {
+	// followOutput scrolls the body to the end, and moves the cursor there, when text is appended
+	// through the API while the end of the body is visible.
+	followOutput bool
+	// userScrollPausesFollow pauses following while the user has scrolled the body away from the
+	// end. Following resumes when the user scrolls back to the end.
+	userScrollPausesFollow bool
+	// paused is true while following is paused. Cursor moves made through the API are ignored
+	// while it is set, so that the tool doesn't move the view the user is reading.
+	paused bool
+	// topLeft is the TopLeftIndex of the body when paused was last decided.
+	topLeft int
+}
+
+// SetMode sets the mode of the window. Following is not paused after the mode is set.
+func (w *Window) SetMode(followOutput, userScrollPausesFollow bool) {
+	w.mode = windowMode{
+		followOutput:           followOutput,
+		userScrollPausesFollow: userScrollPausesFollow,
+		topLeft:                w.Body.TopLeftIndex,
+	}
+}
+
+// FollowPaused returns true if following the output appended to the window is paused because
+// the user scrolled away from the end of the body.
+func (w *Window) FollowPaused() bool {
+	return w.mode.paused
+}
+
+// followAppendedOutput scrolls the body to the end after text was appended through the API,
+// if the window follows output and atEnd is true because the end of the body was visible
+// before the text was appended.
+func (w *Window) followAppendedOutput(atEnd bool) {
+	if !w.mode.followOutput || w.mode.paused || !atEnd {
+		return
+	}
+
+	w.Body.AddOpForNextLayout(func(gtx layout.Context) {
+		w.Body.moveToEndOfDoc(gtx)
+	})
+}
+
+// updateFollowPause pauses following when the body was scrolled away from the end since the
+// last layout, and resumes it when it was scrolled back to the end. Text appended without
+// scrolling doesn't change whether following is paused.
+func (w *Window) updateFollowPause() {
+	m := &w.mode
+	if !m.followOutput || !m.userScrollPausesFollow {
+		return
+	}
+
+	if w.Body.TopLeftIndex == m.topLeft {
+		return
+	}
+	m.topLeft = w.Body.TopLeftIndex
+	m.paused = !w.Body.endOfDocVisible()
+}
+
+// apiCursorMovesAllowed returns false if cursor moves made through the API are ignored because
+// following is paused.
+func (w *Window) apiCursorMovesAllowed() bool {
+	return !w.mode.paused
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"strings"
+	"testing"
+
+	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
+)
+
+func TestWindowModePausesWhileScrolledUp(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var buf strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&buf, "line %d\n", i)
+	}
+	text := buf.String()
+	nearEnd := strings.Index(text, "line 490\n")
+
+	var win *Window
+	onMainGoroutine(func() {
+		win = editor.NewWindow(nil)
+		win.Body.SetText([]byte(text))
+		// The body shows 20 lines, starting near the end.
+		win.Body.maxSizeLastLayout = image.Pt(400, 20*win.Body.lineHeight())
+		win.Body.TopLeftIndex = nearEnd
+	})
+	awin := api.Window{Id: win.Id}
+
+	err := anvil.SetWindowMode(awin, api.WindowMode{FollowOutput: true, UserScrollPausesFollow: true})
+	if err != nil {
+		t.Fatalf("setting the mode failed: %v", err)
+	}
+
+	mode := func() api.WindowMode {
+		m, err := anvil.WindowMode(awin)
+		if err != nil {
+			t.Fatalf("getting the mode failed: %v", err)
+		}
+		return m
+	}
+	if m := mode(); !m.FollowOutput || !m.UserScrollPausesFollow || m.Paused {
+		t.Fatalf("expected the mode to be following and not paused but it is %+v", m)
+	}
+
+	// Appending while the end is visible follows the output.
+	_, err = anvil.Post(fmt.Sprintf("/wins/%d/body", win.Id), bytes.NewBufferString("more\n"))
+	if err != nil {
+		t.Fatalf("appending failed: %v", err)
+	}
+	onMainGoroutine(func() {
+		if len(win.Body.opsForNextLayout) == 0 {
+			t.Errorf("expected the append to scroll to the end at the next layout")
+		}
+		win.Body.opsForNextLayout = nil
+	})
+
+	// The user scrolls up.
+	onMainGoroutine(func() {
+		win.Body.TopLeftIndex = 0
+		win.updateFollowPause()
+	})
+	if !mode().Paused {
+		t.Fatalf("expected following to pause when the user scrolls up")
+	}
+
+	_, err = anvil.Post(fmt.Sprintf("/wins/%d/body", win.Id), bytes.NewBufferString("more\n"))
+	if err != nil {
+		t.Fatalf("appending failed: %v", err)
+	}
+	_, err = anvil.Put(fmt.Sprintf("/wins/%d/body/cursors", win.Id), bytes.NewBufferString("[3]"))
+	if err != nil {
+		t.Fatalf("setting the cursors failed: %v", err)
+	}
+	onMainGoroutine(func() {
+		if len(win.Body.opsForNextLayout) != 0 {
+			t.Errorf("expected the append not to scroll while paused")
+		}
+		if win.Body.TopLeftIndex != 0 || win.Body.CursorIndices[0] == 3 {
+			t.Errorf("expected the view and cursor not to move while paused")
+		}
+	})
+
+	// The user scrolls back to the end.
+	onMainGoroutine(func() {
+		win.Body.TopLeftIndex = nearEnd
+		win.updateFollowPause()
+	})
+	if mode().Paused {
+		t.Fatalf("expected following to resume when the user scrolls back to the end")
+	}
+}
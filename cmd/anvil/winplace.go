@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// The columns new windows can be placed in, set by the window-placement setting.
+const (
+	windowPlacementMostFreeSpace = "most-free-space"
+	windowPlacementActiveColumn  = "active-column"
+	windowPlacementRightMost     = "right-most"
+)
+
+// colForNewWindow returns the visible column a new window is placed in when no column is
+// given, as chosen by the window-placement setting. It returns nil if no column is visible.
+func (e *Editor) colForNewWindow() *Col {
+	cols := e.VisibleCols()
+	if len(cols) == 0 {
+		return nil
+	}
+
+	switch settings.Layout.WindowPlacement {
+	case windowPlacementActiveColumn:
+		if c := e.activeCol(cols); c != nil {
+			return c
+		}
+	case windowPlacementRightMost:
+		rightMost := cols[0]
+		for _, c := range cols {
+			if c.LeftX > rightMost.LeftX {
+				rightMost = c
+			}
+		}
+		return rightMost
+	}
+
+	leastPopulated := cols[0]
+	count := math.MaxInt
+	for _, c := range cols {
+		if len(c.Windows) < count {
+			leastPopulated = c
+			count = len(c.Windows)
+		}
+	}
+	return leastPopulated
+}
+
+// activeCol returns the column of the focused window, or else the column among cols the user
+// last typed or clicked in. It returns nil if there is no such column.
+func (e *Editor) activeCol(cols []*Col) *Col {
+	if e.focusedWindow != nil && e.focusedWindow.col != nil && e.focusedWindow.col.Visible() {
+		return e.focusedWindow.col
+	}
+
+	var active *Col
+	for _, c := range cols {
+		if !c.lastInteraction.IsZero() && (active == nil || c.lastInteraction.After(active.lastInteraction)) {
+			active = c
+		}
+	}
+	return active
+}
+
+// MoveWindowToCol moves w to the bottom of col. The window keeps its body, selections and
+// scroll position. The column w was in is kept even if it becomes empty.
+func (e *Editor) MoveWindowToCol(w *Window, col *Col) {
+	if w.col == col {
+		return
+	}
+
+	log(LogCatgEditor, "Editor.MoveWindowToCol: moving window %d from column %d to column %d\n", w.Id, w.col.Id, col.Id)
+	w.col.detachWindow(w)
+	col.SetVisible(true)
+	w.col = col
+	w.Tag.Scheduler = col.Scheduler
+	col.attachWindow(w)
+	e.SignalRedrawRequired()
+}
+
+// detachWindow removes w from the column without closing it, so that it can be added to
+// another column.
+func (c *Col) detachWindow(w *Window) {
+	remove := func(wins []*Window) []*Window {
+		for i, x := range wins {
+			if x == w {
+				return append(wins[:i:i], wins[i+1:]...)
+			}
+		}
+		return wins
+	}
+
+	c.Windows = remove(c.Windows)
+	c.unpositioned = remove(c.unpositioned)
+	c.remove = remove(c.remove)
+	if w == c.maximizedWindow {
+		c.maximizedWindow = nil
+	}
+	if len(c.Windows) > 0 {
+		c.Windows[0].TopY = 0
+	}
+}
+
+// attachWindow adds w, which was detached from another column, to the column. It is positioned
+// like a new window.
+func (c *Col) attachWindow(w *Window) {
+	if len(c.Windows) == 0 {
+		w.TopY = 0
+		c.Windows = append(c.Windows, w)
+		return
+	}
+	c.unpositioned = append(c.unpositioned, w)
+}
+
+// clampColIndex returns i limited to the indexes of n columns, and whether it had to be changed.
+func clampColIndex(i, n int) (clamped int, changed bool) {
+	switch {
+	case i < 0:
+		return 0, true
+	case i >= n:
+		return n - 1, true
+	}
+	return i, false
+}
+
+// findColForMoveto returns the column named by arg as FindCol does, for the commands that put a
+// window in a column. A number out of range is clamped to the nearest column and msg says so.
+func (e *Editor) findColForMoveto(arg string) (col *Col, msg string, err error) {
+	if len(e.Cols) == 0 {
+		return nil, "", fmt.Errorf("there are no columns")
+	}
+
+	if n, perr := strconv.Atoi(strings.TrimSpace(arg)); perr == nil {
+		i, clamped := clampColIndex(n-1, len(e.Cols))
+		if clamped {
+			msg = fmt.Sprintf("there is no column %d; moved to column %d", n, i+1)
+		}
+		return e.Cols[i], msg, nil
+	}
+
+	col, err = e.FindCol(arg)
+	return
+}
+
+// CmdMoveto moves the window it is executed in to another column.
+func (c CommandExecutor) CmdMoveto(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Moveto: must be executed in a window")
+		return
+	}
+
+	arg := strings.TrimSpace(ctx.CombinedArgs())
+	if arg == "" {
+		editor.AppendError("", "Moveto: a column number or name is required")
+		return
+	}
+
+	col, msg, err := editor.findColForMoveto(arg)
+	if err != nil {
+		editor.AppendError("", fmt.Sprintf("Moveto: %v", err))
+		return
+	}
+	editor.MoveWindowToCol(w, col)
+	if msg != "" {
+		editor.AppendError("", fmt.Sprintf("Moveto: %s", msg))
+	}
+}
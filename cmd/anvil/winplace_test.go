@@ -0,0 +1,155 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func setWindowPlacementForTest(t *testing.T, placement string) {
+	saved := settings.Layout.WindowPlacement
+	settings.Layout.WindowPlacement = placement
+	t.Cleanup(func() { settings.Layout.WindowPlacement = saved })
+}
+
+func TestColForNewWindowFollowsPlacementSetting(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var left, middle, right *Col
+	onMainGoroutine(func() {
+		left = editor.Cols[0]
+		middle = editor.NewColDontPosition()
+		right = editor.NewColDontPosition()
+		middle.LeftX = 300
+		right.LeftX = 600
+		left.NewWindow()
+		left.NewWindow()
+		right.NewWindow()
+		middle.NoteInteraction()
+	})
+
+	tests := []struct {
+		placement string
+		expected  func() *Col
+	}{
+		{windowPlacementMostFreeSpace, func() *Col { return middle }},
+		{windowPlacementRightMost, func() *Col { return right }},
+		{windowPlacementActiveColumn, func() *Col { return middle }},
+		{"", func() *Col { return middle }},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.placement, func(t *testing.T) {
+			setWindowPlacementForTest(t, tc.placement)
+			onMainGoroutine(func() {
+				if c := editor.colForNewWindow(); c != tc.expected() {
+					t.Fatalf("expected column %d but got column %d", tc.expected().Id, c.Id)
+				}
+			})
+		})
+	}
+
+	setWindowPlacementForTest(t, windowPlacementActiveColumn)
+	onMainGoroutine(func() {
+		w := right.Windows[0]
+		editor.focusedWindow = w
+		if c := editor.colForNewWindow(); c != right {
+			t.Fatalf("expected the column of the focused window but got column %d", c.Id)
+		}
+	})
+}
+
+func colHasWindow(c *Col, w *Window) bool {
+	for _, wins := range [][]*Window{c.Windows, c.unpositioned} {
+		for _, x := range wins {
+			if x == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func TestMovetoKeepsBodyAndClampsColumn(t *testing.T) {
+	startHeadlessEditor(t)
+
+	var w *Window
+	var from, to *Col
+	onMainGoroutine(func() {
+		from = editor.Cols[0]
+		to = editor.NewColDontPosition()
+		to.LeftX = 500
+		to.Tag.SetTextStringNoUndo("Logs New Cut")
+		w = from.NewWindow()
+		w.Body.SetTextString("line 1\nline 2\n")
+		w.Body.SetCursorIndices([]int{7})
+
+		NewCommandExecutor(w).CmdMoveto(&CmdContext{Args: []string{"9"}})
+
+		if w.col != to || colHasWindow(from, w) || !colHasWindow(to, w) {
+			t.Fatalf("expected the window to be moved to the last column")
+		}
+		if w.Body.String() != "line 1\nline 2\n" || w.Body.CursorIndices[0] != 7 {
+			t.Fatalf("expected the body to be kept")
+		}
+		errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		if errs == nil || !strings.Contains(errs.Body.String(), "there is no column 9; moved to column 2") {
+			t.Fatalf("expected the clamped column to be reported")
+		}
+
+		NewCommandExecutor(w).CmdMoveto(&CmdContext{Args: []string{"1"}})
+		if w.col != from {
+			t.Fatalf("expected the window to be moved back to the first column")
+		}
+		NewCommandExecutor(w).CmdMoveto(&CmdContext{Args: []string{"Logs"}})
+		if w.col != to {
+			t.Fatalf("expected the window to be moved to the column named Logs")
+		}
+		NewCommandExecutor(w).CmdMoveto(&CmdContext{Args: []string{"Missing"}})
+		if w.col != to {
+			t.Fatalf("expected the window not to be moved to a column that doesn't exist")
+		}
+	})
+}
+
+func TestMoveWindowThroughApi(t *testing.T) {
+	anvil := startHeadlessEditor(t)
+
+	var w *Window
+	onMainGoroutine(func() {
+		c := editor.NewColDontPosition()
+		c.Tag.SetTextStringNoUndo("Logs New Cut")
+		w = editor.Cols[0].NewWindow()
+	})
+
+	wins, err := anvil.Windows()
+	if err != nil || len(wins) != 1 {
+		t.Fatalf("listing windows failed: %v", err)
+	}
+
+	move, err := anvil.MoveWindow(wins[0], 7)
+	if err != nil {
+		t.Fatalf("moving window failed: %v", err)
+	}
+	if move.Column != 1 || !move.Clamped {
+		t.Fatalf("expected the window to be moved to the clamped column 1 but got %+v", move)
+	}
+
+	move, err = anvil.MoveWindow(wins[0], 0)
+	if err != nil || move.Column != 0 || move.Clamped {
+		t.Fatalf("expected the window to be moved to column 0 but got %+v (error %v)", move, err)
+	}
+
+	move, err = anvil.MoveWindowToNamedColumn(wins[0], "Logs")
+	if err != nil || move.Column != 1 {
+		t.Fatalf("expected the window to be moved to the column named Logs but got %+v (error %v)", move, err)
+	}
+	onMainGoroutine(func() {
+		if w.col != editor.Cols[1] {
+			t.Fatalf("expected the window to be in the second column")
+		}
+	})
+
+	if _, err = anvil.MoveWindowToNamedColumn(wins[0], "Missing"); err == nil {
+		t.Fatalf("expected moving to a column that doesn't exist to fail")
+	}
+}
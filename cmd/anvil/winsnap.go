@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jeffwilliams/anvil/internal/pctbl"
+	"github.com/jeffwilliams/anvil/internal/runes"
+)
+
+// defaultSnapName is the name of the checkpoint made by Snap when it is given no name.
+const defaultSnapName = "default"
+
+// bodySnap is a checkpoint of the text of a window body made by Snap. The text is kept as a
+// pctbl.Snapshot, so it refers to the piece table's buffers rather than being a copy.
+type bodySnap struct {
+	text pctbl.Snapshot
+	// file is the path of the file in the window when the checkpoint was made. Revert refuses
+	// to restore the text into a window holding a different file.
+	file  string
+	taken time.Time
+}
+
+// sharedSnaps returns the checkpoints of the window, creating them if there are none, so that a
+// clone of the window, which shares its text, can share them.
+func (w *Window) sharedSnaps() map[string]*bodySnap {
+	if w.snaps == nil {
+		w.snaps = map[string]*bodySnap{}
+	}
+	return w.snaps
+}
+
+func snapNameArg(cmd string, args []string) (name string, err error) {
+	switch len(args) {
+	case 0:
+		return defaultSnapName, nil
+	case 1:
+		return args[0], nil
+	}
+	return "", fmt.Errorf("%s: expected at most one argument, the name of the checkpoint", cmd)
+}
+
+func (c CommandExecutor) CmdSnap(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Snap: must be executed in a window")
+		return
+	}
+
+	name, err := snapNameArg("Snap", ctx.Args)
+	if err != nil {
+		editor.AppendError("", err.Error())
+		return
+	}
+
+	s, ok := w.Body.text.(pctbl.Snapshotter)
+	if !ok {
+		editor.AppendError("", "Snap: the text of the window can't be checkpointed")
+		return
+	}
+
+	w.sharedSnaps()[name] = &bodySnap{text: s.Snapshot(), file: w.file, taken: time.Now()}
+}
+
+func (c CommandExecutor) CmdRevert(ctx *CmdContext) {
+	w, ok := c.source.(*Window)
+	if !ok {
+		editor.AppendError("", "Revert: must be executed in a window")
+		return
+	}
+
+	name, err := snapNameArg("Revert", ctx.Args)
+	if err != nil {
+		editor.AppendError("", err.Error())
+		return
+	}
+
+	snap, ok := w.snaps[name]
+	if !ok {
+		editor.AppendError("", fmt.Sprintf("Revert: the window has no checkpoint named %s. Snaps lists the checkpoints.", name))
+		return
+	}
+
+	if snap.file != w.file {
+		editor.AppendError("", fmt.Sprintf("Revert: checkpoint %s was made of %s but the window now holds %s", name, snap.file, w.file))
+		return
+	}
+
+	w.Body.revertTo(snap.text)
+}
+
+// revertTo replaces the text with the checkpointed text as one change for Undo. The cursor and
+// the view are kept where they were as far as the text allows.
+func (b *Body) revertTo(text pctbl.Snapshot) {
+	cursor, top := b.firstCursorIndex(), b.TopLeftIndex
+
+	if !b.replaceText(text.Bytes()) {
+		return
+	}
+
+	b.setToOneCursorIndex(min(cursor, b.Len()))
+	w := runes.NewWalker(b.Bytes())
+	w.SetRunePosCache(min(top, b.Len()), &b.runeOffsetCache)
+	w.BackwardToStartOfLine()
+	b.TopLeftIndex = w.RunePos()
+}
+
+func (c CommandExecutor) CmdSnaps(ctx *CmdContext) {
+	var wins []*Window
+	if w, ok := c.source.(*Window); ok {
+		wins = []*Window{w}
+	} else {
+		wins = editor.Windows()
+	}
+
+	var buf strings.Builder
+	listed := map[*Window]bool{}
+	for _, w := range wins {
+		// Clones share their checkpoints; list them once.
+		if len(w.snaps) == 0 || listed[w] {
+			continue
+		}
+		listed[w] = true
+		for c := range w.clones {
+			listed[c] = true
+		}
+
+		names := make([]string, 0, len(w.snaps))
+		for name := range w.snaps {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintf(&buf, "%s:\n", w.file)
+		for _, name := range names {
+			s := w.snaps[name]
+			fmt.Fprintf(&buf, "  %s\t%s\t%v ago\n", name, humanizeSize(int64(s.text.ByteLen())), time.Since(s.taken).Round(time.Second))
+		}
+	}
+
+	if buf.Len() == 0 {
+		editor.AppendError("", "There are no checkpoints. Snap makes one.")
+		return
+	}
+	editor.AppendError("", buf.String())
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRevertToSnap(t *testing.T) {
+	startHeadlessEditor(t)
+
+	onMainGoroutine(func() {
+		win := editor.NewWindow(nil)
+		win.SetFilenameAndTag("/tmp/snap.txt", typeFile)
+		win.Body.SetText([]byte("one\ntwo\n"))
+
+		NewCommandExecutor(win).Do("Snap before", &CmdContext{})
+		win.Body.insertToPieceTable(0, "zero\n")
+		win.Body.insertToPieceTable(win.Body.Len(), "three\n")
+
+		NewCommandExecutor(win).Do("Revert before", &CmdContext{})
+		if win.Body.String() != "one\ntwo\n" {
+			t.Fatalf("expected the checkpointed text to be restored but the body is %q", win.Body.String())
+		}
+
+		win.Body.text.Undo()
+		if win.Body.String() != "zero\none\ntwo\nthree\n" {
+			t.Fatalf("expected one undo to reverse the revert but the body is %q", win.Body.String())
+		}
+
+		NewCommandExecutor(win).Do("Snaps", &CmdContext{})
+		errs, _ := editor.FindWindowForFile(editor.ErrorsFileNameOf(""))
+		if errs == nil || !strings.Contains(errs.Body.String(), "  before\t8 B\t") {
+			t.Fatalf("expected Snaps to list the checkpoint with its size")
+		}
+
+		win.SetFilenameAndTag("/tmp/other.txt", typeFile)
+		NewCommandExecutor(win).Do("Revert before", &CmdContext{})
+		if win.Body.String() != "zero\none\ntwo\nthree\n" {
+			t.Fatalf("expected Revert to refuse a window whose file changed but the body is %q", win.Body.String())
+		}
+		if !strings.Contains(errs.Body.String(), "was made of /tmp/snap.txt") {
+			t.Fatalf("expected Revert to report that the file changed")
+		}
+	})
+}
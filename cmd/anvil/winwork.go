@@ -1,7 +1,14 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"time"
+
 	"gioui.org/layout"
+	"github.com/jeffwilliams/anvil/internal/ansi"
+	"github.com/jeffwilliams/anvil/internal/textenc"
 )
 
 type WindowDataLoad struct {
@@ -15,6 +22,33 @@ type WindowDataLoad struct {
 	SelectBehaviour   selectBehaviour
 	GrowBodyBehaviour growBodyBehaviour
 	Job               Job
+	// SpillThreshold, if greater than zero, is the number of bytes of contents after which
+	// the rest of the contents are written to a temporary file rather than the window body.
+	// The window body then only shows part of the contents.
+	SpillThreshold int64
+	// Highlighter, if set, highlights parts of each line of the contents as it is appended
+	// to the window.
+	Highlighter *contentHighlighter
+	// ReportTermination, if set, writes a footer after the contents saying how the command
+	// that produced them ended and how long it ran.
+	ReportTermination bool
+	// LowPriority, if set, loads the contents into the window in the low priority band,
+	// behind the work the user is waiting on.
+	LowPriority bool
+	// Reload, if set, replaces the window body with the contents once they are all loaded,
+	// as a change that can be undone, rather than appending them to the body as they arrive.
+	// ReloadOld is the text of the body at ReloadGeneration, when the reload began. The
+	// changes from it to the contents are found before the body is replaced.
+	Reload           bool
+	ReloadOld        []byte
+	ReloadGeneration int
+	// DecodeContents, if set, converts the contents of the file from the encoding detected from
+	// the start of them, or from Encoding if that is set, to UTF-8 with newline line endings.
+	DecodeContents bool
+	Encoding       *textenc.Encoding
+	started        time.Time
+	failed         atomic.Bool
+	killed         atomic.Bool
 }
 
 type WindowHolder struct {
@@ -43,9 +77,14 @@ func NewWindowHolderForName(winName string) WindowHolder {
 }
 
 func (f *WindowDataLoad) Start(c chan Work) {
+	f.started = time.Now()
 	go f.pump(c)
 }
 
+func (f *WindowDataLoad) StartTime() time.Time {
+	return f.started
+}
+
 func (f *WindowDataLoad) GetJob() Job {
 	if f.Job == nil {
 		return f
@@ -60,6 +99,45 @@ type WindowDataLoadSender struct {
 	sentType        bool
 	work            chan Work
 	load            *WindowDataLoad
+	// loaded is the number of bytes of contents sent so far, and sent holds them until
+	// the spill threshold is reached. read is the number of bytes of contents received,
+	// before they are decoded.
+	loaded int64
+	read   int64
+	sent   bytes.Buffer
+	spill  *spill
+	// output keeps track of the text written to the window until the contents are spilled.
+	output *jobOutput
+	// partialLine holds the end of the contents that is not yet a complete line when
+	// contents are highlighted.
+	partialLine []byte
+	// pendingOutput holds the end of the contents that can't be cleaned until more arrives:
+	// an incomplete escape sequence, or a carriage return that might start a line ending.
+	pendingOutput []byte
+	// termination records how the command ended when the load reports it.
+	termination jobTermination
+	// sentOutput is true once some contents were sent, and endsWithNewline is true if the
+	// last of them ended with a newline.
+	sentOutput      bool
+	endsWithNewline bool
+	// reloaded holds the contents until they are all loaded when the load is a Reload.
+	reloaded bytes.Buffer
+	// head holds the start of the contents until there is enough to detect their encoding, and
+	// decoder converts them once it is detected.
+	head    []byte
+	decoder *textenc.Decoder
+	// decodedSent is the number of bytes of decoded contents appended to the window so far.
+	decodedSent int
+	// limiter caps how fast command output is appended to an +Errors window.
+	limiter outputRateLimiter
+}
+
+func (w *WindowDataLoadSender) send(x Work) {
+	if w.load.LowPriority {
+		x = withPriority(x, workPriorityLow)
+	}
+	editor.workQueue.waitForRoom(priorityOfWork(x))
+	w.work <- x
 }
 
 func (w WindowDataLoadSender) workIsDone() bool {
@@ -71,7 +149,7 @@ func (w *WindowDataLoadSender) sendType(t fileType) {
 		return
 	}
 
-	w.work <- &winSetFiletype{job: w.load.GetJob(), win: w.load.Win, fileType: t}
+	w.send(&winSetFiletype{job: w.load.GetJob(), win: w.load.Win, fileType: t})
 	w.sentType = true
 }
 
@@ -79,16 +157,227 @@ func (w *WindowDataLoadSender) updateStateWhenContentsClosed() {
 	log(LogCatgWin, "pump: contents is closed\n")
 	w.contentsClosed = true
 	w.load.Contents = nil
+	w.flushDecoder()
+	// A carriage return at the very end replaces nothing, and an incomplete escape sequence
+	// is left as it was written.
+	partial := w.partialLine
+	if h := w.load.Highlighter; h != nil {
+		partial = h.filterLines(partial)
+	}
+	x := append(partial, bytes.TrimPrefix(w.pendingOutput, []byte("\r"))...)
+	w.partialLine = nil
+	w.pendingOutput = nil
+	x = append(x, w.limiter.flush()...)
+	if len(x) > 0 {
+		w.sendData(x)
+	}
+}
+
+// isTerminalOutput returns true if the contents are command output for an +Errors window. These
+// are cleaned up to appear as they would in a terminal.
+func (w *WindowDataLoadSender) isTerminalOutput() bool {
+	return !settings.General.RawOutput && w.load.Win.LoadByName() && IsErrorsWindow(w.load.Win.winName)
+}
+
+// cleanOutput removes escape sequences that can't be shown in a window from the command output x,
+// and changes carriage return-newline line endings to newlines. Other carriage returns are left
+// for winLoadData to apply to the window.
+func (w *WindowDataLoadSender) cleanOutput(x []byte) []byte {
+	x = append(w.pendingOutput, x...)
+	x, w.pendingOutput = ansi.StripNonSGRSequences(x)
+	if len(x) > 0 && x[len(x)-1] == '\r' {
+		x = x[:len(x)-1]
+		w.pendingOutput = append([]byte("\r"), w.pendingOutput...)
+	}
+	return bytes.ReplaceAll(x, []byte("\r\n"), []byte("\n"))
 }
 
 func (w *WindowDataLoadSender) sendContents(x []byte) {
 	w.sendType(typeFile)
+	w.read += int64(len(x))
+
+	if w.load.DecodeContents {
+		x = w.decode(x)
+		if len(x) == 0 {
+			return
+		}
+	}
+	w.sendDecodedContents(x)
+}
+
+// decode converts the contents x of a file to UTF-8 with newline line endings. The start of the
+// contents is held until there is enough of it to detect their encoding.
+func (w *WindowDataLoadSender) decode(x []byte) []byte {
+	if w.decoder == nil {
+		w.head = append(w.head, x...)
+		if len(w.head) < textenc.DetectSize {
+			return nil
+		}
+		w.startDecoding()
+		x, w.head = w.head, nil
+	}
+	crlf := w.decoder.Encoding().CRLF
+	x = w.decoder.Decode(x)
+	w.checkDecoderFallBack(crlf)
+	return x
+}
+
+// checkDecoderFallBack handles the decoder falling back to newline line endings because a line
+// ended with just a newline, when crlf is whether it converted CRLF line endings before. The
+// carriage returns are put back in the contents decoded until then so that Put writes the file
+// back as it was.
+func (w *WindowDataLoadSender) checkDecoderFallBack(crlf bool) {
+	enc := w.decoder.Encoding()
+	if !crlf || enc.CRLF {
+		return
+	}
+
+	log(LogCatgWin, "pump: the line endings are mixed; the contents are encoded as %s\n", enc)
+	if w.load.Reload {
+		restored := textenc.RestoreCR(w.reloaded.Bytes())
+		w.reloaded.Reset()
+		w.reloaded.Write(restored)
+	} else if w.decodedSent > 0 {
+		w.send(&winRestoreCR{job: w.load.GetJob(), win: w.load.Win, n: w.decodedSent})
+	}
+	w.send(&winSetEncoding{job: w.load.GetJob(), win: w.load.Win, encoding: enc})
+}
+
+func (w *WindowDataLoadSender) startDecoding() {
+	enc := textenc.Detect(w.head)
+	if w.load.Encoding != nil {
+		enc = *w.load.Encoding
+	}
+	log(LogCatgWin, "pump: contents are encoded as %s\n", enc)
+	w.decoder = textenc.NewDecoder(enc)
+	w.send(&winSetEncoding{job: w.load.GetJob(), win: w.load.Win, encoding: enc})
+}
+
+// flushDecoder sends the contents held by decode once they end.
+func (w *WindowDataLoadSender) flushDecoder() {
+	if w.decoder == nil && len(w.head) == 0 {
+		return
+	}
+
+	var x []byte
+	if w.decoder == nil {
+		w.startDecoding()
+		crlf := w.decoder.Encoding().CRLF
+		x, w.head = w.decoder.Decode(w.head), nil
+		w.checkDecoderFallBack(crlf)
+	}
+	// Flush can't fall back since what it returns holds no newlines.
+	x = append(x, w.decoder.Flush()...)
+	if len(x) > 0 {
+		w.sendDecodedContents(x)
+	}
+}
+
+func (w *WindowDataLoadSender) sendDecodedContents(x []byte) {
+	if w.load.Reload {
+		w.reloaded.Write(x)
+		return
+	}
+
+	if w.load.SpillThreshold > 0 && w.spillContents(x) {
+		return
+	}
 
 	log(LogCatgWin, "pump: got some contents\n")
-	w.work <- &winLoadData{job: w.load.GetJob(), win: w.load.Win, data: x, growBodyBehaviour: w.load.GrowBodyBehaviour}
+	if w.isTerminalOutput() {
+		x = w.cleanOutput(x)
+		x = w.limiter.limit(x, time.Now())
+	}
+	if h := w.load.Highlighter; h != nil {
+		x = h.filterLines(w.completeLines(x))
+	}
+	if len(x) == 0 {
+		return
+	}
+	if w.load.DecodeContents {
+		w.decodedSent += len(x)
+	}
+	w.sendData(x)
+}
+
+// completeLines returns the complete lines in the pending partial line followed by x, and
+// keeps the rest as the new partial line.
+func (w *WindowDataLoadSender) completeLines(x []byte) []byte {
+	i := bytes.LastIndexByte(x, '\n')
+	if i < 0 {
+		w.partialLine = append(w.partialLine, x...)
+		return nil
+	}
+
+	lines := append(w.partialLine, x[:i+1]...)
+	w.partialLine = append([]byte(nil), x[i+1:]...)
+	return lines
+}
+
+// noteOutput records that x was written after the contents sent so far.
+func (w *WindowDataLoadSender) noteOutput(x []byte) {
+	if len(x) == 0 {
+		return
+	}
+	w.sentOutput = true
+	w.endsWithNewline = x[len(x)-1] == '\n'
+}
+
+func (w *WindowDataLoadSender) sendData(x []byte) {
+	w.noteOutput(x)
+	d := &winLoadData{job: w.load.GetJob(), win: w.load.Win, data: x, growBodyBehaviour: w.load.GrowBodyBehaviour, overwriteLines: w.isTerminalOutput(), output: w.output}
+	if h := w.load.Highlighter; h != nil && !h.Stopped() {
+		d.highlighter = h
+		d.highlights = h.highlightsIn(x)
+	}
+	w.send(d)
 	if w.load.Tail {
-		w.work <- &winLoadGoToEnd{job: w.load.GetJob(), win: w.load.Win}
+		w.send(&winLoadGoToEnd{job: w.load.GetJob(), win: w.load.Win})
+	}
+}
+
+// spillContents writes x to the spill file if the contents have exceeded the spill threshold.
+// It returns true if x was written to the spill file and not sent to the window.
+func (w *WindowDataLoadSender) spillContents(x []byte) (spilled bool) {
+	if w.spill != nil {
+		w.writeToSpill(x)
+		return true
+	}
+
+	w.loaded += int64(len(x))
+	if w.loaded <= w.load.SpillThreshold {
+		w.sent.Write(x)
+		return false
+	}
+
+	s, err := newSpill()
+	if err != nil {
+		w.sendError(fmt.Errorf("Output is large but creating a file to hold it failed: %w", err))
+		w.load.SpillThreshold = 0
+		w.sent = bytes.Buffer{}
+		return false
+	}
+
+	log(LogCatgWin, "pump: output exceeded %d bytes. Spilling to %s\n", w.load.SpillThreshold, s.path)
+	w.sent.Write(x)
+	w.noteOutput(w.sent.Bytes())
+	s.Write(w.sent.Bytes())
+	w.sent = bytes.Buffer{}
+	w.spill = s
+	w.send(&winSpillStarted{job: w.load.GetJob(), win: w.load.Win, spill: s, output: w.output})
+	w.output = nil
+	return true
+}
+
+// writeToSpill appends x to the spill file and tells the window that it grew.
+func (w *WindowDataLoadSender) writeToSpill(x []byte) {
+	if _, err := w.spill.Write(x); err != nil {
+		// The window was closed, or the disk is full.
+		log(LogCatgWin, "pump: writing to spill file failed: %v\n", err)
+		return
 	}
+	w.noteOutput(x)
+	w.send(&winSpillGrew{job: w.load.GetJob(), win: w.load.Win, spill: w.spill})
 }
 
 func (w *WindowDataLoadSender) updateStateWhenFilenamesClosed() {
@@ -97,9 +386,9 @@ func (w *WindowDataLoadSender) updateStateWhenFilenamesClosed() {
 	w.load.Filenames = nil
 }
 
-func (w *WindowDataLoadSender) sendFilenames(x []string) {
+func (w *WindowDataLoadSender) sendFilenames(x []DirEntry) {
 	w.sendType(typeDir)
-	w.work <- &winLoadNames{job: w.load.GetJob(), win: w.load.Win, names: x}
+	w.send(&winLoadNames{job: w.load.GetJob(), win: w.load.Win, entries: x})
 	log(LogCatgWin, "pump: got some filenames\n")
 }
 
@@ -111,14 +400,48 @@ func (w *WindowDataLoadSender) updateStateWhenErrorsClosed() {
 
 func (w *WindowDataLoadSender) sendError(x error) {
 	log(LogCatgWin, "pump: got an error: %v %T\n", x, x)
-	w.work <- &winLoadErr{job: w.load.GetJob(), win: w.load.Win, err: x}
+	w.load.failed.Store(true)
+	if w.load.ReportTermination && isTerminationError(x) {
+		// This is reported in the footer instead.
+		w.termination.err = x
+		return
+	}
+	w.send(&winLoadErr{job: w.load.GetJob(), win: w.load.Win, err: x})
+}
+
+// sendTermination writes a footer after the contents saying how the command ended. Commands that
+// succeed quickly get no footer unless the command-footer-always setting is on. Whether the tag
+// flashes when the command fails is up to the job-failed cue.
+func (w *WindowDataLoadSender) sendTermination() {
+	if !w.load.ReportTermination {
+		return
+	}
+
+	t := w.termination
+	t.started = w.load.started
+	t.killed = w.load.Killed()
+	end := time.Now()
+	if !t.reported(end) {
+		return
+	}
+
+	footer := t.footer(w.load.Jobname, end)
+	if w.sentOutput && !w.endsWithNewline {
+		footer = "\n" + footer
+	}
+	if w.spill != nil {
+		w.writeToSpill([]byte(footer))
+	} else {
+		w.sendData([]byte(footer))
+	}
 }
 
 func (w *WindowDataLoadSender) finalize() {
 	// If we are writing this to an existing errors window, don't do any of the normal finalization actions,
 	// just signify that the job is complete. This is to prevent popping up an empty errors window
 	if w.load.Win.LoadByName() {
-		w.work <- &winLoadDone{job: w.load.GetJob(), win: w.load.Win, selectBehaviour: w.load.SelectBehaviour}
+		w.sendTermination()
+		w.send(&winLoadDone{job: w.load.GetJob(), win: w.load.Win, selectBehaviour: w.load.SelectBehaviour, output: w.output})
 		return
 	}
 
@@ -127,7 +450,18 @@ func (w *WindowDataLoadSender) finalize() {
 	w.sendType(typeFile)
 
 	log(LogCatgWin, "pump done\n")
-	w.work <- &winLoadDone{job: w.load.GetJob(), win: w.load.Win, goTo: w.load.Goto, selectBehaviour: w.load.SelectBehaviour}
+	if w.load.Reload {
+		// The body is left as it was if the file couldn't be read completely. Otherwise the
+		// cursors are kept where they are unless the body has to be reset.
+		if !w.load.Failed() {
+			r := newBodyReload(w.load.ReloadOld, w.load.ReloadGeneration, w.reloaded.Bytes())
+			w.send(&winReloadData{job: w.load.GetJob(), win: w.load.Win, reload: r, goTo: w.load.Goto, selectBehaviour: w.load.SelectBehaviour})
+		}
+		w.send(&winLoadDone{job: w.load.GetJob(), win: w.load.Win, selectBehaviour: w.load.SelectBehaviour, reloadFailed: w.load.Failed(), read: w.read})
+		close(w.load.DataLoad.Kill)
+		return
+	}
+	w.send(&winLoadDone{job: w.load.GetJob(), win: w.load.Win, goTo: w.load.Goto, selectBehaviour: w.load.SelectBehaviour, output: w.output, read: w.read})
 	close(w.load.DataLoad.Kill)
 }
 
@@ -135,8 +469,21 @@ func (f *WindowDataLoad) pump(c chan Work) {
 	log(LogCatgWin, "pump started\n")
 
 	sender := WindowDataLoadSender{
-		work: c,
-		load: f,
+		work:    c,
+		load:    f,
+		limiter: newOutputRateLimiter(settings.General.OutputRateLimit),
+	}
+	if f.SpillThreshold > 0 {
+		sender.output = &jobOutput{}
+	}
+
+	// The contents that arrive within a frame are sent as one chunk so that a command that writes
+	// many small pieces of output doesn't make the window change once for each of them.
+	var batch outputBatcher
+	sendBatch := func() {
+		if x := batch.take(); len(x) > 0 {
+			sender.sendContents(x)
+		}
 	}
 
 FOR:
@@ -144,6 +491,7 @@ FOR:
 		select {
 		case x, ok := <-f.Contents:
 			if !ok {
+				sendBatch()
 				sender.updateStateWhenContentsClosed()
 				if sender.workIsDone() {
 					break FOR
@@ -151,7 +499,11 @@ FOR:
 				break
 			}
 
-			sender.sendContents(x)
+			if batch.add(x) {
+				sendBatch()
+			}
+		case <-batch.ready():
+			sendBatch()
 		case x, ok := <-f.Filenames:
 			if !ok {
 				sender.updateStateWhenFilenamesClosed()
@@ -171,10 +523,12 @@ FOR:
 				break
 			}
 
+			sendBatch()
 			sender.sendError(x)
 		}
 	}
 
+	sendBatch()
 	sender.finalize()
 	log(LogCatgWin, "pump finished\n")
 }
@@ -187,6 +541,10 @@ const (
 )
 
 func (l *WindowDataLoad) Kill() {
+	l.killed.Store(true)
+	if l.Highlighter != nil {
+		l.Highlighter.Stop()
+	}
 	select {
 	case l.DataLoad.Kill <- struct{}{}:
 	default:
@@ -197,18 +555,42 @@ func (l *WindowDataLoad) Name() string {
 	return l.Jobname
 }
 
+// Cleanup stops highlighting the contents, in case the job was removed before they ended.
+func (l *WindowDataLoad) Cleanup() {
+	if l.Highlighter != nil {
+		l.Highlighter.Stop()
+	}
+}
+
+func (l *WindowDataLoad) Failed() bool {
+	return l.failed.Load()
+}
+
+func (l *WindowDataLoad) Killed() bool {
+	return l.killed.Load()
+}
+
 // WindowDataChunk is a chunk of data to be written to a window, or an error
 type winLoadData struct {
 	job               Job
 	win               WindowHolder
 	data              []byte
 	growBodyBehaviour growBodyBehaviour
+	// highlights are the byte ranges within data to highlight using highlighter.
+	highlights  [][]int
+	highlighter *contentHighlighter
+	// overwriteLines is set if a carriage return in data moves back to the start of the line,
+	// so that the text after it replaces the line.
+	overwriteLines bool
+	// output, if set, keeps track of the text the job wrote to the window, since its output may
+	// be spilled.
+	output *jobOutput
 }
 
 type winLoadNames struct {
-	job   Job
-	win   WindowHolder
-	names []string
+	job     Job
+	win     WindowHolder
+	entries []DirEntry
 }
 
 type winLoadErr struct {
@@ -222,6 +604,13 @@ type winLoadDone struct {
 	win             WindowHolder
 	goTo            seek
 	selectBehaviour selectBehaviour
+	// reloadFailed is set when reading the file for a Reload failed. The body was left as it
+	// was, so any changes in it are still unsaved.
+	reloadFailed bool
+	// output is the text the job wrote to the window if its output could have been spilled.
+	output *jobOutput
+	// read is the number of bytes of contents loaded, before they were decoded.
+	read int64
 }
 
 type winLoadGoToEnd struct {
@@ -229,12 +618,40 @@ type winLoadGoToEnd struct {
 	win WindowHolder
 }
 
+type winSpillStarted struct {
+	job    Job
+	win    WindowHolder
+	spill  *spill
+	output *jobOutput
+}
+
+type winSpillGrew struct {
+	job   Job
+	win   WindowHolder
+	spill *spill
+}
+
 type winSetFiletype struct {
 	job      Job
 	win      WindowHolder
 	fileType fileType
 }
 
+type winSetEncoding struct {
+	job      Job
+	win      WindowHolder
+	encoding textenc.Encoding
+}
+
+// winRestoreCR puts the carriage returns back in the first n bytes of the window body, which
+// were decoded from a file with CRLF line endings before a line was found that ends with just a
+// newline.
+type winRestoreCR struct {
+	job Job
+	win WindowHolder
+	n   int
+}
+
 type Work interface {
 	// Service returns true if this work completes the job, otherwise it returns false
 	Service() (done bool)
@@ -244,10 +661,14 @@ type Work interface {
 
 func (l winLoadData) Service() (done bool) {
 	win := l.win.Get()
-	win.Append(l.data)
+	if l.overwriteLines {
+		l.appendOverwritingLines(win)
+	} else {
+		l.appendPart(win, 0, len(l.data))
+	}
 	if l.growBodyBehaviour == growBodyIfTooSmall {
 		win.showIfHidden()
-		win.GrowIfBodyTooSmall()
+		win.GrowForOutputIfBodyTooSmall()
 		editor.SetOnlyFlashedWindow(win)
 	}
 
@@ -255,13 +676,67 @@ func (l winLoadData) Service() (done bool) {
 	return false
 }
 
+// appendOverwritingLines appends the data to win the way a terminal would show it: a carriage
+// return moves back to the start of the line, so the text after it replaces the line.
+func (l winLoadData) appendOverwritingLines(win *Window) {
+	from := 0
+	lineDeleted := false
+	for {
+		i := bytes.IndexByte(l.data[from:], '\r')
+		if i < 0 {
+			break
+		}
+		i += from
+		// Text that doesn't finish a line before the next carriage return would only be deleted again.
+		if !lineDeleted || bytes.IndexByte(l.data[from:i], '\n') >= 0 {
+			l.appendPart(win, from, i)
+			win.deleteLastLineOfBody()
+			lineDeleted = true
+		}
+		from = i + 1
+	}
+	l.appendPart(win, from, len(l.data))
+}
+
+// appendPart appends the bytes from to to of the data to the body of win.
+func (l winLoadData) appendPart(win *Window, from, to int) {
+	if from == to {
+		return
+	}
+	start := win.Append(l.data[from:to])
+	if l.output != nil {
+		win.noteJobOutput(l.output, start, win.Body.text.Len())
+	}
+	l.highlight(win, start, from, to)
+}
+
+// highlight adds manual highlights to the body of win for the highlights of the bytes from
+// to to of the data, which were appended to the body at rune index start.
+func (l winLoadData) highlight(win *Window, start, from, to int) {
+	if l.highlighter == nil || l.highlighter.Stopped() {
+		return
+	}
+
+	var ranges [][]int
+	for _, r := range l.highlights {
+		if r[1] <= from || r[0] >= to {
+			continue
+		}
+		ranges = append(ranges, []int{max(r[0], from) - from, min(r[1], to) - from})
+	}
+
+	for _, r := range byteRangesToRuneRanges(l.data[from:to], ranges) {
+		win.Body.AddManualHighlight(start+r[0], start+r[1], l.highlighter.color)
+	}
+}
+
 func (l winLoadData) Job() Job {
 	return l.job
 }
 
 func (l winLoadNames) Service() (done bool) {
 	win := l.win.Get()
-	win.filler.AppendItems(l.names)
+	win.appendDirEntries(l.entries)
 	win.SetTag()
 	return false
 }
@@ -271,24 +746,32 @@ func (l winLoadNames) Job() Job {
 }
 
 func (l winLoadErr) Service() (done bool) {
-	dir := ""
-	win := l.win.Get()
-	if win != nil {
-		f := NewFileFinder(win)
-		d, err := f.WindowDir()
-		if err == nil {
-			dir = d
-		}
-	}
-	editor.AppendError(dir, l.err.Error())
+	editor.AppendError(errorsDirOfWindow(l.win.Get()), l.err.Error())
 	return true
 }
 
+// errorsDirOfWindow returns the directory of the +Errors window that errors about win are
+// written to.
+func errorsDirOfWindow(win *Window) string {
+	if win == nil {
+		return ""
+	}
+	d, err := NewFileFinder(win).WindowDir()
+	if err != nil {
+		return ""
+	}
+	return d
+}
+
 func (l winLoadErr) Job() Job {
 	return l.job
 }
 
 func (l winLoadDone) Service() (done bool) {
+	if l.output != nil && l.output.win != nil {
+		l.output.win.forgetJobOutput(l.output)
+	}
+
 	// If we are writing this to an existing errors window, don't do any of the normal finalization actions,
 	// just signify that the job is complete. This is to prevent popping up an empty errors window
 	if l.win.LoadByName() {
@@ -297,7 +780,11 @@ func (l winLoadDone) Service() (done bool) {
 
 	win := l.win.Get()
 	if win != nil {
-		win.markTextAsUnchanged()
+		if l.reloadFailed {
+			editor.AppendError(errorsDirOfWindow(win), fmt.Sprintf("Reading %s failed, so the window was left as it was", win.file))
+		} else {
+			win.markTextAsUnchanged()
+		}
 		win.SetTag()
 		win.Body.AddOpForNextLayout(func(gtx layout.Context) {
 			// This is to force a redraw
@@ -311,6 +798,12 @@ func (l winLoadDone) Service() (done bool) {
 			})
 		}
 		win.maybeEnableSyntax()
+		win.guessIndentation()
+		if l.reloadFailed {
+			win.stopFollowingAfterFailedLoad()
+		} else {
+			win.resumeFollowingAfterLoad(l.read)
+		}
 	}
 	return true
 }
@@ -321,6 +814,9 @@ func (l winLoadDone) Job() Job {
 
 func (l winLoadGoToEnd) Service() (done bool) {
 	win := l.win.Get()
+	if win.tailingSuspended {
+		return false
+	}
 	win.Body.AddOpForNextLayout(func(gtx layout.Context) {
 		win.Body.moveToEndOfDoc(gtx)
 	})
@@ -331,12 +827,40 @@ func (l winLoadGoToEnd) Job() Job {
 	return l.job
 }
 
+func (l winSpillStarted) Service() (done bool) {
+	win := l.win.Get()
+	if win == nil {
+		l.spill.Close()
+		return false
+	}
+	win.startSpill(l.spill, l.output)
+	return false
+}
+
+func (l winSpillStarted) Job() Job {
+	return l.job
+}
+
+func (l winSpillGrew) Service() (done bool) {
+	win := l.win.Get()
+	if win != nil && win.hasSpill(l.spill) {
+		win.spillGrew(l.spill)
+	}
+	return false
+}
+
+func (l winSpillGrew) Job() Job {
+	return l.job
+}
+
 func (l winSetFiletype) Service() (done bool) {
 	win := l.win.Get()
 	win.SetFilenameAndTag(win.file, l.fileType)
 
 	if l.fileType == typeDir {
+		win.dir.entries = nil
 		win.filler = NewFillEditableWithItemList(&win.Body.layouter, &win.layout.style, []string{})
+		win.filler.afterFill = win.highlightDirEntry
 		win.Body.SetPreDrawHook(win.filler.preDrawHook)
 	} else {
 		win.Body.SetPreDrawHook(nil)
@@ -349,9 +873,36 @@ func (l winSetFiletype) Job() Job {
 	return l.job
 }
 
+func (l winSetEncoding) Service() (done bool) {
+	if win := l.win.Get(); win != nil {
+		win.encoding = l.encoding
+	}
+	return false
+}
+
+func (l winSetEncoding) Job() Job {
+	return l.job
+}
+
+func (l winRestoreCR) Service() (done bool) {
+	win := l.win.Get()
+	if win == nil {
+		return false
+	}
+	b := win.Body.Bytes()
+	n := min(l.n, len(b))
+	win.Body.SetTextStringNoUndo(string(append(textenc.RestoreCR(b[:n]), b[n:]...)))
+	return false
+}
+
+func (l winRestoreCR) Job() Job {
+	return l.job
+}
+
 type WindowDataSave struct {
 	Jobname string
 	Win     *Window
+	File    string
 	errs    chan error
 	kill    chan struct{}
 }
@@ -382,6 +933,12 @@ func (s *WindowDataSave) wait(c chan Work) {
 		return
 	}
 	c <- &winLoadErr{job: s, err: e}
+	c <- basicWork{func() {
+		// The permissions might have changed since the file was loaded.
+		if s.Win.file == s.File {
+			s.Win.checkWritable()
+		}
+	}}
 	s.Win.notifyPut()
 }
 
@@ -391,8 +948,13 @@ type winSaveDone struct {
 }
 
 func (l winSaveDone) Service() (done bool) {
+	fileIndexes.invalidate(l.win.file)
 	l.win.markTextAsUnchanged()
 	l.win.SetTag()
+	autosave.forget(l.win.file, nil)
+	if p, err := NewGlobalPath(l.win.file, GlobalPathUnknown); err == nil && p.IsRemote() {
+		application.Cue(cueRemotePut, l.win)
+	}
 	return true
 }
 
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestCleanTerminalOutputAcrossChunks(t *testing.T) {
+	sender := WindowDataLoadSender{load: &WindowDataLoad{Win: NewWindowHolderForName("/tmp/+Errors")}}
+	if !sender.isTerminalOutput() {
+		t.Fatalf("expected output to an +Errors window to be terminal output")
+	}
+
+	chunks := []string{"\x1b[2Kone\r", "\ntwo\x1b[", "1A\x1b[31mred\x1b[0m\r", "three"}
+	expected := []string{"one", "\ntwo", "\x1b[31mred\x1b[0m", "\rthree"}
+	for i, c := range chunks {
+		x := sender.cleanOutput([]byte(c))
+		if string(x) != expected[i] {
+			t.Fatalf("chunk %d: expected %q but got %q", i, expected[i], x)
+		}
+	}
+
+	old := settings.General.RawOutput
+	settings.General.RawOutput = true
+	defer func() { settings.General.RawOutput = old }()
+	if sender.isTerminalOutput() {
+		t.Fatalf("expected output not to be cleaned when raw-output is set")
+	}
+}
+
+func TestCarriageReturnOverwritesLine(t *testing.T) {
+	newTestEditor()
+
+	win := editor.NewWindow(nil)
+	win.SetFilenameAndTag("+Errors", typeFile)
+	win.Body.SetText([]byte("start\n10%"))
+
+	tests := []struct {
+		data     string
+		expected string
+	}{
+		{"\r20%", "start\n20%"},
+		{"\r30%\r40%\r50%", "start\n50%"},
+		{"\rdone\nnext\r", "start\ndone\n"},
+		{"after", "start\ndone\nafter"},
+	}
+
+	for _, tc := range tests {
+		l := winLoadData{win: NewWindowHolder(win), data: []byte(tc.data), overwriteLines: true}
+		l.appendOverwritingLines(win)
+		if win.Body.String() != tc.expected {
+			t.Fatalf("after appending %q expected the body %q but got %q", tc.data, tc.expected, win.Body.String())
+		}
+	}
+}
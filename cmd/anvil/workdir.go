@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// CommandDir returns the directory that commands executed from the window run in: the one set
+// with Cd, or otherwise the directory of the window's file.
+func (f FileFinder) CommandDir() (path string, err error) {
+	if f.win != nil && f.win.workDir != "" {
+		return f.win.workDir, nil
+	}
+	return f.WindowDir()
+}
+
+// SetWorkDir sets the directory that commands executed from the window run in. dir is made
+// absolute relative to the directory commands run in now, and a remote directory is used as
+// is. An empty dir clears the directory, so that commands run in the directory of the file.
+func (w *Window) SetWorkDir(dir string) error {
+	if dir == "" {
+		w.workDir = ""
+		return nil
+	}
+
+	cur, err := NewFileFinder(w).CommandDir()
+	if err != nil {
+		return err
+	}
+	full, err := CommandExecutor{}.globalizeAndMakeAbsolute(cur, dir)
+	if err != nil {
+		return err
+	}
+	g, err := NewGlobalPath(full, GlobalPathIsDir)
+	if err != nil {
+		return err
+	}
+
+	// Remote directories aren't checked so as not to wait for an ssh connection.
+	if !g.IsRemote() {
+		fi, err := os.Stat(g.Path())
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			return fmt.Errorf("%s is not a directory", g.Path())
+		}
+	}
+
+	w.workDir = g.String()
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gioui.org/layout"
+)
+
+func TestCdSetsCommandDir(t *testing.T) {
+	startHeadlessEditor(t)
+
+	root := t.TempDir()
+	for _, d := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(root, d), 0755); err != nil {
+			t.Fatalf("creating the directory failed: %v", err)
+		}
+	}
+	file := filepath.Join(root, "a", "notes")
+
+	var dirs []string
+	var state *WindowState
+	var apiDir string
+	onMainGoroutine(func() {
+		w := editor.NewWindow(nil)
+		w.SetFilenameAndTag(file, typeFile)
+		cmdDir := func() string {
+			ctx := w.Tag.adapter.(*editableAdapter).buildCmdContext(&w.Tag.editable, layout.Context{}, nil)
+			return strings.TrimSuffix(ctx.Dir, string(filepath.Separator))
+		}
+
+		dirs = append(dirs, cmdDir())
+		NewCommandExecutor(w).Do("Cd ../b", &CmdContext{Dir: cmdDir()})
+		dirs = append(dirs, cmdDir())
+		NewCommandExecutor(w).Do("Cd missing", &CmdContext{Dir: cmdDir()})
+		dirs = append(dirs, cmdDir())
+		state = w.State()
+		apiDir = ApiHandler{}.buildWindowOnMainGoroutine(w).Dir
+		NewCommandExecutor(w).Do("Cd -", &CmdContext{Dir: cmdDir()})
+		dirs = append(dirs, cmdDir())
+	})
+
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+	expected := []string{a, b, b, a}
+	for i := range expected {
+		if dirs[i] != expected[i] {
+			t.Fatalf("expected commands to run in %v as Cd was used but they ran in %v", expected, dirs)
+		}
+	}
+	if strings.TrimSuffix(state.WorkDir, string(filepath.Separator)) != b {
+		t.Fatalf("expected the directory to be saved in the state but it is %q", state.WorkDir)
+	}
+	if strings.TrimSuffix(apiDir, string(filepath.Separator)) != b {
+		t.Fatalf("expected the API to report the directory but it reported %q", apiDir)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// workPriority is the band a Work item is serviced in. The main goroutine services all the
+// work in a higher band before any in a lower band, so that heavy background work like the
+// output of a project-wide Find can't make typing stutter.
+type workPriority int
+
+const (
+	// workPriorityInteractive is for work the user is waiting on as they type or point, like
+	// applying completions or scrolling while dragging.
+	workPriorityInteractive workPriority = iota
+	// workPriorityHigh is for work that changes what is shown, like syntax highlighting.
+	workPriorityHigh
+	// workPriorityNormal is the band of work that doesn't declare one, like building
+	// completions and autosaving.
+	workPriorityNormal
+	// workPriorityLow is for bulk background work like refreshing file indexes and loading the
+	// output of searches.
+	workPriorityLow
+	numWorkPriorities
+)
+
+func (p workPriority) String() string {
+	switch p {
+	case workPriorityInteractive:
+		return "interactive"
+	case workPriorityHigh:
+		return "high"
+	case workPriorityNormal:
+		return "normal"
+	case workPriorityLow:
+		return "low"
+	}
+	return "unknown"
+}
+
+// prioritizedWork is implemented by Work that isn't serviced in the normal band.
+type prioritizedWork interface {
+	priority() workPriority
+}
+
+func priorityOfWork(w Work) workPriority {
+	if p, ok := w.(prioritizedWork); ok {
+		return p.priority()
+	}
+	return workPriorityNormal
+}
+
+// workWithPriority is a Work item serviced in the band p.
+type workWithPriority struct {
+	Work
+	p workPriority
+}
+
+func (w workWithPriority) priority() workPriority {
+	return w.p
+}
+
+// withPriority returns w serviced in the band p.
+func withPriority(w Work, p workPriority) Work {
+	if p == workPriorityNormal {
+		return w
+	}
+	return workWithPriority{w, p}
+}
+
+// workQueueBandCapacity is how many items may be queued in a band before producers that stream
+// work wait for room in it.
+const workQueueBandCapacity = 256
+
+// maxWorkWait is how long a band with work waits for higher bands to drain before its next item
+// is serviced anyway, so that a busy band can't starve the others.
+const maxWorkWait = 500 * time.Millisecond
+
+// workQueue reads the Work sent to the editor's work channel and hands it to the main
+// goroutine highest band first. Items in the same band are handed over in the order they
+// were sent, so the items for one job stay in order as long as they are in one band.
+//
+// The work channel is always read so that work the user is waiting on is never stuck behind
+// producers blocked sending background work. Instead, producers that stream work, like the
+// loads of command output, call waitForRoom before each send.
+type workQueue struct {
+	in    chan Work
+	out   chan Work
+	start sync.Once
+	bands [numWorkPriorities][]queuedWork
+	// handedOver is when an item in each band was last handed over.
+	handedOver [numWorkPriorities]time.Time
+	// roomLock protects depths, which producers waiting for room in a band watch.
+	roomLock sync.Mutex
+	room     *sync.Cond
+	depths   [numWorkPriorities]int
+	stats    workStats
+}
+
+type queuedWork struct {
+	Work
+	p      workPriority
+	queued time.Time
+}
+
+func newWorkQueue(in chan Work) *workQueue {
+	q := &workQueue{
+		in:  in,
+		out: make(chan Work),
+	}
+	q.room = sync.NewCond(&q.roomLock)
+	return q
+}
+
+// Ready returns the channel the work is handed to the main goroutine on.
+func (q *workQueue) Ready() chan Work {
+	q.start.Do(func() { go q.run() })
+	return q.out
+}
+
+func (q *workQueue) run() {
+	for {
+		var out chan Work
+		var next Work
+		p, ok := q.next(time.Now())
+		if ok {
+			out = q.out
+			next = q.bands[p][0].Work
+		}
+
+		select {
+		case w := <-q.in:
+			q.push(queuedWork{Work: w, p: priorityOfWork(w), queued: time.Now()})
+		case out <- next:
+			q.pop(p, time.Now())
+		}
+	}
+}
+
+// waitForRoom blocks while the band p is full.
+func (q *workQueue) waitForRoom(p workPriority) {
+	q.roomLock.Lock()
+	for q.depths[p] >= workQueueBandCapacity {
+		q.room.Wait()
+	}
+	q.roomLock.Unlock()
+}
+
+func (q *workQueue) push(w queuedWork) {
+	q.bands[w.p] = append(q.bands[w.p], w)
+	q.setDepth(w.p)
+}
+
+func (q *workQueue) setDepth(p workPriority) {
+	q.roomLock.Lock()
+	q.depths[p] = len(q.bands[p])
+	if q.depths[p] < workQueueBandCapacity {
+		q.room.Broadcast()
+	}
+	q.roomLock.Unlock()
+}
+
+func (q *workQueue) depth(p workPriority) int {
+	q.roomLock.Lock()
+	defer q.roomLock.Unlock()
+	return q.depths[p]
+}
+
+// next returns the band whose first item should be handed over next: the band that has waited
+// longest if it has waited more than maxWorkWait, or else the highest band with work.
+func (q *workQueue) next(now time.Time) (p workPriority, ok bool) {
+	var longest time.Duration
+	for b := workPriority(0); b < numWorkPriorities; b++ {
+		if len(q.bands[b]) == 0 {
+			continue
+		}
+		since := q.bands[b][0].queued
+		if q.handedOver[b].After(since) {
+			since = q.handedOver[b]
+		}
+		waited := now.Sub(since)
+		if !ok {
+			p, ok = b, true
+			if waited > maxWorkWait {
+				longest = waited
+			}
+			continue
+		}
+		if waited > maxWorkWait && waited > longest {
+			p, longest = b, waited
+		}
+	}
+	return
+}
+
+func (q *workQueue) pop(p workPriority, now time.Time) {
+	w := q.bands[p][0]
+	q.bands[p][0] = queuedWork{}
+	q.bands[p] = q.bands[p][1:]
+	if len(q.bands[p]) == 0 {
+		q.bands[p] = nil
+	}
+	q.handedOver[p] = now
+	q.setDepth(p)
+	q.stats.addWait(now, p, now.Sub(w.queued), len(q.bands[p]))
+}
+
+// serviced records that the main goroutine spent d servicing w.
+func (q *workQueue) serviced(w Work, d time.Duration) {
+	q.stats.addService(time.Now(), priorityOfWork(w), d)
+}
+
+// String describes the queue depths and the time spent in each band over the last minute.
+func (q *workQueue) String() string {
+	var buf bytes.Buffer
+	bands := q.stats.lastMinute(time.Now())
+	fmt.Fprintf(&buf, "Work queued and serviced in the last %d seconds:\n", len(q.stats.seconds))
+	fmt.Fprintf(&buf, "  %-12s %6s %10s %9s %10s %10s %12s\n", "band", "queued", "max queued", "serviced", "mean wait", "max wait", "service time")
+	for p := workPriority(0); p < numWorkPriorities; p++ {
+		b := bands[p]
+		var meanWait time.Duration
+		if b.items > 0 {
+			meanWait = b.wait / time.Duration(b.items)
+		}
+		fmt.Fprintf(&buf, "  %-12s %6d %10d %9d %10s %10s %12s\n", p, q.depth(p), b.maxDepth, b.items,
+			meanWait.Round(time.Microsecond), b.maxWait.Round(time.Microsecond), b.service.Round(time.Microsecond))
+	}
+	return buf.String()
+}
+
+// workStats holds what happened in each band for each of the last 60 seconds.
+type workStats struct {
+	lock    sync.Mutex
+	seconds [60]workStatsSecond
+}
+
+type workStatsSecond struct {
+	unix  int64
+	bands [numWorkPriorities]workBandStats
+}
+
+type workBandStats struct {
+	items    int
+	wait     time.Duration
+	maxWait  time.Duration
+	service  time.Duration
+	maxDepth int
+}
+
+// at returns the stats for the second containing now. The lock must be held.
+func (s *workStats) at(now time.Time) *workStatsSecond {
+	sec := &s.seconds[now.Unix()%int64(len(s.seconds))]
+	if sec.unix != now.Unix() {
+		*sec = workStatsSecond{unix: now.Unix()}
+	}
+	return sec
+}
+
+func (s *workStats) addWait(now time.Time, p workPriority, wait time.Duration, depth int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	b := &s.at(now).bands[p]
+	b.items++
+	b.wait += wait
+	b.maxWait = max(b.maxWait, wait)
+	b.maxDepth = max(b.maxDepth, depth+1)
+}
+
+func (s *workStats) addService(now time.Time, p workPriority, d time.Duration) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.at(now).bands[p].service += d
+}
+
+func (s *workStats) lastMinute(now time.Time) (bands [numWorkPriorities]workBandStats) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, sec := range s.seconds {
+		if now.Unix()-sec.unix >= int64(len(s.seconds)) {
+			continue
+		}
+		for p, b := range sec.bands {
+			t := &bands[p]
+			t.items += b.items
+			t.wait += b.wait
+			t.maxWait = max(t.maxWait, b.maxWait)
+			t.service += b.service
+			t.maxDepth = max(t.maxDepth, b.maxDepth)
+		}
+	}
+	return
+}
+
+// lastInteraction is when the user last typed or pressed a pointer button, in nanoseconds
+// since the epoch.
+var lastInteraction atomic.Int64
+
+func noteInteractiveActivity() {
+	lastInteraction.Store(time.Now().UnixNano())
+}
+
+const (
+	// interactiveQuietPeriod is how long after the user last typed or pointed that low
+	// priority background goroutines run at full speed.
+	interactiveQuietPeriod = 100 * time.Millisecond
+	// backgroundYieldSlice is how long low priority background goroutines pause at a time
+	// while the user is typing.
+	backgroundYieldSlice = 2 * time.Millisecond
+	// maxBackgroundYield is the longest a low priority background goroutine pauses at once,
+	// so that it still makes progress while the user types continuously.
+	maxBackgroundYield = 20 * time.Millisecond
+)
+
+// yieldToInteractiveWork is called by low priority background goroutines between slices of
+// their work. It pauses for a bounded time while the user is typing or pointing, leaving the
+// CPU to the main goroutine.
+func yieldToInteractiveWork() {
+	for waited := time.Duration(0); waited < maxBackgroundYield; waited += backgroundYieldSlice {
+		if time.Since(time.Unix(0, lastInteraction.Load())) > interactiveQuietPeriod {
+			return
+		}
+		time.Sleep(backgroundYieldSlice)
+	}
+}
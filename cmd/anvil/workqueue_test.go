@@ -0,0 +1,199 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWorkQueueServicesHigherBandsFirst(t *testing.T) {
+	q := newWorkQueue(nil)
+	start := time.Now()
+	var serviced []string
+	add := func(name string, p workPriority) {
+		w := withPriority(basicWork{func() { serviced = append(serviced, name) }}, p)
+		q.push(queuedWork{Work: w, p: priorityOfWork(w), queued: start})
+	}
+
+	add("low 1", workPriorityLow)
+	add("normal", workPriorityNormal)
+	add("low 2", workPriorityLow)
+	add("interactive", workPriorityInteractive)
+	add("high", workPriorityHigh)
+
+	for {
+		p, ok := q.next(start)
+		if !ok {
+			break
+		}
+		q.bands[p][0].Service()
+		q.pop(p, start)
+	}
+
+	expected := []string{"interactive", "high", "normal", "low 1", "low 2"}
+	if len(serviced) != len(expected) {
+		t.Fatalf("expected %v but got %v", expected, serviced)
+	}
+	for i := range expected {
+		if serviced[i] != expected[i] {
+			t.Fatalf("expected %v but got %v", expected, serviced)
+		}
+	}
+}
+
+func TestWorkQueueDoesNotStarveLowerBands(t *testing.T) {
+	q := newWorkQueue(nil)
+	start := time.Now()
+	q.push(queuedWork{Work: basicWork{}, p: workPriorityLow, queued: start})
+	q.push(queuedWork{Work: basicWork{}, p: workPriorityInteractive, queued: start.Add(time.Millisecond)})
+
+	if p, _ := q.next(start.Add(maxWorkWait / 2)); p != workPriorityInteractive {
+		t.Fatalf("expected the interactive band first but got the %s band", p)
+	}
+	if p, _ := q.next(start.Add(maxWorkWait * 2)); p != workPriorityLow {
+		t.Fatalf("expected the low band to be serviced once its item waited too long but got the %s band", p)
+	}
+}
+
+func TestKeystrokeIsServicedBeforeQueuedBackgroundWork(t *testing.T) {
+	q := newWorkQueue(nil)
+	start := time.Now()
+
+	// A project-wide Find and a file index refresh filled their bands before the keystroke.
+	for i := 0; i < workQueueBandCapacity; i++ {
+		q.push(queuedWork{Work: basicWork{}, p: workPriorityLow, queued: start})
+		q.push(queuedWork{Work: basicWork{}, p: workPriorityNormal, queued: start})
+	}
+	now := start.Add(maxWorkWait / 4)
+	q.push(queuedWork{Work: basicWork{}, p: workPriorityInteractive, queued: now})
+
+	if p, _ := q.next(now); p != workPriorityInteractive {
+		t.Fatalf("expected the keystroke to be serviced next but got the %s band", p)
+	}
+	q.pop(workPriorityInteractive, now)
+	if p, _ := q.next(now); p != workPriorityNormal {
+		t.Fatalf("expected the normal band after the keystroke but got the %s band", p)
+	}
+}
+
+func TestWorkQueueProducersWaitForRoom(t *testing.T) {
+	q := newWorkQueue(nil)
+	for i := 0; i < workQueueBandCapacity; i++ {
+		q.push(queuedWork{Work: basicWork{}, p: workPriorityLow})
+	}
+
+	waited := make(chan struct{})
+	go func() {
+		q.waitForRoom(workPriorityLow)
+		close(waited)
+	}()
+	q.waitForRoom(workPriorityInteractive)
+
+	select {
+	case <-waited:
+		t.Fatalf("expected the producer to wait while the band is full")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	q.pop(workPriorityLow, time.Now())
+	select {
+	case <-waited:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the producer to stop waiting once there was room")
+	}
+}
+
+// keystrokeLatencies simulates typing while a project-wide Find and a file index refresh load
+// their results, and returns how long each keystroke waited to be serviced. If prioritized is
+// false all the work is sent in the normal band, so keystrokes wait behind the background work.
+func keystrokeLatencies(prioritized bool, keystrokes int) []time.Duration {
+	in := make(chan Work)
+	q := newWorkQueue(in)
+	stop := make(chan struct{})
+	stopServicing := make(chan struct{})
+	var wg sync.WaitGroup
+
+	go func() {
+		for {
+			select {
+			case w := <-q.Ready():
+				start := time.Now()
+				w.Service()
+				q.serviced(w, time.Since(start))
+			case <-stopServicing:
+				return
+			}
+		}
+	}()
+
+	band := func(p workPriority) workPriority {
+		if prioritized {
+			return p
+		}
+		return workPriorityNormal
+	}
+
+	// Each item of Find output takes a while to append and highlight.
+	background := func(p workPriority, cost time.Duration) {
+		defer wg.Done()
+		p = band(p)
+		for {
+			w := withPriority(basicWork{func() { time.Sleep(cost) }}, p)
+			q.waitForRoom(p)
+			select {
+			case in <- w:
+			case <-stop:
+				return
+			}
+		}
+	}
+	wg.Add(5)
+	for i := 0; i < 3; i++ {
+		go background(workPriorityLow, time.Millisecond)
+	}
+	go background(workPriorityLow, 2*time.Millisecond)
+	go background(workPriorityNormal, 500*time.Microsecond)
+
+	latencies := make([]time.Duration, keystrokes)
+	for i := 0; i < keystrokes; i++ {
+		done := make(chan struct{})
+		sent := time.Now()
+		in <- withPriority(basicWork{func() {
+			latencies[i] = time.Since(sent)
+			close(done)
+		}}, band(workPriorityInteractive))
+		<-done
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Producers waiting for room need the work to be serviced to stop.
+	close(stop)
+	wg.Wait()
+	close(stopServicing)
+	return latencies
+}
+
+func p95(latencies []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), latencies...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)*95/100]
+}
+
+// BenchmarkKeystrokeLatency compares the p95 keystroke latency with and without priorities while a
+// project-wide Find and a file index refresh load their results.
+func BenchmarkKeystrokeLatency(b *testing.B) {
+	for _, prioritized := range []bool{true, false} {
+		name := "prioritized"
+		if !prioritized {
+			name = "unprioritized"
+		}
+		b.Run(name, func(b *testing.B) {
+			var latencies []time.Duration
+			for i := 0; i < b.N; i++ {
+				latencies = append(latencies, keystrokeLatencies(prioritized, 20)...)
+			}
+			b.ReportMetric(float64(p95(latencies))/float64(time.Millisecond), "p95-ms")
+		})
+	}
+}
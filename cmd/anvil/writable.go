@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// notWritableTagMarker is shown in the tag of a window whose file the user can't write.
+const notWritableTagMarker = "⊘"
+
+// fileIsWritable returns true if the current user can write the file at path. If the file
+// doesn't exist it returns true if the file could be created in its directory.
+func fileIsWritable(path string) (ok bool, err error) {
+	_, err = os.Stat(path)
+	if os.IsNotExist(err) {
+		path = filepath.Dir(path)
+	} else if err != nil {
+		return
+	}
+	return localFileIsWritable(path)
+}
+
+// checkWritable finds out in the background whether the user can write the file loaded in the
+// window, and marks the window as not writable if not. For remote files this involves a round
+// trip over ssh so it must not block loading the file.
+func (w *Window) checkWritable() {
+	path := w.file
+	if path == "" || w.fileType == typeDir {
+		return
+	}
+
+	if isArchiveMemberPath(path) {
+		w.setNotWritable(true)
+		return
+	}
+
+	go func() {
+		sfs, err := GetFs(path)
+		if err != nil {
+			log(LogCatgWin, "Window.checkWritable: getting filesystem for %s failed: %v\n", path, err)
+			return
+		}
+
+		ok, err := sfs.isWritable(path)
+		if err != nil {
+			log(LogCatgWin, "Window.checkWritable: checking %s failed: %v\n", path, err)
+			return
+		}
+
+		editor.WorkChan() <- basicWork{func() {
+			if w.file != path || w.fileType == typeDir {
+				return
+			}
+			w.setNotWritable(!ok)
+		}}
+	}()
+}
+
+func (w *Window) setNotWritable(b bool) {
+	if w.notWritable == b {
+		return
+	}
+	w.notWritable = b
+	w.notWritableNoticeShown = false
+	if !b {
+		w.editAnyway = false
+	}
+	w.SetTag()
+}
+
+// IsWritable returns false if the user is known to not be able to write the file in the window.
+func (w *Window) IsWritable() bool {
+	return !w.notWritable
+}
+
+// EditAnyway allows the body of a window whose file is not writable to be changed.
+func (w *Window) EditAnyway() {
+	w.editAnyway = true
+	w.SetTag()
+}
+
+// bodyModificationGuarded returns true if changes to the body are refused because the file
+// is not writable and the user hasn't executed Edit-anyway, because the window is following
+// the file, or because the body is shown by a presenter that doesn't allow editing.
+func (w *Window) bodyModificationGuarded() bool {
+	return w.bodyModificationRefusedReason() != ""
+}
+
+func (w *Window) bodyModificationRefusedReason() string {
+	if !w.Body.IsEditable() {
+		return fmt.Sprintf("the window body is shown using the %s presenter, which doesn't allow editing. Execute 'Present %s' to edit it.", w.Body.PresenterName(), defaultPresenterName)
+	}
+	if w.IsFollowing() {
+		return "the window is following the file. Execute 'Follow off' to change the body."
+	}
+	if w.notWritable && !w.editAnyway && isArchiveMemberPath(w.file) {
+		return "the file is inside an archive. Execute Edit-anyway to change the body anyway, and Extract-to-file to save it as a file of its own."
+	}
+	if w.notWritable && !w.editAnyway {
+		return "the file is not writable. Execute Edit-anyway to change the body anyway, but Put will fail unless the permissions change."
+	}
+	return ""
+}
+
+// refuseIfBodyCantBeChanged reports an error and returns true if the command cmd, whose output
+// replaces text in the body of the window it is executed in, can't change the body. This is
+// checked before the command is started, since otherwise its output is refused once it ends.
+func (c CommandExecutor) refuseIfBodyCantBeChanged(cmd string) bool {
+	w, ok := c.source.(*Window)
+	if !ok {
+		return false
+	}
+
+	if reason := w.bodyModificationRefusedReason(); reason != "" {
+		editor.AppendError("", fmt.Sprintf("%s: %s", cmd, reason))
+		return true
+	}
+	return false
+}
+
+// allowBodyModification is called before the body text is changed. It refuses the change
+// if the body may not be changed, and the first time that happens tells the user how to
+// change the body anyway.
+func (w *Window) allowBodyModification() bool {
+	reason := w.bodyModificationRefusedReason()
+	if reason == "" {
+		return true
+	}
+
+	if !w.notWritableNoticeShown {
+		w.notWritableNoticeShown = true
+		editor.AppendError("", fmt.Sprintf("%s: %s", w.file, reason))
+	}
+	return false
+}
+
+// notWritableTagCommands returns the extra commands shown in the tag of a window whose file is not writable.
+func (w *Window) notWritableTagCommands() string {
+	if w.notWritable && !w.editAnyway {
+		return " Edit-anyway"
+	}
+	return ""
+}
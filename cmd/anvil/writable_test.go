@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNotWritableWindowRefusesEditsUntilEditAnyway(t *testing.T) {
+	newTestEditor()
+
+	win := editor.NewWindow(nil)
+	win.SetFilenameAndTag("/etc/passwd", typeFile)
+	win.Body.SetText([]byte("root\n"))
+	win.setNotWritable(true)
+
+	tag := win.Tag.String()
+	if !strings.Contains(tag, notWritableTagMarker) || !strings.Contains(tag, "Edit-anyway") {
+		t.Fatalf("expected the tag to show the file is not writable but it is %q", tag)
+	}
+	win.UpdateFilenameFromTag()
+	if win.file != "/etc/passwd" {
+		t.Fatalf("expected the marker not to change the filename but it is %q", win.file)
+	}
+
+	win.Body.CursorIndices = []int{0}
+	win.Body.restoreCursorsIfModificationRefused(func() { win.Body.InsertText("x") })
+	if win.Body.String() != "root\n" || win.Body.CursorIndices[0] != 0 {
+		t.Fatalf("expected the body and cursor not to change but the body is %q and the cursor is at %d", win.Body.String(), win.Body.CursorIndices[0])
+	}
+	if !win.notWritableNoticeShown {
+		t.Fatalf("expected a notice to be shown when the body was changed")
+	}
+
+	win.EditAnyway()
+	if strings.Contains(win.Tag.String(), "Edit-anyway") {
+		t.Fatalf("expected Edit-anyway to be removed from the tag but it is %q", win.Tag.String())
+	}
+	win.Body.InsertText("x")
+	if win.Body.String() != "xroot\n" {
+		t.Fatalf("expected the body to change after Edit-anyway but it is %q", win.Body.String())
+	}
+
+	win.setNotWritable(false)
+	if strings.Contains(win.Tag.String(), notWritableTagMarker) {
+		t.Fatalf("expected the tag not to be marked once the file is writable but it is %q", win.Tag.String())
+	}
+}
+
+func TestFileIsWritable(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file")
+	err := os.WriteFile(path, []byte("x"), 0644)
+	if err != nil {
+		t.Fatalf("writing file failed: %v", err)
+	}
+
+	ok, err := fileIsWritable(path)
+	if err != nil || !ok {
+		t.Fatalf("expected %s to be writable but got %v, %v", path, ok, err)
+	}
+	ok, err = fileIsWritable(filepath.Join(dir, "new"))
+	if err != nil || !ok {
+		t.Fatalf("expected a new file in %s to be writable but got %v, %v", dir, ok, err)
+	}
+
+	if os.Geteuid() == 0 {
+		t.Skip("permissions aren't enforced for root")
+	}
+	err = os.Chmod(path, 0444)
+	if err != nil {
+		t.Fatalf("chmod failed: %v", err)
+	}
+	ok, err = fileIsWritable(path)
+	if err != nil || ok {
+		t.Fatalf("expected %s not to be writable but got %v, %v", path, ok, err)
+	}
+}
+
+func TestReadOnlyPresenterRefusesChanges(t *testing.T) {
+	h := newTestHeadless()
+
+	win := editor.Cols[0].NewWindow()
+	win.SetFilenameAndTag(filepath.Join(t.TempDir(), "a.bin"), typeFile)
+	win.Body.SetText([]byte("ab\n"))
+	win.Body.setToOneCursorIndex(0)
+	win.Body.InsertText("x")
+	h.Execute(&win.Tag, "Snap")
+	h.Execute(&win.Tag, "Present hex")
+
+	win.Body.InsertText("y")
+	h.Execute(&win.Tag, "Undo")
+	h.Execute(&win.Tag, "Clr")
+	win.Body.InsertText("z")
+	h.Execute(&win.Tag, "Revert")
+	if body := win.Body.String(); body != "xab\n" {
+		t.Fatalf("expected the body shown by the hex presenter not to change but it is %q", body)
+	}
+
+	h.Execute(&win.Tag, "Present text")
+	h.Execute(&win.Tag, "Undo")
+	if body := win.Body.String(); body != "ab\n" {
+		t.Fatalf("expected the body to change once it is shown as text but it is %q", body)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"gioui.org/layout"
+	"github.com/jeffwilliams/anvil/internal/intvl"
+)
+
+// whitespaceDisplay is how whitespace is shown in a window body, as set by the Ws command.
+type whitespaceDisplay int
+
+const (
+	whitespaceDisplayOff whitespaceDisplay = iota
+	// whitespaceDisplayTrailing draws the whitespace at the end of lines with a background colour.
+	whitespaceDisplayTrailing
+	// whitespaceDisplayTabs also draws tabs with a faint guide glyph.
+	whitespaceDisplayTabs
+)
+
+// SetWhitespaceDisplay sets how whitespace is shown in the text.
+func (e *editable) SetWhitespaceDisplay(d whitespaceDisplay) {
+	e.wsDisplay = d
+	// Tabs are drawn differently, so the text must be layed out again.
+	e.invalidateLayedoutText()
+}
+
+// trailingWhitespace is an interval of whitespace at the end of a line.
+type trailingWhitespace struct {
+	start, end int
+}
+
+func (t trailingWhitespace) Start() int {
+	return t.start
+}
+
+func (t trailingWhitespace) End() int {
+	return t.end
+}
+
+// tabGuide is the interval of a tab that is drawn with a guide glyph.
+type tabGuide struct {
+	start, end int
+}
+
+func (t tabGuide) Start() int {
+	return t.start
+}
+
+func (t tabGuide) End() int {
+	return t.end
+}
+
+// initStyleChangesFromWhitespaceDisplay adds the intervals for the whitespace that is shown.
+// Only the visible text is searched so that the cost depends on the size of the window rather
+// than the size of the text.
+func (e *editable) initStyleChangesFromWhitespaceDisplay(gtx layout.Context) {
+	if e.wsDisplay == whitespaceDisplayOff {
+		return
+	}
+
+	tabs := e.wsDisplay == whitespaceDisplayTabs
+	for _, i := range whitespaceIntervalsIn(e.visibleText(gtx), e.displayIndex(e.TopLeftIndex), tabs) {
+		e.styleSeq.AddWithoutSort(i)
+	}
+}
+
+// whitespaceIntervalsIn returns the intervals of trailing whitespace in text, which begins at
+// rune index start, and if tabs is true the intervals of the tabs.
+func whitespaceIntervalsIn(text []byte, start int, tabs bool) (intervals []intvl.Interval) {
+	forEachLine(text, func(line []byte, runeOffset int) {
+		trimmed := bytes.TrimRight(line, " \t")
+		lineStart := start + runeOffset
+
+		if tabs && bytes.IndexByte(line, '\t') >= 0 {
+			i := lineStart
+			for _, r := range string(line) {
+				if r == '\t' {
+					intervals = append(intervals, &tabGuide{start: i, end: i + 1})
+				}
+				i++
+			}
+		}
+
+		if n := len(line) - len(trimmed); n > 0 {
+			// Spaces and tabs are one byte each, so n is also the number of runes.
+			s := lineStart + utf8.RuneCount(trimmed)
+			intervals = append(intervals, &trailingWhitespace{start: s, end: s + n})
+		}
+	})
+	return
+}
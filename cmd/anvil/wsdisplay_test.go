@@ -0,0 +1,95 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWhitespaceIntervalsIn(t *testing.T) {
+	tests := []struct {
+		name             string
+		text             string
+		start            int
+		tabs             bool
+		expectedTrailing [][]int
+		expectedTabs     [][]int
+	}{
+		{
+			name: "no trailing whitespace",
+			text: "a b\n\tc\n",
+		},
+		{
+			name:             "trailing spaces and tabs",
+			text:             "a  \nb\t \nc\n",
+			expectedTrailing: [][]int{{1, 3}, {5, 7}},
+		},
+		{
+			name:             "blank line",
+			text:             "a\n   \nb",
+			expectedTrailing: [][]int{{2, 5}},
+		},
+		{
+			name:             "CRLF endings",
+			text:             "a \r\nb\r\n",
+			expectedTrailing: [][]int{{1, 2}},
+		},
+		{
+			name:             "offset by the start of the visible text",
+			text:             "é \n",
+			start:            10,
+			expectedTrailing: [][]int{{11, 12}},
+		},
+		{
+			name:             "tabs",
+			text:             "\tx\té\t\n",
+			tabs:             true,
+			expectedTrailing: [][]int{{4, 5}},
+			expectedTabs:     [][]int{{0, 1}, {2, 3}, {4, 5}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var trailing, tabs [][]int
+			for _, i := range whitespaceIntervalsIn([]byte(tc.text), tc.start, tc.tabs) {
+				switch i.(type) {
+				case *trailingWhitespace:
+					trailing = append(trailing, []int{i.Start(), i.End()})
+				case *tabGuide:
+					tabs = append(tabs, []int{i.Start(), i.End()})
+				}
+			}
+			if !reflect.DeepEqual(trailing, tc.expectedTrailing) {
+				t.Fatalf("expected trailing whitespace %v but got %v", tc.expectedTrailing, trailing)
+			}
+			if !reflect.DeepEqual(tabs, tc.expectedTabs) {
+				t.Fatalf("expected tabs %v but got %v", tc.expectedTabs, tabs)
+			}
+		})
+	}
+}
+
+func TestWsCommandIsKeptInState(t *testing.T) {
+	win := newZeroxTestWindow(t, "a \n\tb\n")
+	x := NewCommandExecutor(win)
+
+	x.Do("Ws", &CmdContext{})
+	if win.Body.wsDisplay != whitespaceDisplayTrailing {
+		t.Fatalf("expected Ws with no argument to show trailing whitespace but the display is %d", win.Body.wsDisplay)
+	}
+	x.Do("Ws", &CmdContext{})
+	if win.Body.wsDisplay != whitespaceDisplayOff {
+		t.Fatalf("expected Ws with no argument to toggle the display off but it is %d", win.Body.wsDisplay)
+	}
+
+	x.Do("Ws tabs", &CmdContext{})
+	state := win.Body.State(false)
+
+	other := editor.NewWindow(nil)
+	if err := other.Body.SetState(state); err != nil {
+		t.Fatalf("setting the state failed: %v", err)
+	}
+	if other.Body.wsDisplay != whitespaceDisplayTabs {
+		t.Fatalf("expected the display to be restored from the state but it is %d", other.Body.wsDisplay)
+	}
+}
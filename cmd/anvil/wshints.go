@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"gioui.org/f32"
+	"gioui.org/layout"
+	"gioui.org/op/clip"
+	"gioui.org/op/paint"
+	"gioui.org/unit"
+	"github.com/alecthomas/chroma/lexers"
+	"github.com/jeffwilliams/anvil/internal/intvl"
+	"github.com/jeffwilliams/anvil/internal/typeset"
+)
+
+// whitespaceHints are passive hints about whitespace in a file shown in a window body: a marker at
+// the end of the body if the file doesn't end with a newline, and a tint over the leading whitespace
+// of lines that are indented in the less common of tabs and spaces. They never change the text.
+type whitespaceHints struct {
+	// tabsRequired is true for languages like Makefiles where tab indentation is significant.
+	// Lines indented with tabs are never tinted for these.
+	tabsRequired bool
+
+	counts      indentCounts
+	countsValid bool
+
+	// tints is the cached list of tinted ranges in the text that was visible the last time
+	// they were computed. It begins at rune index tintsStart and covers tintsLen bytes.
+	tints      []intvl.Interval
+	tintsStart int
+	tintsLen   int
+	tintsValid bool
+}
+
+func newWhitespaceHints(path string) *whitespaceHints {
+	return &whitespaceHints{tabsRequired: tabsRequiredForFile(path)}
+}
+
+// textChanged invalidates the cached hints.
+func (h *whitespaceHints) textChanged() {
+	h.countsValid = false
+	h.tintsValid = false
+}
+
+func (h *whitespaceHints) indentCounts(text []byte) indentCounts {
+	if !h.countsValid {
+		h.counts = countIndentation(text)
+		h.countsValid = true
+	}
+	return h.counts
+}
+
+// tintsIn returns the intervals to tint in visible, which is the text beginning at rune index start.
+// The whole text is needed to decide which style of indentation is the less common.
+func (h *whitespaceHints) tintsIn(text, visible []byte, start int) []intvl.Interval {
+	if h.tintsValid && h.tintsStart == start && h.tintsLen == len(visible) {
+		return h.tints
+	}
+
+	minority := h.indentCounts(text).minority(h.tabsRequired)
+	h.tints = h.tints[:0]
+	for _, r := range leadingWhitespaceIndentedWith(visible, minority) {
+		h.tints = append(h.tints, &whitespaceTint{start: start + r[0], end: start + r[1]})
+	}
+	h.tintsStart, h.tintsLen, h.tintsValid = start, len(visible), true
+	return h.tints
+}
+
+// whitespaceTint is an interval of leading whitespace drawn with a faint background tint.
+type whitespaceTint struct {
+	start, end int
+}
+
+func (t whitespaceTint) Start() int {
+	return t.start
+}
+
+func (t whitespaceTint) End() int {
+	return t.end
+}
+
+// indentCounts counts the lines in a text that are indented with tabs and with spaces. Lines
+// that contain only whitespace are not counted.
+type indentCounts struct {
+	Tabs, Spaces int
+}
+
+func countIndentation(text []byte) (counts indentCounts) {
+	forEachLine(text, func(line []byte, runeOffset int) {
+		switch lineIndentation(line) {
+		case '\t':
+			counts.Tabs++
+		case ' ':
+			counts.Spaces++
+		}
+	})
+	return
+}
+
+// minority returns the indentation character used by the fewest lines, or 0 if the text doesn't
+// mix tabs and spaces. If tabsRequired is true tabs are never the minority.
+func (c indentCounts) minority(tabsRequired bool) byte {
+	if c.Tabs == 0 || c.Spaces == 0 {
+		return 0
+	}
+	if tabsRequired || c.Spaces <= c.Tabs {
+		return ' '
+	}
+	return '\t'
+}
+
+func (c indentCounts) String() string {
+	return fmt.Sprintf("%d lines indented with tabs, %d with spaces", c.Tabs, c.Spaces)
+}
+
+// lineIndentation returns the first character of line if the line is indented and contains more
+// than whitespace, otherwise 0.
+func lineIndentation(line []byte) byte {
+	if len(line) == 0 || (line[0] != '\t' && line[0] != ' ') {
+		return 0
+	}
+	if len(bytes.TrimLeft(line, " \t\r")) == 0 {
+		return 0
+	}
+	return line[0]
+}
+
+// leadingWhitespaceIndentedWith returns the rune ranges of the leading whitespace of the lines in
+// text that are indented with the character indent.
+func leadingWhitespaceIndentedWith(text []byte, indent byte) (ranges [][]int) {
+	if indent == 0 {
+		return
+	}
+
+	forEachLine(text, func(line []byte, runeOffset int) {
+		if lineIndentation(line) != indent {
+			return
+		}
+		n := len(line) - len(bytes.TrimLeft(line, " \t"))
+		ranges = append(ranges, []int{runeOffset, runeOffset + n})
+	})
+	return
+}
+
+// forEachLine calls fn for each line in text, without the line ending, along with the rune
+// offset of the start of the line.
+func forEachLine(text []byte, fn func(line []byte, runeOffset int)) {
+	runeOffset := 0
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n')
+		line := text
+		if i >= 0 {
+			line = text[:i]
+		}
+		fn(bytes.TrimSuffix(line, []byte("\r")), runeOffset)
+
+		if i < 0 {
+			break
+		}
+		runeOffset += utf8.RuneCount(text[:i+1])
+		text = text[i+1:]
+	}
+}
+
+// lacksFinalNewline returns true if text is not empty and doesn't end with a newline.
+func lacksFinalNewline(text []byte) bool {
+	return len(text) > 0 && text[len(text)-1] != '\n'
+}
+
+// tabsRequiredForFile returns true if path is a file in a language where indentation with tabs
+// is significant.
+func tabsRequiredForFile(path string) bool {
+	lexer := lexers.Match(filepath.Base(path))
+	return lexer != nil && strings.Contains(lexer.Config().Name, "Makefile")
+}
+
+func (e *editable) initStyleChangesFromWhitespaceHints(gtx layout.Context) {
+	if e.wsHints == nil {
+		return
+	}
+
+	for _, i := range e.wsHints.tintsIn(e.Bytes(), e.visibleText(gtx), e.TopLeftIndex) {
+		e.styleSeq.AddWithoutSort(i)
+	}
+}
+
+// whitespaceHintColor returns the color used to draw whitespace hints: the foreground color
+// of the text made mostly transparent.
+func (e *editable) whitespaceHintColor(alpha uint8) Color {
+	c := e.style.FgColor
+	c.A = alpha
+	return c
+}
+
+// drawMissingFinalNewlineMarker draws a struck-through return symbol after the last character
+// of the text if it is visible and isn't a newline.
+func (e *editable) drawMissingFinalNewlineMarker(gtx layout.Context, ltext typeset.Text) {
+	if e.wsHints == nil || !lacksFinalNewline(e.Bytes()) {
+		return
+	}
+
+	pos := e.findCursorsInSlice(gtx, &ltext, []int{e.text.Len()}, -1, -1)
+	if len(pos) == 0 {
+		return
+	}
+
+	lh := float32(e.lineHeight())
+	w := lh * 0.6
+	x := float32(pos[0].X + gtx.Metric.Dp(2))
+	y := float32(pos[0].Y)
+	pt := func(fx, fy float32) f32.Point {
+		return f32.Pt(x+fx*w, y+fy*lh)
+	}
+
+	var path clip.Path
+	path.Begin(gtx.Ops)
+	// The return arrow
+	path.MoveTo(pt(1, 0.25))
+	path.LineTo(pt(1, 0.6))
+	path.LineTo(pt(0, 0.6))
+	path.MoveTo(pt(0.3, 0.45))
+	path.LineTo(pt(0, 0.6))
+	path.LineTo(pt(0.3, 0.75))
+	// The strike through it
+	path.MoveTo(pt(0, 0.2))
+	path.LineTo(pt(1, 0.85))
+
+	stack := clip.Stroke{Path: path.End(), Width: float32(gtx.Metric.Dp(unit.Dp(1)))}.Op().Push(gtx.Ops)
+	paint.ColorOp{Color: color.NRGBA(e.whitespaceHintColor(0x80))}.Add(gtx.Ops)
+	paint.PaintOp{}.Add(gtx.Ops)
+	stack.Pop()
+}
+
+// whitespaceHintsEnabled returns true if whitespace hints should be shown in the window. They
+// are only shown for files, not for directories or windows like +Errors.
+func (w *Window) whitespaceHintsEnabled() bool {
+	if !settings.General.WhitespaceHints || w.whitespaceHintsOff {
+		return false
+	}
+	return w.fileType == typeFile && w.file != "" && !strings.HasPrefix(filepath.Base(w.file), "+")
+}
+
+// updateWhitespaceHints shows or hides the whitespace hints in the window body.
+func (w *Window) updateWhitespaceHints() {
+	if !w.whitespaceHintsEnabled() {
+		w.Body.wsHints = nil
+		return
+	}
+	w.Body.wsHints = newWhitespaceHints(w.file)
+}
+
+// whitespaceLintReport describes the whitespace problems in the window body.
+func (w *Window) whitespaceLintReport() string {
+	text := w.Body.Bytes()
+	counts := countIndentation(text)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s: %s", w.file, counts)
+	if counts.minority(tabsRequiredForFile(w.file)) != 0 {
+		buf.WriteString(" (mixed)")
+	}
+	if lacksFinalNewline(text) {
+		buf.WriteString("; no newline at end of file")
+	}
+	return buf.String()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWhitespaceHints(t *testing.T) {
+	tests := []struct {
+		name                string
+		path                string
+		text                string
+		expectedCounts      indentCounts
+		expectedTints       [][]int
+		expectedNoFinalLine bool
+	}{
+		{
+			name: "empty file",
+			path: "empty.go",
+			text: "",
+		},
+		{
+			name:           "consistent tabs",
+			path:           "a.go",
+			text:           "func f() {\n\tx()\n\ty()\n}\n",
+			expectedCounts: indentCounts{Tabs: 2},
+		},
+		{
+			name:           "mixed, spaces are the minority",
+			path:           "a.go",
+			text:           "{\n\tx()\n\ty()\n    z()\n}\n",
+			expectedCounts: indentCounts{Tabs: 2, Spaces: 1},
+			expectedTints:  [][]int{{12, 16}},
+		},
+		{
+			name:           "mixed, tabs are the minority",
+			path:           "a.py",
+			text:           "if x:\n    a\n    b\n\tc\n",
+			expectedCounts: indentCounts{Tabs: 1, Spaces: 2},
+			expectedTints:  [][]int{{18, 19}},
+		},
+		{
+			name:                "CRLF endings",
+			path:                "a.txt",
+			text:                "a\r\n  b\r\n\tc\r\n  d\r\n  \r\ne",
+			expectedCounts:      indentCounts{Tabs: 1, Spaces: 2},
+			expectedTints:       [][]int{{8, 9}},
+			expectedNoFinalLine: true,
+		},
+		{
+			name:           "CRLF ending counts as a final newline",
+			path:           "a.txt",
+			text:           "a\r\n",
+			expectedCounts: indentCounts{},
+		},
+		{
+			name:           "Makefile with tab-indented recipes",
+			path:           "/src/Makefile",
+			text:           "all:\n\tgo build\n\tgo test\n",
+			expectedCounts: indentCounts{Tabs: 2},
+		},
+		{
+			name:           "Makefile where tabs are the minority are not flagged",
+			path:           "rules.mk",
+			text:           "SRCS = a.c \\\n  b.c \\\n  c.c\nall:\n\tcc $(SRCS)\n",
+			expectedCounts: indentCounts{Tabs: 1, Spaces: 2},
+			expectedTints:  [][]int{{13, 15}, {21, 23}},
+		},
+		{
+			name:                "no final newline",
+			path:                "a.go",
+			text:                "package main",
+			expectedNoFinalLine: true,
+		},
+		{
+			name:           "multibyte text",
+			path:           "a.txt",
+			text:           "é\n\té\n\té\n  é\n",
+			expectedCounts: indentCounts{Tabs: 2, Spaces: 1},
+			expectedTints:  [][]int{{8, 10}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			text := []byte(tc.text)
+			h := newWhitespaceHints(tc.path)
+
+			counts := h.indentCounts(text)
+			if counts != tc.expectedCounts {
+				t.Fatalf("expected counts %+v but got %+v", tc.expectedCounts, counts)
+			}
+
+			var tints [][]int
+			for _, i := range h.tintsIn(text, text, 0) {
+				tints = append(tints, []int{i.Start(), i.End()})
+			}
+			if !reflect.DeepEqual(tints, tc.expectedTints) {
+				t.Fatalf("expected tints %v but got %v", tc.expectedTints, tints)
+			}
+
+			if lacksFinalNewline(text) != tc.expectedNoFinalLine {
+				t.Fatalf("expected lacksFinalNewline to be %v", tc.expectedNoFinalLine)
+			}
+		})
+	}
+}
+
+func TestWhitespaceHintsInvalidatedByTextChange(t *testing.T) {
+	h := newWhitespaceHints("a.go")
+	text := []byte("\ta\n  b\n\tc\n")
+	if n := len(h.tintsIn(text, text, 0)); n != 1 {
+		t.Fatalf("expected 1 tint but got %d", n)
+	}
+
+	// The cached tints are used until the text changes.
+	text = []byte("\ta\n\t\tb\n\tc\n")
+	if n := len(h.tintsIn(text, text, 0)); n != 1 {
+		t.Fatalf("expected cached tints but got %d", n)
+	}
+
+	h.textChanged()
+	if n := len(h.tintsIn(text, text, 0)); n != 0 {
+		t.Fatalf("expected no tints after the text changed but got %d", n)
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"gioui.org/layout"
+	"gioui.org/op"
+)
+
+// scrollFollow links the top of the body of a clone made with Zerox -follow to the top of the
+// body of the window it was made from, so that the clone shows what the source shows as it is
+// scrolled. The link is broken when the clone is scrolled itself. It isn't saved by Dump.
+type scrollFollow struct {
+	source *Window
+	// topLeft is the TopLeftIndex of the clone's body when it was last set from the source. If
+	// the clone's TopLeftIndex differs from it the clone was scrolled by the user.
+	topLeft int
+}
+
+// followScrollOf makes the body of the window, which must be a clone of source, follow the
+// scrolling of source's body.
+func (w *Window) followScrollOf(source *Window) {
+	w.scrollFollow = &scrollFollow{source: source, topLeft: w.Body.TopLeftIndex}
+}
+
+// stopFollowingScrollOf breaks the link to source if the window follows its scrolling.
+func (w *Window) stopFollowingScrollOf(source *Window) {
+	if w.scrollFollow != nil && w.scrollFollow.source == source {
+		w.scrollFollow = nil
+	}
+}
+
+// FollowsScrollOf returns true if the window follows the scrolling of source.
+func (w *Window) FollowsScrollOf(source *Window) bool {
+	return w.scrollFollow != nil && w.scrollFollow.source == source
+}
+
+// scrollFollowTopLeftShifted records the TopLeftIndex of the body after it was shifted because
+// the text changed, so that the shift isn't mistaken for the user scrolling the window.
+func (w *Window) scrollFollowTopLeftShifted() {
+	if w.scrollFollow != nil {
+		w.scrollFollow.topLeft = w.Body.TopLeftIndex
+	}
+}
+
+// scrolledSinceFollowing returns true if the window follows the scrolling of another one, and
+// was scrolled itself since it was last set to match it. The link is broken if so.
+func (w *Window) scrolledSinceFollowing() bool {
+	if w.scrollFollow == nil || w.Body.TopLeftIndex == w.scrollFollow.topLeft {
+		return false
+	}
+	log(LogCatgWin, "Window %d was scrolled; it no longer follows window %d\n", w.Id, w.scrollFollow.source.Id)
+	w.scrollFollow = nil
+	return true
+}
+
+// updateScrollFollowers scrolls the clones that follow the scrolling of the window to show the
+// same text as it. It's done after the window is laid out, since that is when the window is
+// scrolled.
+func (w *Window) updateScrollFollowers(gtx layout.Context) {
+	w.scrolledSinceFollowing()
+
+	changed := false
+	for c := range w.clones {
+		if c == w || !c.FollowsScrollOf(w) || c.scrolledSinceFollowing() {
+			continue
+		}
+		if c.Body.TopLeftIndex == w.Body.TopLeftIndex {
+			continue
+		}
+		c.Body.SetTopLeft(w.Body.TopLeftIndex)
+		c.scrollFollow.topLeft = c.Body.TopLeftIndex
+		changed = true
+	}
+
+	if changed && gtx.Ops != nil {
+		// The clone may have been drawn before this window in this frame.
+		gtx.Execute(op.InvalidateCmd{})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"encoding/binary"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
@@ -16,6 +17,7 @@ import (
 	"sync"
 	"syscall"
 
+	"github.com/pkg/sftp"
 	"github.com/spf13/pflag"
 	"golang.org/x/crypto/ssh"
 )
@@ -23,6 +25,8 @@ import (
 var optListenAddr *string
 var optAuthKeysFile *string
 var optHostKeyFile *string
+var optNoSftp *bool
+var optNoPty *bool
 
 func usage() {
 	fmt.Fprintf(os.Stderr, "Usage: %s [options]\n", os.Args[0])
@@ -58,6 +62,8 @@ func parseFlags() {
 	optListenAddr = pflag.StringP("addr", "a", "0.0.0.0:5001", "What address and port to listen on")
 	optAuthKeysFile = pflag.StringP("authkeys", "z", defAuthKeysFile, "file to load authorized keys from")
 	optHostKeyFile = pflag.StringP("hostkey", "k", defHostKeyFile, "file containing host private key")
+	optNoSftp = pflag.Bool("no-sftp", false, "disable the sftp subsystem")
+	optNoPty = pflag.Bool("no-pty", false, "refuse requests to run commands in a pseudo-terminal")
 
 	pflag.Parse()
 	pflag.Usage = usage
@@ -223,23 +229,34 @@ func processNewChannel(newChannel ssh.NewChannel) {
 		log.Printf("closed channel\n")
 	}()
 
-	initialChannelProps, ok := processInitialRequestsForExec(channel, requests)
+	initialChannelProps, ok := processInitialRequests(channel, requests)
+	pty := initialChannelProps.pty
+	defer pty.Close()
 	if !ok {
 		return
 	}
 
+	if initialChannelProps.subsystem == "sftp" {
+		serveSftp(channel, requests)
+		return
+	}
+
 	cmd := exec.Command("bash", "-c", initialChannelProps.cmd)
 	log.Printf("Running command: bash -c '%s'\n", initialChannelProps.cmd)
 
-	cmd.Stdin = channel
-	cmd.Stdout = channel
-	cmd.Stderr = channel
-	// Set the tree of processes we create to all have the same PGID, so that
-	// we can kill the PGID to kill all processes
-	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	if pty != nil {
+		pty.attach(cmd)
+	} else {
+		cmd.Stdin = channel
+		cmd.Stdout = channel
+		cmd.Stderr = channel
+		// Set the tree of processes we create to all have the same PGID, so that
+		// we can kill the PGID to kill all processes
+		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	}
 
 	killedProcess := make(chan struct{}, 1)
-	go processOngoingRequestsForExec(channel, cmd, requests, killedProcess)
+	go processOngoingRequestsForExec(channel, cmd, pty, requests, killedProcess)
 
 	err = cmd.Start()
 	if err != nil {
@@ -248,8 +265,17 @@ func processNewChannel(newChannel ssh.NewChannel) {
 		return
 	}
 
+	var ptyOutputDone <-chan struct{}
+	if pty != nil {
+		ptyOutputDone = pty.copy(channel)
+	}
+
 	var exitDueToSignal bool
 	err = cmd.Wait()
+	if ptyOutputDone != nil {
+		// Send all the output before the exit status
+		<-ptyOutputDone
+	}
 	if err != nil {
 		if errorIndicatesProcessExitedDueToSignal(err) {
 			log.Printf("Command exited due to signal\n")
@@ -272,13 +298,15 @@ func processNewChannel(newChannel ssh.NewChannel) {
 	log.Printf("Sent exit status\n")
 }
 
-func processOngoingRequestsForExec(channel ssh.Channel, cmd *exec.Cmd, requests <-chan *ssh.Request, killedProcess chan struct{}) {
+func processOngoingRequestsForExec(channel ssh.Channel, cmd *exec.Cmd, pty *sessionPty, requests <-chan *ssh.Request, killedProcess chan struct{}) {
 	for req := range requests {
 		logSshRequest("session", req)
 
 		switch req.Type {
 		case "signal":
 			processSignalReq(channel, cmd, req, killedProcess)
+		case "window-change":
+			processWindowChangeReq(pty, req)
 		}
 	}
 }
@@ -321,11 +349,21 @@ func processSignalReq(channel ssh.Channel, cmd *exec.Cmd, req *ssh.Request, kill
 type initialChannelProps struct {
 	env map[string]string
 	cmd string
+	// subsystem is the name of the subsystem requested instead of a command, if any
+	subsystem string
+	// pty is the pseudo-terminal requested for the command, if any
+	pty *sessionPty
 }
 
-func processInitialRequestsForExec(channel ssh.Channel, requests <-chan *ssh.Request) (props initialChannelProps, ok bool) {
+func processInitialRequests(channel ssh.Channel, requests <-chan *ssh.Request) (props initialChannelProps, ok bool) {
 	ok = true
 
+	sendReply := func(req *ssh.Request, b bool) {
+		if req.WantReply {
+			req.Reply(b, nil)
+		}
+	}
+
 loop:
 	// Process requests until we see one of "shell", "exec" or "subsystem" as per RFC 4254 section 6.5
 	for req := range requests {
@@ -334,13 +372,39 @@ loop:
 		switch req.Type {
 		case "env":
 			handleEnvRequest(req)
-		case "shell", "subsystem":
-			if req.WantReply {
-				req.Reply(false, nil)
+		case "pty-req":
+			if props.pty != nil || !ptySupported() {
+				log.Printf("Rejecting request for a pty")
+				sendReply(req, false)
+				continue
+			}
+			pty, err := allocatePty(req.Payload)
+			if err != nil {
+				log.Printf("Allocating pty failed: %v", err)
+				sendReply(req, false)
+				continue
 			}
+			props.pty = pty
+			sendReply(req, true)
+		case "window-change":
+			processWindowChangeReq(props.pty, req)
+		case "shell":
+			sendReply(req, false)
 			channel.Close()
 			ok = false
 			return
+		case "subsystem":
+			name, err := unmarshalString(req.Payload)
+			if err != nil || !subsystemSupported(name) {
+				log.Printf("Rejecting request for subsystem '%s'", name)
+				sendReply(req, false)
+				channel.Close()
+				ok = false
+				return
+			}
+			props.subsystem = name
+			sendReply(req, true)
+			return
 		case "exec":
 			var err error
 			props.cmd, err = unmarshalString(req.Payload)
@@ -364,13 +428,40 @@ loop:
 		}
 	}
 
-	if props.cmd == "" {
-		log.Printf("No exec message was seen on channel initialization")
+	if props.cmd == "" && props.subsystem == "" {
+		log.Printf("No exec or subsystem message was seen on channel initialization")
 		ok = false
 	}
 	return
 }
 
+func ptySupported() bool {
+	return optNoPty == nil || !*optNoPty
+}
+
+func subsystemSupported(name string) bool {
+	return name == "sftp" && (optNoSftp == nil || !*optNoSftp)
+}
+
+// serveSftp serves the sftp subsystem on the channel until the client closes it.
+func serveSftp(channel ssh.Channel, requests <-chan *ssh.Request) {
+	go ssh.DiscardRequests(requests)
+
+	server, err := sftp.NewServer(channel)
+	if err != nil {
+		log.Printf("Creating sftp server failed: %v\n", err)
+		return
+	}
+
+	log.Printf("Serving sftp\n")
+	err = server.Serve()
+	if err != nil && err != io.EOF {
+		log.Printf("Serving sftp failed: %v\n", err)
+	}
+	server.Close()
+	log.Printf("Finished serving sftp\n")
+}
+
 func logSshRequest(prefix string, r *ssh.Request) {
 	log.Printf("%s Request. type: %s want reply: %v payload:\n%s\n", prefix, r.Type, r.WantReply, hex.Dump(r.Payload))
 }
@@ -0,0 +1,406 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// TestApiPortReachableFromRemoteCommand checks that a command executed by anvsshd with
+// ANVIL_API_PORT set to the port of a listener forwarded over the connection (as Anvil does
+// when it runs commands remotely) can use that port to reach the client.
+func TestApiPortReachableFromRemoteCommand(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	addr, clientSigner := startTestServer(t)
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            "test",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(clientSigner)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("dialing server failed: %v", err)
+	}
+	defer client.Close()
+
+	// This is what Anvil does to serve the API to remote commands.
+	listener, err := client.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("requesting remote listener failed: %v", err)
+	}
+	defer listener.Close()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	if port == 0 {
+		t.Fatalf("server did not report the port of the remote listener")
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				b := make([]byte, 4)
+				if _, err := io.ReadFull(conn, b); err != nil {
+					return
+				}
+				conn.Write([]byte("pong"))
+			}()
+		}
+	}()
+
+	sess, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("creating session failed: %v", err)
+	}
+	defer sess.Close()
+
+	err = sess.Setenv("ANVIL_API_PORT", strconv.Itoa(port))
+	if err != nil {
+		t.Fatalf("setting environment failed: %v", err)
+	}
+
+	out, err := sess.Output(`exec 3<>/dev/tcp/127.0.0.1/$ANVIL_API_PORT; printf ping >&3; read -n 4 r <&3; printf %s "$r"`)
+	if err != nil {
+		t.Fatalf("running remote command failed: %v. Output: %s", err, out)
+	}
+
+	if string(out) != "pong" {
+		t.Fatalf("expected remote command to reach the client through ANVIL_API_PORT and read 'pong' but got '%s'", out)
+	}
+}
+
+// startTestServer starts anvsshd listening on a random local port and returns the address
+// and the signer that a client should use to authenticate.
+func startTestServer(t *testing.T) (addr string, clientSigner ssh.Signer) {
+	dir := t.TempDir()
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating host key failed: %v", err)
+	}
+	_, clientKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generating client key failed: %v", err)
+	}
+
+	blk, err := ssh.MarshalPrivateKey(hostKey, "")
+	if err != nil {
+		t.Fatalf("marshalling host key failed: %v", err)
+	}
+	hostKeyFile := filepath.Join(dir, "host_key")
+	if err := os.WriteFile(hostKeyFile, pem.EncodeToMemory(blk), 0600); err != nil {
+		t.Fatalf("writing host key failed: %v", err)
+	}
+
+	clientSigner, err = ssh.NewSignerFromKey(clientKey)
+	if err != nil {
+		t.Fatalf("creating client signer failed: %v", err)
+	}
+	authKeysFile := filepath.Join(dir, "authorized_keys")
+	if err := os.WriteFile(authKeysFile, ssh.MarshalAuthorizedKey(clientSigner.PublicKey()), 0600); err != nil {
+		t.Fatalf("writing authorized keys failed: %v", err)
+	}
+
+	optAuthKeysFile = &authKeysFile
+	optHostKeyFile = &hostKeyFile
+	config := buildServerConfig()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			nConn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go handleConnection(nConn, config)
+		}
+	}()
+
+	return listener.Addr().String(), clientSigner
+}
+
+func TestSftpRoundTrip(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	client := dialTestServer(t)
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		t.Fatalf("starting sftp client failed: %v", err)
+	}
+	defer sc.Close()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	const contents = "hello from sftp\n"
+
+	f, err := sc.Create(path)
+	if err != nil {
+		t.Fatalf("creating remote file failed: %v", err)
+	}
+	if _, err := f.Write([]byte(contents)); err != nil {
+		t.Fatalf("writing remote file failed: %v", err)
+	}
+	f.Close()
+
+	fi, err := sc.Stat(path)
+	if err != nil {
+		t.Fatalf("stat of remote file failed: %v", err)
+	}
+	if fi.Size() != int64(len(contents)) {
+		t.Fatalf("expected remote file to be %d bytes but it is %d", len(contents), fi.Size())
+	}
+
+	renamed := filepath.Join(dir, "renamed.txt")
+	if err := sc.Rename(path, renamed); err != nil {
+		t.Fatalf("renaming remote file failed: %v", err)
+	}
+
+	entries, err := sc.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("reading remote directory failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "renamed.txt" {
+		t.Fatalf("unexpected directory entries %v", entries)
+	}
+
+	f, err = sc.Open(renamed)
+	if err != nil {
+		t.Fatalf("opening remote file failed: %v", err)
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("reading remote file failed: %v", err)
+	}
+	if string(b) != contents {
+		t.Fatalf("expected remote file to contain %q but got %q", contents, b)
+	}
+
+	if err := sc.Remove(renamed); err != nil {
+		t.Fatalf("removing remote file failed: %v", err)
+	}
+	if _, err := os.Stat(renamed); !os.IsNotExist(err) {
+		t.Fatalf("expected remote file to be removed")
+	}
+}
+
+func TestSftpCanBeDisabled(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	noSftp := true
+	optNoSftp = &noSftp
+	defer func() { optNoSftp = nil }()
+
+	client := dialTestServer(t)
+
+	_, err := sftp.NewClient(client)
+	if err == nil {
+		t.Fatalf("expected sftp client to fail when the subsystem is disabled")
+	}
+}
+
+func dialTestServer(t *testing.T) *ssh.Client {
+	addr, clientSigner := startTestServer(t)
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            "test",
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(clientSigner)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+	})
+	if err != nil {
+		t.Fatalf("dialing server failed: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestPtySizeAndModes(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	client := dialTestServer(t)
+
+	sess, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("creating session failed: %v", err)
+	}
+	defer sess.Close()
+
+	err = sess.RequestPty("xterm", 40, 132, ssh.TerminalModes{ssh.ECHO: 0, ssh.ONLCR: 0})
+	if err != nil {
+		t.Fatalf("requesting pty failed: %v", err)
+	}
+
+	out, err := sess.Output(`stty size; echo $TERM; stty -a | grep -o -- '-echo\b'; test -t 0 && echo tty`)
+	if err != nil {
+		t.Fatalf("running remote command failed: %v. Output: %s", err, out)
+	}
+
+	if string(out) != "40 132\nxterm\n-echo\ntty\n" {
+		t.Fatalf("expected the command to see the size, type and modes of the requested terminal but got %q", out)
+	}
+}
+
+func TestPtyWindowChange(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	client := dialTestServer(t)
+
+	sess, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("creating session failed: %v", err)
+	}
+	defer sess.Close()
+
+	err = sess.RequestPty("xterm", 24, 80, ssh.TerminalModes{ssh.ECHO: 0})
+	if err != nil {
+		t.Fatalf("requesting pty failed: %v", err)
+	}
+
+	stdin, err := sess.StdinPipe()
+	if err != nil {
+		t.Fatalf("getting stdin failed: %v", err)
+	}
+	var out safeBuffer
+	sess.Stdout = &out
+
+	err = sess.Start(`while read l; do stty size; done`)
+	if err != nil {
+		t.Fatalf("starting remote command failed: %v", err)
+	}
+
+	waitFor := func(size string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !strings.Contains(out.String(), size) {
+			if time.Now().After(deadline) {
+				t.Fatalf("expected the command to see the size %q but the output is %q", size, out.String())
+			}
+			stdin.Write([]byte("\n"))
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+
+	waitFor("24 80")
+	err = sess.WindowChange(50, 100)
+	if err != nil {
+		t.Fatalf("changing window size failed: %v", err)
+	}
+	waitFor("50 100")
+}
+
+func TestPtySessionCanBeKilled(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	client := dialTestServer(t)
+
+	sess, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("creating session failed: %v", err)
+	}
+	defer sess.Close()
+
+	err = sess.RequestPty("xterm", 24, 80, ssh.TerminalModes{})
+	if err != nil {
+		t.Fatalf("requesting pty failed: %v", err)
+	}
+
+	var out safeBuffer
+	sess.Stdout = &out
+	err = sess.Start(`sleep 30 & echo started; sleep 30`)
+	if err != nil {
+		t.Fatalf("starting remote command failed: %v", err)
+	}
+
+	// A signal is only sent once the command is running
+	for i := 0; i < 250 && !strings.Contains(out.String(), "started"); i++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- sess.Wait() }()
+
+	sess.Signal(ssh.SIGKILL)
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("expected the killed command to fail")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected the command and its children to be killed")
+	}
+}
+
+func TestPtyCanBeDisabled(t *testing.T) {
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+
+	noPty := true
+	optNoPty = &noPty
+	defer func() { optNoPty = nil }()
+
+	client := dialTestServer(t)
+
+	sess, err := client.NewSession()
+	if err != nil {
+		t.Fatalf("creating session failed: %v", err)
+	}
+	defer sess.Close()
+
+	if err := sess.RequestPty("xterm", 24, 80, ssh.TerminalModes{}); err == nil {
+		t.Fatalf("expected the pty request to be refused when ptys are disabled")
+	}
+
+	out, err := sess.Output(`test -t 0 || echo notty`)
+	if err != nil || string(out) != "notty\n" {
+		t.Fatalf("expected the command to run without a terminal but got %q (%v)", out, err)
+	}
+}
+
+// safeBuffer is a bytes.Buffer that may be written and read from different goroutines.
+type safeBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *safeBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *safeBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/creack/pty"
+	"golang.org/x/crypto/ssh"
+)
+
+// sessionPty is a pseudo-terminal allocated for a session by a "pty-req" request. The command
+// executed in the session is run with the terminal as its controlling terminal.
+type sessionPty struct {
+	ptmx *os.File
+	tty  *os.File
+	term string
+}
+
+// ptyRequest is the payload of a "pty-req" request as per RFC 4254 section 6.2
+type ptyRequest struct {
+	Term          string
+	Columns, Rows uint32
+	Width, Height uint32
+	Modes         string
+}
+
+// windowChangeRequest is the payload of a "window-change" request as per RFC 4254 section 6.7
+type windowChangeRequest struct {
+	Columns, Rows uint32
+	Width, Height uint32
+}
+
+// allocatePty allocates a pseudo-terminal as asked for by the payload of a "pty-req" request,
+// with the requested window size and terminal modes.
+func allocatePty(payload []byte) (*sessionPty, error) {
+	var req ptyRequest
+	err := ssh.Unmarshal(payload, &req)
+	if err != nil {
+		return nil, fmt.Errorf("unmarshalling pty-req failed: %w", err)
+	}
+
+	ptmx, tty, err := pty.Open()
+	if err != nil {
+		return nil, fmt.Errorf("opening pty failed: %w", err)
+	}
+	p := &sessionPty{ptmx: ptmx, tty: tty, term: req.Term}
+
+	err = p.setSize(req.Columns, req.Rows, req.Width, req.Height)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	err = applyTerminalModes(tty, parseTerminalModes([]byte(req.Modes)))
+	if err != nil {
+		// The terminal is still usable with the default modes
+		log.Printf("Applying terminal modes failed: %v\n", err)
+	}
+	return p, nil
+}
+
+func (p *sessionPty) setSize(cols, rows, width, height uint32) error {
+	ws := &pty.Winsize{Cols: uint16(cols), Rows: uint16(rows), X: uint16(width), Y: uint16(height)}
+	err := pty.Setsize(p.ptmx, ws)
+	if err != nil {
+		return fmt.Errorf("setting pty size failed: %w", err)
+	}
+	return nil
+}
+
+// Close closes the terminal. It may be called on a nil sessionPty.
+func (p *sessionPty) Close() {
+	if p == nil {
+		return
+	}
+	p.ptmx.Close()
+	p.tty.Close()
+}
+
+// attach makes the terminal the controlling terminal and the stdin, stdout and stderr of cmd.
+// The command is run in a new session, which also makes it the leader of a new process group
+// with the same id as its pid so that the group can be killed as when there is no terminal.
+func (p *sessionPty) attach(cmd *exec.Cmd) {
+	cmd.Stdin = p.tty
+	cmd.Stdout = p.tty
+	cmd.Stderr = p.tty
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if p.term != "" {
+		cmd.Env = append(os.Environ(), "TERM="+p.term)
+	}
+}
+
+// copy copies the data sent on the channel to the terminal and the output of the terminal to
+// the channel. It must be called after the command is started. The returned channel is closed
+// once all the output is copied, which is when every process using the terminal closed it.
+func (p *sessionPty) copy(channel ssh.Channel) (done <-chan struct{}) {
+	// Only the command should hold the terminal open now, so that reading the output ends when
+	// the command and its children exit.
+	p.tty.Close()
+
+	go io.Copy(p.ptmx, channel)
+
+	ch := make(chan struct{})
+	go func() {
+		io.Copy(channel, p.ptmx)
+		close(ch)
+	}()
+	return ch
+}
+
+func processWindowChangeReq(p *sessionPty, req *ssh.Request) {
+	sendReply := func(b bool) {
+		if req.WantReply {
+			req.Reply(b, nil)
+		}
+	}
+
+	if p == nil {
+		log.Printf("Ignoring window-change request for a session without a pty\n")
+		sendReply(false)
+		return
+	}
+
+	var wc windowChangeRequest
+	err := ssh.Unmarshal(req.Payload, &wc)
+	if err != nil {
+		log.Printf("Unmarshalling window-change request failed: %v\n", err)
+		sendReply(false)
+		return
+	}
+
+	err = p.setSize(wc.Columns, wc.Rows, wc.Width, wc.Height)
+	if err != nil {
+		log.Printf("Handling window-change request failed: %v\n", err)
+		sendReply(false)
+		return
+	}
+	sendReply(true)
+}
+
+// terminalMode is one of the encoded terminal modes of a "pty-req" request.
+type terminalMode struct {
+	opcode byte
+	value  uint32
+}
+
+// ttyOpEnd is the opcode that ends the terminal modes, from RFC 4254 section 8
+const ttyOpEnd = 0
+
+// parseTerminalModes decodes the terminal modes of a "pty-req" request. Each is an opcode
+// followed by a uint32 argument, and the list ends at the opcode TTY_OP_END. Opcodes from 160
+// on are not defined and stop the parsing, as the RFC says they must.
+func parseTerminalModes(b []byte) (modes []terminalMode) {
+	for len(b) >= 5 {
+		op := b[0]
+		if op == ttyOpEnd || op >= 160 {
+			break
+		}
+		modes = append(modes, terminalMode{opcode: op, value: binary.BigEndian.Uint32(b[1:5])})
+		b = b[5:]
+	}
+	return
+}
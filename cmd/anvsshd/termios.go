@@ -0,0 +1,148 @@
+//go:build linux || darwin
+
+package main
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// The termios fields that terminal mode flags are set in
+const (
+	iflag = iota
+	oflag
+	cflag
+	lflag
+)
+
+type termiosFlag struct {
+	field int
+	bit   uint64
+}
+
+// Terminal mode opcodes from RFC 4254 section 8 that set control characters. Those that are
+// not supported on every system are left out.
+var terminalModeChars = map[byte]int{
+	1:  unix.VINTR,
+	2:  unix.VQUIT,
+	3:  unix.VERASE,
+	4:  unix.VKILL,
+	5:  unix.VEOF,
+	6:  unix.VEOL,
+	7:  unix.VEOL2,
+	8:  unix.VSTART,
+	9:  unix.VSTOP,
+	10: unix.VSUSP,
+	12: unix.VREPRINT,
+	13: unix.VWERASE,
+	14: unix.VLNEXT,
+	18: unix.VDISCARD,
+}
+
+// Terminal mode opcodes from RFC 4254 section 8 that set flags.
+var terminalModeFlags = map[byte]termiosFlag{
+	30: {iflag, unix.IGNPAR},
+	31: {iflag, unix.PARMRK},
+	32: {iflag, unix.INPCK},
+	33: {iflag, unix.ISTRIP},
+	34: {iflag, unix.INLCR},
+	35: {iflag, unix.IGNCR},
+	36: {iflag, unix.ICRNL},
+	38: {iflag, unix.IXON},
+	39: {iflag, unix.IXANY},
+	40: {iflag, unix.IXOFF},
+	41: {iflag, unix.IMAXBEL},
+	42: {iflag, unix.IUTF8},
+	50: {lflag, unix.ISIG},
+	51: {lflag, unix.ICANON},
+	53: {lflag, unix.ECHO},
+	54: {lflag, unix.ECHOE},
+	55: {lflag, unix.ECHOK},
+	56: {lflag, unix.ECHONL},
+	57: {lflag, unix.NOFLSH},
+	58: {lflag, unix.TOSTOP},
+	59: {lflag, unix.IEXTEN},
+	60: {lflag, unix.ECHOCTL},
+	61: {lflag, unix.ECHOKE},
+	62: {lflag, unix.PENDIN},
+	70: {oflag, unix.OPOST},
+	72: {oflag, unix.ONLCR},
+	73: {oflag, unix.OCRNL},
+	74: {oflag, unix.ONOCR},
+	75: {oflag, unix.ONLRET},
+	92: {cflag, unix.PARENB},
+	93: {cflag, unix.PARODD},
+}
+
+// Opcodes for the character size, which is a field of the cflags rather than a flag
+const (
+	ttyOpCS7 = 90
+	ttyOpCS8 = 91
+)
+
+// applyTerminalModes sets the terminal modes on the terminal tty. Modes that are not supported
+// are ignored, as are the terminal speeds since a pseudo-terminal has none.
+func applyTerminalModes(tty *os.File, modes []terminalMode) error {
+	if len(modes) == 0 {
+		return nil
+	}
+
+	fd := int(tty.Fd())
+	t, err := unix.IoctlGetTermios(fd, ioctlGetTermios)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range modes {
+		if i, ok := terminalModeChars[m.opcode]; ok {
+			t.Cc[i] = uint8(m.value)
+			continue
+		}
+
+		on := m.value != 0
+		switch m.opcode {
+		case ttyOpCS7:
+			if on {
+				setCharSize(&t.Cflag, unix.CS7)
+			}
+			continue
+		case ttyOpCS8:
+			if on {
+				setCharSize(&t.Cflag, unix.CS8)
+			}
+			continue
+		}
+
+		f, ok := terminalModeFlags[m.opcode]
+		if !ok {
+			continue
+		}
+		switch f.field {
+		case iflag:
+			setTermiosFlag(&t.Iflag, f.bit, on)
+		case oflag:
+			setTermiosFlag(&t.Oflag, f.bit, on)
+		case cflag:
+			setTermiosFlag(&t.Cflag, f.bit, on)
+		case lflag:
+			setTermiosFlag(&t.Lflag, f.bit, on)
+		}
+	}
+
+	return unix.IoctlSetTermios(fd, ioctlSetTermios, t)
+}
+
+// setTermiosFlag sets or clears the flag bit in the termios field f, whose type depends on the
+// system.
+func setTermiosFlag[T uint32 | uint64](f *T, bit uint64, on bool) {
+	if on {
+		*f |= T(bit)
+	} else {
+		*f &^= T(bit)
+	}
+}
+
+func setCharSize[T uint32 | uint64](cflag *T, size uint64) {
+	*cflag = *cflag&^T(unix.CSIZE) | T(size)
+}
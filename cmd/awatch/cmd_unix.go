@@ -2,9 +2,23 @@
 
 package main
 
-import "os/exec"
+import (
+	"os/exec"
+	"syscall"
+)
 
 func newCmd(cmd string) *exec.Cmd {
 	c := exec.Command("bash", "-c", cmd)
+	// Put the processes the command starts in their own process group so that they can all be
+	// killed together.
+	c.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	return c
 }
+
+// killCmd kills the process group of the command.
+func killCmd(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	syscall.Kill(-c.Process.Pid, syscall.SIGKILL)
+}
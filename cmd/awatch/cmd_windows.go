@@ -15,3 +15,10 @@ func newCmd(cmd string) *exec.Cmd {
 	}
 	return c
 }
+
+func killCmd(c *exec.Cmd) {
+	if c.Process == nil {
+		return
+	}
+	c.Process.Kill()
+}
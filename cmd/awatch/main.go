@@ -9,6 +9,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	api "github.com/jeffwilliams/anvil/pkg/anvil-go-api"
 
@@ -20,12 +21,35 @@ var (
 	httpApi  api.Anvil
 	cmds     = []string{}
 	watchWin api.Window
+	// triggers receives a value when a file was Put and the commands should be run again.
+	triggers = make(chan struct{}, 1)
+	// reconnected receives a value when the connection to Anvil was restored, since the watch
+	// window may have been closed while disconnected.
+	reconnected = make(chan struct{}, 1)
 )
 
 var (
-	optDebug = pflag.BoolP("debug", "d", false, "Print debug messages")
+	optDebug    = pflag.BoolP("debug", "d", false, "Print debug messages")
+	optCmds     commandList
+	optDebounce = pflag.DurationP("debounce", "b", 500*time.Millisecond, "How long to wait after a file is Put for more Puts before running the commands. The Puts made within this time cause one run")
 )
 
+func init() {
+	pflag.VarP(&optCmds, "cmd", "c", "A command to run. May be given more than once to run several commands in order")
+}
+
+// commandList is a flag that may be repeated to list more than one command.
+type commandList []string
+
+func (l *commandList) String() string {
+	return strings.Join(*l, "; ")
+}
+
+func (l *commandList) Set(cmd string) error {
+	*l = append(*l, cmd)
+	return nil
+}
+
 func main() {
 	pflag.Parse()
 
@@ -34,18 +58,21 @@ func main() {
 	dieIfError(err, "connecting to API failed")
 
 	handlers := api.WebsockHandlers{
-		Notification: handlePutNotification,
+		Notification:      handlePutNotification,
+		Reconnect:         true,
+		ConnectionChanged: handleConnectionChanged,
 	}
 
 	wsApi, err := httpApi.Websock(handlers)
 	dieIfError(err, "creating websocket failed")
 
-	loadFirstCommand()
+	loadCommands()
 	watchWin = findOrCreateWindow(&httpApi, watchPath())
 
-	runCmdsAndUpdateWindow()
+	go watch()
 
-	wsApi.Run()
+	err = wsApi.Run()
+	dieIfError(err, "lost the connection to Anvil")
 }
 
 func debug(format string, args ...interface{}) {
@@ -67,17 +94,45 @@ func die(msg string) {
 	os.Exit(1)
 }
 
-func loadFirstCommand() {
-	if len(pflag.Args()) < 1 {
-		die("no arguments were passed. The arguments must be a command to run")
+func loadCommands() {
+	cmds = append(cmds, optCmds...)
+	if len(pflag.Args()) > 0 {
+		cmds = append(cmds, strings.Join(pflag.Args(), " "))
 	}
 
-	cmds = append(cmds, strings.Join(pflag.Args(), " "))
+	if len(cmds) == 0 {
+		die("no commands were passed. Pass commands to run with -c, or as the arguments")
+	}
 }
 
-func run(cmd string) (output []byte, err error) {
+// run runs cmd and returns its combined output. If cancel is closed before cmd finishes, the
+// processes it started are killed and cancelled is true.
+func run(cmd string, cancel <-chan struct{}) (output []byte, state *os.ProcessState, cancelled bool, err error) {
 	c := newCmd(cmd)
-	return c.CombinedOutput()
+	var buf bytes.Buffer
+	c.Stdout = &buf
+	c.Stderr = &buf
+
+	err = c.Start()
+	if err != nil {
+		return
+	}
+
+	waitDone := make(chan error, 1)
+	go func() {
+		waitDone <- c.Wait()
+	}()
+
+	select {
+	case err = <-waitDone:
+	case <-cancel:
+		debug("awatch: killing command: %s\n", cmd)
+		killCmd(c)
+		err = <-waitDone
+		cancelled = true
+	}
+
+	return buf.Bytes(), c.ProcessState, cancelled, err
 }
 
 func findOrCreateWindow(anvil *api.Anvil, watchPath string) api.Window {
@@ -119,6 +174,19 @@ func setWindowTag(anvil *api.Anvil, winId int, watchPath string) {
 	anvil.Put(fmt.Sprintf("/wins/%d/tag", winId), &buf)
 }
 
+func handleConnectionChanged(state api.ConnectionState, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "awatch: lost the connection to Anvil, reconnecting: %v\n", err)
+		return
+	}
+	debug("awatch: %s to Anvil\n", state)
+
+	select {
+	case reconnected <- struct{}{}:
+	default:
+	}
+}
+
 func handlePutNotification(notif *api.Notification, err error) {
 	if err != nil {
 		// Parsing notification failed.
@@ -142,13 +210,13 @@ func handlePutNotification(notif *api.Notification, err error) {
 
 	localDir, err := filepath.Abs(os.Getenv("ANVIL_WIN_LOCAL_DIR"))
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "awatch: getting absolute path of %s failed: %v\n", os.Getenv("ANVIL_WIN_LOCAL_DIR"))
+		fmt.Fprintf(os.Stderr, "awatch: getting absolute path of %s failed: %v\n", os.Getenv("ANVIL_WIN_LOCAL_DIR"), err)
 		return
 	}
 
 	winPath, err := filepath.Abs(info.Path)
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "awatch: getting absolute path of %s failed: %v\n", info.Path)
+		fmt.Fprintf(os.Stderr, "awatch: getting absolute path of %s failed: %v\n", info.Path, err)
 		return
 	}
 
@@ -157,26 +225,85 @@ func handlePutNotification(notif *api.Notification, err error) {
 		return
 	}
 
-	runCmdsAndUpdateWindow()
+	select {
+	case triggers <- struct{}{}:
+	default:
+		// A run is already pending.
+	}
+}
+
+// watch runs the commands once at the start and then each time it is triggered. Triggers that
+// arrive within the debounce time of each other cause a single run, and a trigger stops the
+// commands if they are still running from the last time. After reconnecting to Anvil the watch
+// window is found or created again and the commands are run.
+func watch() {
+	var cancel, done chan struct{}
+	debounce := time.After(0)
+
+	stop := func() {
+		if cancel != nil {
+			close(cancel)
+			<-done
+			cancel = nil
+		}
+	}
+
+	for {
+		select {
+		case <-triggers:
+			stop()
+			debounce = time.After(*optDebounce)
+		case <-reconnected:
+			stop()
+			watchWin = findOrCreateWindow(&httpApi, watchPath())
+			debounce = time.After(0)
+		case <-debounce:
+			debounce = nil
+			cancel = make(chan struct{})
+			done = make(chan struct{})
+			go func(win api.Window, cancel, done chan struct{}) {
+				defer close(done)
+				runCmdsAndUpdateWindow(win, cancel)
+			}(watchWin, cancel, done)
+		}
+	}
 }
 
-func runCmdsAndUpdateWindow() {
-	output := runCmds()
-	httpApi.Put(fmt.Sprintf("/wins/%d/body", watchWin.Id), output)
+func runCmdsAndUpdateWindow(win api.Window, cancel <-chan struct{}) {
+	output, cancelled := runCmds(cancel)
+	if cancelled {
+		// The commands are run again with the newer files.
+		return
+	}
+	httpApi.Put(fmt.Sprintf("/wins/%d/body", win.Id), output)
 }
 
-func runCmds() (output *bytes.Buffer) {
+func runCmds(cancel <-chan struct{}) (output *bytes.Buffer, cancelled bool) {
 	buf := new(bytes.Buffer)
 
-	for _, c := range cmds {
+	for i, c := range cmds {
+		if i > 0 {
+			buf.WriteRune('\n')
+		}
 		fmt.Fprintf(buf, "%% %s\n", c)
 		debug("awatch: running command: %s\n", c)
-		output, err := run(c)
-		if err != nil {
-			fmt.Fprintf(buf, "(execution error: %v)\n", err)
+		start := time.Now()
+		output, state, cancelled, err := run(c, cancel)
+		if cancelled {
+			return buf, true
 		}
 		buf.Write(output)
+		if len(output) > 0 && output[len(output)-1] != '\n' {
+			buf.WriteRune('\n')
+		}
+
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if state == nil {
+			fmt.Fprintf(buf, "%% (execution error: %v)\n", err)
+			continue
+		}
+		fmt.Fprintf(buf, "%% (exit status %d after %v)\n", state.ExitCode(), elapsed)
 	}
 
-	return buf
+	return buf, false
 }
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunCmdsLabelsEachSection(t *testing.T) {
+	cmds = []string{"echo one", "echo two; exit 3"}
+	defer func() { cmds = nil }()
+
+	output, cancelled := runCmds(make(chan struct{}))
+	if cancelled {
+		t.Fatalf("expected the commands not to be cancelled")
+	}
+
+	lines := strings.Split(output.String(), "\n")
+	expected := []string{"% echo one", "one", "% (exit status 0 after", "", "% echo two; exit 3", "two", "% (exit status 3 after"}
+	if len(lines) < len(expected) {
+		t.Fatalf("expected at least %d lines but got:\n%s", len(expected), output)
+	}
+	for i, e := range expected {
+		if !strings.HasPrefix(lines[i], e) {
+			t.Fatalf("expected line %d to start with %q but it is %q", i, e, lines[i])
+		}
+	}
+}
+
+func TestRunIsCancelled(t *testing.T) {
+	cancel := make(chan struct{})
+	time.AfterFunc(100*time.Millisecond, func() { close(cancel) })
+
+	start := time.Now()
+	// The sleep runs in a child of the shell, which must be killed too.
+	_, _, cancelled, _ := run("sleep 10; echo done", cancel)
+	if !cancelled {
+		t.Fatalf("expected the command to be cancelled")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("cancelling the command took %v", d)
+	}
+}
@@ -27,3 +27,28 @@ func TestPromptOrLastFullLine(t *testing.T) {
 		})
 	}
 }
+
+func TestClean(t *testing.T) {
+
+	type test struct {
+		name, input, output string
+	}
+
+	tests := []test{
+		{"plain", "abc\r\n", "abc\n"},
+		{"color", "\x1b[31mred\x1b[0m", "red"},
+		{"title with bel", "\x1b]0;user@host: ~/src\x07$ ", "$ "},
+		{"title with st", "\x1b]2;a title\x1b\\>>> ", ">>> "},
+		{"bracketed paste", "\x1b[?2004h$ ", "$ "},
+	}
+
+	var p ProcessOutputHandler
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			output := p.clean([]byte(tc.input))
+			if output != tc.output {
+				t.Fatalf("For %q, expected %q does not match actual %q", tc.input, tc.output, output)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"regexp"
 	"strings"
 	"sync"
 	"time"
@@ -44,7 +45,7 @@ func main() {
 		os.Exit(1)
 	}
 
-	cmdStdin, cmdStdout, f, err := startCmd(cmdArgv)
+	cmdStdin, cmdStdout, ctl, f, err := startCmd(cmdArgv)
 	isTerminated = f
 	dieIfError(err, fmt.Sprintf("awin: Starting command failed: %v\n", err))
 
@@ -64,14 +65,16 @@ func main() {
 	compoundPath := compoundPathForTag(anvilGlobalPath, cmdArgv)
 	win := findOrCreateWindow(&anvil, compoundPath)
 	ttyWinId = win.Id
+	followedByAnvil := setTerminalMode(&anvil, win)
 
 	notifChan, lastLineChan, clearLastLineChan, procOutputChan := setupPlumbing()
 
 	go readNotifs(notifChan)
 	go readProcess(cmdStdout, procOutputChan)
-	np := NewNotificationProcessor(cmdStdin, notifChan, lastLineChan, clearLastLineChan)
+	np := NewNotificationProcessor(cmdStdin, ctl, notifChan, lastLineChan, clearLastLineChan)
 	go np.run()
 	oh := NewProcessOutputHandler(ttyWinId, procOutputChan, lastLineChan, clearLastLineChan)
+	oh.followedByAnvil = followedByAnvil
 	oh.run()
 }
 
@@ -113,18 +116,29 @@ func readNotifs(c chan<- []api.Notification) {
 	}
 }
 
+// processControl controls the terminal and process that the command is running in.
+type processControl interface {
+	// Resize sets the size of the terminal in characters.
+	Resize(cols, rows int) error
+	// Interrupt interrupts the process as if Ctrl-C was pressed in the terminal.
+	Interrupt() error
+}
+
 type NotificationProcessor struct {
 	lastLineFromProcess string
 	cmdStdin            io.Writer
+	ctl                 processControl
+	cols, rows          int
 	notifChan           <-chan []api.Notification
 	lastLineChan        <-chan string
 	clearLastLineChan   chan<- struct{}
 }
 
-func NewNotificationProcessor(cmdStdin io.Writer, nc <-chan []api.Notification,
+func NewNotificationProcessor(cmdStdin io.Writer, ctl processControl, nc <-chan []api.Notification,
 	lastLineChan <-chan string, clearLastLineChan chan<- struct{}) NotificationProcessor {
 	return NotificationProcessor{
 		cmdStdin:          cmdStdin,
+		ctl:               ctl,
 		notifChan:         nc,
 		lastLineChan:      lastLineChan,
 		clearLastLineChan: clearLastLineChan,
@@ -151,11 +165,12 @@ func (p *NotificationProcessor) processExecNotifs(notifs []api.Notification) {
 				continue
 			}
 
-			if n.Cmd[0] != "Send" {
-				continue
+			switch n.Cmd[0] {
+			case "Send":
+				p.processSendNotification(n)
+			case "Intr":
+				p.processIntrNotification(n)
 			}
-
-			p.processSendNotification(n)
 		}
 	}
 }
@@ -169,9 +184,37 @@ func (p *NotificationProcessor) processSendNotification(n api.Notification) {
 	}
 }
 
+func (p *NotificationProcessor) processIntrNotification(n api.Notification) {
+	debug("awin: interrupting process\n")
+	err := p.ctl.Interrupt()
+	if err != nil {
+		debug("awin: interrupting process failed: %v\n", err)
+	}
+}
+
+// resizeIfNeeded resizes the terminal of the process to match the size of the window body.
+func (p *NotificationProcessor) resizeIfNeeded(info api.WindowBody) {
+	if info.Cols <= 0 || info.Rows <= 0 {
+		return
+	}
+
+	if info.Cols == p.cols && info.Rows == p.rows {
+		return
+	}
+
+	debug("awin: resizing terminal to %dx%d\n", info.Cols, info.Rows)
+	err := p.ctl.Resize(info.Cols, info.Rows)
+	if err != nil {
+		debug("awin: resizing terminal failed: %v\n", err)
+		return
+	}
+	p.cols, p.rows = info.Cols, info.Rows
+}
+
 func (p *NotificationProcessor) processBodyChangeNotifs(notifs []api.Notification) {
 	var info api.WindowBody
 	anvil.GetInto(fmt.Sprintf("/wins/%d/body/info", ttyWinId), &info)
+	p.resizeIfNeeded(info)
 
 	isAppend := doNotifsContainAnAppend(notifs, info.Len)
 	if !isAppend {
@@ -239,6 +282,9 @@ type ProcessOutputHandler struct {
 	lastLineChan      chan<- string
 	clearLastLineChan <-chan struct{}
 	winId             int
+	// followedByAnvil is true if Anvil scrolls the window to the end of the output itself, so
+	// the cursor doesn't need to be moved there after each append.
+	followedByAnvil bool
 }
 
 func NewProcessOutputHandler(winId int, procOutput <-chan []byte, lastLineChan chan<- string, clearLastLineChan <-chan struct{}) ProcessOutputHandler {
@@ -272,7 +318,9 @@ func (p *ProcessOutputHandler) process(buf []byte) {
 	debug("awin: output from process: '%s'\n", cleaned)
 	debug("awin: last line from process: '%s'\n", lastLineFromProcess)
 	p.appendText([]byte(cleaned))
-	p.moveCursorToEndOfBody()
+	if !p.followedByAnvil {
+		p.moveCursorToEndOfBody()
+	}
 }
 
 func (p *ProcessOutputHandler) updateLastLineAndSendNotifs(buf []byte) {
@@ -289,8 +337,13 @@ func (p *ProcessOutputHandler) updateLastLineAndSendNotifs(buf []byte) {
 
 }
 
+// oscSequence matches operating system commands, such as the sequences that set the terminal
+// title, which are terminated by BEL or ST.
+var oscSequence = regexp.MustCompile("\x1b\\][^\x07\x1b]*(?:\x07|\x1b\\\\)")
+
 func (p *ProcessOutputHandler) clean(buf []byte) string {
 	cleaned := strings.ReplaceAll(string(buf), "\r\n", "\n")
+	cleaned = oscSequence.ReplaceAllString(cleaned, "")
 	cleaned = stripansi.Strip(cleaned)
 	return cleaned
 }
@@ -339,6 +392,18 @@ func (p *ProcessOutputHandler) appendToWindowBody(buf []byte) {
 	anvil.Post(fmt.Sprintf("/wins/%d/body", p.winId), r)
 }
 
+// setTerminalMode asks Anvil to follow the output appended to the window, pausing while the user
+// scrolls up to read earlier output. It returns false if this version of Anvil doesn't
+// support window modes, in which case the cursor must be moved to the end after each append.
+func setTerminalMode(anvil *api.Anvil, win api.Window) bool {
+	err := anvil.SetWindowMode(win, api.WindowMode{FollowOutput: true, UserScrollPausesFollow: true})
+	if err != nil {
+		debug("awin: setting the window mode failed, so the cursor will be moved explicitly: %v\n", err)
+		return false
+	}
+	return true
+}
+
 func (p *ProcessOutputHandler) moveCursorToEndOfBody() {
 	var info api.WindowBody
 	anvil.GetInto(fmt.Sprintf("/wins/%d/body/info", p.winId), &info)
@@ -364,11 +429,11 @@ func stripPrompt(s, prompt string) string {
 }
 
 func registerSendCommand(anvil *api.Anvil) {
-	debug("awin: Registering Send command\n")
+	debug("awin: Registering Send and Intr commands\n")
 	var buf bytes.Buffer
-	buf.WriteString(`["Send"]`)
+	buf.WriteString(`["Send", "Intr"]`)
 	anvil.Post("/cmds", &buf)
-	debug("awin: Done registering Send command\n")
+	debug("awin: Done registering Send and Intr commands\n")
 }
 
 func findOrCreateWindow(anvil *api.Anvil, compoundPath string) api.Window {
@@ -405,7 +470,7 @@ func createNewWindow(anvil *api.Anvil) api.Window {
 
 func setWindowTag(anvil *api.Anvil, winId int, compoundPath string) {
 	var buf bytes.Buffer
-	fmt.Fprintf(&buf, "%s Del! Snarf | Look  Send ", compoundPath)
+	fmt.Fprintf(&buf, "%s Del! Snarf | Look  Send Intr ", compoundPath)
 	anvil.Put(fmt.Sprintf("/wins/%d/tag", winId), &buf)
 }
 
@@ -4,12 +4,14 @@ package main
 
 import (
 	"io"
+	"os"
 	"os/exec"
 
 	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
 )
 
-func startCmd(argv []string) (stdin io.Writer, stdout io.Reader, terminated func() bool, err error) {
+func startCmd(argv []string) (stdin io.Writer, stdout io.Reader, ctl processControl, terminated func() bool, err error) {
 	//fmt.Printf("Running command %s %s\n", os.Args[1], strings.Join(args, " "))
 
 	c := exec.Command(argv[0], argv[1:]...)
@@ -23,6 +25,7 @@ func startCmd(argv []string) (stdin io.Writer, stdout io.Reader, terminated func
 
 	stdin = tty
 	stdout = tty
+	ctl = ptyControl{tty: tty, cmd: c}
 
 	ch := make(chan struct{})
 	go func() {
@@ -41,3 +44,22 @@ func startCmd(argv []string) (stdin io.Writer, stdout io.Reader, terminated func
 
 	return
 }
+
+type ptyControl struct {
+	tty *os.File
+	cmd *exec.Cmd
+}
+
+func (p ptyControl) Resize(cols, rows int) error {
+	return pty.Setsize(p.tty, &pty.Winsize{Rows: uint16(rows), Cols: uint16(cols)})
+}
+
+// Interrupt sends SIGINT to the foreground process group of the terminal, which is the
+// group of the command unless it started a job of its own (like a shell does).
+func (p ptyControl) Interrupt() error {
+	pgrp, err := unix.IoctlGetInt(int(p.tty.Fd()), unix.TIOCGPGRP)
+	if err != nil || pgrp <= 0 {
+		pgrp = p.cmd.Process.Pid
+	}
+	return unix.Kill(-pgrp, unix.SIGINT)
+}
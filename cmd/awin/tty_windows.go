@@ -8,7 +8,7 @@ import (
 	"github.com/UserExistsError/conpty"
 )
 
-func startCmd(argv []string) (stdin io.Writer, stdout io.Reader, terminated func() bool, err error) {
+func startCmd(argv []string) (stdin io.Writer, stdout io.Reader, ctl processControl, terminated func() bool, err error) {
 	c := strings.Join(argv, " ")
 	debug("awin: running command '%s'\n", c)
 
@@ -20,6 +20,7 @@ func startCmd(argv []string) (stdin io.Writer, stdout io.Reader, terminated func
 
 	stdin = tty
 	stdout = tty
+	ctl = conptyControl{tty: tty}
 
 	ch := make(chan struct{})
 	go func() {
@@ -43,3 +44,18 @@ func startCmd(argv []string) (stdin io.Writer, stdout io.Reader, terminated func
 
 	return
 }
+
+type conptyControl struct {
+	tty *conpty.ConPty
+}
+
+func (c conptyControl) Resize(cols, rows int) error {
+	return c.tty.Resize(cols, rows)
+}
+
+// Interrupt writes Ctrl-C to the console, which the pseudo console turns into a
+// CTRL_C_EVENT for the processes attached to it.
+func (c conptyControl) Interrupt() error {
+	_, err := c.tty.Write([]byte{0x03})
+	return err
+}
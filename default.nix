@@ -19,7 +19,7 @@ buildGoModule {
 
   src = lib.cleanSource ./.;
 
-  vendorHash = "sha256-Pt3pBHOw1NJeNpLg24Fgqq5J0p2HPvmBF9YyMMfT8B8=";
+  vendorHash = "sha256-tm4AJ0nU2CcoMvYuCLds2zRBDF18Df/eazFqgd0rbu4=";
 
   nativeBuildInputs =
     [
@@ -30,6 +30,7 @@ require (
 	github.com/ogier/pflag v0.0.1
 	github.com/pelletier/go-toml v1.9.5
 	github.com/pkg/profile v1.6.0
+	github.com/pkg/sftp v1.13.6
 	github.com/sarpdag/boyermoore v0.0.0-20210425165139-a89ed1b5913b
 	github.com/speedata/hyphenation v1.0.2
 	github.com/spf13/pflag v1.0.5
@@ -45,6 +46,7 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/dlclark/regexp2 v1.11.0 // indirect
 	github.com/go-text/typesetting v0.1.2 // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/rivo/uniseg v0.2.0 // indirect
 	golang.org/x/exp v0.0.0-20240707233637-46b078467d37 // indirect
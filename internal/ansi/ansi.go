@@ -1,6 +1,7 @@
 package ansi
 
 import (
+	"bytes"
 	"image/color"
 	"unicode/utf8"
 
@@ -14,11 +15,101 @@ func InitColors(colors [16]color.NRGBA) {
 	}
 }
 
+var csiStart = []byte("\x1b[")
+
 func HasEscapeCodes(text []byte) bool {
-	return ansip.HasEscapeCodes(string(text))
+	return bytes.Contains(text, csiStart)
+}
+
+// maxCSILen is the longest CSI escape sequence that is recognized. It limits how much of the
+// end of the text is held back as a possibly incomplete sequence.
+const maxCSILen = 64
+
+// csiAt checks if text begins with a CSI (Control Sequence Introducer) escape sequence. It returns
+// the length of the sequence and its final byte, which identifies the function of the sequence.
+// If the text ends before the sequence does, incomplete is true.
+func csiAt(text []byte) (length int, final byte, incomplete bool) {
+	if !bytes.HasPrefix(text, csiStart) {
+		return 0, 0, len(text) == 1 && text[0] == '\x1b'
+	}
+
+	i := len(csiStart)
+	// Parameter bytes
+	for i < len(text) && text[i] >= 0x30 && text[i] <= 0x3f {
+		i++
+	}
+	// Intermediate bytes
+	for i < len(text) && text[i] >= 0x20 && text[i] <= 0x2f {
+		i++
+	}
+
+	if i == len(text) {
+		return 0, 0, i < maxCSILen
+	}
+	if i >= maxCSILen || text[i] < 0x40 || text[i] > 0x7e {
+		return 0, 0, false
+	}
+	return i + 1, text[i], false
+}
+
+// StripNonSGRSequences returns text with the CSI escape sequences removed except the SGR (Select
+// Graphic Rendition) sequences that set colors. These are sequences like the ones that move the
+// cursor or erase the line, which are used by progress bars and can't be shown in a window.
+//
+// If text ends with what might be the start of an escape sequence it is not included in stripped
+// but returned in incomplete, so that it can be prepended to the text that follows.
+func StripNonSGRSequences(text []byte) (stripped, incomplete []byte) {
+	stripped = make([]byte, 0, len(text))
+	for {
+		i := bytes.IndexByte(text, '\x1b')
+		if i < 0 {
+			stripped = append(stripped, text...)
+			return
+		}
+
+		stripped = append(stripped, text[:i]...)
+		text = text[i:]
+
+		l, final, inc := csiAt(text)
+		if inc {
+			incomplete = append([]byte(nil), text...)
+			return
+		}
+		if l == 0 {
+			stripped = append(stripped, text[0])
+			text = text[1:]
+			continue
+		}
+		if final == 'm' {
+			stripped = append(stripped, text[:l]...)
+		}
+		text = text[l:]
+	}
+}
+
+// withoutNonSGRSequences returns a copy of text where the escape character that begins each CSI
+// sequence other than SGR sequences is replaced by a space. The sequences are then treated as
+// ordinary text when parsing the colors, and the rune offsets of the text are unchanged.
+func withoutNonSGRSequences(text []byte) []byte {
+	text = append([]byte(nil), text...)
+	for i := 0; i < len(text); {
+		j := bytes.Index(text[i:], csiStart)
+		if j < 0 {
+			break
+		}
+		i += j
+
+		l, final, _ := csiAt(text[i:])
+		if l == 0 || final != 'm' {
+			text[i] = ' '
+		}
+		i++
+	}
+	return text
 }
 
 func HighlightColorEscapeSequences(text []byte, runeOffset int, makeInterval func(start, end int, color color.NRGBA) intvl.Interval) (seq []intvl.Interval, err error) {
+	text = withoutNonSGRSequences(text)
 	parsed, err := ansip.Parse(string(text), ansip.WithIgnoreInvalidCodes())
 	if err != nil {
 		return
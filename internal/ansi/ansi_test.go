@@ -0,0 +1,67 @@
+package ansi
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/jeffwilliams/anvil/internal/intvl"
+)
+
+func TestStripNonSGRSequences(t *testing.T) {
+
+	tests := []struct {
+		name               string
+		input              string
+		expected           string
+		expectedIncomplete string
+	}{
+		{name: "plain", input: "hello\n", expected: "hello\n"},
+		{name: "color kept", input: "\x1b[31mred\x1b[0m", expected: "\x1b[31mred\x1b[0m"},
+		{name: "erase line", input: "\x1b[2Kdone\n", expected: "done\n"},
+		{name: "cursor up", input: "a\x1b[1Ab", expected: "ab"},
+		{name: "private mode", input: "\x1b[?25lhidden\x1b[?25h", expected: "hidden"},
+		{name: "mixed", input: "\x1b[2K\x1b[32m50%\x1b[0m\x1b[1G", expected: "\x1b[32m50%\x1b[0m"},
+		{name: "lone escape kept", input: "a\x1bb", expected: "a\x1bb"},
+		{name: "invalid sequence kept", input: "a\x1b[1\x01b", expected: "a\x1b[1\x01b"},
+		{name: "incomplete at end", input: "abc\x1b[1", expected: "abc", expectedIncomplete: "\x1b[1"},
+		{name: "escape at end", input: "abc\x1b", expected: "abc", expectedIncomplete: "\x1b"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual, incomplete := StripNonSGRSequences([]byte(tc.input))
+			if string(actual) != tc.expected {
+				t.Fatalf("expected %q but got %q", tc.expected, string(actual))
+			}
+			if string(incomplete) != tc.expectedIncomplete {
+				t.Fatalf("expected incomplete %q but got %q", tc.expectedIncomplete, string(incomplete))
+			}
+		})
+	}
+}
+
+func TestHighlightColorEscapeSequencesIgnoresNonSGRSequences(t *testing.T) {
+	var c [16]color.NRGBA
+	c[1] = color.NRGBA{R: 0xff, A: 0xff}
+	InitColors(c)
+
+	text := []byte("\x1b[2Kab\x1b[31mcd\x1b[0m")
+
+	type span struct{ start, end int }
+	var spans []span
+	makeInterval := func(start, end int, color color.NRGBA) intvl.Interval {
+		spans = append(spans, span{start, end})
+		return nil
+	}
+
+	_, err := HighlightColorEscapeSequences(text, 10, makeInterval)
+	if err != nil {
+		t.Fatalf("HighlightColorEscapeSequences failed: %v", err)
+	}
+
+	// The red run starts at the SGR sequence after "\x1b[2Kab" and ends before the reset.
+	expected := []span{{10 + 6, 10 + 13}}
+	if len(spans) != len(expected) || spans[0] != expected[0] {
+		t.Fatalf("expected %v but got %v", expected, spans)
+	}
+}
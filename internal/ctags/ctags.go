@@ -0,0 +1,70 @@
+// Package ctags finds and reads tags files in the ctags format described at
+// http://ctags.sourceforge.net/FORMAT.
+package ctags
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileName is the name of tags files.
+const FileName = "tags"
+
+// FindAll returns the paths of the tags files in dir and in each of the directories above it,
+// nearest first.
+func FindAll(dir string) (paths []string, err error) {
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return
+	}
+
+	for {
+		f := filepath.Join(dir, FileName)
+		if fi, err := os.Stat(f); err == nil && !fi.IsDir() {
+			paths = append(paths, f)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return
+		}
+		dir = parent
+	}
+}
+
+// Name returns the name of the tag on a line of a tags file. Lines starting with !_ hold
+// information about the file rather than tags, and ok is false for them and for empty lines.
+func Name(line string) (name string, ok bool) {
+	if line == "" || strings.HasPrefix(line, "!_") {
+		return "", false
+	}
+	name, _, _ = strings.Cut(line, "\t")
+	return name, name != ""
+}
+
+// Names returns the names of the tags in the tags file read from r, sorted and each only once.
+func Names(r io.Reader) (names []string, err error) {
+	s := bufio.NewScanner(r)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for s.Scan() {
+		if name, ok := Name(s.Text()); ok {
+			names = append(names, name)
+		}
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(names)
+	unique := names[:0]
+	for _, n := range names {
+		if len(unique) == 0 || n != unique[len(unique)-1] {
+			unique = append(unique, n)
+		}
+	}
+	return unique, nil
+}
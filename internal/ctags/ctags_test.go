@@ -0,0 +1,52 @@
+package ctags
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNames(t *testing.T) {
+	file := "!_TAG_FILE_FORMAT\t2\t/extended format/\n" +
+		"Open\tfile.go\t/^func Open(/;\"\tf\n" +
+		"Close\tfile.go\t12;\"\tf\n" +
+		"\n" +
+		"Open\tother.go\t/^func Open(/;\"\tf\n" +
+		"OpenFile\tfile.go\t20\n"
+
+	names, err := Names(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("reading the names failed: %v", err)
+	}
+	expected := []string{"Close", "Open", "OpenFile"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Fatalf("expected %v but got %v", expected, names)
+	}
+}
+
+func TestFindAll(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "a", "b")
+	if err := os.MkdirAll(sub, 0755); err != nil {
+		t.Fatalf("creating directories failed: %v", err)
+	}
+	for _, d := range []string{root, filepath.Join(root, "a", "b")} {
+		if err := os.WriteFile(filepath.Join(d, FileName), nil, 0644); err != nil {
+			t.Fatalf("writing tags file failed: %v", err)
+		}
+	}
+	// A directory named tags is not a tags file.
+	if err := os.Mkdir(filepath.Join(root, "a", FileName), 0755); err != nil {
+		t.Fatalf("creating directory failed: %v", err)
+	}
+
+	paths, err := FindAll(sub)
+	if err != nil {
+		t.Fatalf("finding tags files failed: %v", err)
+	}
+	if len(paths) < 2 || paths[0] != filepath.Join(sub, FileName) || paths[1] != filepath.Join(root, FileName) {
+		t.Fatalf("expected the tags files in %s and %s, nearest first, but got %v", sub, root, paths)
+	}
+}
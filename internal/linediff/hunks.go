@@ -0,0 +1,87 @@
+package linediff
+
+import "sort"
+
+// Hunk is a group of nearby edits with the unchanged lines around them, as shown in a unified
+// diff.
+type Hunk struct {
+	// OldStart and NewStart are the numbers, counting from 1, of the first line of the hunk in
+	// the old and new text. OldLines and NewLines are how many lines of each the hunk covers. As
+	// in unified diffs, when a hunk covers no lines of a text its start is the line before.
+	OldStart, OldLines int
+	NewStart, NewLines int
+	// Lines are the lines of the hunk, each beginning with ' ' if it is unchanged, '-' if it was
+	// deleted or '+' if it was inserted, and ending with a newline. A line that had no newline
+	// at the end of the text is followed by the line "\ No newline at end of file".
+	Lines []string
+}
+
+// Hunks groups the edits that Diff returned for old into hunks with context unchanged lines
+// before and after each edit. Edits that are no more than 2*context lines apart are put in the
+// same hunk.
+func Hunks(old []byte, edits []Edit, context int) (hunks []Hunk) {
+	lines, offsets := splitLines(old)
+	lineOf := func(offset int) int {
+		return sort.SearchInts(offsets, offset)
+	}
+
+	type span struct {
+		start, end int
+		text       [][]byte
+	}
+	spans := make([]span, len(edits))
+	for i, e := range edits {
+		text, _ := splitLines(e.Text)
+		spans[i] = span{lineOf(e.Start), lineOf(e.End), text}
+	}
+
+	addLine := func(h *Hunk, prefix byte, line []byte) {
+		l := string(prefix) + string(line)
+		if len(line) == 0 || line[len(line)-1] != '\n' {
+			l += "\n\\ No newline at end of file\n"
+		}
+		h.Lines = append(h.Lines, l)
+	}
+
+	delta := 0
+	for i := 0; i < len(spans); {
+		j := i + 1
+		for j < len(spans) && spans[j].start-spans[j-1].end <= 2*context {
+			j++
+		}
+
+		start := max(0, spans[i].start-context)
+		end := min(len(lines), spans[j-1].end+context)
+		h := Hunk{OldLines: end - start}
+		h.NewStart = start + delta
+
+		pos := start
+		for _, s := range spans[i:j] {
+			for ; pos < s.start; pos++ {
+				addLine(&h, ' ', lines[pos])
+			}
+			for ; pos < s.end; pos++ {
+				addLine(&h, '-', lines[pos])
+			}
+			for _, t := range s.text {
+				addLine(&h, '+', t)
+			}
+			delta += len(s.text) - (s.end - s.start)
+		}
+		for ; pos < end; pos++ {
+			addLine(&h, ' ', lines[pos])
+		}
+
+		h.NewLines = h.OldLines + start + delta - h.NewStart
+		h.OldStart = start
+		if h.OldLines > 0 {
+			h.OldStart++
+		}
+		if h.NewLines > 0 {
+			h.NewStart++
+		}
+		hunks = append(hunks, h)
+		i = j
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package linediff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func formatHunks(hunks []Hunk) string {
+	var b strings.Builder
+	for _, h := range hunks {
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+		for _, l := range h.Lines {
+			b.WriteString(l)
+		}
+	}
+	return b.String()
+}
+
+func TestHunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		context  int
+		expected string
+	}{
+		{
+			name:     "same",
+			old:      "a\nb\n",
+			new:      "a\nb\n",
+			context:  3,
+			expected: "",
+		},
+		{
+			name:     "changed line with context",
+			old:      "1\n2\n3\n4\n5\n6\n7\n",
+			new:      "1\n2\n3\nfour\n5\n6\n7\n",
+			context:  1,
+			expected: "@@ -3,3 +3,3 @@\n 3\n-4\n+four\n 5\n",
+		},
+		{
+			name:     "edits far apart are separate hunks",
+			old:      "1\n2\n3\n4\n5\n6\n7\n8\n",
+			new:      "0\n1\n2\n3\n4\n5\n6\n7\n",
+			context:  1,
+			expected: "@@ -1,1 +1,2 @@\n+0\n 1\n@@ -7,2 +8,1 @@\n 7\n-8\n",
+		},
+		{
+			name:     "edits close together share a hunk",
+			old:      "1\n2\n3\n4\n5\n",
+			new:      "one\n2\n3\nfour\n5\n",
+			context:  1,
+			expected: "@@ -1,5 +1,5 @@\n-1\n+one\n 2\n 3\n-4\n+four\n 5\n",
+		},
+		{
+			name:     "no newline at end",
+			old:      "a\nb",
+			new:      "a\nb\n",
+			context:  3,
+			expected: "@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hunks := Hunks([]byte(tc.old), Diff([]byte(tc.old), []byte(tc.new), 100), tc.context)
+			if got := formatHunks(hunks); got != tc.expected {
+				t.Fatalf("expected\n%s\nbut got\n%s", tc.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+// Package linediff finds the lines that differ between two texts.
+package linediff
+
+import "bytes"
+
+// Edit replaces the bytes in the range [Start,End) of the old text with Text.
+type Edit struct {
+	Start, End int
+	Text       []byte
+}
+
+// Diff returns the edits that change old into new, in order from the start of old. Each edit
+// replaces whole lines. If more than maxChanges lines were inserted or deleted, all the lines
+// between the first and the last that differ are replaced by one edit rather than finding the
+// shortest set of changes.
+func Diff(old, new []byte, maxChanges int) (edits []Edit) {
+	a, aOffs := splitLines(old)
+	b, bOffs := splitLines(new)
+	ids := map[string]int{}
+	aIds, bIds := lineIds(a, ids), lineIds(b, ids)
+
+	prefix := 0
+	for prefix < len(aIds) && prefix < len(bIds) && aIds[prefix] == bIds[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(aIds)-prefix && suffix < len(bIds)-prefix && aIds[len(aIds)-1-suffix] == bIds[len(bIds)-1-suffix] {
+		suffix++
+	}
+
+	aMid := aIds[prefix : len(aIds)-suffix]
+	bMid := bIds[prefix : len(bIds)-suffix]
+	if len(aMid) == 0 && len(bMid) == 0 {
+		return nil
+	}
+
+	edit := func(aStart, aEnd, bStart, bEnd int) Edit {
+		return Edit{
+			Start: aOffs[prefix+aStart],
+			End:   aOffs[prefix+aEnd],
+			Text:  new[bOffs[prefix+bStart]:bOffs[prefix+bEnd]],
+		}
+	}
+
+	if len(aMid) == 0 || len(bMid) == 0 {
+		return []Edit{edit(0, len(aMid), 0, len(bMid))}
+	}
+
+	matches, ok := matchingLines(aMid, bMid, maxChanges)
+	if !ok {
+		return []Edit{edit(0, len(aMid), 0, len(bMid))}
+	}
+
+	// Each run of lines between two matching lines is replaced by one edit.
+	x, y := 0, 0
+	for _, m := range append(matches, match{len(aMid), len(bMid)}) {
+		if m.x > x || m.y > y {
+			edits = append(edits, edit(x, m.x, y, m.y))
+		}
+		x, y = m.x+1, m.y+1
+	}
+	return
+}
+
+// splitLines splits text into lines that each include their newline. offsets holds the byte
+// offset of the start of each line followed by the length of the text.
+func splitLines(text []byte) (lines [][]byte, offsets []int) {
+	start := 0
+	for start < len(text) {
+		end := len(text)
+		if i := bytes.IndexByte(text[start:], '\n'); i >= 0 {
+			end = start + i + 1
+		}
+		lines = append(lines, text[start:end])
+		offsets = append(offsets, start)
+		start = end
+	}
+	offsets = append(offsets, len(text))
+	return
+}
+
+// lineIds returns a number for each line so that equal lines have the same number.
+func lineIds(lines [][]byte, ids map[string]int) []int {
+	r := make([]int, len(lines))
+	for i, l := range lines {
+		id, ok := ids[string(l)]
+		if !ok {
+			id = len(ids)
+			ids[string(l)] = id
+		}
+		r[i] = id
+	}
+	return r
+}
+
+// match is a line at index x in the old text that is kept as the line at index y in the new.
+type match struct {
+	x, y int
+}
+
+// matchingLines returns the lines kept between a and b when changing a into b with the fewest
+// insertions and deletions, using Myers' algorithm. ok is false if more than maxChanges are
+// needed. It takes time proportional to (len(a)+len(b))*maxChanges and memory proportional to
+// the square of maxChanges.
+func matchingLines(a, b []int, maxChanges int) (matches []match, ok bool) {
+	n, m := len(a), len(b)
+	maxD := min(n+m, maxChanges)
+	off := maxD + 1
+	v := make([]int, 2*maxD+3)
+
+	// trace holds the diagonals -d to d of v as they were before each round d, to find the path
+	// taken afterwards. Those are the only ones the round reads.
+	var trace [][]int
+	found := false
+	for d := 0; d <= maxD && !found; d++ {
+		trace = append(trace, append([]int(nil), v[off-d:off+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[off+k-1] < v[off+k+1]) {
+				x = v[off+k+1]
+			} else {
+				x = v[off+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[off+k] = x
+			if x >= n && y >= m {
+				found = true
+				break
+			}
+		}
+	}
+	if !found {
+		return nil, false
+	}
+
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		// v is indexed by the diagonal plus d.
+		v := trace[d]
+		k := x - y
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prevK := k - 1
+			if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+				prevK = k + 1
+			}
+			prevX = v[d+prevK]
+			prevY = prevX - prevK
+		}
+		for x > prevX && y > prevY {
+			x--
+			y--
+			matches = append(matches, match{x, y})
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(matches)-1; i < j; i, j = i+1, j-1 {
+		matches[i], matches[j] = matches[j], matches[i]
+	}
+	return matches, true
+}
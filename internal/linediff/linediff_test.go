@@ -0,0 +1,107 @@
+package linediff
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func apply(old string, edits []Edit) string {
+	var b strings.Builder
+	pos := 0
+	for _, e := range edits {
+		b.WriteString(old[pos:e.Start])
+		b.Write(e.Text)
+		pos = e.End
+	}
+	b.WriteString(old[pos:])
+	return b.String()
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name       string
+		old, new   string
+		maxChanges int
+		expected   []Edit
+	}{
+		{
+			name:       "same",
+			old:        "a\nb\n",
+			new:        "a\nb\n",
+			maxChanges: 10,
+			expected:   nil,
+		},
+		{
+			name:       "changed line",
+			old:        "a\nb\nc\n",
+			new:        "a\nB\nc\n",
+			maxChanges: 10,
+			expected:   []Edit{{2, 4, []byte("B\n")}},
+		},
+		{
+			name:       "inserted and deleted lines",
+			old:        "a\nb\nc\nd\n",
+			new:        "x\na\nc\nd\ny",
+			maxChanges: 10,
+			expected:   []Edit{{0, 0, []byte("x\n")}, {2, 4, nil}, {8, 8, []byte("y")}},
+		},
+		{
+			name:       "last line without newline",
+			old:        "a\nb",
+			new:        "a\nb\n",
+			maxChanges: 10,
+			expected:   []Edit{{2, 3, []byte("b\n")}},
+		},
+		{
+			name:       "to empty",
+			old:        "a\nb\n",
+			new:        "",
+			maxChanges: 10,
+			expected:   []Edit{{0, 4, nil}},
+		},
+		{
+			name:       "too many changes",
+			old:        "a\nb\nc\nd\ne\n",
+			new:        "a\nB\nc\nD\ne\n",
+			maxChanges: 3,
+			expected:   []Edit{{2, 8, []byte("B\nc\nD\n")}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			edits := Diff([]byte(tc.old), []byte(tc.new), tc.maxChanges)
+			if len(edits) != len(tc.expected) {
+				t.Fatalf("expected %d edits but got %d: %q", len(tc.expected), len(edits), edits)
+			}
+			for i, e := range edits {
+				x := tc.expected[i]
+				if e.Start != x.Start || e.End != x.End || string(e.Text) != string(x.Text) {
+					t.Fatalf("edit %d: expected %d-%d %q but got %d-%d %q", i, x.Start, x.End, x.Text, e.Start, e.End, e.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestDiffAppliesToNew(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomText := func() string {
+		var b strings.Builder
+		for i := r.Intn(30); i > 0; i-- {
+			b.WriteByte(byte('a' + r.Intn(4)))
+			b.WriteByte('\n')
+		}
+		return b.String()
+	}
+
+	for i := 0; i < 500; i++ {
+		old, new := randomText(), randomText()
+		for _, maxChanges := range []int{0, 5, 100} {
+			if got := apply(old, Diff([]byte(old), []byte(new), maxChanges)); got != new {
+				t.Fatalf("applying the diff of %q and %q with at most %d changes gave %q", old, new, maxChanges, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,6 @@
+//go:build !pctbldebug
+
+package pctbl
+
+// panicOnViolation makes misuse of transactions panic so that it is found where it happens.
+const panicOnViolation = false
@@ -204,6 +204,36 @@ func (c *OptimizedPieceTable) EndTransaction() {
 	c.ptbl.EndTransaction()
 }
 
+func (c *OptimizedPieceTable) StartTransactionFor(owner string) {
+	c.ptbl.StartTransactionFor(owner)
+}
+
+func (c *OptimizedPieceTable) RunInTransaction(owner string, fn func()) {
+	c.ptbl.RunInTransaction(owner, fn)
+}
+
+func (c *OptimizedPieceTable) TransactionDepth() int {
+	return c.ptbl.TransactionDepth()
+}
+
+// Check verifies the invariants of the piece table, and that the cached text is the text of the
+// table.
+func (c *OptimizedPieceTable) Check() (violations []string) {
+	violations = c.ptbl.Check()
+	if c.cachedBytes != nil && !bytes.Equal(c.cachedBytes, c.ptbl.Bytes()) {
+		violations = append(violations, "the cached text differs from the text of the table")
+	}
+	return
+}
+
+func (c *OptimizedPieceTable) StartOuterTransaction() {
+	c.ptbl.StartOuterTransaction()
+}
+
+func (c *OptimizedPieceTable) EndOuterTransaction() {
+	c.ptbl.EndOuterTransaction()
+}
+
 func (c *OptimizedPieceTable) TruncateLastInsert(countToRemove int) {
 	if countToRemove <= 0 {
 		return
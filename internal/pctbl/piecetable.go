@@ -57,6 +57,11 @@ type PieceTable struct {
 	mergeUndo            bool
 	undoData             []interface{}
 	skipNextAppend       bool
+	// txOwners holds the owner of each open transaction, innermost last.
+	txOwners []string
+	// sharedBuf is set for each buffer that a Snapshot refers to. Such a buffer is copied rather
+	// than overwritten in place when it is truncated.
+	sharedBuf [3]bool
 }
 
 func NewPieceTable(text []byte) *PieceTable {
@@ -69,6 +74,7 @@ func (pt *PieceTable) Set(text []byte) {
 	pt.trackUndos = true
 	pt.buf = [3][]byte{}
 	pt.bufLen = [3]int{}
+	pt.sharedBuf = [3]bool{}
 	pt.undoStack = pieceRangeStack{}
 	pt.redoStack = pieceRangeStack{}
 	pt.lastInsertedPiece = nil
@@ -85,15 +91,24 @@ func (pt *PieceTable) Set(text []byte) {
 //
 // This is useful when you must perform multiple small operations on the table that are really one large
 // operation, such as substituting all strings with another string.
+//
+// Transactions may be nested, in which case the changes are merged until the outermost transaction ends.
 func (pt *PieceTable) StartTransaction() {
-	pt.trackUndos = false
-	if !pt.trackUndos {
-		pt.mergeUndo = false
-	}
+	pt.StartTransactionFor("")
 }
 
 // EndTransaction ends a transaction started with StartTransaction.
 func (pt *PieceTable) EndTransaction() {
+	if len(pt.txOwners) == 0 {
+		violation("EndTransaction was called with no transaction open")
+		return
+	}
+
+	pt.txOwners = pt.txOwners[:len(pt.txOwners)-1]
+	if len(pt.txOwners) > 0 {
+		return
+	}
+
 	pt.trackUndos = true
 	// If the user just performed a transaction, they probably don't want the next inserted
 	// text to be undone along with that transaction as if it is part of it. So we prevent
@@ -102,6 +117,20 @@ func (pt *PieceTable) EndTransaction() {
 	pt.skipNextAppend = true
 }
 
+// StartOuterTransaction begins a transaction that encloses other transactions, so that
+// all the changes made until EndOuterTransaction is called, including those in nested transactions,
+// are undone and redone at once. Unlike StartTransaction the first change in the outer transaction
+// is never appended to the piece inserted before it.
+func (pt *PieceTable) StartOuterTransaction() {
+	pt.StartTransaction()
+	pt.skipNextAppend = true
+}
+
+// EndOuterTransaction ends a transaction started with StartOuterTransaction.
+func (pt *PieceTable) EndOuterTransaction() {
+	pt.EndTransaction()
+}
+
 func (pt *PieceTable) SetString(text string) {
 	pt.Set([]byte(text))
 }
@@ -471,7 +500,14 @@ func (pt *PieceTable) TruncateLastInsert(countToRemove int) {
 	}
 	pt.lastInsertedPiece.byteLen -= count
 
-	pt.buf[pt.lastInsertedPiece.source] = pt.buf[pt.lastInsertedPiece.source][0 : blen-count]
+	src := pt.lastInsertedPiece.source
+	if pt.sharedBuf[src] {
+		// A snapshot may refer to the truncated text, which later appends would overwrite.
+		pt.buf[src] = append([]byte(nil), pt.buf[src][0:blen-count]...)
+		pt.sharedBuf[src] = false
+		return
+	}
+	pt.buf[src] = pt.buf[src][0 : blen-count]
 }
 
 func (pt *PieceTable) stepAlongUndoRedoSequence(from, to *pieceRangeStack) (undoData []interface{}) {
@@ -484,6 +520,10 @@ func (pt *PieceTable) stepAlongUndoRedoSequence(from, to *pieceRangeStack) (undo
 
 	newPieceRange := from.pop()
 
+	// The last inserted piece may have been swapped out of the list, so don't append to it.
+	pt.lastInsertedPiece = nil
+	pt.lastInsertEndIndex = 0
+
 	oldPieceRange := &pieceRange{
 		first:     newPieceRange.first.prev.next,
 		last:      newPieceRange.last.next.prev,
@@ -780,6 +780,99 @@ func TestPieceTableUndoDisable(t *testing.T) {
 			},
 			expected: "test this sentence",
 		},
+		{
+			name:    "nested transactions in outer transaction",
+			initial: "test sentence",
+			debug:   false,
+			ops: []testOp{
+				{
+					opcode:       insert,
+					index:        5,
+					textToInsert: "this ",
+				},
+				// "test this sentence"
+				{
+					opcode: startOuterTransaction,
+				},
+				{
+					opcode: disableUndoTracking,
+				},
+				{
+					opcode:       insert,
+					index:        10,
+					textToInsert: "long ",
+				},
+				// "test this long sentence"
+				{
+					opcode: enableUndoTracking,
+				},
+				{
+					opcode: disableUndoTracking,
+				},
+				{
+					opcode:         delet,
+					index:          0,
+					lengthToDelete: 5,
+				},
+				// "this long sentence"
+				{
+					opcode: enableUndoTracking,
+				},
+				{
+					opcode:       insert,
+					index:        18,
+					textToInsert: "!",
+				},
+				// "this long sentence!"
+				{
+					opcode: endOuterTransaction,
+				},
+				{
+					opcode: undo,
+				},
+				// "test this sentence"
+			},
+			expected: "test this sentence",
+		},
+		{
+			name:    "outer transaction redo",
+			initial: "test sentence",
+			debug:   false,
+			ops: []testOp{
+				{
+					opcode: startOuterTransaction,
+				},
+				{
+					opcode:       insert,
+					index:        5,
+					textToInsert: "this ",
+				},
+				{
+					opcode: disableUndoTracking,
+				},
+				{
+					opcode:       insert,
+					index:        10,
+					textToInsert: "long ",
+				},
+				{
+					opcode: enableUndoTracking,
+				},
+				{
+					opcode: endOuterTransaction,
+				},
+				// "test this long sentence"
+				{
+					opcode: undo,
+				},
+				// "test sentence"
+				{
+					opcode: redo,
+				},
+				// "test this long sentence"
+			},
+			expected: "test this long sentence",
+		},
 	}
 
 	doTests(t, tests)
@@ -855,6 +948,8 @@ const (
 	disableUndoTracking
 	enableUndoTracking
 	setWithUndo
+	startOuterTransaction
+	endOuterTransaction
 )
 
 type testOp struct {
@@ -898,6 +993,10 @@ func (o *testOp) apply(pt *PieceTable) {
 		pt.EndTransaction()
 	case setWithUndo:
 		pt.SetWithUndo([]byte(o.textToInsert))
+	case startOuterTransaction:
+		pt.StartOuterTransaction()
+	case endOuterTransaction:
+		pt.EndOuterTransaction()
 	}
 	o.returnedMarked = pt.marked
 }
@@ -922,6 +1021,10 @@ func (o testOp) Name() string {
 		return "enableUndoTracking"
 	case setWithUndo:
 		return "setWithUndo"
+	case startOuterTransaction:
+		return "startOuterTransaction"
+	case endOuterTransaction:
+		return "endOuterTransaction"
 	}
 	return "unknown"
 }
@@ -0,0 +1,62 @@
+package pctbl
+
+import "bytes"
+
+// Snapshot is the text of a PieceTable at the time Snapshot was called. It refers to the pieces
+// of the table's buffers that held the text rather than copying it, so taking one costs time
+// and memory in proportion to the number of pieces rather than the length of the text. The
+// buffers are only appended to, so the text it refers to doesn't change as the table is edited.
+type Snapshot struct {
+	pieces  [][]byte
+	length  int
+	byteLen int
+}
+
+// Snapshotter is implemented by tables that can take a Snapshot of their text.
+type Snapshotter interface {
+	Snapshot() Snapshot
+}
+
+// Snapshot returns a Snapshot of the current text of the table.
+func (pt *PieceTable) Snapshot() Snapshot {
+	s := Snapshot{length: pt.length}
+	for n := pt.pieces.first(); n != pt.pieces.tail; n = n.next {
+		if n.byteLen == 0 {
+			continue
+		}
+		end := n.byteStart + n.byteLen
+		s.pieces = append(s.pieces, pt.buf[n.source][n.byteStart:end:end])
+		s.byteLen += n.byteLen
+		pt.sharedBuf[n.source] = true
+	}
+	return s
+}
+
+// Len returns the length of the text in runes.
+func (s Snapshot) Len() int {
+	return s.length
+}
+
+// ByteLen returns the length of the text in bytes.
+func (s Snapshot) ByteLen() int {
+	return s.byteLen
+}
+
+// Pieces returns the number of pieces the text is made of.
+func (s Snapshot) Pieces() int {
+	return len(s.pieces)
+}
+
+// Bytes returns a copy of the text.
+func (s Snapshot) Bytes() []byte {
+	var buf bytes.Buffer
+	buf.Grow(s.byteLen)
+	for _, p := range s.pieces {
+		buf.Write(p)
+	}
+	return buf.Bytes()
+}
+
+func (c *OptimizedPieceTable) Snapshot() Snapshot {
+	return c.ptbl.Snapshot()
+}
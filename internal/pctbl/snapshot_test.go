@@ -0,0 +1,36 @@
+package pctbl
+
+import "testing"
+
+func TestSnapshotKeepsTextAfterEdits(t *testing.T) {
+	pt := NewPieceTable([]byte("héllo world"))
+	pt.Insert(5, ", big")
+	snap := pt.Snapshot()
+
+	if snap.Len() != 16 || snap.ByteLen() != 17 || string(snap.Bytes()) != "héllo, big world" {
+		t.Fatalf("unexpected snapshot %q of %d runes and %d bytes", snap.Bytes(), snap.Len(), snap.ByteLen())
+	}
+
+	pt.Delete(0, 7)
+	pt.Insert(0, "goodbye")
+	snap2 := pt.Snapshot()
+	pt.TruncateLastInsert(3)
+	pt.Insert(4, "xyz")
+	pt.Append("!")
+
+	if string(snap.Bytes()) != "héllo, big world" {
+		t.Fatalf("expected the snapshot to be unchanged by edits but it is %q", snap.Bytes())
+	}
+	if string(snap2.Bytes()) != "goodbyebig world" {
+		t.Fatalf("expected the snapshot to keep truncated text but it is %q", snap2.Bytes())
+	}
+	if pt.String() != "goodxyzbig world!" {
+		t.Fatalf("unexpected text %q", pt.String())
+	}
+
+	pt.SetWithUndo(snap.Bytes())
+	pt.Undo()
+	if pt.String() != "goodxyzbig world!" {
+		t.Fatalf("expected restoring the snapshot to be one undo but the text is %q", pt.String())
+	}
+}
@@ -18,7 +18,13 @@ type Table interface {
 	SetWithUndo(text []byte)
 	String() string
 	StartTransaction()
+	StartTransactionFor(owner string)
 	EndTransaction()
+	RunInTransaction(owner string, fn func())
+	TransactionDepth() int
+	Check() (violations []string)
+	StartOuterTransaction()
+	EndOuterTransaction()
 	TruncateLastInsert(countToRemove int)
 	Undo() (undoData []interface{})
 }
@@ -0,0 +1,167 @@
+package pctbl
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+/*
+Transaction discipline
+----------------------
+
+Transactions may be nested: StartTransaction increments a depth counter and EndTransaction decrements
+it, and the changes are only treated as one undo when the outermost transaction is started and ended.
+A path that returns early without ending a transaction leaves the depth above zero, which would otherwise
+only show up much later as unrelated changes being undone together. To catch these bugs an EndTransaction
+with no transaction open, or a transaction started for an owner while a different owner's transaction is
+open, is reported as a violation. Violations panic when built with the pctbldebug tag, and otherwise are
+passed to ReportViolation.
+
+RunInTransaction is the preferred way of making a transaction since it always ends it, even if the
+function panics.
+*/
+
+// ReportViolation is called with a description of each misuse of transactions found when not built
+// with the pctbldebug tag. If it is nil violations are ignored.
+var ReportViolation func(message string)
+
+func violation(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if panicOnViolation {
+		panic("pctbl: " + msg)
+	}
+	if ReportViolation != nil {
+		ReportViolation(msg)
+	}
+}
+
+// StartTransactionFor begins a transaction like StartTransaction on behalf of owner, which names
+// the feature making the changes. If a transaction for a different owner is already open it is
+// reported as a violation, since the changes of the two owners would be undone together. An empty
+// owner matches any other owner.
+func (pt *PieceTable) StartTransactionFor(owner string) {
+	if open := pt.transactionOwner(); open != "" && owner != "" && owner != open {
+		violation("a transaction for %s was started while the transaction for %s is open", owner, open)
+	}
+
+	pt.txOwners = append(pt.txOwners, owner)
+	if len(pt.txOwners) > 1 {
+		return
+	}
+
+	pt.trackUndos = false
+	pt.mergeUndo = false
+}
+
+// transactionOwner returns the owner of the innermost open transaction that has one.
+func (pt *PieceTable) transactionOwner() string {
+	for i := len(pt.txOwners) - 1; i >= 0; i-- {
+		if pt.txOwners[i] != "" {
+			return pt.txOwners[i]
+		}
+	}
+	return ""
+}
+
+// RunInTransaction calls fn within a transaction for owner, and ends the transaction when fn
+// returns or panics.
+func (pt *PieceTable) RunInTransaction(owner string, fn func()) {
+	pt.StartTransactionFor(owner)
+	defer pt.EndTransaction()
+	fn()
+}
+
+// TransactionDepth returns the number of transactions that are open.
+func (pt *PieceTable) TransactionDepth() int {
+	return len(pt.txOwners)
+}
+
+// Check verifies the invariants of the piece table and returns a description of each one that
+// doesn't hold. It is meant for debugging, and walks all the pieces and the undo and redo stacks.
+func (pt *PieceTable) Check() (violations []string) {
+	report := func(format string, args ...interface{}) {
+		violations = append(violations, fmt.Sprintf(format, args...))
+	}
+
+	if d := pt.TransactionDepth(); d != 0 {
+		owner := ""
+		if o := pt.transactionOwner(); o != "" {
+			owner = fmt.Sprintf(" (owned by %s)", o)
+		}
+		report("%d transactions are open%s", d, owner)
+	}
+
+	l := 0
+	i := 0
+	for n := pt.pieces.first(); n != pt.pieces.tail; n = n.next {
+		if n == nil {
+			report("the piece list is not terminated by the tail")
+			break
+		}
+		if n.next == nil || n.next.prev != n {
+			report("piece %d is not linked back to by the next piece", i)
+		}
+		pt.checkPiece(n, fmt.Sprintf("piece %d", i), report)
+		l += n.length
+		i++
+	}
+
+	if l != pt.length {
+		report("the pieces have a total length of %d but the table has length %d", l, pt.length)
+	}
+
+	pt.checkStack(&pt.undoStack, "undo", report)
+	pt.checkStack(&pt.redoStack, "redo", report)
+	return
+}
+
+func (pt *PieceTable) checkPiece(n *piece, name string, report func(format string, args ...interface{})) {
+	if n.source != original && n.source != add {
+		report("%s has an invalid source buffer %d", name, n.source)
+		return
+	}
+
+	if n.start < 0 || n.length < 0 || n.start+n.length > pt.bufLen[n.source] {
+		report("%s refers to runes [%d,%d) outside its buffer of %d runes", name, n.start, n.start+n.length, pt.bufLen[n.source])
+	}
+
+	if n.byteStart < 0 || n.byteLen < 0 || n.byteStart+n.byteLen > len(pt.buf[n.source]) {
+		report("%s refers to bytes [%d,%d) outside its buffer of %d bytes", name, n.byteStart, n.byteStart+n.byteLen, len(pt.buf[n.source]))
+		return
+	}
+
+	if c := utf8.RuneCount(pt.textOf(n)); c != n.length {
+		report("%s has length %d but its text has %d runes", name, n.length, c)
+	}
+}
+
+func (pt *PieceTable) checkStack(stk *pieceRangeStack, name string, report func(format string, args ...interface{})) {
+	i := 0
+	for r := stk.top_; r != nil; r = r.next {
+		rname := fmt.Sprintf("%s record %d", name, i)
+		i++
+
+		if r.first == nil || r.last == nil {
+			report("%s has no pieces", rname)
+			continue
+		}
+
+		j := 0
+		n := r.first
+		for ; n != nil && n != r.last.next; n = n.next {
+			pt.checkPiece(n, fmt.Sprintf("%s piece %d", rname, j), report)
+			j++
+		}
+		if n == nil && r.last.next != nil {
+			report("%s doesn't reach its last piece from its first", rname)
+		}
+
+		if r.first.prev == nil || r.last.next == nil {
+			report("%s is not linked to the pieces around it", rname)
+		}
+	}
+
+	if i != stk.count {
+		report("the %s stack has %d records but counts %d", name, i, stk.count)
+	}
+}
@@ -0,0 +1,224 @@
+package pctbl
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// recordViolations collects the violations reported while the test runs.
+func recordViolations(t *testing.T) *[]string {
+	if panicOnViolation {
+		t.Skip("violations panic when built with the pctbldebug tag")
+	}
+
+	var violations []string
+	old := ReportViolation
+	ReportViolation = func(message string) {
+		violations = append(violations, message)
+	}
+	t.Cleanup(func() { ReportViolation = old })
+	return &violations
+}
+
+func TestNestedTransactionsAreUndoneTogether(t *testing.T) {
+	pt := NewPieceTable([]byte("test sentence"))
+	pt.StartTransaction()
+	pt.Insert(5, "this ")
+	pt.StartTransaction()
+	pt.Insert(10, "long ")
+	pt.EndTransaction()
+	pt.Delete(0, 5)
+	pt.EndTransaction()
+
+	if pt.String() != "this long sentence" {
+		t.Fatalf("unexpected text %q", pt.String())
+	}
+	pt.Undo()
+	if pt.String() != "test sentence" {
+		t.Fatalf("expected the nested transaction to be undone with the outer one but the text is %q", pt.String())
+	}
+}
+
+func TestTransactionViolationsAreReported(t *testing.T) {
+	violations := recordViolations(t)
+
+	pt := NewPieceTable([]byte("abc"))
+	pt.EndTransaction()
+	if len(*violations) != 1 {
+		t.Fatalf("expected an unbalanced EndTransaction to be reported but got %v", *violations)
+	}
+
+	pt.StartTransactionFor("typing")
+	pt.StartTransactionFor("typing")
+	pt.EndTransaction()
+	if len(*violations) != 1 {
+		t.Fatalf("expected nesting by the same owner to be allowed but got %v", *violations)
+	}
+
+	pt.StartTransactionFor("api")
+	if len(*violations) != 2 || !strings.Contains((*violations)[1], "api") {
+		t.Fatalf("expected a transaction for another owner to be reported but got %v", *violations)
+	}
+	pt.EndTransaction()
+	pt.EndTransaction()
+
+	// An anonymous outer transaction may enclose transactions for different owners in turn.
+	pt.StartOuterTransaction()
+	pt.RunInTransaction("typing", func() { pt.Insert(0, "x") })
+	pt.RunInTransaction("api", func() { pt.Insert(0, "y") })
+	pt.EndOuterTransaction()
+	if len(*violations) != 2 {
+		t.Fatalf("expected no more violations but got %v", *violations)
+	}
+	if v := pt.Check(); len(v) != 0 {
+		t.Fatalf("expected no invariant violations but got %v", v)
+	}
+}
+
+func TestRunInTransactionEndsTransactionOnPanic(t *testing.T) {
+	pt := NewPieceTable([]byte("abc"))
+
+	func() {
+		defer func() { recover() }()
+		pt.RunInTransaction("typing", func() {
+			pt.Insert(3, "d")
+			panic("failed")
+		})
+	}()
+
+	if pt.TransactionDepth() != 0 {
+		t.Fatalf("expected the transaction to be ended but the depth is %d", pt.TransactionDepth())
+	}
+	if v := pt.Check(); len(v) != 0 {
+		t.Fatalf("expected no invariant violations but got %v", v)
+	}
+}
+
+func TestCheckReportsOpenTransactions(t *testing.T) {
+	pt := NewPieceTable([]byte("abc"))
+	pt.StartTransactionFor("typing")
+	v := pt.Check()
+	if len(v) != 1 || !strings.Contains(v[0], "typing") {
+		t.Fatalf("expected the open transaction to be reported but got %v", v)
+	}
+}
+
+// FuzzTransactions interleaves changes, transactions, early returns from transactions and undos,
+// checking the invariants of the table after each step and that undoing everything restores the
+// initial text.
+func FuzzTransactions(f *testing.F) {
+	f.Add("hello world", []byte{0, 3, 1, 2, 4, 5, 6, 0, 7, 3})
+	f.Add("", []byte{2, 0, 0, 2, 1, 3, 3, 5, 5, 6, 6, 7})
+	f.Add("héllo\nwörld", []byte{4, 1, 1, 4, 0, 0, 5, 2, 3, 3, 6})
+
+	f.Fuzz(func(t *testing.T, initial string, ops []byte) {
+		if !utf8.ValidString(initial) {
+			return
+		}
+		violations := recordViolations(t)
+
+		pt := NewPieceTable([]byte(initial))
+		texts := []string{"a", "bc", "é", "xyz\n"}
+		model := []rune(initial)
+		depth := 0
+
+		insert := func(b byte) {
+			i := int(b) % (len(model) + 1)
+			s := texts[int(b)%len(texts)]
+			pt.Insert(i, s)
+			model = append(model[:i], append([]rune(s), model[i:]...)...)
+		}
+
+		del := func(b byte) {
+			if len(model) == 0 {
+				return
+			}
+			i := int(b) % len(model)
+			n := 1 + int(b)%3
+			if i+n > len(model) {
+				n = len(model) - i
+			}
+			pt.Delete(i, n)
+			model = append(model[:i], model[i+n:]...)
+		}
+
+		for i, op := range ops {
+			switch op % 8 {
+			case 0:
+				insert(op / 8)
+			case 1:
+				del(op / 8)
+			case 2:
+				pt.StartTransaction()
+				depth++
+			case 3:
+				if depth > 0 {
+					pt.EndTransaction()
+					depth--
+				}
+			case 4:
+				// A transaction whose function returns early after one change.
+				pt.RunInTransaction("typing", func() {
+					insert(op / 8)
+					if op%16 < 8 {
+						return
+					}
+					del(op / 8)
+				})
+			case 5:
+				// A transaction whose function fails part way.
+				func() {
+					defer func() { recover() }()
+					pt.RunInTransaction("api", func() {
+						del(op / 8)
+						panic("failed")
+					})
+				}()
+			case 6:
+				pt.Undo()
+				model = []rune(pt.String())
+			case 7:
+				pt.Redo()
+				model = []rune(pt.String())
+			}
+
+			if pt.String() != string(model) {
+				t.Fatalf("after op %d expected %q but the text is %q", i, string(model), pt.String())
+			}
+			if pt.TransactionDepth() != depth {
+				t.Fatalf("after op %d expected a depth of %d but it is %d", i, depth, pt.TransactionDepth())
+			}
+			if v := pt.Check(); len(v) > 0 && depth == 0 {
+				t.Fatalf("after op %d the invariants don't hold: %v", i, v)
+			}
+		}
+
+		for ; depth > 0; depth-- {
+			pt.EndTransaction()
+		}
+		if v := pt.Check(); len(v) > 0 {
+			t.Fatalf("the invariants don't hold: %v", v)
+		}
+		if len(*violations) > 0 {
+			t.Fatalf("unexpected violations: %v", *violations)
+		}
+
+		for pt.redoStack.top() != nil {
+			pt.Redo()
+		}
+		final := pt.String()
+		for pt.undoStack.top() != nil {
+			pt.Undo()
+		}
+		if pt.String() != initial {
+			t.Fatalf("expected undoing everything to restore %q but the text is %q", initial, pt.String())
+		}
+		for pt.redoStack.top() != nil {
+			pt.Redo()
+		}
+		if pt.String() != final {
+			t.Fatalf("expected redoing everything to restore %q but the text is %q", final, pt.String())
+		}
+	})
+}
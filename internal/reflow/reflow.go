@@ -0,0 +1,142 @@
+// Package reflow re-wraps paragraphs of text to a maximum line width, like fmt(1).
+package reflow
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// markers are the prefixes of lines, after any indentation, that are kept at the start of each
+// line of a paragraph when it is re-wrapped: line comments and quoted text.
+var markers = []string{"//", "#", ">"}
+
+// Text re-wraps the paragraphs in text so that no line is wider than width columns, unless it
+// holds a single word that is wider. Paragraphs are separated by blank lines. The indentation
+// and markers like "# " or "> " at the start of the first line of a paragraph are repeated at
+// the start of each of its lines, and a line with different ones starts another paragraph. The
+// lines after the first line of a list item like "- item" or "1. item" are indented to line up
+// with the text of the item. Tabs are counted as advancing to the next multiple of tabWidth
+// columns.
+func Text(text string, width, tabWidth int) string {
+	if tabWidth <= 0 {
+		tabWidth = 8
+	}
+
+	lines := strings.Split(text, "\n")
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		if i > 0 {
+			b.WriteRune('\n')
+		}
+
+		prefix, content := splitPrefix(lines[i])
+		if strings.TrimSpace(content) == "" {
+			b.WriteString(lines[i])
+			i++
+			continue
+		}
+
+		// The lines after the first are indented past the list marker, if there is one.
+		rest := prefix
+		if m := listMarker(content); m != "" {
+			rest = prefix + strings.Repeat(" ", utf8.RuneCountInString(m))
+		}
+
+		words := strings.Fields(content)
+		i++
+		for ; i < len(lines); i++ {
+			p, c := splitPrefix(lines[i])
+			if p != rest || strings.TrimSpace(c) == "" || listMarker(c) != "" {
+				break
+			}
+			words = append(words, strings.Fields(c)...)
+		}
+
+		fill(&b, words, prefix, rest, width, tabWidth)
+	}
+	return b.String()
+}
+
+// IsBlank returns true if line is empty apart from its indentation and markers.
+func IsBlank(line string) bool {
+	_, content := splitPrefix(line)
+	return strings.TrimSpace(content) == ""
+}
+
+// fill writes words to b, separated by spaces, starting a new line before a word that would make
+// the line wider than width. The first line starts with first and the others with rest.
+func fill(b *strings.Builder, words []string, first, rest string, width, tabWidth int) {
+	b.WriteString(first)
+	col := columns(first, 0, tabWidth)
+	lineEmpty := true
+	for _, w := range words {
+		n := utf8.RuneCountInString(w)
+		if !lineEmpty && col+1+n > width {
+			b.WriteRune('\n')
+			b.WriteString(rest)
+			col = columns(rest, 0, tabWidth)
+			lineEmpty = true
+		}
+		if !lineEmpty {
+			b.WriteRune(' ')
+			col++
+		}
+		b.WriteString(w)
+		col += n
+		lineEmpty = false
+	}
+}
+
+// columns returns the column that s ends at if it starts at column col.
+func columns(s string, col, tabWidth int) int {
+	for _, r := range s {
+		if r == '\t' {
+			col += tabWidth - col%tabWidth
+			continue
+		}
+		col++
+	}
+	return col
+}
+
+// splitPrefix splits line into its indentation and markers, including the spaces that follow
+// them, and the rest of the line.
+func splitPrefix(line string) (prefix, content string) {
+	i := len(line) - len(strings.TrimLeftFunc(line, unicode.IsSpace))
+	for {
+		m := markerAt(line[i:])
+		if m == "" {
+			break
+		}
+		i += len(m)
+		i = len(line) - len(strings.TrimLeftFunc(line[i:], unicode.IsSpace))
+	}
+	return line[:i], line[i:]
+}
+
+func markerAt(s string) string {
+	for _, m := range markers {
+		if strings.HasPrefix(s, m) {
+			return m
+		}
+	}
+	return ""
+}
+
+// listMarker returns the marker of a list item at the start of s, such as "- " or "12. ", or an
+// empty string if s isn't a list item.
+func listMarker(s string) string {
+	if len(s) >= 2 && strings.ContainsRune("-*+", rune(s[0])) && s[1] == ' ' {
+		return s[:2]
+	}
+
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	if i > 0 && i+1 < len(s) && (s[i] == '.' || s[i] == ')') && s[i+1] == ' ' {
+		return s[:i+2]
+	}
+	return ""
+}
@@ -0,0 +1,109 @@
+package reflow
+
+import "testing"
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		width    int
+		expected string
+	}{
+		{
+			name:     "empty",
+			input:    "",
+			width:    10,
+			expected: "",
+		},
+		{
+			name:     "join short lines",
+			input:    "the quick\nbrown\nfox",
+			width:    20,
+			expected: "the quick brown fox",
+		},
+		{
+			name:     "wrap long line",
+			input:    "the quick brown fox jumps over the lazy dog\n",
+			width:    15,
+			expected: "the quick brown\nfox jumps over\nthe lazy dog\n",
+		},
+		{
+			name:     "paragraphs separated by blank lines",
+			input:    "one two\nthree\n\nfour\nfive\n",
+			width:    20,
+			expected: "one two three\n\nfour five\n",
+		},
+		{
+			name:     "word wider than the width",
+			input:    "a supercalifragilistic word",
+			width:    5,
+			expected: "a\nsupercalifragilistic\nword",
+		},
+		{
+			name:     "indentation is kept",
+			input:    "    indented text that\n    wraps around",
+			width:    16,
+			expected: "    indented\n    text that\n    wraps around",
+		},
+		{
+			name:     "tabs count to the next tab stop",
+			input:    "\tone two three",
+			width:    10,
+			expected: "\tone\n\ttwo\n\tthree",
+		},
+		{
+			name:     "comment prefix",
+			input:    "# a comment that is\n# rather long\n#\n# second",
+			width:    14,
+			expected: "# a comment\n# that is\n# rather long\n#\n# second",
+		},
+		{
+			name:     "quote prefix",
+			input:    "> > quoted text here",
+			width:    12,
+			expected: "> > quoted\n> > text\n> > here",
+		},
+		{
+			name:     "different prefixes are different paragraphs",
+			input:    "// comment\ncode here",
+			width:    40,
+			expected: "// comment\ncode here",
+		},
+		{
+			name:     "list items",
+			input:    "- first item that wraps\n- second\n  continued\n1. numbered item",
+			width:    12,
+			expected: "- first item\n  that wraps\n- second\n  continued\n1. numbered\n   item",
+		},
+		{
+			name:     "multibyte runes",
+			input:    "héllo wörld ünïcode",
+			width:    11,
+			expected: "héllo wörld\nünïcode",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := Text(tc.input, tc.width, 4)
+			if actual != tc.expected {
+				t.Fatalf("expected\n%q\nbut got\n%q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIsBlank(t *testing.T) {
+	for line, expected := range map[string]bool{
+		"":       true,
+		"  \t":   true,
+		"#":      true,
+		" // ":   true,
+		"> text": false,
+		"x":      false,
+	} {
+		if IsBlank(line) != expected {
+			t.Errorf("expected IsBlank(%q) to be %v", line, expected)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package runes
+
+import (
+	"bytes"
+	"sort"
+	"unicode/utf8"
+)
+
+// LineCol is a position in a document as a line and a column in runes, both counting from 1.
+type LineCol struct {
+	Line, Col int
+}
+
+// RunePositionsOfLineCols returns the rune position in text of each of lcs, in the same order.
+// The positions are found in one pass over text, so converting many positions of a large
+// document is not much slower than converting one. A line or column less than 1 is treated as
+// 1, a column past the end of its line is the end of the line, and a line past the end of text
+// is the end of text.
+func RunePositionsOfLineCols(text []byte, lcs []LineCol) []int {
+	order := make([]int, len(lcs))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		a, b := lcs[order[i]], lcs[order[j]]
+		return a.Line < b.Line || (a.Line == b.Line && a.Col < b.Col)
+	})
+
+	positions := make([]int, len(lcs))
+	line, lineStartByte, lineStartRune := 1, 0, 0
+	for _, i := range order {
+		lc := lcs[i]
+		for line < lc.Line {
+			nl := bytes.IndexByte(text[lineStartByte:], '\n')
+			if nl < 0 {
+				break
+			}
+			lineStartRune += utf8.RuneCount(text[lineStartByte : lineStartByte+nl+1])
+			lineStartByte += nl + 1
+			line++
+		}
+
+		rest := text[lineStartByte:]
+		if line < lc.Line {
+			positions[i] = lineStartRune + utf8.RuneCount(rest)
+			continue
+		}
+
+		if nl := bytes.IndexByte(rest, '\n'); nl >= 0 {
+			rest = rest[:nl]
+		}
+		col := 0
+		for ; col < lc.Col-1 && len(rest) > 0; col++ {
+			_, sz := utf8.DecodeRune(rest)
+			rest = rest[sz:]
+		}
+		positions[i] = lineStartRune + col
+	}
+	return positions
+}
+
+// LineColsOfRunePositions returns the line and column in text of each of the rune positions, in
+// the same order. Like RunePositionsOfLineCols, the lines and columns are found in one pass
+// over text. A position past the end of text is treated as the end of text.
+func LineColsOfRunePositions(text []byte, positions []int) []LineCol {
+	order := make([]int, len(positions))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return positions[order[i]] < positions[order[j]]
+	})
+
+	lcs := make([]LineCol, len(positions))
+	line, lineStartRune, runePos := 1, 0, 0
+	for _, i := range order {
+		for runePos < positions[i] && len(text) > 0 {
+			r, sz := utf8.DecodeRune(text)
+			text = text[sz:]
+			runePos++
+			if r == '\n' {
+				line++
+				lineStartRune = runePos
+			}
+		}
+		lcs[i] = LineCol{Line: line, Col: runePos - lineStartRune + 1}
+	}
+	return lcs
+}
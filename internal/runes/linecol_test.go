@@ -0,0 +1,52 @@
+package runes
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRunePositionsOfLineCols(t *testing.T) {
+	text := []byte("one\ntwö\n\nfour")
+
+	lcs := []LineCol{
+		{Line: 4, Col: 2},
+		{Line: 1, Col: 1},
+		{Line: 2, Col: 3},
+		{Line: 2, Col: 20},
+		{Line: 3, Col: 1},
+		{Line: 9, Col: 1},
+		{Line: 0, Col: 0},
+		{Line: 2, Col: 4},
+	}
+	expected := []int{10, 0, 6, 7, 8, 13, 0, 7}
+
+	got := RunePositionsOfLineCols(text, lcs)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v but got %v", expected, got)
+	}
+}
+
+func TestLineColsOfRunePositions(t *testing.T) {
+	text := []byte("one\ntwö\n\nfour")
+
+	positions := []int{10, 0, 6, 7, 8, 13, 50, 3}
+	expected := []LineCol{
+		{Line: 4, Col: 2},
+		{Line: 1, Col: 1},
+		{Line: 2, Col: 3},
+		{Line: 2, Col: 4},
+		{Line: 3, Col: 1},
+		{Line: 4, Col: 5},
+		{Line: 4, Col: 5},
+		{Line: 1, Col: 4},
+	}
+
+	got := LineColsOfRunePositions(text, positions)
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v but got %v", expected, got)
+	}
+
+	if back := RunePositionsOfLineCols(text, got[:6]); !reflect.DeepEqual(back, positions[:6]) {
+		t.Fatalf("expected the lines and columns to convert back to %v but got %v", positions[:6], back)
+	}
+}
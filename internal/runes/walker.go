@@ -209,6 +209,17 @@ func (r *Walker) CurrentIdentifierBounds() (startRuneIndex, endRuneIndex int) {
 	return
 }
 
+// CurrentBounds returns the bounds of the run of runes surrounding the current position
+// for which inRun returns true.
+func (r *Walker) CurrentBounds(inRun func(rn rune) bool) (startRuneIndex, endRuneIndex int) {
+	stop := func(rn rune) bool {
+		return !inRun(rn)
+	}
+	_, startRuneIndex = r.leftBoundary(stop)
+	_, endRuneIndex = r.rightBoundary(stop)
+	return
+}
+
 func (r *Walker) CurrentRunOfSpaces() string {
 	right, _ := r.rightRunOfSpacesBoundary()
 	left, _ := r.leftRunOfSpacesBoundary()
@@ -498,6 +509,36 @@ func (r *Walker) GoToLineAndCol(line, col int) {
 	r.Forward(col - 1)
 }
 
+// LineBoundsInBox returns the start and end of the part of each line that is inside the box with
+// corners at the rune indexes anchor and pos, from the line containing the first of them to the line
+// containing the last. Columns are counted in runes from the start of each line. A line that is too
+// short to reach a column of the box is cut off at its end, so its bounds may be empty.
+func (r *Walker) LineBoundsInBox(anchor, pos int) (bounds [][2]int) {
+	r.SetRunePos(anchor)
+	left := r.IndexInLine()
+	r.SetRunePos(pos)
+	right := r.IndexInLine()
+	if left > right {
+		left, right = right, left
+	}
+
+	first, last := anchor, pos
+	if first > last {
+		first, last = last, first
+	}
+
+	r.SetRunePos(first)
+	for {
+		start, end := r.CurrentLineBounds()
+		bounds = append(bounds, [2]int{min(start+left, end), min(start+right, end)})
+		r.ForwardToEndOfLine()
+		if r.RunePos() >= last || r.AtEnd() {
+			return
+		}
+		r.Forward(1)
+	}
+}
+
 func (r *Walker) ForwardLines(n int) (eof bool) {
 	for ; n > 0 && r.bytePos < len(r.bytes); n-- {
 		eof = r.ForwardLine()
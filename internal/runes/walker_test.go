@@ -1,6 +1,9 @@
 package runes
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestWalkerGotoLineAndCol(t *testing.T) {
 
@@ -237,3 +240,73 @@ func TestWalkerCurrentLineBoundsWithTrailingNl(t *testing.T) {
 		})
 	}
 }
+
+func TestWalkerLineBoundsInBox(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		anchor   int
+		pos      int
+		expected [][2]int
+	}{
+		{
+			name:     "one line",
+			input:    "abcdef\n",
+			anchor:   1,
+			pos:      4,
+			expected: [][2]int{{1, 4}},
+		},
+		{
+			name:     "down and right",
+			input:    "abcdef\nghijkl\nmnopqr",
+			anchor:   1,
+			pos:      17,
+			expected: [][2]int{{1, 3}, {8, 10}, {15, 17}},
+		},
+		{
+			name:     "up and left",
+			input:    "abcdef\nghijkl\nmnopqr",
+			anchor:   17,
+			pos:      1,
+			expected: [][2]int{{1, 3}, {8, 10}, {15, 17}},
+		},
+		{
+			name:     "up and right",
+			input:    "abcdef\nghijkl\nmnopqr",
+			anchor:   15,
+			pos:      3,
+			expected: [][2]int{{1, 3}, {8, 10}, {15, 17}},
+		},
+		{
+			name:     "zero width",
+			input:    "abcdef\nghijkl\n",
+			anchor:   2,
+			pos:      9,
+			expected: [][2]int{{2, 2}, {9, 9}},
+		},
+		{
+			name:     "short lines are clamped",
+			input:    "abcdef\nab\n\nabcdef",
+			anchor:   1,
+			pos:      15,
+			expected: [][2]int{{1, 4}, {8, 9}, {10, 10}, {12, 15}},
+		},
+		{
+			name:     "multibyte runes",
+			input:    "äöü\näöü",
+			anchor:   1,
+			pos:      6,
+			expected: [][2]int{{1, 2}, {5, 6}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := NewWalker([]byte(tc.input))
+			bounds := w.LineBoundsInBox(tc.anchor, tc.pos)
+			if !reflect.DeepEqual(bounds, tc.expected) {
+				t.Fatalf("expected %v but got %v", tc.expected, bounds)
+			}
+		})
+	}
+}
@@ -0,0 +1,310 @@
+// Package textcase implements case conversions of text, including conversions between
+// identifier styles such as camelCase and snake_case.
+package textcase
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Locale returns the special case mappings used for the language lang, which may be a
+// language code like "tr" or a locale like "tr_TR.UTF-8". It returns nil if the language
+// uses the default Unicode case mappings.
+func Locale(lang string) unicode.SpecialCase {
+	lang = strings.ToLower(lang)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+
+	switch lang {
+	case "tr":
+		return unicode.TurkishCase
+	case "az":
+		return unicode.AzeriCase
+	}
+	return nil
+}
+
+// fullUpper contains the runes whose uppercase form is more than one rune. The unicode
+// package only implements the simple one-to-one mappings.
+var fullUpper = map[rune]string{
+	'ß': "SS",
+	'ŉ': "ʼN",
+	'ǰ': "J̌",
+	'ΐ': "Ϊ́",
+	'ΰ': "Ϋ́",
+	'և': "ԵՒ",
+	'ẖ': "H̱",
+	'ẗ': "T̈",
+	'ẘ': "W̊",
+	'ẙ': "Y̊",
+	'ẚ': "Aʾ",
+	'ﬀ': "FF",
+	'ﬁ': "FI",
+	'ﬂ': "FL",
+	'ﬃ': "FFI",
+	'ﬄ': "FFL",
+	'ﬅ': "ST",
+	'ﬆ': "ST",
+}
+
+// fullTitle contains the runes whose titlecase form is more than one rune.
+var fullTitle = map[rune]string{
+	'ß': "Ss",
+	'ŉ': "ʼN",
+	'և': "Եւ",
+	'ﬀ': "Ff",
+	'ﬁ': "Fi",
+	'ﬂ': "Fl",
+	'ﬃ': "Ffi",
+	'ﬄ': "Ffl",
+	'ﬅ': "St",
+	'ﬆ': "St",
+}
+
+func upper(b *strings.Builder, rn rune, sc unicode.SpecialCase) {
+	if sc == nil {
+		if s, ok := fullUpper[rn]; ok {
+			b.WriteString(s)
+			return
+		}
+		b.WriteRune(unicode.ToUpper(rn))
+		return
+	}
+	b.WriteRune(sc.ToUpper(rn))
+}
+
+func lower(b *strings.Builder, rn rune, sc unicode.SpecialCase) {
+	if sc == nil {
+		b.WriteRune(unicode.ToLower(rn))
+		return
+	}
+	b.WriteRune(sc.ToLower(rn))
+}
+
+func title(b *strings.Builder, rn rune, sc unicode.SpecialCase) {
+	if sc == nil {
+		if s, ok := fullTitle[rn]; ok {
+			b.WriteString(s)
+			return
+		}
+		b.WriteRune(unicode.ToTitle(rn))
+		return
+	}
+	b.WriteRune(sc.ToTitle(rn))
+}
+
+// Upper returns s with all letters converted to upper case. Letters such as ß that have no
+// single-rune uppercase form are expanded (ß becomes SS). sc may be nil or the special case
+// mappings returned by Locale.
+func Upper(s string, sc unicode.SpecialCase) string {
+	var b strings.Builder
+	for _, rn := range s {
+		upper(&b, rn, sc)
+	}
+	return b.String()
+}
+
+// Lower returns s with all letters converted to lower case.
+func Lower(s string, sc unicode.SpecialCase) string {
+	var b strings.Builder
+	for _, rn := range s {
+		lower(&b, rn, sc)
+	}
+	return b.String()
+}
+
+// Title returns s with the first letter of each word converted to title case and the
+// remaining letters converted to lower case. A word is a run of letters, digits, marks and
+// apostrophes.
+func Title(s string, sc unicode.SpecialCase) string {
+	var b strings.Builder
+	inWord := false
+	for _, rn := range s {
+		switch {
+		case unicode.IsLetter(rn):
+			if inWord {
+				lower(&b, rn, sc)
+			} else {
+				title(&b, rn, sc)
+			}
+			inWord = true
+		case unicode.IsDigit(rn):
+			b.WriteRune(rn)
+			inWord = true
+		case unicode.IsMark(rn) || rn == '\'' || rn == '’':
+			b.WriteRune(rn)
+		default:
+			b.WriteRune(rn)
+			inWord = false
+		}
+	}
+	return b.String()
+}
+
+// Swap returns s with upper and title case letters converted to lower case, and lower case
+// letters converted to upper case.
+func Swap(s string, sc unicode.SpecialCase) string {
+	var b strings.Builder
+	for _, rn := range s {
+		switch {
+		case unicode.IsUpper(rn) || unicode.IsTitle(rn):
+			lower(&b, rn, sc)
+		case unicode.IsLower(rn):
+			upper(&b, rn, sc)
+		default:
+			b.WriteRune(rn)
+		}
+	}
+	return b.String()
+}
+
+// Snake converts the identifiers in s to snake_case. For example parseHTTPResponse
+// becomes parse_http_response.
+func Snake(s string, sc unicode.SpecialCase) string {
+	return convertIdentifiers(s, func(words []string) string {
+		return joinLower(words, "_", sc)
+	})
+}
+
+// Kebab converts the identifiers in s to kebab-case. For example parseHTTPResponse
+// becomes parse-http-response.
+func Kebab(s string, sc unicode.SpecialCase) string {
+	return convertIdentifiers(s, func(words []string) string {
+		return joinLower(words, "-", sc)
+	})
+}
+
+// Camel converts the identifiers in s to camelCase. For example parse_http_response
+// becomes parseHttpResponse. Since the words of a snake_case or kebab-case identifier
+// don't record which of them were acronyms, those are capitalized like any other word.
+func Camel(s string, sc unicode.SpecialCase) string {
+	return convertIdentifiers(s, func(words []string) string {
+		var b strings.Builder
+		for i, w := range words {
+			if i == 0 {
+				b.WriteString(Lower(w, sc))
+				continue
+			}
+			for j, rn := range w {
+				if j == 0 {
+					title(&b, rn, sc)
+				} else {
+					lower(&b, rn, sc)
+				}
+			}
+		}
+		return b.String()
+	})
+}
+
+func joinLower(words []string, sep string, sc unicode.SpecialCase) string {
+	var b strings.Builder
+	for i, w := range words {
+		if i > 0 {
+			b.WriteString(sep)
+		}
+		b.WriteString(Lower(w, sc))
+	}
+	return b.String()
+}
+
+func isIdentifierRune(rn rune) bool {
+	return unicode.IsLetter(rn) || unicode.IsDigit(rn) || unicode.IsMark(rn) || isSeparator(rn)
+}
+
+func isSeparator(rn rune) bool {
+	return rn == '_' || rn == '-'
+}
+
+// convertIdentifiers splits s into identifiers and passes the words of each to join,
+// replacing the identifier with the result. Text between identifiers and separators
+// at the start or end of an identifier (as in _private) are kept unchanged.
+func convertIdentifiers(s string, join func(words []string) string) string {
+	var b strings.Builder
+	rns := []rune(s)
+
+	for i := 0; i < len(rns); {
+		if !isIdentifierRune(rns[i]) {
+			b.WriteRune(rns[i])
+			i++
+			continue
+		}
+
+		j := i
+		for j < len(rns) && isIdentifierRune(rns[j]) {
+			j++
+		}
+		ident := rns[i:j]
+		i = j
+
+		start := 0
+		for start < len(ident) && isSeparator(ident[start]) {
+			start++
+		}
+		end := len(ident)
+		for end > start && isSeparator(ident[end-1]) {
+			end--
+		}
+
+		b.WriteString(string(ident[:start]))
+		if start < end {
+			b.WriteString(join(SplitWords(string(ident[start:end]))))
+		}
+		b.WriteString(string(ident[end:]))
+	}
+	return b.String()
+}
+
+// SplitWords splits an identifier into the words it is made of. Words are separated by
+// underscores and hyphens, by a change from a lowercase letter or digit to an uppercase
+// letter (parse|Response), and at the end of a run of uppercase letters that is followed
+// by a lowercase letter (HTTP|Response). Digits belong to the word they follow, so
+// utf8Decode is split into utf8 and Decode.
+func SplitWords(ident string) []string {
+	var words []string
+	rns := []rune(ident)
+
+	start := 0
+	for i := range rns {
+		if isSeparator(rns[i]) {
+			if i > start {
+				words = append(words, string(rns[start:i]))
+			}
+			start = i + 1
+			continue
+		}
+
+		if i == start {
+			continue
+		}
+
+		prev := rns[i-1]
+		var next rune
+		if i+1 < len(rns) {
+			next = rns[i+1]
+		}
+
+		split := false
+		switch {
+		case isUpper(rns[i]) && (unicode.IsLower(prev) || unicode.IsDigit(prev)):
+			split = true
+		case isUpper(rns[i]) && isUpper(prev) && unicode.IsLower(next):
+			split = true
+		}
+
+		if split {
+			words = append(words, string(rns[start:i]))
+			start = i
+		}
+	}
+
+	if start < len(rns) {
+		words = append(words, string(rns[start:]))
+	}
+	return words
+}
+
+func isUpper(rn rune) bool {
+	return unicode.IsUpper(rn) || unicode.IsTitle(rn)
+}
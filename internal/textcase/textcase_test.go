@@ -0,0 +1,90 @@
+package textcase
+
+import (
+	"reflect"
+	"testing"
+	"unicode"
+)
+
+func TestCaseConversions(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		conv     func(s string, sc unicode.SpecialCase) string
+		sc       unicode.SpecialCase
+		input    string
+		expected string
+	}{
+		{name: "upper", conv: Upper, input: "hello, world", expected: "HELLO, WORLD"},
+		{name: "upper sharp s", conv: Upper, input: "straße", expected: "STRASSE"},
+		{name: "upper ligature", conv: Upper, input: "ﬁne", expected: "FINE"},
+		{name: "upper greek", conv: Upper, input: "αβγ", expected: "ΑΒΓ"},
+		{name: "upper turkish", conv: Upper, sc: unicode.TurkishCase, input: "istanbul", expected: "İSTANBUL"},
+		{name: "lower", conv: Lower, input: "HeLLo ÉTÉ", expected: "hello été"},
+		{name: "lower turkish", conv: Lower, sc: unicode.TurkishCase, input: "DİYARBAKIR", expected: "diyarbakır"},
+		{name: "lower empty", conv: Lower, input: "", expected: ""},
+		{name: "title", conv: Title, input: "the QUICK brown fox's tail", expected: "The Quick Brown Fox's Tail"},
+		{name: "title digits", conv: Title, input: "4th of july", expected: "4th Of July"},
+		{name: "title sharp s", conv: Title, input: "ßa", expected: "Ssa"},
+		{name: "swap", conv: Swap, input: "Hello World 42", expected: "hELLO wORLD 42"},
+		{name: "swap sharp s", conv: Swap, input: "Maß", expected: "mASS"},
+		{name: "snake from camel", conv: Snake, input: "parseHTTPResponse", expected: "parse_http_response"},
+		{name: "snake from pascal", conv: Snake, input: "HTTPServer", expected: "http_server"},
+		{name: "snake from kebab", conv: Snake, input: "line-height", expected: "line_height"},
+		{name: "snake digits", conv: Snake, input: "utf8Decode base64URL", expected: "utf8_decode base64_url"},
+		{name: "snake keeps separators at ends", conv: Snake, input: "_privateField__", expected: "_private_field__"},
+		{name: "snake unicode", conv: Snake, input: "größeÄnderung", expected: "größe_änderung"},
+		{name: "snake many identifiers", conv: Snake, input: "a := fooBar(bazQux)", expected: "a := foo_bar(baz_qux)"},
+		{name: "camel from snake", conv: Camel, input: "parse_http_response", expected: "parseHttpResponse"},
+		{name: "camel from kebab", conv: Camel, input: "line-height", expected: "lineHeight"},
+		{name: "camel from pascal", conv: Camel, input: "HTTPServer", expected: "httpServer"},
+		{name: "camel digits", conv: Camel, input: "vec3_length", expected: "vec3Length"},
+		{name: "camel idempotent", conv: Camel, input: "alreadyCamel", expected: "alreadyCamel"},
+		{name: "kebab from camel", conv: Kebab, input: "parseHTTPResponse", expected: "parse-http-response"},
+		{name: "kebab from snake", conv: Kebab, input: "SCREAMING_SNAKE", expected: "screaming-snake"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			actual := tc.conv(tc.input, tc.sc)
+			if actual != tc.expected {
+				t.Fatalf("expected %q but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestSplitWords(t *testing.T) {
+
+	tests := []struct {
+		input    string
+		expected []string
+	}{
+		{"parseHTTPResponse", []string{"parse", "HTTP", "Response"}},
+		{"parse_http_response", []string{"parse", "http", "response"}},
+		{"parse-http-response", []string{"parse", "http", "response"}},
+		{"ID", []string{"ID"}},
+		{"userID", []string{"user", "ID"}},
+		{"utf8Decode", []string{"utf8", "Decode"}},
+		{"a__b", []string{"a", "b"}},
+		{"", nil},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.input, func(t *testing.T) {
+			actual := SplitWords(tc.input)
+			if !reflect.DeepEqual(actual, tc.expected) {
+				t.Fatalf("expected %q but got %q", tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestLocale(t *testing.T) {
+	if Locale("tr_TR.UTF-8") == nil {
+		t.Fatalf("expected special cases for Turkish")
+	}
+	if Locale("en_US.UTF-8") != nil {
+		t.Fatalf("expected no special cases for English")
+	}
+}
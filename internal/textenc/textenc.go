@@ -0,0 +1,335 @@
+// Package textenc detects the character encoding and line endings of the text of files, and
+// converts the text to and from UTF-8 with newline line endings.
+package textenc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Charset is the character encoding of text.
+type Charset int
+
+const (
+	UTF8 Charset = iota
+	UTF16LE
+	UTF16BE
+)
+
+var charsetNames = map[Charset]string{
+	UTF8:    "utf-8",
+	UTF16LE: "utf-16le",
+	UTF16BE: "utf-16be",
+}
+
+func (c Charset) String() string {
+	return charsetNames[c]
+}
+
+// DetectSize is the number of bytes at the start of a file that are enough to detect its encoding.
+const DetectSize = 4096
+
+// Encoding is the character encoding and line endings of the text of a file. The zero value is
+// UTF-8 with newline line endings, which is how text is kept in the editor.
+type Encoding struct {
+	Charset Charset
+	// BOM is true if the text starts with a byte order mark.
+	BOM bool
+	// CRLF is true if lines end with a carriage return and newline rather than just a newline.
+	CRLF bool
+}
+
+var (
+	utf8BOM    = []byte{0xef, 0xbb, 0xbf}
+	utf16LEBOM = []byte{0xff, 0xfe}
+	utf16BEBOM = []byte{0xfe, 0xff}
+)
+
+// String returns the encoding as the words that Parse accepts, like "utf-16le bom crlf".
+func (e Encoding) String() string {
+	s := e.Charset.String()
+	if e.BOM {
+		s += " bom"
+	}
+	if e.CRLF {
+		return s + " crlf"
+	}
+	return s + " lf"
+}
+
+// IsDefault returns true if text in the encoding doesn't need to be converted.
+func (e Encoding) IsDefault() bool {
+	return e == Encoding{}
+}
+
+// Parse changes the encoding e as described by the words: a charset (utf-8, utf-16le or
+// utf-16be), bom or nobom, and crlf or lf. Changing the charset to UTF-16 adds a byte order mark
+// and changing it to UTF-8 removes it, unless bom or nobom is given.
+func Parse(e Encoding, words []string) (Encoding, error) {
+	bomGiven := false
+	for _, w := range words {
+		switch strings.ToLower(w) {
+		case "utf-8", "utf8":
+			e.Charset = UTF8
+			if !bomGiven {
+				e.BOM = false
+			}
+		case "utf-16le", "utf16le":
+			e.Charset = UTF16LE
+			if !bomGiven {
+				e.BOM = true
+			}
+		case "utf-16be", "utf16be":
+			e.Charset = UTF16BE
+			if !bomGiven {
+				e.BOM = true
+			}
+		case "bom":
+			e.BOM = true
+			bomGiven = true
+		case "nobom":
+			e.BOM = false
+			bomGiven = true
+		case "crlf":
+			e.CRLF = true
+		case "lf":
+			e.CRLF = false
+		default:
+			return e, fmt.Errorf("unknown encoding or line ending '%s'", w)
+		}
+	}
+	return e, nil
+}
+
+// Detect guesses the encoding of the text that starts with head. The charset is found from the
+// byte order mark, or for UTF-16 without one, from how many of the high bytes of the characters
+// are zero. The line endings are CRLF if every line in head that ends does so with a carriage
+// return and newline. A line further on may still end with just a newline; the Decoder falls
+// back to newline line endings when it finds one.
+func Detect(head []byte) (e Encoding) {
+	switch {
+	case bytes.HasPrefix(head, utf8BOM):
+		e.Charset, e.BOM = UTF8, true
+	case bytes.HasPrefix(head, utf16LEBOM):
+		e.Charset, e.BOM = UTF16LE, true
+	case bytes.HasPrefix(head, utf16BEBOM):
+		e.Charset, e.BOM = UTF16BE, true
+	default:
+		e.Charset = guessBOMlessCharset(head)
+	}
+
+	d := NewDecoder(Encoding{Charset: e.Charset, BOM: e.BOM})
+	text := append(d.Decode(head), d.Flush()...)
+	lf := bytes.Count(text, []byte("\n"))
+	crlf := bytes.Count(text, []byte("\r\n"))
+	e.CRLF = lf > 0 && crlf == lf
+	return
+}
+
+// guessBOMlessCharset guesses whether head is UTF-16 without a byte order mark. That is the case
+// when most characters are ASCII, so that nearly all the bytes on one side of each pair are zero
+// and none on the other side are.
+func guessBOMlessCharset(head []byte) Charset {
+	pairs := len(head) / 2
+	if pairs == 0 {
+		return UTF8
+	}
+
+	var zeros [2]int
+	for i := 0; i < pairs*2; i++ {
+		if head[i] == 0 {
+			zeros[i%2]++
+		}
+	}
+
+	most := pairs * 3 / 4
+	switch {
+	case zeros[1] > most && zeros[0] == 0:
+		return UTF16LE
+	case zeros[0] > most && zeros[1] == 0:
+		return UTF16BE
+	}
+	return UTF8
+}
+
+// Decoder converts text in an encoding to UTF-8 with newline line endings. The text may be
+// passed to Decode in pieces that split characters or line endings.
+//
+// If the encoding has CRLF line endings but a line ends with just a newline, converting the text
+// back with Encode would change that line. So the Decoder stops converting line endings from the
+// piece of text with that line on, returning the whole piece unconverted, and its Encoding no
+// longer has CRLF line endings. The text returned before then should have its carriage returns
+// put back with RestoreCR.
+type Decoder struct {
+	enc     Encoding
+	started bool
+	// pending holds the end of the text passed to Decode that can't be converted until more
+	// arrives: half of a UTF-16 code unit, or the first of a surrogate pair.
+	pending []byte
+	// cr is true if the converted text so far ended with a carriage return that might start a
+	// line ending.
+	cr bool
+}
+
+func NewDecoder(enc Encoding) *Decoder {
+	return &Decoder{enc: enc}
+}
+
+// NewDecoderAfterStart returns a Decoder for text in enc that continues text already decoded,
+// like data appended to a file. The text is not expected to start with a byte order mark.
+func NewDecoderAfterStart(enc Encoding) *Decoder {
+	return &Decoder{enc: enc, started: true}
+}
+
+// Encoding returns the encoding the text is converted from. It only differs from the encoding
+// the Decoder was made with when it fell back to newline line endings.
+func (d *Decoder) Encoding() Encoding {
+	return d.enc
+}
+
+// RestoreCR puts back the carriage returns that a Decoder removed from the line endings of text
+// before it fell back to newline line endings.
+func RestoreCR(text []byte) []byte {
+	return bytes.ReplaceAll(text, []byte("\n"), []byte("\r\n"))
+}
+
+// Decode converts the next piece of the text and returns as much of it as can be converted.
+func (d *Decoder) Decode(p []byte) []byte {
+	data := append(d.pending, p...)
+	d.pending = nil
+
+	if !d.started {
+		bom := d.enc.bom()
+		if len(data) < len(bom) && bytes.HasPrefix(bom, data) {
+			d.pending = data
+			return nil
+		}
+		data = bytes.TrimPrefix(data, bom)
+		d.started = true
+	}
+
+	var text []byte
+	if d.enc.Charset == UTF8 {
+		text = data
+	} else {
+		text, d.pending = d.decodeUTF16(data)
+	}
+	return d.convertLineEndings(text, false)
+}
+
+// Flush returns the rest of the text held back by Decode, once there is no more.
+func (d *Decoder) Flush() []byte {
+	var text []byte
+	if len(d.pending) > 0 {
+		if !d.started || d.enc.Charset == UTF8 {
+			text = d.pending
+		} else {
+			text = []byte(string(utf8.RuneError))
+		}
+		d.pending = nil
+	}
+	return d.convertLineEndings(text, true)
+}
+
+// bom returns the byte order mark that text in the encoding starts with, if any.
+func (e Encoding) bom() []byte {
+	if !e.BOM {
+		return nil
+	}
+	switch e.Charset {
+	case UTF16LE:
+		return utf16LEBOM
+	case UTF16BE:
+		return utf16BEBOM
+	}
+	return utf8BOM
+}
+
+// decodeUTF16 converts the UTF-16 text in data to UTF-8, and returns the bytes at the end that
+// don't make up a whole character.
+func (d *Decoder) decodeUTF16(data []byte) (text, rest []byte) {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		if d.enc.Charset == UTF16LE {
+			units = append(units, uint16(data[i])|uint16(data[i+1])<<8)
+		} else {
+			units = append(units, uint16(data[i])<<8|uint16(data[i+1]))
+		}
+	}
+
+	n := len(units) * 2
+	if len(units) > 0 && utf16.IsSurrogate(rune(units[len(units)-1])) && units[len(units)-1] < 0xdc00 {
+		// The first of a surrogate pair.
+		units = units[:len(units)-1]
+		n -= 2
+	}
+	rest = append([]byte(nil), data[n:]...)
+
+	text = make([]byte, 0, len(units))
+	for _, r := range utf16.Decode(units) {
+		text = utf8.AppendRune(text, r)
+	}
+	return
+}
+
+// convertLineEndings changes the carriage return-newline pairs in text to newlines if the encoding
+// has CRLF line endings. A carriage return at the end is held back until the text that follows it
+// is known, unless last is true. If text has a newline without a carriage return before it, the
+// Decoder falls back to newline line endings and text is returned as it is.
+func (d *Decoder) convertLineEndings(text []byte, last bool) []byte {
+	if !d.enc.CRLF {
+		return text
+	}
+
+	if d.cr {
+		text = append([]byte("\r"), text...)
+		d.cr = false
+	}
+	if hasLoneNewline(text) {
+		d.enc.CRLF = false
+		return text
+	}
+	if !last && len(text) > 0 && text[len(text)-1] == '\r' {
+		text = text[:len(text)-1]
+		d.cr = true
+	}
+	return bytes.ReplaceAll(text, []byte("\r\n"), []byte("\n"))
+}
+
+func hasLoneNewline(text []byte) bool {
+	for i, c := range text {
+		if c == '\n' && (i == 0 || text[i-1] != '\r') {
+			return true
+		}
+	}
+	return false
+}
+
+// Encode converts text in UTF-8 with newline line endings to the encoding e.
+func Encode(text []byte, e Encoding) []byte {
+	if e.IsDefault() {
+		return text
+	}
+
+	if e.CRLF {
+		text = RestoreCR(text)
+	}
+
+	out := append([]byte(nil), e.bom()...)
+	switch e.Charset {
+	case UTF16LE, UTF16BE:
+		for _, u := range utf16.Encode([]rune(string(text))) {
+			if e.Charset == UTF16LE {
+				out = append(out, byte(u), byte(u>>8))
+			} else {
+				out = append(out, byte(u>>8), byte(u))
+			}
+		}
+	default:
+		out = append(out, text...)
+	}
+	return out
+}
@@ -0,0 +1,154 @@
+package textenc
+
+import (
+	"bytes"
+	"testing"
+	"unicode/utf16"
+)
+
+func utf16Bytes(s string, bigEndian bool) []byte {
+	var b []byte
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	return b
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name     string
+		head     []byte
+		expected Encoding
+	}{
+		{"empty", nil, Encoding{}},
+		{"utf-8", []byte("one\ntwo\n"), Encoding{}},
+		{"utf-8 with bom", []byte("\xef\xbb\xbfone\n"), Encoding{Charset: UTF8, BOM: true}},
+		{"crlf", []byte("one\r\ntwo\r\nthree"), Encoding{CRLF: true}},
+		{"mixed line endings", []byte("one\r\ntwo\nthree\r\n"), Encoding{}},
+		{"utf-16le", append([]byte{0xff, 0xfe}, utf16Bytes("one\r\n", false)...), Encoding{Charset: UTF16LE, BOM: true, CRLF: true}},
+		{"utf-16be", append([]byte{0xfe, 0xff}, utf16Bytes("one\n", true)...), Encoding{Charset: UTF16BE, BOM: true}},
+		{"utf-16le without bom", utf16Bytes("some text\n", false), Encoding{Charset: UTF16LE}},
+		{"utf-16be without bom", utf16Bytes("some text\n", true), Encoding{Charset: UTF16BE}},
+		{"binary", []byte{0, 0, 1, 0, 0, 2, 0, 0}, Encoding{}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if e := Detect(tc.head); e != tc.expected {
+				t.Fatalf("expected %v but got %v", tc.expected, e)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	text := "line one\nsecond 😀 line\n\r\nlast é"
+	tests := []struct {
+		name string
+		enc  Encoding
+	}{
+		{"utf-8", Encoding{}},
+		{"utf-8 bom crlf", Encoding{Charset: UTF8, BOM: true, CRLF: true}},
+		{"utf-16le bom crlf", Encoding{Charset: UTF16LE, BOM: true, CRLF: true}},
+		{"utf-16be", Encoding{Charset: UTF16BE}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			file := Encode([]byte(text), tc.enc)
+
+			// Decode the file one byte at a time so that characters and line endings are split.
+			d := NewDecoder(tc.enc)
+			var decoded []byte
+			for i := range file {
+				decoded = append(decoded, d.Decode(file[i:i+1])...)
+			}
+			decoded = append(decoded, d.Flush()...)
+
+			if string(decoded) != text {
+				t.Fatalf("expected the decoded text to be %q but it is %q", text, decoded)
+			}
+			if again := Encode(decoded, tc.enc); !bytes.Equal(again, file) {
+				t.Fatalf("expected encoding the decoded text to give the file back")
+			}
+		})
+	}
+}
+
+func TestDecoderAfterStart(t *testing.T) {
+	enc := Encoding{Charset: UTF16LE, BOM: true, CRLF: true}
+	file := Encode([]byte("one\ntwo\n"), enc)
+	start := len(Encode([]byte("one\n"), enc))
+
+	d := NewDecoderAfterStart(enc)
+	// The rest of the file is decoded in two pieces that split a character.
+	text := append(d.Decode(file[start:start+3]), d.Decode(file[start+3:])...)
+	text = append(text, d.Flush()...)
+	if string(text) != "two\n" {
+		t.Fatalf("expected the rest of the file to decode to %q but got %q", "two\n", text)
+	}
+}
+
+func TestDecodeKeepsTrailingCarriageReturn(t *testing.T) {
+	d := NewDecoder(Encoding{CRLF: true})
+	text := append(d.Decode([]byte("one\r\ntwo\r")), d.Flush()...)
+	if string(text) != "one\ntwo\r" {
+		t.Fatalf("unexpected text %q", text)
+	}
+}
+
+func TestMixedLineEndingsAfterDetectSize(t *testing.T) {
+	file := append(bytes.Repeat([]byte("a crlf line\r\n"), 400), "an lf line\nthe end\r\n"...)
+	if len(file) <= DetectSize {
+		t.Fatalf("the lone newline must come after the first %d bytes", DetectSize)
+	}
+
+	for _, size := range []int{1, 1000, len(file)} {
+		enc := Detect(file[:DetectSize])
+		if !enc.CRLF {
+			t.Fatalf("expected the start of the file to be detected as crlf")
+		}
+
+		d := NewDecoder(enc)
+		var text []byte
+		for i := 0; i < len(file); i += size {
+			crlf := d.Encoding().CRLF
+			piece := d.Decode(file[i:min(i+size, len(file))])
+			if crlf && !d.Encoding().CRLF {
+				text = RestoreCR(text)
+			}
+			text = append(text, piece...)
+		}
+		text = append(text, d.Flush()...)
+
+		if d.Encoding().CRLF {
+			t.Fatalf("expected the decoder to fall back to lf line endings")
+		}
+		if again := Encode(text, d.Encoding()); !bytes.Equal(again, file) {
+			t.Fatalf("decoding %d bytes at a time: expected encoding the decoded text to give the %d bytes of the file back but got %d bytes", size, len(file), len(again))
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	e, err := Parse(Encoding{CRLF: true}, []string{"utf-16le"})
+	if err != nil || e != (Encoding{Charset: UTF16LE, BOM: true, CRLF: true}) {
+		t.Fatalf("unexpected encoding %v (error %v)", e, err)
+	}
+	if e.String() != "utf-16le bom crlf" {
+		t.Fatalf("unexpected string %q", e.String())
+	}
+
+	e, err = Parse(e, []string{"nobom", "UTF-8", "lf"})
+	if err != nil || !e.IsDefault() {
+		t.Fatalf("expected the default encoding but got %v (error %v)", e, err)
+	}
+
+	if _, err = Parse(e, []string{"latin1"}); err == nil {
+		t.Fatalf("expected an unknown encoding to be an error")
+	}
+}
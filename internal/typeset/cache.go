@@ -13,6 +13,7 @@ func layoutCacheForConstraints(constraints Constraints) cache.Cache[string, []Li
 		constraints.FontFaceId,
 		constraints.WrapWidth,
 		constraints.TabStopInterval,
+		constraints.ShowTabs,
 	}
 
 	entry := layoutCaches.Get(k)
@@ -37,6 +38,7 @@ type layoutCacheKey struct {
 	FaceId          string
 	WrapWidth       int
 	TabStopInterval int
+	ShowTabs        bool
 }
 
 type textShaperCache map[text.FontFace]*text.Shaper
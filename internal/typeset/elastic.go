@@ -0,0 +1,131 @@
+package typeset
+
+import (
+	"bytes"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// elasticTabPadding is the space left after the text of a cell, in widths of a space.
+const elasticTabPadding = 2
+
+// ElasticTabStops computes elastic tab stops for text and returns a resolver for them to use
+// as Constraints.TabStops. Line firstLine of text is the first line of the text that will be
+// layed out; the lines before and after it are only used to compute the tab stops.
+//
+// The text of each line is divided into cells by tabs; the text after the last tab of a line
+// is not a cell. A column block is a run of adjacent lines that each have a cell at the same
+// index, and all the cells of a column block are as wide as the widest of them plus some
+// padding, but no narrower than the tab stop interval. This lines up the columns of adjacent
+// lines, so that text aligned with tabs stays aligned whatever the width of the cells.
+func ElasticTabStops(text []byte, firstLine int, constraints Constraints) TabStopResolver {
+	l := newLayouter(nil, constraints)
+	m := elasticMeasurer{
+		l:        &l,
+		advances: map[rune]fixed.Int26_6{},
+		padding:  elasticTabPadding * roundFixed(l.spaceGlyph.Advance),
+		min:      fixed.I(constraints.TabStopInterval),
+	}
+
+	var widths [][]fixed.Int26_6
+	for len(text) > 0 {
+		line := text
+		if i := bytes.IndexByte(text, '\n'); i >= 0 {
+			line, text = text[:i], text[i+1:]
+		} else {
+			text = nil
+		}
+		widths = append(widths, m.cellWidths(line))
+	}
+
+	stops := elasticTabStopsFromCellWidths(widths)
+	return func(line, cell int) (stop fixed.Int26_6, ok bool) {
+		line += firstLine
+		if line < 0 || line >= len(stops) || cell >= len(stops[line]) {
+			return
+		}
+		return stops[line][cell], true
+	}
+}
+
+// elasticTabStopsFromCellWidths returns the position of the end of each cell of each line,
+// given the widths of the cells.
+func elasticTabStopsFromCellWidths(widths [][]fixed.Int26_6) [][]fixed.Int26_6 {
+	stops := make([][]fixed.Int26_6, len(widths))
+	for i, w := range widths {
+		stops[i] = make([]fixed.Int26_6, len(w))
+	}
+
+	for col := 0; ; col++ {
+		found := false
+		for start := 0; start < len(widths); {
+			if len(widths[start]) <= col {
+				start++
+				continue
+			}
+			found = true
+
+			end := start
+			var max fixed.Int26_6
+			for ; end < len(widths) && len(widths[end]) > col; end++ {
+				if widths[end][col] > max {
+					max = widths[end][col]
+				}
+			}
+
+			for i := start; i < end; i++ {
+				stops[i][col] = max
+				if col > 0 {
+					stops[i][col] += stops[i][col-1]
+				}
+			}
+			start = end
+		}
+		if !found {
+			return stops
+		}
+	}
+}
+
+type elasticMeasurer struct {
+	l        *layouter
+	advances map[rune]fixed.Int26_6
+	padding  fixed.Int26_6
+	min      fixed.Int26_6
+}
+
+// cellWidths returns the widths the cells of the line need.
+func (m *elasticMeasurer) cellWidths(line []byte) (widths []fixed.Int26_6) {
+	if bytes.IndexByte(line, '\t') < 0 {
+		return nil
+	}
+
+	var w fixed.Int26_6
+	for _, r := range string(line) {
+		if r != '\t' {
+			w += m.advance(r)
+			continue
+		}
+		w += m.padding
+		if w < m.min {
+			w = m.min
+		}
+		widths = append(widths, w)
+		w = 0
+	}
+	return
+}
+
+func (m *elasticMeasurer) advance(r rune) fixed.Int26_6 {
+	if a, ok := m.advances[r]; ok {
+		return a
+	}
+
+	g, err := m.l.shapeOneRune(r)
+	if err != nil {
+		g = m.l.tofuGlyph
+	}
+	a := roundFixed(g.Advance)
+	m.advances[r] = a
+	return a
+}
@@ -32,9 +32,14 @@ type layouter struct {
 	extraLineGap    fixed.Int26_6
 	text            Text
 	lineBuilder     lineBuilder
+	// cell is the number of tabs already layed out in the current source line, and wrapped is
+	// true if the current line is not the first one the source line was wrapped into.
+	cell    int
+	wrapped bool
 
 	spaceGlyph   text.Glyph
 	tofuGlyph    text.Glyph
+	tabGlyph     text.Glyph
 	newlineGlyph text.Glyph
 	errors       []error
 	shaper       *text.Shaper
@@ -63,7 +68,7 @@ func (l *layouter) layout() Text {
 		// Checks our cache of previously output lines.
 		// The cache is keyed by the unwrapped input line. We read all the way to the newline
 		// then check the cache for which lines that turns into.
-		if l.currentLineEmpty() && cachingEnabled {
+		if l.currentLineEmpty() && cachingEnabled && l.currentLineCacheable() {
 			// TODO: this []rune to string conversion should be avoided.
 			e := l.cache.Get(string(l.currentLine))
 			if e != nil {
@@ -74,6 +79,7 @@ func (l *layouter) layout() Text {
 				l.outputLines(lines)
 				l.currentLine = l.lineStartingAt(l.nextRune)
 				l.incrementSourceLineCount()
+				l.startSourceLine()
 				continue
 			}
 		}
@@ -83,12 +89,14 @@ func (l *layouter) layout() Text {
 			l.appendNewlineToLine()
 			l.cacheAndOutputLine()
 			l.currentLine = l.lineStartingAt(l.nextRune)
+			l.startSourceLine()
 			continue
 		}
 
 		output := l.layoutRune(r, offset)
 		if l.wrapWidth > 0 && l.lineWidthPlus(&output) > l.wrapWidth {
 			l.cacheAndOutputLine()
+			l.wrapped = true
 		}
 
 		l.appendRuneToLine(r, &output)
@@ -106,6 +114,25 @@ func (l *layouter) layout() Text {
 	return l.text
 }
 
+func (l *layouter) startSourceLine() {
+	l.cell = 0
+	l.wrapped = false
+}
+
+// currentLineCacheable returns false if the layout of the current source line may depend on
+// the lines around it, which is the case for lines with tabs when the tab stops are elastic.
+func (l *layouter) currentLineCacheable() bool {
+	if l.constraints.TabStops == nil {
+		return true
+	}
+	for _, r := range l.currentLine {
+		if r == '\t' {
+			return false
+		}
+	}
+	return true
+}
+
 func (l *layouter) nextInputRune() (offset int, rn rune, eof bool) {
 	if l.nextRune >= len(l.input) {
 		eof = true
@@ -149,6 +176,7 @@ func (l *layouter) init() {
 	l.initShaper()
 	l.initSpaceGlyph()
 	l.initTofuGlyph()
+	l.initTabGlyph()
 	l.initLineHeight()
 	l.initNewlineGlyph()
 }
@@ -163,6 +191,7 @@ func (l *layouter) initSpaceGlyph() {
 	if err != nil {
 		l.errors = append(l.errors, fmt.Errorf("Got an error making space Glyph: %v. Perhaps font face contains no glyph for the space rune?", err))
 	}
+	l.text.spaceWidth = l.spaceGlyph.Advance
 }
 
 func (l *layouter) initTofuGlyph() {
@@ -174,6 +203,18 @@ func (l *layouter) initTofuGlyph() {
 
 }
 
+func (l *layouter) initTabGlyph() {
+	if !l.constraints.ShowTabs {
+		return
+	}
+
+	var err error
+	l.tabGlyph, err = l.shapeOneRune('→')
+	if err != nil {
+		l.errors = append(l.errors, fmt.Errorf("Got an error making tab Glyph: %v. Perhaps font face contains no glyph for the arrow rune?", err))
+	}
+}
+
 func (l *layouter) initLineHeight() {
 	l.text.lineHeight, l.text.ascent, l.text.descent = l.calculateLineMetricsBasedOn('X')
 }
@@ -206,6 +247,13 @@ func CalculateLineHeight(face text.FontFace, fontSize, extraLineGap int) (height
 	return
 }
 
+// CalculateAdvance returns how far the glyph for r in the font face at the given size moves
+// the position of the next glyph.
+func CalculateAdvance(r rune, face text.FontFace, fontSize int) (advance fixed.Int26_6, err error) {
+	g, err := shapeOneRune(r, face, fontSize)
+	return g.Advance, err
+}
+
 func (l *layouter) shapeOneRune(r rune) (glyph text.Glyph, err error) {
 	params := text.Parameters{
 		Font:    l.constraints.FontFace.Font,
@@ -265,7 +313,7 @@ func (l *layouter) cacheAndOutputLine() {
 }
 
 func (l *layouter) cacheLine(line Line) {
-	if cachingEnabled {
+	if cachingEnabled && l.currentLineCacheable() {
 		// TODO: this []rune to string conversion should be avoided
 		s := string(l.currentLine)
 		e := l.cache.Get(s)
@@ -315,13 +363,34 @@ func (l *layouter) expandTabsInGlyph(r rune, g *text.Glyph) {
 	}
 
 	nextTabStop := (l.lineBuilder.lineWidth()/l.tabStopInterval + 1) * l.tabStopInterval
+	if stop, ok := l.elasticTabStop(); ok {
+		nextTabStop = stop
+	}
+	l.cell++
 	advance := nextTabStop - l.lineWidth()
 	g.Advance = advance
 	g.Offset = fixed.Point26_6{0, 0}
 	g.ID = l.spaceGlyph.ID
+	if l.tabGlyph.ID != 0 {
+		// Draw the guide glyph at the start of the space the tab takes up.
+		g.ID = l.tabGlyph.ID
+	}
 	g.Ascent = l.text.LineAscent()
 }
 
+// elasticTabStop returns the tab stop for the current tab from the TabStops resolver, if there
+// is one and the stop is after the current position. Lines that were wrapped don't use it.
+func (l *layouter) elasticTabStop() (stop fixed.Int26_6, ok bool) {
+	if l.constraints.TabStops == nil || l.wrapped {
+		return
+	}
+	stop, ok = l.constraints.TabStops(l.text.sourceLineCount, l.cell)
+	if !ok || stop <= l.lineWidth() {
+		return 0, false
+	}
+	return
+}
+
 func (l *layouter) replaceCarriageReturnsInGlyph(r rune, g *text.Glyph) {
 	if r != '\r' || l.tofuGlyph.ID == 0 || !l.constraints.ReplaceCRWithTofu {
 		return
@@ -455,4 +524,15 @@ type Constraints struct {
 	MaxHeight         int // stop laying out when this height is reached. Use -1 to layout all text.
 	ExtraLineGap      int
 	ReplaceCRWithTofu bool
-}
+	// ShowTabs draws tabs as a guide glyph rather than as blank space.
+	ShowTabs bool
+	// TabStops, if set, gives the position of the tab stop for each tab instead of the next
+	// multiple of TabStopInterval. It is used for elastic tab stops.
+	TabStops TabStopResolver
+}
+
+// TabStopResolver returns the position of the tab stop that the tab ending cell `cell` of
+// source line `line` of the text being layed out moves to, measured from the start of the
+// line. Cells and lines are counted from 0. If ok is false the next multiple of the tab stop
+// interval is used.
+type TabStopResolver func(line, cell int) (stop fixed.Int26_6, ok bool)
@@ -14,6 +14,7 @@ type Text struct {
 	lineHeight      fixed.Int26_6
 	ascent          fixed.Int26_6
 	descent         fixed.Int26_6
+	spaceWidth      fixed.Int26_6
 	sourceLineCount int // Count of (unwrapped) lines in the input text that this Text represents.
 	byteCount       int
 }
@@ -32,6 +33,12 @@ func (t Text) LineHeight() int {
 	return t.lineHeight.Round()
 }
 
+// SpaceWidth is the advance of the space glyph in the font the text was laid out with. In a
+// monospaced font it is the width of every column.
+func (t Text) SpaceWidth() fixed.Int26_6 {
+	return t.spaceWidth
+}
+
 func (t Text) LineAscent() fixed.Int26_6 {
 	return t.ascent
 }
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"github.com/armon/go-radix"
 	"github.com/jeffwilliams/anvil/internal/slice"
+	"sort"
 	"unicode"
 	"unicode/utf8"
 )
@@ -13,6 +14,11 @@ type Completion struct {
 	sources []string
 }
 
+// NewCompletion returns a completion of word that came from the named sources.
+func NewCompletion(word string, sources ...string) Completion {
+	return Completion{word: word, sources: sources}
+}
+
 func (c Completion) Word() string {
 	return c.word
 }
@@ -45,6 +51,16 @@ func (c *Completer) Build(source string, text []byte) {
 	}
 }
 
+// AddWords adds each of words from the specified source to the tree. Unlike Build the words
+// are not split further, so they may contain any characters.
+func (c *Completer) AddWords(source string, words []string) {
+	for _, w := range words {
+		if w != "" {
+			c.insert(w, source)
+		}
+	}
+}
+
 func (c *Completer) insert(word string, source string) {
 	node, ok := c.tree.Get(word)
 	var compl *Completion
@@ -107,6 +123,41 @@ func (c *Completer) Completions(word string) (comps []Completion, commonPrefix s
 	return
 }
 
+// MergeCompletions returns the completions in all the lists sorted by word. A word that is in
+// more than one list is returned once with the sources from each.
+func MergeCompletions(lists ...[]Completion) (merged []Completion) {
+	index := map[string]int{}
+	for _, l := range lists {
+		for _, c := range l {
+			i, ok := index[c.word]
+			if !ok {
+				index[c.word] = len(merged)
+				merged = append(merged, Completion{word: c.word, sources: append([]string(nil), c.sources...)})
+				continue
+			}
+			for _, src := range c.sources {
+				if !contains(merged[i].sources, src) {
+					merged[i].sources = append(merged[i].sources, src)
+				}
+			}
+		}
+	}
+
+	sort.Slice(merged, func(a, b int) bool {
+		return merged[a].word < merged[b].word
+	})
+	return
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Completer) commonPrefix(comps []Completion) string {
 	strs := make([]string, len(comps))
 	for i, s := range comps {
@@ -62,3 +62,30 @@ func TestCommonPrefix(t *testing.T) {
 	testCommonPrefixFn("fellow", "fell", "fell")
 	testCommonPrefixFn("fell", "fellow", "fell")
 }
+
+func TestAddWordsAndMergeCompletions(t *testing.T) {
+	dict := NewCompleter()
+	dict.AddWords("dict", []string{"apple", "apple's", "", "apricot"})
+
+	comps, _ := dict.Completions("app")
+	if len(comps) != 2 || comps[0].Word() != "apple" || comps[1].Word() != "apple's" {
+		t.Fatalf("expected the words to be added unsplit but got %v", comps)
+	}
+
+	doc := NewCompleter()
+	doc.Build("win", []byte("apple approach"))
+	docComps, _ := doc.Completions("ap")
+	dictComps, _ := dict.Completions("ap")
+
+	merged := MergeCompletions(docComps, dictComps, []Completion{NewCompletion("apex", "env")})
+	expected := []Completion{
+		NewCompletion("apex", "env"),
+		NewCompletion("apple", "win", "dict"),
+		NewCompletion("apple's", "dict"),
+		NewCompletion("approach", "win"),
+		NewCompletion("apricot", "dict"),
+	}
+	if !reflect.DeepEqual(merged, expected) {
+		t.Fatalf("expected %v but got %v", expected, merged)
+	}
+}
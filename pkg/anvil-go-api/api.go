@@ -14,6 +14,8 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
@@ -21,11 +23,15 @@ import (
 func checkHttpError(rsp *http.Response, msg string) error {
 	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
 		body, _ := ioutil.ReadAll(rsp.Body)
-		return fmt.Errorf("%s: response contained a non-success status code (%d) %s\n", msg, rsp.StatusCode, string(body))
+		return statusError(rsp.StatusCode, string(body), msg)
 	}
 	return nil
 }
 
+func statusError(status int, body, msg string) error {
+	return fmt.Errorf("%s: response contained a non-success status code (%d) %s\n", msg, status, body)
+}
+
 func prefixError(err error, msg string) error {
 	if err == nil {
 		return nil
@@ -52,16 +58,25 @@ func (u URLs) Build(path string) string {
 	return fmt.Sprintf("%s://%s:%s%s", u.Proto, u.Host, u.Port, path)
 }
 
+// DefaultTimeout is how long requests to Anvil may take before they fail, unless changed
+// with SetTimeout.
+const DefaultTimeout = 30 * time.Second
+
 type Anvil struct {
 	sessId string
 	urls   URLs
 	client http.Client
+	// cmds holds the commands registered with RegisterCommands, so that they can be registered
+	// again when a websocket reconnects. It is shared by the copies of the Anvil.
+	cmds *registeredCommands
 }
 
 func New(sessId, port string) Anvil {
 	return Anvil{
 		sessId: sessId,
 		urls:   NewURLs(port),
+		client: http.Client{Timeout: DefaultTimeout},
+		cmds:   &registeredCommands{},
 	}
 }
 
@@ -78,13 +93,16 @@ func NewFromEnv() (anvil Anvil, err error) {
 		return
 	}
 
-	anvil = Anvil{
-		sessId: sessId,
-		urls:   NewURLs(port),
-	}
+	anvil = New(sessId, port)
 	return
 }
 
+// SetTimeout sets how long requests to Anvil, and connecting the websocket, may take before
+// they fail. A timeout of zero means requests never time out.
+func (a *Anvil) SetTimeout(d time.Duration) {
+	a.client.Timeout = d
+}
+
 // Get is a low-level API that performs an HTTP GET request to
 // Anvil and returns the response.
 func (a Anvil) Get(path string) (rsp *http.Response, err error) {
@@ -175,7 +193,21 @@ func (a Anvil) setHeaderFields(hdr *http.Header) {
 // Websock creates a websocket connection with Anvil to receive notifications. The handlers
 // in `handlers` are called when notifications arrive from Anvil.
 func (a Anvil) Websock(handlers WebsockHandlers) (ws Websock, err error) {
-	dialer := websocket.Dialer{}
+	conn, err := a.dialWebsock()
+	if err != nil {
+		return
+	}
+
+	ws = Websock{
+		anvil:    a,
+		conn:     conn,
+		handlers: handlers,
+	}
+	return
+}
+
+func (a Anvil) dialWebsock() (conn *websocket.Conn, err error) {
+	dialer := websocket.Dialer{HandshakeTimeout: a.client.Timeout}
 	hdr := make(http.Header)
 	a.setHeaderFields(&hdr)
 
@@ -183,17 +215,13 @@ func (a Anvil) Websock(handlers WebsockHandlers) (ws Websock, err error) {
 	urls.Proto = "ws"
 	url := urls.Build("/ws")
 
-	var conn *websocket.Conn
-	conn, _, err = dialer.Dial(url, hdr)
-
-	err = prefixError(err, fmt.Sprintf("GET to %s failed", url))
-	if err != nil {
-		return
+	conn, rsp, err := dialer.Dial(url, hdr)
+	if err != nil && rsp != nil && rsp.StatusCode == http.StatusUnauthorized {
+		err = errSessionUnknown
 	}
-
-	ws = Websock{
-		conn:     conn,
-		handlers: handlers,
+	if err != nil {
+		// The error is wrapped so that reconnect can tell why dialling failed.
+		err = fmt.Errorf("GET to %s failed: %w", url, err)
 	}
 	return
 }
@@ -275,6 +303,46 @@ func (a Anvil) NewWindow() (win Window, err error) {
 	return
 }
 
+// NewWindowWithPath is a high-level API to post to /wins in Anvil, which creates a new
+// window, loads the file at path into it and returns it. If the file doesn't exist the window
+// is empty and its tag is set to path. The window is created in the column with index column,
+// or if column is negative in the column with the fewest windows.
+func (a Anvil) NewWindowWithPath(path string, column int) (win Window, err error) {
+	val := map[string]interface{}{
+		"path": path,
+	}
+	if column >= 0 {
+		val["column"] = column
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		err = fmt.Errorf("marshalling window options to JSON failed: %v", err)
+		return
+	}
+
+	err = a.postInto("/wins", bytes.NewReader(b), &win)
+	err = prefixError(err, "creating new window failed")
+	return
+}
+
+// postInto performs an HTTP POST request to Anvil and decodes the JSON response into resp.
+func (a Anvil) postInto(path string, body io.Reader, resp interface{}) (err error) {
+	rsp, err := a.Post(path, body)
+	if err != nil {
+		return
+	}
+	defer rsp.Body.Close()
+
+	raw, err := ioutil.ReadAll(rsp.Body)
+	err = prefixError(err, "Error reading response body")
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(raw, resp)
+	err = prefixError(err, fmt.Sprintf("Error decoding JSON POST response body, body is '%s'", raw))
+	return
+}
+
 // Window is a high-level API to get from /wins/%d/info/ in Anvil, which returns
 // the information about the window with the given id
 func (a Anvil) Window(id int) (win Window, err error) {
@@ -303,6 +371,54 @@ func (a Anvil) SetWindowTag(win Window, tag string) (err error) {
 	return
 }
 
+// EditorTag is a high-level API to get from /tag in Anvil, which returns
+// the editor tag
+func (a Anvil) EditorTag() (tag string, err error) {
+	return a.getTag("/tag")
+}
+
+// SetEditorTag is a high-level API to put to /tag, which replaces the
+// editor tag
+func (a Anvil) SetEditorTag(tag string) (err error) {
+	return a.putTag("/tag", tag)
+}
+
+// Columns is a high-level API to get from /cols in Anvil, which returns
+// the columns from left to right
+func (a Anvil) Columns() (cols []Column, err error) {
+	err = a.GetInto("/cols", &cols)
+	return
+}
+
+// ColumnTag is a high-level API to get from /cols/%d/tag in Anvil, which
+// returns the tag of the column with the given index
+func (a Anvil) ColumnTag(col int) (tag string, err error) {
+	return a.getTag(fmt.Sprintf("/cols/%d/tag", col))
+}
+
+// SetColumnTag is a high-level API to put to /cols/%d/tag, which replaces
+// the tag of the column with the given index
+func (a Anvil) SetColumnTag(col int, tag string) (err error) {
+	return a.putTag(fmt.Sprintf("/cols/%d/tag", col), tag)
+}
+
+func (a Anvil) getTag(path string) (tag string, err error) {
+	rsp, err := a.Get(path)
+	if err == nil {
+		var b []byte
+		b, err = ioutil.ReadAll(rsp.Body)
+		tag = string(b)
+	}
+	return
+}
+
+func (a Anvil) putTag(path, tag string) (err error) {
+	var buf bytes.Buffer
+	buf.WriteString(tag)
+	_, err = a.Put(path, &buf)
+	return
+}
+
 func (a Anvil) SetWindowBody(win Window, body io.Reader) (err error) {
 	_, err = a.Put(fmt.Sprintf("/wins/%d/body", win.Id), body)
 	return
@@ -321,20 +437,226 @@ func (a Anvil) WindowBody(win Window) (body io.Reader, err error) {
 	return
 }
 
+// WindowBodyRange returns the part of the window body between the rune offsets start and end,
+// without transferring the rest of the body.
+func (a Anvil) WindowBodyRange(win Window, start, end int) (body []byte, err error) {
+	rsp, err := a.Get(fmt.Sprintf("/wins/%d/body?start=%d&end=%d", win.Id, start, end))
+	if err != nil {
+		return
+	}
+	defer rsp.Body.Close()
+	body, err = ioutil.ReadAll(rsp.Body)
+	err = prefixError(err, "Error reading body range")
+	return
+}
+
+// SetWindowBodyRange replaces the part of the window body between the rune offsets start and
+// end with text. The cursors and selections are shifted as if the text was typed, and the
+// change is undone in one step.
+func (a Anvil) SetWindowBodyRange(win Window, start, end int, text string) (err error) {
+	_, err = a.Put(fmt.Sprintf("/wins/%d/body?start=%d&end=%d", win.Id, start, end), strings.NewReader(text))
+	return
+}
+
 func (a Anvil) WindowBodyInfo(win Window) (body WindowBody, err error) {
 	err = a.GetInto(fmt.Sprintf("/wins/%d/body/info", win.Id), &body)
 	return
 }
 
+// WindowBodyCursors returns the rune offsets of the cursors in the window body.
+func (a Anvil) WindowBodyCursors(win Window) (cursors []int, err error) {
+	err = a.GetInto(fmt.Sprintf("/wins/%d/body/cursors", win.Id), &cursors)
+	return
+}
+
+// WindowBodyCursorsLineCol returns the cursors in the window body with both their rune offsets
+// and their lines and columns.
+func (a Anvil) WindowBodyCursorsLineCol(win Window) (cursors []Cursor, err error) {
+	err = a.GetInto(fmt.Sprintf("/wins/%d/body/cursors?fmt=linecol", win.Id), &cursors)
+	return
+}
+
+// SetWindowBodyCursors moves the cursors in the window body to the rune offsets.
+func (a Anvil) SetWindowBodyCursors(win Window, cursors []int) (err error) {
+	b, err := json.Marshal(cursors)
+	if err != nil {
+		return
+	}
+	_, err = a.Put(fmt.Sprintf("/wins/%d/body/cursors", win.Id), bytes.NewReader(b))
+	return
+}
+
+// SetCursorsLineCol moves the cursors in the window body to the lines and columns. Anvil
+// converts them to rune offsets, so the body doesn't need to be read to place a cursor on a
+// line. A column past the end of its line is the end of the line.
+func (a Anvil) SetCursorsLineCol(win Window, cursors []LineCol) (err error) {
+	b, err := json.Marshal(cursors)
+	if err != nil {
+		return
+	}
+	_, err = a.Put(fmt.Sprintf("/wins/%d/body/cursors", win.Id), bytes.NewReader(b))
+	return
+}
+
 func (a Anvil) WindowBodySelections(win Window) (sels []Selection, err error) {
 	err = a.GetInto(fmt.Sprintf("/wins/%d/selections", win.Id), &sels)
 	return
 }
 
+func (a Anvil) WindowBodyHighlights(win Window) (hls []Highlight, err error) {
+	err = a.GetInto(fmt.Sprintf("/wins/%d/body/highlights", win.Id), &hls)
+	return
+}
+
+// SetWindowBodyHighlights replaces the highlights in the window body with hls.
+func (a Anvil) SetWindowBodyHighlights(win Window, hls []Highlight) (err error) {
+	b, err := json.Marshal(hls)
+	if err != nil {
+		return
+	}
+	_, err = a.Put(fmt.Sprintf("/wins/%d/body/highlights", win.Id), bytes.NewReader(b))
+	return
+}
+
+// AddWindowBodyHighlights adds hls to the highlights in the window body.
+func (a Anvil) AddWindowBodyHighlights(win Window, hls []Highlight) (err error) {
+	b, err := json.Marshal(hls)
+	if err != nil {
+		return
+	}
+	_, err = a.Post(fmt.Sprintf("/wins/%d/body/highlights", win.Id), bytes.NewReader(b))
+	return
+}
+
+// WindowMode returns the mode of the window.
+func (a Anvil) WindowMode(win Window) (mode WindowMode, err error) {
+	err = a.GetInto(fmt.Sprintf("/wins/%d/mode", win.Id), &mode)
+	return
+}
+
+// SetWindowMode sets the mode of the window. It fails with versions of Anvil that don't
+// support window modes.
+func (a Anvil) SetWindowMode(win Window, mode WindowMode) (err error) {
+	b, err := json.Marshal(mode)
+	if err != nil {
+		return
+	}
+	_, err = a.Put(fmt.Sprintf("/wins/%d/mode", win.Id), bytes.NewReader(b))
+	return
+}
+
+// MoveWindow moves the window to the column with index column, as in the list returned by
+// Columns. An index out of range is clamped to the first or last column.
+func (a Anvil) MoveWindow(win Window, column int) (move WindowMove, err error) {
+	return a.moveWindow(win, map[string]interface{}{"column": column})
+}
+
+// MoveWindowToNamedColumn moves the window to the column whose name, the first word of its
+// tag, is name.
+func (a Anvil) MoveWindowToNamedColumn(win Window, name string) (move WindowMove, err error) {
+	return a.moveWindow(win, map[string]interface{}{"name": name})
+}
+
+func (a Anvil) moveWindow(win Window, val map[string]interface{}) (move WindowMove, err error) {
+	b, err := json.Marshal(val)
+	if err != nil {
+		return
+	}
+	err = a.postInto(fmt.Sprintf("/wins/%d/move", win.Id), bytes.NewReader(b), &move)
+	err = prefixError(err, "moving window failed")
+	return
+}
+
+// RegisterCommands is a high-level API to post to /cmds in Anvil, which creates commands
+// that are sent to the client as notifications when executed. The commands are remembered
+// and registered again when a websocket with Reconnect set reconnects.
 func (a Anvil) RegisterCommands(names ...string) error {
-	var buf bytes.Buffer
-	l := strings.Join(names, ",")
-	buf.WriteString(fmt.Sprintf(`["%s"]`, l))
-	_, err := a.Post("/cmds", &buf)
+	err := a.registerCommands(names)
+	if err == nil && a.cmds != nil {
+		a.cmds.add(names)
+	}
+	return err
+}
+
+func (a Anvil) registerCommands(names []string) error {
+	b, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	_, err = a.Post("/cmds", bytes.NewReader(b))
 	return err
 }
+
+// registeredCommands is the list of commands that a client registered.
+type registeredCommands struct {
+	lock  sync.Mutex
+	names []string
+}
+
+func (r *registeredCommands) add(names []string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.names = append(r.names, names...)
+}
+
+func (r *registeredCommands) list() []string {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+// ApplyEdits is a high-level API to post to /edits in Anvil, which applies the edits
+// in all of the groups or, if any group can't be applied, none of them. The results
+// describe why each file could not be edited, if it couldn't.
+func (a Anvil) ApplyEdits(groups []EditGroup) (results []EditResult, err error) {
+	b, err := json.Marshal(groups)
+	if err != nil {
+		err = fmt.Errorf("marshalling edits to JSON failed: %v", err)
+		return
+	}
+
+	req, url, err := a.buildReq(http.MethodPost, "/edits", bytes.NewReader(b))
+	if err != nil {
+		return
+	}
+
+	rsp, err := a.client.Do(req)
+	err = prefixError(err, fmt.Sprintf("POST to %s failed", url))
+	if err != nil {
+		return
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode != http.StatusOK && rsp.StatusCode != http.StatusConflict && rsp.StatusCode != http.StatusInternalServerError {
+		err = checkHttpError(rsp, fmt.Sprintf("POST to %s failed", url))
+		return
+	}
+
+	var body struct {
+		Files []EditResult
+	}
+	raw, err := ioutil.ReadAll(rsp.Body)
+	if err == nil {
+		err = json.Unmarshal(raw, &body)
+	}
+	err = prefixError(err, fmt.Sprintf("Error decoding JSON POST response body, body is '%s'", raw))
+	if err != nil {
+		return
+	}
+	results = body.Files
+
+	if rsp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("edits were not applied: %s", describeEditErrors(results))
+	}
+	return
+}
+
+func describeEditErrors(results []EditResult) string {
+	var msgs []string
+	for _, r := range results {
+		if r.Error != "" {
+			msgs = append(msgs, fmt.Sprintf("%s: %s", r.Path, r.Error))
+		}
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// Batch is a list of operations that Anvil performs in order in one step, so that the user
+// can't change the windows between them. Operations are added to a Batch using its methods, and
+// performed using Anvil.RunBatch. The methods that read from a window return a value that holds
+// what was read once the batch has run.
+type Batch struct {
+	ops     []batchOp
+	texts   map[int]*BatchText
+	cursors map[int]*BatchCursors
+}
+
+type batchOp struct {
+	Op      string
+	WinId   int
+	Text    string   `json:",omitempty"`
+	Cursors []int    `json:",omitempty"`
+	Cmd     string   `json:",omitempty"`
+	Args    []string `json:",omitempty"`
+}
+
+type batchResult struct {
+	Index   int
+	Op      string
+	Text    string
+	Cursors []int
+	Error   string
+}
+
+// BatchText holds a window body or tag read by a Batch. Text is set once the batch has run.
+type BatchText struct {
+	Text string
+}
+
+// BatchCursors holds the cursors of a window body read by a Batch. Cursors is set once the batch
+// has run.
+type BatchCursors struct {
+	Cursors []int
+}
+
+// BatchError is returned by RunBatch when an operation in the batch failed. The operations
+// before it were performed, and those after it were not.
+type BatchError struct {
+	// Index is the index of the operation that failed, in the order they were added.
+	Index int
+	// Status is the HTTP status code Anvil responded with.
+	Status int
+	Msg    string
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("batch operation %d failed: %s", e.Index, e.Msg)
+}
+
+// Len returns the number of operations in the batch.
+func (b *Batch) Len() int {
+	return len(b.ops)
+}
+
+func (b *Batch) add(op batchOp) int {
+	b.ops = append(b.ops, op)
+	return len(b.ops) - 1
+}
+
+func (b *Batch) addText(op batchOp) *BatchText {
+	if b.texts == nil {
+		b.texts = make(map[int]*BatchText)
+	}
+	t := &BatchText{}
+	b.texts[b.add(op)] = t
+	return t
+}
+
+// WindowBody adds an operation that reads the window body.
+func (b *Batch) WindowBody(win Window) *BatchText {
+	return b.addText(batchOp{Op: "getbody", WinId: win.Id})
+}
+
+// SetWindowBody adds an operation that replaces the window body with body.
+func (b *Batch) SetWindowBody(win Window, body string) {
+	b.add(batchOp{Op: "putbody", WinId: win.Id, Text: body})
+}
+
+// WindowTag adds an operation that reads the window tag.
+func (b *Batch) WindowTag(win Window) *BatchText {
+	return b.addText(batchOp{Op: "gettag", WinId: win.Id})
+}
+
+// SetWindowTag adds an operation that replaces the window tag with tag.
+func (b *Batch) SetWindowTag(win Window, tag string) {
+	b.add(batchOp{Op: "puttag", WinId: win.Id, Text: tag})
+}
+
+// WindowBodyCursors adds an operation that reads the rune offsets of the cursors in the window
+// body.
+func (b *Batch) WindowBodyCursors(win Window) *BatchCursors {
+	if b.cursors == nil {
+		b.cursors = make(map[int]*BatchCursors)
+	}
+	c := &BatchCursors{}
+	b.cursors[b.add(batchOp{Op: "getcursors", WinId: win.Id})] = c
+	return c
+}
+
+// SetWindowBodyCursors adds an operation that moves the cursors in the window body to the rune
+// offsets.
+func (b *Batch) SetWindowBodyCursors(win Window, cursors []int) {
+	b.add(batchOp{Op: "putcursors", WinId: win.Id, Cursors: cursors})
+}
+
+// Execute adds an operation that executes a command as if it was run from the editor tag. As
+// with Anvil.Execute the command runs after the batch, so the operations after it don't see its
+// effects.
+func (b *Batch) Execute(command string, args []string) {
+	b.add(batchOp{Op: "execute", WinId: -1, Cmd: command, Args: args})
+}
+
+// ExecuteInWin adds an operation that executes a command as if it was run from the window.
+func (b *Batch) ExecuteInWin(win Window, command string, args []string) {
+	b.add(batchOp{Op: "execute", WinId: win.Id, Cmd: command, Args: args})
+}
+
+// RunBatch is a high-level API to post to /batch in Anvil, which performs the operations in the
+// batch in one step. If an operation fails the error is a *BatchError, and the values returned
+// by the methods of the batch for the operations before it are set.
+func (a Anvil) RunBatch(b *Batch) (err error) {
+	body, err := json.Marshal(b.ops)
+	if err != nil {
+		err = fmt.Errorf("marshalling batch to JSON failed: %v", err)
+		return
+	}
+
+	req, url, err := a.buildReq(http.MethodPost, "/batch", bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	rsp, err := a.client.Do(req)
+	err = prefixError(err, fmt.Sprintf("POST to %s failed", url))
+	if err != nil {
+		return
+	}
+	defer rsp.Body.Close()
+
+	raw, err := ioutil.ReadAll(rsp.Body)
+	err = prefixError(err, "Error reading response body")
+	if err != nil {
+		return
+	}
+
+	var results []batchResult
+	err = json.Unmarshal(raw, &results)
+	if err != nil {
+		// A failure that isn't for an operation, such as a malformed request, has a text body.
+		if rsp.StatusCode != http.StatusOK {
+			err = fmt.Errorf("POST to %s failed: response contained a non-success status code (%d) %s", url, rsp.StatusCode, raw)
+			return
+		}
+		err = prefixError(err, fmt.Sprintf("Error decoding JSON POST response body, body is '%s'", raw))
+		return
+	}
+
+	for _, r := range results {
+		if r.Error != "" {
+			return &BatchError{Index: r.Index, Status: rsp.StatusCode, Msg: r.Error}
+		}
+		if t, ok := b.texts[r.Index]; ok {
+			t.Text = r.Text
+		}
+		if c, ok := b.cursors[r.Index]; ok {
+			c.Cursors = r.Cursors
+		}
+	}
+	return
+}
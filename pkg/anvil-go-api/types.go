@@ -4,10 +4,77 @@ type Window struct {
 	Id         int
 	GlobalPath string
 	Path       string
+	// MissingFinalNewline is true if the window holds a file whose text doesn't end with a newline.
+	MissingFinalNewline bool
+	// Dirty is true if the window holds a file with changes that haven't been saved.
+	Dirty bool
+	// Encoding is the character encoding and line endings of the file in the window, like
+	// "utf-16le bom crlf". It is empty if the window doesn't hold a file.
+	Encoding string
+	// Dir is the directory commands executed from the window run in. It is the directory set
+	// with the Cd command, or the directory of the file in the window.
+	Dir string
+}
+
+// Column is a column of windows. The index of a column in the list returned by Columns is
+// the index used to get and set its tag.
+type Column struct {
+	Id      int
+	Tag     string
+	Windows []int
+}
+
+// WindowMove is the result of moving a window to another column.
+type WindowMove struct {
+	// Column is the index of the column the window was moved to.
+	Column int `json:"column"`
+	// Clamped is true if the requested column index was out of range, so the window was moved
+	// to the first or last column instead.
+	Clamped bool `json:"clamped"`
 }
 
 type WindowBody struct {
 	Len int
+	// Cols and Rows are the approximate number of characters that fit across the body, and
+	// the number of lines that fit down it.
+	Cols, Rows int
+	// Generation changes whenever the body text changes.
+	Generation int
+}
+
+// WindowMode makes a window driven by a tool behave like a terminal.
+type WindowMode struct {
+	// FollowOutput scrolls the body to the end, and moves the cursor there, when text is
+	// appended to it while the end of the body is visible.
+	FollowOutput bool `json:"followOutput"`
+	// UserScrollPausesFollow pauses following while the user has scrolled away from the end.
+	UserScrollPausesFollow bool `json:"userScrollPausesFollow"`
+	// Paused is true while following is paused. Cursor moves made through the API are ignored
+	// while it is true. It is ignored when the mode is set.
+	Paused bool `json:"paused"`
+}
+
+// EditGroup is a list of edits to apply to one file. If the file is open in a window the
+// edits are applied to the window body, otherwise they are applied to the file on disk.
+type EditGroup struct {
+	Path string
+	// Generation, if set, must match the Generation of the window body or the edits are rejected.
+	Generation *int `json:",omitempty"`
+	Edits      []Edit
+}
+
+// Edit replaces Length runes at rune offset Offset with Text.
+type Edit struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+// EditResult describes the outcome of applying an EditGroup. Error is empty if the group
+// could be applied.
+type EditResult struct {
+	Path  string
+	Error string
 }
 
 type Notification struct {
@@ -16,12 +83,36 @@ type Notification struct {
 	Offset int
 	Len    int
 	Cmd    []string
+	// Col is the index of the column whose tag changed, for NotificationOpColTagChanged.
+	Col int
+	// Selections are the selections in the window body for NotificationOpSelectionChanged,
+	// the primary selection first.
+	Selections []Selection
 }
 
 type Selection struct {
 	Start, End, Len int
 }
 
+// LineCol is a position in a window body as a line and a column in runes, both counting from 1.
+type LineCol struct {
+	Line int `json:"line"`
+	Col  int `json:"col"`
+}
+
+// Cursor is a cursor in a window body: its rune offset, and its line and column counting from 1.
+type Cursor struct {
+	Offset    int
+	Line, Col int
+}
+
+// Highlight colors the text of a window body in the rune range [Start,End). Color is a hex
+// color code in the form #rrggbb or a color name.
+type Highlight struct {
+	Start, End int
+	Color      string
+}
+
 type NotificationOp int
 
 const (
@@ -31,6 +122,19 @@ const (
 	NotificationOpPut
 	NotificationOpFileClosed
 	NotificationOpFileOpened
+	// NotificationOpDirty is sent when a window that had no unsaved changes is changed.
+	NotificationOpDirty
+	// NotificationOpClean is sent when a window that had unsaved changes no longer does,
+	// for example because it was saved.
+	NotificationOpClean
+	// NotificationOpEditorTagChanged is sent when the editor tag changes. Offset and Len are
+	// the rune offset and length of the text that was inserted or deleted.
+	NotificationOpEditorTagChanged
+	// NotificationOpColTagChanged is sent when the tag of the column with index Col changes.
+	NotificationOpColTagChanged
+	// NotificationOpSelectionChanged is sent shortly after the primary selection in the body of
+	// window WinId changes. Selections holds all of the selections in the body.
+	NotificationOpSelectionChanged
 )
 
 type ExecuteReq struct {
@@ -38,8 +142,31 @@ type ExecuteReq struct {
 	Args []string
 }
 
+// WebsockMessageId is the kind of a message sent to the client over a websocket, given by the
+// field Message. Notifications have no such field.
 type WebsockMessageId int
 
 const (
 	WebsockMessageNotification = iota
+	// WebsockMessageResponse is the response to a request made over the websocket.
+	WebsockMessageResponse
 )
+
+// WebsockRequest is a request made over a websocket. Method, Path and Body are those of the HTTP
+// request that would otherwise be made, and Id is chosen by the client to match the response to
+// the request.
+type WebsockRequest struct {
+	Id     int
+	Method string
+	Path   string
+	Body   string
+}
+
+// WebsockResponse is the response to a WebsockRequest. Message is WebsockMessageResponse, and
+// Status is the HTTP status code of the response.
+type WebsockResponse struct {
+	Message   WebsockMessageId
+	RequestId int
+	Status    int
+	Body      string
+}
@@ -2,34 +2,152 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 type Websock struct {
+	anvil    Anvil
 	conn     *websocket.Conn
 	handlers WebsockHandlers
+	// pending holds the requests made over the websocket by a WebsockClient that are waiting for
+	// their response. It is nil for websockets that only receive notifications.
+	pending *pendingRequests
 }
 
 type WebsockHandlers struct {
 	Notification func(n *Notification, err error)
+	// SelectionChanged is called for each NotificationOpSelectionChanged notification, after
+	// Notification.
+	SelectionChanged func(winId int, sels []Selection)
+	// Reconnect, if set, makes Run connect the websocket again when the connection is lost
+	// while Anvil keeps running, for example because a network connection or an ssh tunnel
+	// dropped, rather than returning. It waits longer between each failed attempt, and registers
+	// the commands registered with RegisterCommands again once connected. It does not survive a
+	// restart of Anvil: sessions only last as long as the Anvil that created them and Anvil
+	// listens on a different port each time it starts, so Run returns if Anvil no longer listens
+	// on the port or no longer knows the session, or after maxReconnectAttempts. A client that
+	// must outlive Anvil has to be started again by the new Anvil.
+	Reconnect bool
+	// ConnectionChanged, if set, is called when the connection is lost with the error that
+	// ended it, and when Run connects again.
+	ConnectionChanged func(state ConnectionState, err error)
 }
 
+// ConnectionState is the state of the connection of a websocket.
+type ConnectionState int
+
+const (
+	Disconnected ConnectionState = iota
+	Connected
+)
+
+func (s ConnectionState) String() string {
+	if s == Connected {
+		return "connected"
+	}
+	return "disconnected"
+}
+
+const (
+	minReconnectDelay = 250 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+	// maxReconnectAttempts is how many times Run tries to connect again before it gives up.
+	maxReconnectAttempts = 8
+)
+
+// errSessionUnknown is returned when connecting the websocket is refused because Anvil doesn't
+// know the session, for example because it was removed or Anvil was restarted.
+var errSessionUnknown = errors.New("Anvil does not know the session")
+
 func (ws *Websock) Run() error {
 	for {
 		typ, buf, err := ws.conn.ReadMessage()
 		if err != nil {
-			return err
+			ws.pending.fail(err)
+			if !ws.handlers.Reconnect || ws.pending.isClosed() {
+				return err
+			}
+			ws.connectionChanged(Disconnected, err)
+			if err := ws.reconnect(); err != nil {
+				return prefixError(err, "reconnecting to Anvil failed")
+			}
+			if !ws.pending.setConn(ws.conn) {
+				return errWebsockClientClosed
+			}
+			ws.connectionChanged(Connected, nil)
+			continue
 		}
 
 		if typ != websocket.TextMessage {
 			continue
 		}
 
+		if ws.pending.deliver(buf) {
+			continue
+		}
+
 		var n Notification
 		err = json.Unmarshal(buf, &n)
 		if ws.handlers.Notification != nil {
 			ws.handlers.Notification(&n, err)
 		}
+		if err == nil && n.Op == NotificationOpSelectionChanged && ws.handlers.SelectionChanged != nil {
+			ws.handlers.SelectionChanged(n.WinId, n.Selections)
+		}
+	}
+}
+
+// reconnect connects the websocket again and registers the client's commands, retrying with
+// exponential backoff. It gives up at once if the connection can never succeed, and after
+// maxReconnectAttempts otherwise.
+func (ws *Websock) reconnect() (err error) {
+	ws.conn.Close()
+
+	delay := minReconnectDelay
+	for i := 0; i < maxReconnectAttempts; i++ {
+		time.Sleep(delay)
+		delay *= 2
+		if delay > maxReconnectDelay {
+			delay = maxReconnectDelay
+		}
+
+		var conn *websocket.Conn
+		conn, err = ws.anvil.dialWebsock()
+		if err != nil {
+			if permanentDialError(err) {
+				return
+			}
+			continue
+		}
+
+		if ws.anvil.cmds != nil {
+			if names := ws.anvil.cmds.list(); len(names) > 0 {
+				if err = ws.anvil.registerCommands(names); err != nil {
+					conn.Close()
+					continue
+				}
+			}
+		}
+
+		ws.conn = conn
+		return nil
+	}
+	return prefixError(err, fmt.Sprintf("giving up after %d attempts", maxReconnectAttempts))
+}
+
+// permanentDialError returns true if err, returned from dialWebsock, means that connecting again
+// can't succeed: nothing listens on the port any more, or Anvil doesn't know the session.
+func permanentDialError(err error) bool {
+	return errors.Is(err, errSessionUnknown) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func (ws *Websock) connectionChanged(state ConnectionState, err error) {
+	if ws.handlers.ConnectionChanged != nil {
+		ws.handlers.ConnectionChanged(state, err)
 	}
 }
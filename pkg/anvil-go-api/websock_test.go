@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWebsockReconnectGivesUp(t *testing.T) {
+	tests := []struct {
+		name string
+		// closeServer stops anything listening on the port once the first connection is made.
+		closeServer bool
+		err         string
+	}{
+		{"session removed", false, errSessionUnknown.Error()},
+		{"Anvil exited", true, "refused"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			// The server accepts the first connection and closes it at once, and refuses the
+			// session after that.
+			var conns atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+				if conns.Add(1) > 1 {
+					http.Error(rsp, "Anvil-Sess header is missing or invalid", http.StatusUnauthorized)
+					return
+				}
+				conn, err := (&websocket.Upgrader{}).Upgrade(rsp, req, nil)
+				if err == nil {
+					conn.Close()
+				}
+			}))
+			defer server.Close()
+
+			u, err := url.Parse(server.URL)
+			if err != nil {
+				t.Fatalf("parsing the URL of the server failed: %v", err)
+			}
+			ws, err := New("sess", u.Port()).Websock(WebsockHandlers{Reconnect: true})
+			if err != nil {
+				t.Fatalf("connecting failed: %v", err)
+			}
+			if tc.closeServer {
+				server.Close()
+			}
+
+			done := make(chan error, 1)
+			go func() { done <- ws.Run() }()
+			select {
+			case err := <-done:
+				if err == nil || !strings.Contains(err.Error(), tc.err) {
+					t.Fatalf("expected Run to fail with an error containing %q but got %v", tc.err, err)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Run kept trying to reconnect")
+			}
+		})
+	}
+}
+
+func TestWebsockReconnectRegistersCommandsAgain(t *testing.T) {
+	// The server closes the first websocket connection at once, and records the commands posted
+	// to /cmds.
+	var conns atomic.Int32
+	registered := make(chan []string, 10)
+	reconnected := make(chan *websocket.Conn, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case "/cmds":
+			var names []string
+			if err := json.NewDecoder(req.Body).Decode(&names); err != nil {
+				http.Error(rsp, err.Error(), http.StatusBadRequest)
+				return
+			}
+			registered <- names
+		case "/ws":
+			conn, err := (&websocket.Upgrader{}).Upgrade(rsp, req, nil)
+			if err != nil {
+				return
+			}
+			if conns.Add(1) == 1 {
+				conn.Close()
+				return
+			}
+			reconnected <- conn
+		}
+	}))
+	defer server.Close()
+
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("parsing the URL of the server failed: %v", err)
+	}
+	anvil := New("sess", u.Port())
+	if err := anvil.RegisterCommands("Foo", "Bar"); err != nil {
+		t.Fatalf("registering commands failed: %v", err)
+	}
+	if names := <-registered; strings.Join(names, ",") != "Foo,Bar" {
+		t.Fatalf("unexpected commands registered %v", names)
+	}
+
+	var states []ConnectionState
+	ws, err := anvil.Websock(WebsockHandlers{
+		Reconnect: true,
+		ConnectionChanged: func(state ConnectionState, err error) {
+			states = append(states, state)
+		},
+	})
+	if err != nil {
+		t.Fatalf("connecting failed: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- ws.Run() }()
+
+	var conn *websocket.Conn
+	select {
+	case conn = <-reconnected:
+	case err := <-done:
+		t.Fatalf("Run returned instead of reconnecting: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the websocket did not reconnect")
+	}
+	select {
+	case names := <-registered:
+		if strings.Join(names, ",") != "Foo,Bar" {
+			t.Fatalf("expected the commands to be registered again but got %v", names)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the commands were not registered again")
+	}
+
+	// Once Anvil exits Run gives up.
+	server.Close()
+	conn.Close()
+	<-done
+	if len(states) < 2 || states[0] != Disconnected || states[1] != Connected {
+		t.Fatalf("expected the connection to be lost and made again but the states were %v", states)
+	}
+}
@@ -0,0 +1,325 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var errWebsockClientClosed = errors.New("the websocket client is closed")
+
+// WebsockClient makes requests to Anvil over a websocket instead of over HTTP, and receives the
+// notifications over the same connection, so that a client only needs one connection. Many
+// requests may be waiting for a response at once, and notifications are delivered to the
+// handlers while they wait.
+type WebsockClient struct {
+	ws      *Websock
+	pending *pendingRequests
+	timeout time.Duration
+	// done is closed when the connection is lost for good, after which err holds the error
+	// that ended it.
+	done chan struct{}
+	err  error
+}
+
+// WebsockClient creates a websocket connection with Anvil to make requests over and to receive
+// notifications. The handlers in `handlers` are called when notifications arrive from Anvil.
+// Unlike with Websock, the websocket is read in the background and there is no Run to call.
+func (a Anvil) WebsockClient(handlers WebsockHandlers) (c *WebsockClient, err error) {
+	ws, err := a.Websock(handlers)
+	if err != nil {
+		return
+	}
+
+	p := &pendingRequests{conn: ws.conn, reqs: map[int]chan websockResult{}}
+	ws.pending = p
+	c = &WebsockClient{
+		ws:      &ws,
+		pending: p,
+		timeout: a.client.Timeout,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		c.err = c.ws.Run()
+		close(c.done)
+	}()
+	return
+}
+
+// Close closes the connection. Requests waiting for a response fail.
+func (c *WebsockClient) Close() error {
+	return c.pending.close()
+}
+
+// Wait waits until the connection is lost and returns the error that ended it. If Reconnect is
+// set in the handlers it only returns once Close is called.
+func (c *WebsockClient) Wait() error {
+	<-c.done
+	return c.err
+}
+
+// Do is a low-level API that makes a request to Anvil over the websocket and waits for the
+// response. Method, path and body are those of the HTTP request that would otherwise be made.
+// Body should usually be a JSON encoded value.
+func (c *WebsockClient) Do(method, path string, body []byte) (rsp WebsockResponse, err error) {
+	msg := fmt.Sprintf("%s to %s over the websocket failed", method, path)
+
+	id, ch, conn, err := c.pending.add()
+	err = prefixError(err, msg)
+	if err != nil {
+		return
+	}
+
+	req := WebsockRequest{Id: id, Method: method, Path: path, Body: string(body)}
+	err = c.pending.write(conn, req, c.timeout)
+	if err != nil {
+		c.pending.remove(id)
+		err = prefixError(err, msg)
+		return
+	}
+
+	var timeout <-chan time.Time
+	if c.timeout > 0 {
+		t := time.NewTimer(c.timeout)
+		defer t.Stop()
+		timeout = t.C
+	}
+
+	select {
+	case r := <-ch:
+		rsp, err = r.rsp, r.err
+	case <-timeout:
+		c.pending.remove(id)
+		err = fmt.Errorf("no response after %s", c.timeout)
+	}
+
+	err = prefixError(err, msg)
+	if err != nil {
+		return
+	}
+	if rsp.Status < 200 || rsp.Status >= 300 {
+		err = statusError(rsp.Status, rsp.Body, msg)
+	}
+	return
+}
+
+// DoInto is a low-level API that makes a request to Anvil over the websocket and decodes the
+// response into resp. It is decoded using the encoding/json package.
+func (c *WebsockClient) DoInto(method, path string, body []byte, resp interface{}) (err error) {
+	rsp, err := c.Do(method, path, body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal([]byte(rsp.Body), resp)
+	err = prefixError(err, fmt.Sprintf("Error decoding JSON %s response body, body is '%s'", method, rsp.Body))
+	return
+}
+
+// Windows returns the windows, like Anvil.Windows.
+func (c *WebsockClient) Windows() (wins []Window, err error) {
+	err = c.DoInto(http.MethodGet, "/wins", nil, &wins)
+	return
+}
+
+// Window returns the information about the window with the given id, like Anvil.Window.
+func (c *WebsockClient) Window(id int) (win Window, err error) {
+	err = c.DoInto(http.MethodGet, fmt.Sprintf("/wins/%d/info", id), nil, &win)
+	return
+}
+
+// WindowTag returns the window tag, like Anvil.WindowTag.
+func (c *WebsockClient) WindowTag(win Window) (tag string, err error) {
+	rsp, err := c.Do(http.MethodGet, fmt.Sprintf("/wins/%d/tag", win.Id), nil)
+	tag = rsp.Body
+	return
+}
+
+// SetWindowTag sets the window tag, like Anvil.SetWindowTag.
+func (c *WebsockClient) SetWindowTag(win Window, tag string) (err error) {
+	_, err = c.Do(http.MethodPut, fmt.Sprintf("/wins/%d/tag", win.Id), []byte(tag))
+	return
+}
+
+// WindowBody returns the window body, like Anvil.WindowBody.
+func (c *WebsockClient) WindowBody(win Window) (body string, err error) {
+	rsp, err := c.Do(http.MethodGet, fmt.Sprintf("/wins/%d/body", win.Id), nil)
+	body = rsp.Body
+	return
+}
+
+// SetWindowBodyString replaces the window body, like Anvil.SetWindowBodyString.
+func (c *WebsockClient) SetWindowBodyString(win Window, body string) (err error) {
+	_, err = c.Do(http.MethodPut, fmt.Sprintf("/wins/%d/body", win.Id), []byte(body))
+	return
+}
+
+// Execute executes a command as if it was run from the editor tag, like Anvil.Execute.
+func (c *WebsockClient) Execute(command string, args []string) (err error) {
+	return c.execute(-1, command, args)
+}
+
+// ExecuteInWin executes a command as if it was run in the specified window, like
+// Anvil.ExecuteInWin.
+func (c *WebsockClient) ExecuteInWin(win Window, command string, args []string) (err error) {
+	return c.execute(win.Id, command, args)
+}
+
+func (c *WebsockClient) execute(winId int, command string, args []string) (err error) {
+	val := map[string]interface{}{
+		"cmd":   command,
+		"args":  args,
+		"winid": winId,
+	}
+	b, err := json.Marshal(val)
+	if err != nil {
+		err = fmt.Errorf("marshalling command to JSON failed: %v", err)
+		return
+	}
+
+	_, err = c.Do(http.MethodPost, "/execute", b)
+	return
+}
+
+type websockResult struct {
+	rsp WebsockResponse
+	err error
+}
+
+// pendingRequests holds the requests made over a websocket that are waiting for their response,
+// by id. It is shared by the goroutines making requests and the one reading the websocket.
+type pendingRequests struct {
+	lock   sync.Mutex
+	conn   *websocket.Conn
+	nextId int
+	reqs   map[int]chan websockResult
+	closed bool
+	// writeLock serializes the writes to the websocket.
+	writeLock sync.Mutex
+}
+
+// add registers a new request and returns its id, the channel its result is sent on and the
+// connection to send it over.
+func (p *pendingRequests) add() (id int, ch chan websockResult, conn *websocket.Conn, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		err = errWebsockClientClosed
+		return
+	}
+
+	p.nextId++
+	id = p.nextId
+	ch = make(chan websockResult, 1)
+	p.reqs[id] = ch
+	conn = p.conn
+	return
+}
+
+func (p *pendingRequests) remove(id int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.reqs, id)
+}
+
+func (p *pendingRequests) write(conn *websocket.Conn, req WebsockRequest, timeout time.Duration) error {
+	p.writeLock.Lock()
+	defer p.writeLock.Unlock()
+
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	err := conn.SetWriteDeadline(deadline)
+	if err != nil {
+		return err
+	}
+	return conn.WriteJSON(req)
+}
+
+// deliver sends the response in the message buf to the request waiting for it. It returns false
+// if the message is not a response.
+func (p *pendingRequests) deliver(buf []byte) bool {
+	if p == nil {
+		return false
+	}
+
+	var msg struct{ Message WebsockMessageId }
+	if err := json.Unmarshal(buf, &msg); err != nil || msg.Message != WebsockMessageResponse {
+		return false
+	}
+
+	var rsp WebsockResponse
+	if err := json.Unmarshal(buf, &rsp); err != nil {
+		return true
+	}
+
+	p.lock.Lock()
+	ch, ok := p.reqs[rsp.RequestId]
+	delete(p.reqs, rsp.RequestId)
+	p.lock.Unlock()
+
+	if ok {
+		ch <- websockResult{rsp: rsp}
+	}
+	return true
+}
+
+// fail makes all of the requests waiting for a response fail with err. Their responses can't
+// arrive once the connection they were sent over is lost.
+func (p *pendingRequests) fail(err error) {
+	if p == nil {
+		return
+	}
+
+	p.lock.Lock()
+	reqs := p.reqs
+	p.reqs = map[int]chan websockResult{}
+	p.lock.Unlock()
+
+	for _, ch := range reqs {
+		ch <- websockResult{err: fmt.Errorf("the connection was lost: %v", err)}
+	}
+}
+
+// setConn makes the requests be sent over conn after reconnecting. It closes conn and returns
+// false if the client was closed while reconnecting.
+func (p *pendingRequests) setConn(conn *websocket.Conn) bool {
+	if p == nil {
+		return true
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.closed {
+		conn.Close()
+		return false
+	}
+	p.conn = conn
+	return true
+}
+
+func (p *pendingRequests) isClosed() bool {
+	if p == nil {
+		return false
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.closed
+}
+
+func (p *pendingRequests) close() error {
+	p.lock.Lock()
+	p.closed = true
+	conn := p.conn
+	p.lock.Unlock()
+
+	return conn.Close()
+}
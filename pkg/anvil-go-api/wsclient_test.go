@@ -0,0 +1,194 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeAnvil serves the websocket of Anvil. It answers each request with the path of the request
+// as the body, except that it holds the requests for /slow until release is closed and that
+// /missing is not found.
+type fakeAnvil struct {
+	t       *testing.T
+	server  *httptest.Server
+	conns   chan *websocket.Conn
+	release chan struct{}
+	lock    sync.Mutex
+}
+
+func newFakeAnvil(t *testing.T) *fakeAnvil {
+	f := &fakeAnvil{
+		t:       t,
+		conns:   make(chan *websocket.Conn, 1),
+		release: make(chan struct{}),
+	}
+	f.server = httptest.NewServer(http.HandlerFunc(f.serve))
+	return f
+}
+
+func (f *fakeAnvil) anvil() Anvil {
+	u, err := url.Parse(f.server.URL)
+	if err != nil {
+		f.t.Fatalf("parsing the URL of the server failed: %v", err)
+	}
+	a := New("sess", u.Port())
+	a.SetTimeout(5 * time.Second)
+	return a
+}
+
+func (f *fakeAnvil) serve(rsp http.ResponseWriter, req *http.Request) {
+	conn, err := (&websocket.Upgrader{}).Upgrade(rsp, req, nil)
+	if err != nil {
+		return
+	}
+	f.conns <- conn
+
+	for {
+		var r WebsockRequest
+		if err := conn.ReadJSON(&r); err != nil {
+			return
+		}
+		go func() {
+			if r.Path == "/slow" {
+				<-f.release
+			}
+			status := http.StatusOK
+			if r.Path == "/missing" {
+				status = http.StatusNotFound
+			}
+			f.write(conn, WebsockResponse{
+				Message:   WebsockMessageResponse,
+				RequestId: r.Id,
+				Status:    status,
+				Body:      r.Method + " " + r.Path + " " + r.Body,
+			})
+		}()
+	}
+}
+
+func (f *fakeAnvil) write(conn *websocket.Conn, v interface{}) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	conn.WriteJSON(v)
+}
+
+func TestWebsockClientConcurrentRequests(t *testing.T) {
+	f := newFakeAnvil(t)
+	defer f.server.Close()
+
+	notes := make(chan Notification, 10)
+	c, err := f.anvil().WebsockClient(WebsockHandlers{
+		Notification: func(n *Notification, err error) {
+			if err == nil {
+				notes <- *n
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("connecting failed: %v", err)
+	}
+	defer c.Close()
+	conn := <-f.conns
+
+	slow := make(chan string, 1)
+	go func() {
+		rsp, err := c.Do(http.MethodGet, "/slow", nil)
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		slow <- rsp.Body
+	}()
+
+	// The requests made while /slow waits for its response are answered first.
+	var wg sync.WaitGroup
+	bodies := make([]string, 5)
+	errs := make([]error, 5)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i], errs[i] = c.WindowBody(Window{Id: i})
+		}(i)
+	}
+	wg.Wait()
+	for i, b := range bodies {
+		if errs[i] != nil {
+			t.Fatalf("request %d failed: %v", i, errs[i])
+		}
+		if expected := fmt.Sprintf("GET /wins/%d/body ", i); b != expected {
+			t.Fatalf("expected the response to request %d to be %q but got %q", i, expected, b)
+		}
+	}
+
+	// Notifications are delivered while /slow still waits.
+	f.write(conn, Notification{WinId: 7, Op: NotificationOpPut})
+	select {
+	case n := <-notes:
+		if n.WinId != 7 || n.Op != NotificationOpPut {
+			t.Fatalf("expected the notification that was sent but got %+v", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the notification was not delivered while a request was pending")
+	}
+	select {
+	case b := <-slow:
+		t.Fatalf("expected /slow to still wait for its response but it got %q", b)
+	default:
+	}
+
+	close(f.release)
+	if b := <-slow; b != "GET /slow " {
+		t.Fatalf("expected the response to /slow but got %q", b)
+	}
+	if len(notes) != 0 {
+		t.Fatalf("expected responses not to be delivered as notifications")
+	}
+}
+
+func TestWebsockClientErrors(t *testing.T) {
+	f := newFakeAnvil(t)
+	defer f.server.Close()
+	defer close(f.release)
+
+	c, err := f.anvil().WebsockClient(WebsockHandlers{})
+	if err != nil {
+		t.Fatalf("connecting failed: %v", err)
+	}
+	<-f.conns
+
+	if _, err := c.Do(http.MethodGet, "/missing", nil); err == nil || !strings.Contains(err.Error(), "(404)") {
+		t.Fatalf("expected a response with a non-success status to be an error but got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c.Do(http.MethodGet, "/slow", nil)
+		done <- err
+	}()
+
+	// Requests waiting for a response fail when the connection is closed.
+	time.Sleep(20 * time.Millisecond)
+	c.Close()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatalf("expected the pending request to fail when the connection was closed")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("the pending request did not fail when the connection was closed")
+	}
+	c.Wait()
+
+	if _, err := c.Do(http.MethodGet, "/wins", nil); err == nil {
+		t.Fatalf("expected a request after Close to fail")
+	}
+}